@@ -0,0 +1,276 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// access log formats of --access-log
+const (
+	logCombined = "combined"
+	logJSON     = "json"
+)
+
+// combinedLogLine matches the nginx/Apache common and combined formats, e.g.
+// 1.2.3.4 - - [10/Oct/2000:13:55:36 -0700] "GET /a.gif HTTP/1.0" 200 2326 ...
+var combinedLogLine = regexp.MustCompile(`^\S+ \S+ \S+ \[([^\]]+)\] "(\S+) (\S+)[^"]*"`)
+
+const combinedTimeLayout = "02/Jan/2006:15:04:05 -0700"
+
+// logEntry is one request of an access log, at offset since the first one
+type logEntry struct {
+	offset time.Duration
+	method string
+	uri    string // path and query
+}
+
+// requestStream replays the requests of an access log in order, each one
+// sent at its original time scaled by speed, or as soon as possible when 0
+type requestStream struct {
+	entries []*logEntry
+	speed   float64
+	// prefix is the path of the base url the requests are sent under
+	prefix string
+	next   uint64
+}
+
+// logFields are the keys of the fields of a JSON log, dotted for nested
+// objects, e.g. time=ts,method=request.method,path=request.uri
+type logFields struct {
+	time, method, path string
+}
+
+func parseLogFields(s string) (*logFields, error) {
+	f := &logFields{time: "time", method: "method", path: "path"}
+	if s == "" {
+		return f, nil
+	}
+	for _, kv := range strings.Split(s, ",") {
+		n := strings.SplitN(strings.TrimSpace(kv), "=", 2)
+		if len(n) != 2 || n[1] == "" {
+			return nil, fmt.Errorf("invalid --log-fields %q: expect FIELD=KEY", kv)
+		}
+		switch n[0] {
+		case "time":
+			f.time = n[1]
+		case "method":
+			f.method = n[1]
+		case "path", "uri", "url":
+			f.path = n[1]
+		default:
+			return nil, fmt.Errorf("invalid --log-fields %q: unknown field %s, expect time, method or path", kv, n[0])
+		}
+	}
+	return f, nil
+}
+
+// loadRequestStream reads the requests of an access log, in the combined
+// format or as JSON lines of the given fields, sorted by time
+func loadRequestStream(path, format string, fields *logFields, speed float64) (*requestStream, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if format == "" {
+		format = logCombined
+		if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+			format = logJSON
+		}
+	}
+	type timedEntry struct {
+		at time.Time
+		*logEntry
+	}
+	var entries []timedEntry
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for n := 1; sc.Scan(); n++ {
+		line := bytes.TrimSpace(sc.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var at time.Time
+		e := &logEntry{}
+		if format == logJSON {
+			at, e.method, e.uri, err = parseJSONLogLine(line, fields)
+		} else {
+			at, e.method, e.uri, err = parseCombinedLogLine(line)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %s", path, n, err)
+		}
+		if u := strings.Index(e.uri, "://"); u >= 0 {
+			// absolute urls of proxy logs keep their path only
+			e.uri = e.uri[u+3:]
+			if i := strings.IndexByte(e.uri, '/'); i >= 0 {
+				e.uri = e.uri[i:]
+			} else {
+				e.uri = "/"
+			}
+		}
+		if !strings.HasPrefix(e.uri, "/") {
+			continue
+		}
+		entries = append(entries, timedEntry{at, e})
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("%s: no request to replay", path)
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].at.Before(entries[j].at) })
+	s := &requestStream{speed: speed}
+	for _, e := range entries {
+		e.offset = e.at.Sub(entries[0].at)
+		s.entries = append(s.entries, e.logEntry)
+	}
+	return s, nil
+}
+
+func parseCombinedLogLine(line []byte) (time.Time, string, string, error) {
+	m := combinedLogLine.FindSubmatch(line)
+	if m == nil {
+		return time.Time{}, "", "", fmt.Errorf("not a combined log line")
+	}
+	at, err := time.Parse(combinedTimeLayout, string(m[1]))
+	if err != nil {
+		return time.Time{}, "", "", err
+	}
+	return at, string(m[2]), string(m[3]), nil
+}
+
+func parseJSONLogLine(line []byte, fields *logFields) (time.Time, string, string, error) {
+	dec := json.NewDecoder(bytes.NewReader(line))
+	dec.UseNumber()
+	var v map[string]interface{}
+	if err := dec.Decode(&v); err != nil {
+		return time.Time{}, "", "", err
+	}
+	at, err := logTime(jsonField(v, fields.time))
+	if err != nil {
+		return time.Time{}, "", "", fmt.Errorf("field %s: %s", fields.time, err)
+	}
+	method, _ := jsonField(v, fields.method).(string)
+	if method == "" {
+		method = "GET"
+	}
+	uri, _ := jsonField(v, fields.path).(string)
+	if uri == "" {
+		return time.Time{}, "", "", fmt.Errorf("no %s field", fields.path)
+	}
+	return at, strings.ToUpper(method), uri, nil
+}
+
+// jsonField returns the value at the dotted key of v, nil if missing
+func jsonField(v map[string]interface{}, key string) interface{} {
+	var cur interface{} = v
+	for _, k := range strings.Split(key, ".") {
+		m, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		cur = m[k]
+	}
+	return cur
+}
+
+// logTime reads an RFC 3339 or combined log time, or a unix time in
+// seconds, or milliseconds when that large
+func logTime(v interface{}) (time.Time, error) {
+	switch t := v.(type) {
+	case json.Number:
+		f, err := t.Float64()
+		if err != nil {
+			return time.Time{}, err
+		}
+		if f > 1e12 {
+			return time.UnixMilli(int64(f)), nil
+		}
+		return time.Unix(0, int64(f*float64(time.Second))), nil
+	case string:
+		if at, err := time.Parse(time.RFC3339Nano, t); err == nil {
+			return at, nil
+		}
+		if at, err := time.Parse(combinedTimeLayout, t); err == nil {
+			return at, nil
+		}
+		if _, err := strconv.ParseFloat(t, 64); err == nil {
+			return logTime(json.Number(t))
+		}
+		return time.Time{}, fmt.Errorf("unknown time format %q", t)
+	}
+	return time.Time{}, fmt.Errorf("missing time")
+}
+
+// take returns the next request of the stream once its time has come,
+// nil at the end of the stream or when ctx is done
+func (s *requestStream) take(ctx context.Context) *logEntry {
+	i := atomic.AddUint64(&s.next, 1) - 1
+	if i >= uint64(len(s.entries)) {
+		return nil
+	}
+	e := s.entries[i]
+	if s.speed > 0 {
+		if wait := time.Until(s.due(e)); wait > 0 {
+			timer := time.NewTimer(wait)
+			defer timer.Stop()
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-timer.C:
+			}
+		}
+	}
+	return e
+}
+
+// due is the time e is sent at in the replay, zero as fast as possible
+func (s *requestStream) due(e *logEntry) time.Time {
+	if s.speed <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, atomic.LoadInt64(&startTimeUnixNano)).Add(time.Duration(float64(e.offset) / s.speed))
+}
+
+// apply sets the method and uri of e on req of target t
+func (s *requestStream) apply(e *logEntry, req *fasthttp.Request, t *target) {
+	req.Header.SetMethod(e.method)
+	t.setRequestURI(req, []byte(s.prefix+e.uri))
+}
+
+// span is the time the replay takes at speed, 0 as fast as possible
+func (s *requestStream) span() time.Duration {
+	if s.speed <= 0 {
+		return 0
+	}
+	return time.Duration(float64(s.entries[len(s.entries)-1].offset) / s.speed)
+}
+
+// replayTolerance is the lag behind its time a replayed request is still on
+// time within
+const replayTolerance = 10 * time.Millisecond
+
+// ReplayReport is how closely the --replay-speed replay kept the times of the
+// access log, Lag being the delay of the requests behind their time, when no
+// connection was free at their time or the rate was bounded
+type ReplayReport struct {
+	Speed   float64
+	Entries int
+	Sent    int64
+	OnTime  int64 // sent within replayTolerance of their time
+	Lag     *ConnLatencyReport
+	MaxLag  time.Duration
+}
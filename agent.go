@@ -0,0 +1,833 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"golang.org/x/time/rate"
+	"gopkg.in/alecthomas/kingpin.v3-unstable"
+)
+
+// recordSource produces the records of a benchmark, either locally or on remote agents
+type recordSource interface {
+	Run()
+	Cancel()
+	Interrupt()
+	Interrupted() <-chan struct{}
+	RecordChan() <-chan *ReportRecord
+	Targets() *targetPool
+	Backpressure() *backpressure
+}
+
+// AgentJob is the share of a benchmark a controller sends to one agent
+type AgentJob struct {
+	URLs          []string      `json:"urls"`
+	Weights       []int         `json:"weights,omitempty"`
+	Method        string        `json:"method"`
+	Headers       []string      `json:"headers,omitempty"`
+	TraceHeader   string        `json:"traceHeader,omitempty"` // NAME:FORMAT
+	Body          []byte        `json:"body,omitempty"`
+	ContentType   string        `json:"contentType,omitempty"`
+	Host          string        `json:"host,omitempty"`
+	SNI           string        `json:"sni,omitempty"`
+	ConnectTo     string        `json:"connectTo,omitempty"`
+	Resolver      string        `json:"resolver,omitempty"`
+	DNSRefresh    time.Duration `json:"dnsRefresh,omitempty"`
+	ResolveOnce   bool          `json:"resolveOnce,omitempty"`
+	DNSRoundRobin bool          `json:"dnsRoundRobin,omitempty"`
+	Insecure      bool          `json:"insecure,omitempty"`
+	Concurrency   int           `json:"concurrency"`
+	Requests      int64         `json:"requests"`
+	Duration      time.Duration `json:"duration"`
+	Rate          float64       `json:"rate,omitempty"`     // requests per second, 0 for unlimited
+	ConnRate      float64       `json:"connRate,omitempty"` // new connections per second, 0 for unlimited
+	RampUp        int           `json:"rampUp"`
+	Timeout       time.Duration `json:"timeout,omitempty"`
+	StaleConn     string        `json:"staleConn,omitempty"`
+	Stages        []*AgentStage `json:"stages,omitempty"`
+
+	ProxyProtocol int      `json:"proxyProtocol,omitempty"`
+	ProxySources  []string `json:"proxySources,omitempty"`
+	Proxies       []string `json:"proxies,omitempty"`
+
+	GraphQL  bool                     `json:"graphql,omitempty"`
+	Template bool                     `json:"template,omitempty"`
+	DataRows []map[string]interface{} `json:"dataRows,omitempty"`
+	DataMode string                   `json:"dataMode,omitempty"`
+	Captures []string                 `json:"captures,omitempty"`
+
+	Extracts    []string `json:"extracts,omitempty"`
+	ExtractRate float64  `json:"extractRate,omitempty"`
+
+	TargetRequests []*targetRequest `json:"targetRequests,omitempty"`
+	Sequence       bool             `json:"sequence,omitempty"`
+	CookieJar      bool             `json:"cookieJar,omitempty"`
+	User           string           `json:"user,omitempty"`
+	Digest         bool             `json:"digest,omitempty"`
+
+	FollowRedirects int  `json:"followRedirects,omitempty"`
+	RedirectFailure bool `json:"redirectFailure,omitempty"`
+
+	Validate     string  `json:"validate,omitempty"`
+	ValidateRate float64 `json:"validateRate,omitempty"`
+	HashRate     float64 `json:"hashRate,omitempty"` // of --hash-bodies, 0 without
+
+	GRPC            string  `json:"grpc,omitempty"`
+	GRPCMessages    int     `json:"grpcMessages,omitempty"`
+	GRPCMessageRate float64 `json:"grpcMessageRate,omitempty"`
+	GRPCStreams     int     `json:"grpcStreams,omitempty"`
+
+	// TCP is the raw TCP mode of the tcp:// and tls:// urls
+	TCP             bool   `json:"tcp,omitempty"`
+	TCPResponseSize int    `json:"tcpResponseSize,omitempty"`
+	TCPDelimiter    []byte `json:"tcpDelimiter,omitempty"`
+
+	// DNSQueries are the names of --dns-query, of DNSType
+	DNSQueries []string `json:"dnsQueries,omitempty"`
+	DNSType    string   `json:"dnsType,omitempty"`
+
+	// MQTTTopics are the topics of the mqtt:// and mqtts:// urls
+	MQTTTopics []string `json:"mqttTopics,omitempty"`
+	MQTTQoS    int      `json:"mqttQoS,omitempty"`
+
+	DisableKeepalive bool   `json:"disableKeepalive,omitempty"`
+	Compressed       bool   `json:"compressed,omitempty"`
+	Pipeline         int    `json:"pipeline,omitempty"`
+	ThinkTime        string `json:"thinkTime,omitempty"`
+	Arrival          string `json:"arrival,omitempty"`
+	MaxQueue         int    `json:"maxQueue,omitempty"`
+
+	DialTimeout  time.Duration `json:"dialTimeout,omitempty"`
+	TLSTimeout   time.Duration `json:"tlsTimeout,omitempty"`
+	ReadTimeout  time.Duration `json:"readTimeout,omitempty"`
+	WriteTimeout time.Duration `json:"writeTimeout,omitempty"`
+	Grace        time.Duration `json:"grace,omitempty"`
+
+	JWTKey    []byte        `json:"jwtKey,omitempty"`
+	JWTClaims []byte        `json:"jwtClaims,omitempty"`
+	JWTAlg    string        `json:"jwtAlg,omitempty"`
+	JWTTTL    time.Duration `json:"jwtTTL,omitempty"`
+	JWTHeader string        `json:"jwtHeader,omitempty"`
+
+	// each agent fetches its own OAuth2 tokens
+	OAuth2TokenURL string `json:"oauth2TokenURL,omitempty"`
+	OAuth2Client   string `json:"oauth2Client,omitempty"`
+	OAuth2User     string `json:"oauth2User,omitempty"`
+	OAuth2Scope    string `json:"oauth2Scope,omitempty"`
+
+	// kerberos files are read on the agent
+	NTLM      string `json:"ntlm,omitempty"`
+	Negotiate bool   `json:"negotiate,omitempty"`
+	Keytab    string `json:"keytab,omitempty"`
+	Principal string `json:"principal,omitempty"`
+	SPN       string `json:"spn,omitempty"`
+}
+
+// AgentStage is a stage of an AgentJob, thresholds are checked by the controller
+type AgentStage struct {
+	Duration time.Duration `json:"duration"`
+	Rate     float64       `json:"rate,omitempty"`
+}
+
+// agentRecord is the wire form of a ReportRecord
+type agentRecord struct {
+	Target        int
+	Stage         int
+	Cost          time.Duration
+	AuthCost      time.Duration
+	Code          int
+	Error         string
+	ErrClass      int
+	GQLError      string
+	ServerTimings []ServerTiming
+	Phases        phaseTimes
+	Cold          bool
+	Stale         int
+	Proxy         int
+	Addr          string
+	Extracted     []float64
+	Redirects     int
+	ReqSize       int64
+	RespSize      int64
+	DecodedSize   int64
+	Validated     bool
+	Malformed     string
+	BodyHash      uint64
+	BodySample    string
+	TraceID       string
+	GRPCStatus    string
+	Rcode         string
+	MsgSent       int
+	MsgRecv       int
+	MsgLatencies  []time.Duration
+	QueueWait     time.Duration
+	ReadBytes     int64
+	WriteBytes    int64
+	Dropped       int64
+	Concurrency   int
+}
+
+func newAgentJob(opt *ClientOpt, concurrency int, requests int64, duration time.Duration, limit *rate.Limit, rampUp int, stages []*Stage) *AgentJob {
+	job := &AgentJob{
+		URLs:        opt.urls,
+		Weights:     opt.weights,
+		Method:      opt.method,
+		Headers:     opt.headers,
+		Body:        opt.bodyBytes,
+		ContentType: opt.contentType,
+		Host:        opt.host,
+		SNI:         opt.sni,
+		ConnectTo:   opt.connectTo,
+		Insecure:    opt.insecure,
+		Concurrency: concurrency,
+		Requests:    requests,
+		Duration:    duration,
+		RampUp:      rampUp,
+		Timeout:     opt.doTimeout,
+		StaleConn:   opt.staleConn,
+
+		ProxyProtocol: opt.proxyProtocol,
+		Proxies:       opt.proxies,
+		GraphQL:       opt.graphql,
+		Template:      opt.templating,
+		ExtractRate:   opt.extractRate,
+
+		TargetRequests: opt.targetRequests,
+		Sequence:       opt.sequence,
+		CookieJar:      opt.cookieJar,
+		User:           opt.user,
+		Digest:         opt.digest,
+
+		FollowRedirects: opt.followRedirects,
+		RedirectFailure: opt.redirectFailure,
+
+		Validate:     opt.validate,
+		ValidateRate: opt.validateRate,
+
+		GRPC:            opt.grpc,
+		GRPCMessages:    opt.grpcMessages,
+		GRPCMessageRate: opt.grpcMessageRate,
+		GRPCStreams:     opt.grpcStreams,
+
+		DisableKeepalive: opt.disableKeepalive,
+		Compressed:       opt.compressed,
+		Pipeline:         opt.pipeline,
+
+		DialTimeout:  opt.dialTimeout,
+		TLSTimeout:   opt.tlsTimeout,
+		ReadTimeout:  opt.readTimeout,
+		WriteTimeout: opt.writeTimeout,
+		Grace:        opt.grace,
+	}
+	if opt.data != nil {
+		job.DataRows, job.DataMode = opt.data.rows, opt.data.mode
+	}
+	if opt.thinkTime != nil {
+		job.ThinkTime = opt.thinkTime.String()
+	}
+	job.Arrival, job.MaxQueue = opt.arrival, opt.maxQueue
+	if th := opt.traceHeader; th != nil {
+		job.TraceHeader = th.name + ":" + th.format
+	}
+	if opt.hasher != nil {
+		job.HashRate = opt.hasher.rate
+	}
+	if opt.tcp != nil {
+		job.TCP, job.TCPResponseSize, job.TCPDelimiter = true, opt.tcp.size, opt.tcp.delimiter
+	}
+	if opt.dnsQuery != nil {
+		job.DNSQueries, job.DNSType = opt.dnsQuery.names.items, opt.dnsQuery.typeName
+	}
+	if opt.mqtt != nil {
+		job.MQTTTopics, job.MQTTQoS = opt.mqtt.topics.items, opt.mqtt.qos
+	}
+	for _, c := range opt.captures {
+		job.Captures = append(job.Captures, c.String())
+	}
+	for _, e := range opt.extractors {
+		job.Extracts = append(job.Extracts, e.name+"="+e.path)
+	}
+	for _, src := range opt.proxySources {
+		job.ProxySources = append(job.ProxySources, src.String())
+	}
+	if limit != nil {
+		job.Rate = float64(*limit)
+	}
+	if opt.connLimiter != nil {
+		job.ConnRate = float64(opt.connLimiter.Limit())
+	}
+	for _, st := range stages {
+		as := &AgentStage{Duration: st.Duration}
+		if st.Rate != nil {
+			as.Rate = float64(*st.Rate)
+		}
+		job.Stages = append(job.Stages, as)
+	}
+	return job
+}
+
+// split returns the share of the job for agent i of n
+func (j *AgentJob) split(i, n int) *AgentJob {
+	share := func(total int64) int64 {
+		if total < 0 {
+			return total
+		}
+		q := total / int64(n)
+		if int64(i) < total%int64(n) {
+			q++
+		}
+		return q
+	}
+	job := *j
+	job.Concurrency = int(share(int64(j.Concurrency)))
+	if job.Concurrency < 1 {
+		job.Concurrency = 1
+	}
+	job.Requests = share(j.Requests)
+	if len(j.DataRows) > 0 {
+		job.DataRows = (&dataFeed{rows: j.DataRows, mode: j.DataMode}).part(i, n)
+	}
+	job.Rate = j.Rate / float64(n)
+	job.ConnRate = j.ConnRate / float64(n)
+	job.Stages = nil
+	for _, st := range j.Stages {
+		job.Stages = append(job.Stages, &AgentStage{Duration: st.Duration, Rate: st.Rate / float64(n)})
+	}
+	return &job
+}
+
+func (j *AgentJob) newRequester(errWriter io.Writer) (*Requester, error) {
+	opt := &ClientOpt{
+		urls:        j.URLs,
+		weights:     j.Weights,
+		method:      j.Method,
+		headers:     j.Headers,
+		bodyBytes:   j.Body,
+		contentType: j.ContentType,
+		host:        j.Host,
+		sni:         j.SNI,
+		connectTo:   j.ConnectTo,
+		insecure:    j.Insecure,
+		maxConns:    j.Concurrency,
+		doTimeout:   j.Timeout,
+		staleConn:   j.StaleConn,
+
+		proxyProtocol: j.ProxyProtocol,
+		proxies:       j.Proxies,
+		graphql:       j.GraphQL,
+		templating:    j.Template,
+		extractRate:   j.ExtractRate,
+
+		targetRequests: j.TargetRequests,
+		sequence:       j.Sequence,
+		cookieJar:      j.CookieJar,
+		user:           j.User,
+		digest:         j.Digest,
+
+		followRedirects: j.FollowRedirects,
+		redirectFailure: j.RedirectFailure,
+
+		validate:     j.Validate,
+		validateRate: j.ValidateRate,
+
+		grpc:            j.GRPC,
+		grpcMessages:    j.GRPCMessages,
+		grpcMessageRate: j.GRPCMessageRate,
+		grpcStreams:     j.GRPCStreams,
+
+		disableKeepalive: j.DisableKeepalive,
+		compressed:       j.Compressed,
+		pipeline:         j.Pipeline,
+
+		dialTimeout:  j.DialTimeout,
+		tlsTimeout:   j.TLSTimeout,
+		readTimeout:  j.ReadTimeout,
+		writeTimeout: j.WriteTimeout,
+		grace:        j.Grace,
+	}
+	if len(j.DataRows) > 0 {
+		opt.data = &dataFeed{rows: j.DataRows, mode: j.DataMode}
+	}
+	if j.ThinkTime != "" {
+		t, err := parseThinkTime(j.ThinkTime)
+		if err != nil {
+			return nil, err
+		}
+		opt.thinkTime = t
+	}
+	opt.arrival, opt.maxQueue = j.Arrival, j.MaxQueue
+	if j.TraceHeader != "" {
+		th, err := parseTraceHeader(j.TraceHeader)
+		if err != nil {
+			return nil, err
+		}
+		opt.traceHeader = th
+	}
+	if j.HashRate > 0 {
+		opt.hasher = &bodyHasher{rate: j.HashRate}
+	}
+	if j.TCP {
+		opt.tcp = &tcpMode{size: j.TCPResponseSize, delimiter: j.TCPDelimiter}
+	}
+	if len(j.DNSQueries) > 0 {
+		dnsQuery, err := newDNSMode(j.DNSQueries, j.DNSType)
+		if err != nil {
+			return nil, err
+		}
+		opt.dnsQuery = dnsQuery
+	}
+	if len(j.MQTTTopics) > 0 {
+		mqtt, err := newMQTTMode(j.MQTTTopics, j.MQTTQoS, nil)
+		if err != nil {
+			return nil, err
+		}
+		opt.mqtt = mqtt
+	}
+	captures, err := parseHeaderCaptures(j.Captures)
+	if err != nil {
+		return nil, err
+	}
+	opt.captures = captures
+	for _, s := range j.Extracts {
+		e, err := parseExtractor(s)
+		if err != nil {
+			return nil, err
+		}
+		opt.extractors = append(opt.extractors, e)
+	}
+	for _, ps := range j.ProxySources {
+		src, err := parseProxySource(ps)
+		if err != nil {
+			return nil, err
+		}
+		opt.proxySources = append(opt.proxySources, src)
+	}
+	auth, err := newAuthScheme(j.NTLM, opt.digestUser(), j.Negotiate, j.Keytab, j.Principal, j.SPN)
+	if err != nil {
+		return nil, err
+	}
+	opt.auth = auth
+	if opt.dns, err = newDNSResolver(j.Resolver, j.DNSRefresh, j.ResolveOnce, j.DNSRoundRobin); err != nil {
+		return nil, err
+	}
+	if len(j.JWTKey) > 0 {
+		if opt.jwt, err = newJWTMinter(j.JWTKey, j.JWTClaims, j.JWTAlg, j.JWTTTL, j.JWTHeader); err != nil {
+			return nil, err
+		}
+	}
+	if j.OAuth2TokenURL != "" {
+		if opt.oauth2, err = newOAuth2Source(j.OAuth2TokenURL, j.OAuth2Client, j.OAuth2User, j.OAuth2Scope, j.Insecure); err != nil {
+			return nil, err
+		}
+		if _, err = opt.oauth2.get(); err != nil {
+			return nil, err
+		}
+	}
+	if j.ConnRate > 0 {
+		opt.connLimiter = rate.NewLimiter(rate.Limit(j.ConnRate), 1)
+	}
+	var limit *rate.Limit
+	if j.Rate > 0 {
+		l := rate.Limit(j.Rate)
+		limit = &l
+	}
+	var stages []*Stage
+	for _, as := range j.Stages {
+		st := &Stage{Duration: as.Duration}
+		if as.Rate > 0 {
+			l := rate.Limit(as.Rate)
+			st.Rate = &l
+		}
+		stages = append(stages, st)
+	}
+	r, err := NewRequester(j.Concurrency, j.Requests, j.Duration, limit, errWriter, opt, j.RampUp)
+	if err != nil {
+		return nil, err
+	}
+	r.stages = stages
+	return r, nil
+}
+
+// Agent runs the jobs sent by a controller and streams the records back
+type Agent struct {
+	ln    net.Listener
+	token string // of --token, "" for none
+	busy  int32
+
+	mu        sync.Mutex
+	requester *Requester // of the running job
+}
+
+func (a *Agent) Handler(ctx *fasthttp.RequestCtx) {
+	if !agentAuthorized(ctx, a.token) {
+		ctx.Error("the --token of the agent is required", fasthttp.StatusUnauthorized)
+		return
+	}
+	switch string(ctx.Path()) {
+	case "/status":
+		ctx.SetContentType("application/json")
+		_ = json.NewEncoder(ctx).Encode(map[string]interface{}{"busy": atomic.LoadInt32(&a.busy) == 1, "version": version})
+	case "/run":
+		a.handleRun(ctx)
+	case "/stop":
+		// the job stops like on ctrl-c, its last records still streamed
+		a.mu.Lock()
+		if a.requester != nil {
+			a.requester.Cancel()
+		}
+		a.mu.Unlock()
+	default:
+		ctx.Error("NotFound", fasthttp.StatusNotFound)
+	}
+}
+
+func (a *Agent) handleRun(ctx *fasthttp.RequestCtx) {
+	var job AgentJob
+	if err := json.Unmarshal(ctx.PostBody(), &job); err != nil {
+		ctx.Error("invalid job: "+err.Error(), fasthttp.StatusBadRequest)
+		return
+	}
+	if !atomic.CompareAndSwapInt32(&a.busy, 0, 1) {
+		ctx.Error("agent is busy", fasthttp.StatusConflict)
+		return
+	}
+	requester, err := job.newRequester(io.Discard)
+	if err != nil {
+		atomic.StoreInt32(&a.busy, 0)
+		ctx.Error(err.Error(), fasthttp.StatusBadRequest)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "plow agent: running %d connection(s) against %v for %s\n", job.Concurrency, job.URLs, ctx.RemoteAddr())
+
+	ctx.SetContentType("application/octet-stream")
+	ctx.SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer atomic.StoreInt32(&a.busy, 0)
+		go requester.Run()
+		a.mu.Lock()
+		a.requester = requester
+		a.mu.Unlock()
+		defer func() {
+			a.mu.Lock()
+			a.requester = nil
+			a.mu.Unlock()
+		}()
+
+		enc := gob.NewEncoder(w)
+		batch := make([]agentRecord, 0, 1024)
+		ticker := time.NewTicker(100 * time.Millisecond)
+		defer ticker.Stop()
+		failed := false
+		flush := func() {
+			if failed || len(batch) == 0 {
+				return
+			}
+			if enc.Encode(batch) != nil || w.Flush() != nil {
+				// the controller went away
+				failed = true
+				requester.Cancel()
+			}
+			batch = batch[:0]
+		}
+		records := requester.RecordChan()
+		for {
+			select {
+			case rr, ok := <-records:
+				if !ok {
+					flush()
+					fmt.Fprintln(os.Stderr, "plow agent: job done")
+					return
+				}
+				batch = append(batch, agentRecord{
+					Target: rr.target, Stage: rr.stage, Cost: rr.cost, AuthCost: rr.authCost, Code: rr.code, Error: rr.error, ErrClass: rr.errClass, GQLError: rr.graphqlError, Phases: rr.phases, Cold: rr.cold, Stale: rr.stale, Proxy: rr.proxy, Addr: rr.addr,
+					Redirects: rr.redirects, ReqSize: rr.reqSize, RespSize: rr.respSize, DecodedSize: rr.decodedSize,
+					Validated: rr.validated, Malformed: rr.malformed, BodyHash: rr.bodyHash, BodySample: rr.bodySample, TraceID: rr.traceID, GRPCStatus: rr.grpcStatus, Rcode: rr.rcode, MsgSent: rr.msgSent, MsgRecv: rr.msgRecv, QueueWait: rr.queueWait, ReadBytes: rr.readBytes, WriteBytes: rr.writeBytes, Dropped: rr.dropped, Concurrency: rr.concurrencyCount,
+				})
+				if len(rr.msgLatencies) > 0 {
+					batch[len(batch)-1].MsgLatencies = append([]time.Duration(nil), rr.msgLatencies...)
+				}
+				if len(rr.serverTimings) > 0 {
+					batch[len(batch)-1].ServerTimings = append([]ServerTiming(nil), rr.serverTimings...)
+				}
+				if len(rr.extracted) > 0 {
+					batch[len(batch)-1].Extracted = append([]float64(nil), rr.extracted...)
+				}
+				recordPool.Put(rr)
+				if len(batch) == cap(batch) {
+					flush()
+				}
+			case <-ticker.C:
+				flush()
+			}
+		}
+	})
+}
+
+func runAgentCommand(args []string) {
+	app := kingpin.New("plow agent", "Run benchmark jobs sent by a plow controller (plow --agent host:port ...)")
+	listen := app.Flag("listen", "Listen addr for the controller, e.g. :19999 for all the interfaces").Default("127.0.0.1:19999").String()
+	token := app.Flag("token", "Require this bearer token of the --agent-token of the controller").Envar("PLOW_AGENT_TOKEN").PlaceHolder("TOKEN").String()
+	tlsCert := app.Flag("tls-cert", "Serve the controller over TLS with this certificate, along --tls-key").PlaceHolder("FILE").ExistingFile()
+	tlsKey := app.Flag("tls-key", "Private key of --tls-cert").PlaceHolder("FILE").ExistingFile()
+	clientCA := app.Flag("tls-client-ca", "Require a client certificate of this CA from the controller, its --agent-cert").PlaceHolder("FILE").ExistingFile()
+	app.Version(version)
+	kingpin.MustParse(app.Parse(args))
+
+	ln, err := agentListen(*listen, *tlsCert, *tlsKey, *clientCA)
+	if err != nil {
+		errAndExit(err.Error())
+		return
+	}
+	fmt.Fprintf(os.Stderr, "plow agent is listening on %s\n", ln.Addr())
+	// the jobs carry the credentials of the target
+	agentWarnExposed("agent", ln, *token, *tlsCert)
+	a := &Agent{ln: ln, token: *token}
+	server := fasthttp.Server{Handler: a.Handler}
+	if err := server.Serve(ln); err != nil {
+		errAndExit(err.Error())
+	}
+}
+
+// Controller fans a benchmark out to agents and merges their record streams
+type Controller struct {
+	agents []string
+	job    *AgentJob
+	// regions labeling the agents, with the index of the region of each agent
+	regions  []*agentRegion
+	regionOf []int
+
+	transport  *agentTransport
+	recordChan chan *ReportRecord
+	cancel     func()
+	errWriter  io.Writer
+	stalls     backpressure
+	// interrupted is closed when the run was stopped before its end, the
+	// agents draining their requests in flight
+	interrupted   chan struct{}
+	interruptOnce sync.Once
+
+	lock       sync.Mutex
+	readBytes  []int64
+	writeBytes []int64
+	dropped    []int64
+	conc       []int
+}
+
+// NewController runs job on the agents, given as HOST:PORT or REGION=HOST:PORT
+// and reached through transport
+func NewController(agents []string, job *AgentJob, transport *agentTransport, errWriter io.Writer) *Controller {
+	addrs, regions, regionOf := parseAgents(agents)
+	return &Controller{
+		agents:      addrs,
+		job:         job,
+		transport:   transport,
+		regions:     regions,
+		regionOf:    regionOf,
+		errWriter:   errWriter,
+		recordChan:  make(chan *ReportRecord, 8192),
+		interrupted: make(chan struct{}),
+		readBytes:   make([]int64, len(agents)),
+		writeBytes:  make([]int64, len(agents)),
+		dropped:     make([]int64, len(agents)),
+		conc:        make([]int, len(agents)),
+	}
+}
+
+func (c *Controller) RecordChan() <-chan *ReportRecord {
+	return c.recordChan
+}
+
+func (c *Controller) Backpressure() *backpressure {
+	return &c.stalls
+}
+
+func (c *Controller) Targets() *targetPool {
+	return nil
+}
+
+func (c *Controller) Cancel() {
+	if c.cancel != nil {
+		c.cancel()
+	}
+}
+
+// agentFlushDelay is the time for the last records of the agents to arrive
+// after their grace period
+const agentFlushDelay = time.Second
+
+// Interrupt stops the jobs of the agents, which stream the records of their
+// requests in flight until their grace period is over
+func (c *Controller) Interrupt() {
+	c.interruptOnce.Do(func() {
+		close(c.interrupted)
+		for _, addr := range c.agents {
+			go func(addr string) {
+				req, err := http.NewRequest(http.MethodPost, c.transport.url(addr, "/stop"), nil)
+				if err != nil {
+					return
+				}
+				if resp, err := c.transport.do(req); err == nil {
+					resp.Body.Close()
+				}
+			}(addr)
+		}
+		time.AfterFunc(c.job.Grace+agentFlushDelay, c.Cancel)
+	})
+}
+
+func (c *Controller) Interrupted() <-chan struct{} {
+	return c.interrupted
+}
+
+func (c *Controller) Run() {
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+	go func() {
+		select {
+		case <-sigs:
+			c.Interrupt()
+		case <-ctx.Done():
+			return
+		}
+		// a second one does not wait for the agents
+		select {
+		case <-sigs:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	atomic.StoreInt64(&startTimeUnixNano, time.Now().UnixNano())
+	var wg sync.WaitGroup
+	for i, addr := range c.agents {
+		wg.Add(1)
+		go func(i int, addr string) {
+			defer wg.Done()
+			if err := c.runAgent(ctx, i, addr); err != nil && ctx.Err() == nil {
+				fmt.Fprintf(c.errWriter, "agent %s: %s\n", addr, err)
+				rr := recordPool.Get().(*ReportRecord)
+				*rr = ReportRecord{stage: -1, proxy: -1, region: c.region(i), error: "agent " + addr + ": " + err.Error()}
+				c.recordChan <- rr
+			}
+		}(i, addr)
+	}
+	wg.Wait()
+	cancel()
+	close(c.recordChan)
+}
+
+// region is the index of the region of agent i, -1 without regions
+func (c *Controller) region(i int) int {
+	if i < len(c.regionOf) {
+		return c.regionOf[i]
+	}
+	return -1
+}
+
+func (c *Controller) runAgent(ctx context.Context, i int, addr string) error {
+	body, err := json.Marshal(c.job.split(i, len(c.agents)))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", c.transport.url(addr, "/run"), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.transport.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+
+	dec := gob.NewDecoder(bufio.NewReader(resp.Body))
+	for {
+		batch, err := decodeAgentBatch(dec)
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		for _, ar := range batch {
+			rr := recordPool.Get().(*ReportRecord)
+			rr.target = ar.Target
+			rr.stage = ar.Stage
+			rr.cost = ar.Cost
+			rr.authCost = ar.AuthCost
+			rr.code = ar.Code
+			rr.error = ar.Error
+			rr.errClass = ar.ErrClass
+			rr.graphqlError = ar.GQLError
+			rr.serverTimings = append(rr.serverTimings[:0], ar.ServerTimings...)
+			rr.phases = ar.Phases
+			rr.cold = ar.Cold
+			rr.stale = ar.Stale
+			rr.proxy = ar.Proxy
+			rr.region = c.region(i)
+			rr.addr = ar.Addr
+			rr.extracted = append(rr.extracted[:0], ar.Extracted...)
+			rr.redirects = ar.Redirects
+			rr.reqSize, rr.respSize, rr.decodedSize = ar.ReqSize, ar.RespSize, ar.DecodedSize
+			rr.validated, rr.malformed = ar.Validated, ar.Malformed
+			rr.bodyHash, rr.bodySample = ar.BodyHash, ar.BodySample
+			rr.traceID = ar.TraceID
+			rr.msgSent, rr.msgRecv = ar.MsgSent, ar.MsgRecv
+			rr.grpcStatus = ar.GRPCStatus
+			rr.rcode = ar.Rcode
+			rr.msgLatencies = append(rr.msgLatencies[:0], ar.MsgLatencies...)
+			rr.queueWait = ar.QueueWait
+			rr.readBytes, rr.writeBytes, rr.dropped, rr.concurrencyCount = c.merge(i, &ar)
+			c.stalls.send(c.recordChan, rr)
+		}
+	}
+}
+
+// decodeAgentBatch decodes the next batch of records of an agent into a new
+// slice, gob leaving out the zero fields which would keep the values of the
+// previous batch in reused elements
+func decodeAgentBatch(dec *gob.Decoder) ([]agentRecord, error) {
+	var batch []agentRecord
+	if err := dec.Decode(&batch); err != nil {
+		return nil, err
+	}
+	return batch, nil
+}
+
+// merge turns the cumulative counters of one agent into cluster-wide ones
+func (c *Controller) merge(i int, ar *agentRecord) (readBytes, writeBytes, dropped int64, conc int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.readBytes[i] = ar.ReadBytes
+	c.writeBytes[i] = ar.WriteBytes
+	c.dropped[i] = ar.Dropped
+	c.conc[i] = ar.Concurrency
+	for j := range c.agents {
+		readBytes += c.readBytes[j]
+		writeBytes += c.writeBytes[j]
+		dropped += c.dropped[j]
+		conc += c.conc[j]
+	}
+	return
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestDecodeAgentBatch checks that a batch decodes clean after one whose
+// fields gob sent and the next one leaves out as zero
+func TestDecodeAgentBatch(t *testing.T) {
+	failed := agentRecord{
+		Target: 1, Cost: time.Second, Error: "timeout", ErrClass: 3, GQLError: "bad query", Cold: true, Stale: 2,
+		Redirects: 3, Malformed: "not json", Validated: true, Extracted: []float64{1.5}, Rcode: "NXDOMAIN",
+	}
+	ok := agentRecord{Target: 1, Cost: time.Millisecond, Code: 200}
+
+	var buf bytes.Buffer
+	enc := gob.NewEncoder(&buf)
+	for _, batch := range [][]agentRecord{{failed, failed}, {ok}} {
+		if err := enc.Encode(batch); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dec := gob.NewDecoder(&buf)
+	first, err := decodeAgentBatch(dec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first) != 2 || !reflect.DeepEqual(first[0], failed) {
+		t.Fatalf("first batch %+v", first)
+	}
+	second, err := decodeAgentBatch(dec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(second) != 1 || !reflect.DeepEqual(second[0], ok) {
+		t.Errorf("second batch %+v, want %+v", second, ok)
+	}
+	// the records of the first batch were not overwritten
+	if first[0].Error != "timeout" || first[0].Extracted[0] != 1.5 {
+		t.Errorf("first batch changed %+v", first[0])
+	}
+}
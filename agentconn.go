@@ -0,0 +1,138 @@
+package main
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+)
+
+// agentTransport is how a controller reaches its agents and probes: the
+// shared token of their --token, sent as a bearer token, and the TLS of
+// those serving with --tls-cert
+type agentTransport struct {
+	token  string
+	secure bool // the agents given as HOST:PORT are https:// ones
+	client *http.Client
+}
+
+// newAgentTransport trusts the agents of the certificate authority ca, the
+// system ones without, and presents cert and key to those asking for a
+// client certificate
+func newAgentTransport(token, ca, cert, key string) (*agentTransport, error) {
+	t := &agentTransport{token: token, client: http.DefaultClient}
+	if ca == "" && cert == "" {
+		return t, nil
+	}
+	if (cert == "") != (key == "") {
+		return nil, errors.New("--agent-cert and --agent-key go together")
+	}
+	cfg := &tls.Config{}
+	if ca != "" {
+		pem, err := os.ReadFile(ca)
+		if err != nil {
+			return nil, err
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("--agent-ca %s holds no PEM certificate", ca)
+		}
+	}
+	if cert != "" {
+		c, err := tls.LoadX509KeyPair(cert, key)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{c}
+	}
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	tr.TLSClientConfig = cfg
+	t.secure, t.client = true, &http.Client{Transport: tr}
+	return t, nil
+}
+
+// url is the url of path on the agent or probe addr, given as HOST:PORT or
+// as an http:// or https:// url
+func (t *agentTransport) url(addr, path string) string {
+	if strings.HasPrefix(addr, "http://") || strings.HasPrefix(addr, "https://") {
+		return addr + path
+	}
+	if t.secure {
+		return "https://" + addr + path
+	}
+	return "http://" + addr + path
+}
+
+// do sends req with the token
+func (t *agentTransport) do(req *http.Request) (*http.Response, error) {
+	if t.token != "" {
+		req.Header.Set("Authorization", "Bearer "+t.token)
+	}
+	return t.client.Do(req)
+}
+
+// agentListen listens on addr for the controllers, over TLS with cert and
+// key, asking them for a certificate of clientCA with one
+func agentListen(addr, cert, key, clientCA string) (net.Listener, error) {
+	if (cert == "") != (key == "") {
+		return nil, errors.New("--tls-cert and --tls-key go together")
+	}
+	if clientCA != "" && cert == "" {
+		return nil, errors.New("--tls-client-ca goes with --tls-cert")
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil || cert == "" {
+		return ln, err
+	}
+	c, err := tls.LoadX509KeyPair(cert, key)
+	if err != nil {
+		ln.Close()
+		return nil, err
+	}
+	cfg := &tls.Config{Certificates: []tls.Certificate{c}}
+	if clientCA != "" {
+		pem, err := os.ReadFile(clientCA)
+		if err != nil {
+			ln.Close()
+			return nil, err
+		}
+		cfg.ClientCAs = x509.NewCertPool()
+		if !cfg.ClientCAs.AppendCertsFromPEM(pem) {
+			ln.Close()
+			return nil, fmt.Errorf("--tls-client-ca %s holds no PEM certificate", clientCA)
+		}
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tls.NewListener(ln, cfg), nil
+}
+
+// agentAuthorized tells whether ctx presents the token of an agent or
+// probe, any request being allowed without
+func agentAuthorized(ctx *fasthttp.RequestCtx, token string) bool {
+	if token == "" {
+		return true
+	}
+	got, _ := strings.CutPrefix(string(ctx.Request.Header.Peek("Authorization")), "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
+
+// agentWarnExposed warns when an agent or probe listens beyond the loopback
+// without a token or TLS
+func agentWarnExposed(name string, ln net.Listener, token, cert string) {
+	if addr, ok := ln.Addr().(*net.TCPAddr); ok && addr.IP.IsLoopback() {
+		return
+	}
+	if token == "" {
+		fmt.Fprintf(os.Stderr, "⚠️  plow %s accepts anyone on %s without --token\n", name, ln.Addr())
+	}
+	if cert == "" {
+		fmt.Fprintf(os.Stderr, "⚠️  plow %s exchanges in clear on %s without --tls-cert\n", name, ln.Addr())
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// arrival models of --arrival
+const (
+	arrivalFixed   = "fixed"
+	arrivalPoisson = "poisson"
+)
+
+// arrivals is the open-loop generator of --arrival poisson: the requests
+// arrive at exponential intervals of the mean of the rate whether or not the
+// connections keep up, and wait in a bounded queue for a free one. The wait
+// is reported apart from the latency, rather than slowing the arrivals down
+// as the closed loop of the fixed rate does (the coordinated omission).
+type arrivals struct {
+	queue   chan time.Time // the scheduled time of each arrival
+	dropped int64          // arrivals that found the queue full
+}
+
+func newArrivals(maxQueue int) *arrivals {
+	return &arrivals{queue: make(chan time.Time, maxQueue)}
+}
+
+// run schedules the arrivals at the rate of limiter, as the stages set it,
+// until ctx is done
+func (a *arrivals) run(ctx context.Context, limiter *rate.Limiter) {
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	next := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+		now := time.Now()
+		switch limit := limiter.Limit(); {
+		case limit == rate.Inf:
+			// a stage without rate is a closed loop
+			select {
+			case <-ctx.Done():
+				return
+			case a.queue <- now:
+			}
+			next = time.Now()
+		case limit <= 0:
+			next = now.Add(100 * time.Millisecond)
+		default:
+			// the arrivals due since the last wake up keep their schedule
+			for !next.After(now) {
+				select {
+				case a.queue <- next:
+				default:
+					atomic.AddInt64(&a.dropped, 1)
+				}
+				next = next.Add(time.Duration(rng.ExpFloat64() / float64(limit) * float64(time.Second)))
+			}
+		}
+		timer.Reset(time.Until(next))
+	}
+}
+
+// take waits for the next arrival, false when ctx is done meanwhile
+func (a *arrivals) take(ctx context.Context) (time.Time, bool) {
+	select {
+	case <-ctx.Done():
+		return time.Time{}, false
+	case t := <-a.queue:
+		return t, true
+	}
+}
+
+// droppedCount is the arrivals dropped so far, 0 without generator
+func (a *arrivals) droppedCount() int64 {
+	if a == nil {
+		return 0
+	}
+	return atomic.LoadInt64(&a.dropped)
+}
+
+// ArrivalReport is the wait of the open-loop arrivals for a free connection,
+// Response being the latency seen from their scheduled time, wait included
+type ArrivalReport struct {
+	Model     string
+	MaxQueue  int
+	Dropped   int64
+	QueueWait *ConnLatencyReport
+	Response  *ConnLatencyReport
+}
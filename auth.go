@@ -0,0 +1,236 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jcmturner/gokrb5/v8/client"
+	"github.com/jcmturner/gokrb5/v8/config"
+	"github.com/jcmturner/gokrb5/v8/credentials"
+	"github.com/jcmturner/gokrb5/v8/keytab"
+	"github.com/jcmturner/gokrb5/v8/spnego"
+	"github.com/valyala/fasthttp"
+)
+
+// authScheme is a connection-oriented HTTP authentication such as NTLM or Negotiate
+type authScheme interface {
+	// authorize runs the preliminary legs of the handshake on the connection of
+	// client, and sets the Authorization header of req for the final leg.
+	authorize(client *fasthttp.HostClient, req *fasthttp.Request, timeout time.Duration) error
+}
+
+// requestAuth is an authScheme authorizing each request of an authenticated
+// connection rather than the connection once, such as Digest
+type requestAuth interface {
+	// forConn returns the scheme of a new connection, with its own state
+	forConn() authScheme
+	// sign sets the Authorization header of req once the handshake is done
+	sign(req *fasthttp.Request)
+}
+
+// connAuth tracks whether the connection of one worker is authenticated
+type connAuth struct {
+	scheme authScheme
+	authed bool
+}
+
+func newConnAuth(scheme authScheme) *connAuth {
+	if ra, ok := scheme.(requestAuth); ok {
+		scheme = ra.forConn()
+	}
+	return &connAuth{scheme: scheme}
+}
+
+// AuthReport is the handshake overhead, kept out of the request latency
+type AuthReport struct {
+	Handshakes int64
+	Mean       time.Duration
+	Max        time.Duration
+	Total      time.Duration
+}
+
+func doTimeout(client *fasthttp.HostClient, req *fasthttp.Request, resp *fasthttp.Response, timeout time.Duration) error {
+	if timeout > 0 {
+		return client.DoTimeout(req, resp, timeout)
+	}
+	return client.Do(req, resp)
+}
+
+// authChallenge returns the token of the scheme in WWW-Authenticate
+func authChallenge(resp *fasthttp.Response, scheme string) (string, bool) {
+	var token string
+	var found bool
+	resp.Header.VisitAll(func(k, v []byte) {
+		if found || !strings.EqualFold(string(k), "WWW-Authenticate") {
+			return
+		}
+		for _, c := range strings.Split(string(v), ",") {
+			c = strings.TrimSpace(c)
+			if strings.EqualFold(c, scheme) {
+				found = true
+			} else if len(c) > len(scheme) && strings.EqualFold(c[:len(scheme)+1], scheme+" ") {
+				token, found = strings.TrimSpace(c[len(scheme)+1:]), true
+			}
+		}
+	})
+	return token, found
+}
+
+type ntlmAuth struct {
+	cred *ntlmCredentials
+}
+
+func (a *ntlmAuth) authorize(client *fasthttp.HostClient, req *fasthttp.Request, timeout time.Duration) error {
+	leg := fasthttp.AcquireRequest()
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseRequest(leg)
+	defer fasthttp.ReleaseResponse(resp)
+	req.Header.CopyTo(&leg.Header)
+	leg.Header.SetContentLength(0)
+	leg.Header.Set("Authorization", "NTLM "+base64.StdEncoding.EncodeToString(ntlmNegotiateMessage()))
+	if err := doTimeout(client, leg, resp, timeout); err != nil {
+		return err
+	}
+	token, ok := authChallenge(resp, "NTLM")
+	if resp.StatusCode() != fasthttp.StatusUnauthorized || !ok || token == "" {
+		return fmt.Errorf("ntlm: no challenge in %d response", resp.StatusCode())
+	}
+	msg, err := base64.StdEncoding.DecodeString(token)
+	if err != nil {
+		return fmt.Errorf("ntlm: %s", err)
+	}
+	challenge, err := parseNTLMChallenge(msg)
+	if err != nil {
+		return err
+	}
+	auth, err := ntlmAuthenticateMessage(a.cred, challenge)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "NTLM "+base64.StdEncoding.EncodeToString(auth))
+	return nil
+}
+
+// negotiateAuth sends SPNEGO tokens of Kerberos service tickets, the tickets
+// are cached by the kerberos client so only the first handshake hits the KDC.
+type negotiateAuth struct {
+	client *client.Client
+	spn    string
+}
+
+func newNegotiateAuth(keytabPath, principal, spn string) (*negotiateAuth, error) {
+	confPath := os.Getenv("KRB5_CONFIG")
+	if confPath == "" {
+		confPath = "/etc/krb5.conf"
+	}
+	conf, err := config.Load(confPath)
+	if err != nil {
+		return nil, fmt.Errorf("negotiate: %s", err)
+	}
+	var cl *client.Client
+	if keytabPath != "" {
+		i := strings.LastIndex(principal, "@")
+		if i <= 0 {
+			return nil, fmt.Errorf("negotiate: --principal must be USER@REALM with --keytab")
+		}
+		kt, err := keytab.Load(keytabPath)
+		if err != nil {
+			return nil, fmt.Errorf("negotiate: %s", err)
+		}
+		cl = client.NewWithKeytab(principal[:i], principal[i+1:], kt, conf, client.DisablePAFXFAST(true))
+	} else {
+		ccPath := strings.TrimPrefix(os.Getenv("KRB5CCNAME"), "FILE:")
+		if ccPath == "" {
+			ccPath = fmt.Sprintf("/tmp/krb5cc_%d", os.Getuid())
+		}
+		cc, err := credentials.LoadCCache(ccPath)
+		if err != nil {
+			return nil, fmt.Errorf("negotiate: %s (run kinit or use --keytab)", err)
+		}
+		if cl, err = client.NewFromCCache(cc, conf, client.DisablePAFXFAST(true)); err != nil {
+			return nil, fmt.Errorf("negotiate: %s", err)
+		}
+	}
+	if err := cl.AffirmLogin(); err != nil {
+		return nil, fmt.Errorf("negotiate: %s", err)
+	}
+	return &negotiateAuth{client: cl, spn: spn}, nil
+}
+
+func (a *negotiateAuth) authorize(_ *fasthttp.HostClient, req *fasthttp.Request, _ time.Duration) error {
+	spn := a.spn
+	if spn == "" {
+		host := string(req.Header.Host())
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		spn = "HTTP/" + host
+	}
+	token, err := spnego.SPNEGOClient(a.client, spn).InitSecContext()
+	if err != nil {
+		return fmt.Errorf("negotiate: %s", err)
+	}
+	b, err := token.Marshal()
+	if err != nil {
+		return fmt.Errorf("negotiate: %s", err)
+	}
+	req.Header.Set("Authorization", "Negotiate "+base64.StdEncoding.EncodeToString(b))
+	return nil
+}
+
+// newAuthScheme returns the scheme chosen by the flags, nil without any
+func newAuthScheme(ntlm, digestUser string, negotiate bool, keytabPath, principal, spn string) (authScheme, error) {
+	switch {
+	case digestUser != "":
+		return newDigestAuth(digestUser)
+	case ntlm != "":
+		cred, err := parseNTLMCredentials(ntlm)
+		if err != nil {
+			return nil, err
+		}
+		return &ntlmAuth{cred: cred}, nil
+	case negotiate:
+		return newNegotiateAuth(keytabPath, principal, spn)
+	}
+	return nil, nil
+}
+
+// doAuthRequest sends req on an authenticated connection, running the handshake
+// first when needed or when the server asks for it again (i.e. after a re-dial).
+func (r *Requester) doAuthRequest(a *connAuth, client *fasthttp.HostClient, pt *phaseTracker, req *fasthttp.Request, resp *fasthttp.Response, rr *ReportRecord) {
+	rr.authCost = 0
+	authorize := !a.authed
+	for {
+		if ra, ok := a.scheme.(requestAuth); ok && !authorize {
+			ra.sign(req)
+		}
+		if authorize {
+			t := time.Now()
+			err := a.scheme.authorize(client, req, r.clientOpt.doTimeout)
+			rr.authCost += time.Since(t)
+			if err != nil {
+				a.authed = false
+				rr.cost = 0
+				rr.reset()
+				rr.error = err.Error()
+				rr.errClass = classifyError(err)
+				return
+			}
+		}
+		r.DoRequest(client, pt, req, resp, rr)
+		req.Header.Del("Authorization")
+		if rr.code == fasthttp.StatusUnauthorized && !authorize {
+			// the connection lost its authentication, count this leg as handshake
+			rr.authCost += rr.cost
+			authorize = true
+			resp.Reset()
+			continue
+		}
+		a.authed = rr.error == "" && rr.code != fasthttp.StatusUnauthorized
+		return
+	}
+}
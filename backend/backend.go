@@ -0,0 +1,47 @@
+// Package backend is the interface of the protocols plow loads with
+// --backend, from executables whose main serves a Backend with
+//
+//	func main() { backend.Serve(&myBackend{}) }
+//
+// plow running the executable and sending it its requests over its standard
+// input and output, its standard error being plow's one
+package backend
+
+import (
+	"context"
+	"time"
+)
+
+// Config is the run a backend is set up for
+type Config struct {
+	URLs        []string          // the urls of the command line
+	Concurrency int               // of -c, the workers calling Do at once
+	Timeout     time.Duration     // of --timeout, 0 without
+	Options     map[string]string // the KEY=VALUE of --backend-option
+}
+
+// Record is the result of a request, counted in the report like the ones
+// of the built-in protocols
+type Record struct {
+	// Latency of the request, the time of Do when 0
+	Latency time.Duration
+	// Code is the status of the request, e.g. the response code of the
+	// protocol, counted by the report, "" for none
+	Code string
+	// Error fails the request
+	Error error
+	// ReqSize and RespSize are the bytes sent and received
+	ReqSize  int64
+	RespSize int64
+}
+
+// Backend sends the requests of a protocol plow doesn't know
+type Backend interface {
+	// Setup is called once before the run, an error aborting it
+	Setup(ctx context.Context, cfg Config) error
+	// Do sends a request to url, one of the urls of the command line, and
+	// is called by the workers concurrently
+	Do(ctx context.Context, worker int, url string) Record
+	// Teardown is called once after the run
+	Teardown() error
+}
@@ -0,0 +1,180 @@
+package backend
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// process is the Backend of an executable calling Serve, run by Exec
+type process struct {
+	cmd *exec.Cmd
+
+	wmu   sync.Mutex
+	stdin io.WriteCloser
+	enc   *json.Encoder
+
+	mu      sync.Mutex
+	next    uint64
+	pending map[uint64]chan *result
+
+	exited chan struct{}
+	err    error // why the executable exited, set before exited is closed
+}
+
+// Exec runs the executable at path and returns its Backend, the calls
+// failing once it exits
+func Exec(path string, args ...string) (Backend, error) {
+	cmd := exec.Command(path, args...)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	p := &process{
+		cmd:     cmd,
+		stdin:   stdin,
+		enc:     json.NewEncoder(stdin),
+		pending: make(map[uint64]chan *result),
+		exited:  make(chan struct{}),
+	}
+	go p.read(stdout)
+	return p, nil
+}
+
+// read hands the results to their calls until the executable exits
+func (p *process) read(stdout io.Reader) {
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxLine)
+	var err error
+	for scanner.Scan() {
+		res := &result{}
+		if err = json.Unmarshal(scanner.Bytes(), res); err != nil {
+			err = fmt.Errorf("invalid result %q: %w", scanner.Bytes(), err)
+			break
+		}
+		p.mu.Lock()
+		ch := p.pending[res.ID]
+		delete(p.pending, res.ID)
+		p.mu.Unlock()
+		if ch != nil {
+			ch <- res
+		}
+	}
+	if err == nil {
+		err = scanner.Err()
+	}
+	if err != nil {
+		p.cmd.Process.Kill()
+	}
+	if werr := p.cmd.Wait(); err == nil {
+		err = werr
+	}
+	if err == nil {
+		err = errors.New("the backend exited")
+	} else {
+		err = fmt.Errorf("the backend exited: %w", err)
+	}
+	p.err = err
+	close(p.exited)
+}
+
+// call sends c and waits for its result
+func (p *process) call(ctx context.Context, c *call) (*result, error) {
+	ch := make(chan *result, 1)
+	p.mu.Lock()
+	p.next++
+	c.ID = p.next
+	p.pending[c.ID] = ch
+	p.mu.Unlock()
+	forget := func() {
+		p.mu.Lock()
+		delete(p.pending, c.ID)
+		p.mu.Unlock()
+	}
+
+	p.wmu.Lock()
+	err := p.enc.Encode(c)
+	p.wmu.Unlock()
+	if err != nil {
+		forget()
+		select {
+		case <-p.exited:
+			return nil, p.err
+		default:
+			return nil, err
+		}
+	}
+	select {
+	case res := <-ch:
+		return res, nil
+	case <-ctx.Done():
+		forget()
+		return nil, ctx.Err()
+	case <-p.exited:
+		forget()
+		return nil, p.err
+	}
+}
+
+func (p *process) Setup(ctx context.Context, cfg Config) error {
+	res, err := p.call(ctx, &call{Op: opSetup, Config: &cfg})
+	if err != nil {
+		return err
+	}
+	if res.Error != "" {
+		return errors.New(res.Error)
+	}
+	return nil
+}
+
+func (p *process) Do(ctx context.Context, worker int, url string) Record {
+	res, err := p.call(ctx, &call{Op: opDo, Worker: worker, URL: url})
+	if err != nil {
+		return Record{Error: err}
+	}
+	rec := Record{Latency: res.Latency, Code: res.Code, ReqSize: res.ReqSize, RespSize: res.RespSize}
+	if res.Error != "" {
+		rec.Error = errors.New(res.Error)
+	}
+	return rec
+}
+
+// teardownWait is the time the executable has to exit after its teardown
+const teardownWait = 10 * time.Second
+
+// Teardown tears the backend down and waits for the executable to exit,
+// killing it after teardownWait
+func (p *process) Teardown() error {
+	ctx, cancel := context.WithTimeout(context.Background(), teardownWait)
+	defer cancel()
+	res, err := p.call(ctx, &call{Op: opTeardown})
+	p.stdin.Close()
+	select {
+	case <-p.exited:
+	case <-ctx.Done():
+		p.cmd.Process.Kill()
+		<-p.exited
+	}
+	if err != nil {
+		return err
+	}
+	if res.Error != "" {
+		return errors.New(res.Error)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package backend
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// the calls of plow and their results, a JSON object per line
+const (
+	opSetup    = "setup"
+	opDo       = "do"
+	opTeardown = "teardown"
+)
+
+type call struct {
+	ID     uint64  `json:"id"`
+	Op     string  `json:"op"`
+	Config *Config `json:"config,omitempty"`
+	Worker int     `json:"worker,omitempty"`
+	URL    string  `json:"url,omitempty"`
+}
+
+type result struct {
+	ID       uint64        `json:"id"`
+	Error    string        `json:"error,omitempty"`
+	Latency  time.Duration `json:"latency,omitempty"`
+	Code     string        `json:"code,omitempty"`
+	ReqSize  int64         `json:"reqSize,omitempty"`
+	RespSize int64         `json:"respSize,omitempty"`
+}
+
+// maxLine bounds the calls and results, a Config with many urls included
+const maxLine = 16 << 20
+
+// Serve serves b to the plow running the executable until its teardown,
+// calling Do concurrently for the workers, each within the --timeout of
+// the run. Serve is the main of a backend: nothing else must write to the
+// standard output, the logs going to the standard error
+func Serve(b Backend) error {
+	return serve(b, os.Stdin, os.Stdout)
+}
+
+func serve(b Backend, in io.Reader, out io.Writer) error {
+	var mu sync.Mutex
+	w := bufio.NewWriter(out)
+	enc := json.NewEncoder(w)
+	reply := func(res *result) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if err := enc.Encode(res); err != nil {
+			return err
+		}
+		return w.Flush()
+	}
+
+	var (
+		wg      sync.WaitGroup
+		timeout time.Duration
+	)
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxLine)
+	for scanner.Scan() {
+		var c call
+		if err := json.Unmarshal(scanner.Bytes(), &c); err != nil {
+			return err
+		}
+		switch c.Op {
+		case opSetup:
+			if c.Config == nil {
+				return errors.New("backend: setup without a config")
+			}
+			timeout = c.Config.Timeout
+			res := &result{ID: c.ID}
+			if err := b.Setup(context.Background(), *c.Config); err != nil {
+				res.Error = err.Error()
+			}
+			if err := reply(res); err != nil {
+				return err
+			}
+		case opDo:
+			wg.Add(1)
+			go func(c call) {
+				defer wg.Done()
+				ctx := context.Background()
+				if timeout > 0 {
+					var cancel context.CancelFunc
+					ctx, cancel = context.WithTimeout(ctx, timeout)
+					defer cancel()
+				}
+				rec := b.Do(ctx, c.Worker, c.URL)
+				res := &result{ID: c.ID, Latency: rec.Latency, Code: rec.Code, ReqSize: rec.ReqSize, RespSize: rec.RespSize}
+				if rec.Error != nil {
+					res.Error = rec.Error.Error()
+				}
+				reply(res)
+			}(c)
+		case opTeardown:
+			wg.Wait()
+			res := &result{ID: c.ID}
+			if err := b.Teardown(); err != nil {
+				res.Error = err.Error()
+			}
+			return reply(res)
+		default:
+			return errors.New("backend: unknown call " + c.Op)
+		}
+	}
+	wg.Wait()
+	return scanner.Err()
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// backpressure counts the records whose sender waited for the report to
+// collect them, the pipeline being full. Meanwhile the workers don't send
+// requests, so that the load is lower than asked and the stats of the run
+// partial by as much.
+type backpressure struct {
+	stalls  int64
+	stallNs int64
+}
+
+// send sends rr to ch, counting the wait when ch is full
+func (b *backpressure) send(ch chan<- *ReportRecord, rr *ReportRecord) {
+	select {
+	case ch <- rr:
+		return
+	default:
+	}
+	start := time.Now()
+	ch <- rr
+	atomic.AddInt64(&b.stalls, 1)
+	atomic.AddInt64(&b.stallNs, int64(time.Since(start)))
+}
+
+// report is the backpressure so far, nil when no record waited
+func (b *backpressure) report() *BackpressureReport {
+	if b == nil {
+		return nil
+	}
+	stalls := atomic.LoadInt64(&b.stalls)
+	if stalls == 0 {
+		return nil
+	}
+	return &BackpressureReport{Stalled: stalls, StallTime: time.Duration(atomic.LoadInt64(&b.stallNs))}
+}
+
+// BackpressureReport is the records which waited for the report to collect
+// them, StallTime being their total wait
+type BackpressureReport struct {
+	Stalled   int64
+	StallTime time.Duration
+}
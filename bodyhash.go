@@ -0,0 +1,142 @@
+package main
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"unicode"
+)
+
+const (
+	// maxBodyVariants bounds the distinct bodies counted, e.g. for bodies
+	// with a timestamp each
+	maxBodyVariants = 1000
+	// shownBodyVariants is the number of the most frequent variants reported
+	shownBodyVariants = 10
+	// bodySampleLen is the start of a variant kept to tell it
+	bodySampleLen = 80
+)
+
+// BodyVariantsReport is the distinct response bodies of --hash-bodies, e.g.
+// an error page answered with 200 along with the expected body
+type BodyVariantsReport struct {
+	Hashed   int64
+	Distinct int // up to maxBodyVariants
+	Variants []*BodyVariant
+}
+
+// BodyVariant is a body answered Count times with the status Code
+type BodyVariant struct {
+	Hash   string // FNV-1a of the body
+	Code   int
+	Size   int64
+	Count  int64
+	Share  float64 // of the hashed bodies
+	Sample string  // start of the body
+}
+
+type bodyVariantKey struct {
+	hash uint64
+	code int
+}
+
+// bodyHasher hashes a share rate of the response bodies, the first body of
+// each variant being sent with its sample
+type bodyHasher struct {
+	rate  float64
+	seen  sync.Map // bodyVariantKey
+	count int64
+}
+
+// hash returns the hash of body, 0 when it was not picked, and its sample
+// when the variant is new
+func (h *bodyHasher) hash(code int, body []byte) (uint64, string) {
+	if h.rate < 1 && rand.Float64() >= h.rate {
+		return 0, ""
+	}
+	f := fnv.New64a()
+	f.Write(body)
+	sum := f.Sum64()
+	if sum == 0 {
+		sum = 1
+	}
+	if atomic.LoadInt64(&h.count) >= maxBodyVariants {
+		return sum, ""
+	}
+	if _, loaded := h.seen.LoadOrStore(bodyVariantKey{sum, code}, struct{}{}); loaded {
+		return sum, ""
+	}
+	atomic.AddInt64(&h.count, 1)
+	return sum, bodySample(body)
+}
+
+// bodySample is the start of body on one line, its control characters shown
+// as dots
+func bodySample(body []byte) string {
+	if len(body) > bodySampleLen*4 {
+		body = body[:bodySampleLen*4]
+	}
+	s := strings.ToValidUTF8(string(body), "")
+	s = strings.Join(strings.Fields(s), " ")
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return '.'
+		}
+		return r
+	}, s)
+	if r := []rune(s); len(r) > bodySampleLen {
+		s = string(r[:bodySampleLen]) + "…"
+	}
+	return s
+}
+
+// bodyVariants counts the variants of the hashed bodies
+type bodyVariants struct {
+	hashed   int64
+	variants map[bodyVariantKey]*BodyVariant
+}
+
+func (v *bodyVariants) add(r *ReportRecord) {
+	v.hashed++
+	key := bodyVariantKey{r.bodyHash, r.code}
+	bv := v.variants[key]
+	if bv == nil {
+		if len(v.variants) >= maxBodyVariants {
+			return
+		}
+		size := r.respSize
+		if r.decodedSize > 0 {
+			size = r.decodedSize
+		}
+		bv = &BodyVariant{Hash: strconv.FormatUint(r.bodyHash, 16), Code: r.code, Size: size}
+		v.variants[key] = bv
+	}
+	bv.Count++
+	if r.bodySample != "" {
+		bv.Sample = r.bodySample
+	}
+}
+
+func (v *bodyVariants) report() *BodyVariantsReport {
+	rs := &BodyVariantsReport{Hashed: v.hashed, Distinct: len(v.variants)}
+	for _, bv := range v.variants {
+		c := *bv
+		c.Share = float64(c.Count) / float64(v.hashed)
+		rs.Variants = append(rs.Variants, &c)
+	}
+	sort.Slice(rs.Variants, func(i, j int) bool {
+		a, b := rs.Variants[i], rs.Variants[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Hash < b.Hash
+	})
+	if len(rs.Variants) > shownBodyVariants {
+		rs.Variants = rs.Variants[:shownBodyVariants]
+	}
+	return rs
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+)
+
+// BodySizeReport is the size of the request and response bodies, in bytes,
+// Decoded being the one of the responses once decoded with --compressed and
+// Ratio their decoded bytes per byte on the wire. Histogram spreads the
+// responses by size, telling the truncated ones apart.
+type BodySizeReport struct {
+	Request   BodySizeStats
+	Response  BodySizeStats
+	Decoded   *BodySizeStats `json:",omitempty"`
+	Ratio     float64        `json:",omitempty"`
+	Histogram []BodySizeBin  `json:",omitempty"`
+}
+
+type BodySizeStats struct {
+	Min   int64
+	Mean  float64
+	Max   int64
+	Total int64
+}
+
+func newBodySizeStats(s *Stats) BodySizeStats {
+	return BodySizeStats{Min: int64(s.min), Mean: s.Mean(), Max: int64(s.max), Total: int64(s.sum)}
+}
+
+// BodySizeBin counts the responses of Min to Max bytes, the smallest and
+// largest ones of its bucket
+type BodySizeBin struct {
+	Min   int64
+	Max   int64
+	Count int64
+}
+
+const (
+	// sizeBinsPerOctave is the number of buckets of the sizes between a
+	// power of two and the next one
+	sizeBinsPerOctave = 4
+	// maxSizeBins bounds the bins of the histogram, the neighbour buckets of
+	// a wide spread being merged
+	maxSizeBins = 12
+)
+
+// sizeBins are the response sizes by logarithmic bucket
+type sizeBins map[int]*BodySizeBin
+
+func (b sizeBins) add(size int64) {
+	i := 0
+	if size > 0 {
+		i = 1 + int(math.Log2(float64(size))*sizeBinsPerOctave)
+	}
+	bin := b[i]
+	if bin == nil {
+		b[i] = &BodySizeBin{Min: size, Max: size, Count: 1}
+		return
+	}
+	bin.Min, bin.Max = min(bin.Min, size), max(bin.Max, size)
+	bin.Count++
+}
+
+// histogram is the bins from the smallest sizes to the largest
+func (b sizeBins) histogram() []BodySizeBin {
+	keys := make([]int, 0, len(b))
+	for i := range b {
+		keys = append(keys, i)
+	}
+	sort.Ints(keys)
+	per := (len(keys) + maxSizeBins - 1) / maxSizeBins
+	bins := make([]BodySizeBin, 0, min(len(keys), maxSizeBins))
+	for j, i := range keys {
+		if j%per == 0 {
+			bins = append(bins, *b[i])
+			continue
+		}
+		last := &bins[len(bins)-1]
+		last.Max = b[i].Max
+		last.Count += b[i].Count
+	}
+	return bins
+}
+
+// requestBodySize is the size of the body of req once sent, 0 for a stream of
+// unknown length
+func requestBodySize(req *fasthttp.Request) int64 {
+	if req.IsBodyStream() {
+		return int64(max(req.Header.ContentLength(), 0))
+	}
+	return int64(len(req.Body()))
+}
+
+// acceptEncoding is the Accept-Encoding of --compressed, the encodings
+// fasthttp decodes
+const acceptEncoding = "gzip, deflate, br, zstd"
+
+// hasHeader tells whether the K:V headers set name
+func hasHeader(headers []string, name string) bool {
+	for _, h := range headers {
+		if k, _, ok := strings.Cut(h, ":"); ok && strings.EqualFold(strings.TrimSpace(k), name) {
+			return true
+		}
+	}
+	return false
+}
+
+// decodedBody is the body of resp decoded from its Content-Encoding
+func decodedBody(resp *fasthttp.Response) ([]byte, error) {
+	ce := resp.Header.ContentEncoding()
+	if len(ce) == 0 || string(ce) == "identity" {
+		return resp.Body(), nil
+	}
+	body, err := resp.BodyUncompressed()
+	if err != nil {
+		return nil, fmt.Errorf("decoding the %s body: %s", ce, err)
+	}
+	return body, nil
+}
+
+// formatBytes prints n bytes with a binary unit, e.g. 1.5KB
+func formatBytes(n float64) string {
+	units := []string{"B", "KB", "MB", "GB", "TB"}
+	i := 0
+	for ; n >= 1024 && i < len(units)-1; i++ {
+		n /= 1024
+	}
+	if i == 0 {
+		return strconv.FormatFloat(n, 'f', 0, 64) + units[i]
+	}
+	return strconv.FormatFloat(n, 'f', 2, 64) + units[i]
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxChunkSize is the largest chunk fasthttp writes, the size of its copy
+// buffer
+const maxChunkSize = 4 << 10
+
+// bodyStream sends the bodies of --stream and --body-size with chunked
+// encoding, in chunks of chunkSize bytes at rate bytes per second
+type bodyStream struct {
+	size      int64  // of the generated bodies, 0 to stream the --body file
+	pattern   []byte // repeated by the generated bodies
+	chunkSize int
+	rate      int64 // of each body, 0 for as fast as possible
+}
+
+// newBodyStream returns the stream of the bodies, generated by repeating
+// pattern, or random bytes without, when size is set
+func newBodyStream(size int64, pattern []byte, chunkSize int, rate int64) *bodyStream {
+	if size > 0 && len(pattern) == 0 {
+		pattern = make([]byte, maxChunkSize)
+		rand.Read(pattern)
+	}
+	return &bodyStream{size: size, pattern: pattern, chunkSize: chunkSize, rate: rate}
+}
+
+// body returns the body of a request, reading the --body file src or
+// generating it, until ended is closed
+func (s *bodyStream) body(ended <-chan struct{}, src io.Reader) *pacedBody {
+	if s.size > 0 {
+		src = &repeatReader{pattern: s.pattern, left: s.size}
+	}
+	return &pacedBody{ended: ended, src: src, stream: s}
+}
+
+var errBodyStreamEnded = errors.New("body stream: the run ended")
+
+// pacedBody is a body sent in chunks at the rate of its stream, counting the
+// bytes sent
+type pacedBody struct {
+	ended  <-chan struct{}
+	src    io.Reader
+	stream *bodyStream
+	start  time.Time
+	sent   int64
+}
+
+func (b *pacedBody) Read(p []byte) (int, error) {
+	if len(p) > b.stream.chunkSize {
+		p = p[:b.stream.chunkSize]
+	}
+	if b.start.IsZero() {
+		b.start = time.Now()
+	} else if b.stream.rate > 0 {
+		due := b.start.Add(time.Duration(float64(b.sent) / float64(b.stream.rate) * float64(time.Second)))
+		if wait := time.Until(due); wait > 0 {
+			t := time.NewTimer(wait)
+			select {
+			case <-b.ended:
+				t.Stop()
+				return 0, errBodyStreamEnded
+			case <-t.C:
+			}
+		}
+	}
+	n, err := b.src.Read(p)
+	b.sent += int64(n)
+	return n, err
+}
+
+// Close closes the --body file, fasthttp closing the body once sent
+func (b *pacedBody) Close() error {
+	if c, ok := b.src.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// repeatReader reads pattern over and over until left bytes were read
+type repeatReader struct {
+	pattern []byte
+	off     int
+	left    int64
+}
+
+func (r *repeatReader) Read(p []byte) (int, error) {
+	if r.left <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > r.left {
+		p = p[:r.left]
+	}
+	n := 0
+	for n < len(p) {
+		c := copy(p[n:], r.pattern[r.off:])
+		n += c
+		r.off = (r.off + c) % len(r.pattern)
+	}
+	r.left -= int64(n)
+	return n, nil
+}
+
+// parseBytes parses a size with an optional binary unit, e.g. 512, 64KB or
+// 1.5GB
+func parseBytes(s string) (int64, error) {
+	num := strings.ToUpper(strings.TrimSpace(s))
+	num = strings.TrimSuffix(num, "B")
+	unit := int64(1)
+	for i, u := range []string{"K", "M", "G", "T"} {
+		if rest, ok := strings.CutSuffix(num, u); ok {
+			num, unit = rest, int64(1)<<(10*(i+1))
+			break
+		}
+	}
+	f, err := strconv.ParseFloat(strings.TrimSpace(num), 64)
+	if err != nil || f < 0 {
+		return 0, fmt.Errorf("%q is not a size, e.g. 64KB", s)
+	}
+	return int64(f * float64(unit)), nil
+}
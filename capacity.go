@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"os"
+	"time"
+)
+
+// capacityPoint is the outcome of a stage at the rate it offered, a point of
+// the throughput-latency curve of --capacity-report, latencies in ms
+type capacityPoint struct {
+	Stage      string  `json:"stage"`
+	Offered    float64 `json:"offered"` // requests per second
+	Throughput float64 `json:"throughput"`
+	P50        float64 `json:"p50"`
+	P90        float64 `json:"p90"`
+	P99        float64 `json:"p99"`
+	ErrorRate  float64 `json:"errorRate"` // %
+	Passed     bool    `json:"passed"`
+}
+
+// capacityPoints are the points of the stages with a rate which ran, in the
+// order of the stages
+func capacityPoints(stages []*Stage, s *SnapshotReport, results []*StageResult) []*capacityPoint {
+	ms := func(d time.Duration) float64 { return roundFloat(float64(d)/float64(time.Millisecond), 3) }
+	var points []*capacityPoint
+	for i, st := range stages {
+		ss := stageSnapshot(s, i)
+		if st.Rate == nil || ss.Count == 0 {
+			continue
+		}
+		p := &capacityPoint{
+			Stage:      fmt.Sprintf("#%d %s", i+1, st),
+			Offered:    roundFloat(float64(*st.Rate), 3),
+			Throughput: roundFloat(ss.RPS, 3),
+			ErrorRate:  roundFloat(float64(errorCount(ss))*100/float64(ss.Count), 3),
+			Passed:     i >= len(results) || results[i].Passed,
+		}
+		for _, pc := range ss.Percentiles {
+			switch pc.Percentile {
+			case 0.5:
+				p.P50 = ms(pc.Latency)
+			case 0.9:
+				p.P90 = ms(pc.Latency)
+			case 0.99:
+				p.P99 = ms(pc.Latency)
+			}
+		}
+		points = append(points, p)
+	}
+	return points
+}
+
+// capacityPageHTML charts the latency percentiles and the error rate of the
+// points against their offered rate, the stages failing their thresholds in
+// red, with echarts inlined for the page to open offline
+const capacityPageHTML = `<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>plow capacity</title>
+<style>
+body { font-family: sans-serif; margin: 24px; color: #333; }
+table { border-collapse: collapse; margin-top: 16px; }
+th, td { padding: 4px 12px; border-bottom: 1px solid #ddd; text-align: right; }
+th:first-child, td:first-child { text-align: left; }
+.failed { color: #c23531; }
+</style>
+<script>%s</script>
+</head><body>
+<h3>%s</h3>
+<div id="chart" style="width: 100%%; height: 520px;"></div>
+<table>
+<tr><th>Stage</th><th>Offered req/s</th><th>Throughput req/s</th><th>P50 ms</th><th>P90 ms</th><th>P99 ms</th><th>Errors %%</th></tr>
+%s</table>
+<script>
+const points = %s;
+const failed = p => p.passed ? null : {color: '#c23531', borderColor: '#c23531'};
+const series = (name, key, axis) => ({
+  name: name, type: 'line', yAxisIndex: axis, symbolSize: 8,
+  data: points.map(p => ({value: [p.offered, p[key]], itemStyle: failed(p)})),
+});
+echarts.init(document.getElementById('chart')).setOption({
+  title: {text: 'Latency and errors vs offered rate'},
+  tooltip: {trigger: 'axis'},
+  legend: {top: 28},
+  grid: {top: 80},
+  xAxis: {type: 'value', name: 'offered req/s', scale: true},
+  yAxis: [{type: 'value', name: 'latency ms'}, {type: 'value', name: 'errors %%', min: 0, max: 100}],
+  series: [series('P50', 'p50', 0), series('P90', 'p90', 0), series('P99', 'p99', 0), series('Errors', 'errorRate', 1)],
+});
+</script>
+</body></html>
+`
+
+// capacityReport renders the page of the points, desc being the one of the
+// run
+func capacityReport(desc string, points []*capacityPoint) ([]byte, error) {
+	js, err := assetsFS.ReadFile("echarts.min.js")
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(points)
+	if err != nil {
+		return nil, err
+	}
+	var rows bytes.Buffer
+	for _, p := range points {
+		class := ""
+		if !p.Passed {
+			class = ` class="failed"`
+		}
+		fmt.Fprintf(&rows, "<tr%s><td>%s</td><td>%g</td><td>%g</td><td>%g</td><td>%g</td><td>%g</td><td>%g</td></tr>\n",
+			class, html.EscapeString(p.Stage), p.Offered, p.Throughput, p.P50, p.P90, p.P99, p.ErrorRate)
+	}
+	return []byte(fmt.Sprintf(capacityPageHTML, js, html.EscapeString(desc), rows.String(), data)), nil
+}
+
+func writeCapacityReport(path, desc string, stages []*Stage, s *SnapshotReport, results []*StageResult) error {
+	page, err := capacityReport(desc, capacityPoints(stages, s, results))
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, page, 0o644)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+)
+
+// headerCapture is a --capture of a response header into a variable of the
+// templates of the next requests of the worker, e.g. the next-page token of a
+// paginated crawl
+type headerCapture struct {
+	name   string
+	header string
+}
+
+// parseHeaderCapture parses `NAME=HEADER`, such as `cursor=X-Next-Cursor`
+func parseHeaderCapture(s string) (*headerCapture, error) {
+	name, header, ok := strings.Cut(s, "=")
+	name, header = strings.TrimSpace(name), strings.TrimSpace(header)
+	if !ok || name == "" || header == "" {
+		return nil, fmt.Errorf("capture %q is not a NAME=HEADER (i.e. cursor=X-Next-Cursor)", s)
+	}
+	if strings.ContainsAny(name, ". {}") {
+		return nil, fmt.Errorf("capture %q: %q is not a valid variable name", s, name)
+	}
+	return &headerCapture{name: name, header: header}, nil
+}
+
+func (c *headerCapture) String() string {
+	return c.name + "=" + c.header
+}
+
+func parseHeaderCaptures(specs []string) ([]*headerCapture, error) {
+	var res []*headerCapture
+	for _, s := range specs {
+		c, err := parseHeaderCapture(s)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, c)
+	}
+	return res, nil
+}
+
+// captureSample is the data of the first render of the templates, the
+// variables of the captures being empty until their first response
+func captureSample(row map[string]interface{}, captures []*headerCapture) interface{} {
+	if len(captures) == 0 {
+		if row == nil {
+			return nil
+		}
+		return row
+	}
+	sample := make(map[string]interface{}, len(row)+len(captures))
+	for k, v := range row {
+		sample[k] = v
+	}
+	for _, c := range captures {
+		if _, ok := sample[c.name]; !ok {
+			sample[c.name] = ""
+		}
+	}
+	return sample
+}
+
+// captureVars are the captured variables of a worker. They override the
+// column of the same name of the data feed, which is their value until the
+// first capture and again after a response without the header, so that a
+// crawl starts over at its last page.
+type captureVars struct {
+	captures []*headerCapture
+	values   map[string]string
+	data     map[string]interface{}
+}
+
+func newCaptureVars(captures []*headerCapture) *captureVars {
+	return &captureVars{
+		captures: captures,
+		values:   make(map[string]string, len(captures)),
+		data:     make(map[string]interface{}),
+	}
+}
+
+// with is the data of the templates of the next request, row being the one
+// of the data feed, if any
+func (v *captureVars) with(row interface{}) interface{} {
+	clear(v.data)
+	if m, ok := row.(map[string]interface{}); ok {
+		for k, val := range m {
+			v.data[k] = val
+		}
+	}
+	for _, c := range v.captures {
+		if val, ok := v.values[c.name]; ok {
+			v.data[c.name] = val
+		} else if _, ok := v.data[c.name]; !ok {
+			v.data[c.name] = ""
+		}
+	}
+	return v.data
+}
+
+// update captures the headers of resp, the response of a request that
+// didn't fail
+func (v *captureVars) update(resp *fasthttp.Response) {
+	for _, c := range v.captures {
+		if h := resp.Header.Peek(c.header); len(h) > 0 {
+			v.values[c.name] = string(h)
+		} else {
+			delete(v.values, c.name)
+		}
+	}
+}
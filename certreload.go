@@ -0,0 +1,157 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// certReloader presents the client certificate of --cert/--key, reloaded from
+// disk once the files change, and counts the handshakes of each certificate.
+type certReloader struct {
+	certPath, keyPath string
+
+	mu      sync.Mutex
+	current *loadedCert
+	certs   []*loadedCert
+	stamp   string // size and modification time of the files
+
+	sessions tls.ClientSessionCache
+}
+
+type loadedCert struct {
+	cert       tls.Certificate
+	subject    string
+	serial     string
+	notAfter   time.Time
+	loaded     time.Time
+	handshakes int64
+}
+
+// ClientCertReport is a client certificate of the run, Handshakes counting
+// the full TLS handshakes it was presented in.
+type ClientCertReport struct {
+	Subject    string
+	Serial     string
+	NotAfter   time.Time
+	Loaded     time.Time
+	Handshakes int64
+}
+
+func newCertReloader(certPath, keyPath string) (*certReloader, error) {
+	c := &certReloader{certPath: certPath, keyPath: keyPath, sessions: tls.NewLRUClientSessionCache(0)}
+	if _, err := c.reload(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *certReloader) fileStamp() string {
+	var stamp string
+	for _, p := range []string{c.certPath, c.keyPath} {
+		if fi, err := os.Stat(p); err == nil {
+			stamp += fmt.Sprintf("%d/%d;", fi.ModTime().UnixNano(), fi.Size())
+		}
+	}
+	return stamp
+}
+
+// reload loads the certificate again if its files changed, keeping the
+// current one when the new pair can't be loaded, e.g. while being written.
+func (c *certReloader) reload() (bool, error) {
+	stamp := c.fileStamp()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.current != nil && stamp == c.stamp {
+		return false, nil
+	}
+	cert, err := tls.LoadX509KeyPair(c.certPath, c.keyPath)
+	if err != nil {
+		return false, err
+	}
+	lc := &loadedCert{cert: cert, loaded: time.Now()}
+	if leaf, err := x509.ParseCertificate(cert.Certificate[0]); err == nil {
+		lc.subject = leaf.Subject.String()
+		lc.serial = leaf.SerialNumber.Text(16)
+		lc.notAfter = leaf.NotAfter
+	}
+	if c.current != nil && lc.serial != "" && lc.serial == c.current.serial {
+		// rewritten with the same certificate
+		c.stamp = stamp
+		return false, nil
+	}
+	c.current = lc
+	c.certs = append(c.certs, lc)
+	c.stamp = stamp
+	return true, nil
+}
+
+// watch checks the files every interval until done
+func (c *certReloader) watch(interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			_, _ = c.reload()
+		}
+	}
+}
+
+func (c *certReloader) getClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	c.mu.Lock()
+	lc := c.current
+	c.mu.Unlock()
+	atomic.AddInt64(&lc.handshakes, 1)
+	return &lc.cert, nil
+}
+
+// bind returns cfg presenting the current certificate on a new connection,
+// its TLS sessions kept apart from those of the other certificates so that
+// no session of a previous one is resumed once reloaded
+func (c *certReloader) bind(cfg *tls.Config) *tls.Config {
+	c.mu.Lock()
+	lc := c.current
+	c.mu.Unlock()
+	cfg = cfg.Clone()
+	cfg.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+		atomic.AddInt64(&lc.handshakes, 1)
+		return &lc.cert, nil
+	}
+	cfg.ClientSessionCache = &certSessionCache{cache: c.sessions, serial: lc.serial}
+	return cfg
+}
+
+type certSessionCache struct {
+	cache  tls.ClientSessionCache
+	serial string
+}
+
+func (s *certSessionCache) Get(sessionKey string) (*tls.ClientSessionState, bool) {
+	return s.cache.Get(s.serial + "/" + sessionKey)
+}
+
+func (s *certSessionCache) Put(sessionKey string, cs *tls.ClientSessionState) {
+	s.cache.Put(s.serial+"/"+sessionKey, cs)
+}
+
+// reports returns the certificates presented at least once, or after a reload
+func (c *certReloader) reports() []*ClientCertReport {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var res []*ClientCertReport
+	for _, lc := range c.certs {
+		n := atomic.LoadInt64(&lc.handshakes)
+		if n == 0 && len(c.certs) == 1 {
+			continue
+		}
+		res = append(res, &ClientCertReport{Subject: lc.subject, Serial: lc.serial, NotAfter: lc.notAfter, Loaded: lc.loaded, Handshakes: n})
+	}
+	return res
+}
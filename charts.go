@@ -34,6 +34,13 @@ var (
 	rpsView         = "rps"
 	codeView        = "code"
 	concurrencyView = "concurrency"
+	throughputView  = "throughput"
+	errorsView      = "errors"
+	phasesView      = "phases"
+	extractView     = "extract"
+	poolView        = "pool"
+	clientView      = "client"
+	serverView      = "server"
 	timeFormat      = "15:04:05"
 	refreshInterval = time.Second
 
@@ -42,6 +49,13 @@ var (
 		latencyView:     ViewTpl,
 		codeView:        CodeViewTpl,
 		concurrencyView: ViewTpl,
+		throughputView:  ViewTpl,
+		errorsView:      ViewTpl,
+		phasesView:      ViewTpl,
+		extractView:     ViewTpl,
+		poolView:        ViewTpl,
+		clientView:      ViewTpl,
+		serverView:      ViewTpl,
 	}
 )
 
@@ -56,7 +70,7 @@ function {{ .ViewID }}_sync() {
         success: function (result) {
             let opt = goecharts_{{ .ViewID }}.getOption();
             let x = opt.xAxis[0].data;
-            x.push(result.time);
+            x.push(result.warmup ? result.time + ' warmup' : result.time);
             opt.xAxis[0].data = x;
             for (let i = 0; i < result.values.length; i++) {
                 let y = opt.series[i].data;
@@ -90,7 +104,7 @@ function {{ .ViewID }}_sync() {
         success: function (result) {
             let opt = goecharts_{{ .ViewID }}.getOption();
             let x = opt.xAxis[0].data;
-            x.push(result.time);
+            x.push(result.warmup ? result.time + ' warmup' : result.time);
             opt.xAxis[0].data = x;
 							
 			let nameAndSeriesMapping = {};
@@ -126,6 +140,10 @@ function {{ .ViewID }}_sync() {
 							type: 'line',
 							data:  data
 						};
+						if (code[0] === '3') {
+							// 3xx count as success or failure with --redirect-status
+							newSeries.itemStyle = {color: '{{ .RedirectColor }}'};
+						}
 						opt.series.push(newSeries);
 					}
 				}
@@ -148,11 +166,17 @@ func (c *Charts) genViewTemplate(vid, route string) string {
 		APIPath  string
 		Route    string
 		ViewID   string
+		// RedirectColor is the one of the 3xx series of the status chart
+		RedirectColor string
 	}{
-		Interval: int(refreshInterval.Milliseconds()),
-		APIPath:  apiPath,
-		Route:    route,
-		ViewID:   vid,
+		Interval:      int(refreshInterval.Milliseconds()),
+		APIPath:       apiPath,
+		Route:         route,
+		ViewID:        vid,
+		RedirectColor: "#91cc75",
+	}
+	if c.redirectFailure {
+		d.RedirectColor = "#ee6666"
 	}
 
 	buf := bytes.Buffer{}
@@ -226,26 +250,145 @@ func (c *Charts) newConcurrencyView() components.Charter {
 	return graph
 }
 
+func (c *Charts) newThroughputView() components.Charter {
+	graph := c.newBasicView(throughputView)
+	graph.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{Title: "Bandwidth"}),
+		charts.WithYAxisOpts(opts.YAxis{Scale: opts.Bool(true), AxisLabel: &opts.AxisLabel{Formatter: "{value} MB/s"}}),
+		charts.WithLegendOpts(opts.Legend{Show: opts.Bool(true)}),
+	)
+	graph.AddSeries("Read", []opts.LineData{}).
+		AddSeries("Write", []opts.LineData{})
+	return graph
+}
+
+func (c *Charts) newErrorsView() components.Charter {
+	graph := c.newBasicView(errorsView)
+	graph.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{Title: "Errors/sec"}),
+		charts.WithYAxisOpts(opts.YAxis{Scale: opts.Bool(true)}),
+		charts.WithLegendOpts(opts.Legend{Show: opts.Bool(true)}),
+	)
+	for _, name := range errorClassNames {
+		graph.AddSeries(name, []opts.LineData{})
+	}
+	graph.SetSeriesOptions(charts.WithLineChartOpts(opts.LineChart{Stack: "errors"}))
+	return graph
+}
+
+func (c *Charts) newPhasesView() components.Charter {
+	graph := c.newBasicView(phasesView)
+	graph.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{Title: "Latency Phases"}),
+		charts.WithYAxisOpts(opts.YAxis{Scale: opts.Bool(true), AxisLabel: &opts.AxisLabel{Formatter: "{value} ms"}}),
+		charts.WithLegendOpts(opts.Legend{Show: opts.Bool(true)}),
+	)
+	for _, name := range phaseNames {
+		graph.AddSeries(name, []opts.LineData{})
+	}
+	graph.SetSeriesOptions(
+		charts.WithLineChartOpts(opts.LineChart{Stack: "phases"}),
+		charts.WithAreaStyleOpts(opts.AreaStyle{Opacity: 0.4}),
+	)
+	return graph
+}
+
+func (c *Charts) newPoolView() components.Charter {
+	graph := c.newBasicView(poolView)
+	graph.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{Title: "Connection Pool"}),
+		charts.WithYAxisOpts(opts.YAxis{Scale: opts.Bool(true)}),
+		charts.WithLegendOpts(opts.Legend{Show: opts.Bool(true)}),
+	)
+	graph.AddSeries("Open", []opts.LineData{}).
+		AddSeries("Opened/s", []opts.LineData{}).
+		AddSeries("Closed/s", []opts.LineData{}).
+		AddSeries("Wait ms", []opts.LineData{})
+	return graph
+}
+
+func (c *Charts) newClientView() components.Charter {
+	graph := c.newBasicView(clientView)
+	graph.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{Title: "Client Resources"}),
+		charts.WithYAxisOpts(opts.YAxis{Scale: opts.Bool(true)}),
+		charts.WithLegendOpts(opts.Legend{Show: opts.Bool(true), Selected: map[string]bool{"Goroutines": false, "Open Files": false}}),
+	)
+	graph.AddSeries("CPU %", []opts.LineData{}).
+		AddSeries("Memory MB", []opts.LineData{}).
+		AddSeries("Goroutines", []opts.LineData{}).
+		AddSeries("Open Files", []opts.LineData{})
+	return graph
+}
+
+func (c *Charts) newServerView() components.Charter {
+	graph := c.newBasicView(serverView)
+	graph.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{Title: "Server Resources"}),
+		charts.WithYAxisOpts(opts.YAxis{Scale: opts.Bool(true)}),
+		charts.WithLegendOpts(opts.Legend{Show: opts.Bool(true), Selected: map[string]bool{"Load": false}}),
+	)
+	graph.AddSeries("CPU %", []opts.LineData{}).
+		AddSeries("Memory %", []opts.LineData{}).
+		AddSeries("Load", []opts.LineData{}).
+		AddSeries("Net Rx MB/s", []opts.LineData{}).
+		AddSeries("Net Tx MB/s", []opts.LineData{})
+	return graph
+}
+
+// pool returns the connections open, opened and closed per second, and the
+// mean wait for a free connection in ms, or nils without data
+func (cr *ChartsReport) pool() []interface{} {
+	if cr == nil {
+		return []interface{}{nil, nil, nil, nil}
+	}
+	return []interface{}{cr.OpenConns, cr.ConnsOpened, cr.ConnsClosed, cr.ConnWait}
+}
+
+func (c *Charts) newExtractView() components.Charter {
+	graph := c.newBasicView(extractView)
+	graph.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{Title: "Extracted Fields"}),
+		charts.WithYAxisOpts(opts.YAxis{Scale: opts.Bool(true)}),
+		charts.WithLegendOpts(opts.Legend{Show: opts.Bool(true)}),
+	)
+	for _, e := range c.extracts {
+		graph.AddSeries(e.name, []opts.LineData{})
+	}
+	return graph
+}
+
 type Metrics struct {
-	Values []interface{} `json:"values"`
-	Time   string        `json:"time"`
+	Values  []interface{} `json:"values"`
+	Time    string        `json:"time"`
+	Warning string        `json:"warning,omitempty"`
+	Warmup  bool          `json:"warmup,omitempty"` // of the warmup, excluded from the summary
 }
 
 type Charts struct {
 	page     *components.Page
 	ln       net.Listener
 	dataFunc func() *ChartsReport
+	extracts []*extractor
+	// redirectFailure draws the 3xx as failures
+	redirectFailure bool
 }
 
-func NewCharts(ln net.Listener, dataFunc func() *ChartsReport, desc string) (*Charts, error) {
+func NewCharts(ln net.Listener, dataFunc func() *ChartsReport, desc string, extracts []*extractor, redirectFailure, probe bool) (*Charts, error) {
 	templates.PageTpl = fmt.Sprintf(PageTpl, desc)
 
-	c := &Charts{ln: ln, dataFunc: dataFunc}
+	c := &Charts{ln: ln, dataFunc: dataFunc, extracts: extracts, redirectFailure: redirectFailure}
 	c.page = components.NewPage()
 	c.page.PageTitle = "plow"
 	c.page.AssetsHost = assetsPath
 	c.page.Assets.JSAssets.Add("jquery.min.js")
-	c.page.AddCharts(c.newLatencyView(), c.newRPSView(), c.newCodeView(), c.newConcurrencyView())
+	c.page.AddCharts(c.newLatencyView(), c.newRPSView(), c.newCodeView(), c.newConcurrencyView(), c.newThroughputView(), c.newErrorsView(), c.newPhasesView(), c.newPoolView(), c.newClientView())
+	if len(extracts) > 0 {
+		c.page.AddCharts(c.newExtractView())
+	}
+	if probe {
+		c.page.AddCharts(c.newServerView())
+	}
 
 	return c, nil
 }
@@ -283,10 +426,37 @@ func (c *Charts) Handler(ctx *fasthttp.RequestCtx) {
 			} else {
 				values = append(values, nil)
 			}
+		case throughputView:
+			if reportData != nil {
+				values = append(values, reportData.ReadThroughput, reportData.WriteThroughput)
+			} else {
+				values = append(values, nil, nil)
+			}
+		case errorsView:
+			for i := range errorClassNames {
+				if reportData != nil {
+					values = append(values, reportData.Errors[i])
+				} else {
+					values = append(values, nil)
+				}
+			}
+		case phasesView:
+			for _, p := range reportData.phases() {
+				values = append(values, p)
+			}
+		case extractView:
+			values = reportData.extracted(len(c.extracts))
+		case poolView:
+			values = reportData.pool()
+		case clientView:
+			values, _ = reportData.client()
+		case serverView:
+			values = reportData.server()
 		}
 		metrics := &Metrics{
 			Time:   time.Now().Format(timeFormat),
 			Values: values,
+			Warmup: reportData != nil && reportData.Warmup,
 		}
 		_ = json.NewEncoder(ctx).Encode(metrics)
 	} else if path == "/" {
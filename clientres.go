@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"time"
+)
+
+// the use of the resources of plow itself above which the load generator is
+// saturated, its results measuring it rather than the server
+const (
+	clientSaturatedCPU = 90 // % of all the cores
+	clientSaturatedFDs = 90 // % of the open files limit
+)
+
+// clientSample is the resources used by plow itself in the last second
+type clientSample struct {
+	CPU        float64 // % of all the cores
+	Mem        float64 // MB obtained from the system
+	Goroutines int
+	FDs        int // -1 where unknown
+	FDLimit    int // 0 where unknown
+}
+
+// saturation tells why the generator is saturated in the second of c, ""
+// when it isn't
+func (c clientSample) saturation() string {
+	if c.CPU >= clientSaturatedCPU {
+		return fmt.Sprintf("CPU at %.0f%% of %d cores", c.CPU, runtime.NumCPU())
+	}
+	if c.FDLimit > 0 && c.FDs*100 >= c.FDLimit*clientSaturatedFDs {
+		return fmt.Sprintf("%d open files of the limit of %d", c.FDs, c.FDLimit)
+	}
+	return ""
+}
+
+// clientMonitor samples the resources of the process each second of the
+// run, the seconds it was saturated being reported
+type clientMonitor struct {
+	lastCPU  time.Duration
+	lastTime time.Time
+
+	// guarded by the lock of the report
+	last      clientSample
+	saturated int // seconds
+	peakCPU   float64
+	reason    string // of the last saturated second
+}
+
+func newClientMonitor() *clientMonitor {
+	cpu, _ := processCPUTime()
+	return &clientMonitor{lastCPU: cpu, lastTime: time.Now()}
+}
+
+// sample measures the resources used since the last sample, by the ticker of
+// the report only
+func (m *clientMonitor) sample() clientSample {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	c := clientSample{
+		Mem:        float64(ms.Sys) / 1024 / 1024,
+		Goroutines: runtime.NumGoroutine(),
+		FDs:        openFiles(),
+		FDLimit:    openFilesLimit(),
+	}
+	now := time.Now()
+	if cpu, ok := processCPUTime(); ok {
+		if wall := now.Sub(m.lastTime); wall > 0 {
+			c.CPU = float64(cpu-m.lastCPU) * 100 / float64(wall) / float64(runtime.NumCPU())
+		}
+		m.lastCPU = cpu
+	}
+	m.lastTime = now
+	return c
+}
+
+// observe records the sample of the last second, under the lock of the
+// report
+func (m *clientMonitor) observe(c clientSample) {
+	m.last = c
+	if c.CPU > m.peakCPU {
+		m.peakCPU = c.CPU
+	}
+	if reason := c.saturation(); reason != "" {
+		m.saturated++
+		m.reason = reason
+	}
+}
+
+// report is the saturation of the generator, nil when it never was
+func (m *clientMonitor) report() *ClientReport {
+	if m == nil || m.saturated == 0 {
+		return nil
+	}
+	return &ClientReport{Saturated: m.saturated, PeakCPU: roundFloat(m.peakCPU, 1), Cores: runtime.NumCPU(), Reason: m.reason}
+}
+
+// ClientReport is the seconds during which the load generator was saturated,
+// Reason being the one of the last
+type ClientReport struct {
+	Saturated int     // seconds
+	PeakCPU   float64 // % of all the cores
+	Cores     int
+	Reason    string
+}
+
+// openFiles counts the open files of the process, -1 where unknown
+func openFiles() int {
+	for _, dir := range []string{"/proc/self/fd", "/dev/fd"} {
+		if entries, err := os.ReadDir(dir); err == nil {
+			// but the one reading the directory
+			return len(entries) - 1
+		}
+	}
+	return -1
+}
+
+// client returns the CPU, memory, goroutines and open files of plow in the
+// last second, and the warning when it was saturated, or nils without data
+func (cr *ChartsReport) client() ([]interface{}, string) {
+	if cr == nil {
+		return []interface{}{nil, nil, nil, nil}, ""
+	}
+	c := cr.Client
+	var fds interface{}
+	if c.FDs >= 0 {
+		fds = c.FDs
+	}
+	warning := ""
+	if reason := c.saturation(); reason != "" {
+		warning = "plow itself is saturated, " + reason + ": the results measure the generator rather than the server"
+	}
+	return []interface{}{c.CPU, c.Mem, c.Goroutines, fds}, warning
+}
@@ -0,0 +1,13 @@
+//go:build !unix
+
+package main
+
+import "time"
+
+func processCPUTime() (time.Duration, bool) {
+	return 0, false
+}
+
+func openFilesLimit() int {
+	return 0
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/alecthomas/kingpin.v3-unstable"
+)
+
+// runCompareCommand compares the summary of a run with the one of a baseline,
+// exiting 1 when its RPS dropped or its P99 rose by more than --max-regression,
+// or when either can't be compared
+func runCompareCommand(args []string) {
+	app := kingpin.New("plow compare", "Compare the --summary-json summary of a run with the one of a baseline, failing when the RPS dropped or the P99 rose by more than the allowed regression")
+	baselineFile := app.Arg("baseline", "Summary JSON of the baseline run").Required().String()
+	currentFile := app.Arg("current", "Summary JSON of the run compared with the baseline").Required().String()
+	maxRegression := app.Flag("max-regression", "Allowed drop of the RPS and rise of the P99, in percent of the baseline").Default("5%").String()
+	app.Version(version)
+	kingpin.MustParse(app.Parse(args))
+
+	limit, err := parsePercent(*maxRegression)
+	if err != nil {
+		errAndExit("--max-regression: " + err.Error())
+		return
+	}
+	baseline, err := readSummaryFile(*baselineFile)
+	if err != nil {
+		errAndExit(err.Error())
+		return
+	}
+	current, err := readSummaryFile(*currentFile)
+	if err != nil {
+		errAndExit(err.Error())
+		return
+	}
+	rows, failed := compareSummaries(baseline, current, limit)
+	var out bytes.Buffer
+	writeBulk(&out, rows)
+	if failed {
+		out.WriteString(colorize(fmt.Sprintf("\nRegression beyond %s%% of the baseline, or a metric it can't be compared on\n", formatFloat64(limit)), FgRedColor))
+	}
+	os.Stdout.Write(out.Bytes())
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// parsePercent parses a percentage, with or without its `%`
+func parsePercent(s string) (float64, error) {
+	f, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(s), "%"), 64)
+	if err != nil || f < 0 {
+		return 0, fmt.Errorf("%q is not a positive percentage", s)
+	}
+	return f, nil
+}
+
+func readSummaryFile(path string) (*Summary, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s, err := readSummary(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	return s, nil
+}
+
+// summaryP99 is the P99 of s, whatever its latency unit
+func summaryP99(s *Summary) (time.Duration, bool) {
+	p99, ok := s.Percentiles["P99"]
+	if !ok {
+		return 0, false
+	}
+	unit := float64(time.Millisecond)
+	if s.LatencyUnit == "s" {
+		unit = float64(time.Second)
+	}
+	return time.Duration(p99 * unit), true
+}
+
+// compareSummaries is the table comparing the RPS and the P99 of current
+// with the ones of baseline, and whether either regressed by more than
+// limit percent, or can't be compared, missing or without a baseline
+func compareSummaries(baseline, current *Summary, limit float64) ([][]string, bool) {
+	bulk := [][]string{{"", "Baseline", "Current", "Change", ""}}
+	failed := false
+	fail := colorize("✗", FgRedColor)
+	row := func(name, base, cur string, change float64, worse bool) {
+		mark := colorize("✓", FgGreenColor)
+		if worse {
+			mark, failed = fail, true
+		}
+		bulk = append(bulk, []string{name, base, cur, fmt.Sprintf("%+.2f%%", change), mark})
+	}
+	missing := func(name, base, cur string) {
+		bulk = append(bulk, []string{name, base, cur, "n/a", fail})
+		failed = true
+	}
+
+	baseRPS, curRPS := fmt.Sprintf("%.3f", baseline.RPS), fmt.Sprintf("%.3f", current.RPS)
+	if baseline.RPS == 0 {
+		missing("RPS", baseRPS, curRPS)
+	} else {
+		c := (current.RPS - baseline.RPS) * 100 / baseline.RPS
+		row("RPS", baseRPS, curRPS, c, -c > limit)
+	}
+
+	baseP99, ok1 := summaryP99(baseline)
+	curP99, ok2 := summaryP99(current)
+	p99 := func(d time.Duration, ok bool) string {
+		if !ok {
+			return "-"
+		}
+		return durationToString(d, false)
+	}
+	if !ok1 || !ok2 || baseP99 == 0 {
+		missing("P99", p99(baseP99, ok1), p99(curP99, ok2))
+	} else {
+		c := float64(curP99-baseP99) * 100 / float64(baseP99)
+		row("P99", p99(baseP99, ok1), p99(curP99, ok2), c, c > limit)
+	}
+	alignBulk(bulk, AlignLeft, AlignRight, AlignRight, AlignRight, AlignLeft)
+	return bulk, failed
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestCompareSummaries checks the gates of plow compare, failing the metrics
+// without a value or a baseline to compare with
+func TestCompareSummaries(t *testing.T) {
+	summary := func(rps float64, percentiles map[string]float64) *Summary {
+		return &Summary{RPS: rps, LatencyUnit: "ms", Percentiles: percentiles}
+	}
+	p99 := func(ms float64) map[string]float64 { return map[string]float64{"P99": ms} }
+
+	tests := []struct {
+		name     string
+		baseline *Summary
+		current  *Summary
+		failed   bool
+		changes  []string // the Change column of the RPS and P99 rows
+	}{
+		{"same", summary(100, p99(10)), summary(100, p99(10)), false, []string{"+0.00%", "+0.00%"}},
+		{"within the limit", summary(100, p99(10)), summary(96, p99(10.4)), false, []string{"-4.00%", "+4.00%"}},
+		{"faster", summary(100, p99(10)), summary(150, p99(5)), false, []string{"+50.00%", "-50.00%"}},
+		{"rps dropped", summary(100, p99(10)), summary(90, p99(10)), true, []string{"-10.00%", "+0.00%"}},
+		{"p99 rose", summary(100, p99(10)), summary(100, p99(12)), true, []string{"+0.00%", "+20.00%"}},
+		{"no baseline p99", summary(100, nil), summary(100, p99(10)), true, []string{"+0.00%", "n/a"}},
+		{"no current p99", summary(100, p99(10)), summary(100, map[string]float64{"P90": 8}), true, []string{"+0.00%", "n/a"}},
+		{"zero baseline rps", summary(0, p99(10)), summary(100, p99(10)), true, []string{"n/a", "+0.00%"}},
+		{"zero baseline p99", summary(100, p99(0)), summary(100, p99(10)), true, []string{"+0.00%", "n/a"}},
+		{"seconds", &Summary{RPS: 100, LatencyUnit: "s", Percentiles: p99(0.01)}, summary(100, p99(10)), false, []string{"+0.00%", "+0.00%"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, failed := compareSummaries(tt.baseline, tt.current, 5)
+			if failed != tt.failed {
+				t.Errorf("failed = %v, want %v", failed, tt.failed)
+			}
+			if len(rows) != 3 {
+				t.Fatalf("got %d rows, want the header, RPS and P99", len(rows))
+			}
+			for i, want := range tt.changes {
+				if got := strings.TrimSpace(rows[i+1][3]); got != want {
+					t.Errorf("%s change = %q, want %q", strings.TrimSpace(rows[i+1][0]), got, want)
+				}
+			}
+		})
+	}
+}
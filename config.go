@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+	"gopkg.in/yaml.v2"
+)
+
+// benchConfigVersion is the version of the exported config files
+const benchConfigVersion = 1
+
+// benchConfig is the file a benchmark of the GUI form is exported to, as
+// JSON or YAML, imported back into the form or run by `plow run -f FILE`
+type benchConfig struct {
+	Version int `json:"version"`
+	BenchmarkRequest
+}
+
+// parseBenchConfig parses a config file, JSON when it starts with `{` and
+// YAML otherwise, with the same keys
+func parseBenchConfig(data []byte) (*BenchmarkRequest, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] != '{' {
+		var v interface{}
+		if err := yaml.Unmarshal(data, &v); err != nil {
+			return nil, err
+		}
+		var err error
+		if data, err = json.Marshal(jsonValue(v)); err != nil {
+			return nil, err
+		}
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var c benchConfig
+	if err := dec.Decode(&c); err != nil {
+		return nil, err
+	}
+	if c.Version > benchConfigVersion {
+		return nil, fmt.Errorf("config version %d is newer than the supported %d", c.Version, benchConfigVersion)
+	}
+	if c.URL == "" && c.HAR == "" {
+		return nil, fmt.Errorf("config has neither a url nor a har")
+	}
+	return &c.BenchmarkRequest, nil
+}
+
+// jsonValue converts the maps of a YAML value to the ones of JSON
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			m[fmt.Sprint(k)] = jsonValue(val)
+		}
+		return m
+	case []interface{}:
+		for i, val := range v {
+			v[i] = jsonValue(val)
+		}
+	}
+	return v
+}
+
+// marshalBenchConfig writes the config of req as YAML, or as JSON for the
+// json format, without the preset and the notes of the run
+func marshalBenchConfig(req BenchmarkRequest, format string) ([]byte, error) {
+	req.Preset, req.Notes = "", ""
+	data, err := json.MarshalIndent(&benchConfig{Version: benchConfigVersion, BenchmarkRequest: req}, "", "  ")
+	if err != nil || format == "json" {
+		return data, err
+	}
+	// the keys of the JSON in their order
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	var doc yaml.MapSlice
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		var v interface{}
+		if err := dec.Decode(&v); err != nil {
+			return nil, err
+		}
+		if f, ok := v.(float64); ok && f == math.Trunc(f) {
+			v = int64(f)
+		}
+		doc = append(doc, yaml.MapItem{Key: key, Value: v})
+	}
+	return yaml.Marshal(doc)
+}
+
+// args are the arguments of the main command line running the config, but
+// for the flags in overridden. The HAR is written to a temporary file, whose
+// path is returned to be removed once read.
+func (req *BenchmarkRequest) args(overridden map[string]bool) (args []string, harPath string, err error) {
+	flag := func(name, value string) {
+		if !overridden[name] {
+			args = append(args, "--"+name+"="+value)
+		}
+	}
+	boolFlag := func(name string, set bool) {
+		if set && !overridden[name] {
+			args = append(args, "--"+name)
+		}
+	}
+	if req.HAR != "" && !overridden["har"] {
+		f, err := os.CreateTemp("", "plow-config-*.har")
+		if err != nil {
+			return nil, "", err
+		}
+		_, err = f.WriteString(req.HAR)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(f.Name())
+			return nil, "", err
+		}
+		harPath = f.Name()
+		flag("har", harPath)
+		if req.HARMode != "" {
+			flag("har-mode", req.HARMode)
+		}
+	} else if req.HAR == "" {
+		args = append(args, req.URL)
+	}
+	if req.Concurrency > 0 {
+		flag("concurrency", strconv.Itoa(req.Concurrency))
+	}
+	if req.Duration > 0 {
+		flag("duration", strconv.Itoa(req.Duration)+"s")
+	}
+	if req.Requests > 0 {
+		flag("requests", strconv.FormatInt(req.Requests, 10))
+	}
+	if req.Rate > 0 {
+		// --rate takes whole requests, over a longer time for fractional rates
+		if req.Rate == math.Trunc(req.Rate) {
+			flag("rate", strconv.FormatFloat(req.Rate, 'f', -1, 64))
+		} else {
+			flag("rate", fmt.Sprintf("%d/1000s", int64(math.Round(req.Rate*1000))))
+		}
+	}
+	if req.Method != "" {
+		flag("method", strings.ToUpper(req.Method))
+	}
+	for _, a := range req.Agents {
+		args = append(args, "--agent="+a)
+	}
+	if req.Probe != "" {
+		flag("probe", req.Probe)
+	}
+	for _, h := range req.Headers {
+		args = append(args, "--header="+h)
+	}
+	if req.Body != "" {
+		flag("body", req.Body)
+	}
+	if req.User != "" {
+		flag("user", req.User)
+	}
+	boolFlag("digest", req.Digest)
+	if req.Cert != "" {
+		flag("cert", req.Cert)
+	}
+	if req.Key != "" {
+		flag("key", req.Key)
+	}
+	if req.CACert != "" {
+		flag("cacert", req.CACert)
+	}
+	boolFlag("insecure", req.Insecure)
+	boolFlag("soak", req.Soak)
+	return args, harPath, nil
+}
+
+// runConfigCommand turns `plow run -f FILE [FLAGS]` into the main command
+// line running the config of FILE, the flags overriding its own
+func runConfigCommand(args []string) (mainArgs []string, harPath string, err error) {
+	var path string
+	var rest []string
+	for i := 0; i < len(args); i++ {
+		switch a := args[i]; {
+		case (a == "-f" || a == "--file") && i+1 < len(args):
+			path = args[i+1]
+			i++
+		case strings.HasPrefix(a, "--file="):
+			path = a[len("--file="):]
+		default:
+			rest = append(rest, a)
+		}
+	}
+	if path == "" {
+		return nil, "", fmt.Errorf("usage: plow run -f CONFIG.yaml [FLAGS]")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", err
+	}
+	req, err := parseBenchConfig(data)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %s", path, err)
+	}
+	cfgArgs, harPath, err := req.args(overriddenFlags(rest))
+	if err != nil {
+		return nil, "", err
+	}
+	return append(cfgArgs, rest...), harPath, nil
+}
+
+// handleExportConfig sends the posted form as a config file, YAML unless
+// ?format=json
+func (g *GUIServer) handleExportConfig(ctx *fasthttp.RequestCtx) {
+	var req BenchmarkRequest
+	if err := json.Unmarshal(ctx.PostBody(), &req); err != nil {
+		ctx.SetStatusCode(400)
+		ctx.SetContentType("application/json")
+		json.NewEncoder(ctx).Encode(map[string]string{"error": "invalid request: " + err.Error()})
+		return
+	}
+	format := string(ctx.QueryArgs().Peek("format"))
+	data, err := marshalBenchConfig(req, format)
+	if err != nil {
+		ctx.SetStatusCode(500)
+		ctx.SetContentType("application/json")
+		json.NewEncoder(ctx).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	name := "plow.yaml"
+	ctx.SetContentType("application/yaml")
+	if format == "json" {
+		name = "plow.json"
+		ctx.SetContentType("application/json")
+	}
+	ctx.Response.Header.Set("Content-Disposition", `attachment; filename="`+name+`"`)
+	ctx.Write(data)
+}
+
+// handleImportConfig parses a config file into the request filling the form
+func (g *GUIServer) handleImportConfig(ctx *fasthttp.RequestCtx) {
+	ctx.SetContentType("application/json")
+	req, err := parseBenchConfig(ctx.PostBody())
+	if err != nil {
+		ctx.SetStatusCode(400)
+		json.NewEncoder(ctx).Encode(map[string]string{"error": "invalid config: " + err.Error()})
+		return
+	}
+	json.NewEncoder(ctx).Encode(req)
+}
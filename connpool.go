@@ -0,0 +1,17 @@
+package main
+
+import "sync/atomic"
+
+// connPool counts the connections of the clients of a run, opened by their
+// dials and closed by the clients or by the server
+type connPool struct {
+	opened int64
+	closed int64
+}
+
+func (p *connPool) counts() (opened, closed int64) {
+	if p == nil {
+		return 0, 0
+	}
+	return atomic.LoadInt64(&p.opened), atomic.LoadInt64(&p.closed)
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"context"
+	"net"
+
+	"github.com/valyala/fasthttp"
+	"golang.org/x/time/rate"
+)
+
+// connRateDial waits for the --conn-rate limiter before each dial, for the
+// clients without a phase tracker, which waits for it itself
+func connRateDial(dial fasthttp.DialFunc, limiter *rate.Limiter) fasthttp.DialFunc {
+	return func(addr string) (net.Conn, error) {
+		if err := limiter.Wait(context.Background()); err != nil {
+			return nil, err
+		}
+		return dial(addr)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+
+	"github.com/valyala/fasthttp"
+)
+
+// cookieJar keeps the cookies set by the responses of one worker, sent back
+// in its next requests like the browser of a distinct user would
+type cookieJar struct {
+	jar *cookiejar.Jar
+	// names are the cookies the jar ever had, replaced or removed on the
+	// reused requests of the worker
+	names map[string]bool
+}
+
+func newCookieJar() *cookieJar {
+	jar, _ := cookiejar.New(nil)
+	return &cookieJar{jar: jar, names: make(map[string]bool)}
+}
+
+// requestURL is the url req is sent to, as seen by the server
+func requestURL(req *fasthttp.Request) *url.URL {
+	u := &url.URL{Scheme: "http", Host: string(req.Header.Host()), Path: string(req.URI().Path())}
+	if string(req.URI().Scheme()) == "https" {
+		u.Scheme = "https"
+	}
+	return u
+}
+
+// apply sets the cookies of the jar matching req on it
+func (j *cookieJar) apply(req *fasthttp.Request) {
+	if len(j.names) == 0 {
+		return
+	}
+	for name := range j.names {
+		req.Header.DelCookie(name)
+	}
+	for _, c := range j.jar.Cookies(requestURL(req)) {
+		req.Header.SetCookie(c.Name, c.Value)
+	}
+}
+
+// update stores the Set-Cookie headers of resp, the response to req
+func (j *cookieJar) update(req *fasthttp.Request, resp *fasthttp.Response) {
+	var lines []string
+	resp.Header.VisitAllCookie(func(_, value []byte) {
+		lines = append(lines, string(value))
+	})
+	if len(lines) == 0 {
+		return
+	}
+	cookies := (&http.Response{Header: http.Header{"Set-Cookie": lines}}).Cookies()
+	for _, c := range cookies {
+		j.names[c.Name] = true
+	}
+	j.jar.SetCookies(requestURL(req), cookies)
+}
@@ -0,0 +1,348 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// curlRequest is the request of a copied cURL command, e.g. with "Copy as
+// cURL" of the browser dev tools
+type curlRequest struct {
+	URL      string   `json:"url"`
+	Method   string   `json:"method"`
+	Headers  []string `json:"headers,omitempty"`
+	Body     string   `json:"body,omitempty"`
+	Insecure bool     `json:"insecure,omitempty"`
+	// flags are the plow flags of the other curl options, e.g. --proxy
+	flags []string
+}
+
+// curlSwitches are the curl options without argument that don't change the
+// request, or are implied by plow
+var curlSwitches = map[string]bool{
+	"-s": true, "--silent": true, "-S": true, "--show-error": true,
+	"-v": true, "--verbose": true, "-i": true, "--include": true, "-f": true, "--fail": true,
+	"-N": true, "--no-buffer": true, "-g": true, "--globoff": true, "--http1.1": true, "--http2": true,
+	"--http2-prior-knowledge": true, "--tr-encoding": true, "--path-as-is": true, "-#": true, "--progress-bar": true,
+}
+
+// curlBools are the curl options without argument that change the request
+var curlBools = map[string]bool{
+	"--compressed": true, "-k": true, "--insecure": true, "-G": true, "--get": true, "-I": true, "--head": true,
+	"-L": true, "--location": true,
+}
+
+// curlIgnored are the curl options whose argument doesn't change the request
+var curlIgnored = map[string]bool{
+	"-o": true, "--output": true, "-w": true, "--write-out": true, "-D": true, "--dump-header": true,
+	"-c": true, "--cookie-jar": true, "--retry": true,
+}
+
+// curlFlags map the curl options with argument that plow supports to its flags
+var curlFlags = map[string]string{
+	"-m": "--timeout", "--max-time": "--timeout", "--connect-timeout": "--dial-timeout",
+	"-x": "--proxy", "--proxy": "--proxy", "--cacert": "--cacert", "-E": "--cert", "--cert": "--cert",
+	"--key": "--key", "--unix-socket": "--unix-socket", "--max-redirs": "--max-redirects",
+}
+
+// parseCurl reads the request of a curl command line
+func parseCurl(cmd string) (*curlRequest, error) {
+	args, err := shellWords(cmd)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) > 0 && (args[0] == "curl" || strings.HasSuffix(args[0], "/curl") || args[0] == "curl.exe") {
+		args = args[1:]
+	}
+	cr := &curlRequest{}
+	var data []string
+	var cookies []string
+	get, head := false, false
+	// setSwitch applies opt if it's an option without argument
+	setSwitch := func(opt string) bool {
+		switch opt {
+		case "--compressed":
+			cr.Headers = append(cr.Headers, "Accept-Encoding:deflate, gzip, br")
+			cr.flags = append(cr.flags, "--compressed")
+		case "-k", "--insecure":
+			cr.Insecure = true
+		case "-G", "--get":
+			get = true
+		case "-I", "--head":
+			head = true
+		case "-L", "--location":
+			cr.flags = append(cr.flags, "--follow-redirects")
+		default:
+			return curlSwitches[opt]
+		}
+		return true
+	}
+	hasHeader := func(name string) bool {
+		for _, h := range cr.Headers {
+			if n := strings.SplitN(h, ":", 2); strings.EqualFold(strings.TrimSpace(n[0]), name) {
+				return true
+			}
+		}
+		return false
+	}
+	for i := 0; i < len(args); i++ {
+		opt := args[i]
+		if !strings.HasPrefix(opt, "-") || opt == "-" {
+			if cr.URL != "" {
+				return nil, fmt.Errorf("curl: more than one url: %s and %s", cr.URL, opt)
+			}
+			cr.URL = opt
+			continue
+		}
+		if setSwitch(opt) {
+			continue
+		}
+		var val string
+		if len(opt) > 2 && opt[1] != '-' {
+			if curlSwitches[opt[:2]] || curlBools[opt[:2]] {
+				// -sSL, unless its first option takes the rest as argument, e.g. -XPOST
+				for _, c := range opt[1:] {
+					if !setSwitch("-" + string(c)) {
+						return nil, fmt.Errorf("curl: unsupported option %s", opt)
+					}
+				}
+				continue
+			}
+			opt, val = opt[:2], opt[2:]
+		} else {
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("curl: option %s needs an argument", opt)
+			}
+			i++
+			val = args[i]
+		}
+		switch opt {
+		case "-X", "--request":
+			cr.Method = strings.ToUpper(val)
+		case "--url":
+			cr.URL = val
+		case "-H", "--header":
+			n := strings.SplitN(val, ":", 2)
+			if len(n) != 2 {
+				// "Name;" sends an empty header, "Name:" removes it in curl
+				continue
+			}
+			cr.Headers = append(cr.Headers, strings.TrimSpace(n[0])+":"+strings.TrimSpace(n[1]))
+		case "-A", "--user-agent":
+			cr.Headers = append(cr.Headers, "User-Agent:"+val)
+		case "-e", "--referer":
+			cr.Headers = append(cr.Headers, "Referer:"+val)
+		case "-b", "--cookie":
+			if !strings.Contains(val, "=") {
+				return nil, fmt.Errorf("curl: cookie files are not supported: %s", val)
+			}
+			cookies = append(cookies, val)
+		case "-u", "--user":
+			cr.Headers = append(cr.Headers, "Authorization:Basic "+base64.StdEncoding.EncodeToString([]byte(val)))
+		case "-d", "--data", "--data-ascii", "--data-binary", "--data-raw":
+			data = append(data, val)
+		case "--data-urlencode":
+			if n := strings.IndexAny(val, "=@"); n >= 0 && val[n] == '=' {
+				data = append(data, val[:n+1]+url.QueryEscape(val[n+1:]))
+			} else if n < 0 {
+				data = append(data, url.QueryEscape(val))
+			} else {
+				return nil, fmt.Errorf("curl: --data-urlencode of a file is not supported: %s", val)
+			}
+		case "-F", "--form", "--form-string":
+			return nil, fmt.Errorf("curl: multipart %s is not supported", opt)
+		default:
+			if f, ok := curlFlags[opt]; ok {
+				cr.flags = append(cr.flags, f, curlFlagValue(f, val))
+			} else if !curlIgnored[opt] {
+				return nil, fmt.Errorf("curl: unsupported option %s", opt)
+			}
+		}
+	}
+	if cr.URL == "" {
+		return nil, fmt.Errorf("curl: no url")
+	}
+	if !strings.Contains(cr.URL, "://") {
+		cr.URL = "http://" + cr.URL
+	}
+	if len(cookies) > 0 {
+		cr.Headers = append(cr.Headers, "Cookie:"+strings.Join(cookies, "; "))
+	}
+	if len(data) > 0 {
+		if get {
+			sep := "?"
+			if strings.Contains(cr.URL, "?") {
+				sep = "&"
+			}
+			cr.URL += sep + strings.Join(data, "&")
+		} else {
+			cr.Body = strings.Join(data, "&")
+			if !hasHeader("Content-Type") {
+				cr.Headers = append(cr.Headers, "Content-Type:application/x-www-form-urlencoded")
+			}
+		}
+	}
+	if cr.Method == "" {
+		switch {
+		case head:
+			cr.Method = "HEAD"
+		case cr.Body != "":
+			cr.Method = "POST"
+		default:
+			cr.Method = "GET"
+		}
+	}
+	return cr, nil
+}
+
+// curlFlagValue converts the argument of a curl option to the one of plow
+func curlFlagValue(flag, val string) string {
+	switch flag {
+	case "--timeout", "--dial-timeout":
+		// seconds, possibly fractional
+		return val + "s"
+	case "--max-redirects":
+		// -1 is unlimited in curl
+		if strings.HasPrefix(val, "-") {
+			return "1000"
+		}
+	case "--cert":
+		// plow has no passphrase
+		if n := strings.Index(val, ":"); n > 0 {
+			return val[:n]
+		}
+	}
+	return val
+}
+
+// args are the plow arguments sending the request
+func (cr *curlRequest) args() []string {
+	args := []string{cr.URL}
+	if cr.Method != "GET" {
+		args = append(args, "--method", cr.Method)
+	}
+	for _, h := range cr.Headers {
+		args = append(args, "--header", h)
+	}
+	if cr.Body != "" {
+		args = append(args, "--body", cr.Body)
+	}
+	if cr.Insecure {
+		args = append(args, "--insecure")
+	}
+	return append(args, cr.flags...)
+}
+
+// shellWords splits a POSIX shell command line, with its quotes, escapes,
+// $'...' strings and line continuations
+func shellWords(s string) ([]string, error) {
+	var words []string
+	var w strings.Builder
+	inWord := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && (s[i+1] == '\n' || s[i+1] == '\r'):
+			// line continuation
+			i++
+			if s[i] == '\r' && i+1 < len(s) && s[i+1] == '\n' {
+				i++
+			}
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if inWord {
+				words = append(words, w.String())
+				w.Reset()
+				inWord = false
+			}
+		case c == '\\':
+			inWord = true
+			if i+1 < len(s) {
+				i++
+				w.WriteByte(s[i])
+			}
+		case c == '\'':
+			inWord = true
+			j := strings.IndexByte(s[i+1:], '\'')
+			if j < 0 {
+				return nil, fmt.Errorf("unterminated ' quote")
+			}
+			w.WriteString(s[i+1 : i+1+j])
+			i += j + 1
+		case c == '$' && i+1 < len(s) && s[i+1] == '\'':
+			inWord = true
+			n, err := ansiCString(s[i+2:], &w)
+			if err != nil {
+				return nil, err
+			}
+			i += n + 2
+		case c == '"':
+			inWord = true
+			j := i + 1
+			for ; j < len(s) && s[j] != '"'; j++ {
+				if s[j] == '\\' && j+1 < len(s) && strings.IndexByte("\"\\$`\n", s[j+1]) >= 0 {
+					j++
+					if s[j] == '\n' {
+						continue
+					}
+				}
+				w.WriteByte(s[j])
+			}
+			if j >= len(s) {
+				return nil, fmt.Errorf("unterminated \" quote")
+			}
+			i = j
+		default:
+			inWord = true
+			w.WriteByte(c)
+		}
+	}
+	if inWord {
+		words = append(words, w.String())
+	}
+	return words, nil
+}
+
+// ansiCString writes the $'...' string starting at s, after its quote, and
+// returns the length read including the closing quote
+func ansiCString(s string, w *strings.Builder) (int, error) {
+	escapes := map[byte]byte{'n': '\n', 't': '\t', 'r': '\r', '\\': '\\', '\'': '\'', '"': '"', 'a': '\a', 'b': '\b', 'e': 0x1b, 'f': '\f', 'v': '\v'}
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\'':
+			return i + 1, nil
+		case '\\':
+			if i+1 >= len(s) {
+				break
+			}
+			i++
+			if e, ok := escapes[s[i]]; ok {
+				w.WriteByte(e)
+			} else if s[i] == 'x' || s[i] == 'u' {
+				n := 2
+				if s[i] == 'u' {
+					n = 4
+				}
+				j := i + 1
+				for j < len(s) && j < i+1+n && strings.IndexByte("0123456789abcdefABCDEF", s[j]) >= 0 {
+					j++
+				}
+				var r rune
+				fmt.Sscanf(s[i+1:j], "%x", &r)
+				if s[i] == 'x' {
+					w.WriteByte(byte(r))
+				} else {
+					w.WriteRune(r)
+				}
+				i = j - 1
+			} else {
+				w.WriteByte('\\')
+				w.WriteByte(s[i])
+			}
+			continue
+		}
+		w.WriteByte(s[i])
+	}
+	return 0, fmt.Errorf("unterminated $' quote")
+}
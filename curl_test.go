@@ -0,0 +1,86 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCurl(t *testing.T) {
+	tests := []struct {
+		name string
+		cmd  string
+		want *curlRequest
+	}{
+		{
+			"copied from the browser", `curl 'https://api.example.com/x' -H 'Accept: application/json' --compressed`,
+			&curlRequest{
+				URL: "https://api.example.com/x", Method: "GET",
+				Headers: []string{"Accept:application/json", "Accept-Encoding:deflate, gzip, br"},
+				flags:   []string{"--compressed"},
+			},
+		},
+		{
+			"form data", `curl -XPOST example.com/api -d 'a=1' -d 'b=2'`,
+			&curlRequest{
+				URL: "http://example.com/api", Method: "POST", Body: "a=1&b=2",
+				Headers: []string{"Content-Type:application/x-www-form-urlencoded"},
+			},
+		},
+		{
+			"json body", `curl https://a/x -H 'Content-Type: application/json' --data-raw '{"a":1}'`,
+			&curlRequest{URL: "https://a/x", Method: "POST", Body: `{"a":1}`, Headers: []string{"Content-Type:application/json"}},
+		},
+		{
+			"data in the query", `curl -G 'https://a/x?q=1' --data-urlencode 'name=a b'`,
+			&curlRequest{URL: "https://a/x?q=1&name=a+b", Method: "GET"},
+		},
+		{
+			"grouped switches", `curl -sSLk -u user:pass https://a/`,
+			&curlRequest{
+				URL: "https://a/", Method: "GET", Insecure: true,
+				Headers: []string{"Authorization:Basic dXNlcjpwYXNz"},
+				flags:   []string{"--follow-redirects"},
+			},
+		},
+		{"head", `curl -I https://a/`, &curlRequest{URL: "https://a/", Method: "HEAD"}},
+		{
+			"cookies and plow flags", `curl https://a/ -b 'x=1' -b 'y=2' -m 2.5 -x http://proxy:3128 -o /dev/null`,
+			&curlRequest{
+				URL: "https://a/", Method: "GET", Headers: []string{"Cookie:x=1; y=2"},
+				flags: []string{"--timeout", "2.5s", "--proxy", "http://proxy:3128"},
+			},
+		},
+		{
+			"continued lines and ansi-c quotes", "curl https://a/ \\\n  -H $'X-Tab: a\\tb' \\\n  -H 'Empty;'",
+			&curlRequest{URL: "https://a/", Method: "GET", Headers: []string{"X-Tab:a\tb"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseCurl(tt.cmd)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCurlErrors(t *testing.T) {
+	for _, cmd := range []string{
+		`curl`,
+		`curl 'https://a/`,
+		`curl https://a/ https://b/`,
+		`curl -F a=1 https://a/`,
+		`curl -b cookies.txt https://a/`,
+		`curl --data-urlencode name@file https://a/`,
+		`curl --foo https://a/`,
+		`curl https://a/ -H`,
+	} {
+		if cr, err := parseCurl(cmd); err == nil {
+			t.Errorf("%s: got %+v, want an error", cmd, cr)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+)
+
+// data feed modes: all workers share one cursor, pick rows at random, or
+// each worker goes through its own share of the rows
+const (
+	dataSequential = "sequential"
+	dataRandom     = "random"
+	dataPartition  = "partition"
+)
+
+// dataFeed holds the rows of --data-file, each request taking the next one
+// as the data of its templates, e.g. {{.user}}. Rows wrap around at the end.
+type dataFeed struct {
+	rows []map[string]interface{}
+	mode string
+	seq  uint64
+}
+
+// loadDataFeed reads a CSV file with a header line, or a JSON Lines file of
+// objects when named .jsonl or .ndjson
+func loadDataFeed(path, mode string) (*dataFeed, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rows []map[string]interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jsonl", ".ndjson":
+		rows, err = parseJSONLRows(data)
+	default:
+		rows, err = parseCSVRows(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%s: no data row", path)
+	}
+	return &dataFeed{rows: rows, mode: mode}, nil
+}
+
+func parseCSVRows(data []byte) ([]map[string]interface{}, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.TrimLeadingSpace = true
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	header := records[0]
+	rows := make([]map[string]interface{}, 0, len(records)-1)
+	for _, rec := range records[1:] {
+		row := make(map[string]interface{}, len(header))
+		for i, name := range header {
+			row[name] = rec[i]
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func parseJSONLRows(data []byte) ([]map[string]interface{}, error) {
+	var rows []map[string]interface{}
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for n := 1; sc.Scan(); n++ {
+		line := bytes.TrimSpace(sc.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		dec := json.NewDecoder(bytes.NewReader(line))
+		dec.UseNumber()
+		var row map[string]interface{}
+		if err := dec.Decode(&row); err != nil {
+			return nil, fmt.Errorf("line %d: %s", n, err)
+		}
+		rows = append(rows, row)
+	}
+	return rows, sc.Err()
+}
+
+// next returns the row of the next request of worker out of workers,
+// cursor being the count of rows the worker already took
+func (f *dataFeed) next(worker, workers int, cursor *int) map[string]interface{} {
+	n := len(f.rows)
+	switch f.mode {
+	case dataRandom:
+		return f.rows[rand.Intn(n)]
+	case dataPartition:
+		if worker >= n {
+			// more workers than rows, the rows are shared
+			return f.rows[worker%n]
+		}
+		share := (n - worker + workers - 1) / workers
+		i := worker + (*cursor%share)*workers
+		*cursor++
+		return f.rows[i]
+	}
+	return f.rows[(atomic.AddUint64(&f.seq, 1)-1)%uint64(n)]
+}
+
+// part returns the rows of agent i of n in partition mode, and all of them otherwise
+func (f *dataFeed) part(i, n int) []map[string]interface{} {
+	if f.mode != dataPartition || n <= 1 {
+		return f.rows
+	}
+	var rows []map[string]interface{}
+	for j := i; j < len(f.rows); j += n {
+		rows = append(rows, f.rows[j])
+	}
+	if len(rows) == 0 {
+		rows = []map[string]interface{}{f.rows[i%len(f.rows)]}
+	}
+	return rows
+}
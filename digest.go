@@ -0,0 +1,213 @@
+package main
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// digestChallenge is the Digest challenge of a WWW-Authenticate header
+type digestChallenge struct {
+	realm     string
+	nonce     string
+	opaque    string
+	algorithm string
+	qop       string // the qop chosen among the ones offered, "" without
+	userhash  bool
+}
+
+// digestAuth answers the Digest challenge of the server for each request,
+// with the nonce of the first challenge of the connection and its own count
+type digestAuth struct {
+	user      string
+	password  string
+	challenge *digestChallenge
+	nc        int
+}
+
+// digestUser is the --user of Digest auth, "" with Basic auth
+func (opt *ClientOpt) digestUser() string {
+	if !opt.digest {
+		return ""
+	}
+	return opt.user
+}
+
+func newDigestAuth(user string) (*digestAuth, error) {
+	name, password, ok := strings.Cut(user, ":")
+	if !ok {
+		return nil, fmt.Errorf("--user must be USER:PASSWORD")
+	}
+	return &digestAuth{user: name, password: password}, nil
+}
+
+// authParams splits the comma separated key=value parameters of a challenge,
+// the quoted values keeping their commas
+func authParams(s string) map[string]string {
+	params := make(map[string]string)
+	for s = strings.TrimSpace(s); s != ""; {
+		eq := strings.IndexByte(s, '=')
+		if eq < 0 {
+			break
+		}
+		key := strings.ToLower(strings.TrimSpace(s[:eq]))
+		s = strings.TrimSpace(s[eq+1:])
+		var val string
+		if strings.HasPrefix(s, `"`) {
+			var b strings.Builder
+			i := 1
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				b.WriteByte(s[i])
+			}
+			val, s = b.String(), s[min(i+1, len(s)):]
+		} else {
+			end := strings.IndexByte(s, ',')
+			if end < 0 {
+				end = len(s)
+			}
+			val, s = strings.TrimSpace(s[:end]), s[end:]
+		}
+		params[key] = val
+		s = strings.TrimLeft(s, ", \t")
+	}
+	return params
+}
+
+// parseDigestChallenge reads the first Digest challenge of resp with an
+// algorithm and qop supported
+func parseDigestChallenge(resp *fasthttp.Response) (*digestChallenge, error) {
+	var c *digestChallenge
+	err := fmt.Errorf("digest: no challenge in %d response", resp.StatusCode())
+	resp.Header.VisitAll(func(k, v []byte) {
+		if c != nil || !strings.EqualFold(string(k), "WWW-Authenticate") {
+			return
+		}
+		value := string(v)
+		if len(value) < 7 || !strings.EqualFold(value[:7], "Digest ") {
+			return
+		}
+		p := authParams(value[7:])
+		dc := &digestChallenge{realm: p["realm"], nonce: p["nonce"], opaque: p["opaque"], algorithm: p["algorithm"], userhash: strings.EqualFold(p["userhash"], "true")}
+		if dc.algorithm == "" {
+			dc.algorithm = "MD5"
+		}
+		if qop, ok := p["qop"]; ok {
+			for _, q := range strings.Split(qop, ",") {
+				if q = strings.TrimSpace(q); q == "auth" || q == "auth-int" && dc.qop == "" {
+					dc.qop = q
+				}
+			}
+			if dc.qop == "" {
+				err = fmt.Errorf("digest: unsupported qop %s", qop)
+				return
+			}
+		}
+		switch {
+		case dc.nonce == "":
+			err = fmt.Errorf("digest: no nonce in the challenge")
+		case digestHash(dc.algorithm) == nil:
+			err = fmt.Errorf("digest: unsupported algorithm %s", dc.algorithm)
+		default:
+			c, err = dc, nil
+		}
+	})
+	return c, err
+}
+
+// digestHash returns the hash of a Digest algorithm, nil if unsupported
+func digestHash(algorithm string) func() hash.Hash {
+	switch strings.TrimSuffix(strings.ToUpper(algorithm), "-SESS") {
+	case "MD5":
+		return md5.New
+	case "SHA-256":
+		return sha256.New
+	}
+	return nil
+}
+
+func (a *digestAuth) authorize(client *fasthttp.HostClient, req *fasthttp.Request, timeout time.Duration) error {
+	leg := fasthttp.AcquireRequest()
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseRequest(leg)
+	defer fasthttp.ReleaseResponse(resp)
+	req.Header.CopyTo(&leg.Header)
+	leg.Header.Del("Authorization")
+	leg.Header.SetContentLength(0)
+	if err := doTimeout(client, leg, resp, timeout); err != nil {
+		return err
+	}
+	if resp.StatusCode() != fasthttp.StatusUnauthorized {
+		return fmt.Errorf("digest: no challenge in %d response", resp.StatusCode())
+	}
+	c, err := parseDigestChallenge(resp)
+	if err != nil {
+		return err
+	}
+	a.challenge, a.nc = c, 0
+	a.sign(req)
+	return nil
+}
+
+func (a *digestAuth) forConn() authScheme {
+	return &digestAuth{user: a.user, password: a.password}
+}
+
+// sign sets the Authorization header of req, answering the challenge with the
+// next nonce count
+func (a *digestAuth) sign(req *fasthttp.Request) {
+	c := a.challenge
+	newHash := digestHash(c.algorithm)
+	h := func(s string) string {
+		d := newHash()
+		d.Write([]byte(s))
+		return hex.EncodeToString(d.Sum(nil))
+	}
+	a.nc++
+	nc := fmt.Sprintf("%08x", a.nc)
+	b := make([]byte, 16)
+	rand.Read(b)
+	cnonce := hex.EncodeToString(b)
+	uri := string(req.URI().RequestURI())
+
+	ha1 := h(a.user + ":" + c.realm + ":" + a.password)
+	if strings.HasSuffix(strings.ToUpper(c.algorithm), "-SESS") {
+		ha1 = h(ha1 + ":" + c.nonce + ":" + cnonce)
+	}
+	ha2 := h(string(req.Header.Method()) + ":" + uri)
+	if c.qop == "auth-int" {
+		ha2 = h(string(req.Header.Method()) + ":" + uri + ":" + h(string(req.Body())))
+	}
+	var response string
+	if c.qop == "" {
+		response = h(ha1 + ":" + c.nonce + ":" + ha2)
+	} else {
+		response = h(ha1 + ":" + c.nonce + ":" + nc + ":" + cnonce + ":" + c.qop + ":" + ha2)
+	}
+
+	user := a.user
+	if c.userhash {
+		user = h(a.user + ":" + c.realm)
+	}
+	v := fmt.Sprintf(`Digest username="%s", realm="%s", nonce="%s", uri="%s", algorithm=%s, response="%s"`,
+		user, c.realm, c.nonce, uri, c.algorithm, response)
+	if c.opaque != "" {
+		v += fmt.Sprintf(`, opaque="%s"`, c.opaque)
+	}
+	if c.qop != "" {
+		v += fmt.Sprintf(`, qop=%s, nc=%s, cnonce="%s"`, c.qop, nc, cnonce)
+	}
+	if c.userhash {
+		v += ", userhash=true"
+	}
+	req.Header.Set("Authorization", v)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// dnsResolver resolves the hosts dialed by the workers when --resolver,
+// --dns-refresh, --resolve-once or --dns-round-robin is given, the system
+// resolver being asked on each new connection otherwise.
+type dnsResolver struct {
+	resolver   *net.Resolver
+	refresh    time.Duration // 0 resolves on each new connection
+	once       bool
+	roundRobin bool
+
+	mu    sync.Mutex
+	hosts map[string]*dnsEntry
+}
+
+type dnsEntry struct {
+	ips      []net.IPAddr
+	resolved time.Time
+	next     int // kept across refreshes
+}
+
+func newDNSResolver(server string, refresh time.Duration, once, roundRobin bool) (*dnsResolver, error) {
+	if server == "" && refresh == 0 && !once && !roundRobin {
+		return nil, nil
+	}
+	if refresh < 0 {
+		return nil, fmt.Errorf("--dns-refresh must be positive")
+	}
+	if once && refresh > 0 {
+		return nil, fmt.Errorf("--resolve-once and --dns-refresh can't be used at the same time")
+	}
+	d := &dnsResolver{
+		resolver:   net.DefaultResolver,
+		refresh:    refresh,
+		once:       once,
+		roundRobin: roundRobin,
+		hosts:      make(map[string]*dnsEntry),
+	}
+	if server != "" {
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			server = net.JoinHostPort(server, "53")
+		}
+		if host, _, _ := net.SplitHostPort(server); net.ParseIP(host) == nil {
+			return nil, fmt.Errorf("invalid --resolver %q: must be an ip address", server)
+		}
+		d.resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, network, server)
+			},
+		}
+	}
+	return d, nil
+}
+
+// lookup returns the address to dial for host, the first one returned or,
+// with round robin, each of them in turn across the connections.
+func (d *dnsResolver) lookup(ctx context.Context, host string) (net.IP, error) {
+	d.mu.Lock()
+	e := d.hosts[host]
+	if e == nil || d.expired(e) {
+		// resolved under the lock, so that workers dialing at once ask only once
+		ips, err := d.resolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			d.mu.Unlock()
+			return nil, err
+		}
+		if len(ips) == 0 {
+			d.mu.Unlock()
+			return nil, fmt.Errorf("no address found for %s", host)
+		}
+		if e == nil {
+			e = &dnsEntry{}
+			d.hosts[host] = e
+		}
+		e.ips, e.resolved = ips, time.Now()
+	}
+	ip := e.ips[0].IP
+	if d.roundRobin {
+		ip = e.ips[e.next%len(e.ips)].IP
+		e.next++
+	}
+	d.mu.Unlock()
+	return ip, nil
+}
+
+func (d *dnsResolver) expired(e *dnsEntry) bool {
+	if d.once {
+		return false
+	}
+	return d.refresh == 0 || time.Since(e.resolved) >= d.refresh
+}
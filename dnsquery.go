@@ -0,0 +1,334 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	mrand "math/rand"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// dnsUDPTimeout bounds the wait for the answer to a UDP query without
+// --timeout or --read-timeout, a lost datagram having no other end
+const dnsUDPTimeout = 5 * time.Second
+
+// dnsTypes are the types of --dns-type
+var dnsTypes = map[string]dnsmessage.Type{
+	"A":     dnsmessage.TypeA,
+	"AAAA":  dnsmessage.TypeAAAA,
+	"CNAME": dnsmessage.TypeCNAME,
+	"MX":    dnsmessage.TypeMX,
+	"NS":    dnsmessage.TypeNS,
+	"PTR":   dnsmessage.TypePTR,
+	"SOA":   dnsmessage.TypeSOA,
+	"SRV":   dnsmessage.TypeSRV,
+	"TXT":   dnsmessage.TypeTXT,
+	"ANY":   dnsmessage.TypeALL,
+}
+
+var dnsRcodeNames = map[dnsmessage.RCode]string{
+	dnsmessage.RCodeSuccess:        "NOERROR",
+	dnsmessage.RCodeFormatError:    "FORMERR",
+	dnsmessage.RCodeServerFailure:  "SERVFAIL",
+	dnsmessage.RCodeNameError:      "NXDOMAIN",
+	dnsmessage.RCodeNotImplemented: "NOTIMP",
+	dnsmessage.RCodeRefused:        "REFUSED",
+}
+
+// dnsRcodeName is the mnemonic of rcode, e.g. NXDOMAIN
+func dnsRcodeName(rcode dnsmessage.RCode) string {
+	if name, ok := dnsRcodeNames[rcode]; ok {
+		return name
+	}
+	return "RCODE " + strconv.Itoa(int(rcode))
+}
+
+// dnsAnswered tells the response codes of a resolver that did its job, a
+// name that does not exist being an answer too
+func dnsAnswered(rcode string) bool {
+	return rcode == "NOERROR" || rcode == "NXDOMAIN"
+}
+
+// DNSReport counts the --dns-query queries by the response code of their
+// answer
+type DNSReport struct {
+	Type   string
+	Rcodes map[string]int64
+}
+
+// dnsTransport is the transport of the queries to rawURL: udp, tcp, tls for
+// DNS over TLS or https for DNS over HTTPS, "" for the other urls
+func dnsTransport(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	switch u.Scheme {
+	case "udp", "tcp", "tls", "https":
+		return u.Scheme
+	}
+	return ""
+}
+
+// dnsMode sends the queries of --dns-query, of --dns-type, each request
+// taking the next name
+type dnsMode struct {
+	names    *templateCycle
+	qtype    dnsmessage.Type
+	typeName string
+}
+
+func newDNSMode(names []string, typeName string) (*dnsMode, error) {
+	qtype, ok := dnsTypes[strings.ToUpper(typeName)]
+	if !ok {
+		types := make([]string, 0, len(dnsTypes))
+		for t := range dnsTypes {
+			types = append(types, t)
+		}
+		sort.Strings(types)
+		return nil, fmt.Errorf("--dns-type %q is not one of %s", typeName, strings.Join(types, ", "))
+	}
+	cycle, err := newTemplateCycle("--dns-query", names)
+	if err != nil {
+		return nil, err
+	}
+	return &dnsMode{names: cycle, qtype: qtype, typeName: strings.ToUpper(typeName)}, nil
+}
+
+// dnsConns are the connections of a worker to the resolvers of the udp://,
+// tcp:// and tls:// urls, by client, the https:// ones going through its
+// HTTP clients
+type dnsConns struct {
+	r     *Requester
+	mode  *dnsMode
+	conns map[int]*tcpConn // the reader being nil for UDP
+	id    uint16
+	msg   []byte // of the query, after its 2-byte length for tcp and tls
+	buf   []byte
+	tmpl  bytes.Buffer
+}
+
+func newDNSConns(r *Requester) *dnsConns {
+	return &dnsConns{r: r, mode: r.clientOpt.dnsQuery, conns: make(map[int]*tcpConn), buf: make([]byte, 0, 1<<16)}
+}
+
+func (m *dnsMode) setup(r *Requester) error { return nil }
+
+func (m *dnsMode) conns(r *Requester, worker int) protocolConns { return newDNSConns(r) }
+
+func (m *dnsMode) teardown() {}
+
+func (c *dnsConns) do(x *exchange, rr *ReportRecord) {
+	c.roundTrip(x.ci, x.t, x.client, x.pt, x.req, x.resp, rr)
+}
+
+// close closes the connections of the worker at its end
+func (c *dnsConns) close() {
+	for ci, conn := range c.conns {
+		conn.Close()
+		delete(c.conns, ci)
+	}
+}
+
+// build packs the next query, ahead of the request and out of its latency
+func (c *dnsConns) build() error {
+	name, err := c.mode.names.take(&c.tmpl)
+	if err != nil {
+		return err
+	}
+	if !strings.HasSuffix(name, ".") {
+		name += "."
+	}
+	n, err := dnsmessage.NewName(name)
+	if err != nil {
+		return fmt.Errorf("dns: invalid query name %q", name)
+	}
+	c.id = uint16(mrand.Intn(1 << 16))
+	// room for the length of the message over a stream
+	b := dnsmessage.NewBuilder(append(c.msg[:0], 0, 0), dnsmessage.Header{ID: c.id, RecursionDesired: true})
+	b.EnableCompression()
+	if err = b.StartQuestions(); err == nil {
+		err = b.Question(dnsmessage.Question{Name: n, Type: c.mode.qtype, Class: dnsmessage.ClassINET})
+	}
+	if err == nil {
+		c.msg, err = b.Finish()
+	}
+	if err != nil {
+		return err
+	}
+	binary.BigEndian.PutUint16(c.msg, uint16(len(c.msg)-2))
+	return nil
+}
+
+// roundTrip sends the query built on the connection ci, or as the body of
+// req to an https:// url, and reads its answer, counted by its response code
+func (c *dnsConns) roundTrip(ci int, t *target, client *fasthttp.HostClient, pt *phaseTracker, req *fasthttp.Request, resp *fasthttp.Response, rr *ReportRecord) {
+	transport := dnsTransport(t.url)
+	if transport == "https" {
+		req.Header.SetMethod(fasthttp.MethodPost)
+		req.Header.SetContentType("application/dns-message")
+		req.Header.Set("Accept", "application/dns-message")
+		req.SetBody(c.msg[2:])
+		c.r.DoRequest(client, pt, req, resp, rr)
+		if rr.error == "" && rr.code == fasthttp.StatusOK {
+			c.answer(resp.Body(), rr)
+		}
+		return
+	}
+
+	opt := c.r.clientOpt
+	rr.reset()
+
+	start := time.Now()
+	var msg []byte
+	var err error
+	// a connection the server closed while idle is dialed again once
+	for attempt := 0; attempt < 2; attempt++ {
+		pt.start()
+		start = time.Now()
+		conn := c.conns[ci]
+		rr.cold = conn == nil
+		if conn == nil {
+			var nc net.Conn
+			if transport == "udp" {
+				nc, err = net.DialTimeout("udp", client.Addr, opt.dialTimeout)
+			} else {
+				nc, err = client.Dial(client.Addr)
+			}
+			if err != nil {
+				break
+			}
+			conn = &tcpConn{Conn: nc}
+			if transport != "udp" {
+				conn.br = bufio.NewReader(nc)
+			}
+			c.conns[ci] = conn
+		}
+		if transport == "udp" {
+			msg, err = c.exchangeUDP(conn)
+		} else {
+			msg, err = c.exchangeStream(conn)
+		}
+		if err == nil || rr.cold || transport == "udp" || len(msg) > 0 {
+			break
+		}
+		conn.Close()
+		delete(c.conns, ci)
+		rr.stale = staleRetried
+	}
+	done := time.Now()
+	rr.cost = done.Sub(start)
+	rr.addr = pt.addr
+	rr.phases = pt.times(rr.cost, done)
+	rr.reqSize, rr.respSize = int64(len(c.msg)-2), int64(len(msg))
+	rr.error = ""
+	if err != nil {
+		rr.error = err.Error()
+		rr.errClass = classifyError(err)
+	} else {
+		c.answer(msg, rr)
+	}
+	// a stream is out of step after an error, unlike a datagram socket
+	if conn := c.conns[ci]; conn != nil && (err != nil && transport != "udp" || opt.disableKeepalive) {
+		conn.Close()
+		delete(c.conns, ci)
+	}
+}
+
+// exchangeUDP sends the query as a datagram and reads the answer to it, the
+// late answers to the queries that timed out before being skipped
+func (c *dnsConns) exchangeUDP(conn *tcpConn) ([]byte, error) {
+	opt := c.r.clientOpt
+	timeout := opt.doTimeout
+	if timeout <= 0 {
+		timeout = opt.readTimeout
+	}
+	if timeout <= 0 {
+		timeout = dnsUDPTimeout
+	}
+	_ = conn.SetDeadline(time.Now().Add(timeout))
+	n, err := conn.Write(c.msg[2:])
+	atomic.AddInt64(&c.r.writeBytes, int64(n))
+	if err != nil {
+		return nil, err
+	}
+	buf := c.buf[:cap(c.buf)]
+	for {
+		n, err := conn.Read(buf)
+		atomic.AddInt64(&c.r.readBytes, int64(n))
+		if err != nil {
+			return nil, err
+		}
+		if n >= 2 && binary.BigEndian.Uint16(buf) == c.id {
+			return buf[:n], nil
+		}
+	}
+}
+
+// exchangeStream sends the query over tcp or tls, each message being
+// preceded by its length, and reads the answer
+func (c *dnsConns) exchangeStream(conn *tcpConn) ([]byte, error) {
+	opt := c.r.clientOpt
+	now := time.Now()
+	var deadline time.Time
+	if opt.doTimeout > 0 {
+		deadline = now.Add(opt.doTimeout)
+	}
+	if opt.writeTimeout > 0 {
+		_ = conn.SetWriteDeadline(now.Add(opt.writeTimeout))
+	} else {
+		_ = conn.SetWriteDeadline(deadline)
+	}
+	if _, err := conn.Write(c.msg); err != nil {
+		return nil, err
+	}
+	if opt.readTimeout > 0 {
+		_ = conn.SetReadDeadline(time.Now().Add(opt.readTimeout))
+	} else {
+		_ = conn.SetReadDeadline(deadline)
+	}
+	var size [2]byte
+	if _, err := io.ReadFull(conn.br, size[:]); err != nil {
+		return nil, err
+	}
+	buf := c.buf[:binary.BigEndian.Uint16(size[:])]
+	if n, err := io.ReadFull(conn.br, buf); err != nil {
+		return buf[:n], unexpectedEOF(err, n+2)
+	}
+	return buf, nil
+}
+
+// answer records the response code of the answer msg, the ones other than
+// NOERROR and NXDOMAIN being errors
+func (c *dnsConns) answer(msg []byte, rr *ReportRecord) {
+	var p dnsmessage.Parser
+	h, err := p.Start(msg)
+	switch {
+	case err != nil:
+		err = fmt.Errorf("dns: malformed answer: %w", err)
+	case !h.Response || h.ID != c.id:
+		err = errors.New("dns: answer to another query")
+	}
+	if err != nil {
+		rr.error = err.Error()
+		rr.errClass = errOther
+		return
+	}
+	rr.rcode = dnsRcodeName(h.RCode)
+	if !dnsAnswered(rr.rcode) {
+		rr.error = "dns: " + rr.rcode
+		rr.errClass = errOther
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"html/template"
+	"io"
+	"strings"
+
+	"gopkg.in/alecthomas/kingpin.v3-unstable"
+)
+
+// docsTemplates render the reference of the GUI from the flags of the
+// binary, so that its help can't drift from the command line.
+var docsTemplates = template.Must(template.New("docs").Funcs(template.FuncMap{
+	"usage":    flagUsage,
+	"argUsage": argUsage,
+	"join":     strings.Join,
+}).Parse(`{{define "head"}}<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="UTF-8">
+<meta name="viewport" content="width=device-width, initial-scale=1.0">
+<title>{{.}}</title>
+<style>
+body{font-family:'Inter',sans-serif;background:#0d0f17;color:#e2e8f0;line-height:1.6;margin:0}
+.wrap{max-width:960px;margin:0 auto;padding:28px 36px}
+a{color:#9b8fff;text-decoration:none}
+a:hover{text-decoration:underline}
+h1{font-size:22px;margin-bottom:4px}
+.sub{color:#64748b;font-size:13px;margin-bottom:24px}
+code{font-family:'JetBrains Mono',monospace;font-size:13px;color:#2dd4a0}
+.flag{border-bottom:1px solid #2e3250;padding:12px 0}
+.flag .help{color:#94a3b8;font-size:14px}
+dl{display:grid;grid-template-columns:140px 1fr;gap:6px 16px;font-size:14px}
+dt{color:#64748b}
+</style>
+</head>
+<body><div class="wrap">{{end}}
+
+{{define "index"}}{{template "head" "Plow — Reference"}}
+<h1>Plow {{.Version}} reference</h1>
+<div class="sub">{{.Help}} · <a href="/">back to the GUI</a> · <a href="/flags">JSON</a></div>
+{{range .Args}}<div class="flag" id="{{.Name}}"><a href="/docs/{{.Name}}"><code>{{argUsage .}}</code></a><div class="help">{{.Help}}</div></div>
+{{end}}{{range .Flags}}<div class="flag" id="{{.Name}}"><a href="/docs/{{.Name}}"><code>{{usage .}}</code></a><div class="help">{{.Help}}</div></div>
+{{end}}</div></body></html>
+{{end}}
+
+{{define "flag"}}{{template "head" (printf "Plow — %s" .Name)}}
+<h1><code>{{.Usage}}</code></h1>
+<div class="sub"><a href="/docs">all options</a> · <a href="/">back to the GUI</a></div>
+<p>{{.Help}}</p>
+<dl>
+<dt>Type</dt><dd>{{.Type}}</dd>
+{{if .Default}}<dt>Default</dt><dd><code>{{join .Default ", "}}</code></dd>{{end}}
+{{if .Short}}<dt>Short</dt><dd><code>-{{.Short}}</code></dd>{{end}}
+{{if .Repeatable}}<dt>Repeatable</dt><dd>yes, may be given several times</dd>{{end}}
+{{if .Negatable}}<dt>Negatable</dt><dd><code>--no-{{.Name}}</code></dd>{{end}}
+{{if .Envar}}<dt>Environment</dt><dd><code>{{.Envar}}</code></dd>{{end}}
+</dl>
+</div></body></html>
+{{end}}`))
+
+// flagUsage is how fh is written on the command line, e.g. --duration=DURATION
+func flagUsage(fh *FlagHelp) string {
+	if fh.Type == "bool" {
+		return "--" + fh.Name
+	}
+	ph := fh.PlaceHolder
+	if ph == "" {
+		ph = strings.ToUpper(strings.ReplaceAll(fh.Name, "-", "_"))
+	}
+	return "--" + fh.Name + "=" + ph
+}
+
+// argUsage is how the positional argument fh is written, e.g. <url>...
+func argUsage(fh *FlagHelp) string {
+	s := "<" + fh.Name + ">"
+	if fh.Repeatable {
+		s += "..."
+	}
+	return s
+}
+
+type flagDoc struct {
+	*FlagHelp
+	Usage string
+}
+
+func writeDocsHTML(w io.Writer, app *kingpin.Application) error {
+	return docsTemplates.ExecuteTemplate(w, "index", cliHelp(app))
+}
+
+// writeFlagDocHTML writes the details page of the flag or argument of app
+// named name, false when there is none
+func writeFlagDocHTML(w io.Writer, app *kingpin.Application, name string) (bool, error) {
+	h := cliHelp(app)
+	for _, fh := range h.Args {
+		if fh.Name == name {
+			return true, docsTemplates.ExecuteTemplate(w, "flag", &flagDoc{fh, argUsage(fh)})
+		}
+	}
+	for _, fh := range h.Flags {
+		if fh.Name == name {
+			return true, docsTemplates.ExecuteTemplate(w, "flag", &flagDoc{fh, flagUsage(fh)})
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// classes of the failed requests, the error messages being too many to chart
+const (
+	errDNS = iota
+	errConnRefused
+	errConnTimeout
+	errTLS
+	errTLSTimeout
+	errWriteTimeout
+	errReadTimeout
+	errRequestTimeout
+	errReset
+	errEOF
+	errOther
+	numErrorClasses
+)
+
+var errorClassNames = [numErrorClasses]string{"DNS", "Connect Refused", "Connect Timeout", "TLS", "TLS Timeout",
+	"Write Timeout", "Read Timeout", "Request Timeout", "Reset", "EOF", "Other"}
+
+// tlsTimeoutError is a TLS handshake that went past --tls-timeout
+type tlsTimeoutError struct {
+	timeout time.Duration
+}
+
+func (e *tlsTimeoutError) Error() string {
+	return fmt.Sprintf("tls handshake timeout after %s", e.timeout)
+}
+
+func (e *tlsTimeoutError) Timeout() bool { return true }
+
+func (e *tlsTimeoutError) Temporary() bool { return true }
+
+// classifyError tells the class of the error of a request, by its type when
+// the client kept it and by its message otherwise
+func classifyError(err error) int {
+	var dnsErr *net.DNSError
+	var tlsTimeout *tlsTimeoutError
+	var opErr *net.OpError
+	var recordErr tls.RecordHeaderError
+	var alertErr tls.AlertError
+	var certErr *tls.CertificateVerificationError
+	var authorityErr x509.UnknownAuthorityError
+	var hostErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	dial := errors.As(err, &opErr) && opErr.Op == "dial"
+	msg := err.Error()
+	switch {
+	case errors.As(err, &tlsTimeout):
+		return errTLSTimeout
+	case errors.As(err, &dnsErr) || strings.Contains(msg, "no such host"):
+		return errDNS
+	case errors.As(err, &recordErr) || errors.As(err, &alertErr) || errors.As(err, &certErr) ||
+		errors.As(err, &authorityErr) || errors.As(err, &hostErr) || errors.As(err, &invalidErr) ||
+		strings.HasPrefix(msg, "tls: ") || strings.Contains(msg, "x509: "):
+		return errTLS
+	case errors.Is(err, syscall.ECONNREFUSED) || strings.Contains(msg, "connection refused"):
+		return errConnRefused
+	case errors.Is(err, fasthttp.ErrDialTimeout) || dial && isTimeout(err):
+		return errConnTimeout
+	case errors.Is(err, context.DeadlineExceeded):
+		return errRequestTimeout
+	case errors.Is(err, fasthttp.ErrTimeout) || errors.Is(err, os.ErrDeadlineExceeded) || isTimeout(err):
+		return errReadTimeout
+	case errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) ||
+		strings.Contains(msg, "connection reset") || strings.Contains(msg, "broken pipe"):
+		return errReset
+	case errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, fasthttp.ErrConnectionClosed) ||
+		strings.HasSuffix(msg, "EOF"):
+		return errEOF
+	}
+	return errOther
+}
+
+// timeoutClass tells the timeout of a request that failed with one, as
+// fasthttp reports them all alike: the whole request after its --timeout,
+// else writing the request or reading its response
+func timeoutClass(pt *phaseTracker, cost, timeout time.Duration) int {
+	switch {
+	case timeout > 0 && cost >= timeout:
+		return errRequestTimeout
+	case pt != nil && !pt.reading:
+		return errWriteTimeout
+	}
+	return errReadTimeout
+}
+
+func isTimeout(err error) bool {
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
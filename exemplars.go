@@ -0,0 +1,61 @@
+package main
+
+import (
+	"sort"
+	"time"
+)
+
+// latencyBucketBounds are the upper bounds of the buckets of the exported
+// latency histogram, the default ones of the Prometheus clients
+var latencyBucketBounds = []time.Duration{
+	5 * time.Millisecond, 10 * time.Millisecond, 25 * time.Millisecond, 50 * time.Millisecond,
+	100 * time.Millisecond, 250 * time.Millisecond, 500 * time.Millisecond,
+	time.Second, 2500 * time.Millisecond, 5 * time.Second, 10 * time.Second,
+}
+
+// LatencyBucket is the requests of the run up to Le, counted like the
+// buckets of a Prometheus histogram, Le being 0 for +Inf
+type LatencyBucket struct {
+	Le       time.Duration
+	Count    int64 // cumulative
+	Exemplar *Exemplar
+}
+
+// Exemplar is the last request of a bucket with a --trace-header id, for
+// Grafana to jump from a slow bucket to its trace
+type Exemplar struct {
+	TraceID string
+	Latency time.Duration
+	Time    time.Time
+}
+
+// latencyBuckets counts the requests of each bucket of latencyBucketBounds
+// and the last one of +Inf, with their exemplars
+type latencyBuckets struct {
+	counts    [12]int64
+	exemplars [12]Exemplar
+}
+
+func (b *latencyBuckets) add(r *ReportRecord) {
+	i := sort.Search(len(latencyBucketBounds), func(i int) bool { return r.cost <= latencyBucketBounds[i] })
+	b.counts[i]++
+	if r.traceID != "" {
+		b.exemplars[i] = Exemplar{TraceID: r.traceID, Latency: r.cost, Time: time.Now()}
+	}
+}
+
+func (b *latencyBuckets) report() []LatencyBucket {
+	buckets := make([]LatencyBucket, len(b.counts))
+	var count int64
+	for i := range b.counts {
+		count += b.counts[i]
+		buckets[i].Count = count
+		if i < len(latencyBucketBounds) {
+			buckets[i].Le = latencyBucketBounds[i]
+		}
+		if e := b.exemplars[i]; e.TraceID != "" {
+			buckets[i].Exemplar = &e
+		}
+	}
+	return buckets
+}
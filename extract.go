@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// extractor reads a numeric field of JSON responses, e.g. `queue=$.queue_depth`
+// or `$.shards[0].lag`, the path being the name when none is given.
+type extractor struct {
+	name  string
+	path  string
+	steps []extractStep
+}
+
+// extractStep is a key or, with index >= 0, an array element
+type extractStep struct {
+	key   string
+	index int
+}
+
+func parseExtractor(s string) (*extractor, error) {
+	e := &extractor{name: s, path: s}
+	if i := strings.Index(s, "="); i > 0 && !strings.HasPrefix(s, "$") {
+		e.name, e.path = strings.TrimSpace(s[:i]), strings.TrimSpace(s[i+1:])
+	}
+	if !strings.HasPrefix(e.path, "$") {
+		return nil, fmt.Errorf("invalid extract %q: path must start with $", s)
+	}
+	p := e.path[1:]
+	for p != "" {
+		switch {
+		case p[0] == '.':
+			p = p[1:]
+			n := strings.IndexAny(p, ".[")
+			if n < 0 {
+				n = len(p)
+			}
+			if n == 0 {
+				return nil, fmt.Errorf("invalid extract %q: empty key", s)
+			}
+			e.steps = append(e.steps, extractStep{key: p[:n], index: -1})
+			p = p[n:]
+		case p[0] == '[':
+			end := strings.IndexByte(p, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid extract %q: missing ]", s)
+			}
+			in := p[1:end]
+			if len(in) >= 2 && (in[0] == '\'' || in[0] == '"') && in[len(in)-1] == in[0] {
+				e.steps = append(e.steps, extractStep{key: in[1 : len(in)-1], index: -1})
+			} else if i, err := strconv.Atoi(in); err == nil && i >= 0 {
+				e.steps = append(e.steps, extractStep{index: i})
+			} else {
+				return nil, fmt.Errorf("invalid extract %q: bad index [%s]", s, in)
+			}
+			p = p[end+1:]
+		default:
+			return nil, fmt.Errorf("invalid extract %q: unexpected %q", s, p)
+		}
+	}
+	return e, nil
+}
+
+// value returns the number at the path of v, numeric strings included
+func (e *extractor) value(v interface{}) (float64, bool) {
+	for _, st := range e.steps {
+		if st.index >= 0 {
+			a, ok := v.([]interface{})
+			if !ok || st.index >= len(a) {
+				return 0, false
+			}
+			v = a[st.index]
+		} else {
+			m, ok := v.(map[string]interface{})
+			if !ok {
+				return 0, false
+			}
+			if v, ok = m[st.key]; !ok {
+				return 0, false
+			}
+		}
+	}
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case string:
+		f, err := strconv.ParseFloat(n, 64)
+		return f, err == nil
+	case bool:
+		if n {
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// extractValues parses body once for all the extractors, NaN marking the
+// fields that were not found. Only a share rate of the responses is sampled.
+func extractValues(dst []float64, extractors []*extractor, rate float64, body []byte) []float64 {
+	dst = dst[:0]
+	if len(extractors) == 0 || rate < 1 && rand.Float64() >= rate {
+		return dst
+	}
+	var v interface{}
+	if json.Unmarshal(body, &v) != nil {
+		return dst
+	}
+	for _, e := range extractors {
+		f, ok := e.value(v)
+		if !ok {
+			f = math.NaN()
+		}
+		dst = append(dst, f)
+	}
+	return dst
+}
+
+type extractStats struct {
+	Stats
+	last float64
+}
+
+// updateExtracted adds the values of a record to stats, grown as needed
+func updateExtracted(stats []extractStats, values []float64) []extractStats {
+	for i, v := range values {
+		if math.IsNaN(v) {
+			continue
+		}
+		for len(stats) <= i {
+			stats = append(stats, extractStats{})
+		}
+		stats[i].Update(v)
+		stats[i].last = v
+	}
+	return stats
+}
+
+// ExtractReport is a field extracted from the responses, Series holding its
+// mean of each second.
+type ExtractReport struct {
+	Name   string
+	Path   string
+	Count  int64
+	Min    float64
+	Mean   float64
+	Max    float64
+	Last   float64
+	Series []*ExtractPoint
+}
+
+type ExtractPoint struct {
+	Elapsed time.Duration
+	Value   float64
+}
+
+func extractReports(extractors []*extractor, stats []extractStats, ticks []*TickReport) []*ExtractReport {
+	var res []*ExtractReport
+	for i, e := range extractors {
+		r := &ExtractReport{Name: e.name, Path: e.path}
+		if i < len(stats) {
+			st := stats[i]
+			r.Count, r.Min, r.Mean, r.Max, r.Last = st.count, st.min, st.Mean(), st.max, st.last
+		}
+		for _, t := range ticks {
+			if i < len(t.Extracted) && !math.IsNaN(t.Extracted[i]) && !t.Warmup {
+				r.Series = append(r.Series, &ExtractPoint{Elapsed: t.Elapsed, Value: t.Extracted[i]})
+			}
+		}
+		res = append(res, r)
+	}
+	return res
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// failureBodyLimit is the part of the bodies of a failure sample kept
+const failureBodyLimit = 64 << 10
+
+// defaultFailureSamples is the number of failures the web UI keeps per run
+const defaultFailureSamples = 20
+
+// FailureSample is a failed request with its response, to see what the
+// server answered without running again behind a sniffer
+type FailureSample struct {
+	Time     time.Time       `json:"time"`
+	Reason   string          `json:"reason"` // the error, status, malformed body or GraphQL error
+	Latency  string          `json:"latency"`
+	TraceID  string          `json:"traceId,omitempty"` // of --trace-header, to find the request in the logs of the server
+	Request  SampledMessage  `json:"request"`
+	Response *SampledMessage `json:"response,omitempty"` // nil without one, e.g. on a timeout
+}
+
+// SampledMessage is a request or a response as sent, its start line and
+// headers, and its body up to failureBodyLimit
+type SampledMessage struct {
+	Header    string `json:"header"`
+	Body      string `json:"body,omitempty"`
+	Truncated bool   `json:"truncated,omitempty"`
+}
+
+// failureSamples keeps the first max failures of a run
+type failureSamples struct {
+	max   int64
+	taken int64
+
+	mu      sync.Mutex
+	samples []*FailureSample
+}
+
+func newFailureSamples(max int) *failureSamples {
+	return &failureSamples{max: int64(max)}
+}
+
+// failureReason is why rr failed, "" when it did not
+func failureReason(rr *ReportRecord, redirectFailure bool) string {
+	switch {
+	case rr.error != "":
+		return rr.error
+	case rr.code >= 500 || redirectFailure && rr.code/100 == 3:
+		return fmt.Sprintf("status %d", rr.code)
+	case rr.malformed != "":
+		return "malformed body: " + rr.malformed
+	case rr.graphqlError != "":
+		return "GraphQL error: " + rr.graphqlError
+	}
+	return ""
+}
+
+// add keeps req and resp when rr failed, until max failures were kept
+func (f *failureSamples) add(req *fasthttp.Request, resp *fasthttp.Response, rr *ReportRecord, opt *ClientOpt) {
+	reason := failureReason(rr, opt.redirectFailure)
+	if reason == "" || atomic.LoadInt64(&f.taken) >= f.max || atomic.AddInt64(&f.taken, 1) > f.max {
+		return
+	}
+	s := &FailureSample{Time: time.Now(), Reason: reason, Latency: rr.cost.String()}
+	if opt.traceHeader != nil {
+		s.TraceID = string(req.Header.Peek(opt.traceHeader.name))
+	}
+	s.Request.Header = sampledHeader(req.Header.String())
+	if !req.IsBodyStream() {
+		// a streamed body is gone once sent
+		s.Request.Body, s.Request.Truncated = sampledBody(req.Body())
+	}
+	if rr.code != 0 {
+		body := resp.Body()
+		if opt.compressed {
+			if decoded, err := decodedBody(resp); err == nil {
+				body = decoded
+			}
+		}
+		s.Response = &SampledMessage{Header: sampledHeader(resp.Header.String())}
+		s.Response.Body, s.Response.Truncated = sampledBody(body)
+	}
+	f.mu.Lock()
+	f.samples = append(f.samples, s)
+	f.mu.Unlock()
+}
+
+func (f *failureSamples) list() []*FailureSample {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]*FailureSample{}, f.samples...)
+}
+
+// redactedHeaders are the headers of the samples whose values are
+// credentials, kept out of the web UI and of the --failures file
+var redactedHeaders = map[string]bool{
+	"authorization":       true,
+	"proxy-authorization": true,
+	"cookie":              true,
+	"set-cookie":          true,
+	"x-api-key":           true,
+}
+
+// sampledHeader is the header h with a line per field, the values of the
+// credentials being replaced with [redacted]
+func sampledHeader(h string) string {
+	lines := strings.Split(strings.TrimRight(h, "\r\n"), "\r\n")
+	// the first line is the request or status line
+	for i := 1; i < len(lines); i++ {
+		name, _, ok := strings.Cut(lines[i], ":")
+		if ok && redactedHeaders[strings.ToLower(strings.TrimSpace(name))] {
+			lines[i] = name + ": [redacted]"
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func sampledBody(body []byte) (string, bool) {
+	if len(body) > failureBodyLimit {
+		return string(body[:failureBodyLimit]), true
+	}
+	return string(body), false
+}
+
+// writeFailuresFile writes the samples of f as a JSON array to path
+func writeFailuresFile(path string, f *failureSamples) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(f.list()); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0o600)
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+
+	"gopkg.in/alecthomas/kingpin.v3-unstable"
+)
+
+// grafanaSelector picks the series of the runs of the $job and $run_id
+// variables of the dashboard, the run_id of the --remote-write pushes being
+// missing from the --openmetrics files
+const grafanaSelector = `job=~"$job",run_id=~"$run_id"`
+
+var grafanaDatasource = map[string]interface{}{"type": "prometheus", "uid": "${datasource}"}
+
+func runGrafanaDashboardCommand(args []string) {
+	app := kingpin.New("plow grafana-dashboard", "Print a Grafana dashboard JSON of the metrics of --openmetrics and --remote-write, to import in Grafana or provision")
+	title := app.Flag("title", "Title of the dashboard").Default("plow").String()
+	uid := app.Flag("uid", "Uid of the dashboard, its url on Grafana").Default("plow").String()
+	output := app.Flag("output", "File to write the dashboard to, instead of stdout").Short('o').PlaceHolder("FILE").String()
+	app.Version(version)
+	kingpin.MustParse(app.Parse(args))
+
+	data, err := json.MarshalIndent(grafanaDashboard(*title, *uid), "", "  ")
+	if err != nil {
+		errAndExit(err.Error())
+		return
+	}
+	data = append(data, '\n')
+	if *output == "" {
+		os.Stdout.Write(data)
+		return
+	}
+	if err := os.WriteFile(*output, data, 0o644); err != nil {
+		errAndExit(err.Error())
+	}
+}
+
+// grafanaTarget is a query of a panel, legend being its legendFormat
+type grafanaTarget struct {
+	expr, legend string
+	instant      bool
+}
+
+// grafanaPanel is a panel of the dashboard at x, y of the grid of 24 columns
+type grafanaPanel struct {
+	title, typ, unit string
+	x, y, w, h       int
+	targets          []grafanaTarget
+	// exemplars shows the exemplars of the queries, the traces of the
+	// --trace-header ids
+	exemplars bool
+	// options of the panel type, merged into the defaults
+	options map[string]interface{}
+}
+
+func (p *grafanaPanel) json(id int) map[string]interface{} {
+	targets := make([]map[string]interface{}, len(p.targets))
+	for i, t := range p.targets {
+		targets[i] = map[string]interface{}{
+			"datasource":   grafanaDatasource,
+			"refId":        string(rune('A' + i)),
+			"expr":         t.expr,
+			"legendFormat": t.legend,
+			"instant":      t.instant,
+			"range":        !t.instant,
+			"exemplar":     p.exemplars,
+		}
+	}
+	defaults := map[string]interface{}{}
+	if p.unit != "" {
+		defaults["unit"] = p.unit
+	}
+	panel := map[string]interface{}{
+		"id":          id,
+		"type":        p.typ,
+		"title":       p.title,
+		"datasource":  grafanaDatasource,
+		"gridPos":     map[string]int{"x": p.x, "y": p.y, "w": p.w, "h": p.h},
+		"targets":     targets,
+		"fieldConfig": map[string]interface{}{"defaults": defaults, "overrides": []interface{}{}},
+	}
+	if p.options != nil {
+		panel["options"] = p.options
+	}
+	return panel
+}
+
+// grafanaQueryVariable is a variable of the values of label of the series
+// of query, all of them by default
+func grafanaQueryVariable(name, label, query string) map[string]interface{} {
+	q := "label_values(" + query + ", " + label + ")"
+	return map[string]interface{}{
+		"name":       name,
+		"label":      label,
+		"type":       "query",
+		"datasource": grafanaDatasource,
+		"query":      map[string]string{"query": q, "refId": "PrometheusVariableQueryEditor-VariableQuery"},
+		"definition": q,
+		"refresh":    2,
+		"includeAll": true,
+		"allValue":   ".*",
+		"multi":      true,
+		"sort":       1,
+		"current":    map[string]interface{}{"text": []string{"All"}, "value": []string{"$__all"}},
+	}
+}
+
+// grafanaDashboard is the dashboard of the plow_ metrics of openmetrics.go
+func grafanaDashboard(title, uid string) map[string]interface{} {
+	sel := grafanaSelector
+	stat := map[string]interface{}{"reduceOptions": map[string]interface{}{"calcs": []string{"lastNotNull"}}, "colorMode": "value", "graphMode": "area"}
+	panels := []*grafanaPanel{
+		{title: "Requests", typ: "stat", unit: "short", x: 0, y: 0, w: 4, h: 4, options: stat,
+			targets: []grafanaTarget{{expr: "sum(plow_requests{" + sel + "})"}}},
+		{title: "Requests per second", typ: "stat", unit: "reqps", x: 4, y: 0, w: 4, h: 4, options: stat,
+			targets: []grafanaTarget{{expr: "sum(plow_requests_per_second{" + sel + "})"}}},
+		{title: "Error rate", typ: "stat", unit: "percentunit", x: 8, y: 0, w: 4, h: 4, options: stat,
+			targets: []grafanaTarget{{expr: "sum(plow_errors{" + sel + "}) / sum(plow_requests{" + sel + "})"}}},
+		{title: "P99", typ: "stat", unit: "s", x: 12, y: 0, w: 4, h: 4, options: stat,
+			targets: []grafanaTarget{{expr: `max(plow_latency_seconds{quantile="0.99",` + sel + "})"}}},
+		{title: "Thresholds passed", typ: "stat", unit: "percentunit", x: 16, y: 0, w: 4, h: 4, options: stat,
+			targets: []grafanaTarget{{expr: "avg(plow_threshold_passed{" + sel + "})"}}},
+		{title: "Aborted by the guardrail", typ: "stat", unit: "bool_yes_no", x: 20, y: 0, w: 4, h: 4, options: stat,
+			targets: []grafanaTarget{{expr: "max(plow_run_aborted{" + sel + "})"}}},
+
+		{title: "Requests per second", typ: "timeseries", unit: "reqps", x: 0, y: 4, w: 12, h: 8,
+			targets: []grafanaTarget{{expr: "plow_requests_per_second{" + sel + "}", legend: "{{run_id}}"}}},
+		{title: "Latency quantiles", typ: "timeseries", unit: "s", x: 12, y: 4, w: 12, h: 8,
+			targets: []grafanaTarget{{expr: `plow_latency_seconds{quantile=~"0.5|0.9|0.99|0.999",` + sel + "}", legend: "p{{quantile}} {{run_id}}"}}},
+
+		{title: "Latency histogram, with the traces of --trace-header", typ: "timeseries", unit: "s", x: 0, y: 12, w: 12, h: 8, exemplars: true,
+			targets: []grafanaTarget{
+				{expr: "histogram_quantile(0.5, sum by (le, run_id) (plow_request_duration_seconds_bucket{" + sel + "}))", legend: "p50 {{run_id}}"},
+				{expr: "histogram_quantile(0.99, sum by (le, run_id) (plow_request_duration_seconds_bucket{" + sel + "}))", legend: "p99 {{run_id}}"},
+			}},
+		{title: "Latency distribution", typ: "bargauge", unit: "short", x: 12, y: 12, w: 12, h: 8,
+			options: map[string]interface{}{"reduceOptions": map[string]interface{}{"calcs": []string{"lastNotNull"}}, "orientation": "vertical", "displayMode": "gradient"},
+			targets: []grafanaTarget{{expr: "sum by (le) (plow_request_duration_seconds_bucket{" + sel + "})", legend: "≤ {{le}}s", instant: true}}},
+
+		{title: "Responses by status", typ: "timeseries", unit: "short", x: 0, y: 20, w: 12, h: 8,
+			targets: []grafanaTarget{{expr: "sum by (code) (plow_responses{" + sel + "})", legend: "{{code}}"}}},
+		{title: "Errors by class", typ: "timeseries", unit: "short", x: 12, y: 20, w: 12, h: 8,
+			targets: []grafanaTarget{{expr: "sum by (class) (plow_errors{" + sel + "}) > 0", legend: "{{class}}"}}},
+
+		{title: "Throughput", typ: "timeseries", unit: "Bps", x: 0, y: 28, w: 12, h: 8,
+			targets: []grafanaTarget{
+				{expr: "sum(plow_read_bytes_per_second{" + sel + "})", legend: "read"},
+				{expr: "sum(plow_written_bytes_per_second{" + sel + "})", legend: "written"},
+			}},
+		{title: "P99 by url", typ: "timeseries", unit: "s", x: 12, y: 28, w: 12, h: 8,
+			targets: []grafanaTarget{{expr: `plow_target_latency_seconds{stat="p99",` + sel + "}", legend: "{{url}}"}}},
+
+		{title: "Thresholds", typ: "table", x: 0, y: 36, w: 24, h: 6,
+			targets: []grafanaTarget{{expr: "plow_threshold_passed{" + sel + "}", legend: "{{threshold}}", instant: true}}},
+	}
+	list := make([]map[string]interface{}, len(panels))
+	for i, p := range panels {
+		list[i] = p.json(i + 1)
+	}
+	return map[string]interface{}{
+		"uid":           uid,
+		"title":         title,
+		"description":   "Benchmarks of plow, from its --openmetrics files or --remote-write pushes",
+		"tags":          []string{"plow", "benchmark"},
+		"editable":      true,
+		"schemaVersion": 39,
+		"time":          map[string]string{"from": "now-6h", "to": "now"},
+		"refresh":       "10s",
+		"panels":        list,
+		"templating": map[string]interface{}{"list": []interface{}{
+			map[string]interface{}{"name": "datasource", "label": "Data source", "type": "datasource", "query": "prometheus"},
+			grafanaQueryVariable("job", "job", "plow_requests"),
+			grafanaQueryVariable("run_id", "run_id", `plow_requests{job=~"$job"}`),
+		}},
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// graphqlRequest is the standard POST body of a GraphQL operation
+type graphqlRequest struct {
+	Query         string          `json:"query"`
+	Variables     json.RawMessage `json:"variables,omitempty"`
+	OperationName string          `json:"operationName,omitempty"`
+}
+
+// graphqlResponse only decodes the errors of a GraphQL response
+type graphqlResponse struct {
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// buildGraphQLBody reads the query document and the optional variables file
+func buildGraphQLBody(queryFile, variablesFile, operationName string) ([]byte, error) {
+	query, err := os.ReadFile(queryFile)
+	if err != nil {
+		return nil, err
+	}
+	req := graphqlRequest{Query: string(query), OperationName: operationName}
+	if variablesFile != "" {
+		vars, err := os.ReadFile(variablesFile)
+		if err != nil {
+			return nil, err
+		}
+		var obj map[string]interface{}
+		if err = json.Unmarshal(vars, &obj); err != nil {
+			return nil, fmt.Errorf("graphql variables %s must be a JSON object: %s", variablesFile, err)
+		}
+		req.Variables = vars
+	}
+	return json.Marshal(req)
+}
+
+// graphqlError returns the first error message of a GraphQL response body, if any
+func graphqlError(body []byte) string {
+	var resp graphqlResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return "invalid GraphQL response: " + err.Error()
+	}
+	if len(resp.Errors) == 0 {
+		return ""
+	}
+	msg := strings.TrimSpace(resp.Errors[0].Message)
+	if msg == "" {
+		msg = "(no message)"
+	}
+	return msg
+}
@@ -0,0 +1,394 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	url2 "net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"golang.org/x/net/http2"
+)
+
+// gRPC call types of --grpc
+const (
+	grpcUnary        = "unary"
+	grpcClientStream = "client-stream"
+	grpcServerStream = "server-stream"
+	grpcBidi         = "bidi"
+)
+
+// grpcCodeNames are the names of the gRPC status codes, by code
+var grpcCodeNames = []string{"OK", "Canceled", "Unknown", "InvalidArgument", "DeadlineExceeded", "NotFound",
+	"AlreadyExists", "PermissionDenied", "ResourceExhausted", "FailedPrecondition", "Aborted", "OutOfRange",
+	"Unimplemented", "Internal", "Unavailable", "DataLoss", "Unauthenticated"}
+
+// GRPCReport counts the messages of the gRPC calls, Latency being the one of
+// each message rather than of the whole call
+type GRPCReport struct {
+	Type     string
+	Sent     int64
+	Received int64
+	Rate     float64 // messages sent and received per second
+	Latency  *ConnLatencyReport
+	// Codes counts the calls by grpc-status name, or by RST_STREAM code for
+	// the streams the server reset
+	Codes map[string]int64
+}
+
+// grpcConns are the HTTP/2 connections of a group of --grpc-streams
+// workers, their calls being concurrent streams of the same connection
+type grpcConns struct {
+	mu    sync.Mutex
+	tr    http2.Transport
+	conns map[int]*http2.ClientConn // by slot of the target
+}
+
+// grpcGroup returns the connections shared by worker and the other workers
+// of its group
+func (r *Requester) grpcGroup(worker int) *grpcConns {
+	group := worker / max(r.clientOpt.grpcStreams, 1)
+	r.grpcMu.Lock()
+	defer r.grpcMu.Unlock()
+	if r.grpcGroups == nil {
+		r.grpcGroups = make(map[int]*grpcConns)
+	}
+	g := r.grpcGroups[group]
+	if g == nil {
+		g = &grpcConns{conns: make(map[int]*http2.ClientConn)}
+		r.grpcGroups[group] = g
+	}
+	return g
+}
+
+// grpcCaller sends the gRPC calls of a worker, over the HTTP/2 connection
+// per host of its group, the body of the request being the encoded message
+// sent
+type grpcCaller struct {
+	r     *Requester
+	group *grpcConns
+	hdr   [5]byte
+}
+
+func newGRPCCaller(r *Requester, worker int) *grpcCaller {
+	return &grpcCaller{r: r, group: r.grpcGroup(worker)}
+}
+
+// conn returns the connection to the host of t, dialed like its HTTP/1
+// connections, e.g. through its proxy or to --connect-to, and whether it
+// was opened for this call
+func (c *grpcCaller) conn(t *target, req *fasthttp.Request) (*http2.ClientConn, bool, error) {
+	g := c.group
+	// the other workers of the group wait for the connection dialed
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if cc := g.conns[t.slot]; cc != nil {
+		if cc.CanTakeNewRequest() && !c.r.clientOpt.disableKeepalive {
+			return cc, false, nil
+		}
+		// closed once the calls of the other workers on it end
+		go cc.Shutdown(context.Background())
+		delete(g.conns, t.slot)
+	}
+	hc := t.httpClient
+	conn, err := hc.Dial(hc.Addr)
+	if err != nil {
+		return nil, true, err
+	}
+	if hc.IsTLS {
+		cfg := hc.TLSConfig.Clone()
+		cfg.NextProtos = []string{"h2"}
+		if cfg.ServerName == "" {
+			host := string(req.URI().Host())
+			if h, _, err := net.SplitHostPort(host); err == nil {
+				host = h
+			}
+			cfg.ServerName = host
+		}
+		tc := tls.Client(conn, cfg)
+		timeout := c.r.clientOpt.tlsTimeout
+		if timeout <= 0 {
+			timeout = c.r.clientOpt.writeTimeout
+		}
+		if timeout > 0 {
+			_ = tc.SetDeadline(time.Now().Add(timeout))
+		}
+		if err := tc.Handshake(); err != nil {
+			conn.Close()
+			if isTimeout(err) {
+				return nil, true, &tlsTimeoutError{timeout}
+			}
+			return nil, true, err
+		}
+		_ = tc.SetDeadline(time.Time{})
+		conn = tc
+	}
+	cc, err := g.tr.NewClientConn(conn)
+	if err != nil {
+		conn.Close()
+		return nil, true, err
+	}
+	g.conns[t.slot] = cc
+	return cc, true, nil
+}
+
+// call sends req as a gRPC call of the --grpc type, the latency of rr being
+// the one of the call and its messages being timed one by one: the call for
+// unary, the send of each message for client-stream, the wait for each
+// message for server-stream, and each round trip for bidi, out of the waits
+// of --grpc-message-rate
+func (c *grpcCaller) call(t *target, req *fasthttp.Request, rr *ReportRecord) {
+	opt := c.r.clientOpt
+	rr.reset()
+
+	start := time.Now()
+	fail := func(err error) {
+		rr.cost = time.Since(start)
+		rr.code = 0
+		rr.error = err.Error()
+		rr.errClass = classifyError(err)
+		var se http2.StreamError
+		if errors.As(err, &se) {
+			rr.grpcStatus = "RST_STREAM " + se.Code.String()
+		}
+	}
+	cc, dialed, err := c.conn(t, req)
+	rr.cold = dialed
+	if err != nil {
+		fail(err)
+		return
+	}
+	// the calls in flight at the end of the run complete, like the requests
+	ctx, cancel := context.WithCancel(context.Background())
+	if opt.doTimeout > 0 {
+		cancel()
+		ctx, cancel = context.WithTimeout(context.Background(), opt.doTimeout)
+	}
+	scheme := "http"
+	if t.httpClient.IsTLS {
+		scheme = "https"
+	}
+	pr, pw := io.Pipe()
+	hreq, err := http.NewRequestWithContext(ctx, http.MethodPost, scheme+"://"+string(req.URI().Host())+string(req.URI().RequestURI()), pr)
+	if err != nil {
+		cancel()
+		fail(err)
+		return
+	}
+	req.Header.VisitAll(func(k, v []byte) {
+		switch strings.ToLower(string(k)) {
+		case "host", "content-length", "content-type", "connection", "transfer-encoding", "user-agent":
+			return
+		}
+		hreq.Header.Add(string(k), string(v))
+	})
+	hreq.Host = string(req.Header.Host())
+	hreq.Header.Set("Content-Type", "application/grpc")
+	hreq.Header.Set("TE", "trailers")
+	hreq.Header.Set("User-Agent", "plow")
+
+	type result struct {
+		resp *http.Response
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		resp, err := cc.RoundTrip(hreq)
+		done <- result{resp, err}
+	}()
+	var resp *http.Response
+	var callErr error
+	received := false
+	// response waits for the headers of the response, the error of the call
+	// taking over the one of a write to the stream it broke
+	response := func(werr error) error {
+		if !received {
+			res := <-done
+			resp, callErr, received = res.resp, res.err, true
+		}
+		if callErr != nil {
+			return callErr
+		}
+		return werr
+	}
+	defer func() {
+		cancel()
+		pw.Close()
+		response(nil)
+		if resp != nil {
+			resp.Body.Close()
+		}
+	}()
+
+	msg := req.Body()
+	frame := make([]byte, 5+len(msg))
+	binary.BigEndian.PutUint32(frame[1:5], uint32(len(msg)))
+	copy(frame[5:], msg)
+	send := func() error {
+		if _, err := pw.Write(frame); err != nil {
+			return err
+		}
+		rr.msgSent++
+		rr.reqSize += int64(len(msg))
+		return nil
+	}
+
+	sends := 1
+	if opt.grpc == grpcClientStream || opt.grpc == grpcBidi {
+		sends = opt.grpcMessages
+	}
+	last := start
+sending:
+	for i := 0; i < sends; i++ {
+		if i > 0 && opt.grpcMessageRate > 0 {
+			if !waitUntil(ctx, start.Add(time.Duration(float64(i)/opt.grpcMessageRate*float64(time.Second)))) {
+				fail(response(ctx.Err()))
+				return
+			}
+			last = time.Now()
+		}
+		if err := send(); err != nil {
+			fail(response(err))
+			return
+		}
+		switch opt.grpc {
+		case grpcClientStream:
+			now := time.Now()
+			rr.msgLatencies = append(rr.msgLatencies, now.Sub(last))
+			last = now
+		case grpcBidi:
+			if err := response(nil); err != nil {
+				fail(err)
+				return
+			}
+			if resp.StatusCode != http.StatusOK {
+				break sending
+			}
+			ok, err := c.receive(resp.Body, rr)
+			if err != nil {
+				fail(err)
+				return
+			}
+			if !ok {
+				// the server ended the stream early
+				break sending
+			}
+			now := time.Now()
+			rr.msgLatencies = append(rr.msgLatencies, now.Sub(last))
+			last = now
+		}
+	}
+	pw.Close()
+	if opt.grpc == grpcServerStream {
+		last = time.Now()
+	}
+	if err := response(nil); err != nil {
+		fail(err)
+		return
+	}
+	for resp.StatusCode == http.StatusOK {
+		ok, err := c.receive(resp.Body, rr)
+		if err != nil {
+			fail(err)
+			return
+		}
+		if !ok {
+			break
+		}
+		now := time.Now()
+		switch opt.grpc {
+		case grpcUnary:
+			rr.msgLatencies = append(rr.msgLatencies, now.Sub(start))
+		case grpcServerStream:
+			rr.msgLatencies = append(rr.msgLatencies, now.Sub(last))
+		}
+		last = now
+	}
+	if resp.StatusCode != http.StatusOK {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		rr.cost = time.Since(start)
+		rr.code = resp.StatusCode
+		rr.error = ""
+		return
+	}
+	rr.cost = time.Since(start)
+	status, err := grpcStatus(resp)
+	rr.grpcStatus = status
+	if err != nil {
+		rr.code = 0
+		rr.error = err.Error()
+		rr.errClass = errOther
+		return
+	}
+	rr.code = resp.StatusCode
+	rr.error = ""
+}
+
+// receive reads the next message of body, false at the end of the stream
+func (c *grpcCaller) receive(body io.Reader, rr *ReportRecord) (bool, error) {
+	if _, err := io.ReadFull(body, c.hdr[:]); err != nil {
+		if err == io.EOF {
+			return false, nil
+		}
+		return false, err
+	}
+	n, err := io.CopyN(io.Discard, body, int64(binary.BigEndian.Uint32(c.hdr[1:])))
+	rr.respSize += n
+	if err == io.EOF {
+		return false, io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return false, err
+	}
+	rr.msgRecv++
+	return true, nil
+}
+
+// grpcStatus is the name of the grpc-status of the trailers of resp, or of
+// its headers for a response without messages, and its error, nil for OK
+func grpcStatus(resp *http.Response) (string, error) {
+	h := resp.Trailer
+	if h.Get("Grpc-Status") == "" {
+		h = resp.Header
+	}
+	s := h.Get("Grpc-Status")
+	if s == "" {
+		return "", fmt.Errorf("grpc: no grpc-status in the response")
+	}
+	name := "status " + s
+	if code, err := strconv.Atoi(s); err == nil && code >= 0 && code < len(grpcCodeNames) {
+		name = grpcCodeNames[code]
+	}
+	if s == "0" {
+		return name, nil
+	}
+	msg, err := url2.PathUnescape(h.Get("Grpc-Message"))
+	if err != nil {
+		msg = h.Get("Grpc-Message")
+	}
+	if msg == "" {
+		return name, fmt.Errorf("grpc: %s", name)
+	}
+	return name, fmt.Errorf("grpc: %s: %s", name, msg)
+}
+
+// waitUntil waits for the time at, false when ctx ended first
+func waitUntil(ctx context.Context, at time.Time) bool {
+	timer := time.NewTimer(time.Until(at))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
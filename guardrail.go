@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// guardrail is an external url polled during the run, which stops it by
+// answering "abort", e.g. while a production alert is firing.
+type guardrail struct {
+	url      string
+	interval time.Duration
+	client   *http.Client
+}
+
+func newGuardrail(url string, interval time.Duration) (*guardrail, error) {
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return nil, fmt.Errorf("invalid --guardrail %q: must be an http(s) url", url)
+	}
+	if interval <= 0 {
+		return nil, fmt.Errorf("--guardrail-interval must be positive")
+	}
+	timeout := interval
+	if timeout > 10*time.Second {
+		timeout = 10 * time.Second
+	}
+	return &guardrail{url: url, interval: interval, client: &http.Client{Timeout: timeout}}, nil
+}
+
+// check polls the guardrail, returning the reason to abort or "" to go on
+func (g *guardrail) check() (string, error) {
+	resp, err := g.client.Get(g.url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode/100 != 2 {
+		return "", fmt.Errorf("guardrail status code %d", resp.StatusCode)
+	}
+	return parseGuardrail(body), nil
+}
+
+// parseGuardrail reads the answer of a guardrail, "abort" followed by an
+// optional reason, as text or as {"action": "abort", "reason": "..."}.
+func parseGuardrail(body []byte) string {
+	text := strings.TrimSpace(string(body))
+	if strings.HasPrefix(text, "{") {
+		var v struct {
+			Action string `json:"action"`
+			Reason string `json:"reason"`
+		}
+		if json.Unmarshal(body, &v) != nil || !strings.EqualFold(v.Action, "abort") {
+			return ""
+		}
+		text = "abort " + v.Reason
+	}
+	if len(text) < 5 || !strings.EqualFold(text[:5], "abort") || len(text) > 5 && isLetter(text[5]) {
+		return ""
+	}
+	reason := strings.TrimLeft(text[5:], " \t:-")
+	if reason == "" {
+		reason = "aborted by guardrail"
+	}
+	return reason
+}
+
+func isLetter(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+// watch polls the guardrail until done, calling abort with the reason once
+// it answers "abort". Failed polls don't stop the run.
+func (g *guardrail) watch(done <-chan struct{}, abort func(reason string)) {
+	ticker := time.NewTicker(g.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+		if reason, err := g.check(); err == nil && reason != "" {
+			abort(reason)
+			return
+		}
+	}
+}
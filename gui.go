@@ -1,6 +1,8 @@
 package main
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -12,48 +14,133 @@ import (
 	"time"
 
 	"github.com/valyala/fasthttp"
+	"golang.org/x/time/rate"
+	"gopkg.in/alecthomas/kingpin.v3-unstable"
 )
 
 // GUIServer manages the web-based benchmark interface
 type GUIServer struct {
-	ln net.Listener
+	ln   net.Listener
+	tls  bool     // ln is a TLS listener of --gui-cert
+	auth *guiAuth // nil when open to all
+	// grace is the time the requests in flight have to complete on /stop
+	grace time.Duration
+	// agentConn reaches the agents and probes of the runs
+	agentConn *agentTransport
 
 	mu        sync.Mutex
 	running   bool
-	requester *Requester
+	requester recordSource
 	report    *StreamReport
 	desc      string
+	run       *guiRun
+	runs      []*guiRun
+	presets   map[string]*Preset
+	quotas    guiQuotas
+	webhooks  []*webhook
+
+	// the settings read again by reload
+	quotaSpecs    []string
+	webhookSpecs  []string
+	configPath    string
+	configPresets map[string]bool // the presets of the config file
 }
 
+// guiRun is a benchmark started from the web UI, kept for later retrieval
+type guiRun struct {
+	ID        string    `json:"id"`
+	Desc      string    `json:"desc"`
+	StartedAt time.Time `json:"startedAt"`
+	Done      bool      `json:"done"`
+	// Interrupted is set when /stop ended the run, its summary counting the
+	// requests in flight
+	Interrupted bool   `json:"interrupted,omitempty"`
+	Preset      string `json:"preset,omitempty"`
+	Notes       string `json:"notes,omitempty"` // context of the run, written before or after it
+
+	report   *StreamReport
+	summary  *Summary
+	failures *failureSamples
+	duration time.Duration
+	requests int64 // 0 when bounded by duration only
+	// concurrency is the one of the start, or of the last /adjust, 0 with
+	// agents
+	concurrency int
+}
+
+// maxGUIRuns bounds how many finished runs are kept in memory
+const maxGUIRuns = 100
+
 // BenchmarkRequest is the JSON payload from the web UI
 type BenchmarkRequest struct {
-	URL         string `json:"url"`
-	Concurrency int    `json:"concurrency"`
-	Duration    int    `json:"duration"` // seconds
-	Method      string `json:"method"`
+	URL         string   `json:"url"`
+	Concurrency int      `json:"concurrency"`
+	Duration    int      `json:"duration"` // seconds
+	Method      string   `json:"method"`
+	Rate        float64  `json:"rate,omitempty"`   // requests per second, 0 for unlimited
+	Agents      []string `json:"agents,omitempty"` // fan out to plow agents instead of running locally
+	Preset      string   `json:"preset,omitempty"` // the preset the form was loaded from, grouping the trends of its runs
+	Notes       string   `json:"notes,omitempty"`
+	Requests    int64    `json:"requests,omitempty"` // total, the run ending at the first of it and Duration
+	Probe       string   `json:"probe,omitempty"`    // HOST:PORT of a plow probe on the target host
+	Soak        bool     `json:"soak,omitempty"`     // keep the last hour per second only
+	// FailureSamples is the number of failed requests kept with their
+	// responses, defaultFailureSamples when 0
+	FailureSamples int `json:"failureSamples,omitempty"`
+
+	Headers []string `json:"headers,omitempty"` // K:V
+	Body    string   `json:"body,omitempty"`
+	User    string   `json:"user,omitempty"` // USER:PASSWORD of Basic auth, or of Digest auth
+	Digest  bool     `json:"digest,omitempty"`
+
+	// HAR is the content of a HAR file replayed instead of URL, in HARMode
+	HAR     string `json:"har,omitempty"`
+	HARMode string `json:"harMode,omitempty"`
+
+	// TLS files are paths on the host running the GUI
+	Cert     string `json:"cert,omitempty"`
+	Key      string `json:"key,omitempty"`
+	CACert   string `json:"cacert,omitempty"`
+	Insecure bool   `json:"insecure,omitempty"`
 }
 
 // BenchmarkStatus is returned to the web UI
 type BenchmarkStatus struct {
 	Running bool   `json:"running"`
 	Desc    string `json:"desc"`
+	RunID   string `json:"runId,omitempty"`
+	// the progress of a run bounded by a count of requests
+	Requests  int64 `json:"requests,omitempty"`
+	Completed int64 `json:"completed,omitempty"`
+	// the progress of a running run in time, in seconds
+	Duration float64 `json:"duration,omitempty"`
+	Elapsed  float64 `json:"elapsed,omitempty"`
+	// the workers of a running run, tuned by /adjust
+	Concurrency int `json:"concurrency,omitempty"`
 }
 
 func NewGUIServer(ln net.Listener) *GUIServer {
-	return &GUIServer{ln: ln}
+	return &GUIServer{ln: ln, presets: make(map[string]*Preset)}
 }
 
 func (g *GUIServer) Handler(ctx *fasthttp.RequestCtx) {
 	path := string(ctx.Path())
 	method := string(ctx.Method())
 
-	ctx.Response.Header.Set("Access-Control-Allow-Origin", "*")
-	ctx.Response.Header.Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-	ctx.Response.Header.Set("Access-Control-Allow-Headers", "Content-Type")
-
-	if method == "OPTIONS" {
-		ctx.SetStatusCode(200)
-		return
+	if g.auth != nil && !guiOpenPath(path) {
+		g.mu.Lock()
+		quotas := g.quotas
+		g.mu.Unlock()
+		switch g.auth.role(ctx, quotas) {
+		case guiNobody:
+			g.auth.refuse(ctx)
+			return
+		case guiObserver:
+			if !guiObserverPath(method, path) {
+				g.auth.forbid(ctx)
+				return
+			}
+		}
 	}
 
 	switch {
@@ -67,9 +154,97 @@ func (g *GUIServer) Handler(ctx *fasthttp.RequestCtx) {
 	case path == "/stop" && method == "POST":
 		g.handleStop(ctx)
 
+	case path == "/adjust" && method == "POST":
+		g.handleAdjust(ctx)
+
 	case path == "/status" && method == "GET":
 		g.handleStatus(ctx)
 
+	case path == "/access" && method == "GET":
+		g.handleAccess(ctx)
+
+	case path == "/reload" && method == "POST":
+		g.handleReload(ctx)
+
+	case path == "/curl" && method == "POST":
+		// the request of a pasted cURL command, to fill the form with
+		ctx.SetContentType("application/json")
+		cr, err := parseCurl(string(ctx.PostBody()))
+		if err != nil {
+			ctx.SetStatusCode(400)
+			json.NewEncoder(ctx).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		json.NewEncoder(ctx).Encode(cr)
+
+	case path == "/config/export" && method == "POST":
+		g.handleExportConfig(ctx)
+
+	case path == "/config/import" && method == "POST":
+		g.handleImportConfig(ctx)
+
+	case path == "/flags" && method == "GET":
+		ctx.SetContentType("application/json")
+		_ = writeHelpJSON(ctx, kingpin.CommandLine)
+
+	case path == "/docs" && method == "GET":
+		ctx.SetContentType("text/html; charset=utf-8")
+		_ = writeDocsHTML(ctx, kingpin.CommandLine)
+
+	case strings.HasPrefix(path, "/docs/") && method == "GET":
+		ctx.SetContentType("text/html; charset=utf-8")
+		if ok, _ := writeFlagDocHTML(ctx, kingpin.CommandLine, path[len("/docs/"):]); !ok {
+			ctx.Error("NotFound", fasthttp.StatusNotFound)
+		}
+
+	case path == "/presets" && method == "GET":
+		g.handlePresets(ctx)
+
+	case path == "/presets" && method == "POST":
+		g.handleSavePreset(ctx)
+
+	case path == "/presets/export" && method == "GET":
+		g.handleExportPresets(ctx)
+
+	case path == "/presets/import" && method == "POST":
+		g.handleImportPresets(ctx)
+
+	case strings.HasPrefix(path, "/presets/") && method == "DELETE":
+		g.handleDeletePreset(ctx, path[len("/presets/"):])
+
+	case path == "/runs" && method == "GET":
+		g.handleRuns(ctx)
+
+	case path == "/trends" && method == "GET":
+		g.handleTrends(ctx)
+
+	case strings.HasPrefix(path, "/runs/") && strings.HasSuffix(path, "/summary.json") && method == "GET":
+		g.handleRunSummary(ctx, strings.TrimSuffix(path[len("/runs/"):], "/summary.json"))
+
+	case strings.HasPrefix(path, "/runs/") && strings.HasSuffix(path, "/table") && method == "GET":
+		g.handleRunTable(ctx, strings.TrimSuffix(path[len("/runs/"):], "/table"))
+
+	case strings.HasPrefix(path, "/runs/") && strings.HasSuffix(path, "/notes") && method == "POST":
+		g.handleRunNotes(ctx, strings.TrimSuffix(path[len("/runs/"):], "/notes"))
+
+	case strings.HasPrefix(path, "/runs/") && strings.HasSuffix(path, "/failures") && method == "GET":
+		g.handleRunFailures(ctx, strings.TrimSuffix(path[len("/runs/"):], "/failures"))
+
+	case strings.HasPrefix(path, "/runs/") && strings.HasSuffix(path, "/metrics.csv") && method == "GET":
+		g.handleRunCSV(ctx, strings.TrimSuffix(path[len("/runs/"):], "/metrics.csv"))
+
+	case strings.HasPrefix(path, "/runs/") && strings.HasSuffix(path, "/live") && method == "GET":
+		g.handleRunLive(ctx, strings.TrimSuffix(path[len("/runs/"):], "/live"))
+
+	case strings.HasPrefix(path, "/watch/") && method == "GET":
+		g.handleWatch(ctx, path[len("/watch/"):])
+
+	case path == "/data/heatmap" && method == "GET":
+		g.handleHeatmap(ctx)
+
+	case path == "/data/history" && method == "GET":
+		g.handleHistory(ctx)
+
 	case strings.HasPrefix(path, "/data/") && method == "GET":
 		g.handleChartData(ctx, path[len("/data/"):])
 
@@ -96,20 +271,51 @@ func (g *GUIServer) handleStart(ctx *fasthttp.RequestCtx) {
 		json.NewEncoder(ctx).Encode(map[string]string{"error": "invalid request: " + err.Error()})
 		return
 	}
-	if req.URL == "" {
+	if req.URL == "" && req.HAR == "" {
 		ctx.SetStatusCode(400)
 		json.NewEncoder(ctx).Encode(map[string]string{"error": "url is required"})
 		return
 	}
+	if len(req.Notes) > maxRunNotes {
+		ctx.SetStatusCode(400)
+		json.NewEncoder(ctx).Encode(map[string]string{"error": "notes are limited to 64KB"})
+		return
+	}
+	g.mu.Lock()
+	quotas := g.quotas
+	g.mu.Unlock()
+	if status, err := quotas.apply(requestToken(ctx), &req); err != nil {
+		ctx.SetStatusCode(status)
+		json.NewEncoder(ctx).Encode(map[string]string{"error": err.Error()})
+		return
+	}
 	if req.Concurrency <= 0 {
 		req.Concurrency = 1
 	}
-	if req.Duration <= 0 {
+	if req.Requests < 0 {
+		ctx.SetStatusCode(400)
+		json.NewEncoder(ctx).Encode(map[string]string{"error": "requests must be positive"})
+		return
+	}
+	if req.Duration <= 0 && req.Requests == 0 {
 		req.Duration = 10
 	}
 	if req.Method == "" {
 		req.Method = "GET"
 	}
+	if req.FailureSamples <= 0 {
+		req.FailureSamples = defaultFailureSamples
+	}
+	if (req.Cert == "") != (req.Key == "") {
+		ctx.SetStatusCode(400)
+		json.NewEncoder(ctx).Encode(map[string]string{"error": "cert and key must be set together"})
+		return
+	}
+	if len(req.Agents) > 0 && (req.Cert != "" || req.CACert != "") {
+		ctx.SetStatusCode(400)
+		json.NewEncoder(ctx).Encode(map[string]string{"error": "cert and CA cert are not supported with agents"})
+		return
+	}
 
 	g.mu.Lock()
 	defer g.mu.Unlock()
@@ -122,43 +328,149 @@ func (g *GUIServer) handleStart(ctx *fasthttp.RequestCtx) {
 
 	atomic.StoreInt64(&startTimeUnixNano, 0)
 
+	var har *harScenario
+	urls, weights, err := parseTargetMix([]string{req.URL})
+	if req.HAR != "" {
+		if req.HARMode != harSequence {
+			req.HARMode = harWeighted
+		}
+		if har, err = parseHAR([]byte(req.HAR), req.HARMode, nil); err == nil {
+			urls, weights = har.urls, har.weights
+		}
+	}
+	if err != nil {
+		ctx.SetStatusCode(400)
+		json.NewEncoder(ctx).Encode(map[string]string{"error": err.Error()})
+		return
+	}
 	clientOpt := &ClientOpt{
-		url:      req.URL,
+		urls:     urls,
+		weights:  weights,
 		method:   req.Method,
 		maxConns: req.Concurrency,
+
+		headers:   req.Headers,
+		bodyBytes: []byte(req.Body),
+		user:      req.User,
+		digest:    req.Digest,
+
+		certPath:   req.Cert,
+		keyPath:    req.Key,
+		caCertPath: req.CACert,
+		insecure:   req.Insecure,
+
+		grace:    g.grace,
+		failures: newFailureSamples(req.FailureSamples),
+	}
+	if har != nil {
+		clientOpt.targetRequests, clientOpt.sequence = har.requests, req.HARMode == harSequence
+	}
+
+	if req.Digest && len(req.Agents) == 0 {
+		if clientOpt.auth, err = newDigestAuth(req.User); err != nil {
+			ctx.SetStatusCode(400)
+			json.NewEncoder(ctx).Encode(map[string]string{"error": err.Error()})
+			return
+		}
 	}
 
 	dur := time.Duration(req.Duration) * time.Second
-	requester, err := NewRequester(req.Concurrency, -1, dur, nil, io.Discard, clientOpt, -1)
-	if err != nil {
-		ctx.SetStatusCode(400)
-		json.NewEncoder(ctx).Encode(map[string]string{"error": err.Error()})
-		return
+	requests := int64(-1)
+	if req.Requests > 0 {
+		requests = req.Requests
+	}
+	var limit *rate.Limit
+	if req.Rate > 0 {
+		l := rate.Limit(req.Rate)
+		limit = &l
+	}
+	var requester recordSource
+	if len(req.Agents) > 0 {
+		requester = NewController(req.Agents, newAgentJob(clientOpt, req.Concurrency, requests, dur, limit, -1, nil), g.agentConn, io.Discard)
+	} else {
+		r, err := NewRequester(req.Concurrency, requests, dur, limit, io.Discard, clientOpt, -1)
+		if err != nil {
+			ctx.SetStatusCode(400)
+			json.NewEncoder(ctx).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		requester = r
 	}
 
-	report := NewStreamReport()
+	report := NewStreamReport(requester.Targets())
+	report.backpressure = requester.Backpressure()
+	report.interrupted = requester.Interrupted()
+	report.urls, report.weights = urls, weights
+	if har != nil {
+		report.urls = har.labels()
+	}
+	if c, ok := requester.(*Controller); ok {
+		report.regions = c.regions
+	}
+	if req.Probe != "" {
+		report.probe = newServerProbe(req.Probe, g.agentConn)
+	}
+	report.soak = req.Soak
 	g.report = report
 	g.requester = requester
 	g.running = true
-	g.desc = fmt.Sprintf("Benchmarking %s for %ds using %d connection(s)", req.URL, req.Duration, req.Concurrency)
+	bounds := ""
+	if req.Requests > 0 {
+		bounds = fmt.Sprintf(" with %d request(s)", req.Requests)
+	}
+	if req.Duration > 0 {
+		bounds += fmt.Sprintf(" for %ds", req.Duration)
+	}
+	g.desc = fmt.Sprintf("Benchmarking %s%s using %d connection(s)", req.URL, bounds, req.Concurrency)
+	if har != nil {
+		g.desc = fmt.Sprintf("Replaying %d HAR request(s) in %s mode%s using %d connection(s)", len(urls), req.HARMode, bounds, req.Concurrency)
+	}
+	if req.Rate > 0 {
+		g.desc += fmt.Sprintf(" at %g req/s", req.Rate)
+	}
+	if len(req.Agents) > 0 {
+		g.desc += fmt.Sprintf(" across %d agent(s)", len(req.Agents))
+	}
+	run := &guiRun{ID: newRunID(), Desc: g.desc, StartedAt: time.Now(), Preset: req.Preset, Notes: req.Notes, report: report, failures: clientOpt.failures, duration: dur}
+	if req.Requests > 0 {
+		run.requests = req.Requests
+	}
+	if len(req.Agents) == 0 {
+		run.concurrency = req.Concurrency
+	}
+	g.run = run
+	g.runs = append(g.runs, run)
+	if len(g.runs) > maxGUIRuns {
+		g.runs = g.runs[len(g.runs)-maxGUIRuns:]
+	}
 
 	fmt.Fprintf(os.Stderr, "\n%s\n\n", g.desc)
 
 	go func() {
 		go requester.Run()
+		if report.probe != nil {
+			go report.probe.follow(report.Done())
+		}
 		go report.Collect(requester.RecordChan())
 
-		printer := NewPrinter(-1, dur, false, false)
-		printer.PrintLoop(report.Snapshot, 200*time.Millisecond, false, false, report.Done())
+		printer := NewPrinter(requests, dur, false, false)
+		printer.PrintLoop(report.Snapshot, 200*time.Millisecond, false, false, false, report.Done())
 
+		summary := NewSummary(report.Snapshot(), false)
 		g.mu.Lock()
 		g.running = false
+		run.summary = summary
+		run.Done, run.Interrupted = true, summary.Interrupted
+		webhooks := g.webhooks
 		g.mu.Unlock()
+		if len(webhooks) > 0 {
+			notifyWebhooks(webhooks, newWebhookEvent(run.ID, run.Desc, summary))
+		}
 
 		fmt.Fprintln(os.Stderr, "\n[Benchmark complete]")
 	}()
 
-	json.NewEncoder(ctx).Encode(map[string]string{"status": "started", "desc": g.desc})
+	json.NewEncoder(ctx).Encode(map[string]string{"status": "started", "desc": g.desc, "id": run.ID})
 }
 
 func (g *GUIServer) handleStop(ctx *fasthttp.RequestCtx) {
@@ -169,16 +481,208 @@ func (g *GUIServer) handleStop(ctx *fasthttp.RequestCtx) {
 		json.NewEncoder(ctx).Encode(map[string]string{"status": "not running"})
 		return
 	}
-	g.requester.Cancel()
+	// the run ends once its requests in flight drained, with its summary
+	g.requester.Interrupt()
 	json.NewEncoder(ctx).Encode(map[string]string{"status": "stopped"})
 }
 
+// handleAdjust sets the concurrency of the running benchmark, within the
+// quota of its token
+func (g *GUIServer) handleAdjust(ctx *fasthttp.RequestCtx) {
+	ctx.SetContentType("application/json")
+	var adj struct {
+		Concurrency int `json:"concurrency"`
+	}
+	if err := json.Unmarshal(ctx.PostBody(), &adj); err != nil {
+		ctx.SetStatusCode(400)
+		json.NewEncoder(ctx).Encode(map[string]string{"error": "invalid request: " + err.Error()})
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if status, err := g.quotas.adjust(requestToken(ctx), adj.Concurrency); err != nil {
+		ctx.SetStatusCode(status)
+		json.NewEncoder(ctx).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	if !g.running || g.run == nil {
+		ctx.SetStatusCode(409)
+		json.NewEncoder(ctx).Encode(map[string]string{"error": "benchmark is not running"})
+		return
+	}
+	r, ok := g.requester.(*Requester)
+	if !ok {
+		ctx.SetStatusCode(400)
+		json.NewEncoder(ctx).Encode(map[string]string{"error": "concurrency can't be adjusted with agents"})
+		return
+	}
+	if err := r.Adjust(adj.Concurrency); err != nil {
+		ctx.SetStatusCode(400)
+		json.NewEncoder(ctx).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	g.run.concurrency = adj.Concurrency
+	json.NewEncoder(ctx).Encode(map[string]int{"concurrency": adj.Concurrency})
+}
+
 func (g *GUIServer) handleStatus(ctx *fasthttp.RequestCtx) {
 	ctx.SetContentType("application/json")
 	g.mu.Lock()
-	running, desc := g.running, g.desc
+	status := BenchmarkStatus{Running: g.running, Desc: g.desc}
+	var report *StreamReport
+	if g.run != nil {
+		status.RunID, status.Requests = g.run.ID, g.run.requests
+		report = g.run.report
+		if g.running {
+			status.Duration = g.run.duration.Seconds()
+			status.Elapsed = roundFloat(time.Since(g.run.StartedAt).Seconds(), 1)
+			status.Concurrency = g.run.concurrency
+		}
+	}
+	g.mu.Unlock()
+	if status.Requests > 0 {
+		status.Completed = report.count()
+	}
+	json.NewEncoder(ctx).Encode(status)
+}
+
+func (g *GUIServer) findRun(id string) *guiRun {
+	for _, run := range g.runs {
+		if run.ID == id {
+			return run
+		}
+	}
+	return nil
+}
+
+func (g *GUIServer) handleRuns(ctx *fasthttp.RequestCtx) {
+	ctx.SetContentType("application/json")
+	g.mu.Lock()
+	runs := make([]guiRun, 0, len(g.runs))
+	for i := len(g.runs) - 1; i >= 0; i-- {
+		runs = append(runs, *g.runs[i])
+	}
+	g.mu.Unlock()
+	json.NewEncoder(ctx).Encode(runs)
+}
+
+// handleRunSummary serves the final summary of a run, or a live one while it is still running
+func (g *GUIServer) handleRunSummary(ctx *fasthttp.RequestCtx, id string) {
+	ctx.SetContentType("application/json")
+	g.mu.Lock()
+	run := g.findRun(id)
+	var summary *Summary
+	var report *StreamReport
+	var notes string
+	if run != nil {
+		summary, report, notes = run.summary, run.report, run.Notes
+	}
+	g.mu.Unlock()
+	if run == nil {
+		ctx.SetStatusCode(404)
+		json.NewEncoder(ctx).Encode(map[string]string{"error": "run not found"})
+		return
+	}
+	if summary == nil {
+		summary = NewSummary(report.Snapshot(), false)
+	} else {
+		// the final summary is kept without the notes, edited afterwards
+		s := *summary
+		summary = &s
+	}
+	summary.Notes = notes
+	enc := json.NewEncoder(ctx)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	enc.Encode(summary)
+}
+
+func (g *GUIServer) handleRunCSV(ctx *fasthttp.RequestCtx, id string) {
+	g.mu.Lock()
+	run := g.findRun(id)
+	g.mu.Unlock()
+	if run == nil {
+		ctx.Error("run not found", fasthttp.StatusNotFound)
+		return
+	}
+	ctx.SetContentType("text/csv; charset=utf-8")
+	ctx.Response.Header.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="plow-%s.csv"`, run.ID))
+	_ = writeTicksCSV(ctx, run.report.Ticks(0), true, nil)
+}
+
+// handleRunFailures serves the failed requests of a run kept with their
+// responses, the first ones of the run
+func (g *GUIServer) handleRunFailures(ctx *fasthttp.RequestCtx, id string) {
+	ctx.SetContentType("application/json")
+	g.mu.Lock()
+	run := g.findRun(id)
 	g.mu.Unlock()
-	json.NewEncoder(ctx).Encode(BenchmarkStatus{Running: running, Desc: desc})
+	if run == nil {
+		ctx.SetStatusCode(404)
+		json.NewEncoder(ctx).Encode(map[string]string{"error": "run not found"})
+		return
+	}
+	if ctx.QueryArgs().Has("download") {
+		ctx.Response.Header.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="plow-%s-failures.json"`, run.ID))
+	}
+	enc := json.NewEncoder(ctx)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	enc.Encode(run.failures.list())
+}
+
+// handleRunTable serves the summary tables of a run as the terminal prints them
+func (g *GUIServer) handleRunTable(ctx *fasthttp.RequestCtx, id string) {
+	g.mu.Lock()
+	run := g.findRun(id)
+	var done bool
+	var notes string
+	if run != nil {
+		done, notes = run.Done, run.Notes
+	}
+	g.mu.Unlock()
+	if run == nil {
+		ctx.Error("run not found", fasthttp.StatusNotFound)
+		return
+	}
+	ctx.SetContentType("text/plain; charset=utf-8")
+	if notes != "" {
+		ctx.WriteString("Notes:\n  " + strings.ReplaceAll(notes, "\n", "\n  ") + "\n\n")
+	}
+	printer := NewPrinter(-1, run.duration, false, false)
+	ctx.WriteString(printer.FormatText(run.report.Snapshot(), done, false))
+}
+
+// maxRunNotes bounds the size of the notes of a run
+const maxRunNotes = 64 << 10
+
+// handleRunNotes replaces the notes of a run with the request body
+func (g *GUIServer) handleRunNotes(ctx *fasthttp.RequestCtx, id string) {
+	ctx.SetContentType("application/json")
+	notes := strings.TrimSpace(string(ctx.PostBody()))
+	if len(notes) > maxRunNotes {
+		ctx.SetStatusCode(400)
+		json.NewEncoder(ctx).Encode(map[string]string{"error": "notes are limited to 64KB"})
+		return
+	}
+	g.mu.Lock()
+	run := g.findRun(id)
+	if run != nil {
+		run.Notes = notes
+	}
+	g.mu.Unlock()
+	if run == nil {
+		ctx.SetStatusCode(404)
+		json.NewEncoder(ctx).Encode(map[string]string{"error": "run not found"})
+		return
+	}
+	json.NewEncoder(ctx).Encode(map[string]string{"status": "saved"})
+}
+
+func newRunID() string {
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	return time.Now().Format("20060102-150405") + "-" + hex.EncodeToString(b)
 }
 
 func (g *GUIServer) handleChartData(ctx *fasthttp.RequestCtx, view string) {
@@ -189,8 +693,11 @@ func (g *GUIServer) handleChartData(ctx *fasthttp.RequestCtx, view string) {
 	g.mu.Unlock()
 
 	var values []interface{}
+	var warning string
+	warmup := false
 	if report != nil {
 		rd := report.Charts()
+		warmup = rd != nil && rd.Warmup
 		switch view {
 		case latencyView:
 			if rd != nil {
@@ -219,6 +726,28 @@ func (g *GUIServer) handleChartData(ctx *fasthttp.RequestCtx, view string) {
 			} else {
 				values = append(values, nil)
 			}
+		case errorsView:
+			for i := range errorClassNames {
+				if rd != nil {
+					values = append(values, rd.Errors[i])
+				} else {
+					values = append(values, nil)
+				}
+			}
+		case throughputView:
+			// the last second for the chart, the run and the mean response
+			// size for the stat cards
+			if rd != nil {
+				values = append(values, rd.ReadThroughput, rd.WriteThroughput, rd.AvgReadThroughput, rd.AvgWriteThroughput, rd.RespSize)
+			} else {
+				values = append(values, nil, nil, nil, nil, nil)
+			}
+		case poolView:
+			values = rd.pool()
+		case clientView:
+			values, warning = rd.client()
+		case serverView:
+			values = rd.server()
 		}
 	} else {
 		switch view {
@@ -226,26 +755,43 @@ func (g *GUIServer) handleChartData(ctx *fasthttp.RequestCtx, view string) {
 			values = append(values, nil, nil, nil, nil, nil, nil)
 		case rpsView:
 			values = append(values, nil, nil, nil)
+		case throughputView:
+			values = append(values, nil, nil, nil, nil, nil)
+		case errorsView:
+			values = make([]interface{}, numErrorClasses)
+		case poolView:
+			values = (*ChartsReport)(nil).pool()
+		case clientView:
+			values, _ = (*ChartsReport)(nil).client()
+		case serverView:
+			values = (*ChartsReport)(nil).server()
 		default:
 			values = append(values, nil)
 		}
 	}
-
 	json.NewEncoder(ctx).Encode(&Metrics{
-		Time:   time.Now().Format(timeFormat),
-		Values: values,
+		Time:    time.Now().Format(timeFormat),
+		Values:  values,
+		Warning: warning,
+		Warmup:  warmup,
 	})
 }
 
 func (g *GUIServer) Serve(open bool) {
 	server := fasthttp.Server{Handler: g.Handler}
 	addr := "http://" + g.ln.Addr().String()
+	if g.tls {
+		addr = "https://" + g.ln.Addr().String()
+	}
 	fmt.Fprintf(os.Stderr, "🚀 Plow GUI is ready at %s\n", addr)
 	fmt.Fprintln(os.Stderr, "   Open the URL above in your browser to configure and run benchmarks.")
 	fmt.Fprintln(os.Stderr, "")
 	if open {
 		go openBrowser(addr)
 	}
+	if g.configPath != "" {
+		go g.reloadOnHUP()
+	}
 	_ = server.Serve(g.ln)
 }
 
@@ -286,7 +832,7 @@ body{font-family:'Inter',sans-serif;background:var(--bg);color:var(--text);min-h
 .cfg{padding:28px 28px 24px;margin-bottom:24px}
 .cfg-title{font-size:15px;font-weight:600;margin-bottom:20px;display:flex;align-items:center;gap:8px}
 .cfg-title::before{content:'⚙️';font-size:17px}
-.form-grid{display:grid;grid-template-columns:1fr 120px 120px 120px auto;gap:14px;align-items:end}
+.form-grid{display:grid;grid-template-columns:1fr 110px 110px 110px 110px 110px auto;gap:14px;align-items:end}
 @media(max-width:860px){.form-grid{grid-template-columns:1fr 1fr}.btn-grp{grid-column:1/-1}}
 .fg{display:flex;flex-direction:column;gap:7px}
 .lbl{font-size:11px;font-weight:600;color:var(--text2);text-transform:uppercase;letter-spacing:.5px}
@@ -294,6 +840,28 @@ body{font-family:'Inter',sans-serif;background:var(--bg);color:var(--text);min-h
 .inp:focus{border-color:var(--accent);box-shadow:0 0 0 3px var(--accent-glow)}
 .inp::placeholder{color:var(--text3)}
 .btn-grp{display:flex;gap:10px;align-items:center}
+.doc{margin-left:5px;color:var(--text3);text-decoration:none;font-weight:600}
+.obs{display:none;padding:2px 10px;border:1px solid var(--border);border-radius:20px;font-size:12px;color:var(--accent2)}
+body.observer .obs{display:inline}
+body.observer .cfg, body.observer #notesCard .btn-xs, body.observer .adj{display:none}
+.adj{display:flex;align-items:center;gap:8px;margin-left:auto;margin-right:10px;font-size:11px;color:var(--text2);font-variant-numeric:tabular-nums}
+.adj input{width:110px;accent-color:var(--accent)}
+.doc:hover{color:var(--accent2)}
+.agents{margin-top:14px}
+.presets{display:flex;gap:10px;align-items:center;margin-bottom:18px;flex-wrap:wrap}
+.presets .inp{width:auto;min-width:220px;padding:6px 11px;font-size:13px}
+.btn-sm{font-size:12px;padding:6px 12px}
+.har{display:flex;gap:8px;align-items:center;font-size:13px;color:var(--text2)}
+.presets .har .inp{min-width:0}
+.req-grid{display:grid;grid-template-columns:1fr 1fr;gap:14px;margin-top:14px}
+textarea.inp{font-family:'JetBrains Mono',monospace;font-size:12px;resize:vertical;min-height:64px}
+.curl{margin-bottom:18px}
+.curl .btn-grp{margin-top:8px}
+.tls-grid{display:grid;grid-template-columns:1fr 1fr 1fr auto;gap:14px;align-items:end;margin-top:14px}
+@media(max-width:860px){.tls-grid{grid-template-columns:1fr}}
+.auth-grid{display:grid;grid-template-columns:1fr 160px;gap:14px;margin-top:14px}
+.chk{display:flex;align-items:center;gap:7px;font-size:13px;color:var(--text2);padding:9px 0;white-space:nowrap}
+.opt{text-transform:none;letter-spacing:0;font-weight:400;color:var(--text3)}
 .btn{font-family:'Inter',sans-serif;font-size:14px;font-weight:600;border:none;border-radius:var(--rs);padding:9px 22px;cursor:pointer;transition:all .2s;display:flex;align-items:center;gap:7px;white-space:nowrap}
 .btn-run{background:linear-gradient(135deg,var(--accent),#8b5cf6);color:#fff;box-shadow:0 4px 12px var(--accent-glow)}
 .btn-run:hover:not(:disabled){transform:translateY(-1px);box-shadow:0 6px 20px var(--accent-glow)}
@@ -318,10 +886,16 @@ body{font-family:'Inter',sans-serif;background:var(--bg);color:var(--text);min-h
 .sval{font-family:'JetBrains Mono',monospace;font-size:26px;font-weight:500;line-height:1;transition:all .3s}
 .sval.g{color:var(--green)}.sval.a{color:var(--accent2)}.sval.y{color:var(--yellow)}
 .sunit{font-size:11px;color:var(--text3);margin-top:3px}
+.vtog{display:flex;justify-content:flex-end;margin-bottom:12px}
+.vtog button{background:var(--bg3);border:1px solid var(--border);color:var(--text2);font-size:12px;padding:4px 12px;cursor:pointer}
+.vtog button:first-child{border-radius:var(--rs) 0 0 var(--rs)}.vtog button:last-child{border-radius:0 var(--rs) var(--rs) 0;border-left:none}
+.vtog button.on{background:var(--accent);border-color:var(--accent);color:#fff}
+.cwarn{display:none;margin-bottom:18px;padding:10px 14px;border:1px solid var(--yellow);border-radius:var(--rs);color:var(--yellow);font-size:13px}
 
 /* Charts */
 .charts{display:grid;grid-template-columns:repeat(2,1fr);gap:18px;margin-bottom:24px}
 @media(max-width:860px){.charts{grid-template-columns:1fr}}
+.chart-card.wide{grid-column:1/-1}
 .chart-card{background:var(--card);border:1px solid var(--border);border-radius:var(--r);overflow:hidden;box-shadow:var(--shd)}
 .chart-head{padding:14px 18px 10px;border-bottom:1px solid var(--border);display:flex;align-items:center;justify-content:space-between}
 .chart-title{font-size:13px;font-weight:600}
@@ -337,6 +911,18 @@ body{font-family:'Inter',sans-serif;background:var(--bg);color:var(--text);min-h
 .log-body{padding:14px 18px;font-family:'JetBrains Mono',monospace;font-size:11px;color:var(--text2);height:150px;overflow-y:auto;line-height:1.9}
 .log-body::-webkit-scrollbar{width:3px}
 .log-body::-webkit-scrollbar-thumb{background:var(--border);border-radius:4px}
+.tbl-card{margin-bottom:24px}
+textarea.notes{display:block;width:100%;border:none;border-radius:0;box-sizing:border-box}
+.log-head .inp{width:auto;min-width:180px;padding:5px 10px;font-size:12px}
+.tbl-body{padding:14px 18px;margin:0;font-family:'JetBrains Mono',monospace;font-size:12px;color:var(--text);line-height:1.5;overflow-x:auto;white-space:pre}
+.tgt{width:100%;border-collapse:collapse;font-family:'JetBrains Mono',monospace;font-size:12px}
+.tgt th{color:var(--text3);font-weight:500;text-align:right;padding:8px 18px;border-bottom:1px solid var(--border)}
+.tgt td{text-align:right;padding:6px 18px;border-bottom:1px solid var(--bg3)}
+.tgt th:first-child,.tgt td:first-child{text-align:left;word-break:break-all}
+.tgt td.er{color:var(--red)}
+.fail summary{padding:8px 18px;border-bottom:1px solid var(--bg3);font-family:'JetBrains Mono',monospace;font-size:12px;cursor:pointer}
+.fail summary .er{color:var(--red);margin:0 8px}
+.fail summary .ts{color:var(--text3)}
 .le{margin-bottom:1px}
 .le.ok{color:var(--green)}.le.er{color:var(--red)}.le.in{color:var(--accent2)}
 .le .ts{color:var(--text3);margin-right:8px}
@@ -350,6 +936,9 @@ body{font-family:'Inter',sans-serif;background:var(--bg);color:var(--text);min-h
     <div class="subtitle">HTTP Load Testing Tool</div>
   </div>
   <div class="hstatus">
+    <a class="doc" href="/docs" target="_blank">Reference</a>
+    <span class="obs" title="Read-only view following the runs started by others">👁 Observing</span>
+    <a class="doc" id="signIn" href="/access?login=1" style="display:none">Sign in</a>
     <div class="dot" id="dot"></div>
     <span id="hstxt">Idle</span>
   </div>
@@ -359,22 +948,57 @@ body{font-family:'Inter',sans-serif;background:var(--bg);color:var(--text);min-h
 
   <div class="card cfg">
     <div class="cfg-title">Benchmark Configuration</div>
+    <div class="presets">
+      <select class="inp" id="iPreset" onchange="loadPreset()"><option value="">— Presets —</option></select>
+      <button class="btn btn-stop btn-sm" onclick="savePreset()">Save</button>
+      <button class="btn btn-stop btn-sm" onclick="deletePreset()">Delete</button>
+      <button class="btn btn-stop btn-sm" onclick="window.location=withToken('/presets/export')">⬇ Export</button>
+      <button class="btn btn-stop btn-sm" onclick="document.getElementById('iImport').click()">⬆ Import</button>
+      <input type="file" id="iImport" accept="application/json,.json" style="display:none" onchange="importPresets(this)" />
+      <button class="btn btn-stop btn-sm" onclick="exportConfig()" title="The form as a config file, also run by plow run -f FILE">⬇ Config</button>
+      <button class="btn btn-stop btn-sm" onclick="document.getElementById('iConfig').click()">⬆ Config</button>
+      <input type="file" id="iConfig" accept=".yaml,.yml,.json" style="display:none" onchange="importConfig(this)" />
+      <button class="btn btn-stop btn-sm" onclick="toggleCurl()">📋 cURL</button>
+      <button class="btn btn-stop btn-sm" data-flag="har" onclick="document.getElementById('iHar').click()">📄 HAR</button>
+      <input type="file" id="iHar" accept=".har,application/json" style="display:none" onchange="loadHAR(this)" />
+      <span class="har" id="harInfo" style="display:none">
+        <span id="harName"></span>
+        <select class="inp" id="iHarMode" data-flag="har-mode"><option>weighted</option><option>sequence</option></select>
+        <button class="btn-xs" onclick="clearHAR()">✕</button>
+      </span>
+    </div>
+    <div class="fg curl" id="curlBox" style="display:none">
+      <label class="lbl" for="iCurl">Paste a cURL command <span class="opt">(e.g. Copy as cURL of the browser dev tools)</span></label>
+      <textarea class="inp" id="iCurl" rows="4" placeholder="curl 'https://example.com/api' -H 'Authorization: Bearer …' --data-raw '{…}'"></textarea>
+      <div class="btn-grp">
+        <button class="btn btn-run btn-sm" onclick="importCurl()">Import</button>
+        <button class="btn btn-stop btn-sm" onclick="toggleCurl()">Cancel</button>
+      </div>
+    </div>
     <div class="form-grid">
       <div class="fg">
-        <label class="lbl" for="iUrl">Target URL</label>
-        <input class="inp" id="iUrl" type="url" placeholder="https://example.com/api" value="" />
+        <label class="lbl" for="iUrl">Target URL <span class="opt">(or a weighted mix: url:70, /path:30)</span></label>
+        <input class="inp" id="iUrl" data-flag="url" type="text" placeholder="https://example.com/api" value="" />
       </div>
       <div class="fg">
         <label class="lbl" for="iConc">Concurrency</label>
-        <input class="inp" id="iConc" type="number" min="1" max="10000" value="10" />
+        <input class="inp" id="iConc" data-flag="concurrency" type="number" min="1" max="10000" value="10" />
       </div>
       <div class="fg">
         <label class="lbl" for="iDur">Duration (s)</label>
-        <input class="inp" id="iDur" type="number" min="1" max="3600" value="10" />
+        <input class="inp" id="iDur" data-flag="duration" type="number" min="1" max="3600" value="10" />
+      </div>
+      <div class="fg">
+        <label class="lbl" for="iReq">Requests</label>
+        <input class="inp" id="iReq" data-flag="requests" type="number" min="1" placeholder="∞" value="" />
+      </div>
+      <div class="fg">
+        <label class="lbl" for="iRate">Rate (req/s)</label>
+        <input class="inp" id="iRate" data-flag="rate" type="number" min="0" placeholder="∞" value="" />
       </div>
       <div class="fg">
         <label class="lbl" for="iMeth">Method</label>
-        <select class="inp" id="iMeth">
+        <select class="inp" id="iMeth" data-flag="method">
           <option>GET</option><option>POST</option><option>PUT</option>
           <option>DELETE</option><option>PATCH</option><option>HEAD</option>
         </select>
@@ -382,6 +1006,58 @@ body{font-family:'Inter',sans-serif;background:var(--bg);color:var(--text);min-h
       <div class="btn-grp">
         <button class="btn btn-run" id="btnRun" onclick="startBench()">▶ Run Benchmark</button>
         <button class="btn btn-stop" id="btnStop" onclick="stopBench()" disabled>■ Stop</button>
+        <button class="btn btn-stop" id="btnCsv" onclick="downloadCSV()" disabled>⬇ CSV</button>
+        <button class="btn btn-stop" id="btnWatch" onclick="openWatch()" disabled title="Condensed live view of the run for phones">📱 Watch</button>
+      </div>
+    </div>
+    <div class="fg agents">
+      <label class="lbl" for="iAgents">Agents <span class="opt">(optional, comma-separated host:port of <code>plow agent</code>, region=host:port for per region results)</span></label>
+      <input class="inp" id="iAgents" data-flag="agent" type="text" placeholder="eu=10.0.0.1:19999, us=10.0.0.2:19999" value="" />
+    </div>
+    <div class="fg agents">
+      <label class="lbl" for="iProbe">Probe <span class="opt">(optional, host:port of <code>plow probe</code> on the target host, to chart its CPU, memory and network)</span></label>
+      <input class="inp" id="iProbe" data-flag="probe" type="text" placeholder="10.0.0.9:19998" value="" />
+    </div>
+    <div class="fg agents">
+      <label class="chk"><input type="checkbox" id="iSoak" data-flag="soak" /> Soak <span class="opt">(for runs of hours, keeping the last hour per second and the whole run per minute)</span></label>
+    </div>
+    <div class="fg agents">
+      <label class="lbl" for="iNotes">Run Notes <span class="opt">(optional, kept with the next run and in its reports)</span></label>
+      <textarea class="inp" id="iNotes" rows="2" placeholder="e.g. ran during a partial cache flush"></textarea>
+    </div>
+    <div class="req-grid">
+      <div class="fg">
+        <label class="lbl" for="iHeaders">Headers <span class="opt">(optional, one Name: value per line)</span></label>
+        <textarea class="inp" id="iHeaders" data-flag="header" rows="3" placeholder="Authorization: Bearer …"></textarea>
+      </div>
+      <div class="fg">
+        <label class="lbl" for="iBody">Body <span class="opt">(optional)</span></label>
+        <textarea class="inp" id="iBody" data-flag="body" rows="3" placeholder='{"name":"plow"}'></textarea>
+      </div>
+    </div>
+    <div class="tls-grid">
+      <div class="fg">
+        <label class="lbl" for="iCert">Client Cert <span class="opt">(optional, PEM path)</span></label>
+        <input class="inp" id="iCert" data-flag="cert" type="text" placeholder="/path/to/client.crt" value="" />
+      </div>
+      <div class="fg">
+        <label class="lbl" for="iKey">Client Key <span class="opt">(PEM path)</span></label>
+        <input class="inp" id="iKey" data-flag="key" type="text" placeholder="/path/to/client.key" value="" />
+      </div>
+      <div class="fg">
+        <label class="lbl" for="iCA">CA Cert <span class="opt">(optional, PEM path)</span></label>
+        <input class="inp" id="iCA" data-flag="cacert" type="text" placeholder="/path/to/ca.crt" value="" />
+      </div>
+      <label class="chk"><input type="checkbox" id="iInsecure" data-flag="insecure" /> Skip TLS verify</label>
+    </div>
+    <div class="auth-grid">
+      <div class="fg">
+        <label class="lbl" for="iUser">Credentials <span class="opt">(optional, user:password)</span></label>
+        <input class="inp" id="iUser" data-flag="user" type="text" placeholder="alice:s3cret" value="" autocomplete="off" />
+      </div>
+      <div class="fg">
+        <label class="lbl" for="iAuth">Auth</label>
+        <select class="inp" id="iAuth" data-flag="digest"><option value="basic">Basic</option><option value="digest">Digest</option></select>
       </div>
     </div>
     <div class="prog" id="prog">
@@ -399,15 +1075,22 @@ body{font-family:'Inter',sans-serif;background:var(--bg);color:var(--text);min-h
     <div class="stat" id="sLat"><div class="slbl">Avg Latency</div><div class="sval g" id="vLat">—</div><div class="sunit">ms</div></div>
     <div class="stat" id="sMin"><div class="slbl">Min Latency</div><div class="sval" id="vMin">—</div><div class="sunit">ms</div></div>
     <div class="stat" id="sMax"><div class="slbl">Max Latency</div><div class="sval y" id="vMax">—</div><div class="sunit">ms</div></div>
+    <div class="stat" id="sRead"><div class="slbl">Read Throughput</div><div class="sval a" id="vRead">—</div><div class="sunit">MB/s (avg)</div></div>
+    <div class="stat" id="sWrite"><div class="slbl">Write Throughput</div><div class="sval a" id="vWrite">—</div><div class="sunit">MB/s (avg)</div></div>
+    <div class="stat" id="sSize"><div class="slbl">Response Size</div><div class="sval" id="vSize">—</div><div class="sunit">body (mean)</div></div>
   </div>
 
+  <div class="vtog" title="The latency and RPS charts of the last seconds, or of the whole run per minute">
+    <button class="on" id="tgRt" onclick="setChartView('realtime')">Realtime</button><button id="tgRun" onclick="setChartView('run')">Full run</button>
+  </div>
+  <div class="cwarn" id="clientWarn"></div>
   <div class="charts">
     <div class="chart-card">
-      <div class="chart-head"><div class="chart-title">Latency (ms)</div><div class="badge">realtime</div></div>
+      <div class="chart-head"><div class="chart-title">Latency (ms)</div><div class="badge" id="bLat">realtime</div></div>
       <div class="chart-body"><div id="cLatency" style="height:220px"></div></div>
     </div>
     <div class="chart-card">
-      <div class="chart-head"><div class="chart-title">Requests / Second</div><div class="badge">realtime</div></div>
+      <div class="chart-head"><div class="chart-title">Requests / Second</div><div class="badge" id="bRps">realtime</div></div>
       <div class="chart-body"><div id="cRps" style="height:220px"></div></div>
     </div>
     <div class="chart-card">
@@ -415,9 +1098,91 @@ body{font-family:'Inter',sans-serif;background:var(--bg);color:var(--text);min-h
       <div class="chart-body"><div id="cCode" style="height:220px"></div></div>
     </div>
     <div class="chart-card">
-      <div class="chart-head"><div class="chart-title">Concurrency</div><div class="badge">realtime</div></div>
+      <div class="chart-head"><div class="chart-title">Concurrency</div>
+        <div class="adj" id="adjBox" style="display:none" title="Workers of the running benchmark">
+          <input type="range" id="iAdj" min="1" max="100" value="10" oninput="setText('adjVal',this.value)" onchange="adjust()" /><span id="adjVal">10</span>
+        </div>
+        <div class="badge">realtime</div></div>
       <div class="chart-body"><div id="cConc" style="height:220px"></div></div>
     </div>
+    <div class="chart-card">
+      <div class="chart-head"><div class="chart-title">Bandwidth (MB/s)</div><div class="badge">realtime</div></div>
+      <div class="chart-body"><div id="cBw" style="height:220px"></div></div>
+    </div>
+    <div class="chart-card">
+      <div class="chart-head"><div class="chart-title">Errors / Second</div><div class="badge">by class</div></div>
+      <div class="chart-body"><div id="cErr" style="height:220px"></div></div>
+    </div>
+    <div class="chart-card">
+      <div class="chart-head"><div class="chart-title">Connection Pool</div><div class="badge">open · per second</div></div>
+      <div class="chart-body"><div id="cPool" style="height:220px"></div></div>
+    </div>
+    <div class="chart-card">
+      <div class="chart-head"><div class="chart-title">Client Resources</div><div class="badge">plow itself</div></div>
+      <div class="chart-body"><div id="cClient" style="height:220px"></div></div>
+    </div>
+    <div class="chart-card wide" id="serverCard" style="display:none">
+      <div class="chart-head"><div class="chart-title">Server Resources</div><div class="badge">plow probe</div></div>
+      <div class="chart-body"><div id="cServer" style="height:220px"></div></div>
+    </div>
+    <div class="chart-card wide">
+      <div class="chart-head"><div class="chart-title">Latency Heatmap</div><div class="badge">requests per bucket</div></div>
+      <div class="chart-body"><div id="cHeat" style="height:260px"></div></div>
+    </div>
+  </div>
+
+  <div class="log-card tbl-card" id="tgtCard" style="display:none">
+    <div class="log-head">
+      <div class="log-title">🎯 Targets</div>
+      <div class="badge">per url</div>
+    </div>
+    <table class="tgt">
+      <thead><tr><th>URL</th><th>Weight</th><th>Count</th><th>RPS</th><th>Errors</th><th>Mean</th><th>P90</th><th>P99</th><th>Max</th></tr></thead>
+      <tbody id="tgtBody"></tbody>
+    </table>
+  </div>
+
+  <div class="log-card tbl-card" id="regCard" style="display:none">
+    <div class="log-head">
+      <div class="log-title">🌍 Regions</div>
+      <div class="badge">per agent region</div>
+    </div>
+    <table class="tgt">
+      <thead><tr><th>Region</th><th>Agents</th><th>Count</th><th>RPS</th><th>Errors</th><th>Mean</th><th>P50</th><th>P90</th><th>P99</th><th>Max</th></tr></thead>
+      <tbody id="regBody"></tbody>
+    </table>
+  </div>
+
+  <div class="log-card tbl-card" id="trendCard" style="display:none">
+    <div class="log-head">
+      <div class="log-title">📈 Trends</div>
+      <select class="inp" id="iTrend" onchange="trendPreset=this.value; fetchTrends()"></select>
+    </div>
+    <div class="chart-body"><div id="cTrend" style="height:240px"></div></div>
+  </div>
+
+  <div class="log-card tbl-card" id="notesCard" style="display:none">
+    <div class="log-head">
+      <div class="log-title">📝 Run Notes</div>
+      <button class="btn-xs" onclick="saveNotes()">Save</button>
+    </div>
+    <textarea class="inp notes" id="rNotes" rows="3" placeholder="Context of this run, e.g. what changed or went on meanwhile"></textarea>
+  </div>
+
+  <div class="log-card tbl-card" id="failCard" style="display:none">
+    <div class="log-head">
+      <div class="log-title">🧪 Failures</div>
+      <button class="btn-xs" onclick="downloadFailures()">Download</button>
+    </div>
+    <div id="failBody"></div>
+  </div>
+
+  <div class="log-card tbl-card">
+    <div class="log-head">
+      <div class="log-title">📟 Live Summary</div>
+      <div class="badge">same as terminal</div>
+    </div>
+    <pre class="tbl-body" id="tblBody">No benchmark yet.</pre>
   </div>
 
   <div class="log-card">
@@ -455,8 +1220,20 @@ const D = {
   rps:         { x:[], v:[] },
   code:        { x:[], s:{} },           // s = { '200': [...], ... }
   concurrency: { x:[], v:[] },
+  throughput:  { x:[], r:[], w:[] },
+  errors:      { x:[], s:[] },           // s[i] = counts of ERR_CLASSES[i]
+  heat:        { x:[], c:[], next:0 },   // c[i] = counts by bucket of tick x[i], next = the tick to poll from
+  pool:        { x:[], open:[], opened:[], closed:[], wait:[] },
+  hist:        { x:[], mn:[], mean:[], mx:[], rps:[], next:0 },   // the run per minute, never trimmed
+  client:      { x:[], cpu:[], mem:[], gor:[], fds:[] },
+  server:      { x:[], cpu:[], mem:[], load:[], rx:[], tx:[] },
 };
 
+// the classes of errorClassNames, in order
+const ERR_CLASSES = ['DNS','Connect Refused','Connect Timeout','TLS','TLS Timeout','Write Timeout','Read Timeout','Request Timeout','Reset','EOF','Other'];
+const ERR_COLORS  = ['#f472b6','#ff6b7a','#fb923c','#fbbf24','#facc15','#c084fc','#a78bfa','#818cf8','#60a5fa','#2dd4a0','#94a3b8'];
+D.errors.s = ERR_CLASSES.map(()=>[]);
+
 function trim(a){ while(a.length > MAX) a.shift(); }
 
 // ────────────────────────────────────────────────────────────────────────────
@@ -498,12 +1275,46 @@ const EC = {
   rps: echarts.init(document.getElementById('cRps')),
   cod: echarts.init(document.getElementById('cCode')),
   con: echarts.init(document.getElementById('cConc')),
+  bw:  echarts.init(document.getElementById('cBw')),
+  err: echarts.init(document.getElementById('cErr')),
+  heat: echarts.init(document.getElementById('cHeat')),
+  pool: echarts.init(document.getElementById('cPool')),
+  client: echarts.init(document.getElementById('cClient')),
+  server: echarts.init(document.getElementById('cServer')),
 };
 
 EC.lat.setOption({ ...mkBase(true),  series:[mkSeries('Min',C.green,false), mkSeries('Mean',C.accent2,true), mkSeries('Max',C.yellow,false)] });
 EC.rps.setOption({ ...mkBase(false), series:[mkSeries('RPS',C.accent,true)] });
 EC.cod.setOption({ ...mkBase(false), series:[mkSeries('200',C.green,false)] });
 EC.con.setOption({ ...mkBase(false), series:[mkSeries('Concurrency',C.yellow,true)] });
+EC.bw.setOption({ ...mkBase(true),   series:[mkSeries('Read',C.green,true), mkSeries('Write',C.accent2,true)] });
+EC.err.setOption({ ...mkBase(true),  series:ERR_CLASSES.map((n,i)=>({ ...mkSeries(n,ERR_COLORS[i],true), stack:'errors' })) });
+// the wait of the arrivals for a free connection on its own axis, in ms
+EC.pool.setOption({ ...mkBase(true), grid:{ ...mkBase(true).grid, right:48 },
+  yAxis:[ mkBase(false).yAxis, { ...mkBase(false).yAxis, name:'wait ms', nameTextStyle:{ color:C.text2, fontSize:10 }, splitLine:{ show:false } } ],
+  series:[mkSeries('Open',C.accent2,true), mkSeries('Opened/s',C.green,false), mkSeries('Closed/s',C.red,false),
+          { ...mkSeries('Wait ms',C.yellow,false), yAxisIndex:1 }] });
+// the CPU in % of all the cores on its own axis
+EC.client.setOption({ ...mkBase(true), grid:{ ...mkBase(true).grid, right:48 },
+  legend:{ ...mkBase(true).legend, selected:{ 'Goroutines':false, 'Open Files':false } },
+  yAxis:[ mkBase(false).yAxis, { ...mkBase(false).yAxis, name:'CPU %', max:100, nameTextStyle:{ color:C.text2, fontSize:10 }, splitLine:{ show:false } } ],
+  series:[{ ...mkSeries('CPU %',C.red,true), yAxisIndex:1 }, mkSeries('Memory MB',C.accent2,false),
+          mkSeries('Goroutines',C.green,false), mkSeries('Open Files',C.yellow,false)] });
+// the network in MB/s on its own axis
+EC.server.setOption({ ...mkBase(true), grid:{ ...mkBase(true).grid, right:48 },
+  legend:{ ...mkBase(true).legend, selected:{ 'Load':false } },
+  yAxis:[ { ...mkBase(false).yAxis, name:'%', nameTextStyle:{ color:C.text2, fontSize:10 } },
+          { ...mkBase(false).yAxis, name:'MB/s', nameTextStyle:{ color:C.text2, fontSize:10 }, splitLine:{ show:false } } ],
+  series:[mkSeries('CPU %',C.red,true), mkSeries('Memory %',C.accent2,false), mkSeries('Load',C.text2,false),
+          { ...mkSeries('Net Rx MB/s',C.green,false), yAxisIndex:1 }, { ...mkSeries('Net Tx MB/s',C.yellow,false), yAxisIndex:1 }] });
+EC.heat.setOption({ ...mkBase(false),
+  grid:{ top:14, right:16, bottom:34, left:70 },
+  tooltip:{ position:'top', backgroundColor:'#252840', borderColor:'#2e3250', textStyle:{ color:'#e2e8f0', fontSize:12 },
+            formatter:p=>p.value[2]+' req '+D.heat.y[p.value[1]]+' at '+D.heat.x[p.value[0]] },
+  xAxis:{ ...mkBase(false).xAxis, boundaryGap:true },
+  yAxis:{ type:'category', data:[], axisLine:{ show:false }, axisTick:{ show:false }, axisLabel:{ color:C.text2, fontSize:11 } },
+  visualMap:{ show:false, min:0, max:1, inRange:{ color:['#1e2235',C.accent,C.accent2,C.yellow] } },
+  series:[{ name:'Requests', type:'heatmap', data:[] }] });
 
 window.addEventListener('resize', ()=>{ Object.values(EC).forEach(c=>c.resize()); });
 
@@ -515,14 +1326,141 @@ function updateLatency(t, mn, mean, mx){
   D.latency.mn.push(mn);  trim(D.latency.mn);
   D.latency.mean.push(mean); trim(D.latency.mean);
   D.latency.mx.push(mx);  trim(D.latency.mx);
-  EC.lat.setOption({ xAxis:{ data:D.latency.x },
-    series:[{name:'Min',data:D.latency.mn},{name:'Mean',data:D.latency.mean},{name:'Max',data:D.latency.mx}] });
+  if(chartView==='realtime') drawLatency(D.latency);
+}
+
+function drawLatency(l){
+  EC.lat.setOption({ xAxis:{ data:l.x }, series:[{name:'Min',data:l.mn},{name:'Mean',data:l.mean},{name:'Max',data:l.mx}] });
 }
 
 function updateRps(t, v){
   D.rps.x.push(t); trim(D.rps.x);
   D.rps.v.push(v); trim(D.rps.v);
-  EC.rps.setOption({ xAxis:{ data:D.rps.x }, series:[{name:'RPS',data:D.rps.v}] });
+  if(chartView==='realtime') drawRps(D.rps.x, D.rps.v);
+}
+
+function drawRps(x, v){
+  EC.rps.setOption({ xAxis:{ data:x }, series:[{name:'RPS',data:v}] });
+}
+
+// the latency and RPS charts show the last seconds, or the whole run per
+// minute polled from /data/history
+let chartView = 'realtime';
+
+function setChartView(v){
+  chartView = v;
+  document.getElementById('tgRt').classList.toggle('on', v==='realtime');
+  document.getElementById('tgRun').classList.toggle('on', v==='run');
+  setText('bLat', v==='run' ? 'per minute' : 'realtime');
+  setText('bRps', v==='run' ? 'per minute' : 'realtime');
+  if(v==='run'){
+    drawLatency(D.hist); drawRps(D.hist.x, D.hist.rps);
+    fetchHistory();
+  } else {
+    drawLatency(D.latency); drawRps(D.rps.x, D.rps.v);
+  }
+}
+
+// warmupLabel is the time of a point of the charts, marking the ones of the
+// warmup, excluded from the summary
+const warmupLabel = p => p.warmup ? p.time+' warmup' : p.time;
+
+// fetchHistory appends the minutes of the run since the last poll
+async function fetchHistory(){
+  const hist = D.hist;
+  if(hist.busy) return;
+  hist.busy = true;
+  try{
+    const h = await (await fetch('/data/history?from='+hist.next)).json();
+    if(hist === D.hist && h.points.length){
+      h.points.forEach(p=>{
+        hist.x.push(warmupLabel(p)); hist.mn.push(p.min); hist.mean.push(p.mean); hist.mx.push(p.max); hist.rps.push(p.rps);
+      });
+      hist.next += h.points.length;
+      if(chartView==='run'){ drawLatency(hist); drawRps(hist.x, hist.rps); }
+    }
+  } catch{}
+  hist.busy = false;
+}
+
+function updateThroughput(t, r, w){
+  D.throughput.x.push(t); trim(D.throughput.x);
+  D.throughput.r.push(r); trim(D.throughput.r);
+  D.throughput.w.push(w); trim(D.throughput.w);
+  EC.bw.setOption({ xAxis:{ data:D.throughput.x }, series:[{name:'Read',data:D.throughput.r},{name:'Write',data:D.throughput.w}] });
+}
+
+function updateErrors(t, v){
+  D.errors.x.push(t); trim(D.errors.x);
+  D.errors.s.forEach((a,i)=>{ a.push(v[i]); trim(a); });
+  EC.err.setOption({ xAxis:{ data:D.errors.x }, series:ERR_CLASSES.map((n,i)=>({name:n,data:D.errors.s[i]})) });
+}
+
+function updatePool(t, v){
+  const p = D.pool;
+  p.x.push(t); trim(p.x);
+  [p.open, p.opened, p.closed, p.wait].forEach((a,i)=>{ a.push(v[i]!=null ? +v[i].toFixed(i ? 2 : 0) : null); trim(a); });
+  EC.pool.setOption({ xAxis:{ data:p.x }, series:[{name:'Open',data:p.open},{name:'Opened/s',data:p.opened},{name:'Closed/s',data:p.closed},{name:'Wait ms',data:p.wait}] });
+}
+
+// updateClient charts the resources of plow itself, warning while they are
+// saturated
+function updateClient(t, v, warning){
+  const c = D.client;
+  c.x.push(t); trim(c.x);
+  [c.cpu, c.mem, c.gor, c.fds].forEach((a,i)=>{ a.push(v[i]!=null ? +v[i].toFixed(i<2 ? 1 : 0) : null); trim(a); });
+  EC.client.setOption({ xAxis:{ data:c.x }, series:[{name:'CPU %',data:c.cpu},{name:'Memory MB',data:c.mem},{name:'Goroutines',data:c.gor},{name:'Open Files',data:c.fds}] });
+  const w = document.getElementById('clientWarn');
+  w.textContent = warning ? '⚠ '+warning : '';
+  w.style.display = warning ? 'block' : 'none';
+}
+
+// updateServer charts the utilization of the target host, the card showing
+// up with the first sample of a probe
+function updateServer(t, v){
+  const s = D.server, card = document.getElementById('serverCard');
+  if(card.style.display==='none'){
+    if(v.every(x=>x==null)) return;
+    card.style.display = '';
+    EC.server.resize();
+  }
+  s.x.push(t); trim(s.x);
+  [s.cpu, s.mem, s.load, s.rx, s.tx].forEach((a,i)=>{ a.push(v[i]); trim(a); });
+  EC.server.setOption({ xAxis:{ data:s.x }, series:[{name:'CPU %',data:s.cpu},{name:'Memory %',data:s.mem},{name:'Load',data:s.load},{name:'Net Rx MB/s',data:s.rx},{name:'Net Tx MB/s',data:s.tx}] });
+}
+
+// fetchHeatmap appends the ticks of the heatmap since the last poll
+async function fetchHeatmap(){
+  const heat = D.heat;
+  if(heat.busy) return;
+  heat.busy = true;
+  try{
+    const h = await (await fetch('/data/heatmap?from='+heat.next)).json();
+    if(heat !== D.heat) return; // the charts were reset meanwhile
+    D.heat.y = h.buckets;
+    h.ticks.forEach(t=>{
+      D.heat.x.push(warmupLabel(t)); trim(D.heat.x);
+      D.heat.c.push(t.counts); trim(D.heat.c);
+    });
+    D.heat.next += h.ticks.length;
+    // the buckets from the fastest to the slowest one seen, not to squash the modes
+    let lo = h.buckets.length, hi = 0, max = 1;
+    D.heat.c.forEach(c=>c.forEach((n,j)=>{ if(n){ lo = Math.min(lo,j); hi = Math.max(hi,j); max = Math.max(max,n); } }));
+    if(lo > hi){ lo = 0; hi = 0; }
+    const data = [];
+    D.heat.c.forEach((c,i)=>{ for(let j = lo; j <= hi; j++) data.push([i, j-lo, c[j]||'-']); });
+    D.heat.y = h.buckets.slice(lo, hi+1);
+    EC.heat.setOption({ xAxis:{ data:D.heat.x }, yAxis:{ data:D.heat.y }, visualMap:{ max }, series:[{ name:'Requests', data }] });
+  } catch{}
+  heat.busy = false;
+}
+
+// fmtBytes prints a size with a binary unit, like the terminal report
+function fmtBytes(n){
+  const u = ['B','KB','MB','GB'];
+  let i = 0;
+  for(; n >= 1024 && i < u.length-1; i++) n /= 1024;
+  return i ? n.toFixed(2)+u[i] : Math.round(n)+u[i];
 }
 
 function updateCode(t, codesObj){
@@ -568,36 +1506,368 @@ function updateConc(t, v){
 // STATE
 // ────────────────────────────────────────────────────────────────────────────
 let running = false, pollTmr = null, progTmr = null;
-let startedAt = 0, targetDur = 10;
+let startedAt = 0, targetDur = 10, runId = null;
+// the requests bounding the run, and the ones completed so far
+let targetReq = 0, reqDone = 0;
+// the token of the quota of the runs, given as ?token= in the page url
+const TOKEN = new URLSearchParams(location.search).get('token');
+// the headers of the requests to the server, with the token, if any
+const authHdrs = (h = {}) => { if(TOKEN) h['Authorization'] = 'Bearer '+TOKEN; return h; };
+// the reads need the token too on a server with credentials, and so do the
+// pages opened from this one, given it as ?token=
+const pageFetch = window.fetch.bind(window);
+window.fetch = (u, o = {}) => pageFetch(u, {...o, headers: authHdrs(o.headers || {})});
+const withToken = u => TOKEN ? u+(u.includes('?') ? '&' : '?')+'token='+encodeURIComponent(TOKEN) : u;
+// ?observe opens the read-only page, e.g. to share the screen, which is also
+// the page of those without the credentials to start a run
+const OBSERVE = new URLSearchParams(location.search).has('observe');
 
 // ────────────────────────────────────────────────────────────────────────────
 // CONTROLS
 // ────────────────────────────────────────────────────────────────────────────
+function formRequest(){
+  return {
+    url: document.getElementById('iUrl').value.trim(),
+    concurrency: parseInt(document.getElementById('iConc').value)||10,
+    duration: parseInt(document.getElementById('iDur').value)||(parseInt(document.getElementById('iReq').value) ? undefined : 10),
+    requests: parseInt(document.getElementById('iReq').value)||undefined,
+    rate: parseFloat(document.getElementById('iRate').value)||undefined,
+    method: document.getElementById('iMeth').value,
+    agents: document.getElementById('iAgents').value.split(',').map(s=>s.trim()).filter(s=>s),
+    probe: document.getElementById('iProbe').value.trim() || undefined,
+    soak: document.getElementById('iSoak').checked || undefined,
+    headers: document.getElementById('iHeaders').value.split('\n').map(s=>s.trim().replace(/\s*:\s*/,':')).filter(s=>s.includes(':')),
+    body: document.getElementById('iBody').value,
+    user: document.getElementById('iUser').value.trim() || undefined,
+    digest: (document.getElementById('iUser').value.trim() && document.getElementById('iAuth').value==='digest') || undefined,
+    cert: document.getElementById('iCert').value.trim(),
+    key: document.getElementById('iKey').value.trim(),
+    preset: document.getElementById('iPreset').value || undefined,
+    cacert: document.getElementById('iCA').value.trim(),
+    insecure: document.getElementById('iInsecure').checked,
+    notes: document.getElementById('iNotes').value.trim() || undefined,
+    har: har ? har.text : undefined,
+    harMode: har ? document.getElementById('iHarMode').value : undefined,
+  };
+}
+
+// ────────────────────────────────────────────────────────────────────────────
+// HAR — a browser capture replayed instead of the target URL
+// ────────────────────────────────────────────────────────────────────────────
+let har = null;
+
+function loadHAR(input){
+  const f = input.files[0];
+  input.value = '';
+  if(!f) return;
+  const rd = new FileReader();
+  rd.onload = ()=>{
+    let n = 0;
+    try{ n = (JSON.parse(rd.result).log.entries||[]).length; } catch{ addLog('er','Invalid HAR file: '+f.name); return; }
+    har = { name: f.name, text: rd.result };
+    setText('harName', f.name+' ('+n+' entries)');
+    document.getElementById('harInfo').style.display = '';
+    document.getElementById('iUrl').disabled = true;
+    addLog('in','HAR loaded: '+f.name+', '+n+' entries');
+  };
+  rd.readAsText(f);
+}
+
+function clearHAR(){
+  har = null;
+  document.getElementById('harInfo').style.display = 'none';
+  document.getElementById('iUrl').disabled = false;
+}
+
+// ────────────────────────────────────────────────────────────────────────────
+// CONFIG — the form as a YAML file, the one of plow run -f FILE
+// ────────────────────────────────────────────────────────────────────────────
+async function exportConfig(){
+  try{
+    const r = await fetch('/config/export',{ method:'POST', headers:authHdrs({'Content-Type':'application/json'}), body: JSON.stringify(formRequest()) });
+    if(!r.ok){ const d = await r.json(); addLog('er','Export failed: '+(d.error||r.statusText)); return; }
+    const a = document.createElement('a');
+    a.href = URL.createObjectURL(await r.blob());
+    a.download = 'plow.yaml';
+    a.click();
+    URL.revokeObjectURL(a.href);
+  } catch(e){ addLog('er','Export failed: '+e.message); }
+}
+
+async function importConfig(input){
+  const f = input.files[0];
+  input.value = '';
+  if(!f) return;
+  try{
+    const r = await fetch('/config/import',{ method:'POST', headers:authHdrs(), body: await f.text() });
+    const d = await r.json();
+    if(!r.ok){ addLog('er','Import failed: '+(d.error||r.statusText)); return; }
+    clearHAR();
+    fillForm(d);
+    if(d.har){
+      har = { name: f.name, text: d.har };
+      setText('harName', f.name);
+      document.getElementById('iHarMode').value = d.harMode||'weighted';
+      document.getElementById('harInfo').style.display = '';
+      document.getElementById('iUrl').disabled = true;
+    }
+    addLog('ok','Imported the config of '+f.name);
+  } catch(e){ addLog('er','Import failed: '+e.message); }
+}
+
+// ────────────────────────────────────────────────────────────────────────────
+// cURL — a copied command converted into the form by the server
+// ────────────────────────────────────────────────────────────────────────────
+function toggleCurl(){
+  const box = document.getElementById('curlBox');
+  box.style.display = box.style.display==='none' ? '' : 'none';
+  if(box.style.display==='') document.getElementById('iCurl').focus();
+}
+
+async function importCurl(){
+  const cmd = document.getElementById('iCurl').value.trim();
+  if(!cmd) return;
+  try{
+    const r = await fetch('/curl',{ method:'POST', headers:authHdrs(), body: cmd });
+    const d = await r.json();
+    if(!r.ok){ addLog('er','cURL: '+(d.error||r.statusText)); return; }
+    clearHAR();
+    const meth = document.getElementById('iMeth');
+    if(![...meth.options].some(o=>o.value===d.method)) meth.add(new Option(d.method));
+    document.getElementById('iUrl').value = d.url;
+    meth.value = d.method;
+    document.getElementById('iHeaders').value = (d.headers||[]).map(h=>h.replace(':',': ')).join('\n');
+    document.getElementById('iBody').value = d.body||'';
+    document.getElementById('iInsecure').checked = !!d.insecure;
+    document.getElementById('iCurl').value = '';
+    toggleCurl();
+    addLog('in','cURL imported: '+d.method+' '+d.url);
+  } catch(e){ addLog('er','Network error: '+e.message); }
+}
+
+function fillForm(q){
+  document.getElementById('iUrl').value = q.url||'';
+  document.getElementById('iConc').value = q.concurrency||10;
+  document.getElementById('iDur').value = q.duration||(q.requests ? '' : 10);
+  document.getElementById('iReq').value = q.requests||'';
+  document.getElementById('iRate').value = q.rate||'';
+  document.getElementById('iMeth').value = q.method||'GET';
+  document.getElementById('iAgents').value = (q.agents||[]).join(', ');
+  document.getElementById('iProbe').value = q.probe||'';
+  document.getElementById('iSoak').checked = !!q.soak;
+  document.getElementById('iHeaders').value = (q.headers||[]).join('\n');
+  document.getElementById('iBody').value = q.body||'';
+  document.getElementById('iUser').value = q.user||'';
+  document.getElementById('iAuth').value = q.digest ? 'digest' : 'basic';
+  document.getElementById('iCert').value = q.cert||'';
+  document.getElementById('iKey').value = q.key||'';
+  document.getElementById('iCA').value = q.cacert||'';
+  document.getElementById('iInsecure').checked = !!q.insecure;
+}
+
 async function startBench(){
-  const url  = document.getElementById('iUrl').value.trim();
-  const conc = parseInt(document.getElementById('iConc').value)||10;
-  const dur  = parseInt(document.getElementById('iDur').value)||10;
-  const meth = document.getElementById('iMeth').value;
+  const q = formRequest();
+  const url = q.url, dur = q.duration;
 
-  if(!url){ addLog('er','Please enter a target URL'); document.getElementById('iUrl').focus(); return; }
-  try{ new URL(url); } catch{ addLog('er','Invalid URL — must start with http:// or https://'); return; }
+  if(!har){
+    if(!url){ addLog('er','Please enter a target URL'); document.getElementById('iUrl').focus(); return; }
+    try{ new URL(url); } catch{ addLog('er','Invalid URL — must start with http:// or https://'); return; }
+  }
 
-  targetDur = dur; startedAt = Date.now();
+  targetDur = dur||0; targetReq = q.requests||0; reqDone = 0; startedAt = Date.now();
   resetCharts();
 
   try{
-    const r = await fetch('/start',{ method:'POST', headers:{'Content-Type':'application/json'},
-      body: JSON.stringify({url,concurrency:conc,duration:dur,method:meth}) });
+    const r = await fetch('/start',{ method:'POST', headers:authHdrs({'Content-Type':'application/json'}),
+      body: JSON.stringify(q) });
     const d = await r.json();
     if(!r.ok){ addLog('er','Error: '+(d.error||r.statusText)); return; }
+    runId = d.id;
+    showNotes(q.notes);
+    document.getElementById('iNotes').value = '';
     setRunning(true);
+    if(!q.agents.length) showAdjust(q.concurrency);
     addLog('in','▶ '+d.desc);
     startPoll(); startProg();
   } catch(e){ addLog('er','Network error: '+e.message); }
 }
 
+// ────────────────────────────────────────────────────────────────────────────
+// ADJUST — the concurrency of the running benchmark, tuned with the slider
+// ────────────────────────────────────────────────────────────────────────────
+function showAdjust(conc){
+  const s = document.getElementById('iAdj');
+  s.max = Math.max(100, conc*4);
+  s.value = conc;
+  setText('adjVal', conc);
+  document.getElementById('adjBox').style.display = '';
+}
+
+async function adjust(){
+  const n = parseInt(document.getElementById('iAdj').value);
+  try{
+    const r = await fetch('/adjust',{ method:'POST', headers:authHdrs({'Content-Type':'application/json'}), body: JSON.stringify({concurrency:n}) });
+    const d = await r.json();
+    if(!r.ok){ addLog('er','Adjust failed: '+(d.error||r.statusText)); return; }
+    addLog('in','Concurrency set to '+d.concurrency);
+  } catch(e){ addLog('er','Adjust failed: '+e.message); }
+}
+
+// ────────────────────────────────────────────────────────────────────────────
+// HELP — tooltips of the fields come from the flags of the binary (/flags),
+// each label linking to the details page of its flag
+// ────────────────────────────────────────────────────────────────────────────
+async function loadFlagHelp(){
+  try{
+    const h = await (await fetch('/flags')).json();
+    const flags = {};
+    [].concat(h.args||[], h.flags||[]).forEach(f=>{ flags[f.name] = f; });
+    document.querySelectorAll('[data-flag]').forEach(el=>{
+      const f = flags[el.dataset.flag];
+      if(!f) return;
+      let tip = f.help;
+      if(f.default && f.default.length) tip += ' (default: '+f.default.join(', ')+')';
+      el.title = tip;
+      const lbl = el.id && document.querySelector('label[for="'+el.id+'"]') || el.closest('label');
+      if(!lbl || lbl.querySelector('.doc')) return;
+      lbl.title = tip;
+      const a = document.createElement('a');
+      a.className = 'doc'; a.href = '/docs/'+encodeURIComponent(f.name); a.target = '_blank'; a.textContent = '?';
+      lbl.appendChild(a);
+    });
+  } catch{}
+}
+
+// ────────────────────────────────────────────────────────────────────────────
+// PRESETS
+// ────────────────────────────────────────────────────────────────────────────
+let presets = [];
+
+async function fetchPresets(){
+  try{
+    presets = await (await fetch('/presets')).json();
+    const sel = document.getElementById('iPreset');
+    const cur = sel.value;
+    sel.innerHTML = '<option value="">— Presets —</option>' +
+      presets.map(p=>'<option value="'+esc(p.name)+'">'+esc(p.name)+'</option>').join('');
+    sel.value = presets.some(p=>p.name===cur) ? cur : '';
+  } catch{}
+}
+
+function loadPreset(){
+  const name = document.getElementById('iPreset').value;
+  const p = presets.find(p=>p.name===name);
+  if(p){ fillForm(p.request); addLog('in','Loaded preset '+p.name); }
+}
+
+async function savePreset(){
+  const q = formRequest();
+  if(!q.url){ addLog('er','Please enter a target URL before saving a preset'); return; }
+  const name = prompt('Preset name', document.getElementById('iPreset').value);
+  if(!name) return;
+  delete q.preset;
+  delete q.notes;
+  const r = await fetch('/presets',{ method:'POST', headers:authHdrs({'Content-Type':'application/json'}),
+    body: JSON.stringify({name, request:q}) });
+  const d = await r.json();
+  if(!r.ok){ addLog('er','Error: '+(d.error||r.statusText)); return; }
+  await fetchPresets();
+  document.getElementById('iPreset').value = d.name;
+  addLog('ok','Saved preset '+d.name);
+}
+
+async function deletePreset(){
+  const name = document.getElementById('iPreset').value;
+  if(!name) return;
+  const r = await fetch('/presets/'+encodeURIComponent(name),{method:'DELETE', headers:authHdrs()});
+  if(r.ok) addLog('in','Deleted preset '+name);
+  await fetchPresets();
+}
+
+async function importPresets(input){
+  const f = input.files[0];
+  input.value = '';
+  if(!f) return;
+  try{
+    const r = await fetch('/presets/import',{ method:'POST', headers:authHdrs({'Content-Type':'application/json'}), body: await f.text() });
+    const d = await r.json();
+    if(!r.ok){ addLog('er','Import failed: '+(d.error||r.statusText)); return; }
+    addLog('ok','Imported '+d.imported+' preset(s) from '+f.name);
+    await fetchPresets();
+  } catch(e){ addLog('er','Import failed: '+e.message); }
+}
+
+// ────────────────────────────────────────────────────────────────────────────
+// TRENDS — p99, RPS and error rate of the finished runs of a preset
+// ────────────────────────────────────────────────────────────────────────────
+let trendPreset = null; // null follows the loaded preset
+
+async function fetchTrends(){
+  try{
+    const runs = await (await fetch('/runs')).json();
+    const names = [...new Set(runs.filter(r=>r.done && r.preset).map(r=>r.preset))].sort();
+    const sel = document.getElementById('iTrend');
+    const cur = trendPreset!==null ? trendPreset : document.getElementById('iPreset').value;
+    sel.innerHTML = '<option value="">All runs</option>' +
+      names.map(n=>'<option value="'+esc(n)+'">'+esc(n)+'</option>').join('');
+    sel.value = names.includes(cur) ? cur : '';
+    const ts = await (await fetch('/trends'+(sel.value ? '?preset='+encodeURIComponent(sel.value) : ''))).json();
+    document.getElementById('trendCard').style.display = ts.length ? '' : 'none';
+    if(!ts.length) return;
+    if(!EC.trend){
+      EC.trend = echarts.init(document.getElementById('cTrend'));
+    }
+    EC.trend.resize();
+    const base = mkBase(true);
+    const axis = (name, extra) => ({ ...base.yAxis, name, nameTextStyle:{ color:C.text2, fontSize:11 }, ...extra });
+    const point = (name, color, idx, data) => ({ ...mkSeries(name, color, false), symbol:'circle', symbolSize:6, smooth:false, yAxisIndex:idx, data });
+    EC.trend.setOption({
+      ...base,
+      grid:{ ...base.grid, right:110 },
+      xAxis:{ ...base.xAxis, boundaryGap:true, data: ts.map(t=>new Date(t.startedAt).toLocaleString()) },
+      yAxis:[
+        axis('P99 ms', {}),
+        axis('RPS', { position:'right', splitLine:{ show:false } }),
+        axis('Err %', { position:'right', offset:54, splitLine:{ show:false }, min:0 }),
+      ],
+      series:[
+        point('P99 (ms)', C.yellow, 0, ts.map(t=>t.p99)),
+        point('RPS', C.accent, 1, ts.map(t=>t.rps)),
+        point('Error %', C.red, 2, ts.map(t=>+(100*t.errorRate).toFixed(3))),
+      ],
+    }, true);
+  } catch{}
+}
+
+// ────────────────────────────────────────────────────────────────────────────
+// NOTES — free text kept with the current run, in its summary and table
+// ────────────────────────────────────────────────────────────────────────────
+function showNotes(text){
+  document.getElementById('notesCard').style.display = runId ? '' : 'none';
+  document.getElementById('rNotes').value = text||'';
+}
+
+async function saveNotes(){
+  if(!runId) return;
+  try{
+    const r = await fetch('/runs/'+runId+'/notes',{ method:'POST', headers:authHdrs(), body: document.getElementById('rNotes').value });
+    const d = await r.json();
+    if(!r.ok){ addLog('er','Notes: '+(d.error||r.statusText)); return; }
+    addLog('ok','Notes saved');
+    fetchTable();
+  } catch(e){ addLog('er','Network error: '+e.message); }
+}
+
+function downloadCSV(){
+  if(runId) window.location = withToken('/runs/'+runId+'/metrics.csv');
+}
+
+function openWatch(){
+  if(runId) window.open(withToken('/watch/'+runId), '_blank');
+}
+
 async function stopBench(){
-  try{ await fetch('/stop',{method:'POST'}); addLog('in','■ Stop signal sent'); }
+  try{ await fetch('/stop',{method:'POST', headers:authHdrs()}); addLog('in','■ Stop signal sent'); }
   catch(e){ addLog('er','Failed to stop: '+e.message); }
 }
 
@@ -605,10 +1875,13 @@ function setRunning(r){
   running = r;
   document.getElementById('btnRun').disabled  = r;
   document.getElementById('btnStop').disabled = !r;
+  document.getElementById('btnCsv').disabled  = !runId;
+  document.getElementById('btnWatch').disabled = !runId;
   document.getElementById('dot').className    = 'dot'+(r?' running':'');
   document.getElementById('hstxt').textContent = r ? 'Running…' : 'Idle';
   document.getElementById('prog').className   = 'prog'+(r?' show':'');
   if(!r) document.getElementById('pfill').style.width = '0%';
+  if(!r) document.getElementById('adjBox').style.display = 'none';
   ['sRps','sAvgRps','sMaxRps','sLat','sMin','sMax'].forEach(id=>
     document.getElementById(id).classList.toggle('on',r));
 }
@@ -627,10 +1900,12 @@ async function pollAll(){
   try{
     const r = await fetch('/status');
     const s = await r.json();
+    if(s.requests){ targetReq = s.requests; reqDone = s.completed||0; }
     if(!s.running && running){
       await fetchViews();
       setRunning(false); stopPoll(); stopProg();
       addLog('ok','✓ Benchmark completed!');
+      fetchTrends();
       return;
     }
   } catch{}
@@ -638,7 +1913,64 @@ async function pollAll(){
 }
 
 async function fetchViews(){
-  await Promise.all(['latency','rps','code','concurrency'].map(v=>fetchView(v)));
+  await Promise.all(['latency','rps','code','concurrency','throughput','errors','pool','client','server'].map(v=>fetchView(v)).concat(fetchTable(), fetchHeatmap(), fetchHistory()));
+}
+
+async function fetchTable(){
+  if(!runId) return;
+  try{
+    const r = await fetch('/runs/'+runId+'/table');
+    if(r.ok) document.getElementById('tblBody').textContent = await r.text();
+  } catch{}
+  fetchTargets();
+  fetchFailures();
+}
+
+// fetchFailures lists the failed requests of the run kept with their
+// responses, unfolded one by one
+async function fetchFailures(){
+  const card = document.getElementById('failCard');
+  try{
+    const r = await fetch('/runs/'+runId+'/failures');
+    if(!r.ok) return;
+    const fs = await r.json();
+    card.style.display = fs.length ? '' : 'none';
+    const body = document.getElementById('failBody');
+    if(body.childElementCount === fs.length) return;
+    const msg = m => esc(m.header)+(m.body ? '\n\n'+esc(m.body)+(m.truncated ? '\n…' : '') : '');
+    body.innerHTML = fs.map(f=>
+      '<details class="fail"><summary><span class="ts">'+esc(new Date(f.time).toLocaleTimeString())+'</span>'+
+      '<span class="er">'+esc(f.reason)+'</span>'+esc(f.latency)+'</summary>'+
+      '<pre class="tbl-body">'+msg(f.request)+(f.response ? '\n\n────────\n\n'+msg(f.response) : '')+'</pre></details>').join('');
+  } catch{}
+}
+
+function downloadFailures(){
+  if(runId) window.location = withToken('/runs/'+runId+'/failures?download');
+}
+
+// fetchTargets fills the per-url table of a run with several urls, and the
+// per-region one of a run on agents labeled with regions
+async function fetchTargets(){
+  const card = document.getElementById('tgtCard');
+  try{
+    const r = await fetch('/runs/'+runId+'/summary.json');
+    if(!r.ok) return;
+    const s = await r.json();
+    const ts = s.Targets || [];
+    card.style.display = ts.length ? '' : 'none';
+    const unit = s.LatencyUnit || '';
+    const lat = v => v.toFixed(2)+unit;
+    const errs = t => '<td'+(t.Errors?' class="er"':'')+'>'+t.Errors+(t.Count?' ('+(100*t.Errors/t.Count).toFixed(1)+'%)':'')+'</td>';
+    document.getElementById('tgtBody').innerHTML = ts.map(t=>
+      '<tr><td>'+esc(t.URL)+'</td><td>'+(t.Weight||'—')+'</td><td>'+t.Count+'</td><td>'+t.RPS.toFixed(1)+'</td>'+errs(t)+
+      '<td>'+lat(t.Mean)+'</td><td>'+lat(t.P90)+'</td><td>'+lat(t.P99)+'</td><td>'+lat(t.Max)+'</td></tr>').join('');
+    const rs = s.Regions || [];
+    document.getElementById('regCard').style.display = rs.length ? '' : 'none';
+    document.getElementById('regBody').innerHTML = rs.map(t=>
+      '<tr><td>'+esc(t.Region)+'</td><td>'+t.Agents+'</td><td>'+t.Count+'</td><td>'+t.RPS.toFixed(1)+'</td>'+errs(t)+
+      '<td>'+lat(t.Mean)+'</td><td>'+lat(t.P50)+'</td><td>'+lat(t.P90)+'</td><td>'+lat(t.P99)+'</td><td>'+lat(t.Max)+'</td></tr>').join('');
+  } catch{}
 }
 
 async function fetchView(view){
@@ -646,7 +1978,7 @@ async function fetchView(view){
     const r = await fetch('/data/'+view);
     if(!r.ok) return;
     const d = await r.json();
-    const t = d.time, v = d.values;
+    const t = warmupLabel(d), v = d.values;
 
     if(view==='latency'){
       const [mn,mean,mx, mnAll,meanAll,mxAll] = [v[0],v[1],v[2], v[3],v[4],v[5]];
@@ -666,6 +1998,20 @@ async function fetchView(view){
       updateCode(t, v[0]);
     } else if(view==='concurrency'){
       updateConc(t, v[0]);
+    } else if(view==='errors'){
+      updateErrors(t, v);
+    } else if(view==='pool'){
+      updatePool(t, v);
+    } else if(view==='client'){
+      updateClient(t, v, d.warning);
+    } else if(view==='server'){
+      updateServer(t, v);
+    } else if(view==='throughput'){
+      const [rd, wr, avgRd, avgWr, size] = [v[0], v[1], v[2], v[3], v[4]];
+      updateThroughput(t, rd!=null ? +rd.toFixed(3) : null, wr!=null ? +wr.toFixed(3) : null);
+      setText('vRead',  avgRd!=null ? avgRd.toFixed(2) : '—');
+      setText('vWrite', avgWr!=null ? avgWr.toFixed(2) : '—');
+      setText('vSize',  size !=null ? fmtBytes(size)   : '—');
     }
   } catch{}
 }
@@ -677,9 +2023,15 @@ function startProg(){
   if(progTmr) clearInterval(progTmr);
   progTmr = setInterval(()=>{
     const e = (Date.now()-startedAt)/1000;
-    const p = Math.min(100,(e/targetDur)*100);
+    let p = targetDur ? Math.min(100,(e/targetDur)*100) : 0;
+    let t = Math.floor(e)+'s'+(targetDur ? ' / '+targetDur+'s' : '');
+    if(targetReq){
+      // the first of the requests and the duration ends the run
+      p = Math.max(p, Math.min(100,(reqDone/targetReq)*100));
+      t = reqDone+' / '+targetReq+' req · '+t;
+    }
     document.getElementById('pfill').style.width = p+'%';
-    document.getElementById('ptime').textContent = Math.floor(e)+'s / '+targetDur+'s';
+    document.getElementById('ptime').textContent = t;
   },200);
 }
 function stopProg(){ if(progTmr) clearInterval(progTmr); progTmr=null; }
@@ -692,13 +2044,28 @@ function resetCharts(){
   D.rps         = { x:[], v:[] };
   D.code        = { x:[], s:{} };
   D.concurrency = { x:[], v:[] };
+  D.throughput  = { x:[], r:[], w:[] };
+  D.errors      = { x:[], s:ERR_CLASSES.map(()=>[]) };
+  D.heat        = { x:[], c:[], next:0 };
+  D.pool        = { x:[], open:[], opened:[], closed:[], wait:[] };
+  D.hist        = { x:[], mn:[], mean:[], mx:[], rps:[], next:0 };
+  D.client      = { x:[], cpu:[], mem:[], gor:[], fds:[] };
+  D.server      = { x:[], cpu:[], mem:[], load:[], rx:[], tx:[] };
 
   EC.lat.setOption({ xAxis:{data:[]}, series:[{name:'Min',data:[]},{name:'Mean',data:[]},{name:'Max',data:[]}] }, false);
   EC.rps.setOption({ xAxis:{data:[]}, series:[{name:'RPS',data:[]}] }, false);
   EC.cod.setOption({ xAxis:{data:[]}, series:[{name:'200',data:[]}] }, false);
   EC.con.setOption({ xAxis:{data:[]}, series:[{name:'Concurrency',data:[]}] }, false);
-
-  ['vRps','vAvgRps','vMaxRps','vLat','vMin','vMax'].forEach(id=>setText(id,'—'));
+  EC.bw.setOption({ xAxis:{data:[]}, series:[{name:'Read',data:[]},{name:'Write',data:[]}] }, false);
+  EC.err.setOption({ xAxis:{data:[]}, series:ERR_CLASSES.map(n=>({name:n,data:[]})) }, false);
+  EC.heat.setOption({ xAxis:{data:[]}, yAxis:{data:[]}, series:[{name:'Requests',data:[]}] }, false);
+  EC.pool.setOption({ xAxis:{data:[]}, series:[{name:'Open',data:[]},{name:'Opened/s',data:[]},{name:'Closed/s',data:[]},{name:'Wait ms',data:[]}] }, false);
+  EC.client.setOption({ xAxis:{data:[]}, series:[{name:'CPU %',data:[]},{name:'Memory MB',data:[]},{name:'Goroutines',data:[]},{name:'Open Files',data:[]}] }, false);
+  document.getElementById('clientWarn').style.display = 'none';
+  EC.server.setOption({ xAxis:{data:[]}, series:[{name:'CPU %',data:[]},{name:'Memory %',data:[]},{name:'Load',data:[]},{name:'Net Rx MB/s',data:[]},{name:'Net Tx MB/s',data:[]}] }, false);
+  document.getElementById('serverCard').style.display = 'none';
+
+  ['vRps','vAvgRps','vMaxRps','vLat','vMin','vMax','vRead','vWrite','vSize'].forEach(id=>setText(id,'—'));
 }
 
 function setText(id, txt){ document.getElementById(id).textContent = txt; }
@@ -721,16 +2088,61 @@ function clearLog(){ document.getElementById('logBody').innerHTML = ''; }
 // ON LOAD — check if benchmark already running (e.g. page refresh)
 // ────────────────────────────────────────────────────────────────────────────
 window.addEventListener('load', async ()=>{
+  fetchPresets();
+  loadFlagHelp();
+  fetchTrends();
+  try{
+    const a = await (await fetch('/access',{ headers:authHdrs() })).json();
+    if(OBSERVE || !a.write) observe(!OBSERVE && a.basic);
+  } catch{}
   try{
     const r = await fetch('/status');
     const s = await r.json();
+    runId = s.runId || null;
+    if(runId) fetchNotes();
     if(s.running){
-      setRunning(true);
-      addLog('in','Benchmark in progress: '+s.desc);
-      startPoll(); startProg();
+      attachRun(s);
+    } else {
+      fetchTable();
     }
   } catch{}
 });
+
+// attachRun follows the run of status s started before the page was loaded,
+// or by someone else
+function attachRun(s){
+  targetDur = s.duration||0; targetReq = s.requests||0; reqDone = s.completed||0;
+  startedAt = Date.now() - (s.elapsed||0)*1000;
+  setRunning(true);
+  if(s.concurrency) showAdjust(s.concurrency);
+  addLog('in','Benchmark in progress: '+s.desc);
+  startPoll(); startProg();
+}
+
+async function fetchNotes(){
+  try{
+    const run = (await (await fetch('/runs')).json()).find(x=>x.id===runId);
+    showNotes(run && run.notes);
+  } catch{}
+}
+
+// observe turns the page into the read-only one of an observer, which
+// follows each run started on the server
+function observe(canSignIn){
+  document.body.classList.add('observer');
+  document.getElementById('rNotes').readOnly = true;
+  document.getElementById('signIn').style.display = canSignIn ? '' : 'none';
+  setInterval(async ()=>{
+    try{
+      const s = await (await fetch('/status')).json();
+      if(!s.running || s.runId === runId) return;
+      runId = s.runId;
+      resetCharts();
+      fetchNotes();
+      attachRun(s);
+    } catch{}
+  }, 2000);
+}
 </script>
 </body>
 </html>`
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+)
+
+// guiAuth are the credentials of --gui-user, --gui-token and
+// --gui-observer-token, one of which the requests to the GUI server must
+// present, but for its static pages
+type guiAuth struct {
+	users     map[string]string // password by user, as Basic auth
+	tokens    map[string]bool   // as bearer tokens
+	observers map[string]bool   // as bearer tokens only reading the runs
+}
+
+// guiRole is what the credentials of a request may do on the GUI server
+type guiRole int
+
+const (
+	guiNobody   guiRole = iota // no valid credentials
+	guiObserver                // reads the status, runs and their data
+	guiWriter                  // starts, stops and configures the runs too
+)
+
+// parseGUIAuth parses the `USER:PASSWORD` of users, nil without credentials
+func parseGUIAuth(users, tokens, observers []string) (*guiAuth, error) {
+	if len(users) == 0 && len(tokens) == 0 && len(observers) == 0 {
+		return nil, nil
+	}
+	a := &guiAuth{users: make(map[string]string), tokens: make(map[string]bool), observers: make(map[string]bool)}
+	for _, s := range users {
+		user, password, ok := strings.Cut(s, ":")
+		if !ok || user == "" || password == "" {
+			return nil, fmt.Errorf("gui user %q is not a USER:PASSWORD", s)
+		}
+		a.users[user] = password
+	}
+	for _, t := range tokens {
+		if t = strings.TrimSpace(t); t == "" {
+			return nil, fmt.Errorf("gui token is empty")
+		}
+		a.tokens[t] = true
+	}
+	for _, t := range observers {
+		if t = strings.TrimSpace(t); t == "" {
+			return nil, fmt.Errorf("gui observer token is empty")
+		}
+		a.observers[t] = true
+	}
+	return a, nil
+}
+
+// role tells what the credentials of ctx may do, the tokens with a quota
+// being writers too
+func (a *guiAuth) role(ctx *fasthttp.RequestCtx, quotas guiQuotas) guiRole {
+	auth := string(ctx.Request.Header.Peek("Authorization"))
+	if enc, ok := strings.CutPrefix(auth, "Basic "); ok {
+		dec, err := base64.StdEncoding.DecodeString(strings.TrimSpace(enc))
+		if err != nil {
+			return guiNobody
+		}
+		user, password, _ := strings.Cut(string(dec), ":")
+		want, ok := a.users[user]
+		if ok && subtle.ConstantTimeCompare([]byte(password), []byte(want)) == 1 {
+			return guiWriter
+		}
+		return guiNobody
+	}
+	if token := requestToken(ctx); token != "" {
+		if _, ok := quotas[token]; ok {
+			return guiWriter
+		}
+		for t := range a.tokens {
+			if subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+				return guiWriter
+			}
+		}
+		for t := range a.observers {
+			if subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+				return guiObserver
+			}
+		}
+	}
+	return guiNobody
+}
+
+// guiOpenPath tells the static pages of the GUI server, open without
+// credentials to sign in, the data they show being behind them
+func guiOpenPath(path string) bool {
+	return path == "/" || path == "/access" || path == "/docs" || strings.HasPrefix(path, "/docs/") ||
+		strings.HasPrefix(path, "/watch/") || strings.HasPrefix(path, "/echarts/statics/")
+}
+
+// guiObserverPath tells the routes an observer may use: reading the status,
+// the runs and their data, not the presets which may hold credentials
+func guiObserverPath(method, path string) bool {
+	if method != "GET" {
+		return false
+	}
+	return path == "/status" || path == "/runs" || path == "/trends" || path == "/flags" ||
+		strings.HasPrefix(path, "/runs/") || strings.HasPrefix(path, "/data/") || strings.HasPrefix(path, "/watch/")
+}
+
+// refuse answers a request without credentials, challenging browsers for
+// the Basic ones
+func (a *guiAuth) refuse(ctx *fasthttp.RequestCtx) {
+	if len(a.users) > 0 {
+		ctx.Response.Header.Set("WWW-Authenticate", `Basic realm="plow"`)
+	}
+	ctx.SetStatusCode(fasthttp.StatusUnauthorized)
+	ctx.SetContentType("application/json")
+	ctx.WriteString(`{"error":"credentials are required by this server"}`)
+}
+
+// forbid answers a request of an observer to a route changing the server
+func (a *guiAuth) forbid(ctx *fasthttp.RequestCtx) {
+	ctx.SetStatusCode(fasthttp.StatusForbidden)
+	ctx.SetContentType("application/json")
+	ctx.WriteString(`{"error":"observers can only read the runs of this server"}`)
+}
+
+// handleAccess tells the page whether it has the credentials of the server,
+// the page of the others only offering to sign in. With
+// ?login=1, the browser is asked for the Basic auth credentials, and sent
+// back to the page once they are valid.
+func (g *GUIServer) handleAccess(ctx *fasthttp.RequestCtx) {
+	role := guiWriter
+	if g.auth != nil {
+		g.mu.Lock()
+		quotas := g.quotas
+		g.mu.Unlock()
+		role = g.auth.role(ctx, quotas)
+	}
+	write := role == guiWriter
+	if ctx.QueryArgs().Has("login") {
+		if role == guiNobody {
+			g.auth.refuse(ctx)
+			return
+		}
+		ctx.Redirect("/", fasthttp.StatusFound)
+		return
+	}
+	ctx.SetContentType("application/json")
+	json.NewEncoder(ctx).Encode(map[string]bool{"write": write, "basic": g.auth != nil && len(g.auth.users) > 0})
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+// TestGUIAuthRoles checks the routes each credential may use on a GUI
+// server with a writer and an observer token
+func TestGUIAuthRoles(t *testing.T) {
+	auth, err := parseGUIAuth(nil, []string{"ci-bot"}, []string{"stakeholders"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := NewGUIServer(nil)
+	g.auth = auth
+
+	tests := []struct {
+		token  string
+		method string
+		path   string
+		want   int
+	}{
+		{"", "GET", "/status", fasthttp.StatusUnauthorized},
+		{"", "POST", "/start", fasthttp.StatusUnauthorized},
+		{"nope", "GET", "/status", fasthttp.StatusUnauthorized},
+		{"ci-bot", "GET", "/status", fasthttp.StatusOK},
+		{"ci-bot", "POST", "/stop", fasthttp.StatusOK},
+
+		{"stakeholders", "GET", "/status", fasthttp.StatusOK},
+		{"stakeholders", "GET", "/data/history", fasthttp.StatusOK},
+		{"stakeholders", "POST", "/start", fasthttp.StatusForbidden},
+		{"stakeholders", "POST", "/stop", fasthttp.StatusForbidden},
+		{"stakeholders", "POST", "/adjust", fasthttp.StatusForbidden},
+		{"stakeholders", "POST", "/reload", fasthttp.StatusForbidden},
+		{"stakeholders", "POST", "/curl", fasthttp.StatusForbidden},
+		{"stakeholders", "POST", "/config/import", fasthttp.StatusForbidden},
+		{"stakeholders", "GET", "/config/export", fasthttp.StatusForbidden},
+		{"stakeholders", "POST", "/presets", fasthttp.StatusForbidden},
+		{"stakeholders", "GET", "/presets/export", fasthttp.StatusForbidden},
+		{"stakeholders", "POST", "/presets/import", fasthttp.StatusForbidden},
+		{"stakeholders", "DELETE", "/presets/smoke", fasthttp.StatusForbidden},
+		{"stakeholders", "POST", "/runs/1/notes", fasthttp.StatusForbidden},
+	}
+	for _, tt := range tests {
+		var ctx fasthttp.RequestCtx
+		ctx.Request.Header.SetMethod(tt.method)
+		ctx.Request.SetRequestURI(tt.path)
+		if tt.token != "" {
+			ctx.Request.Header.Set("Authorization", "Bearer "+tt.token)
+		}
+		g.Handler(&ctx)
+		if got := ctx.Response.StatusCode(); got != tt.want {
+			t.Errorf("%s %s with %q = %d, want %d", tt.method, tt.path, tt.token, got, tt.want)
+		}
+	}
+}
+
+// TestGUIAccessObserver checks that /access tells the page of an observer
+// token it can't write
+func TestGUIAccessObserver(t *testing.T) {
+	auth, err := parseGUIAuth(nil, []string{"ci-bot"}, []string{"stakeholders"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := NewGUIServer(nil)
+	g.auth = auth
+
+	for token, want := range map[string]string{
+		"ci-bot":       `{"basic":false,"write":true}`,
+		"stakeholders": `{"basic":false,"write":false}`,
+	} {
+		var ctx fasthttp.RequestCtx
+		ctx.Request.SetRequestURI("/access?token=" + token)
+		g.Handler(&ctx)
+		if got := string(ctx.Response.Body()); got != want+"\n" {
+			t.Errorf("/access with %q = %s, want %s", token, got, want)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/valyala/fasthttp"
+)
+
+// guiConfig is the --gui-config file of the settings of a GUI server, read
+// again on SIGHUP or a POST /reload without stopping its runs. The presets
+// are the ones of an exported preset file.
+type guiConfig struct {
+	Quotas   []string  `json:"quotas"`
+	Presets  []*Preset `json:"presets"`
+	Webhooks []string  `json:"webhooks"`
+}
+
+func loadGUIConfig(path string) (*guiConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg guiConfig
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	for _, p := range cfg.Presets {
+		if p == nil {
+			continue
+		}
+		if err := p.validate(); err != nil {
+			return nil, fmt.Errorf("%s: %s", path, err)
+		}
+	}
+	return &cfg, nil
+}
+
+// reload reads the quotas and webhooks of the flags and of the --gui-config
+// file, if any, along its presets, which replace the ones it loaded before.
+// The current settings are kept when the file is invalid, and the runs
+// already started keep the quota they were started with.
+func (g *GUIServer) reload() error {
+	specs := append([]string(nil), g.quotaSpecs...)
+	hookSpecs := append([]string(nil), g.webhookSpecs...)
+	var presets []*Preset
+	if g.configPath != "" {
+		cfg, err := loadGUIConfig(g.configPath)
+		if err != nil {
+			return err
+		}
+		specs = append(specs, cfg.Quotas...)
+		hookSpecs = append(hookSpecs, cfg.Webhooks...)
+		presets = cfg.Presets
+	}
+	quotas, err := parseGUIQuotas(specs)
+	if err != nil {
+		return err
+	}
+	webhooks, err := parseWebhooks(hookSpecs)
+	if err != nil {
+		return err
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.quotas, g.webhooks = quotas, webhooks
+	for name := range g.configPresets {
+		delete(g.presets, name)
+	}
+	g.configPresets = make(map[string]bool, len(presets))
+	for _, p := range presets {
+		if p != nil {
+			g.presets[p.Name] = p
+			g.configPresets[p.Name] = true
+		}
+	}
+	return nil
+}
+
+// reloadOnHUP reloads the settings on each SIGHUP
+func (g *GUIServer) reloadOnHUP() {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGHUP)
+	for range sigs {
+		if err := g.reload(); err != nil {
+			fmt.Fprintf(os.Stderr, "⚠️  Reload failed, keeping the current settings: %s\n", err)
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "🔄 Reloaded %s\n", g.configPath)
+	}
+}
+
+// handleReload reloads the settings, only for the tokens with a quota of
+// their own when there are some
+func (g *GUIServer) handleReload(ctx *fasthttp.RequestCtx) {
+	ctx.SetContentType("application/json")
+	token := requestToken(ctx)
+	g.mu.Lock()
+	allowed := g.quotas.allowsReload(token)
+	g.mu.Unlock()
+	if !allowed {
+		ctx.SetStatusCode(fasthttp.StatusForbidden)
+		json.NewEncoder(ctx).Encode(map[string]string{"error": "a token with a quota is required to reload the settings"})
+		return
+	}
+	if err := g.reload(); err != nil {
+		ctx.SetStatusCode(400)
+		json.NewEncoder(ctx).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	g.mu.Lock()
+	quotas, presets := len(g.quotas), len(g.configPresets)
+	g.mu.Unlock()
+	json.NewEncoder(ctx).Encode(map[string]interface{}{"status": "reloaded", "quotas": quotas, "presets": presets})
+}
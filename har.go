@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// HAR replay modes: identical requests merged into a weighted mix, or every
+// entry replayed in the order of the capture by each connection
+const (
+	harWeighted = "weighted"
+	harSequence = "sequence"
+)
+
+// targetRequest is the method, headers and body of the requests of one
+// target, e.g. one entry of a HAR file, instead of the ones of the flags
+type targetRequest struct {
+	Method  string   `json:"method"`
+	Headers []string `json:"headers,omitempty"`
+	Body    []byte   `json:"body,omitempty"`
+}
+
+type harLog struct {
+	Log struct {
+		Entries []struct {
+			Request struct {
+				Method  string `json:"method"`
+				URL     string `json:"url"`
+				Headers []struct {
+					Name  string `json:"name"`
+					Value string `json:"value"`
+				} `json:"headers"`
+				PostData *struct {
+					MimeType string `json:"mimeType"`
+					Text     string `json:"text"`
+					Encoding string `json:"encoding"`
+				} `json:"postData"`
+			} `json:"request"`
+		} `json:"entries"`
+	} `json:"log"`
+}
+
+// harSkippedHeaders are set by the client, or only mean something on the
+// connection of the capture
+var harSkippedHeaders = map[string]bool{
+	"host": true, "content-length": true, "connection": true, "keep-alive": true,
+	"transfer-encoding": true, "upgrade": true, "te": true, "http2-settings": true, "proxy-connection": true,
+}
+
+// harScenario is the benchmark of a HAR file: its urls, their weights (nil
+// in sequence mode) and their requests
+type harScenario struct {
+	urls     []string
+	weights  []int
+	requests []*targetRequest
+}
+
+// parseHAR converts the http(s) entries of a HAR export whose url matches
+// include, if set, into a scenario of the given mode
+func parseHAR(data []byte, mode string, include *regexp.Regexp) (*harScenario, error) {
+	var har harLog
+	if err := json.Unmarshal(data, &har); err != nil {
+		return nil, fmt.Errorf("invalid HAR file: %s", err)
+	}
+	sc := &harScenario{}
+	index := make(map[string]int)
+	for _, e := range har.Log.Entries {
+		r := e.Request
+		if !strings.HasPrefix(r.URL, "http://") && !strings.HasPrefix(r.URL, "https://") {
+			continue
+		}
+		if include != nil && !include.MatchString(r.URL) {
+			continue
+		}
+		tr := &targetRequest{Method: strings.ToUpper(r.Method)}
+		for _, h := range r.Headers {
+			if strings.HasPrefix(h.Name, ":") || harSkippedHeaders[strings.ToLower(h.Name)] {
+				continue
+			}
+			tr.Headers = append(tr.Headers, h.Name+":"+h.Value)
+		}
+		if r.PostData != nil && r.PostData.Text != "" {
+			tr.Body = []byte(r.PostData.Text)
+			if r.PostData.Encoding == "base64" {
+				body, err := base64.StdEncoding.DecodeString(r.PostData.Text)
+				if err != nil {
+					return nil, fmt.Errorf("invalid HAR body of %s: %s", r.URL, err)
+				}
+				tr.Body = body
+			}
+		}
+		if mode == harWeighted {
+			key := tr.Method + " " + r.URL + "\n" + string(tr.Body)
+			if i, ok := index[key]; ok {
+				sc.weights[i]++
+				continue
+			}
+			index[key] = len(sc.urls)
+			sc.weights = append(sc.weights, 1)
+		}
+		sc.urls = append(sc.urls, r.URL)
+		sc.requests = append(sc.requests, tr)
+	}
+	if len(sc.urls) == 0 {
+		return nil, fmt.Errorf("no http(s) request to replay in the HAR file")
+	}
+	return sc, nil
+}
+
+// labels name the requests in the report, e.g. "POST https://host/api"
+func (sc *harScenario) labels() []string {
+	labels := make([]string, len(sc.urls))
+	for i, u := range sc.urls {
+		labels[i] = sc.requests[i].Method + " " + u
+	}
+	return labels
+}
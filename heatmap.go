@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// heatmapBounds are the upper bounds of the latency buckets of the heatmap,
+// the last bucket holding the latencies above them
+var heatmapBounds = []time.Duration{
+	100 * time.Microsecond, 200 * time.Microsecond, 500 * time.Microsecond,
+	time.Millisecond, 2 * time.Millisecond, 5 * time.Millisecond,
+	10 * time.Millisecond, 20 * time.Millisecond, 50 * time.Millisecond,
+	100 * time.Millisecond, 200 * time.Millisecond, 500 * time.Millisecond,
+	time.Second, 2 * time.Second, 5 * time.Second, 10 * time.Second,
+}
+
+// heatmapBucket is the index of the bucket of latency d
+func heatmapBucket(d time.Duration) int {
+	return sort.Search(len(heatmapBounds), func(i int) bool { return d <= heatmapBounds[i] })
+}
+
+// heatmapLabels are the labels of the buckets, by their upper bound
+func heatmapLabels() []string {
+	labels := make([]string, 0, len(heatmapBounds)+1)
+	for _, b := range heatmapBounds {
+		labels = append(labels, "≤"+b.String())
+	}
+	return append(labels, ">"+heatmapBounds[len(heatmapBounds)-1].String())
+}
+
+// heatmapData is the count of the requests of each tick by latency bucket,
+// so that the modes of a multi-modal latency, such as cache hits and
+// misses, stay apart
+type heatmapData struct {
+	Buckets []string      `json:"buckets"`
+	From    int           `json:"from"` // index of the first tick, the next poll asking for From+len(Ticks)
+	Ticks   []heatmapTick `json:"ticks"`
+}
+
+type heatmapTick struct {
+	Time   string  `json:"time"`
+	Counts []int64 `json:"counts"`
+	Warmup bool    `json:"warmup,omitempty"` // holding records of the warmup
+}
+
+// handleHeatmap serves the heatmap of the ticks of the current run from the
+// index ?from=N
+func (g *GUIServer) handleHeatmap(ctx *fasthttp.RequestCtx) {
+	ctx.SetContentType("application/json")
+	from := ctx.QueryArgs().GetUintOrZero("from")
+	g.mu.Lock()
+	report := g.report
+	g.mu.Unlock()
+	data := heatmapData{Buckets: heatmapLabels(), From: from, Ticks: []heatmapTick{}}
+	if report != nil {
+		for _, t := range report.Ticks(from) {
+			data.Ticks = append(data.Ticks, heatmapTick{Time: t.Time.Format(timeFormat), Counts: t.Buckets, Warmup: t.Warmup})
+		}
+	}
+	json.NewEncoder(ctx).Encode(&data)
+}
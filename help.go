@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"gopkg.in/alecthomas/kingpin.v3-unstable"
+)
+
+// FlagHelp describes a single flag or argument of the command line
+type FlagHelp struct {
+	Name        string   `json:"name"`
+	Short       string   `json:"short,omitempty"`
+	Help        string   `json:"help"`
+	Type        string   `json:"type"`
+	Default     []string `json:"default,omitempty"`
+	PlaceHolder string   `json:"placeholder,omitempty"`
+	Repeatable  bool     `json:"repeatable,omitempty"`
+	Negatable   bool     `json:"negatable,omitempty"`
+	Required    bool     `json:"required,omitempty"`
+	Envar       string   `json:"envar,omitempty"`
+}
+
+// CLIHelp is the structured form of `plow --help`
+type CLIHelp struct {
+	Name    string      `json:"name"`
+	Version string      `json:"version"`
+	Help    string      `json:"help"`
+	Flags   []*FlagHelp `json:"flags"`
+	Args    []*FlagHelp `json:"args"`
+}
+
+var valueTypeNames = map[string]string{
+	"rateFlagValue":     "rate",
+	"durationValue":     "duration",
+	"existingFileValue": "file",
+	"stringsValue":      "string",
+	"int64Value":        "int",
+	"intValue":          "int",
+}
+
+func flagType(c *kingpin.ClauseModel) string {
+	if c.IsBoolFlag() {
+		return "bool"
+	}
+	name := fmt.Sprintf("%T", c.Value)
+	name = name[strings.LastIndex(name, ".")+1:]
+	if t, ok := valueTypeNames[name]; ok {
+		return t
+	}
+	return strings.TrimSuffix(name, "Value")
+}
+
+func newFlagHelp(c *kingpin.ClauseModel) *FlagHelp {
+	fh := &FlagHelp{
+		Name:        c.Name,
+		Help:        c.Help,
+		Type:        flagType(c),
+		Default:     c.Default,
+		PlaceHolder: c.PlaceHolder,
+		Repeatable:  c.Cumulative,
+		Negatable:   c.IsNegatable(),
+		Required:    c.Required,
+		Envar:       c.Envar,
+	}
+	if c.Short != 0 {
+		fh.Short = string(c.Short)
+	}
+	if fh.Envar == "" && c.Name != "help" {
+		fh.Envar = "PLOW_" + strings.ToUpper(strings.ReplaceAll(c.Name, "-", "_"))
+	}
+	return fh
+}
+
+func cliHelp(app *kingpin.Application) *CLIHelp {
+	model := app.Model()
+	h := &CLIHelp{Name: model.Name, Version: model.Version, Help: model.Help}
+	for _, f := range model.Flags {
+		if f.Hidden {
+			continue
+		}
+		h.Flags = append(h.Flags, newFlagHelp(f))
+	}
+	for _, a := range model.Args {
+		h.Args = append(h.Args, newFlagHelp(a))
+	}
+	return h
+}
+
+func writeHelpJSON(w io.Writer, app *kingpin.Application) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(cliHelp(app))
+}
+
+func writeFishCompletion(w io.Writer, app *kingpin.Application) {
+	name := app.Model().Name
+	for _, f := range cliHelp(app).Flags {
+		line := fmt.Sprintf("complete -c %s -l %s", name, f.Name)
+		if f.Short != "" {
+			line += " -s " + f.Short
+		}
+		switch f.Type {
+		case "bool":
+		case "file":
+			line += " -r -F"
+		default:
+			line += " -r -f"
+		}
+		line += " -d " + fishQuote(f.Help)
+		fmt.Fprintln(w, line)
+		if f.Negatable {
+			fmt.Fprintf(w, "complete -c %s -l no-%s -d %s\n", name, f.Name, fishQuote(f.Help))
+		}
+	}
+}
+
+func fishQuote(s string) string {
+	return "'" + strings.ReplaceAll(strings.ReplaceAll(s, `\`, `\\`), "'", `\'`) + "'"
+}
+
+func printHelpJSON(_ *kingpin.ParseElement, c *kingpin.ParseContext) error {
+	if err := writeHelpJSON(os.Stdout, c.Application); err != nil {
+		return err
+	}
+	os.Exit(0)
+	return nil
+}
+
+func printFishCompletion(_ *kingpin.ParseElement, c *kingpin.ParseContext) error {
+	writeFishCompletion(os.Stdout, c.Application)
+	os.Exit(0)
+	return nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// historyInterval is the period of the downsampled history of a run, kept
+// for the whole run whatever its length
+const historyInterval = time.Minute
+
+// soakTicks is the per-second ticks kept with --soak, the older ones being
+// only in the history. They are dropped by halves of the window, so that up
+// to twice as many are kept.
+const soakTicks = 3600
+
+// historyPoint is a period of the history, latencies in ms
+type historyPoint struct {
+	Time    string  `json:"time"`
+	Elapsed float64 `json:"elapsed"` // seconds at the end of the period
+	RPS     float64 `json:"rps"`
+	Min     float64 `json:"min"`
+	Mean    float64 `json:"mean"`
+	Max     float64 `json:"max"`
+	P99     float64 `json:"p99"`
+	Errors  int64   `json:"errors"`
+	Warmup  bool    `json:"warmup,omitempty"` // holding records of the warmup
+}
+
+type historyData struct {
+	Interval float64        `json:"interval"` // seconds
+	From     int            `json:"from"`     // index of the first point, the next poll asking for From+len(Points)
+	Points   []historyPoint `json:"points"`
+}
+
+// History returns the periods of the history recorded since index from
+func (s *StreamReport) History(from int) []*TickReport {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if from >= len(s.history) {
+		return nil
+	}
+	res := make([]*TickReport, len(s.history)-from)
+	copy(res, s.history[from:])
+	return res
+}
+
+// trimTicks drops the oldest per-second ticks beyond the window of --soak,
+// under the lock
+func (s *StreamReport) trimTicks() {
+	if !s.soak || len(s.ticks) <= 2*soakTicks {
+		return
+	}
+	n := len(s.ticks) - soakTicks
+	s.ticks = append([]*TickReport(nil), s.ticks[n:]...)
+	s.ticksDropped += n
+}
+
+// handleHistory serves the history of the current run from the index
+// ?from=N
+func (g *GUIServer) handleHistory(ctx *fasthttp.RequestCtx) {
+	ctx.SetContentType("application/json")
+	from := ctx.QueryArgs().GetUintOrZero("from")
+	g.mu.Lock()
+	report := g.report
+	g.mu.Unlock()
+	ms := func(v float64) float64 { return roundFloat(v/float64(time.Millisecond), 3) }
+	data := historyData{Interval: historyInterval.Seconds(), From: from, Points: []historyPoint{}}
+	if report != nil {
+		for _, t := range report.History(from) {
+			p := historyPoint{
+				Time:    t.Time.Format(timeFormat),
+				Elapsed: roundFloat(t.Elapsed.Seconds(), 1),
+				RPS:     roundFloat(t.RPS, 3),
+				Min:     ms(t.Latency.min),
+				Mean:    ms(t.Latency.Mean()),
+				Max:     ms(t.Latency.max),
+				Errors:  t.Errors,
+				Warmup:  t.Warmup,
+			}
+			for i, q := range quantiles {
+				if q == 0.99 && i < len(t.Percentiles) {
+					p.P99 = ms(float64(t.Percentiles[i]))
+				}
+			}
+			data.Points = append(data.Points, p)
+		}
+	}
+	json.NewEncoder(ctx).Encode(&data)
+}
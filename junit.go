@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/xml"
+	"io"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+type junitTestSuites struct {
+	XMLName  xml.Name          `xml:"testsuites"`
+	Name     string            `xml:"name,attr"`
+	Tests    int               `xml:"tests,attr"`
+	Failures int               `xml:"failures,attr"`
+	Time     string            `xml:"time,attr"`
+	Suites   []*junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name       string           `xml:"name,attr"`
+	Tests      int              `xml:"tests,attr"`
+	Failures   int              `xml:"failures,attr"`
+	Time       string           `xml:"time,attr"`
+	Timestamp  string           `xml:"timestamp,attr,omitempty"`
+	Properties []*junitProperty `xml:"properties>property,omitempty"`
+	Cases      []*junitTestCase `xml:"testcase"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+func junitSeconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', 3, 64)
+}
+
+// newJUnitSuite turns the threshold results of a report into a test suite,
+// one test case per threshold.
+func newJUnitSuite(name, desc string, s *SnapshotReport, results []*ThresholdResult) *junitTestSuite {
+	suite := &junitTestSuite{
+		Name:      name,
+		Time:      junitSeconds(s.Elapsed),
+		Timestamp: time.Unix(0, atomic.LoadInt64(&startTimeUnixNano)).UTC().Format("2006-01-02T15:04:05"),
+		Properties: []*junitProperty{
+			{Name: "description", Value: desc},
+			{Name: "count", Value: strconv.FormatInt(s.Count, 10)},
+			{Name: "rps", Value: strconv.FormatFloat(s.RPS, 'f', 3, 64)},
+		},
+	}
+	for _, r := range results {
+		tc := &junitTestCase{Name: r.Threshold, ClassName: "plow." + name, Time: suite.Time, SystemOut: "actual: " + r.Actual}
+		if !r.Passed {
+			suite.Failures++
+			tc.Failure = &junitFailure{Message: r.Threshold + " failed, actual " + r.Actual, Type: "threshold", Text: r.Actual}
+		}
+		suite.Cases = append(suite.Cases, tc)
+	}
+	suite.Tests = len(suite.Cases)
+	return suite
+}
+
+func writeJUnit(w io.Writer, suites ...*junitTestSuite) error {
+	root := &junitTestSuites{Name: "plow", Suites: suites}
+	var elapsed float64
+	for _, s := range suites {
+		root.Tests += s.Tests
+		root.Failures += s.Failures
+		t, _ := strconv.ParseFloat(s.Time, 64)
+		elapsed += t
+	}
+	root.Time = strconv.FormatFloat(elapsed, 'f', 3, 64)
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(root); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func writeJUnitFile(path string, suites ...*junitTestSuite) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err = writeJUnit(f, suites...); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
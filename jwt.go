@@ -0,0 +1,191 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"hash"
+	"strings"
+	"time"
+)
+
+// jwtMinter signs a short-lived token per request from a claims template,
+// adding iat, nbf, exp and a unique jti unless the template sets them.
+type jwtMinter struct {
+	alg    string
+	key    interface{}
+	claims map[string]interface{}
+	ttl    time.Duration
+	header string
+	prefix string
+
+	encodedHeader string
+}
+
+var jwtHashes = map[string]crypto.Hash{
+	"256": crypto.SHA256,
+	"384": crypto.SHA384,
+	"512": crypto.SHA512,
+}
+
+// parseJWTKey returns an HMAC secret, or the private key of a PEM file
+func parseJWTKey(data []byte) (interface{}, string, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return []byte(strings.TrimRight(string(data), "\r\n")), "HS256", nil
+	}
+	var key interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("jwt key: %s", err)
+	}
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return k, "RS256", nil
+	case *ecdsa.PrivateKey:
+		switch k.Curve.Params().BitSize {
+		case 384:
+			return k, "ES384", nil
+		case 521:
+			return k, "ES512", nil
+		}
+		return k, "ES256", nil
+	case ed25519.PrivateKey:
+		return k, "EdDSA", nil
+	}
+	return nil, "", fmt.Errorf("jwt key: unsupported key type %T", key)
+}
+
+func newJWTMinter(keyData, claims []byte, alg string, ttl time.Duration, header string) (*jwtMinter, error) {
+	key, defaultAlg, err := parseJWTKey(keyData)
+	if err != nil {
+		return nil, err
+	}
+	if alg == "" {
+		alg = defaultAlg
+	}
+	if alg != "EdDSA" && (len(alg) != 5 || jwtHashes[alg[2:]] == 0) {
+		return nil, fmt.Errorf("jwt: unsupported alg %q", alg)
+	}
+	ok := false
+	switch key.(type) {
+	case []byte:
+		ok = strings.HasPrefix(alg, "HS")
+	case *rsa.PrivateKey:
+		ok = strings.HasPrefix(alg, "RS") || strings.HasPrefix(alg, "PS")
+	case *ecdsa.PrivateKey:
+		ok = strings.HasPrefix(alg, "ES")
+	case ed25519.PrivateKey:
+		ok = alg == "EdDSA"
+	}
+	if !ok {
+		return nil, fmt.Errorf("jwt: alg %s doesn't match the key", alg)
+	}
+
+	m := &jwtMinter{alg: alg, key: key, ttl: ttl, header: header, prefix: "Bearer "}
+	if m.header == "" {
+		m.header = "Authorization"
+	} else if !strings.EqualFold(m.header, "Authorization") {
+		m.prefix = ""
+	}
+	if len(claims) > 0 {
+		if err := json.Unmarshal(claims, &m.claims); err != nil {
+			return nil, fmt.Errorf("jwt claims must be a JSON object: %s", err)
+		}
+	}
+	h, _ := json.Marshal(map[string]string{"alg": alg, "typ": "JWT"})
+	m.encodedHeader = base64.RawURLEncoding.EncodeToString(h)
+	return m, nil
+}
+
+func (m *jwtMinter) sign(data []byte) ([]byte, error) {
+	if m.alg == "EdDSA" {
+		return ed25519.Sign(m.key.(ed25519.PrivateKey), data), nil
+	}
+	hf := jwtHashes[m.alg[2:]]
+	if k, ok := m.key.([]byte); ok {
+		var newHash func() hash.Hash
+		switch hf {
+		case crypto.SHA384:
+			newHash = sha512.New384
+		case crypto.SHA512:
+			newHash = sha512.New
+		default:
+			newHash = sha256.New
+		}
+		mac := hmac.New(newHash, k)
+		mac.Write(data)
+		return mac.Sum(nil), nil
+	}
+	h := hf.New()
+	h.Write(data)
+	digest := h.Sum(nil)
+	switch k := m.key.(type) {
+	case *rsa.PrivateKey:
+		if strings.HasPrefix(m.alg, "PS") {
+			return rsa.SignPSS(rand.Reader, k, hf, digest, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		}
+		return rsa.SignPKCS1v15(rand.Reader, k, hf, digest)
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest)
+		if err != nil {
+			return nil, err
+		}
+		size := (k.Curve.Params().BitSize + 7) / 8
+		sig := make([]byte, 2*size)
+		r.FillBytes(sig[:size])
+		s.FillBytes(sig[size:])
+		return sig, nil
+	}
+	return nil, fmt.Errorf("jwt: unsupported key")
+}
+
+// mint returns a new signed token
+func (m *jwtMinter) mint() (string, error) {
+	now := time.Now()
+	claims := make(map[string]interface{}, len(m.claims)+4)
+	for k, v := range m.claims {
+		claims[k] = v
+	}
+	setDefault := func(k string, v interface{}) {
+		if _, ok := claims[k]; !ok {
+			claims[k] = v
+		}
+	}
+	setDefault("iat", now.Unix())
+	setDefault("nbf", now.Unix())
+	setDefault("exp", now.Add(m.ttl).Unix())
+	jti := make([]byte, 12)
+	_, _ = rand.Read(jti)
+	setDefault("jti", hex.EncodeToString(jti))
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := m.encodedHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	sig, err := m.sign([]byte(signingInput))
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
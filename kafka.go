@@ -0,0 +1,599 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// the Kafka APIs and versions sent
+const (
+	kafkaProduce         = 0
+	kafkaProduceVersion  = 3
+	kafkaMetadata        = 3
+	kafkaMetadataVersion = 1
+)
+
+// kafkaTimeout is the timeout of the produce requests without --timeout,
+// and bounds the metadata requests
+const kafkaTimeout = 30 * time.Second
+
+// kafkaMetadataBackoff is the time a failed metadata request is answered
+// again to the requests of its topic before it is sent again
+const kafkaMetadataBackoff = time.Second
+
+// maxKafkaResponse bounds the responses read
+const maxKafkaResponse = 64 << 20
+
+var kafkaCRC = crc32.MakeTable(crc32.Castagnoli)
+
+// kafkaErrors are the names of the error codes of the partitions
+var kafkaErrors = map[int16]string{
+	2: "CORRUPT_MESSAGE", 3: "UNKNOWN_TOPIC_OR_PARTITION", 5: "LEADER_NOT_AVAILABLE", 6: "NOT_LEADER_OR_FOLLOWER",
+	7: "REQUEST_TIMED_OUT", 10: "MESSAGE_TOO_LARGE", 17: "INVALID_TOPIC_EXCEPTION", 18: "RECORD_LIST_TOO_LARGE",
+	19: "NOT_ENOUGH_REPLICAS", 20: "NOT_ENOUGH_REPLICAS_AFTER_APPEND", 21: "INVALID_REQUIRED_ACKS",
+	29: "TOPIC_AUTHORIZATION_FAILED", 35: "UNSUPPORTED_VERSION", 87: "INVALID_RECORD",
+}
+
+// kafkaError is the error code of a partition
+type kafkaError int16
+
+func (e kafkaError) Error() string {
+	if name, ok := kafkaErrors[int16(e)]; ok {
+		return "kafka: " + name
+	}
+	return "kafka: error code " + strconv.Itoa(int(e))
+}
+
+// isKafkaURL tells the kafka:// urls, their path being the topic
+func isKafkaURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	return err == nil && u.Scheme == "kafka"
+}
+
+// KafkaReport counts the messages produced, Rate being the messages per
+// second
+type KafkaReport struct {
+	Acks     string
+	Batch    int
+	Messages int64
+	Rate     float64
+	Bytes    int64 // of the values of the messages
+}
+
+// kafkaStats are the counters of the messages of all the workers, a
+// request producing a batch of them
+type kafkaStats struct {
+	messages int64
+	bytes    int64
+}
+
+// kafkaMode produces the --body of each request, or a random value of
+// --kafka-message-size, to the topic of the path of the kafka:// urls, in
+// batches of --kafka-batch messages to the next partition
+type kafkaMode struct {
+	acks    int16 // -1 for all
+	batch   int
+	value   []byte // nil for the body of the requests
+	stats   kafkaStats
+	mu      sync.Mutex
+	topics  map[string]*kafkaTopic // by url
+	fetches map[string]*kafkaFetch // the metadata requests in flight, by url
+	failed  map[string]*kafkaFetch // the last failed ones, until their backoff ends
+	nextCID int32
+}
+
+func newKafkaMode(acks string, batch int, messageSize int64) (*kafkaMode, error) {
+	if batch < 1 {
+		return nil, fmt.Errorf("--kafka-batch must be at least 1")
+	}
+	m := &kafkaMode{
+		batch:   batch,
+		topics:  make(map[string]*kafkaTopic),
+		fetches: make(map[string]*kafkaFetch),
+		failed:  make(map[string]*kafkaFetch),
+	}
+	switch acks {
+	case "all":
+		m.acks = -1
+	case "0", "1":
+		m.acks = int16(acks[0] - '0')
+	default:
+		return nil, fmt.Errorf("--kafka-acks must be 0, 1 or all")
+	}
+	if messageSize > 0 {
+		m.value = make([]byte, messageSize)
+		_, _ = rand.Read(m.value)
+	}
+	return m, nil
+}
+
+func (m *kafkaMode) acksName() string {
+	if m.acks < 0 {
+		return "all"
+	}
+	return strconv.Itoa(int(m.acks))
+}
+
+func (m *kafkaMode) report(elapsed float64) *KafkaReport {
+	rs := &KafkaReport{
+		Acks:     m.acksName(),
+		Batch:    m.batch,
+		Messages: atomic.LoadInt64(&m.stats.messages),
+		Bytes:    atomic.LoadInt64(&m.stats.bytes),
+	}
+	if elapsed > 0 {
+		rs.Rate = float64(rs.Messages) / elapsed
+	}
+	return rs
+}
+
+// kafkaTopic is the metadata of a topic, the partitions being taken in turn
+type kafkaTopic struct {
+	name       string
+	partitions []int32
+	leaders    map[int32]string // address of the leader of each partition
+	stale      int32            // set when a leader moved, the metadata fetched again
+	next       uint64
+}
+
+// kafkaFetch is a metadata request of a topic, done once closed
+type kafkaFetch struct {
+	done chan struct{}
+	kt   *kafkaTopic
+	err  error
+	at   time.Time // of the failure
+}
+
+// topic returns the metadata of the topic of t, fetched from its broker the
+// first time and again after a leader moved. A single request fetches them,
+// the others waiting for it only without metadata, and its failure is
+// returned for kafkaMetadataBackoff, the moved leaders being used meanwhile.
+func (m *kafkaMode) topic(t *target) (*kafkaTopic, error) {
+	m.mu.Lock()
+	kt := m.topics[t.url]
+	if kt != nil && atomic.LoadInt32(&kt.stale) == 0 {
+		m.mu.Unlock()
+		return kt, nil
+	}
+	if f := m.failed[t.url]; f != nil && time.Since(f.at) < kafkaMetadataBackoff {
+		m.mu.Unlock()
+		if kt != nil {
+			return kt, nil
+		}
+		return nil, f.err
+	}
+	if f := m.fetches[t.url]; f != nil {
+		m.mu.Unlock()
+		if kt != nil {
+			return kt, nil
+		}
+		<-f.done
+		return f.kt, f.err
+	}
+	f := &kafkaFetch{done: make(chan struct{})}
+	m.fetches[t.url] = f
+	m.mu.Unlock()
+
+	f.kt, f.err = m.fetchMetadata(t)
+	m.mu.Lock()
+	delete(m.fetches, t.url)
+	if f.err != nil {
+		f.at = time.Now()
+		m.failed[t.url] = f
+	} else {
+		delete(m.failed, t.url)
+		m.topics[t.url] = f.kt
+	}
+	m.mu.Unlock()
+	close(f.done)
+	if f.err != nil && kt != nil {
+		return kt, nil
+	}
+	return f.kt, f.err
+}
+
+// fetchMetadata asks the broker of the url of t for the partitions of its
+// topic and their leaders
+func (m *kafkaMode) fetchMetadata(t *target) (*kafkaTopic, error) {
+	u, err := url.Parse(t.url)
+	if err != nil {
+		return nil, err
+	}
+	name := strings.Trim(u.Path, "/")
+	client := t.httpClient
+	nc, err := client.Dial(client.Addr)
+	if err != nil {
+		return nil, err
+	}
+	defer nc.Close()
+	_ = nc.SetDeadline(time.Now().Add(kafkaTimeout))
+	conn := &kafkaConn{Conn: nc, br: bufio.NewReader(nc)}
+	body := binary.BigEndian.AppendUint32(nil, 1)
+	body = kafkaString(body, name)
+	resp, err := conn.roundTrip(kafkaMetadata, kafkaMetadataVersion, m.correlationID(), body)
+	if err != nil {
+		return nil, err
+	}
+	return parseKafkaMetadata(resp, name)
+}
+
+func (m *kafkaMode) correlationID() int32 {
+	return atomic.AddInt32(&m.nextCID, 1)
+}
+
+// parseKafkaMetadata reads the brokers and the partitions of topic of a
+// Metadata v1 response
+func parseKafkaMetadata(b []byte, topic string) (*kafkaTopic, error) {
+	r := &kafkaReader{b: b}
+	brokers := make(map[int32]string)
+	for n := r.int32(); n > 0 && r.err == nil; n-- {
+		id, host, port := r.int32(), r.string(), r.int32()
+		r.string() // rack
+		brokers[id] = net.JoinHostPort(host, strconv.Itoa(int(port)))
+	}
+	r.int32() // controller
+	kt := &kafkaTopic{name: topic, leaders: make(map[int32]string)}
+	for n := r.int32(); n > 0 && r.err == nil; n-- {
+		code, name := r.int16(), r.string()
+		r.int8() // internal
+		if code != 0 && name == topic {
+			return nil, fmt.Errorf("metadata of topic %q: %w", topic, kafkaError(code))
+		}
+		for p := r.int32(); p > 0 && r.err == nil; p-- {
+			r.int16()
+			index, leader := r.int32(), r.int32()
+			r.int32s() // replicas
+			r.int32s() // isr
+			if addr, ok := brokers[leader]; ok && name == topic {
+				kt.partitions = append(kt.partitions, index)
+				kt.leaders[index] = addr
+			}
+		}
+	}
+	if r.err != nil {
+		return nil, fmt.Errorf("kafka: malformed metadata response")
+	}
+	if len(kt.partitions) == 0 {
+		return nil, fmt.Errorf("kafka: no partition of topic %q with a leader", topic)
+	}
+	return kt, nil
+}
+
+// kafkaString appends s with its 2-byte length
+func kafkaString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+// kafkaReader reads the fields of a response, err being set past its end
+type kafkaReader struct {
+	b   []byte
+	err error
+}
+
+func (r *kafkaReader) take(n int) []byte {
+	if r.err != nil || n < 0 || len(r.b) < n {
+		r.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	v := r.b[:n]
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *kafkaReader) int8() int8 {
+	if v := r.take(1); v != nil {
+		return int8(v[0])
+	}
+	return 0
+}
+
+func (r *kafkaReader) int16() int16 {
+	if v := r.take(2); v != nil {
+		return int16(binary.BigEndian.Uint16(v))
+	}
+	return 0
+}
+
+func (r *kafkaReader) int32() int32 {
+	if v := r.take(4); v != nil {
+		return int32(binary.BigEndian.Uint32(v))
+	}
+	return 0
+}
+
+func (r *kafkaReader) int64() int64 {
+	if v := r.take(8); v != nil {
+		return int64(binary.BigEndian.Uint64(v))
+	}
+	return 0
+}
+
+// string reads a nullable string, "" for null
+func (r *kafkaReader) string() string {
+	n := r.int16()
+	if n < 0 {
+		return ""
+	}
+	return string(r.take(int(n)))
+}
+
+func (r *kafkaReader) int32s() {
+	for n := r.int32(); n > 0 && r.err == nil; n-- {
+		r.int32()
+	}
+}
+
+// kafkaConn is a connection to a broker
+type kafkaConn struct {
+	net.Conn
+	br  *bufio.Reader
+	out []byte
+	in  []byte
+}
+
+// send writes a request of api with body, the size and header prepended
+func (c *kafkaConn) send(api, version int16, cid int32, body []byte) error {
+	c.out = binary.BigEndian.AppendUint32(c.out[:0], 0)
+	c.out = binary.BigEndian.AppendUint16(c.out, uint16(api))
+	c.out = binary.BigEndian.AppendUint16(c.out, uint16(version))
+	c.out = binary.BigEndian.AppendUint32(c.out, uint32(cid))
+	c.out = kafkaString(c.out, "plow")
+	c.out = append(c.out, body...)
+	binary.BigEndian.PutUint32(c.out, uint32(len(c.out)-4))
+	_, err := c.Write(c.out)
+	return err
+}
+
+// receive reads the response of correlation id cid, without its header
+func (c *kafkaConn) receive(cid int32) ([]byte, error) {
+	var hdr [8]byte
+	if _, err := io.ReadFull(c.br, hdr[:]); err != nil {
+		return nil, err
+	}
+	size := int(binary.BigEndian.Uint32(hdr[:]))
+	if size < 4 || size > maxKafkaResponse {
+		return nil, errors.New("kafka: malformed response size")
+	}
+	if int32(binary.BigEndian.Uint32(hdr[4:])) != cid {
+		return nil, errors.New("kafka: response to another request")
+	}
+	if cap(c.in) < size-4 {
+		c.in = make([]byte, size-4)
+	}
+	if _, err := io.ReadFull(c.br, c.in[:size-4]); err != nil {
+		return nil, unexpectedEOF(err, 1)
+	}
+	return c.in[:size-4], nil
+}
+
+func (c *kafkaConn) roundTrip(api, version int16, cid int32, body []byte) ([]byte, error) {
+	if err := c.send(api, version, cid, body); err != nil {
+		return nil, err
+	}
+	return c.receive(cid)
+}
+
+// kafkaConns are the connections of a worker to the leaders of the
+// partitions, by address
+type kafkaConns struct {
+	r     *Requester
+	mode  *kafkaMode
+	conns map[string]*kafkaConn
+	body  []byte
+	batch []byte
+}
+
+func newKafkaConns(r *Requester) *kafkaConns {
+	return &kafkaConns{r: r, mode: r.clientOpt.kafka, conns: make(map[string]*kafkaConn)}
+}
+
+// setup fetches the partitions of the topics and their leaders, known ahead
+// of the run
+func (m *kafkaMode) setup(r *Requester) error {
+	for _, t := range r.targets.targets {
+		if _, err := m.topic(t); err != nil {
+			return fmt.Errorf("%s: %w", t.url, err)
+		}
+	}
+	return nil
+}
+
+func (m *kafkaMode) conns(r *Requester, worker int) protocolConns { return newKafkaConns(r) }
+
+func (m *kafkaMode) teardown() {}
+
+func (c *kafkaConns) build() error { return nil }
+
+func (c *kafkaConns) do(x *exchange, rr *ReportRecord) {
+	c.produce(x.t, x.client, x.pt, x.req, rr)
+}
+
+// close closes the connections of the worker at its end
+func (c *kafkaConns) close() {
+	for addr, conn := range c.conns {
+		conn.Close()
+		delete(c.conns, addr)
+	}
+}
+
+// produce sends a batch of messages to the next partition of the topic of t,
+// the latency of rr being the time to the response of its leader, or the
+// write of the request with --kafka-acks 0
+func (c *kafkaConns) produce(t *target, client *fasthttp.HostClient, pt *phaseTracker, req *fasthttp.Request, rr *ReportRecord) {
+	opt := c.r.clientOpt
+	rr.reset()
+
+	value := c.mode.value
+	if value == nil {
+		value = req.Body()
+	}
+	kt, err := c.mode.topic(t)
+	start := time.Now()
+	n := 0
+	if err == nil {
+		partition := kt.partitions[(atomic.AddUint64(&kt.next, 1)-1)%uint64(len(kt.partitions))]
+		addr := kt.leaders[partition]
+		c.pack(kt.name, partition, value, time.Now())
+		// a connection the broker closed while idle is dialed again once
+		for attempt := 0; attempt < 2; attempt++ {
+			pt.start()
+			start = time.Now()
+			conn := c.conns[addr]
+			rr.cold = conn == nil
+			if conn == nil {
+				var nc net.Conn
+				if nc, err = client.Dial(addr); err != nil {
+					break
+				}
+				conn = &kafkaConn{Conn: nc, br: bufio.NewReader(nc)}
+				c.conns[addr] = conn
+			}
+			c.deadlines(conn)
+			if n, err = c.exchange(conn); err == nil || rr.cold || n > 0 {
+				break
+			}
+			conn.Close()
+			delete(c.conns, addr)
+			rr.stale = staleRetried
+		}
+		var ke kafkaError
+		if errors.As(err, &ke) && (ke == 5 || ke == 6) {
+			atomic.StoreInt32(&kt.stale, 1)
+		}
+		// the error of a partition leaves the connection in step, unlike the others
+		if conn := c.conns[addr]; conn != nil && (err != nil && !errors.As(err, &ke) || opt.disableKeepalive) {
+			conn.Close()
+			delete(c.conns, addr)
+		}
+	}
+	done := time.Now()
+	rr.cost = done.Sub(start)
+	rr.addr = pt.addr
+	rr.phases = pt.times(rr.cost, done)
+	rr.reqSize, rr.respSize = int64(len(c.batch)), int64(n)
+	rr.error = ""
+	if err != nil {
+		rr.error = err.Error()
+		rr.errClass = classifyError(err)
+		return
+	}
+	atomic.AddInt64(&c.mode.stats.messages, int64(c.mode.batch))
+	atomic.AddInt64(&c.mode.stats.bytes, int64(c.mode.batch*len(value)))
+}
+
+// deadlines sets the deadlines of a request on conn
+func (c *kafkaConns) deadlines(conn *kafkaConn) {
+	opt := c.r.clientOpt
+	now := time.Now()
+	var deadline time.Time
+	if opt.doTimeout > 0 {
+		deadline = now.Add(opt.doTimeout)
+	}
+	if opt.writeTimeout > 0 {
+		_ = conn.SetWriteDeadline(now.Add(opt.writeTimeout))
+	} else {
+		_ = conn.SetWriteDeadline(deadline)
+	}
+	if opt.readTimeout > 0 {
+		_ = conn.SetReadDeadline(now.Add(opt.readTimeout))
+	} else {
+		_ = conn.SetReadDeadline(deadline)
+	}
+}
+
+// pack packs the Produce v3 request of a batch of --kafka-batch messages of
+// value to partition, produced at the time at
+func (c *kafkaConns) pack(topic string, partition int32, value []byte, at time.Time) {
+	now := at.UnixMilli()
+	// the record batch v2, its crc covering the bytes after it
+	b := binary.BigEndian.AppendUint64(c.batch[:0], 0) // base offset
+	b = binary.BigEndian.AppendUint32(b, 0)            // length, set below
+	b = binary.BigEndian.AppendUint32(b, 0xffffffff)   // partition leader epoch
+	b = append(b, 2)                                   // magic
+	b = binary.BigEndian.AppendUint32(b, 0)            // crc, set below
+	b = binary.BigEndian.AppendUint16(b, 0)            // attributes
+	b = binary.BigEndian.AppendUint32(b, uint32(c.mode.batch-1))
+	b = binary.BigEndian.AppendUint64(b, uint64(now)) // first timestamp
+	b = binary.BigEndian.AppendUint64(b, uint64(now)) // max timestamp
+	b = binary.BigEndian.AppendUint64(b, ^uint64(0))  // producer id
+	b = binary.BigEndian.AppendUint16(b, 0xffff)      // producer epoch
+	b = binary.BigEndian.AppendUint32(b, 0xffffffff)  // base sequence
+	b = binary.BigEndian.AppendUint32(b, uint32(c.mode.batch))
+	var record []byte
+	for i := 0; i < c.mode.batch; i++ {
+		record = append(record[:0], 0)          // attributes
+		record = binary.AppendVarint(record, 0) // timestamp delta
+		record = binary.AppendVarint(record, int64(i))
+		record = binary.AppendVarint(record, -1) // null key
+		record = binary.AppendVarint(record, int64(len(value)))
+		record = append(record, value...)
+		record = binary.AppendVarint(record, 0) // headers
+		b = binary.AppendVarint(b, int64(len(record)))
+		b = append(b, record...)
+	}
+	binary.BigEndian.PutUint32(b[8:], uint32(len(b)-12))
+	binary.BigEndian.PutUint32(b[17:], crc32.Checksum(b[21:], kafkaCRC))
+	c.batch = b
+
+	timeout := c.r.clientOpt.doTimeout
+	if timeout <= 0 {
+		timeout = kafkaTimeout
+	}
+	body := binary.BigEndian.AppendUint16(c.body[:0], 0xffff) // no transactional id
+	body = binary.BigEndian.AppendUint16(body, uint16(c.mode.acks))
+	body = binary.BigEndian.AppendUint32(body, uint32(timeout.Milliseconds()))
+	body = binary.BigEndian.AppendUint32(body, 1)
+	body = kafkaString(body, topic)
+	body = binary.BigEndian.AppendUint32(body, 1)
+	body = binary.BigEndian.AppendUint32(body, uint32(partition))
+	body = binary.BigEndian.AppendUint32(body, uint32(len(c.batch)))
+	c.body = append(body, c.batch...)
+}
+
+// exchange sends the request built on conn and reads its response, none
+// coming with --kafka-acks 0, returning the bytes of the response read
+func (c *kafkaConns) exchange(conn *kafkaConn) (int, error) {
+	cid := c.mode.correlationID()
+	if err := conn.send(kafkaProduce, kafkaProduceVersion, cid, c.body); err != nil {
+		return 0, err
+	}
+	if c.mode.acks == 0 {
+		return 0, nil
+	}
+	resp, err := conn.receive(cid)
+	if err != nil {
+		return 0, err
+	}
+	r := &kafkaReader{b: resp}
+	for n := r.int32(); n > 0 && r.err == nil; n-- {
+		r.string()
+		for p := r.int32(); p > 0 && r.err == nil; p-- {
+			r.int32()
+			code := r.int16()
+			r.int64() // base offset
+			r.int64() // log append time
+			if code != 0 && r.err == nil {
+				return len(resp) + 8, kafkaError(code)
+			}
+		}
+	}
+	if r.err != nil {
+		return len(resp) + 8, errors.New("kafka: malformed produce response")
+	}
+	return len(resp) + 8, nil
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"net"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// scriptedConn is a connection reading the replies of in and keeping what
+// is written to it in out
+type scriptedConn struct {
+	net.Conn
+	in  *bytes.Reader
+	out bytes.Buffer
+}
+
+func newScriptedConn(in []byte) *scriptedConn {
+	return &scriptedConn{in: bytes.NewReader(in)}
+}
+
+func (c *scriptedConn) Read(b []byte) (int, error)  { return c.in.Read(b) }
+func (c *scriptedConn) Write(b []byte) (int, error) { return c.out.Write(b) }
+
+func TestKafkaProduceRequest(t *testing.T) {
+	c := &kafkaConns{
+		r:    &Requester{clientOpt: &ClientOpt{doTimeout: 5 * time.Second}},
+		mode: &kafkaMode{acks: -1, batch: 2},
+	}
+	c.pack("events", 2, []byte("hi"), time.UnixMilli(1700000000000))
+	want := unhex(t, `
+ff ff ff ff 00 00 13 88 00 00 00 01 00 06 65 76 65 6e 74 73 00 00 00 01 00 00 00 02 00 00 00 4f
+00 00 00 00 00 00 00 00 00 00 00 43 ff ff ff ff 02 24 3f 2c 8e 00 00 00 00 00 01 00 00 01 8b cf
+e5 68 00 00 00 01 8b cf e5 68 00 ff ff ff ff ff ff ff ff ff ff ff ff ff ff 00 00 00 02 10 00 00
+00 01 04 68 69 00 10 00 00 02 01 04 68 69 00`)
+	if !bytes.Equal(c.body, want) {
+		t.Errorf("got\n% x\nwant\n% x", c.body, want)
+	}
+}
+
+func TestKafkaSend(t *testing.T) {
+	nc := newScriptedConn(nil)
+	conn := &kafkaConn{Conn: nc, br: bufio.NewReader(nc)}
+	if err := conn.send(kafkaMetadata, kafkaMetadataVersion, 7, kafkaString(unhex(t, "00 00 00 01"), "events")); err != nil {
+		t.Fatal(err)
+	}
+	want := unhex(t, `00 00 00 1a 00 03 00 01 00 00 00 07 00 04 70 6c 6f 77 00 00 00 01 00 06 65 76 65 6e 74 73`)
+	if !bytes.Equal(nc.out.Bytes(), want) {
+		t.Errorf("got % x, want % x", nc.out.Bytes(), want)
+	}
+}
+
+func TestKafkaReceive(t *testing.T) {
+	nc := newScriptedConn(unhex(t, "00 00 00 06 00 00 00 07 ab cd"))
+	conn := &kafkaConn{Conn: nc, br: bufio.NewReader(nc)}
+	b, err := conn.receive(7)
+	if err != nil || !bytes.Equal(b, []byte{0xab, 0xcd}) {
+		t.Errorf("got % x, %v", b, err)
+	}
+	nc = newScriptedConn(unhex(t, "00 00 00 06 00 00 00 08 ab cd"))
+	conn = &kafkaConn{Conn: nc, br: bufio.NewReader(nc)}
+	if _, err := conn.receive(7); err == nil {
+		t.Error("response to another request: no error")
+	}
+}
+
+func TestParseKafkaMetadata(t *testing.T) {
+	resp := unhex(t, `
+00 00 00 01 00 00 00 01 00 02 62 31 00 00 23 84 ff ff
+00 00 00 01
+00 00 00 01 00 00 00 06 65 76 65 6e 74 73 00 00 00 00 02
+00 00 00 00 00 00 00 00 00 01 00 00 00 01 00 00 00 01 00 00 00 01 00 00 00 01
+00 00 00 00 00 01 00 00 00 07 00 00 00 00 00 00 00 00`)
+	kt, err := parseKafkaMetadata(resp, "events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the leader of the partition 1 is not a known broker
+	if !reflect.DeepEqual(kt.partitions, []int32{0}) || !reflect.DeepEqual(kt.leaders, map[int32]string{0: "b1:9092"}) {
+		t.Errorf("got %v %v", kt.partitions, kt.leaders)
+	}
+	if _, err := parseKafkaMetadata(resp[:30], "events"); err == nil {
+		t.Error("truncated response: no error")
+	}
+}
+
+// TestKafkaTopicFetch checks that the workers share a single metadata
+// request, whose failure is returned until its backoff ends, and keep the
+// metadata of a moved leader while it can't be fetched again
+func TestKafkaTopicFetch(t *testing.T) {
+	m, err := newKafkaMode("1", 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dials int32
+	release := make(chan struct{})
+	tg := &target{url: "kafka://b1:9092/events", httpClient: &fasthttp.HostClient{
+		Addr: "b1:9092",
+		Dial: func(addr string) (net.Conn, error) {
+			atomic.AddInt32(&dials, 1)
+			<-release
+			return nil, errors.New("connection refused")
+		},
+	}}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := m.topic(tg); err == nil {
+				t.Error("no error without a broker")
+			}
+		}()
+	}
+	for atomic.LoadInt32(&dials) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if n := atomic.LoadInt32(&dials); n != 1 {
+		t.Fatalf("%d metadata requests, want 1", n)
+	}
+
+	// the failure is returned until the backoff ends
+	if _, err := m.topic(tg); err == nil || atomic.LoadInt32(&dials) != 1 {
+		t.Fatalf("within the backoff: %v after %d requests", err, dials)
+	}
+	m.failed[tg.url].at = time.Now().Add(-kafkaMetadataBackoff)
+	if _, err := m.topic(tg); err == nil || atomic.LoadInt32(&dials) != 2 {
+		t.Fatalf("after the backoff: %v after %d requests", err, dials)
+	}
+
+	// a moved leader is used while the metadata can't be fetched again
+	stale := &kafkaTopic{name: "events", partitions: []int32{0}, leaders: map[int32]string{0: "b1:9092"}, stale: 1}
+	m.topics[tg.url] = stale
+	delete(m.failed, tg.url)
+	for i := 0; i < 2; i++ {
+		if kt, err := m.topic(tg); kt != stale || err != nil {
+			t.Fatalf("stale metadata: got %v, %v", kt, err)
+		}
+	}
+	if n := atomic.LoadInt32(&dials); n != 3 {
+		t.Errorf("%d metadata requests, want 3", n)
+	}
+}
@@ -0,0 +1,8 @@
+package main
+
+// ConnectionsReport is the connections opened with --disable-keepalive, one
+// per request, Rate being the ones per second
+type ConnectionsReport struct {
+	Opened int64
+	Rate   float64
+}
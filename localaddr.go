@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// localAddrs are the source addresses new connections are bound to in turn,
+// given as ips, CIDR blocks or network interfaces.
+type localAddrs struct {
+	sources []*proxySource
+	seq     uint64
+}
+
+func parseLocalAddrs(list []string) (*localAddrs, error) {
+	l := &localAddrs{}
+	for _, s := range list {
+		if iface, err := net.InterfaceByName(s); err == nil {
+			addrs, err := iface.Addrs()
+			if err != nil {
+				return nil, err
+			}
+			for _, a := range addrs {
+				// link-local addresses would need the zone
+				if ipNet, ok := a.(*net.IPNet); ok && !ipNet.IP.IsLinkLocalUnicast() {
+					l.sources = append(l.sources, &proxySource{ip: ipNet.IP})
+				}
+			}
+			continue
+		}
+		src, err := parseProxySource(s)
+		if err != nil || src.port > 0 {
+			return nil, fmt.Errorf("invalid local address %q, must be IP, CIDR or an interface", s)
+		}
+		l.sources = append(l.sources, src)
+	}
+	if len(l.sources) == 0 {
+		return nil, fmt.Errorf("no local address found in %v", list)
+	}
+	return l, nil
+}
+
+// next returns the source of the next connection, going through each
+// source, then through the following address of each CIDR block.
+func (l *localAddrs) next() net.IP {
+	n := atomic.AddUint64(&l.seq, 1) - 1
+	s := l.sources[n%uint64(len(l.sources))]
+	return s.addr(n / uint64(len(l.sources)))
+}
+
+// dial connects from the next source address, the port being picked at
+// connect time where possible so each source has its own ephemeral ports.
+func (l *localAddrs) dial(timeout time.Duration) fasthttp.DialFunc {
+	return func(addr string) (net.Conn, error) {
+		src := l.next()
+		if host, _, err := net.SplitHostPort(addr); err == nil {
+			// skip the sources of the other family, e.g. ::1 of the lo interface
+			if ip := net.ParseIP(host); ip != nil {
+				for i := 0; i < len(l.sources) && (ip.To4() == nil) != (src.To4() == nil); i++ {
+					src = l.next()
+				}
+			}
+		}
+		d := net.Dialer{
+			Timeout:   timeout,
+			LocalAddr: &net.TCPAddr{IP: src},
+			Control:   bindAddressNoPort,
+		}
+		return d.Dial("tcp", addr)
+	}
+}
@@ -0,0 +1,14 @@
+package main
+
+import "syscall"
+
+// ipBindAddressNoPort is IP_BIND_ADDRESS_NO_PORT, missing from syscall
+const ipBindAddressNoPort = 24
+
+// bindAddressNoPort defers the choice of the local port to connect, so that
+// the ephemeral ports aren't shared by all the source addresses.
+func bindAddressNoPort(network, address string, c syscall.RawConn) error {
+	return c.Control(func(fd uintptr) {
+		_ = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, ipBindAddressNoPort, 1)
+	})
+}
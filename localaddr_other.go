@@ -0,0 +1,9 @@
+//go:build !linux
+
+package main
+
+import "syscall"
+
+func bindAddressNoPort(network, address string, c syscall.RawConn) error {
+	return nil
+}
@@ -1,12 +1,16 @@
 package main
 
 import (
+	"bytes"
+	"crypto/tls"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
 	_ "net/http/pprof"
+	"net/url"
 	"os"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -19,47 +23,217 @@ import (
 var (
 	concurrency = kingpin.Flag("concurrency", "Number of connections to run concurrently").Short('c').Default("1").Int()
 	reqRate     = rateFlag(kingpin.Flag("rate", "Number of requests per time unit, examples: --rate 50 --rate 10/ms").Default("infinity"))
+	connRate    = rateFlag(kingpin.Flag("conn-rate", "Number of new connections, and so TLS handshakes, per time unit whatever the request rate, the wait being out of the latency, examples: --conn-rate 50 --conn-rate 1/20ms").Default("infinity"))
+	thinkSpec   = kingpin.Flag("think-time", "Pause of each connection between a response and its next request, as a user reading a page, fixed, uniform between MIN and MAX, or exponential of mean MEAN, examples: --think-time 500ms --think-time 1s-3s --think-time exp:2s").PlaceHolder("DURATION|MIN-MAX|exp:MEAN").String()
+	arrival     = kingpin.Flag("arrival", "Model of the arrivals of --rate: fixed, each connection waiting for its turn after its response, or poisson, open-loop arrivals at exponential intervals waiting for a free connection, the wait being reported apart from the latency").Default(arrivalFixed).Enum(arrivalFixed, arrivalPoisson)
+	maxQueue    = kingpin.Flag("max-queue", "Arrivals waiting for a free connection at most with --arrival poisson, the others being dropped").Default("1000").Int()
 	rampUp      = kingpin.Flag("ramp-up", "Concurrently will increase pre seconds").Default("-1").Int()
 	requests    = kingpin.Flag("requests", "Number of requests to run").Short('n').Default("-1").Int64()
 	duration    = kingpin.Flag("duration", "Duration of test, examples: -d 10s -d 3m").Short('d').PlaceHolder("DURATION").Duration()
+	until       = kingpin.Flag("until", "End the run at this wall-clock time, whatever the start delays, e.g. 2024-06-01T06:00:00Z or 06:00 for the next 6 AM local time").PlaceHolder("TIME").String()
+	grace       = kingpin.Flag("grace", "Time the requests in flight have to complete when the run is stopped by ctrl-c or /stop, the final summary counting them; a second ctrl-c stops at once").Default("5s").Duration()
 	interval    = kingpin.Flag("interval", "Print snapshot result every interval, use 0 to print once at the end").Short('i').Default("200ms").Duration()
 	seconds     = kingpin.Flag("seconds", "Use seconds as time unit to print").Bool()
 	jsonFormat  = kingpin.Flag("json", "Print snapshot result as JSON").Bool()
+	summaryJSON = kingpin.Flag("summary-json", "Print only the final summary as JSON, stamped with its SchemaVersion, instead of the realtime table").Bool()
+	tuiMode     = kingpin.Flag("tui", "Show a live dashboard of the run on the whole terminal instead of the realtime table, with sparklines of the requests, latency and failures and keys to stop it (q) or change its connections (+ and -), e.g. over SSH").Bool()
 
-	body      = kingpin.Flag("body", "HTTP request body, if body starts with '@' the rest will be considered a file's path from which to read the actual body content").Short('b').String()
-	stream    = kingpin.Flag("stream", "Specify whether to stream file specified by '--body @file' using chunked encoding or to read into memory").Default("false").Bool()
-	methodSet = false
-	method    = kingpin.Flag("method", "HTTP method").Action(func(_ *kingpin.ParseElement, _ *kingpin.ParseContext) error {
+	body       = kingpin.Flag("body", "HTTP request body, if body starts with '@' the rest will be considered a file's path from which to read the actual body content").Short('b').String()
+	templating = kingpin.Flag("template", "Render the placeholders of the url path and query, headers and body per request, e.g. {{uuid}}, {{randInt 1 100}}, {{name}}, {{email}}, {{timestamp}} or {{seq}}").Bool()
+	dataFile   = kingpin.Flag("data-file", "CSV file with a header line, or JSON Lines file, each request taking the next row as the data of its templates, e.g. {{.user}}, implies --template").PlaceHolder("FILE").String()
+	dataMode   = kingpin.Flag("data-mode", "How requests take the --data-file rows: sequential (shared by all connections), random or partition (each connection has its own rows)").Default(dataSequential).Enum(dataSequential, dataRandom, dataPartition)
+	capture    = kingpin.Flag("capture", "Capture a response header into a variable of the templates of the next requests of the connection, e.g. the cursor of a paginated crawl used as {{.cursor}}, the column of the same name of --data-file being its value until then, implies --template, example: --capture cursor=X-Next-Cursor").PlaceHolder("NAME=HEADER").Strings()
+	harFile    = kingpin.Flag("har", "Replay the requests of a HAR file exported by a browser instead of the url arguments, also run as `plow replay --har FILE`").PlaceHolder("FILE").ExistingFile()
+	harMode    = kingpin.Flag("har-mode", "How the HAR requests are replayed: weighted (identical requests merged into a weighted mix) or sequence (each connection sends all of them in order)").Default(harWeighted).Enum(harWeighted, harSequence)
+	harInclude = kingpin.Flag("har-include", "Only replay the HAR requests whose url matches this regexp").PlaceHolder("REGEXP").String()
+	stream     = kingpin.Flag("stream", "Specify whether to stream file specified by '--body @file' using chunked encoding or to read into memory").Default("false").Bool()
+	bodySize   = kingpin.Flag("body-size", "Stream a body of this size generated on the fly with chunked encoding, e.g. 10GB, repeating --body or random bytes without").PlaceHolder("SIZE").String()
+	chunkSize  = kingpin.Flag("chunk-size", "Size of the chunks of the bodies of --stream and --body-size, up to 4KB").Default("4KB").String()
+	streamRate = kingpin.Flag("stream-rate", "Bytes per second each body of --stream and --body-size is sent at, e.g. 1MB, as fast as possible without").PlaceHolder("SIZE").String()
+	methodSet  = false
+	method     = kingpin.Flag("method", "HTTP method").Action(func(_ *kingpin.ParseElement, _ *kingpin.ParseContext) error {
 		methodSet = true
 		return nil
 	}).Default("GET").Short('m').String()
+
+	accessLog   = kingpin.Flag("access-log", "Replay the requests of an nginx/Apache combined log, or of a JSON lines log, in order on the url argument as the new base url").PlaceHolder("FILE").ExistingFile()
+	logFormat   = kingpin.Flag("log-format", "Format of --access-log, guessed from its first line by default").PlaceHolder("combined|json").Enum(logCombined, logJSON)
+	logFieldMap = kingpin.Flag("log-fields", "Keys of the fields of a JSON --access-log, dotted for nested ones, e.g. time=ts,method=req.method,path=req.uri").PlaceHolder("MAP").String()
+	replaySpeed = kingpin.Flag("replay-speed", "Send the --access-log requests at their original times scaled by this factor, e.g. 1 for the original pace or 2 for twice as fast, instead of as fast as possible").PlaceHolder("FACTOR").Float64()
+
+	followRedirects = kingpin.Flag("follow-redirects", "Follow the Location of the 3xx responses, up to --max-redirects hops or N with --follow-redirects=N, the latency adding up the hops and the code being the one of the last").Bool()
+	maxRedirects    = kingpin.Flag("max-redirects", "Most redirects followed per request, the ones going further count as errors").Default("10").Int()
+	redirectStatus  = kingpin.Flag("redirect-status", "Count the 3xx responses as success or failure, in the error rate, the ejection of targets and the status chart").Default("success").Enum("success", "failure")
+
+	grpcType     = kingpin.Flag("grpc", "Send gRPC calls of this type over HTTP/2 (h2c for http urls), the url path being the /package.Service/Method and --body the encoded protobuf message sent, with messages/sec and per-message latency").PlaceHolder("unary|client-stream|server-stream|bidi").Enum(grpcUnary, grpcClientStream, grpcServerStream, grpcBidi)
+	grpcMessages = kingpin.Flag("grpc-messages", "Messages sent per client-stream or bidi call, bidi waiting for a reply to each").Default("10").Int()
+	grpcMsgRate  = kingpin.Flag("grpc-message-rate", "Messages sent per second on each client-stream or bidi call, 0 for as fast as possible").Default("0").Float64()
+	grpcStreams  = kingpin.Flag("grpc-streams", "Concurrent calls on each HTTP/2 connection, that many of the -c workers sharing one connection").Default("1").Int()
+	tcpRespSize  = kingpin.Flag("tcp-response-size", "Bytes of the response to each --body sent to a tcp:// or tls:// url, the echo of the body checked as such by default").Default("0").Int()
+	tcpDelimiter = kingpin.Flag("tcp-delimiter", "End of the responses of the tcp:// and tls:// urls, with Go escapes, e.g. \\r\\n, instead of --tcp-response-size").PlaceHolder("STRING").String()
+	dnsQueries   = kingpin.Flag("dns-query", "Send DNS queries for this name to the udp://, tcp://, tls:// (DNS over TLS) or https:// (DNS over HTTPS) urls, each request taking the next one, with the placeholders of --template, e.g. {{randString 8}}.example.com, counted by response code").PlaceHolder("NAME").Strings()
+	dnsType      = kingpin.Flag("dns-type", "Type of the --dns-query queries: A, AAAA, CNAME, MX, NS, PTR, SOA, SRV, TXT or ANY").Default("A").String()
+	mqttTopics   = kingpin.Flag("mqtt-topic", "Topic the --body of each request is published to on the mqtt:// and mqtts:// urls, each request taking the next one, with the placeholders of --template, e.g. sensors/{{randInt 1 1000}}").Default("plow").PlaceHolder("TOPIC").Strings()
+	mqttQoS      = kingpin.Flag("mqtt-qos", "QoS of the MQTT publishes, 1 timing them up to their PUBACK").Default("0").Int()
+	mqttSubs     = kingpin.Flag("mqtt-subscribe", "Subscribe to this topic filter on each broker during the run, with the messages delivered and their latency from the publish, stamped at the start of their payload, e.g. sensors/#").PlaceHolder("FILTER").Strings()
+	redisCmds    = kingpin.Flag("redis-command", "Command sent to the redis:// and rediss:// urls, each taking the next one, with the placeholders of --template and double-quoted arguments, e.g. 'GET key:{{randInt 1 100000}}'").Default("PING").PlaceHolder("COMMAND").Strings()
+	redisPipe    = kingpin.Flag("redis-pipeline", "Commands pipelined per request to the redis:// and rediss:// urls, the latency being the one of the pipeline").Default("1").Int()
+	kafkaAcks    = kingpin.Flag("kafka-acks", "Acks of the produce requests to the kafka://BROKER:PORT/TOPIC urls, 0 timing only their write").Default("1").Enum("0", "1", "all")
+	kafkaBatch   = kingpin.Flag("kafka-batch", "Messages produced per request to the kafka:// urls, in one record batch to the next partition").Default("1").Int()
+	kafkaMsgSize = kingpin.Flag("kafka-message-size", "Produce random values of this size to the kafka:// urls instead of --body, e.g. 1KB").PlaceHolder("SIZE").String()
+	sqlQuery     = kingpin.Flag("sql-query", "Query run on the databases of the postgres:// and mysql:// urls, prepared once, with the $1 or ? placeholders of the driver for --sql-arg, e.g. 'SELECT * FROM users WHERE id = $1'").PlaceHolder("QUERY").String()
+	sqlArgs      = kingpin.Flag("sql-arg", "Argument of --sql-query, in order, with the placeholders of --template rendered per query, e.g. '{{randInt 1 100000}}'").PlaceHolder("VALUE").Strings()
+	sqlPool      = kingpin.Flag("sql-pool", "Connections of the pool to each database of the postgres:// and mysql:// urls, -c by default").PlaceHolder("N").Int()
+	backendPath  = kingpin.Flag("backend", "Send the requests to the urls through the Backend served by this executable with the backend package of plow, for the protocols plow doesn't know").PlaceHolder("PATH").String()
+	backendOpts  = kingpin.Flag("backend-option", "Option passed to the Setup of the --backend, e.g. region=eu-west-1").PlaceHolder("KEY=VALUE").Strings()
+	sseMode      = kingpin.Flag("sse", "Read the responses as Server-Sent Events streams until the end of the run, the latency being the time to their first event, with events/sec, dropped streams and reconnects").Bool()
+
 	headers     = kingpin.Flag("header", "Custom HTTP headers").Short('H').PlaceHolder("K:V").Strings()
+	traceHdr    = kingpin.Flag("trace-header", "Set a new id in this header of each request, to match the logs of the server with the requests, in uuid, hex or traceparent format, the W3C one of the traceparent header by default").PlaceHolder("NAME[:FORMAT]").String()
 	host        = kingpin.Flag("host", "Host header").String()
+	sni         = kingpin.Flag("sni", "TLS server name sent and verified instead of the host of the url, the name of --host by default, e.g. to hit one backend by its ip as the production host").PlaceHolder("NAME").String()
+	compressed  = kingpin.Flag("compressed", "Send Accept-Encoding: "+acceptEncoding+", unless set by --header, and decode the responses, counting their decoded bytes along the ones on the wire").Bool()
+	noCompress  = kingpin.Flag("disable-compression", "Send Accept-Encoding: identity, asking for uncompressed responses").Bool()
+	authUser    = kingpin.Flag("user", "Authenticate with Basic auth, or with Digest auth along --digest").Short('u').PlaceHolder("USER:PASSWORD").String()
+	authDigest  = kingpin.Flag("digest", "Answer the Digest challenge of the server with the --user credentials, instead of sending them as Basic auth").Bool()
+	cookieJars  = kingpin.Flag("cookie-jar", "Give each connection its own cookie jar, sending back the cookies set by its responses like a distinct user, e.g. for a login then authenticated requests").Bool()
 	contentType = kingpin.Flag("content", "Content-Type header").Short('T').String()
 	cert        = kingpin.Flag("cert", "Path to the client's TLS Certificate").ExistingFile()
 	key         = kingpin.Flag("key", "Path to the client's TLS Certificate Private Key").ExistingFile()
+	certReload  = kingpin.Flag("cert-reload", "Check --cert and --key for changes at this interval and present the new certificate in the next handshakes").PlaceHolder("INTERVAL").Duration()
+	cacert      = kingpin.Flag("cacert", "Path to the CA certificates verifying the server, instead of the system ones").ExistingFile()
 	insecure    = kingpin.Flag("insecure", "Controls whether a client verifies the server's certificate chain and host name").Short('k').Bool()
 
 	chartsListenAddr = kingpin.Flag("listen", "Listen addr to serve Web UI").Default(":18888").String()
-	timeout          = kingpin.Flag("timeout", "Timeout for each http request").PlaceHolder("DURATION").Duration()
-	dialTimeout      = kingpin.Flag("dial-timeout", "Timeout for dial addr").PlaceHolder("DURATION").Duration()
-	reqWriteTimeout  = kingpin.Flag("req-timeout", "Timeout for full request writing").PlaceHolder("DURATION").Duration()
-	respReadTimeout  = kingpin.Flag("resp-timeout", "Timeout for full response reading").PlaceHolder("DURATION").Duration()
+	guiQuotaSpecs    = kingpin.Flag("gui-quota", "Bound the concurrency, duration and rate of each run started from the GUI, for the runs of a bearer TOKEN or the others, which are refused when only tokens have quotas, e.g. --gui-quota c=50,d=5m,rate=500 --gui-quota ci-bot:c=200,d=30m").PlaceHolder("[TOKEN:]c=N,d=DURATION,rate=RATE").Strings()
+	guiUsers         = kingpin.Flag("gui-user", "Require this Basic auth USER:PASSWORD, or a --gui-token, to use the GUI server, its runs, presets and results included").PlaceHolder("USER:PASSWORD").Strings()
+	guiTokens        = kingpin.Flag("gui-token", "Require this bearer token, or a --gui-user, to use the GUI server, the page opened as /?token=TOKEN sending it").PlaceHolder("TOKEN").Strings()
+	guiObservers     = kingpin.Flag("gui-observer-token", "Let this bearer token follow the runs of the GUI server without starting, stopping or configuring them, which answers it 403, the page opened as /?token=TOKEN sending it").PlaceHolder("TOKEN").Strings()
+	guiCert          = kingpin.Flag("gui-cert", "Serve the GUI over TLS with this certificate, along --gui-key").PlaceHolder("FILE").ExistingFile()
+	guiKey           = kingpin.Flag("gui-key", "Private key of --gui-cert").PlaceHolder("FILE").ExistingFile()
+	guiConfigFile    = kingpin.Flag("gui-config", "Read the quotas, presets and webhooks of the GUI from this JSON file, read again on SIGHUP or a POST /reload without stopping the runs, e.g. {\"quotas\": [\"ci-bot:c=200,d=30m\"], \"presets\": [...], \"webhooks\": [...]}").PlaceHolder("FILE").ExistingFile()
+	timeout          = kingpin.Flag("timeout", "Timeout for each http request, from its dial to its whole response, reported as a Request Timeout").PlaceHolder("DURATION").Duration()
+	dialTimeout      = kingpin.Flag("dial-timeout", "Timeout for dial addr, reported as a Connect Timeout").PlaceHolder("DURATION").Duration()
+	tlsTimeout       = kingpin.Flag("tls-timeout", "Timeout for the TLS handshake of new connections, --write-timeout by default, reported as a TLS Timeout").PlaceHolder("DURATION").Duration()
+	reqWriteTimeout  = kingpin.Flag("write-timeout", "Timeout for full request writing, reported as a Write Timeout").PlaceHolder("DURATION").Duration()
+	respReadTimeout  = kingpin.Flag("read-timeout", "Timeout for full response reading, reported as a Read Timeout").PlaceHolder("DURATION").Duration()
+	reqTimeoutAlias  = kingpin.Flag("req-timeout", "Former name of --write-timeout").Hidden().Duration()
+	respTimeoutAlias = kingpin.Flag("resp-timeout", "Former name of --read-timeout").Hidden().Duration()
+	staleConn        = kingpin.Flag("stale-conn", "What a request failing on an idle reused connection does, e.g. closed by the server's keep-alive timeout: retry (sent again once on a new connection, like most HTTP clients) or error (reported as an error)").Default(staleRetry).Enum(staleRetry, staleError)
+	disableKeepalive = kingpin.Flag("disable-keepalive", "Open a new connection, and so do a new TCP and TLS handshake, for each request, reporting the connections per second").Bool()
+	pipeline         = kingpin.Flag("pipeline", "Pipeline this many requests on each connection, the connections being the concurrency divided by it, with the requests per second of each connection").PlaceHolder("N").Int()
 	socks5           = kingpin.Flag("socks5", "Socks5 proxy").PlaceHolder("ip:port").String()
 	httpProxy        = kingpin.Flag("http-proxy", "Set HTTP proxy").PlaceHolder("username:password@ip:port").String()
+	proxies          = kingpin.Flag("proxy", "Proxy url (http:// or socks5://, with optional user:pass@), repeat to rotate requests across proxies").PlaceHolder("URL").Strings()
 
-	autoOpenBrowser = kingpin.Flag("auto-open-browser", "Specify whether auto open browser to show web charts").Bool()
-	clean           = kingpin.Flag("clean", "Clean the histogram bar once its finished. Default is true").Default("true").NegatableBool()
-	outputErrors    = kingpin.Flag("output-errors", "Output errors to file").String()
-	summary         = kingpin.Flag("summary", "Only print the summary without realtime reports").Default("false").Bool()
-	pprofAddr       = kingpin.Flag("pprof", "Enable pprof at special address").Hidden().String()
-	url             = kingpin.Arg("url", "Request url (optional — omit to launch GUI mode)").String()
-	unixSocket      = kingpin.Flag("unix-socket", "Unix domain socket path to use for connection").String()
+	autoOpenBrowser   = kingpin.Flag("auto-open-browser", "Specify whether auto open browser to show web charts").Bool()
+	clean             = kingpin.Flag("clean", "Clean the histogram bar once its finished. Default is true").Default("true").NegatableBool()
+	outputErrors      = kingpin.Flag("output-errors", "Output errors to file").String()
+	summary           = kingpin.Flag("summary", "Only print the summary without realtime reports").Default("false").Bool()
+	pprofAddr         = kingpin.Flag("pprof", "Enable pprof at special address").Hidden().String()
+	urls              = kingpin.Arg("url", "Request url(s), rotated in turn or mixed by weight as URL:WEIGHT (optional — omit to launch GUI mode)").Strings()
+	unixSocket        = kingpin.Flag("unix-socket", "Unix domain socket path to use for connection").String()
+	resolver          = kingpin.Flag("resolver", "DNS server to resolve the url hosts with instead of the system one").PlaceHolder("IP[:PORT]").String()
+	dnsRefresh        = kingpin.Flag("dns-refresh", "Re-resolve the url hosts at this interval instead of on each new connection").PlaceHolder("DURATION").Duration()
+	resolveOnce       = kingpin.Flag("resolve-once", "Resolve the url hosts once for the whole run").Bool()
+	dnsRoundRobin     = kingpin.Flag("dns-round-robin", "Spread new connections across all the A/AAAA records of the url hosts, with per address results").Bool()
+	localAddrList     = kingpin.Flag("local-addr", "Source address of the connections, rotated per connection, a CIDR yields each of its addresses in turn and an interface each of its addresses").PlaceHolder("IP|CIDR|IFACE").Strings()
+	connectTo         = kingpin.Flag("connect-to", "Connect to this address instead of the host of the url, keeping the Host header and TLS server name of the url").PlaceHolder("HOST:PORT").String()
+	ejectAfter        = kingpin.Flag("eject-after", "Eject a url from rotation after N consecutive failures, use 0 to never eject").Default("0").Int()
+	probeInterval     = kingpin.Flag("probe-interval", "Interval to re-probe an ejected url").Default("5s").Duration()
+	guardrailURL      = kingpin.Flag("guardrail", "Url polled during the run, which stops it by answering \"abort\", optionally followed by a reason").PlaceHolder("URL").String()
+	guardrailInterval = kingpin.Flag("guardrail-interval", "Interval to poll the --guardrail url").Default("5s").Duration()
+	extractExprs      = kingpin.Flag("extract", "Numeric field of the JSON responses reported as a time series, e.g. --extract 'queue=$.queue_depth'").PlaceHolder("[NAME=]$.PATH").Strings()
+	extractRate       = kingpin.Flag("extract-rate", "Share of the responses the --extract fields are read from, between 0 and 1").Default("1").Float64()
+	validateFormat    = kingpin.Flag("validate", "Check that the bodies of the 2xx responses are well-formed, counting the truncated or invalid ones apart from the errors").PlaceHolder("utf8|json|xml").Enum("utf8", "json", "xml")
+	validateRate      = kingpin.Flag("validate-rate", "Share of the responses checked by --validate, between 0 and 1").Default("1").Float64()
+	hashBodies        = kingpin.Flag("hash-bodies", "Hash the response bodies, reporting their distinct variants by status with the start of each, e.g. an error page answered with 200").Bool()
+	hashRate          = kingpin.Flag("hash-rate", "Share of the responses hashed by --hash-bodies, between 0 and 1").Default("1").Float64()
+	thresholdExprs    = kingpin.Flag("threshold", "Check the final report against a threshold and exit non-zero on failure, examples: --threshold 'p99<200ms' --threshold 'error_rate<1%'").PlaceHolder("METRIC<VALUE").Strings()
+	uploads           = kingpin.Flag("upload", "Upload the final report to s3://BUCKET/KEY, gs://BUCKET/KEY or azblob://ACCOUNT/CONTAINER/KEY, the key being a template of {{.RunID}}, {{.Date}}, {{.Time}}, {{.Timestamp}}, {{.Host}} and {{.Hostname}}, a .html key gets the HTML report and others the JSON one").PlaceHolder("URL").Strings()
+	webhookURLs       = kingpin.Flag("webhook", "POST the summary of the run to this url once it passed, failed its thresholds or stages, or aborted, as a Slack message for slack:URL or the urls of hooks.slack.com").PlaceHolder("[slack:]URL").Strings()
+	junitFile         = kingpin.Flag("junit", "Write threshold results as a JUnit XML report to file").PlaceHolder("FILE").String()
+	soak              = kingpin.Flag("soak", "Long run mode, keeping the per-second metrics of the last hour only, the whole run being kept per minute").Bool()
+	csvFile           = kingpin.Flag("csv", "Write per-second metrics as CSV rows to file").PlaceHolder("FILE").String()
+	capacityFile      = kingpin.Flag("capacity-report", "Write a standalone HTML page charting the latency percentiles and the error rate of each --stage against its rate, the throughput-latency curve of a ramp to failure, to file at the end of the run").PlaceHolder("FILE").String()
+	openMetricsFile   = kingpin.Flag("openmetrics", "Write the final metrics and threshold results in OpenMetrics text format to file at the end of the run, e.g. a .prom file of the node_exporter textfile collector").PlaceHolder("FILE").String()
+	remoteWriteURL    = kingpin.Flag("remote-write", "Push the metrics of the run to this Prometheus remote write url every --remote-write-interval and at its end, e.g. the /api/v1/push of Mimir, labeled with job=\"plow\" and its run_id").PlaceHolder("URL").String()
+	remoteWriteEvery  = kingpin.Flag("remote-write-interval", "Interval between the pushes of --remote-write during the run").Default("10s").Duration()
+	remoteWriteLabels = kingpin.Flag("remote-write-label", "Label added to the series of --remote-write, or replacing job or run_id").PlaceHolder("NAME=VALUE").Strings()
+	remoteWriteHdrs   = kingpin.Flag("remote-write-header", "Header of the pushes of --remote-write, e.g. X-Scope-OrgID:team or Authorization:Bearer TOKEN").PlaceHolder("K:V").Strings()
+	failuresFile      = kingpin.Flag("failures", "Write the first --failure-samples failed requests, on an error, a timeout, a 5xx, a malformed body or a GraphQL error, with their responses as JSON to file at the end of the run").PlaceHolder("FILE").String()
+	maxFailures       = kingpin.Flag("failure-samples", "Number of failed requests kept with their responses for --failures").Default("20").Int()
+	proxyProtocol     = kingpin.Flag("proxy-protocol", "Send a PROXY protocol header of version v1 or v2 on each new connection").PlaceHolder("VERSION").Enum("v1", "v2")
+	proxySources      = kingpin.Flag("proxy-source", "Client address announced in the PROXY header instead of the real one, rotated per connection, a CIDR yields each of its addresses in turn").PlaceHolder("IP[:PORT]|CIDR").Strings()
+	ntlm              = kingpin.Flag("ntlm", "Authenticate each connection with an NTLM handshake").PlaceHolder(`DOMAIN\USER:PASSWORD`).String()
+	negotiate         = kingpin.Flag("negotiate", "Authenticate each connection with SPNEGO/Kerberos, using the credential cache of kinit or --keytab").Bool()
+	keytabFile        = kingpin.Flag("keytab", "Kerberos keytab for --negotiate").PlaceHolder("FILE").String()
+	principal         = kingpin.Flag("principal", "Kerberos principal of --keytab").PlaceHolder("USER@REALM").String()
+	spn               = kingpin.Flag("spn", "Kerberos service principal name, default HTTP/<url host>").String()
+	graphqlFile       = kingpin.Flag("graphql", "Send the GraphQL query/mutation document of file as a JSON POST body, and count the errors[] of 200 responses as GraphQL errors").PlaceHolder("FILE").ExistingFile()
+	graphqlVars       = kingpin.Flag("variables", "JSON file of the GraphQL variables").PlaceHolder("FILE").ExistingFile()
+	graphqlOp         = kingpin.Flag("operation", "GraphQL operation name to run, when the document has several").String()
+	jwtKey            = kingpin.Flag("jwt-key", "Sign a fresh JWT for each request with the HMAC secret or PEM private key (RSA, EC, Ed25519) of file").PlaceHolder("FILE").ExistingFile()
+	jwtClaims         = kingpin.Flag("jwt-claims", "JSON file of the JWT claims, iat, nbf, exp and jti are added unless set").PlaceHolder("FILE").ExistingFile()
+	jwtAlg            = kingpin.Flag("jwt-alg", "JWT signing algorithm, default HS256 or the one of the key type").Enum("HS256", "HS384", "HS512", "RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA")
+	jwtTTL            = kingpin.Flag("jwt-ttl", "Expiry of each JWT").Default("1m").Duration()
+	jwtHeader         = kingpin.Flag("jwt-header", "Header carrying the JWT, Authorization gets the Bearer prefix").Default("Authorization").String()
+	oauth2TokenURL    = kingpin.Flag("oauth2-token-url", "Fetch a bearer token for the Authorization header from this OAuth2 token endpoint before the run, fetched again ahead of its expiry").PlaceHolder("URL").String()
+	oauth2Client      = kingpin.Flag("oauth2-client", "OAuth2 client credentials, for the client_credentials grant").PlaceHolder("ID:SECRET").String()
+	oauth2User        = kingpin.Flag("oauth2-user", "Use the OAuth2 password grant with these resource owner credentials").PlaceHolder("USER:PASSWORD").String()
+	oauth2Scope       = kingpin.Flag("oauth2-scope", "Scope of the OAuth2 token, space separated").PlaceHolder("SCOPE").String()
+	probeAddr         = kingpin.Flag("probe", "Plot the CPU, memory and network of the target host streamed by a plow probe running on it").PlaceHolder("HOST:PORT").String()
+	agents            = kingpin.Flag("agent", "Fan the benchmark out to a plow agent, splitting connections, requests and rate evenly across agents, with per region results for the agents labeled REGION=HOST:PORT").PlaceHolder("[REGION=]HOST:PORT").Strings()
+	agentToken        = kingpin.Flag("agent-token", "Bearer token sent to the agents and the probe, the one of their --token").PlaceHolder("TOKEN").String()
+	agentCA           = kingpin.Flag("agent-ca", "Trust the agents and the probe serving with --tls-cert of this CA, given as HOST:PORT being https:// ones").PlaceHolder("FILE").ExistingFile()
+	agentCert         = kingpin.Flag("agent-cert", "Client certificate presented to the agents and the probe of --tls-client-ca, along --agent-key").PlaceHolder("FILE").ExistingFile()
+	agentKey          = kingpin.Flag("agent-key", "Private key of --agent-cert").PlaceHolder("FILE").ExistingFile()
+	warmup            = kingpin.Flag("warmup", "Send requests for this long at the start of the run but exclude them from the summary, percentiles and thresholds, e.g. while JIT, caches and TCP slow start settle, --auto-warmup then detecting the end of the warmup").PlaceHolder("DURATION").Duration()
+	autoWarmup        = kingpin.Flag("auto-warmup", "Exclude the first requests from the summary until the per-second median latency stabilizes, within 20% over 5s, or until MAX").PlaceHolder("MAX").Duration()
+	searchSpec        = kingpin.Flag("search", "Search for the highest rate within MIN-MAX requests per second the target sustains within the --threshold budget, by binary search over steps of --search-step").PlaceHolder("MIN-MAX").String()
+	searchStep        = kingpin.Flag("search-step", "Duration of each step of --search").Default("30s").Duration()
+	searchPrecision   = kingpin.Flag("search-precision", "End --search once its bounds are within this % of each other").Default("5").Float64()
+	stageSpecs        = kingpin.Flag("stage", "Run stages one after another, each with its own rate and thresholds, examples: --stage 30s,rate=50 --stage '1m,rate=200,p99<100ms,error_rate<1%'").PlaceHolder("DURATION[,rate=RATE][,THRESHOLD...]").Strings()
+
+	_ = kingpin.Flag("help-json", "Print all flags and args as JSON and exit").PreAction(printHelpJSON).Bool()
+	_ = kingpin.Flag("completion-script-fish", "Generate completion script for fish.").Hidden().PreAction(printFishCompletion).Bool()
 )
 
 // dynamically set by GoReleaser
 var version = "dev"
 
+// subcommands are dispatched on the first argument before the main command line
+// is parsed, as kingpin can't mix commands with the top-level url args
+var subcommands = map[string]func(args []string){
+	"agent":             runAgentCommand,
+	"serve-test":        runServeTestCommand,
+	"profile":           runProfileCommand,
+	"probe":             runProbeCommand,
+	"compare":           runCompareCommand,
+	"grafana-dashboard": runGrafanaDashboardCommand,
+}
+
+// protocolFlag is a flag, whether it is set, and the protocols taking it,
+// e.g. "dns tcp", the others than HTTP refusing it
+type protocolFlag struct {
+	flag      string
+	set       bool
+	protocols string
+}
+
+// checkProtocolFlags exits naming the first flag set that protocol, as
+// described by name, doesn't take, and is false then
+func checkProtocolFlags(flags []protocolFlag, protocol, name string) bool {
+	for _, f := range flags {
+		if !f.set || strings.Contains(" "+f.protocols+" ", " "+protocol+" ") {
+			continue
+		}
+		errAndExit(fmt.Sprintf("%s can't be used with %s", name, f.flag))
+		return false
+	}
+	return true
+}
+
 func errAndExit(msg string) {
 	fmt.Fprintln(os.Stderr, "plow: "+msg)
 	os.Exit(1)
@@ -96,7 +270,11 @@ Examples:
 
   plow                                           (GUI mode — opens browser)
   plow http://127.0.0.1:8080/ -c 20 -n 100000
+  plow http://10.0.0.1:8080/ http://10.0.0.2:8080/ -c 20 --eject-after 5
   plow https://httpbin.org/post -c 20 -d 5m --body @file.json -T 'application/json' -m POST
+  plow agent --listen :19999                     (then: plow http://127.0.0.1:8080/ --agent host1:19999 --agent host2:19999)
+  plow curl "curl https://example.com/api -H 'Authorization: Bearer token'" -c 20 -d 1m
+  plow scenario suites/checkout.yaml -d 5m
 
 {{if .Context.Flags -}}
 {{T "Flags:"}}
@@ -176,6 +354,29 @@ func (f *rateFlagValue) String() string {
 	return f.v
 }
 
+// parseUntil reads the deadline of --until: an RFC 3339 time, a local date
+// and time, or a local time of day meaning its next occurrence after now
+func parseUntil(s string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02T15:04", "2006-01-02 15:04"} {
+		if t, err := time.ParseInLocation(layout, s, now.Location()); err == nil {
+			return t, nil
+		}
+	}
+	for _, layout := range []string{"15:04:05", "15:04"} {
+		if t, err := time.ParseInLocation(layout, s, now.Location()); err == nil {
+			t = time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), t.Second(), 0, now.Location())
+			if !t.After(now) {
+				t = t.AddDate(0, 0, 1)
+			}
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("--until %q is neither an RFC 3339 time, a date and time nor a time of day", s)
+}
+
 func rateFlag(c *kingpin.Clause) (target *rateFlagValue) {
 	target = new(rateFlagValue)
 	c.SetValue(target)
@@ -183,6 +384,61 @@ func rateFlag(c *kingpin.Clause) (target *rateFlagValue) {
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		// `plow replay --har FILE` is the main command with --har
+		os.Args = append(os.Args[:1:1], os.Args[2:]...)
+	}
+	if len(os.Args) > 1 && os.Args[1] == "curl" {
+		// `plow curl "curl ..." FLAGS` benchmarks the request of a copied cURL
+		// command, read from stdin when given as -
+		if len(os.Args) < 3 {
+			errAndExit(`usage: plow curl "curl URL ..." [FLAGS], or plow curl - [FLAGS] with the command on stdin`)
+		}
+		cmd := os.Args[2]
+		if cmd == "-" {
+			data, err := io.ReadAll(os.Stdin)
+			if err != nil {
+				errAndExit(err.Error())
+			}
+			cmd = string(data)
+		}
+		cr, err := parseCurl(cmd)
+		if err != nil {
+			errAndExit(err.Error())
+		}
+		os.Args = append(append(os.Args[:1:1], cr.args()...), os.Args[3:]...)
+	}
+	var configHAR string
+	if len(os.Args) > 1 && os.Args[1] == "run" {
+		// `plow run -f FILE FLAGS` runs the benchmark of a config exported by
+		// the GUI, the flags of the command line overriding its own
+		args, harPath, err := runConfigCommand(os.Args[2:])
+		if err != nil {
+			errAndExit(err.Error())
+		}
+		configHAR = harPath
+		os.Args = append(os.Args[:1:1], args...)
+	}
+	if len(os.Args) > 1 && os.Args[1] == "scenario" {
+		// `plow scenario FILE FLAGS` runs the benchmark of a YAML scenario,
+		// the flags of the command line overriding its own
+		if len(os.Args) < 3 {
+			errAndExit("usage: plow scenario FILE.yaml [FLAGS]")
+		}
+		args, err := loadScenario(os.Args[2], overriddenFlags(os.Args[3:]))
+		if err != nil {
+			errAndExit(err.Error())
+		}
+		os.Args = append(append(os.Args[:1:1], args...), os.Args[3:]...)
+	}
+	if len(os.Args) > 1 {
+		if cmd, ok := subcommands[os.Args[1]]; ok {
+			cmd(os.Args[2:])
+			return
+		}
+	}
+	os.Args = expandFollowRedirects(os.Args)
+
 	kingpin.UsageTemplate(CompactUsageTemplate).
 		Version(version).
 		Author("six-ddc@github").
@@ -193,27 +449,139 @@ func main() {
 	if *pprofAddr != "" {
 		go http.ListenAndServe(*pprofAddr, nil)
 	}
+	agentConn, err := newAgentTransport(*agentToken, *agentCA, *agentCert, *agentKey)
+	if err != nil {
+		errAndExit(err.Error())
+		return
+	}
 
 	// ── GUI MODE ──────────────────────────────────────────────
 	// When no URL argument is given, launch the web-based benchmark GUI.
-	if *url == "" {
+	if len(*urls) == 0 && *harFile == "" {
 		listenAddr := *chartsListenAddr
 		if listenAddr == "" {
 			listenAddr = ":18888"
 		}
+		auth, err := parseGUIAuth(*guiUsers, *guiTokens, *guiObservers)
+		if err != nil {
+			errAndExit(err.Error())
+			return
+		}
+		if (*guiCert == "") != (*guiKey == "") {
+			errAndExit("--gui-cert and --gui-key go together")
+			return
+		}
+		var tlsConfig *tls.Config
+		if *guiCert != "" {
+			cert, err := tls.LoadX509KeyPair(*guiCert, *guiKey)
+			if err != nil {
+				errAndExit(err.Error())
+				return
+			}
+			tlsConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+		} else if len(*guiUsers) > 0 {
+			fmt.Fprintln(os.Stderr, "⚠️  --gui-user passwords go in clear without --gui-cert")
+		}
 		ln, err := net.Listen("tcp", listenAddr)
 		if err != nil {
 			errAndExit(err.Error())
 			return
 		}
+		if tlsConfig != nil {
+			ln = tls.NewListener(ln, tlsConfig)
+		}
 
 		gui := NewGUIServer(ln)
+		gui.tls, gui.auth, gui.grace, gui.agentConn = tlsConfig != nil, auth, *grace, agentConn
+		gui.quotaSpecs, gui.webhookSpecs, gui.configPath = *guiQuotaSpecs, *webhookURLs, *guiConfigFile
+		if err := gui.reload(); err != nil {
+			errAndExit(err.Error())
+			return
+		}
 		// Only open browser if user explicitly passes --auto-open-browser
 		gui.Serve(*autoOpenBrowser)
 		return
 	}
 
 	// ── CLI MODE ──────────────────────────────────────────────
+	targetURLs, weights, err := parseTargetMix(*urls)
+	if err != nil {
+		errAndExit(err.Error())
+		return
+	}
+	var har *harScenario
+	if *harFile != "" {
+		if len(*urls) > 0 || *body != "" || methodSet || *graphqlFile != "" {
+			errAndExit("--har replaces the url arguments, --body, --graphql and --method")
+			return
+		}
+		var include *regexp.Regexp
+		if *harInclude != "" {
+			if include, err = regexp.Compile(*harInclude); err != nil {
+				errAndExit("invalid --har-include: " + err.Error())
+				return
+			}
+		}
+		data, err := os.ReadFile(*harFile)
+		if configHAR != "" {
+			os.Remove(configHAR)
+		}
+		if err != nil {
+			errAndExit(err.Error())
+			return
+		}
+		if har, err = parseHAR(data, *harMode, include); err != nil {
+			errAndExit(fmt.Sprintf("%s: %s", *harFile, err))
+			return
+		}
+		targetURLs, weights = har.urls, har.weights
+	}
+	// the patterns of the url arguments are shown as given
+	shownURLs := append([]string(nil), targetURLs...)
+	for i, u := range targetURLs {
+		if har != nil || *accessLog != "" {
+			break
+		}
+		expanded, ok, err := expandURLPatterns(u)
+		if err != nil {
+			errAndExit(err.Error())
+			return
+		}
+		if ok {
+			targetURLs[i] = expanded
+			*templating = true
+		}
+	}
+	if len(patternLines) > 0 && len(*agents) > 0 {
+		errAndExit("the {FILE} patterns of the url are not supported with --agent")
+		return
+	}
+	var logStream *requestStream
+	if *accessLog != "" {
+		if len(targetURLs) != 1 || weights != nil || har != nil {
+			errAndExit("--access-log replays on one base url argument")
+			return
+		}
+		if *replaySpeed < 0 {
+			errAndExit("--replay-speed can't be negative")
+			return
+		}
+		fields, err := parseLogFields(*logFieldMap)
+		if err != nil {
+			errAndExit(err.Error())
+			return
+		}
+		if logStream, err = loadRequestStream(*accessLog, *logFormat, fields, *replaySpeed); err != nil {
+			errAndExit(err.Error())
+			return
+		}
+		base, err := url.Parse(targetURLs[0])
+		if err != nil {
+			errAndExit(err.Error())
+			return
+		}
+		logStream.prefix = strings.TrimSuffix(base.Path, "/")
+	}
 	if *requests >= 0 && *requests < int64(*concurrency) {
 		errAndExit("requests must greater than or equal concurrency")
 		return
@@ -222,8 +590,557 @@ func main() {
 		errAndExit("must specify cert and key at the same time")
 		return
 	}
+	var proxyURLs []string
+	if *socks5 != "" {
+		proxyURLs = append(proxyURLs, "socks5://"+strings.TrimPrefix(*socks5, "socks5://"))
+	}
+	if *httpProxy != "" {
+		proxyURLs = append(proxyURLs, *httpProxy)
+	}
+	proxyURLs = append(proxyURLs, *proxies...)
+	for i, p := range proxyURLs {
+		u, err := parseProxyURL(p)
+		if err != nil {
+			errAndExit(err.Error())
+			return
+		}
+		proxyURLs[i] = u
+	}
+	if *proxyProtocol != "" && len(proxyURLs) > 0 {
+		errAndExit("--proxy-protocol can't be used with --proxy, --socks5 or --http-proxy")
+		return
+	}
+	if *unixSocket != "" && len(proxyURLs) > 0 {
+		errAndExit("--unix-socket can't be used with --proxy, --socks5 or --http-proxy")
+		return
+	}
+	if *sni == "" && *host != "" {
+		*sni = *host
+		if h, _, err := net.SplitHostPort(*host); err == nil {
+			*sni = h
+		}
+	}
+	if *connectTo != "" {
+		if *unixSocket != "" {
+			errAndExit("--connect-to can't be used with --unix-socket")
+			return
+		}
+		if _, _, err := net.SplitHostPort(*connectTo); err != nil {
+			errAndExit(fmt.Sprintf("invalid --connect-to %q: %s", *connectTo, err))
+			return
+		}
+	}
+	dns, err := newDNSResolver(*resolver, *dnsRefresh, *resolveOnce, *dnsRoundRobin)
+	if err != nil {
+		errAndExit(err.Error())
+		return
+	}
+	if dns != nil && (*unixSocket != "" || len(proxyURLs) > 0) {
+		errAndExit("--resolver, --dns-refresh, --resolve-once and --dns-round-robin can't be used with --unix-socket or a proxy")
+		return
+	}
+	var certs *certReloader
+	if *certReload < 0 || *certReload > 0 && (*cert == "" || *key == "") {
+		errAndExit("--cert-reload requires --cert and --key and a positive interval")
+		return
+	}
+	if *cert != "" && *key != "" {
+		if certs, err = newCertReloader(*cert, *key); err != nil {
+			errAndExit(err.Error())
+			return
+		}
+	}
+	var extractors []*extractor
+	for _, s := range *extractExprs {
+		e, err := parseExtractor(s)
+		if err != nil {
+			errAndExit(err.Error())
+			return
+		}
+		extractors = append(extractors, e)
+	}
+	if *extractRate <= 0 || *extractRate > 1 {
+		errAndExit("--extract-rate must be between 0 and 1")
+		return
+	}
+	if *validateRate <= 0 || *validateRate > 1 {
+		errAndExit("--validate-rate must be between 0 and 1")
+		return
+	}
+	if *hashRate <= 0 || *hashRate > 1 {
+		errAndExit("--hash-rate must be between 0 and 1")
+		return
+	}
+	var local *localAddrs
+	if len(*localAddrList) > 0 {
+		if *unixSocket != "" || len(proxyURLs) > 0 {
+			errAndExit("--local-addr can't be used with --unix-socket or a proxy")
+			return
+		}
+		if local, err = parseLocalAddrs(*localAddrList); err != nil {
+			errAndExit(err.Error())
+			return
+		}
+	}
+	if len(*proxySources) > 0 && *proxyProtocol == "" {
+		errAndExit("--proxy-source requires --proxy-protocol")
+		return
+	}
+	if *graphqlFile != "" && *body != "" {
+		errAndExit("--graphql and --body can't be used at the same time")
+		return
+	}
+	if *ntlm != "" && *negotiate {
+		errAndExit("--ntlm and --negotiate can't be used at the same time")
+		return
+	}
+	var data *dataFeed
+	if *dataFile != "" {
+		if data, err = loadDataFeed(*dataFile, *dataMode); err != nil {
+			errAndExit(err.Error())
+			return
+		}
+		*templating = true
+	}
+	captures, err := parseHeaderCaptures(*capture)
+	if err != nil {
+		errAndExit(err.Error())
+		return
+	}
+	if len(captures) > 0 {
+		*templating = true
+	}
+	if *templating && *stream {
+		errAndExit("--template and --data-file can't be used with --stream")
+		return
+	}
+	if len(*agents) > 0 && (*stream || *cert != "" || *cacert != "" || *unixSocket != "" || len(*localAddrList) > 0 || *accessLog != "") {
+		errAndExit("--stream, --cert, --cacert, --unix-socket, --local-addr and --access-log are not supported with --agent")
+		return
+	}
+	if *jsonFormat && *summaryJSON {
+		errAndExit("--json and --summary-json can't be used at the same time")
+		return
+	}
+	if *tuiMode && (*jsonFormat || *summaryJSON || *summary || !isTerminal) {
+		errAndExit("--tui needs a terminal, without --json, --summary-json or --summary")
+		return
+	}
+	if *failuresFile != "" && len(*agents) > 0 {
+		errAndExit("--failures is not supported with --agent, the failures being on the agents")
+		return
+	}
+	var remote *remoteWriter
+	if *remoteWriteURL != "" {
+		if *remoteWriteEvery <= 0 {
+			errAndExit("--remote-write-interval must be positive")
+			return
+		}
+		if remote, err = newRemoteWriter(*remoteWriteURL, *remoteWriteEvery, *remoteWriteLabels, *remoteWriteHdrs, newRunID()); err != nil {
+			errAndExit(err.Error())
+			return
+		}
+	}
+	if *maxFailures <= 0 {
+		errAndExit("--failure-samples must be positive")
+		return
+	}
+
+	thresholds, err := parseThresholds(*thresholdExprs)
+	if err != nil {
+		errAndExit(err.Error())
+		return
+	}
+	var reportUploads []*reportUpload
+	for _, s := range *uploads {
+		u, err := parseReportUpload(s)
+		if err != nil {
+			errAndExit(err.Error())
+			return
+		}
+		reportUploads = append(reportUploads, u)
+	}
+	webhooks, err := parseWebhooks(*webhookURLs)
+	if err != nil {
+		errAndExit(err.Error())
+		return
+	}
+
+	stages, err := parseStages(*stageSpecs)
+	if err != nil {
+		errAndExit(err.Error())
+		return
+	}
+	if d := stagesDuration(stages); d > 0 && (*duration <= 0 || d < *duration) {
+		*duration = d
+	}
+	var search *rateSearch
+	if *searchSpec != "" {
+		if len(stages) > 0 || reqRate.Limit() != nil || len(*agents) > 0 {
+			errAndExit("--search is not supported with --stage, --rate or --agent")
+			return
+		}
+		if search, err = parseSearch(*searchSpec, *searchStep, *searchPrecision, thresholds); err != nil {
+			errAndExit(err.Error())
+			return
+		}
+		// the thresholds judge the steps, not the run
+		thresholds = nil
+	}
+	if *capacityFile != "" && search == nil {
+		rated := 0
+		for _, st := range stages {
+			if st.Rate != nil {
+				rated++
+			}
+		}
+		if rated < 2 {
+			errAndExit("--capacity-report needs stages at 2 rates at least, e.g. --stage 1m,rate=100 --stage 1m,rate=200")
+			return
+		}
+	}
+	if *warmup > 0 && *duration > 0 && *warmup >= *duration {
+		errAndExit(fmt.Sprintf("--warmup %s leaves nothing of the %s run to measure", *warmup, *duration))
+		return
+	}
+
+	var deadline time.Time
+	if *until != "" {
+		if deadline, err = parseUntil(*until, time.Now()); err != nil {
+			errAndExit(err.Error())
+			return
+		}
+		left := time.Until(deadline)
+		if left <= 0 {
+			errAndExit(fmt.Sprintf("--until %s is already past", deadline.Format(time.RFC3339)))
+			return
+		}
+		if *duration <= 0 || left < *duration {
+			*duration = left
+		}
+	}
+
+	var sources []*proxySource
+	for _, ps := range *proxySources {
+		src, err := parseProxySource(ps)
+		if err != nil {
+			errAndExit(err.Error())
+			return
+		}
+		sources = append(sources, src)
+	}
+
+	var jwtKeyData, jwtClaimsData []byte
+	var jwt *jwtMinter
+	if *jwtKey != "" {
+		if jwtKeyData, err = os.ReadFile(*jwtKey); err != nil {
+			errAndExit(err.Error())
+			return
+		}
+		if *jwtClaims != "" {
+			if jwtClaimsData, err = os.ReadFile(*jwtClaims); err != nil {
+				errAndExit(err.Error())
+				return
+			}
+		}
+		if jwt, err = newJWTMinter(jwtKeyData, jwtClaimsData, *jwtAlg, *jwtTTL, *jwtHeader); err != nil {
+			errAndExit(err.Error())
+			return
+		}
+	}
+	if *followRedirects && *maxRedirects < 1 {
+		errAndExit("--max-redirects must be at least 1")
+		return
+	}
+	if *grpcType != "" {
+		if *grpcMessages < 1 {
+			errAndExit("--grpc-messages must be at least 1")
+			return
+		}
+		if *grpcMsgRate < 0 {
+			errAndExit("--grpc-message-rate can't be negative")
+			return
+		}
+		if *grpcStreams < 1 {
+			errAndExit("--grpc-streams must be at least 1")
+			return
+		}
+		if *grpcStreams > 1 && *disableKeepalive {
+			errAndExit("--grpc-streams can't be used with --disable-keepalive")
+			return
+		}
+		if *followRedirects || *stream || *ntlm != "" || *negotiate || *authDigest || *graphqlFile != "" {
+			errAndExit("--grpc can't be used with --follow-redirects, --stream, --ntlm, --negotiate, --digest or --graphql")
+			return
+		}
+		for _, u := range targetURLs {
+			if !strings.HasPrefix(u, "http://") && !strings.HasPrefix(u, "https://") {
+				errAndExit(fmt.Sprintf("--grpc needs an http:// or https:// url, not %s", u))
+				return
+			}
+		}
+	}
+	if *sseMode {
+		if *grpcType != "" || *followRedirects || *stream || *ntlm != "" || *negotiate || *authDigest || *graphqlFile != "" || len(*agents) > 0 {
+			errAndExit("--sse can't be used with --grpc, --follow-redirects, --stream, --ntlm, --negotiate, --digest, --graphql or --agent")
+			return
+		}
+		for _, u := range targetURLs {
+			if !strings.HasPrefix(u, "http://") && !strings.HasPrefix(u, "https://") {
+				errAndExit(fmt.Sprintf("--sse needs an http:// or https:// url, not %s", u))
+				return
+			}
+		}
+	}
+	// the flags of the HTTP requests and of the other protocols, with the
+	// protocols taking them
+	protocolFlags := []protocolFlag{
+		{"--grpc", *grpcType != "", ""},
+		{"--sse", *sseMode, ""},
+		{"--dns-query", len(*dnsQueries) > 0, "dns"},
+		{"--follow-redirects", *followRedirects, ""},
+		{"--stream", *stream, ""},
+		{"--ntlm", *ntlm != "", ""},
+		{"--negotiate", *negotiate, ""},
+		{"--digest", *authDigest, ""},
+		{"--graphql", *graphqlFile != "", ""},
+		{"--pipeline", *pipeline > 0, ""},
+		{"--har", har != nil, ""},
+		{"--access-log", logStream != nil, ""},
+		{"--proxy", len(proxyURLs) > 0, "dns mqtt redis kafka tcp"},
+		{"--agent", len(*agents) > 0, "dns mqtt tcp"},
+		{"--tcp-response-size", *tcpRespSize != 0, "tcp"},
+		{"--tcp-delimiter", *tcpDelimiter != "", "tcp"},
+	}
+	var plugged *pluggedMode
+	if *backendPath != "" {
+		if !checkProtocolFlags(protocolFlags, "backend", "--backend") {
+			return
+		}
+		if plugged, err = newPluggedMode(*backendPath, *backendOpts); err != nil {
+			errAndExit(err.Error())
+			return
+		}
+	} else if len(*backendOpts) > 0 {
+		errAndExit("--backend-option goes with --backend")
+		return
+	}
+	var dnsQuery *dnsMode
+	if len(*dnsQueries) > 0 {
+		if dnsQuery, err = newDNSMode(*dnsQueries, *dnsType); err != nil {
+			errAndExit(err.Error())
+			return
+		}
+		for _, u := range targetURLs {
+			transport := dnsTransport(u)
+			if pu, err := url.Parse(u); err != nil || transport == "" || transport != "https" && pu.Port() == "" {
+				errAndExit(fmt.Sprintf("--dns-query needs udp://, tcp:// or tls:// urls with a port, or https:// ones, not %s", u))
+				return
+			}
+		}
+		if !checkProtocolFlags(protocolFlags, "dns", "--dns-query") {
+			return
+		}
+	} else if plugged == nil {
+		for _, u := range targetURLs {
+			if dnsTransport(u) == "udp" {
+				errAndExit(fmt.Sprintf("the udp:// urls need --dns-query, not %s", u))
+				return
+			}
+		}
+	}
+	var mqtt *mqttMode
+	for _, u := range targetURLs {
+		if isMQTTURL(u) && plugged == nil {
+			if mqtt, err = newMQTTMode(*mqttTopics, *mqttQoS, *mqttSubs); err != nil {
+				errAndExit(err.Error())
+				return
+			}
+			break
+		}
+	}
+	if mqtt != nil {
+		for _, u := range targetURLs {
+			if pu, err := url.Parse(u); err != nil || !isMQTTURL(u) || pu.Port() == "" {
+				errAndExit(fmt.Sprintf("the mqtt:// and mqtts:// urls need a port and no other urls, not %s", u))
+				return
+			}
+		}
+		if !checkProtocolFlags(protocolFlags, "mqtt", "the mqtt:// and mqtts:// urls") {
+			return
+		}
+		if len(*mqttSubs) > 0 && len(*agents) > 0 {
+			errAndExit("--mqtt-subscribe is not supported with --agent")
+			return
+		}
+	} else if *mqttQoS != 0 || len(*mqttSubs) > 0 {
+		errAndExit("--mqtt-qos and --mqtt-subscribe go with mqtt:// or mqtts:// urls")
+		return
+	}
+	var redis *redisMode
+	for _, u := range targetURLs {
+		if isRedisURL(u) && plugged == nil {
+			if redis, err = newRedisMode(*redisCmds, *redisPipe); err != nil {
+				errAndExit(err.Error())
+				return
+			}
+			break
+		}
+	}
+	if redis != nil {
+		for _, u := range targetURLs {
+			if pu, err := url.Parse(u); err != nil || !isRedisURL(u) || pu.Port() == "" {
+				errAndExit(fmt.Sprintf("the redis:// and rediss:// urls need a port and no other urls, not %s", u))
+				return
+			}
+		}
+		if !checkProtocolFlags(protocolFlags, "redis", "the redis:// and rediss:// urls") {
+			return
+		}
+	} else if *redisPipe != 1 {
+		errAndExit("--redis-pipeline goes with redis:// or rediss:// urls")
+		return
+	}
+	var kafka *kafkaMode
+	for _, u := range targetURLs {
+		if isKafkaURL(u) && plugged == nil {
+			var size int64
+			if *kafkaMsgSize != "" {
+				if size, err = parseBytes(*kafkaMsgSize); err != nil || size <= 0 {
+					errAndExit(fmt.Sprintf("--kafka-message-size %q must be a positive size, e.g. 1KB", *kafkaMsgSize))
+					return
+				}
+			}
+			if kafka, err = newKafkaMode(*kafkaAcks, *kafkaBatch, size); err != nil {
+				errAndExit(err.Error())
+				return
+			}
+			break
+		}
+	}
+	if kafka != nil {
+		for _, u := range targetURLs {
+			if pu, err := url.Parse(u); err != nil || !isKafkaURL(u) || pu.Port() == "" || strings.Trim(pu.Path, "/") == "" {
+				errAndExit(fmt.Sprintf("the kafka:// urls need a port, a topic as their path and no other urls, not %s", u))
+				return
+			}
+		}
+		if !checkProtocolFlags(protocolFlags, "kafka", "the kafka:// urls") {
+			return
+		}
+	} else if *kafkaAcks != "1" || *kafkaBatch != 1 || *kafkaMsgSize != "" {
+		errAndExit("--kafka-acks, --kafka-batch and --kafka-message-size go with kafka:// urls")
+		return
+	}
+	var sqlDB *sqlMode
+	for _, u := range targetURLs {
+		if isSQLURL(u) && plugged == nil {
+			pool := *sqlPool
+			if pool == 0 {
+				pool = *concurrency
+			}
+			if sqlDB, err = newSQLMode(*sqlQuery, *sqlArgs, pool); err != nil {
+				errAndExit(err.Error())
+				return
+			}
+			break
+		}
+	}
+	if sqlDB != nil {
+		for _, u := range targetURLs {
+			if !isSQLURL(u) {
+				errAndExit(fmt.Sprintf("the postgres:// and mysql:// urls can't be mixed with other urls, not %s", u))
+				return
+			}
+		}
+		if !checkProtocolFlags(protocolFlags, "sql", "the postgres:// and mysql:// urls") {
+			return
+		}
+	} else if *sqlQuery != "" || len(*sqlArgs) > 0 || *sqlPool != 0 {
+		errAndExit("--sql-query, --sql-arg and --sql-pool go with postgres:// or mysql:// urls")
+		return
+	}
+	var rawTCP *tcpMode
+	for _, u := range targetURLs {
+		if isTCPURL(u) && dnsQuery == nil && plugged == nil {
+			rawTCP = &tcpMode{size: *tcpRespSize}
+			break
+		}
+	}
+	if rawTCP != nil {
+		for _, u := range targetURLs {
+			if pu, err := url.Parse(u); err != nil || !isTCPURL(u) || pu.Port() == "" {
+				errAndExit(fmt.Sprintf("the tcp:// and tls:// urls need a port and no other urls, not %s", u))
+				return
+			}
+		}
+		if !checkProtocolFlags(protocolFlags, "tcp", "the tcp:// and tls:// urls") {
+			return
+		}
+		if *tcpRespSize < 0 {
+			errAndExit("--tcp-response-size can't be negative")
+			return
+		}
+		if *tcpDelimiter != "" {
+			if *tcpRespSize > 0 {
+				errAndExit("--tcp-response-size and --tcp-delimiter don't go together")
+				return
+			}
+			if rawTCP.delimiter, err = parseTCPDelimiter(*tcpDelimiter); err != nil {
+				errAndExit(err.Error())
+				return
+			}
+		}
+	} else if *tcpRespSize != 0 || *tcpDelimiter != "" {
+		errAndExit("--tcp-response-size and --tcp-delimiter go with tcp:// or tls:// urls")
+		return
+	}
+	if *pipeline < 0 {
+		errAndExit("--pipeline can't be negative")
+		return
+	}
+	if *pipeline > 0 && (*disableKeepalive || *followRedirects || *ntlm != "" || *negotiate || *authDigest || *grpcType != "" || *sseMode || *stream || *bodySize != "") {
+		errAndExit("--pipeline can't be used with --disable-keepalive, --follow-redirects, --ntlm, --negotiate, --digest, --grpc, --sse, --stream or --body-size")
+		return
+	}
+	if *compressed && *noCompress {
+		errAndExit("--compressed and --disable-compression can't be used together")
+		return
+	}
+	if *compressed && !hasHeader(*headers, "Accept-Encoding") {
+		*headers = append(*headers, "Accept-Encoding:"+acceptEncoding)
+	}
+	if *noCompress {
+		if hasHeader(*headers, "Accept-Encoding") {
+			errAndExit("--disable-compression can't be used with an Accept-Encoding --header")
+			return
+		}
+		*headers = append(*headers, "Accept-Encoding:identity")
+	}
+	if *authDigest && *authUser == "" {
+		errAndExit("--digest needs the --user credentials")
+		return
+	}
+	var oauth2 *oauth2Source
+	if *oauth2TokenURL != "" {
+		if oauth2, err = newOAuth2Source(*oauth2TokenURL, *oauth2Client, *oauth2User, *oauth2Scope, *insecure); err != nil {
+			errAndExit(err.Error())
+			return
+		}
+		// fails before the run rather than on each request
+		if _, err = oauth2.get(); err != nil {
+			errAndExit(err.Error())
+			return
+		}
+	}
+
+	if *reqWriteTimeout == 0 {
+		*reqWriteTimeout = *reqTimeoutAlias
+	}
+	if *respReadTimeout == 0 {
+		*respReadTimeout = *respTimeoutAlias
+	}
 
-	var err error
 	var bodyBytes []byte
 	var bodyFile string
 
@@ -251,6 +1168,47 @@ func main() {
 			*method = "POST"
 		}
 	}
+	var streamBody *bodyStream
+	if *bodySize != "" || *stream {
+		var size, chunk, rate int64
+		if *bodySize != "" {
+			if size, err = parseBytes(*bodySize); err != nil || size <= 0 {
+				errAndExit(fmt.Sprintf("--body-size %q must be a positive size, e.g. 10GB", *bodySize))
+				return
+			}
+		}
+		if chunk, err = parseBytes(*chunkSize); err != nil || chunk <= 0 || chunk > maxChunkSize {
+			errAndExit(fmt.Sprintf("--chunk-size %q must be a size from 1 to 4KB", *chunkSize))
+			return
+		}
+		if *streamRate != "" {
+			if rate, err = parseBytes(*streamRate); err != nil || rate <= 0 {
+				errAndExit(fmt.Sprintf("--stream-rate %q must be a positive size per second, e.g. 1MB", *streamRate))
+				return
+			}
+		}
+		if size > 0 && (*stream || *templating || *graphqlFile != "" || *grpcType != "" || *sseMode || len(*agents) > 0) {
+			errAndExit("--body-size can't be used with --stream, --template, --graphql, --grpc, --sse or --agent")
+			return
+		}
+		streamBody = newBodyStream(size, bodyBytes, int(chunk), rate)
+		if size > 0 && !methodSet {
+			*method = "POST"
+		}
+	}
+	if *graphqlFile != "" {
+		bodyBytes, err = buildGraphQLBody(*graphqlFile, *graphqlVars, *graphqlOp)
+		if err != nil {
+			errAndExit(err.Error())
+			return
+		}
+		if !methodSet {
+			*method = "POST"
+		}
+		if *contentType == "" {
+			*contentType = "application/json"
+		}
+	}
 
 	errWriter := io.Discard
 	if *outputErrors != "" {
@@ -262,48 +1220,192 @@ func main() {
 	}
 
 	clientOpt := ClientOpt{
-		url:       *url,
+		urls:      targetURLs,
+		weights:   weights,
 		method:    *method,
 		headers:   *headers,
 		bodyBytes: bodyBytes,
 		bodyFile:  bodyFile,
 
-		certPath: *cert,
-		keyPath:  *key,
-		insecure: *insecure,
+		bodyStream: streamBody,
+
+		certPath:   *cert,
+		keyPath:    *key,
+		caCertPath: *cacert,
+		certs:      certs,
+		insecure:   *insecure,
 
 		maxConns:     *concurrency,
 		doTimeout:    *timeout,
 		readTimeout:  *respReadTimeout,
 		writeTimeout: *reqWriteTimeout,
 		dialTimeout:  *dialTimeout,
+		grace:        *grace,
+		staleConn:    *staleConn,
 
-		socks5Proxy: *socks5,
-		httpProxy:   *httpProxy,
+		proxies:     proxyURLs,
 		contentType: *contentType,
 		host:        *host,
+		sni:         *sni,
 		unixSocket:  *unixSocket,
+		connectTo:   *connectTo,
+		dns:         dns,
+		local:       local,
+
+		ejectAfter:    *ejectAfter,
+		probeInterval: *probeInterval,
+
+		proxySources: sources,
+		graphql:      *graphqlFile != "",
+		templating:   *templating,
+		cookieJar:    *cookieJars,
+		user:         *authUser,
+		digest:       *authDigest,
+		data:         data,
+		captures:     captures,
+		extractors:   extractors,
+		extractRate:  *extractRate,
+		jwt:          jwt,
+		oauth2:       oauth2,
+	}
+	if har != nil {
+		clientOpt.targetRequests, clientOpt.sequence = har.requests, *harMode == harSequence
+	}
+	if *followRedirects {
+		clientOpt.followRedirects = *maxRedirects
+	}
+	if l := connRate.Limit(); l != nil {
+		if *l <= 0 {
+			errAndExit("--conn-rate must be positive")
+			return
+		}
+		clientOpt.connLimiter = rate.NewLimiter(*l, 1)
+	}
+	clientOpt.redirectFailure = *redirectStatus == "failure"
+	clientOpt.validate, clientOpt.validateRate = *validateFormat, *validateRate
+	if *hashBodies {
+		clientOpt.hasher = &bodyHasher{rate: *hashRate}
+	}
+	clientOpt.grpc, clientOpt.grpcMessages = *grpcType, *grpcMessages
+	clientOpt.grpcMessageRate, clientOpt.grpcStreams = *grpcMsgRate, *grpcStreams
+	clientOpt.tcp = rawTCP
+	clientOpt.dnsQuery = dnsQuery
+	clientOpt.mqtt = mqtt
+	clientOpt.redis = redis
+	clientOpt.kafka = kafka
+	clientOpt.sql = sqlDB
+	clientOpt.plugged = plugged
+	if *sseMode {
+		clientOpt.sse = &sseStats{}
+	}
+	clientOpt.disableKeepalive = *disableKeepalive
+	if *traceHdr != "" {
+		if clientOpt.traceHeader, err = parseTraceHeader(*traceHdr); err != nil {
+			errAndExit(err.Error())
+			return
+		}
+	}
+	if *failuresFile != "" {
+		clientOpt.failures = newFailureSamples(*maxFailures)
+	}
+	clientOpt.compressed = *compressed
+	clientOpt.pipeline = *pipeline
+	clientOpt.tlsTimeout = *tlsTimeout
+	if *thinkSpec != "" {
+		if clientOpt.thinkTime, err = parseThinkTime(*thinkSpec); err != nil {
+			errAndExit(err.Error())
+			return
+		}
+	}
+	if *arrival == arrivalPoisson {
+		rated := reqRate.Limit() != nil || search != nil
+		for _, st := range stages {
+			rated = rated || st.Rate != nil
+		}
+		if !rated {
+			errAndExit("--arrival poisson needs a --rate, or stages with one")
+			return
+		}
+		if *maxQueue <= 0 {
+			errAndExit("--max-queue must be positive")
+			return
+		}
+		clientOpt.arrival, clientOpt.maxQueue = *arrival, *maxQueue
+	}
+	clientOpt.stream = logStream
+	if *proxyProtocol != "" {
+		clientOpt.proxyProtocol, _ = strconv.Atoi((*proxyProtocol)[1:])
+	}
+	if len(*agents) == 0 {
+		clientOpt.auth, err = newAuthScheme(*ntlm, clientOpt.digestUser(), *negotiate, *keytabFile, *principal, *spn)
+		if err != nil {
+			errAndExit(err.Error())
+			return
+		}
 	}
 
-	requester, err := NewRequester(*concurrency, *requests, *duration, reqRate.Limit(), errWriter, &clientOpt, *rampUp)
-	if err != nil {
-		errAndExit(err.Error())
-		return
+	var requester recordSource
+	if len(*agents) > 0 {
+		job := newAgentJob(&clientOpt, *concurrency, *requests, *duration, reqRate.Limit(), *rampUp, stages)
+		job.NTLM, job.Negotiate, job.Keytab, job.Principal, job.SPN = *ntlm, *negotiate, *keytabFile, *principal, *spn
+		job.Resolver, job.DNSRefresh, job.ResolveOnce, job.DNSRoundRobin = *resolver, *dnsRefresh, *resolveOnce, *dnsRoundRobin
+		job.JWTKey, job.JWTClaims, job.JWTAlg, job.JWTTTL, job.JWTHeader = jwtKeyData, jwtClaimsData, *jwtAlg, *jwtTTL, *jwtHeader
+		job.OAuth2TokenURL, job.OAuth2Client, job.OAuth2User, job.OAuth2Scope = *oauth2TokenURL, *oauth2Client, *oauth2User, *oauth2Scope
+		requester = NewController(*agents, job, agentConn, errWriter)
+	} else {
+		r, err := NewRequester(*concurrency, *requests, *duration, reqRate.Limit(), errWriter, &clientOpt, *rampUp)
+		if err != nil {
+			errAndExit(err.Error())
+			return
+		}
+		r.stages = stages
+		r.search = search
+		requester = r
 	}
 
 	// description
 	var desc string
-	desc = fmt.Sprintf("Benchmarking %s", *url)
+	desc = fmt.Sprintf("Benchmarking %s", strings.Join(shownURLs, ", "))
+	if har != nil {
+		desc = fmt.Sprintf("Replaying %d request(s) of %s in %s mode", len(targetURLs), *harFile, *harMode)
+	}
+	if logStream != nil {
+		desc = fmt.Sprintf("Replaying %d request(s) of %s on %s", len(logStream.entries), *accessLog, targetURLs[0])
+		if logStream.speed > 0 {
+			desc += fmt.Sprintf(" at %sx speed over %s", formatFloat64(logStream.speed), logStream.span())
+		}
+	}
 	if *requests > 0 {
 		desc += fmt.Sprintf(" with %d request(s)", *requests)
 	}
-	if *duration > 0 {
+	if !deadline.IsZero() {
+		desc += fmt.Sprintf(" until %s", deadline.Format(time.RFC3339))
+	} else if *duration > 0 {
 		desc += fmt.Sprintf(" for %s", duration.String())
 	}
 	if *rampUp > 0 {
 		desc += fmt.Sprintf(" with ramp up %d pre second", *rampUp)
 	}
-	desc += fmt.Sprintf(" using %d connection(s).", *concurrency)
+	if len(stages) > 0 {
+		desc += fmt.Sprintf(" in %d stage(s)", len(stages))
+	}
+	if search != nil {
+		desc += fmt.Sprintf(" searching the max rate within %s req/s in steps of %s", *searchSpec, *searchStep)
+	}
+	desc += fmt.Sprintf(" using %d connection(s)", *concurrency)
+	if clientOpt.thinkTime != nil {
+		desc += fmt.Sprintf(" thinking %s between requests", clientOpt.thinkTime)
+	}
+	if clientOpt.arrival == arrivalPoisson {
+		desc += fmt.Sprintf(" with poisson arrivals queued up to %d", clientOpt.maxQueue)
+	}
+	if len(*agents) > 0 {
+		desc += fmt.Sprintf(" across %d agent(s)", len(*agents))
+		if c, ok := requester.(*Controller); ok && len(c.regions) > 0 {
+			desc += fmt.Sprintf(" in %d region(s)", len(c.regions))
+		}
+	}
+	desc += "."
 	fmt.Fprintln(os.Stderr, desc)
 
 	// charts listener
@@ -318,16 +1420,107 @@ func main() {
 	}
 	fmt.Fprintln(os.Stderr, "")
 
+	var guard *guardrail
+	if *guardrailURL != "" {
+		if guard, err = newGuardrail(*guardrailURL, *guardrailInterval); err != nil {
+			errAndExit(err.Error())
+			return
+		}
+		// no run while the guardrail already says abort
+		reason, err := guard.check()
+		if err != nil {
+			errAndExit(err.Error())
+			return
+		}
+		if reason != "" {
+			errAndExit("guardrail: " + reason)
+			return
+		}
+	}
+
 	// do request
 	go requester.Run()
+	if !deadline.IsZero() {
+		// the run may have started late, e.g. on agents, so it ends on the clock
+		stop := time.AfterFunc(time.Until(deadline), requester.Cancel)
+		defer stop.Stop()
+	}
 
 	// metrics collection
-	report := NewStreamReport()
+	report := NewStreamReport(requester.Targets())
+	report.backpressure = requester.Backpressure()
+	report.interrupted = requester.Interrupted()
+	report.proxies = proxyURLs
+	report.extracts = extractors
+	report.urls, report.weights = shownURLs, weights
+	if har != nil {
+		report.urls = har.labels()
+	}
+	report.certs = certs
+	report.redirectFailure = clientOpt.redirectFailure
+	report.grpcMode = clientOpt.grpc
+	if dnsQuery != nil {
+		report.dnsType = dnsQuery.typeName
+	}
+	report.sse = clientOpt.sse
+	report.mqtt = clientOpt.mqtt
+	report.redis = clientOpt.redis
+	report.kafka = clientOpt.kafka
+	report.sql = clientOpt.sql
+	report.plugged = clientOpt.plugged
+	report.arrival, report.maxQueue = clientOpt.arrival, clientOpt.maxQueue
+	if logStream != nil {
+		report.replaySpeed, report.replayEntries = logStream.speed, len(logStream.entries)
+	}
+	report.disableKeepalive = clientOpt.disableKeepalive
+	report.compressed = clientOpt.compressed
+	if clientOpt.pipeline > 0 {
+		report.pipeline = &PipelineReport{Depth: clientOpt.pipeline, Connections: pipelineConns(*concurrency, clientOpt.pipeline)}
+	}
+	report.soak = *soak
+	if *autoWarmup > 0 || *warmup > 0 {
+		report.warmup = &warmupDetector{max: *autoWarmup, fixed: *warmup}
+	}
+	if c, ok := requester.(*Controller); ok {
+		report.regions = c.regions
+	}
+	if search != nil {
+		report.search = search
+		search.setSnapshot(func(i int) *SnapshotReport { return stageSnapshot(report.Snapshot(), i) })
+	}
+	if *probeAddr != "" {
+		report.probe = newServerProbe(*probeAddr, agentConn)
+		go report.probe.follow(report.Done())
+	}
 	go report.Collect(requester.RecordChan())
 
+	guardDone := make(chan struct{})
+	if certs != nil && *certReload > 0 {
+		go certs.watch(*certReload, guardDone)
+	}
+	if remote != nil {
+		go remote.watch(report.Snapshot, guardDone)
+	}
+	if guard != nil {
+		go guard.watch(guardDone, func(reason string) {
+			report.Abort(reason)
+			requester.Cancel()
+		})
+	}
+
+	var csvWriter *tickCSVWriter
+	if *csvFile != "" {
+		csvWriter, err = newTickCSVWriter(*csvFile, report)
+		if err != nil {
+			errAndExit(err.Error())
+			return
+		}
+		go csvWriter.Run()
+	}
+
 	if ln != nil {
 		// serve charts data
-		charts, err := NewCharts(ln, report.Charts, desc)
+		charts, err := NewCharts(ln, report.Charts, desc, extractors, clientOpt.redirectFailure, report.probe != nil)
 		if err != nil {
 			errAndExit(err.Error())
 			return
@@ -337,5 +1530,93 @@ func main() {
 
 	// terminal printer
 	printer := NewPrinter(*requests, *duration, !*clean, *summary)
-	printer.PrintLoop(report.Snapshot, *interval, *seconds, *jsonFormat, report.Done())
+	printer.thresholds = thresholds
+	printer.stages = stages
+	printInterval := *interval
+	if *tuiMode {
+		dashboard := &tui{desc: desc, maxNum: *requests, maxDuration: *duration, useSeconds: *seconds, source: requester}
+		dashboard.run(report.Snapshot, report.Charts, max(*interval, 100*time.Millisecond), report.Done())
+		// the final tables only, back on the main screen
+		printInterval = 0
+	}
+	final := printer.PrintLoop(report.Snapshot, printInterval, *seconds, *jsonFormat, *summaryJSON, report.Done())
+	close(guardDone)
+	if csvWriter != nil {
+		if err := csvWriter.Close(); err != nil {
+			errAndExit(err.Error())
+			return
+		}
+	}
+
+	results := evaluateThresholds(thresholds, final)
+	stageResults := evaluateStages(stages, final, *seconds)
+	searchFailed := false
+	if search != nil {
+		// the report of the steps, without their verdicts failing the run
+		stages = search.stages()
+		searchFailed = final.Search.Rate == 0
+	}
+	if *junitFile != "" {
+		suites := []*junitTestSuite{newJUnitSuite("thresholds", desc, final, results)}
+		for i, sr := range stageResults {
+			suites = append(suites, newJUnitSuite("stage"+strconv.Itoa(i+1), sr.Stage, stageSnapshot(final, i), sr.Thresholds))
+		}
+		if err := writeJUnitFile(*junitFile, suites...); err != nil {
+			errAndExit(err.Error())
+			return
+		}
+	}
+	if *capacityFile != "" {
+		if search != nil {
+			stageResults = evaluateStages(stages, final, *seconds)
+		}
+		if err := writeCapacityReport(*capacityFile, desc, stages, final, stageResults); err != nil {
+			errAndExit(err.Error())
+			return
+		}
+	}
+	if *openMetricsFile != "" {
+		if err := writeOpenMetricsFile(*openMetricsFile, final, results); err != nil {
+			errAndExit(err.Error())
+			return
+		}
+	}
+	if clientOpt.failures != nil {
+		if err := writeFailuresFile(*failuresFile, clientOpt.failures); err != nil {
+			errAndExit(err.Error())
+			return
+		}
+	}
+	uploadFailed := false
+	if remote != nil {
+		if err := remote.push(final, results); err != nil {
+			fmt.Fprintf(os.Stderr, "plow: remote write to %s failed: %s\n", remote.url, err)
+			uploadFailed = true
+		}
+	}
+	runID := ""
+	if len(reportUploads) > 0 {
+		var jsonReport bytes.Buffer
+		printer.PrintJSON(&jsonReport, final, *seconds)
+		page := htmlReport(desc, printer.FormatText(final, true, *seconds))
+		keyData := newUploadKeyData(targetURLs)
+		runID = keyData.RunID
+		for _, u := range reportUploads {
+			dest, err := u.upload(keyData, jsonReport.Bytes(), page)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "plow: upload to %s://%s/%s failed: %s\n", u.scheme, u.bucket, u.keySource, err)
+				uploadFailed = true
+				continue
+			}
+			fmt.Fprintf(os.Stderr, "@ Report uploaded to %s\n", dest)
+		}
+	}
+	if len(webhooks) > 0 {
+		s := NewSummary(final, *seconds)
+		s.Thresholds, s.Stages = results, stageResults
+		notifyWebhooks(webhooks, newWebhookEvent(runID, desc, s))
+	}
+	if !thresholdsPassed(results) || !stagesPassed(stageResults) || searchFailed || uploadFailed || final.Aborted != "" {
+		os.Exit(1)
+	}
 }
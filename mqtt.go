@@ -0,0 +1,504 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	mrand "math/rand"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// the MQTT 3.1.1 packets sent and read
+const (
+	mqttConnect   = 0x10
+	mqttConnack   = 0x20
+	mqttPublish   = 0x30
+	mqttPuback    = 0x40
+	mqttSubscribe = 0x82
+	mqttSuback    = 0x90
+)
+
+// mqttTimeout bounds the connect and subscribe of the subscribers
+const mqttTimeout = 10 * time.Second
+
+var mqttConnackErrors = []string{"", "unacceptable protocol version", "identifier rejected", "server unavailable",
+	"bad user name or password", "not authorized"}
+
+// isMQTTURL tells the mqtt:// and mqtts:// urls of the brokers
+func isMQTTURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	return err == nil && (u.Scheme == "mqtt" || u.Scheme == "mqtts")
+}
+
+// MQTTReport is the delivery of the messages published to the subscribers
+// of --mqtt-subscribe, Latency being the time from their publish
+type MQTTReport struct {
+	QoS       int
+	Delivered int64
+	Rate      float64 // messages delivered per second
+	Latency   *ConnLatencyReport
+}
+
+// mqttMode publishes the body of each request to the next topic of
+// --mqtt-topic, at --mqtt-qos. With subscriptions, the payload starts with
+// the time it was published at, read back by the subscribers.
+type mqttMode struct {
+	topics    *templateCycle
+	qos       int
+	subscribe []string
+	stats     *mqttStats // nil without subscriptions
+	// subscribers read the messages of --mqtt-subscribe during the run
+	subscribers *mqttSubscribers
+}
+
+func newMQTTMode(topics []string, qos int, subscribe []string) (*mqttMode, error) {
+	if qos != 0 && qos != 1 {
+		return nil, fmt.Errorf("--mqtt-qos must be 0 or 1")
+	}
+	cycle, err := newTemplateCycle("--mqtt-topic", topics)
+	if err != nil {
+		return nil, err
+	}
+	m := &mqttMode{topics: cycle, qos: qos, subscribe: subscribe}
+	if len(subscribe) > 0 {
+		m.stats = &mqttStats{latency: newConnLatency()}
+	}
+	return m, nil
+}
+
+// mqttStats are the deliveries to the subscribers, read by the report as
+// they are not requests
+type mqttStats struct {
+	mu        sync.Mutex
+	delivered int64
+	latency   *connLatency
+}
+
+func (s *mqttStats) deliver(latency time.Duration) {
+	s.mu.Lock()
+	s.delivered++
+	if latency >= 0 {
+		s.latency.insert(float64(latency))
+	}
+	s.mu.Unlock()
+}
+
+func (s *mqttStats) report(qos int, elapsed float64) *MQTTReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rs := &MQTTReport{QoS: qos, Delivered: s.delivered}
+	if elapsed > 0 {
+		rs.Rate = float64(s.delivered) / elapsed
+	}
+	if s.latency.stats.count > 0 {
+		rs.Latency = s.latency.snapshot()
+	}
+	return rs
+}
+
+// mqttString appends s with its 2-byte length
+func mqttString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+// mqttPacket appends the packet of type typ, body being its variable header
+// and payload
+func mqttPacket(b []byte, typ byte, body []byte) []byte {
+	b = append(b, typ)
+	n := len(body)
+	for {
+		d := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			d |= 0x80
+		}
+		b = append(b, d)
+		if n == 0 {
+			break
+		}
+	}
+	return append(b, body...)
+}
+
+// mqttConn is a connection to a broker, CONNECTed as a clean session
+type mqttConn struct {
+	net.Conn
+	br     *bufio.Reader
+	nextID uint16
+	out    []byte
+	body   []byte
+	in     []byte
+}
+
+// mqttDial connects to the broker of rawURL through dial, with the user and
+// password of the url, waiting for its CONNACK
+func mqttDial(dial func() (net.Conn, error), rawURL string) (*mqttConn, error) {
+	nc, err := dial()
+	if err != nil {
+		return nil, err
+	}
+	c := &mqttConn{Conn: nc, br: bufio.NewReader(nc)}
+	if err = c.connect(rawURL); err != nil {
+		nc.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *mqttConn) connect(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	id := make([]byte, 12)
+	for i := range id {
+		id[i] = randChars[mrand.Intn(len(randChars))]
+	}
+	// clean session, no keepalive as the connections are busy
+	flags := byte(0x02)
+	if u.User != nil {
+		flags |= 0x80
+		if _, ok := u.User.Password(); ok {
+			flags |= 0x40
+		}
+	}
+	body := mqttString(nil, "MQTT")
+	body = append(body, 4, flags, 0, 0)
+	body = mqttString(body, "plow-"+string(id))
+	if u.User != nil {
+		body = mqttString(body, u.User.Username())
+		if pass, ok := u.User.Password(); ok {
+			body = mqttString(body, pass)
+		}
+	}
+	if _, err = c.Write(mqttPacket(nil, mqttConnect, body)); err != nil {
+		return err
+	}
+	typ, b, err := c.read()
+	if err != nil {
+		return err
+	}
+	if typ != mqttConnack || len(b) != 2 {
+		return errors.New("mqtt: no CONNACK from the broker")
+	}
+	if code := int(b[1]); code != 0 {
+		if code < len(mqttConnackErrors) {
+			return fmt.Errorf("mqtt: broker refused the connection: %s", mqttConnackErrors[code])
+		}
+		return fmt.Errorf("mqtt: broker refused the connection: code %d", code)
+	}
+	return nil
+}
+
+// read reads the next packet, its body being valid until the next read
+func (c *mqttConn) read() (byte, []byte, error) {
+	typ, err := c.br.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	n, shift := 0, 0
+	for {
+		d, err := c.br.ReadByte()
+		if err != nil {
+			return 0, nil, unexpectedEOF(err, 1)
+		}
+		n |= int(d&0x7f) << shift
+		if d&0x80 == 0 {
+			break
+		}
+		if shift += 7; shift > 21 {
+			return 0, nil, errors.New("mqtt: malformed packet length")
+		}
+	}
+	if cap(c.in) < n {
+		c.in = make([]byte, n)
+	}
+	if _, err = io.ReadFull(c.br, c.in[:n]); err != nil {
+		return 0, nil, unexpectedEOF(err, 1)
+	}
+	return typ, c.in[:n], nil
+}
+
+// publish sends payload to topic, prefixed by the time now when stamped,
+// waiting for its PUBACK at QoS 1
+func (c *mqttConn) publish(topic string, payload []byte, qos int, stamped bool, now time.Time) (int, error) {
+	typ := byte(mqttPublish)
+	c.body = mqttString(c.body[:0], topic)
+	var id uint16
+	if qos > 0 {
+		typ |= byte(qos) << 1
+		c.nextID++
+		if c.nextID == 0 {
+			c.nextID = 1
+		}
+		id = c.nextID
+		c.body = binary.BigEndian.AppendUint16(c.body, id)
+	}
+	if stamped {
+		c.body = binary.BigEndian.AppendUint64(c.body, uint64(now.UnixNano()))
+	}
+	c.body = append(c.body, payload...)
+	c.out = mqttPacket(c.out[:0], typ, c.body)
+	if _, err := c.Write(c.out); err != nil {
+		return 0, err
+	}
+	if qos == 0 {
+		return 0, nil
+	}
+	for {
+		typ, b, err := c.read()
+		if err != nil {
+			return 0, err
+		}
+		if typ&0xf0 == mqttPuback && len(b) >= 2 && binary.BigEndian.Uint16(b) == id {
+			return len(b) + 2, nil
+		}
+	}
+}
+
+// mqttConns are the connections of a worker to the brokers of the mqtt://
+// and mqtts:// urls, by client, dialed like the HTTP ones and timed by their
+// tracker
+type mqttConns struct {
+	r     *Requester
+	mode  *mqttMode
+	conns map[int]*mqttConn
+	tmpl  bytes.Buffer
+}
+
+func newMQTTConns(r *Requester) *mqttConns {
+	return &mqttConns{r: r, mode: r.clientOpt.mqtt, conns: make(map[int]*mqttConn)}
+}
+
+// setup subscribes to the topics of --mqtt-subscribe, read during the run
+func (m *mqttMode) setup(r *Requester) error {
+	if len(m.subscribe) == 0 {
+		return nil
+	}
+	var err error
+	m.subscribers, err = m.subscribeAll(r.targets, r.clientOpt)
+	return err
+}
+
+func (m *mqttMode) conns(r *Requester, worker int) protocolConns { return newMQTTConns(r) }
+
+func (m *mqttMode) teardown() {
+	if m.subscribers != nil {
+		m.subscribers.close()
+	}
+}
+
+func (c *mqttConns) build() error { return nil }
+
+func (c *mqttConns) do(x *exchange, rr *ReportRecord) {
+	c.publish(x.ci, x.t, x.client, x.pt, x.req, rr)
+}
+
+// close closes the connections of the worker at its end
+func (c *mqttConns) close() {
+	for ci, conn := range c.conns {
+		conn.Close()
+		delete(c.conns, ci)
+	}
+}
+
+// publish publishes the body of req on the connection ci, the latency of rr
+// being the write of the message at QoS 0 and the time to its PUBACK at QoS
+// 1, with the connect and CONNACK of a new connection
+func (c *mqttConns) publish(ci int, t *target, client *fasthttp.HostClient, pt *phaseTracker, req *fasthttp.Request, rr *ReportRecord) {
+	opt := c.r.clientOpt
+	rr.reset()
+
+	topic, err := c.mode.topics.take(&c.tmpl)
+	payload := req.Body()
+	start := time.Now()
+	n := 0
+	if err == nil {
+		// a connection the broker closed while idle is dialed again once
+		for attempt := 0; attempt < 2; attempt++ {
+			pt.start()
+			start = time.Now()
+			conn := c.conns[ci]
+			rr.cold = conn == nil
+			if conn == nil {
+				dial := func() (net.Conn, error) {
+					nc, err := client.Dial(client.Addr)
+					if err == nil {
+						// the CONNACK is waited for like a PUBACK
+						c.deadlines(nc)
+					}
+					return nc, err
+				}
+				if conn, err = mqttDial(dial, t.url); err != nil {
+					break
+				}
+				c.conns[ci] = conn
+			}
+			c.deadlines(conn)
+			if n, err = conn.publish(topic, payload, c.mode.qos, c.mode.stats != nil, time.Now()); err == nil || rr.cold {
+				break
+			}
+			conn.Close()
+			delete(c.conns, ci)
+			rr.stale = staleRetried
+		}
+	}
+	done := time.Now()
+	rr.cost = done.Sub(start)
+	rr.addr = pt.addr
+	rr.phases = pt.times(rr.cost, done)
+	rr.reqSize, rr.respSize = int64(len(payload)), int64(n)
+	rr.error = ""
+	if err != nil {
+		rr.error = err.Error()
+		rr.errClass = classifyError(err)
+	}
+	if conn := c.conns[ci]; conn != nil && (err != nil || opt.disableKeepalive) {
+		conn.Close()
+		delete(c.conns, ci)
+	}
+}
+
+// deadlines sets the deadlines of a publish on conn
+func (c *mqttConns) deadlines(conn net.Conn) {
+	opt := c.r.clientOpt
+	now := time.Now()
+	var deadline time.Time
+	if opt.doTimeout > 0 {
+		deadline = now.Add(opt.doTimeout)
+	}
+	if opt.writeTimeout > 0 {
+		_ = conn.SetWriteDeadline(now.Add(opt.writeTimeout))
+	} else {
+		_ = conn.SetWriteDeadline(deadline)
+	}
+	if opt.readTimeout > 0 {
+		_ = conn.SetReadDeadline(now.Add(opt.readTimeout))
+	} else {
+		_ = conn.SetReadDeadline(deadline)
+	}
+}
+
+// mqttSubscribers are the connections of --mqtt-subscribe, one per topic
+// filter and broker, reading the messages until they are closed
+type mqttSubscribers struct {
+	conns []*mqttConn
+	wg    sync.WaitGroup
+}
+
+// subscribeAll subscribes to the filters of --mqtt-subscribe on the broker
+// of each host of targets, ahead of the run
+func (m *mqttMode) subscribeAll(targets *targetPool, opt *ClientOpt) (*mqttSubscribers, error) {
+	s := &mqttSubscribers{}
+	seen := make(map[int]bool)
+	for _, t := range targets.targets {
+		if seen[t.slot] {
+			continue
+		}
+		seen[t.slot] = true
+		for _, filter := range m.subscribe {
+			conn, err := m.subscribeTo(t, opt, filter)
+			if err != nil {
+				s.close()
+				return nil, fmt.Errorf("--mqtt-subscribe %s on %s: %w", filter, t.httpClient.Addr, err)
+			}
+			s.conns = append(s.conns, conn)
+			s.wg.Add(1)
+			go func() {
+				defer s.wg.Done()
+				m.receive(conn)
+			}()
+		}
+	}
+	return s, nil
+}
+
+func (m *mqttMode) subscribeTo(t *target, opt *ClientOpt, filter string) (*mqttConn, error) {
+	client := t.httpClient
+	dial := func() (net.Conn, error) {
+		conn, err := client.Dial(client.Addr)
+		if err != nil || !client.IsTLS {
+			return conn, err
+		}
+		cfg := client.TLSConfig.Clone()
+		if cfg.ServerName == "" {
+			cfg.ServerName, _, _ = net.SplitHostPort(client.Addr)
+		}
+		tlsConn := tls.Client(conn, cfg)
+		_ = tlsConn.SetDeadline(time.Now().Add(mqttTimeout))
+		if err = tlsConn.Handshake(); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return tlsConn, nil
+	}
+	conn, err := mqttDial(dial, t.url)
+	if err != nil {
+		return nil, err
+	}
+	_ = conn.SetDeadline(time.Now().Add(mqttTimeout))
+	body := binary.BigEndian.AppendUint16(nil, 1)
+	body = mqttString(body, filter)
+	body = append(body, byte(m.qos))
+	if _, err = conn.Write(mqttPacket(nil, mqttSubscribe, body)); err == nil {
+		var typ byte
+		var b []byte
+		if typ, b, err = conn.read(); err == nil && (typ != mqttSuback || len(b) != 3 || b[2] == 0x80) {
+			err = errors.New("mqtt: subscription refused by the broker")
+		}
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	_ = conn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+// receive reads the messages of conn until it is closed, acknowledging the
+// ones of QoS 1
+func (m *mqttMode) receive(conn *mqttConn) {
+	for {
+		typ, b, err := conn.read()
+		if err != nil {
+			return
+		}
+		if typ&0xf0 != mqttPublish || len(b) < 2 {
+			continue
+		}
+		now := time.Now()
+		n := 2 + int(binary.BigEndian.Uint16(b))
+		qos := int(typ>>1) & 3
+		if qos > 0 && n+2 <= len(b) {
+			ack := []byte{mqttPuback, 2, b[n], b[n+1]}
+			n += 2
+			if _, err = conn.Write(ack); err != nil {
+				return
+			}
+		}
+		latency := time.Duration(-1)
+		if n+8 <= len(b) {
+			sent := time.Unix(0, int64(binary.BigEndian.Uint64(b[n:])))
+			latency = max(now.Sub(sent), 0)
+		}
+		m.stats.deliver(latency)
+	}
+}
+
+// close closes the connections of the subscribers and waits for their ends
+func (s *mqttSubscribers) close() {
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+	s.wg.Wait()
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"golang.org/x/crypto/md4"
+)
+
+// NTLM messages (MS-NLMP), only the NTLMv2 response is implemented
+
+var ntlmSignature = []byte("NTLMSSP\x00")
+
+const (
+	ntlmNegotiateUnicode                 = 0x00000001
+	ntlmNegotiateOEM                     = 0x00000002
+	ntlmRequestTarget                    = 0x00000004
+	ntlmNegotiateNTLM                    = 0x00000200
+	ntlmNegotiateAlwaysSign              = 0x00008000
+	ntlmNegotiateExtendedSessionSecurity = 0x00080000
+	ntlmNegotiateTargetInfo              = 0x00800000
+	ntlmNegotiate128                     = 0x20000000
+	ntlmNegotiate56                      = 0x80000000
+
+	ntlmAvEOL       = 0
+	ntlmAvTimestamp = 7
+)
+
+// ntlmCredentials are parsed from `DOMAIN\USER:PASSWORD` or `USER@DOMAIN:PASSWORD`
+type ntlmCredentials struct {
+	domain   string
+	user     string
+	password string
+}
+
+func parseNTLMCredentials(s string) (*ntlmCredentials, error) {
+	i := strings.Index(s, ":")
+	if i <= 0 {
+		return nil, fmt.Errorf("ntlm credentials %q don't match the \"DOMAIN\\USER:PASSWORD\" format", s)
+	}
+	c := &ntlmCredentials{user: s[:i], password: s[i+1:]}
+	if j := strings.Index(c.user, `\`); j >= 0 {
+		c.domain, c.user = c.user[:j], c.user[j+1:]
+	} else if j := strings.LastIndex(c.user, "@"); j >= 0 {
+		c.user, c.domain = c.user[:j], c.user[j+1:]
+	}
+	return c, nil
+}
+
+func utf16le(s string) []byte {
+	u := utf16.Encode([]rune(s))
+	b := make([]byte, 2*len(u))
+	for i, r := range u {
+		binary.LittleEndian.PutUint16(b[2*i:], r)
+	}
+	return b
+}
+
+func hmacMD5(key []byte, data ...[]byte) []byte {
+	h := hmac.New(md5.New, key)
+	for _, d := range data {
+		h.Write(d)
+	}
+	return h.Sum(nil)
+}
+
+func ntlmNegotiateMessage() []byte {
+	msg := make([]byte, 32)
+	copy(msg, ntlmSignature)
+	binary.LittleEndian.PutUint32(msg[8:], 1)
+	binary.LittleEndian.PutUint32(msg[12:], ntlmNegotiateUnicode|ntlmNegotiateOEM|ntlmRequestTarget|ntlmNegotiateNTLM|
+		ntlmNegotiateAlwaysSign|ntlmNegotiateExtendedSessionSecurity|ntlmNegotiate128|ntlmNegotiate56)
+	return msg
+}
+
+type ntlmChallenge struct {
+	flags      uint32
+	challenge  []byte
+	targetInfo []byte
+}
+
+func ntlmField(msg []byte, off int) ([]byte, error) {
+	if len(msg) < off+8 {
+		return nil, fmt.Errorf("ntlm challenge is too short")
+	}
+	l := int(binary.LittleEndian.Uint16(msg[off:]))
+	o := int(binary.LittleEndian.Uint32(msg[off+4:]))
+	if o+l > len(msg) {
+		return nil, fmt.Errorf("ntlm challenge field is out of range")
+	}
+	return msg[o : o+l], nil
+}
+
+func parseNTLMChallenge(msg []byte) (*ntlmChallenge, error) {
+	if len(msg) < 32 || !bytes.Equal(msg[:8], ntlmSignature) || binary.LittleEndian.Uint32(msg[8:]) != 2 {
+		return nil, fmt.Errorf("invalid ntlm challenge")
+	}
+	c := &ntlmChallenge{
+		flags:     binary.LittleEndian.Uint32(msg[20:]),
+		challenge: msg[24:32],
+	}
+	if c.flags&ntlmNegotiateTargetInfo != 0 {
+		var err error
+		if c.targetInfo, err = ntlmField(msg, 40); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// timestamp returns MsvAvTimestamp of the target info, if any
+func (c *ntlmChallenge) timestamp() []byte {
+	info := c.targetInfo
+	for len(info) >= 4 {
+		id := binary.LittleEndian.Uint16(info)
+		l := int(binary.LittleEndian.Uint16(info[2:]))
+		if id == ntlmAvEOL || len(info) < 4+l {
+			break
+		}
+		if id == ntlmAvTimestamp && l == 8 {
+			return info[4:12]
+		}
+		info = info[4+l:]
+	}
+	return nil
+}
+
+// ntowfv2 is the NTOWFv2 of cred, the key of its responses
+func ntowfv2(cred *ntlmCredentials) []byte {
+	h := md4.New()
+	h.Write(utf16le(cred.password))
+	return hmacMD5(h.Sum(nil), utf16le(strings.ToUpper(cred.user)+cred.domain))
+}
+
+// ntlmV2Responses are the LMv2 and NTLMv2 responses of cred to c with
+// clientChallenge at timestamp, a FILETIME
+func ntlmV2Responses(cred *ntlmCredentials, c *ntlmChallenge, clientChallenge, timestamp []byte) ([]byte, []byte) {
+	v2Hash := ntowfv2(cred)
+	lm := append(hmacMD5(v2Hash, c.challenge, clientChallenge), clientChallenge...)
+
+	var temp bytes.Buffer
+	temp.Write([]byte{1, 1, 0, 0, 0, 0, 0, 0})
+	temp.Write(timestamp)
+	temp.Write(clientChallenge)
+	temp.Write([]byte{0, 0, 0, 0})
+	temp.Write(c.targetInfo)
+	temp.Write([]byte{0, 0, 0, 0})
+	nt := append(hmacMD5(v2Hash, c.challenge, temp.Bytes()), temp.Bytes()...)
+	return lm, nt
+}
+
+func ntlmAuthenticateMessage(cred *ntlmCredentials, c *ntlmChallenge) ([]byte, error) {
+	clientChallenge := make([]byte, 8)
+	if _, err := rand.Read(clientChallenge); err != nil {
+		return nil, err
+	}
+	timestamp := c.timestamp()
+	withLM := timestamp == nil
+	if withLM {
+		timestamp = make([]byte, 8)
+		// FILETIME, 100ns since 1601-01-01
+		binary.LittleEndian.PutUint64(timestamp, uint64(time.Now().UnixNano()/100+116444736000000000))
+	}
+	lm, nt := ntlmV2Responses(cred, c, clientChallenge, timestamp)
+	if !withLM {
+		// the server checking the MsvAvTimestamp of its challenge
+		lm = make([]byte, 24)
+	}
+
+	domain, user := utf16le(cred.domain), utf16le(cred.user)
+	payloads := [][]byte{lm, nt, domain, user, nil, nil} // workstation, session key
+	msg := make([]byte, 64)
+	copy(msg, ntlmSignature)
+	binary.LittleEndian.PutUint32(msg[8:], 3)
+	off := len(msg)
+	for i, p := range payloads {
+		binary.LittleEndian.PutUint16(msg[12+8*i:], uint16(len(p)))
+		binary.LittleEndian.PutUint16(msg[14+8*i:], uint16(len(p)))
+		binary.LittleEndian.PutUint32(msg[16+8*i:], uint32(off))
+		off += len(p)
+	}
+	flags := c.flags &^ ntlmNegotiateOEM
+	binary.LittleEndian.PutUint32(msg[60:], flags|ntlmNegotiateUnicode)
+	for _, p := range payloads {
+		msg = append(msg, p...)
+	}
+	return msg, nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func unhex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(strings.Join(strings.Fields(s), ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// the CHALLENGE_MESSAGE of the NTLMv2 examples of MS-NLMP 4.2.4.3
+const ntlmSpecChallenge = `
+4e 54 4c 4d 53 53 50 00 02 00 00 00 0c 00 0c 00 38 00 00 00 33 82 8a e2 01 23 45 67 89 ab cd ef
+00 00 00 00 00 00 00 00 24 00 24 00 44 00 00 00 06 00 70 17 00 00 00 0f 53 00 65 00 72 00 76 00
+65 00 72 00 02 00 0c 00 44 00 6f 00 6d 00 61 00 69 00 6e 00 01 00 0c 00 53 00 65 00 72 00 76 00
+65 00 72 00 00 00 00 00`
+
+// TestNTLMv2 checks the NTLMv2 examples of MS-NLMP 4.2.4
+func TestNTLMv2(t *testing.T) {
+	cred := &ntlmCredentials{domain: "Domain", user: "User", password: "Password"}
+	c, err := parseNTLMChallenge(unhex(t, ntlmSpecChallenge))
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientChallenge := unhex(t, "aa aa aa aa aa aa aa aa")
+	timestamp := make([]byte, 8)
+	lm, nt := ntlmV2Responses(cred, c, clientChallenge, timestamp)
+
+	tests := []struct {
+		name string // of 4.2.4
+		got  []byte
+		want string
+	}{
+		{"server challenge", c.challenge, "01 23 45 67 89 ab cd ef"},
+		{"NTOWFv2", ntowfv2(cred), "0c 86 8a 40 3b fd 7a 93 a3 00 1e f2 2e f0 2e 3f"},
+		{"LMv2 response", lm, "86 c3 50 97 ac 9c ec 10 25 54 76 4a 57 cc cc 19 aa aa aa aa aa aa aa aa"},
+		{"NTProofStr", nt[:16], "68 cd 0a b8 51 e5 1c 96 aa bc 92 7b eb ef 6a 1c"},
+		{"session base key", hmacMD5(ntowfv2(cred), nt[:16]), "8d e4 0c ca db c1 4a 82 f1 5c b0 ad 0d e9 5c a3"},
+		{"temp", nt[16:], `01 01 00 00 00 00 00 00 00 00 00 00 00 00 00 00 aa aa aa aa aa aa aa aa 00 00 00 00
+			02 00 0c 00 44 00 6f 00 6d 00 61 00 69 00 6e 00 01 00 0c 00 53 00 65 00 72 00 76 00 65 00 72 00
+			00 00 00 00 00 00 00 00`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if want := unhex(t, tt.want); !bytes.Equal(tt.got, want) {
+				t.Errorf("got % x, want % x", tt.got, want)
+			}
+		})
+	}
+}
+
+func TestParseNTLMCredentials(t *testing.T) {
+	tests := []struct {
+		in                     string
+		domain, user, password string
+	}{
+		{`Domain\User:Password`, "Domain", "User", "Password"},
+		{`User@Domain:Pass:word`, "Domain", "User", "Pass:word"},
+		{`User:Password`, "", "User", "Password"},
+	}
+	for _, tt := range tests {
+		c, err := parseNTLMCredentials(tt.in)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.in, err)
+		}
+		if c.domain != tt.domain || c.user != tt.user || c.password != tt.password {
+			t.Errorf("%s: got %+v", tt.in, *c)
+		}
+	}
+	if _, err := parseNTLMCredentials(":Password"); err == nil {
+		t.Error("no user: no error")
+	}
+}
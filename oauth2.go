@@ -0,0 +1,158 @@
+package main
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// oauth2Source fetches a bearer token from an OAuth2 token endpoint with the
+// client credentials or password grant, shared by all the workers and
+// fetched again, or refreshed with its refresh token, ahead of its expiry.
+type oauth2Source struct {
+	tokenURL string
+	clientID string
+	secret   string
+	form     url.Values // of the grant
+	client   *http.Client
+
+	mu      sync.RWMutex
+	token   string
+	refresh string
+	// renewAt is when the token is fetched again, zero when it never expires
+	renewAt time.Time
+}
+
+// oauth2Token is the answer of a token endpoint
+type oauth2Token struct {
+	AccessToken  string  `json:"access_token"`
+	TokenType    string  `json:"token_type"`
+	ExpiresIn    float64 `json:"expires_in"`
+	RefreshToken string  `json:"refresh_token"`
+	Error        string  `json:"error"`
+	ErrorDesc    string  `json:"error_description"`
+}
+
+// newOAuth2Source uses the password grant with user, USER:PASSWORD, and the
+// client credentials grant without
+func newOAuth2Source(tokenURL, client, user, scope string, insecure bool) (*oauth2Source, error) {
+	if !strings.HasPrefix(tokenURL, "http://") && !strings.HasPrefix(tokenURL, "https://") {
+		return nil, fmt.Errorf("invalid --oauth2-token-url %q: must be an http(s) url", tokenURL)
+	}
+	s := &oauth2Source{tokenURL: tokenURL, form: url.Values{}}
+	s.clientID, s.secret, _ = strings.Cut(client, ":")
+	if user != "" {
+		name, password, ok := strings.Cut(user, ":")
+		if !ok {
+			return nil, fmt.Errorf("--oauth2-user must be USER:PASSWORD")
+		}
+		s.form.Set("grant_type", "password")
+		s.form.Set("username", name)
+		s.form.Set("password", password)
+	} else {
+		if s.clientID == "" {
+			return nil, fmt.Errorf("--oauth2-client ID:SECRET is needed for the client credentials grant")
+		}
+		s.form.Set("grant_type", "client_credentials")
+	}
+	if scope != "" {
+		s.form.Set("scope", scope)
+	}
+	s.client = &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: insecure}},
+	}
+	return s, nil
+}
+
+// fetch gets a new token, with the refresh token of the current one if any,
+// falling back to the grant when the refresh fails
+func (s *oauth2Source) fetch() error {
+	if s.refresh != "" {
+		form := url.Values{"grant_type": {"refresh_token"}, "refresh_token": {s.refresh}}
+		if err := s.request(form); err == nil {
+			return nil
+		}
+		s.refresh = ""
+	}
+	return s.request(s.form)
+}
+
+func (s *oauth2Source) request(form url.Values) error {
+	req, err := http.NewRequest("POST", s.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if s.clientID != "" {
+		req.SetBasicAuth(url.QueryEscape(s.clientID), url.QueryEscape(s.secret))
+	}
+	start := time.Now()
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("oauth2: %s", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("oauth2: %s", err)
+	}
+	var t oauth2Token
+	if err := json.Unmarshal(body, &t); err != nil && resp.StatusCode/100 == 2 {
+		return fmt.Errorf("oauth2: token response is not JSON: %s", err)
+	}
+	if t.Error != "" {
+		return fmt.Errorf("oauth2: %s", strings.TrimSpace(t.Error+" "+t.ErrorDesc))
+	}
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("oauth2: token endpoint status code %d", resp.StatusCode)
+	}
+	if t.AccessToken == "" {
+		return fmt.Errorf("oauth2: no access_token in the token response")
+	}
+	if t.TokenType != "" && !strings.EqualFold(t.TokenType, "bearer") {
+		return fmt.Errorf("oauth2: unsupported token type %q", t.TokenType)
+	}
+	s.token = t.AccessToken
+	if t.RefreshToken != "" {
+		s.refresh = t.RefreshToken
+	}
+	s.renewAt = time.Time{}
+	if t.ExpiresIn > 0 {
+		// ahead of the expiry by a tenth of the lifetime, up to 30s
+		ttl := time.Duration(t.ExpiresIn * float64(time.Second))
+		early := ttl / 10
+		if early > 30*time.Second {
+			early = 30 * time.Second
+		}
+		s.renewAt = start.Add(ttl - early)
+	}
+	return nil
+}
+
+// get returns the current token, fetching a new one once it's due
+func (s *oauth2Source) get() (string, error) {
+	s.mu.RLock()
+	token, renewAt := s.token, s.renewAt
+	s.mu.RUnlock()
+	if token != "" && (renewAt.IsZero() || time.Now().Before(renewAt)) {
+		return token, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// another worker may have renewed it meanwhile
+	if s.token != "" && (s.renewAt.IsZero() || time.Now().Before(s.renewAt)) {
+		return s.token, nil
+	}
+	if err := s.fetch(); err != nil {
+		return "", err
+	}
+	return s.token, nil
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// metricsSink takes the families of a report and their samples, labels
+// being pairs of names and values
+type metricsSink interface {
+	family(name, typ, unit, help string)
+	sample(name string, v float64, labels ...string)
+	// exemplarSample is a sample with the exemplar e, none when nil
+	exemplarSample(name string, v float64, e *Exemplar, labels ...string)
+}
+
+// openMetricsWriter writes the families of the final report in the
+// OpenMetrics text format, which the Prometheus text parser of the textfile
+// collector of node_exporter reads too. The values are the ones of the run,
+// so all of them are gauges but the latency summary and histogram. The
+// exemplars of the histogram are OpenMetrics only, the textfile collector
+// rejecting them.
+type openMetricsWriter struct {
+	w *bufio.Writer
+}
+
+func (m *openMetricsWriter) family(name, typ, unit, help string) {
+	fmt.Fprintf(m.w, "# TYPE %s %s\n", name, typ)
+	if unit != "" {
+		fmt.Fprintf(m.w, "# UNIT %s %s\n", name, unit)
+	}
+	fmt.Fprintf(m.w, "# HELP %s %s\n", name, help)
+}
+
+// sample writes a sample of name, labels being pairs of names and values
+func (m *openMetricsWriter) sample(name string, v float64, labels ...string) {
+	m.exemplarSample(name, v, nil, labels...)
+}
+
+func (m *openMetricsWriter) exemplarSample(name string, v float64, e *Exemplar, labels ...string) {
+	m.w.WriteString(name)
+	if len(labels) > 0 {
+		m.w.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				m.w.WriteByte(',')
+			}
+			fmt.Fprintf(m.w, "%s=\"%s\"", labels[i], escapeLabelValue(labels[i+1]))
+		}
+		m.w.WriteByte('}')
+	}
+	m.w.WriteByte(' ')
+	m.w.WriteString(strconv.FormatFloat(v, 'g', -1, 64))
+	if e != nil {
+		fmt.Fprintf(m.w, " # {trace_id=\"%s\"} %s %s", escapeLabelValue(e.TraceID),
+			strconv.FormatFloat(e.Latency.Seconds(), 'g', -1, 64),
+			strconv.FormatFloat(float64(e.Time.UnixMilli())/1000, 'f', 3, 64))
+	}
+	m.w.WriteByte('\n')
+}
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(s string) string {
+	return labelValueReplacer.Replace(s)
+}
+
+// writeOpenMetrics writes the final report s and the results of its
+// thresholds
+func writeOpenMetrics(w io.Writer, s *SnapshotReport, results []*ThresholdResult) error {
+	m := &openMetricsWriter{w: bufio.NewWriter(w)}
+	collectMetrics(m, s, results)
+	m.w.WriteString("# EOF\n")
+	return m.w.Flush()
+}
+
+// collectMetrics gives the families of the report s and of the results of
+// its thresholds to m
+func collectMetrics(m metricsSink, s *SnapshotReport, results []*ThresholdResult) {
+	m.family("plow_run_start_timestamp_seconds", "gauge", "seconds", "Unix time the run started at.")
+	m.sample("plow_run_start_timestamp_seconds", float64(atomic.LoadInt64(&startTimeUnixNano))/float64(time.Second))
+	m.family("plow_run_duration_seconds", "gauge", "seconds", "Duration of the run, after the warmup.")
+	m.sample("plow_run_duration_seconds", s.Elapsed.Seconds())
+	m.family("plow_run_aborted", "gauge", "", "Whether the guardrail stopped the run.")
+	if s.Aborted != "" {
+		m.sample("plow_run_aborted", 1, "reason", s.Aborted)
+	} else {
+		m.sample("plow_run_aborted", 0)
+	}
+
+	m.family("plow_requests", "gauge", "", "Requests of the run.")
+	m.sample("plow_requests", float64(s.Count))
+	m.family("plow_requests_per_second", "gauge", "", "Mean requests per second of the run.")
+	m.sample("plow_requests_per_second", s.RPS)
+	m.family("plow_responses", "gauge", "", "Responses of the run, by status class.")
+	for _, code := range sortedKeys(s.Codes) {
+		m.sample("plow_responses", float64(s.Codes[code]), "code", code)
+	}
+	m.family("plow_errors", "gauge", "", "Failed requests of the run, by error class.")
+	for _, class := range errorClassNames {
+		m.sample("plow_errors", float64(s.ErrorClasses[class]), "class", class)
+	}
+
+	m.family("plow_latency_seconds", "summary", "seconds", "Latency of the requests of the run.")
+	for _, p := range s.Percentiles {
+		m.sample("plow_latency_seconds", p.Latency.Seconds(), "quantile", formatFloat64(p.Percentile))
+	}
+	m.sample("plow_latency_seconds_sum", s.Stats.Mean.Seconds()*float64(s.Count))
+	m.sample("plow_latency_seconds_count", float64(s.Count))
+	if len(s.LatencyBuckets) > 0 {
+		m.family("plow_request_duration_seconds", "histogram", "seconds", "Latency of the requests of the run, with the --trace-header ids of some as exemplars.")
+		for _, b := range s.LatencyBuckets {
+			le := "+Inf"
+			if b.Le > 0 {
+				le = formatFloat64(b.Le.Seconds())
+			}
+			m.exemplarSample("plow_request_duration_seconds_bucket", float64(b.Count), b.Exemplar, "le", le)
+		}
+		m.sample("plow_request_duration_seconds_sum", s.Stats.Mean.Seconds()*float64(s.Count))
+		m.sample("plow_request_duration_seconds_count", float64(s.Count))
+	}
+	m.family("plow_latency_min_seconds", "gauge", "seconds", "Lowest latency of the run.")
+	m.sample("plow_latency_min_seconds", s.Stats.Min.Seconds())
+	m.family("plow_latency_max_seconds", "gauge", "seconds", "Highest latency of the run.")
+	m.sample("plow_latency_max_seconds", s.Stats.Max.Seconds())
+
+	if a := s.Arrivals; a != nil {
+		m.family("plow_arrivals_dropped", "gauge", "", "Open-loop arrivals dropped on a full queue.")
+		m.sample("plow_arrivals_dropped", float64(a.Dropped))
+		if a.QueueWait != nil {
+			m.family("plow_queue_wait_seconds", "summary", "seconds", "Wait of the open-loop arrivals for a free connection.")
+			for i, q := range quantiles {
+				m.sample("plow_queue_wait_seconds", a.QueueWait.Percentiles[i].Seconds(), "quantile", formatFloat64(q))
+			}
+			m.sample("plow_queue_wait_seconds_sum", a.QueueWait.Mean.Seconds()*float64(a.QueueWait.Count))
+			m.sample("plow_queue_wait_seconds_count", float64(a.QueueWait.Count))
+		}
+	}
+
+	if b := s.Backpressure; b != nil {
+		m.family("plow_report_stalled_records", "gauge", "", "Records which waited for the report to collect them.")
+		m.sample("plow_report_stalled_records", float64(b.Stalled))
+		m.family("plow_report_stall_seconds", "gauge", "seconds", "Total wait of the records for the report.")
+		m.sample("plow_report_stall_seconds", b.StallTime.Seconds())
+	}
+	if r := s.Replay; r != nil && r.Lag != nil {
+		m.family("plow_replay_on_time_ratio", "gauge", "", "Share of the replayed requests sent within 10ms of their time.")
+		m.sample("plow_replay_on_time_ratio", float64(r.OnTime)/float64(r.Sent))
+		m.family("plow_replay_lag_seconds", "summary", "seconds", "Lag of the replayed requests behind their time.")
+		for i, q := range quantiles {
+			m.sample("plow_replay_lag_seconds", r.Lag.Percentiles[i].Seconds(), "quantile", formatFloat64(q))
+		}
+		m.sample("plow_replay_lag_seconds_sum", r.Lag.Mean.Seconds()*float64(r.Lag.Count))
+		m.sample("plow_replay_lag_seconds_count", float64(r.Lag.Count))
+	}
+
+	m.family("plow_read_bytes_per_second", "gauge", "", "Mean bytes read per second.")
+	m.sample("plow_read_bytes_per_second", s.ReadThroughput*1024*1024)
+	m.family("plow_written_bytes_per_second", "gauge", "", "Mean bytes written per second.")
+	m.sample("plow_written_bytes_per_second", s.WriteThroughput*1024*1024)
+
+	if len(s.Targets) > 1 {
+		m.family("plow_target_requests", "gauge", "", "Requests of the run, by url.")
+		for _, t := range s.Targets {
+			m.sample("plow_target_requests", float64(t.Count), "url", t.URL)
+		}
+		m.family("plow_target_errors", "gauge", "", "Failed requests and 5xx responses of the run, by url.")
+		for _, t := range s.Targets {
+			m.sample("plow_target_errors", float64(t.Errors), "url", t.URL)
+		}
+		m.family("plow_target_latency_seconds", "gauge", "seconds", "Latency of the requests of the run, by url and statistic.")
+		for _, t := range s.Targets {
+			m.sample("plow_target_latency_seconds", t.Mean.Seconds(), "url", t.URL, "stat", "mean")
+			m.sample("plow_target_latency_seconds", t.P90.Seconds(), "url", t.URL, "stat", "p90")
+			m.sample("plow_target_latency_seconds", t.P99.Seconds(), "url", t.URL, "stat", "p99")
+			m.sample("plow_target_latency_seconds", t.Max.Seconds(), "url", t.URL, "stat", "max")
+		}
+	}
+
+	if len(results) > 0 {
+		m.family("plow_threshold_passed", "gauge", "", "Whether the run passed the threshold.")
+		for _, r := range results {
+			v := 0.0
+			if r.Passed {
+				v = 1
+			}
+			m.sample("plow_threshold_passed", v, "threshold", r.Threshold)
+		}
+	}
+}
+
+func sortedKeys(m map[string]int64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// writeOpenMetricsFile replaces path at once, as the textfile collector may
+// read it at any time
+func writeOpenMetricsFile(path string, s *SnapshotReport, results []*ThresholdResult) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if err = writeOpenMetrics(f, s, results); err == nil {
+		err = f.Chmod(0o644)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
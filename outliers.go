@@ -0,0 +1,127 @@
+package main
+
+import (
+	"sort"
+	"time"
+)
+
+// a second of the run is an outlier when its P99 latency is outlierFactor
+// times the median P99 of the seconds, outliers in a row making a cluster
+const (
+	outlierFactor = 3
+	// outlierMinTicks is the seconds needed to tell the usual P99
+	outlierMinTicks = 10
+	// outlierMinPeriodic is the clusters needed to tell a period, whose
+	// intervals are off by outlierJitter of it, or 1.5s, at most
+	outlierMinPeriodic = 3
+	outlierJitter      = 0.1
+	// maxOutlierTimes bounds the starts of clusters printed
+	maxOutlierTimes = 10
+)
+
+// OutlierCluster is a spike of latency, over the seconds of the run it lasted
+type OutlierCluster struct {
+	Start    time.Duration // elapsed time of the run
+	Duration time.Duration
+	Peak     time.Duration // highest P99 of its seconds
+	Count    int64         // requests of its seconds
+}
+
+// OutlierReport is the latency spikes of the run, and their period when they
+// come back at regular intervals, as the cron jobs or GC cycles of a server
+type OutlierReport struct {
+	Threshold time.Duration
+	Clusters  []*OutlierCluster
+	Period    time.Duration // 0 when the clusters aren't periodic
+	Jitter    time.Duration // the most an interval between clusters is off Period
+}
+
+// outlierReport clusters the outlier seconds of ticks after from, nil
+// without any
+func outlierReport(ticks []*TickReport, from time.Duration) *OutlierReport {
+	p99 := -1
+	for i, q := range quantiles {
+		if q == 0.99 {
+			p99 = i
+		}
+	}
+	var seconds []*TickReport
+	var values []float64
+	for _, t := range ticks {
+		if t.Count > 0 && t.Elapsed > from && p99 < len(t.Percentiles) {
+			seconds = append(seconds, t)
+			values = append(values, float64(t.Percentiles[p99]))
+		}
+	}
+	if p99 < 0 || len(seconds) < outlierMinTicks {
+		return nil
+	}
+	sort.Float64s(values)
+	median := values[len(values)/2]
+	if median <= 0 {
+		return nil
+	}
+	r := &OutlierReport{Threshold: time.Duration(outlierFactor * median)}
+	var c *OutlierCluster
+	prev := from
+	for _, t := range seconds {
+		v := t.Percentiles[p99]
+		switch {
+		case v < r.Threshold:
+			c = nil
+		case c != nil && t.Elapsed-prev < 2*time.Second:
+			c.Duration = t.Elapsed - c.Start
+			c.Count += t.Count
+			if v > c.Peak {
+				c.Peak = v
+			}
+		default:
+			start := prev
+			if t.Elapsed-start > time.Second+time.Second/2 {
+				// after idle seconds
+				start = t.Elapsed - time.Second
+			}
+			c = &OutlierCluster{Start: start, Duration: t.Elapsed - start, Peak: v, Count: t.Count}
+			r.Clusters = append(r.Clusters, c)
+		}
+		prev = t.Elapsed
+	}
+	if len(r.Clusters) == 0 {
+		return nil
+	}
+	if len(r.Clusters) >= outlierMinPeriodic {
+		r.Period, r.Jitter = clusterPeriod(r.Clusters)
+	}
+	return r
+}
+
+// clusterPeriod is the median interval between the starts of clusters and
+// the most an interval is off it, 0 when they aren't regular
+func clusterPeriod(clusters []*OutlierCluster) (time.Duration, time.Duration) {
+	intervals := make([]time.Duration, 0, len(clusters)-1)
+	for i := 1; i < len(clusters); i++ {
+		intervals = append(intervals, clusters[i].Start-clusters[i-1].Start)
+	}
+	sorted := append([]time.Duration(nil), intervals...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	period := sorted[len(sorted)/2]
+	var jitter time.Duration
+	for _, iv := range intervals {
+		d := iv - period
+		if d < 0 {
+			d = -d
+		}
+		if d > jitter {
+			jitter = d
+		}
+	}
+	tolerance := time.Duration(outlierJitter * float64(period))
+	if tolerance < 1500*time.Millisecond {
+		// the seconds of the run blur the edges of the spikes
+		tolerance = 1500 * time.Millisecond
+	}
+	if period < 2*time.Second || jitter > tolerance {
+		return 0, 0
+	}
+	return period.Round(100 * time.Millisecond), jitter.Round(100 * time.Millisecond)
+}
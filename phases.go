@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"os"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// request phases, like httpstat: the dial phases are only non-zero for the
+// requests that opened their connection.
+const (
+	phaseDNS = iota
+	phaseConnect
+	phaseTLS
+	phaseWait
+	phaseTransfer
+	numPhases
+)
+
+var phaseNames = [numPhases]string{"DNS", "Connect", "TLS", "Wait", "Transfer"}
+
+type phaseTimes [numPhases]time.Duration
+
+// phaseTracker times the connection of a single-connection worker client,
+// it's only used by the goroutine of the worker.
+type phaseTracker struct {
+	dns, connect, tls time.Duration
+	firstByte         time.Time
+	dialed            bool
+	reading           bool // the request was written and its response is read
+	// queued is the wait for --conn-rate before dialing
+	queued time.Duration
+	// stale is the outcome of the request failing on a reused connection
+	stale int
+	// addr is the ip of the connection when dialing the target directly
+	addr string
+}
+
+// phaseConn marks the first response byte read after the request started
+type phaseConn struct {
+	net.Conn
+	t *phaseTracker
+}
+
+func (c *phaseConn) Read(b []byte) (int, error) {
+	c.t.reading = true
+	n, err := c.Conn.Read(b)
+	if n > 0 && c.t.firstByte.IsZero() {
+		c.t.firstByte = time.Now()
+	}
+	return n, err
+}
+
+// start clears the timings before a request
+func (t *phaseTracker) start() {
+	t.dns, t.connect, t.tls = 0, 0, 0
+	t.firstByte = time.Time{}
+	t.dialed = false
+	t.reading = false
+	t.queued = 0
+	t.stale = staleNone
+}
+
+// times splits cost, done being the time the response was read
+func (t *phaseTracker) times(cost time.Duration, done time.Time) phaseTimes {
+	var p phaseTimes
+	p[phaseDNS], p[phaseConnect], p[phaseTLS] = t.dns, t.connect, t.tls
+	if !t.firstByte.IsZero() {
+		p[phaseTransfer] = done.Sub(t.firstByte)
+	}
+	p[phaseWait] = cost - t.dns - t.connect - t.tls - p[phaseTransfer]
+	if p[phaseWait] < 0 {
+		p[phaseWait] = 0
+	}
+	return p
+}
+
+// workerClient builds a single-connection client for target t, so that the
+// phases of each request are known, and the connection authenticated by a
+// handshake is the one used by the next requests.
+func (r *Requester) workerClient(t *target, pt *phaseTracker, proxy int) *fasthttp.HostClient {
+	opt := *r.clientOpt
+	opt.maxConns = 1
+	opt.phases = pt
+	if proxy >= 0 {
+		opt.proxy = opt.proxies[proxy]
+	}
+	client, _, err := buildRequestClient(&opt, t.url, &r.readBytes, &r.writeBytes)
+	if err != nil {
+		// the same url was already accepted by NewRequester
+		return t.httpClient
+	}
+	client.RetryIfErr = pt.retryIf(opt.staleConn)
+	return client
+}
+
+func proxyFromEnv() bool {
+	for _, k := range []string{"HTTP_PROXY", "HTTPS_PROXY", "http_proxy", "https_proxy"} {
+		if os.Getenv(k) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// dial wraps the dial of client to time its phases. The name is resolved
+// ahead only when dialing the target directly, and the TLS handshake is done
+// here rather than by fasthttp, which skips conns that are already TLS.
+func (t *phaseTracker) dial(dial fasthttp.DialFunc, opt *ClientOpt, tlsConfig *tls.Config, isTLS bool) fasthttp.DialFunc {
+	resolve := len(opt.proxies) == 0 && opt.unixSocket == "" && !proxyFromEnv()
+	if isTLS {
+		tlsConfig = tlsConfig.Clone()
+		if tlsConfig.ClientSessionCache == nil {
+			tlsConfig.ClientSessionCache = tls.NewLRUClientSessionCache(0)
+		}
+	}
+	return func(addr string) (net.Conn, error) {
+		t.dialed = true
+		if opt.connLimiter != nil {
+			start := time.Now()
+			if err := opt.connLimiter.Wait(context.Background()); err != nil {
+				return nil, err
+			}
+			t.queued = time.Since(start)
+		}
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		dialAddr, dialHost := addr, host
+		if opt.connectTo != "" {
+			dialAddr = opt.connectTo
+			if dialHost, port, err = net.SplitHostPort(dialAddr); err != nil {
+				return nil, err
+			}
+		}
+		t.addr = ""
+		if resolve && net.ParseIP(dialHost) == nil {
+			start := time.Now()
+			if opt.dns != nil {
+				var ip net.IP
+				ip, err = opt.dns.lookup(context.Background(), dialHost)
+				if err == nil {
+					dialAddr = net.JoinHostPort(ip.String(), port)
+				}
+			} else {
+				var ips []net.IPAddr
+				ips, err = net.DefaultResolver.LookupIPAddr(context.Background(), dialHost)
+				if err == nil && len(ips) > 0 {
+					dialAddr = net.JoinHostPort(ips[0].String(), port)
+				}
+			}
+			t.dns = time.Since(start)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if resolve {
+			t.addr, _, _ = net.SplitHostPort(dialAddr)
+		}
+
+		start := time.Now()
+		conn, err := dial(dialAddr)
+		t.connect = time.Since(start)
+		if err != nil {
+			return nil, err
+		}
+		conn = &phaseConn{Conn: conn, t: t}
+		if !isTLS {
+			return conn, nil
+		}
+
+		cfg := tlsConfig
+		if opt.certs != nil {
+			cfg = opt.certs.bind(cfg)
+		}
+		if cfg.ServerName == "" {
+			if cfg == tlsConfig {
+				cfg = cfg.Clone()
+			}
+			cfg.ServerName = host
+		}
+		tlsConn := tls.Client(conn, cfg)
+		timeout := opt.tlsTimeout
+		if timeout <= 0 {
+			timeout = opt.writeTimeout
+		}
+		if timeout > 0 {
+			_ = tlsConn.SetDeadline(time.Now().Add(timeout))
+		}
+		start = time.Now()
+		err = tlsConn.Handshake()
+		t.tls = time.Since(start)
+		t.firstByte = time.Time{}
+		t.reading = false
+		if err != nil {
+			conn.Close()
+			if isTimeout(err) {
+				return nil, &tlsTimeoutError{timeout}
+			}
+			return nil, err
+		}
+		_ = tlsConn.SetDeadline(time.Time{})
+		return tlsConn, nil
+	}
+}
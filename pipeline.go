@@ -0,0 +1,42 @@
+package main
+
+import (
+	"github.com/valyala/fasthttp"
+)
+
+// PipelineReport is the --pipeline depth of the connections, RPSPerConn
+// being the throughput of each to compare with a run without
+type PipelineReport struct {
+	Depth       int
+	Connections int
+	RPSPerConn  float64
+}
+
+// pipelineConns is the number of connections pipelining depth requests each
+// for the concurrency
+func pipelineConns(concurrency, depth int) int {
+	return max((concurrency+depth-1)/depth, 1)
+}
+
+// newPipelineClient returns the client pipelining the requests of all the
+// workers to the host of client, dialing like it
+func newPipelineClient(client *fasthttp.HostClient, opt *ClientOpt, concurrency int) *fasthttp.PipelineClient {
+	return &fasthttp.PipelineClient{
+		Addr:                          client.Addr,
+		Name:                          client.Name,
+		IsTLS:                         client.IsTLS,
+		TLSConfig:                     client.TLSConfig,
+		Dial:                          client.Dial,
+		MaxConns:                      pipelineConns(concurrency, opt.pipeline),
+		MaxPendingRequests:            opt.pipeline,
+		ReadTimeout:                   opt.readTimeout,
+		WriteTimeout:                  opt.writeTimeout,
+		DisableHeaderNamesNormalizing: true,
+		// the errors are the ones of the requests
+		Logger: quietLogger{},
+	}
+}
+
+type quietLogger struct{}
+
+func (quietLogger) Printf(string, ...interface{}) {}
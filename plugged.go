@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/six-ddc/plow/backend"
+)
+
+// BackendReport counts the requests of a --backend by the codes of their
+// records
+type BackendReport struct {
+	Name  string
+	Codes map[string]int64
+}
+
+// pluggedMode sends the requests through the Backend served by the
+// executable of --backend
+type pluggedMode struct {
+	name    string // of the executable, without its extension
+	b       backend.Backend
+	options map[string]string
+}
+
+func newPluggedMode(path string, options []string) (*pluggedMode, error) {
+	m := &pluggedMode{
+		name:    strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)),
+		options: make(map[string]string, len(options)),
+	}
+	for _, o := range options {
+		k, v, ok := strings.Cut(o, "=")
+		if !ok || k == "" {
+			return nil, fmt.Errorf("invalid --backend-option %q, not KEY=VALUE", o)
+		}
+		m.options[k] = v
+	}
+	var err error
+	if m.b, err = backend.Exec(path); err != nil {
+		return nil, fmt.Errorf("--backend %s: %w", path, err)
+	}
+	return m, nil
+}
+
+// setup sets the backend up for the urls of the targets, ahead of the run
+func (m *pluggedMode) setup(r *Requester) error {
+	cfg := backend.Config{Concurrency: r.concurrency, Timeout: r.clientOpt.doTimeout, Options: m.options}
+	for _, t := range r.targets.targets {
+		cfg.URLs = append(cfg.URLs, t.url)
+	}
+	if err := m.b.Setup(context.Background(), cfg); err != nil {
+		return fmt.Errorf("%s: %w", m.name, err)
+	}
+	return nil
+}
+
+// teardown ends the backend after the run, its error only being shown
+func (m *pluggedMode) teardown() {
+	if err := m.b.Teardown(); err != nil {
+		fmt.Fprintf(os.Stderr, "plow: %s: %v\n", m.name, err)
+	}
+}
+
+func (m *pluggedMode) conns(r *Requester, worker int) protocolConns {
+	return &pluggedConns{m: m, r: r, worker: worker}
+}
+
+// pluggedConns are the requests of a worker through the backend, which
+// holds its connections
+type pluggedConns struct {
+	m      *pluggedMode
+	r      *Requester
+	worker int
+}
+
+func (c *pluggedConns) build() error { return nil }
+
+func (c *pluggedConns) do(x *exchange, rr *ReportRecord) { c.m.do(c.r, c.worker, x.t, rr) }
+
+func (c *pluggedConns) close() {}
+
+func (m *pluggedMode) report(codes map[string]int64) *BackendReport {
+	rs := &BackendReport{Name: m.name}
+	if len(codes) > 0 {
+		rs.Codes = make(map[string]int64, len(codes))
+		for k, v := range codes {
+			rs.Codes[k] = v
+		}
+	}
+	return rs
+}
+
+// do sends a request of the worker to t through the backend, its record
+// filling rr
+func (m *pluggedMode) do(r *Requester, worker int, t *target, rr *ReportRecord) {
+	rr.reset()
+
+	ctx := context.Background()
+	if r.clientOpt.doTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.clientOpt.doTimeout)
+		defer cancel()
+	}
+	start := time.Now()
+	rec := m.b.Do(ctx, worker, t.url)
+	rr.cost = rec.Latency
+	if rr.cost <= 0 {
+		rr.cost = time.Since(start)
+	}
+	rr.phases[phaseWait] = rr.cost
+	rr.reqSize, rr.respSize = rec.ReqSize, rec.RespSize
+	rr.rcode = rec.Code
+	rr.error = ""
+	if rec.Error != nil {
+		rr.error = rec.Error.Error()
+		rr.errClass = classifyError(rec.Error)
+	}
+}
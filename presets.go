@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// presetBundleVersion is the version of the exported preset files
+const presetBundleVersion = 1
+
+// Preset is a named benchmark definition of the web UI
+type Preset struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	Request     BenchmarkRequest `json:"request"`
+}
+
+// PresetBundle is the JSON file presets are exported to and imported from
+type PresetBundle struct {
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exportedAt"`
+	Presets    []*Preset `json:"presets"`
+}
+
+func (p *Preset) validate() error {
+	p.Name = strings.TrimSpace(p.Name)
+	if p.Name == "" {
+		return fmt.Errorf("preset name is required")
+	}
+	if p.Request.URL == "" {
+		return fmt.Errorf("preset %q has no url", p.Name)
+	}
+	if u, err := url.Parse(p.Request.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("preset %q has an invalid url %q", p.Name, p.Request.URL)
+	}
+	return nil
+}
+
+// sortedPresets returns the presets by name, g.mu must be held
+func (g *GUIServer) sortedPresets() []*Preset {
+	res := make([]*Preset, 0, len(g.presets))
+	for _, p := range g.presets {
+		res = append(res, p)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res
+}
+
+func (g *GUIServer) handlePresets(ctx *fasthttp.RequestCtx) {
+	g.mu.Lock()
+	presets := g.sortedPresets()
+	g.mu.Unlock()
+	ctx.SetContentType("application/json")
+	json.NewEncoder(ctx).Encode(presets)
+}
+
+// handleSavePreset adds a preset, replacing the one of the same name
+func (g *GUIServer) handleSavePreset(ctx *fasthttp.RequestCtx) {
+	ctx.SetContentType("application/json")
+	var p Preset
+	if err := json.Unmarshal(ctx.PostBody(), &p); err != nil {
+		ctx.SetStatusCode(400)
+		json.NewEncoder(ctx).Encode(map[string]string{"error": "invalid preset: " + err.Error()})
+		return
+	}
+	if err := p.validate(); err != nil {
+		ctx.SetStatusCode(400)
+		json.NewEncoder(ctx).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	g.mu.Lock()
+	g.presets[p.Name] = &p
+	g.mu.Unlock()
+	json.NewEncoder(ctx).Encode(&p)
+}
+
+func (g *GUIServer) handleDeletePreset(ctx *fasthttp.RequestCtx, name string) {
+	ctx.SetContentType("application/json")
+	name, _ = url.PathUnescape(name)
+	g.mu.Lock()
+	_, ok := g.presets[name]
+	delete(g.presets, name)
+	g.mu.Unlock()
+	if !ok {
+		ctx.SetStatusCode(404)
+		json.NewEncoder(ctx).Encode(map[string]string{"error": "preset not found"})
+		return
+	}
+	json.NewEncoder(ctx).Encode(map[string]string{"status": "deleted"})
+}
+
+func (g *GUIServer) handleExportPresets(ctx *fasthttp.RequestCtx) {
+	g.mu.Lock()
+	bundle := &PresetBundle{Version: presetBundleVersion, ExportedAt: time.Now(), Presets: g.sortedPresets()}
+	g.mu.Unlock()
+	ctx.SetContentType("application/json")
+	ctx.Response.Header.Set("Content-Disposition", `attachment; filename="plow-presets.json"`)
+	enc := json.NewEncoder(ctx)
+	enc.SetIndent("", "  ")
+	enc.Encode(bundle)
+}
+
+// handleImportPresets merges an exported bundle, the whole bundle is rejected
+// if any preset is invalid
+func (g *GUIServer) handleImportPresets(ctx *fasthttp.RequestCtx) {
+	ctx.SetContentType("application/json")
+	var bundle PresetBundle
+	if err := json.Unmarshal(ctx.PostBody(), &bundle); err != nil {
+		ctx.SetStatusCode(400)
+		json.NewEncoder(ctx).Encode(map[string]string{"error": "invalid preset file: " + err.Error()})
+		return
+	}
+	if bundle.Version > presetBundleVersion {
+		ctx.SetStatusCode(400)
+		json.NewEncoder(ctx).Encode(map[string]string{"error": fmt.Sprintf("preset file version %d is newer than this plow (%d)", bundle.Version, presetBundleVersion)})
+		return
+	}
+	for _, p := range bundle.Presets {
+		if p == nil {
+			continue
+		}
+		if err := p.validate(); err != nil {
+			ctx.SetStatusCode(400)
+			json.NewEncoder(ctx).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+	}
+	n := 0
+	g.mu.Lock()
+	for _, p := range bundle.Presets {
+		if p != nil {
+			g.presets[p.Name] = p
+			n++
+		}
+	}
+	g.mu.Unlock()
+	json.NewEncoder(ctx).Encode(map[string]int{"imported": n})
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"math"
 	"os"
 	"regexp"
@@ -36,6 +37,8 @@ type Printer struct {
 	pbDurStr    string
 	noClean     bool
 	summary     bool
+	thresholds  []*Threshold
+	stages      []*Stage
 }
 
 func NewPrinter(maxNum int64, maxDuration time.Duration, noCleanBar, summary bool) *Printer {
@@ -65,7 +68,16 @@ func (p *Printer) updateProgressValue(rs *SnapshotReport) {
 	}
 }
 
-func (p *Printer) PrintLoop(snapshot func() *SnapshotReport, interval time.Duration, useSeconds bool, json bool, doneChan <-chan struct{}) {
+// PrintLoop prints a report every interval until doneChan is closed and returns the final report,
+// the realtime reports being JSON ones with json, and only the final summary printed with summaryJSON.
+func (p *Printer) PrintLoop(snapshot func() *SnapshotReport, interval time.Duration, useSeconds bool, json, summaryJSON bool, doneChan <-chan struct{}) *SnapshotReport {
+	if summaryJSON {
+		<-doneChan
+		report := snapshot()
+		p.PrintJSON(os.Stdout, report, useSeconds)
+		return report
+	}
+
 	var buf bytes.Buffer
 
 	var backCursor string
@@ -73,8 +85,9 @@ func (p *Printer) PrintLoop(snapshot func() *SnapshotReport, interval time.Durat
 	if p.summary || interval == 0 || !isTerminal {
 		cl = nil
 	}
+	var report *SnapshotReport
 	echo := func(isFinal bool) {
-		report := snapshot()
+		report = snapshot()
 		p.updateProgressValue(report)
 		os.Stdout.WriteString(backCursor)
 		buf.Reset()
@@ -122,6 +135,26 @@ func (p *Printer) PrintLoop(snapshot func() *SnapshotReport, interval time.Durat
 		<-doneChan
 	}
 	echo(true)
+	return report
+}
+
+// PrintJSON writes the final summary as indented JSON, free of any terminal escapes.
+func (p *Printer) PrintJSON(w io.Writer, snapshot *SnapshotReport, useSeconds bool) {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	summary := NewSummary(snapshot, useSeconds)
+	summary.Thresholds = evaluateThresholds(p.thresholds, snapshot)
+	summary.Stages = evaluateStages(p.stages, snapshot, useSeconds)
+	_ = enc.Encode(summary)
+}
+
+// FormatText renders the same tables as the terminal, without colors or cursor movement.
+func (p *Printer) FormatText(snapshot *SnapshotReport, isFinal bool, useSeconds bool) string {
+	var buf bytes.Buffer
+	p.updateProgressValue(snapshot)
+	p.formatTableReports(&buf, snapshot, isFinal, useSeconds)
+	return ansi.ReplaceAllLiteralString(buf.String(), "")
 }
 
 // nolint
@@ -202,6 +235,10 @@ func (p *Printer) formatJSONReports(writer *bytes.Buffer, snapshot *SnapshotRepo
 		writer.WriteString(",\n")
 		p.buildJSONErrors(writer, snapshot, indent)
 	}
+	if len(snapshot.Ejections) != 0 {
+		writer.WriteString(",\n")
+		p.buildJSONEjections(writer, snapshot, indent)
+	}
 	writer.WriteString(",\n")
 	p.buildJSONStats(writer, snapshot, useSeconds, indent)
 	writer.WriteString(",\n")
@@ -213,21 +250,275 @@ func (p *Printer) formatJSONReports(writer *bytes.Buffer, snapshot *SnapshotRepo
 
 func (p *Printer) formatTableReports(writer *bytes.Buffer, snapshot *SnapshotReport, isFinal bool, useSeconds bool) {
 	summaryBulk := p.buildSummary(snapshot, isFinal)
-	errorsBulks := p.buildErrors(snapshot)
+	errorsBulks := p.buildErrors(snapshot.Errors)
+	errorClassesBulk := p.buildErrorClasses(snapshot)
+	graphqlBulks := p.buildErrors(snapshot.GraphQLErrors)
+	validationBulk := p.buildValidation(snapshot)
+	variantsBulk := p.buildBodyVariants(snapshot)
+	ejectionsBulk := p.buildEjections(snapshot)
+	authBulk := p.buildAuth(snapshot, useSeconds)
+	staleBulk := p.buildStaleConns(snapshot)
+	warmupBulk := p.buildWarmup(snapshot, isFinal)
+	redirectsBulk := p.buildRedirects(snapshot)
+	serverTimingBulk := p.buildServerTiming(snapshot, useSeconds)
+	extractedBulk := p.buildExtracted(snapshot)
+	bodySizeBulk := p.buildBodySize(snapshot)
+	sizeHisBulk := p.buildSizeHistogram(snapshot, isFinal)
+	grpcBulk := p.buildGRPC(snapshot)
+	grpcCodesBulk := p.buildGRPCCodes(snapshot)
+	dnsBulk := p.buildDNS(snapshot)
+	mqttBulk := p.buildMQTT(snapshot)
+	redisBulk := p.buildRedis(snapshot)
+	kafkaBulk := p.buildKafka(snapshot)
+	sqlBulk, sqlCodesBulk := p.buildSQL(snapshot)
+	backendBulk := p.buildBackend(snapshot)
+	sseBulk := p.buildSSE(snapshot)
+	pipelineBulk := p.buildPipeline(snapshot)
+	msgPercBulk := p.buildMessagePercentile(snapshot, useSeconds)
+	arrivalsBulk := p.buildArrivals(snapshot)
+	replayBulk := p.buildReplay(snapshot, useSeconds)
+	backpressureBulk := p.buildBackpressure(snapshot, useSeconds)
+	clientBulk := p.buildClient(snapshot)
+	serverBulk := p.buildServer(snapshot)
+	searchBulk := p.buildSearch(snapshot, useSeconds)
+	queuePercBulk := p.buildQueuePercentile(snapshot, useSeconds)
+	certsBulk := p.buildClientCerts(snapshot)
 	statsBulk := p.buildStats(snapshot, useSeconds)
 	percBulk := p.buildPercentile(snapshot, useSeconds)
+	connBulk := p.buildConnPercentile(snapshot, useSeconds)
+	outliersBulk := p.buildOutliers(snapshot, useSeconds)
+	targetsBulk := p.buildTargets(snapshot, useSeconds)
+	regionsBulk := p.buildRegions(snapshot, useSeconds)
+	proxiesBulk := p.buildProxies(snapshot, useSeconds)
+	addressesBulk := p.buildAddresses(snapshot, useSeconds)
 	hisBulk := p.buildHistogram(snapshot, useSeconds, isFinal)
 
 	writer.WriteString("Summary:\n")
 	writeBulk(writer, summaryBulk)
 	writer.WriteString("\n")
 
+	if snapshot.Aborted != "" {
+		writer.WriteString("Aborted:\n  " + colorize(snapshot.Aborted, FgRedColor) + "\n\n")
+	} else if snapshot.Interrupted {
+		writer.WriteString("Interrupted:\n  " + colorize("stopped before its end by ctrl-c, q of --tui or /stop", FgYellowColor) + "\n\n")
+	}
+
+	if warmupBulk != nil {
+		writer.WriteString("Warmup:\n")
+		writeBulk(writer, warmupBulk)
+		writer.WriteString("\n")
+	}
+
+	if redirectsBulk != nil {
+		writer.WriteString("Redirects:\n")
+		writeBulk(writer, redirectsBulk)
+		writer.WriteString("\n")
+	}
+
 	if errorsBulks != nil {
 		writer.WriteString("Error:\n")
 		writeBulk(writer, errorsBulks)
 		writer.WriteString("\n")
 	}
 
+	if errorClassesBulk != nil {
+		writer.WriteString("Error Classes:\n")
+		writeBulk(writer, errorClassesBulk)
+		writer.WriteString("\n")
+	}
+
+	if graphqlBulks != nil {
+		writer.WriteString("GraphQL Error:\n")
+		writeBulk(writer, graphqlBulks)
+		writer.WriteString("\n")
+	}
+
+	if validationBulk != nil {
+		writer.WriteString("Validation:\n")
+		writeBulk(writer, validationBulk)
+		writer.WriteString("\n")
+	}
+
+	if variantsBulk != nil {
+		writer.WriteString("Body Variants:\n")
+		writeBulk(writer, variantsBulk)
+		writer.WriteString("\n")
+	}
+
+	if staleBulk != nil {
+		writer.WriteString("Stale Connections:\n")
+		writeBulk(writer, staleBulk)
+		writer.WriteString("\n")
+	}
+
+	if ejectionsBulk != nil {
+		writer.WriteString("Ejections:\n")
+		writeBulk(writer, ejectionsBulk)
+		writer.WriteString("\n")
+	}
+
+	if targetsBulk != nil {
+		writer.WriteString("Targets:\n")
+		writeBulk(writer, targetsBulk)
+		writer.WriteString("\n")
+	}
+
+	if regionsBulk != nil {
+		writer.WriteString("Regions:\n")
+		writeBulk(writer, regionsBulk)
+		writer.WriteString("\n")
+	}
+
+	if proxiesBulk != nil {
+		writer.WriteString("Proxies:\n")
+		writeBulk(writer, proxiesBulk)
+		writer.WriteString("\n")
+	}
+
+	if addressesBulk != nil {
+		writer.WriteString("Addresses:\n")
+		writeBulk(writer, addressesBulk)
+		writer.WriteString("\n")
+	}
+
+	if certsBulk != nil {
+		writer.WriteString("Client Certificates:\n")
+		writeBulk(writer, certsBulk)
+		writer.WriteString("\n")
+	}
+
+	if authBulk != nil {
+		writer.WriteString("Auth:\n")
+		writeBulk(writer, authBulk)
+		writer.WriteString("\n")
+	}
+
+	if serverTimingBulk != nil {
+		writer.WriteString("Server-Timing:\n")
+		writeBulk(writer, serverTimingBulk)
+		writer.WriteString("\n")
+	}
+
+	if extractedBulk != nil {
+		writer.WriteString("Extracted:\n")
+		writeBulk(writer, extractedBulk)
+		writer.WriteString("\n")
+	}
+
+	if bodySizeBulk != nil {
+		writer.WriteString("Body Size:\n")
+		writeBulk(writer, bodySizeBulk)
+		writer.WriteString("\n")
+	}
+
+	if sizeHisBulk != nil {
+		writer.WriteString("Response Size Histogram:\n")
+		writeBulk(writer, sizeHisBulk)
+		writer.WriteString("\n")
+	}
+
+	if grpcBulk != nil {
+		writer.WriteString("gRPC Messages:\n")
+		writeBulk(writer, grpcBulk)
+		writer.WriteString("\n")
+	}
+
+	if grpcCodesBulk != nil {
+		writer.WriteString("gRPC Status:\n")
+		writeBulk(writer, grpcCodesBulk)
+		writer.WriteString("\n")
+	}
+
+	if dnsBulk != nil {
+		writer.WriteString("DNS " + snapshot.DNS.Type + " Rcodes:\n")
+		writeBulk(writer, dnsBulk)
+		writer.WriteString("\n")
+	}
+
+	if mqttBulk != nil {
+		writer.WriteString("MQTT Delivery:\n")
+		writeBulk(writer, mqttBulk)
+		writer.WriteString("\n")
+	}
+
+	if redisBulk != nil {
+		writer.WriteString("Redis Commands:\n")
+		writeBulk(writer, redisBulk)
+		writer.WriteString("\n")
+	}
+
+	if kafkaBulk != nil {
+		writer.WriteString("Kafka Produce:\n")
+		writeBulk(writer, kafkaBulk)
+		writer.WriteString("\n")
+	}
+
+	if sqlBulk != nil {
+		writer.WriteString("SQL Queries:\n")
+		writeBulk(writer, sqlBulk)
+		writer.WriteString("\n")
+	}
+
+	if sqlCodesBulk != nil {
+		writer.WriteString("SQL Error Codes:\n")
+		writeBulk(writer, sqlCodesBulk)
+		writer.WriteString("\n")
+	}
+
+	if backendBulk != nil {
+		writer.WriteString("Backend " + snapshot.Backend.Name + " Codes:\n")
+		writeBulk(writer, backendBulk)
+		writer.WriteString("\n")
+	}
+
+	if pipelineBulk != nil {
+		writer.WriteString("Pipelining:\n")
+		writeBulk(writer, pipelineBulk)
+		writer.WriteString("\n")
+	}
+
+	if sseBulk != nil {
+		writer.WriteString("Server-Sent Events:\n")
+		writeBulk(writer, sseBulk)
+		writer.WriteString("\n")
+	}
+
+	if arrivalsBulk != nil {
+		writer.WriteString("Arrivals:\n")
+		writeBulk(writer, arrivalsBulk)
+		writer.WriteString("\n")
+	}
+
+	if replayBulk != nil {
+		writer.WriteString("Replay Fidelity:\n")
+		writeBulk(writer, replayBulk)
+		writer.WriteString("\n")
+	}
+
+	if backpressureBulk != nil {
+		writer.WriteString("Report Backpressure:\n")
+		writeBulk(writer, backpressureBulk)
+		writer.WriteString("\n")
+	}
+
+	if clientBulk != nil {
+		writer.WriteString("Client Saturation:\n")
+		writeBulk(writer, clientBulk)
+		writer.WriteString("\n")
+	}
+
+	if serverBulk != nil {
+		writer.WriteString("Server Utilization:\n")
+		writeBulk(writer, serverBulk)
+		writer.WriteString("\n")
+	}
+
+	if searchBulk != nil {
+		writer.WriteString("Rate Search:\n")
+		writeBulk(writer, searchBulk)
+		writer.WriteString("\n")
+	}
+
 	writeBulkWith(writer, statsBulk, "", "  ", "\n")
 	writer.WriteString("\n")
 
@@ -235,8 +526,87 @@ func (p *Printer) formatTableReports(writer *bytes.Buffer, snapshot *SnapshotRep
 	writeBulk(writer, percBulk)
 	writer.WriteString("\n")
 
+	if msgPercBulk != nil && snapshot.MQTT != nil {
+		writer.WriteString("Delivery Latency Percentile:\n")
+		writeBulk(writer, msgPercBulk)
+		writer.WriteString("\n")
+	} else if msgPercBulk != nil {
+		writer.WriteString("Message Latency Percentile:\n")
+		writeBulk(writer, msgPercBulk)
+		writer.WriteString("\n")
+	}
+
+	if queuePercBulk != nil {
+		writer.WriteString("Queue Wait/Response Percentile:\n")
+		writeBulk(writer, queuePercBulk)
+		writer.WriteString("\n")
+	}
+
+	if connBulk != nil {
+		writer.WriteString("Cold/Warm Connection Percentile:\n")
+		writeBulk(writer, connBulk)
+		writer.WriteString("\n")
+	}
+
+	if outliersBulk != nil {
+		writer.WriteString("Latency Outliers:\n")
+		writeBulk(writer, outliersBulk)
+		writer.WriteString("\n")
+	}
+
 	writer.WriteString("Latency Histogram:\n")
 	writeBulk(writer, hisBulk)
+
+	if isFinal && len(p.stages) > 0 {
+		writer.WriteString("\nStages:\n")
+		writeBulk(writer, p.buildStages(snapshot, useSeconds))
+	}
+
+	if isFinal && len(p.thresholds) > 0 {
+		writer.WriteString("\nThresholds:\n")
+		writeBulk(writer, p.buildThresholds(snapshot))
+	}
+}
+
+func (p *Printer) buildStages(snapshot *SnapshotReport, useSeconds bool) [][]string {
+	bulk := [][]string{{"", "Stage", "Count", "RPS", "P99", "Errors"}}
+	results := evaluateStages(p.stages, snapshot, useSeconds)
+	for i, r := range results {
+		mark := colorize("✓", FgGreenColor)
+		if !r.Passed {
+			mark = colorize("✗", FgRedColor)
+		}
+		p99 := ""
+		for _, pc := range stageSnapshot(snapshot, i).Percentiles {
+			if pc.Percentile == 0.99 {
+				p99 = durationToString(pc.Latency, useSeconds)
+			}
+		}
+		bulk = append(bulk, []string{mark, r.Stage, strconv.FormatInt(r.Count, 10),
+			fmt.Sprintf("%.3f", r.RPS), p99, strconv.FormatInt(r.Errors, 10)})
+		for _, t := range r.Thresholds {
+			tm := colorize("✓", FgGreenColor)
+			if !t.Passed {
+				tm = colorize("✗", FgRedColor)
+			}
+			bulk = append(bulk, []string{"", "  " + tm + " " + t.Threshold, t.Actual})
+		}
+	}
+	alignBulk(bulk, AlignLeft, AlignLeft, AlignRight, AlignRight, AlignRight, AlignRight)
+	return bulk
+}
+
+func (p *Printer) buildThresholds(snapshot *SnapshotReport) [][]string {
+	var bulk [][]string
+	for _, r := range evaluateThresholds(p.thresholds, snapshot) {
+		mark := colorize("✓", FgGreenColor)
+		if !r.Passed {
+			mark = colorize("✗", FgRedColor)
+		}
+		bulk = append(bulk, []string{mark, r.Threshold, r.Actual})
+	}
+	alignBulk(bulk, AlignLeft, AlignLeft, AlignLeft)
+	return bulk
 }
 
 func (p *Printer) buildJSONHistogram(writer *bytes.Buffer, snapshot *SnapshotReport, useSeconds bool, indent int) {
@@ -295,6 +665,542 @@ func (p *Printer) buildHistogram(snapshot *SnapshotReport, useSeconds bool, isFi
 	return hisBulk
 }
 
+func (p *Printer) buildProxies(snapshot *SnapshotReport, useSeconds bool) [][]string {
+	if len(snapshot.Proxies) < 2 {
+		return nil
+	}
+	bulk := [][]string{{"", "Count", "Errors", "Mean", "Max"}}
+	for _, px := range snapshot.Proxies {
+		bulk = append(bulk, []string{
+			px.Proxy,
+			strconv.FormatInt(px.Count, 10),
+			strconv.FormatInt(px.Errors, 10),
+			durationToString(px.Mean, useSeconds),
+			durationToString(px.Max, useSeconds),
+		})
+	}
+	alignBulk(bulk, AlignLeft, AlignRight, AlignRight, AlignRight, AlignRight)
+	return bulk
+}
+
+func (p *Printer) buildTargets(snapshot *SnapshotReport, useSeconds bool) [][]string {
+	if len(snapshot.Targets) == 0 {
+		return nil
+	}
+	weighted := snapshot.Targets[0].Weight > 0
+	bulk := [][]string{{"", "Count", "RPS", "Errors", "Mean", "P90", "P99", "Max"}}
+	aligns := []int{AlignLeft, AlignRight, AlignRight, AlignRight, AlignRight, AlignRight, AlignRight, AlignRight}
+	if weighted {
+		bulk[0] = append(bulk[0], "Weight")
+		aligns = append(aligns, AlignRight)
+	}
+	for _, t := range snapshot.Targets {
+		row := []string{
+			t.URL,
+			strconv.FormatInt(t.Count, 10),
+			fmt.Sprintf("%.3f", t.RPS),
+			strconv.FormatInt(t.Errors, 10),
+			durationToString(t.Mean, useSeconds),
+			durationToString(t.P90, useSeconds),
+			durationToString(t.P99, useSeconds),
+			durationToString(t.Max, useSeconds),
+		}
+		if weighted {
+			row = append(row, strconv.Itoa(t.Weight))
+		}
+		bulk = append(bulk, row)
+	}
+	alignBulk(bulk, aligns...)
+	return bulk
+}
+
+func (p *Printer) buildRegions(snapshot *SnapshotReport, useSeconds bool) [][]string {
+	if len(snapshot.Regions) == 0 {
+		return nil
+	}
+	bulk := [][]string{{"", "Agents", "Count", "RPS", "Errors", "Mean", "P50", "P90", "P99", "Max"}}
+	for _, r := range snapshot.Regions {
+		bulk = append(bulk, []string{
+			r.Region,
+			strconv.Itoa(r.Agents),
+			strconv.FormatInt(r.Count, 10),
+			fmt.Sprintf("%.3f", r.RPS),
+			strconv.FormatInt(r.Errors, 10),
+			durationToString(r.Mean, useSeconds),
+			durationToString(r.P50, useSeconds),
+			durationToString(r.P90, useSeconds),
+			durationToString(r.P99, useSeconds),
+			durationToString(r.Max, useSeconds),
+		})
+	}
+	alignBulk(bulk, AlignLeft, AlignRight, AlignRight, AlignRight, AlignRight, AlignRight, AlignRight, AlignRight, AlignRight, AlignRight)
+	return bulk
+}
+
+func (p *Printer) buildAddresses(snapshot *SnapshotReport, useSeconds bool) [][]string {
+	if len(snapshot.Addresses) < 2 {
+		return nil
+	}
+	bulk := [][]string{{"", "Count", "Errors", "Mean", "Max"}}
+	for _, a := range snapshot.Addresses {
+		bulk = append(bulk, []string{
+			a.Addr,
+			strconv.FormatInt(a.Count, 10),
+			strconv.FormatInt(a.Errors, 10),
+			durationToString(a.Mean, useSeconds),
+			durationToString(a.Max, useSeconds),
+		})
+	}
+	alignBulk(bulk, AlignLeft, AlignRight, AlignRight, AlignRight, AlignRight)
+	return bulk
+}
+
+func (p *Printer) buildConnPercentile(snapshot *SnapshotReport, useSeconds bool) [][]string {
+	if snapshot.Cold == nil || snapshot.Warm == nil {
+		return nil
+	}
+	bulk := [][]string{{"", "Count", "Mean"}}
+	aligns := []int{AlignLeft, AlignRight, AlignCenter}
+	for _, q := range quantiles {
+		bulk[0] = append(bulk[0], "P"+formatFloat64(q*100))
+		aligns = append(aligns, AlignCenter)
+	}
+	for _, c := range []struct {
+		name string
+		r    *ConnLatencyReport
+	}{{"Cold", snapshot.Cold}, {"Warm", snapshot.Warm}} {
+		row := []string{c.name, strconv.FormatInt(c.r.Count, 10), durationToString(c.r.Mean, useSeconds)}
+		for _, l := range c.r.Percentiles {
+			row = append(row, durationToString(l, useSeconds))
+		}
+		bulk = append(bulk, row)
+	}
+	alignBulk(bulk, aligns...)
+	return bulk
+}
+
+// buildOutliers is the seconds of the run whose P99 spiked, clustered, and
+// the period of the clusters when they come back at regular intervals
+func (p *Printer) buildOutliers(snapshot *SnapshotReport, useSeconds bool) [][]string {
+	o := snapshot.Outliers
+	if o == nil {
+		return nil
+	}
+	var peak time.Duration
+	var at []string
+	for i, c := range o.Clusters {
+		if c.Peak > peak {
+			peak = c.Peak
+		}
+		if i == maxOutlierTimes {
+			at = append(at, "...")
+		} else if i < maxOutlierTimes {
+			at = append(at, c.Start.Truncate(time.Second).String())
+		}
+	}
+	period := "none"
+	if o.Period > 0 {
+		period = colorize(fmt.Sprintf("every %s ±%s, e.g. a periodic job of the server", o.Period, o.Jitter), FgYellowColor)
+	}
+	bulk := [][]string{
+		{"Threshold", durationToString(o.Threshold, useSeconds) + fmt.Sprintf(" (%dx the median per-second P99)", outlierFactor)},
+		{"Clusters", strconv.Itoa(len(o.Clusters))},
+		{"Peak P99", durationToString(peak, useSeconds)},
+		{"At", strings.Join(at, " ")},
+		{"Period", period},
+	}
+	alignBulk(bulk, AlignLeft, AlignLeft)
+	return bulk
+}
+
+// buildGRPC counts the messages of the gRPC calls
+func (p *Printer) buildGRPC(snapshot *SnapshotReport) [][]string {
+	g := snapshot.GRPC
+	if g == nil {
+		return nil
+	}
+	grpcBulk := [][]string{
+		{"Type", g.Type},
+		{"Sent", strconv.FormatInt(g.Sent, 10)},
+		{"Received", strconv.FormatInt(g.Received, 10)},
+		{"Msg/s", strconv.FormatFloat(g.Rate, 'f', 3, 64)},
+	}
+	alignBulk(grpcBulk, AlignLeft, AlignRight)
+	return grpcBulk
+}
+
+// buildGRPCCodes counts the gRPC calls by status, the most frequent first
+func (p *Printer) buildGRPCCodes(snapshot *SnapshotReport) [][]string {
+	g := snapshot.GRPC
+	if g == nil || len(g.Codes) == 0 {
+		return nil
+	}
+	var total int64
+	for _, n := range g.Codes {
+		total += n
+	}
+	names := sortedKeys(g.Codes)
+	sort.SliceStable(names, func(i, j int) bool { return g.Codes[names[i]] > g.Codes[names[j]] })
+	bulk := make([][]string, 0, len(names))
+	for _, name := range names {
+		n := g.Codes[name]
+		label := name
+		if name != "OK" {
+			label = colorize(name, FgRedColor)
+		}
+		bulk = append(bulk, []string{label, strconv.FormatInt(n, 10), fmt.Sprintf("%.2f%%", float64(n)*100/float64(total))})
+	}
+	alignBulk(bulk, AlignLeft, AlignRight, AlignRight)
+	return bulk
+}
+
+// buildDNS counts the DNS queries by response code, the most frequent first
+func (p *Printer) buildDNS(snapshot *SnapshotReport) [][]string {
+	d := snapshot.DNS
+	if d == nil || len(d.Rcodes) == 0 {
+		return nil
+	}
+	var total int64
+	for _, n := range d.Rcodes {
+		total += n
+	}
+	names := sortedKeys(d.Rcodes)
+	sort.SliceStable(names, func(i, j int) bool { return d.Rcodes[names[i]] > d.Rcodes[names[j]] })
+	bulk := make([][]string, 0, len(names))
+	for _, name := range names {
+		n := d.Rcodes[name]
+		label := name
+		if !dnsAnswered(name) {
+			label = colorize(name, FgRedColor)
+		}
+		bulk = append(bulk, []string{label, strconv.FormatInt(n, 10), fmt.Sprintf("%.2f%%", float64(n)*100/float64(total))})
+	}
+	alignBulk(bulk, AlignLeft, AlignRight, AlignRight)
+	return bulk
+}
+
+// buildMQTT counts the messages delivered to the subscribers
+func (p *Printer) buildMQTT(snapshot *SnapshotReport) [][]string {
+	m := snapshot.MQTT
+	if m == nil {
+		return nil
+	}
+	bulk := [][]string{
+		{"QoS", strconv.Itoa(m.QoS)},
+		{"Delivered", strconv.FormatInt(m.Delivered, 10)},
+		{"Msg/s", strconv.FormatFloat(m.Rate, 'f', 3, 64)},
+	}
+	alignBulk(bulk, AlignLeft, AlignRight)
+	return bulk
+}
+
+// buildRedis counts the commands of the Redis pipelines with their nil and
+// error replies
+func (p *Printer) buildRedis(snapshot *SnapshotReport) [][]string {
+	r := snapshot.Redis
+	if r == nil {
+		return nil
+	}
+	share := func(n int64) string {
+		if r.Commands == 0 {
+			return strconv.FormatInt(n, 10)
+		}
+		return fmt.Sprintf("%d (%.2f%%)", n, float64(n)*100/float64(r.Commands))
+	}
+	errs := share(r.Errors)
+	if r.Errors > 0 {
+		errs = colorize(errs, FgRedColor)
+	}
+	bulk := [][]string{
+		{"Commands", strconv.FormatInt(r.Commands, 10)},
+		{"Ops/s", strconv.FormatFloat(r.Rate, 'f', 3, 64)},
+		{"Nil", share(r.Nil)},
+		{"Errors", errs},
+	}
+	alignBulk(bulk, AlignLeft, AlignRight)
+	return bulk
+}
+
+// buildKafka counts the messages produced
+func (p *Printer) buildKafka(snapshot *SnapshotReport) [][]string {
+	k := snapshot.Kafka
+	if k == nil {
+		return nil
+	}
+	bulk := [][]string{
+		{"Acks", k.Acks},
+		{"Batch", strconv.Itoa(k.Batch)},
+		{"Messages", strconv.FormatInt(k.Messages, 10)},
+		{"Msg/s", strconv.FormatFloat(k.Rate, 'f', 3, 64)},
+		{"Bytes", formatBytes(float64(k.Bytes))},
+	}
+	alignBulk(bulk, AlignLeft, AlignRight)
+	return bulk
+}
+
+// buildSQL counts the rows read by the queries, and their errors by
+// SQLSTATE or MySQL error number
+func (p *Printer) buildSQL(snapshot *SnapshotReport) ([][]string, [][]string) {
+	q := snapshot.SQL
+	if q == nil {
+		return nil, nil
+	}
+	bulk := [][]string{
+		{"Pool", strconv.Itoa(q.Pool)},
+		{"Rows", strconv.FormatInt(q.Rows, 10)},
+		{"Rows/s", strconv.FormatFloat(q.Rate, 'f', 3, 64)},
+	}
+	alignBulk(bulk, AlignLeft, AlignRight)
+	if len(q.Codes) == 0 {
+		return bulk, nil
+	}
+	var total int64
+	for _, n := range q.Codes {
+		total += n
+	}
+	names := sortedKeys(q.Codes)
+	sort.SliceStable(names, func(i, j int) bool { return q.Codes[names[i]] > q.Codes[names[j]] })
+	codes := make([][]string, 0, len(names))
+	for _, name := range names {
+		n := q.Codes[name]
+		codes = append(codes, []string{colorize(name, FgRedColor), strconv.FormatInt(n, 10), fmt.Sprintf("%.2f%%", float64(n)*100/float64(total))})
+	}
+	alignBulk(codes, AlignLeft, AlignRight, AlignRight)
+	return bulk, codes
+}
+
+// buildBackend counts the requests of a --backend by the codes of their
+// records
+func (p *Printer) buildBackend(snapshot *SnapshotReport) [][]string {
+	b := snapshot.Backend
+	if b == nil || len(b.Codes) == 0 {
+		return nil
+	}
+	var total int64
+	for _, n := range b.Codes {
+		total += n
+	}
+	names := sortedKeys(b.Codes)
+	sort.SliceStable(names, func(i, j int) bool { return b.Codes[names[i]] > b.Codes[names[j]] })
+	bulk := make([][]string, 0, len(names))
+	for _, name := range names {
+		n := b.Codes[name]
+		bulk = append(bulk, []string{name, strconv.FormatInt(n, 10), fmt.Sprintf("%.2f%%", float64(n)*100/float64(total))})
+	}
+	alignBulk(bulk, AlignLeft, AlignRight, AlignRight)
+	return bulk
+}
+
+// buildPipeline is the throughput of each pipelining connection
+func (p *Printer) buildPipeline(snapshot *SnapshotReport) [][]string {
+	pl := snapshot.Pipeline
+	if pl == nil {
+		return nil
+	}
+	bulk := [][]string{
+		{"Depth", strconv.Itoa(pl.Depth)},
+		{"Connections", strconv.Itoa(pl.Connections)},
+		{"RPS/conn", strconv.FormatFloat(pl.RPSPerConn, 'f', 3, 64)},
+	}
+	alignBulk(bulk, AlignLeft, AlignRight)
+	return bulk
+}
+
+// buildSSE counts the events and the drops of the --sse streams
+func (p *Printer) buildSSE(snapshot *SnapshotReport) [][]string {
+	s := snapshot.SSE
+	if s == nil {
+		return nil
+	}
+	dropped := strconv.FormatInt(s.Dropped, 10)
+	if s.Dropped > 0 {
+		dropped = colorize(dropped, FgRedColor)
+	}
+	sseBulk := [][]string{
+		{"Open", strconv.FormatInt(s.Open, 10)},
+		{"Events", strconv.FormatInt(s.Events, 10)},
+		{"Events/s", strconv.FormatFloat(s.Rate, 'f', 3, 64)},
+		{"Dropped", dropped},
+		{"Reconnects", strconv.FormatInt(s.Reconnects, 10)},
+	}
+	alignBulk(sseBulk, AlignLeft, AlignRight)
+	return sseBulk
+}
+
+// buildMessagePercentile is the latency of each message of the gRPC calls,
+// or of the delivery of the MQTT messages to the subscribers
+func (p *Printer) buildMessagePercentile(snapshot *SnapshotReport, useSeconds bool) [][]string {
+	var l *ConnLatencyReport
+	if snapshot.GRPC != nil {
+		l = snapshot.GRPC.Latency
+	} else if snapshot.MQTT != nil {
+		l = snapshot.MQTT.Latency
+	}
+	if l == nil {
+		return nil
+	}
+	bulk := [][]string{{"Count", "Mean"}, {strconv.FormatInt(l.Count, 10), durationToString(l.Mean, useSeconds)}}
+	aligns := []int{AlignLeft, AlignCenter}
+	for i, q := range quantiles {
+		bulk[0] = append(bulk[0], "P"+formatFloat64(q*100))
+		bulk[1] = append(bulk[1], durationToString(l.Percentiles[i], useSeconds))
+		aligns = append(aligns, AlignCenter)
+	}
+	alignBulk(bulk, aligns...)
+	return bulk
+}
+
+// buildArrivals counts the open-loop arrivals dropped on a full queue
+func (p *Printer) buildArrivals(snapshot *SnapshotReport) [][]string {
+	a := snapshot.Arrivals
+	if a == nil {
+		return nil
+	}
+	dropped := strconv.FormatInt(a.Dropped, 10)
+	if total := snapshot.Count + a.Dropped; a.Dropped > 0 && total > 0 {
+		dropped = colorize(fmt.Sprintf("%d (%.2f%%)", a.Dropped, float64(a.Dropped)*100/float64(total)), FgRedColor)
+	}
+	bulk := [][]string{
+		{"Model", a.Model},
+		{"Max Queue", strconv.Itoa(a.MaxQueue)},
+		{"Dropped", dropped},
+	}
+	alignBulk(bulk, AlignLeft, AlignRight)
+	return bulk
+}
+
+// buildBackpressure warns of the records which waited for the report, the
+// workers not sending requests meanwhile
+func (p *Printer) buildBackpressure(snapshot *SnapshotReport, useSeconds bool) [][]string {
+	b := snapshot.Backpressure
+	if b == nil {
+		return nil
+	}
+	stalled := strconv.FormatInt(b.Stalled, 10)
+	if snapshot.Count > 0 {
+		stalled = fmt.Sprintf("%d (%.2f%%)", b.Stalled, float64(b.Stalled)*100/float64(snapshot.Count))
+	}
+	bulk := [][]string{
+		{"Stalled Records", colorize(stalled, FgYellowColor)},
+		{"Stall Time", durationToString(b.StallTime, useSeconds)},
+	}
+	alignBulk(bulk, AlignLeft, AlignRight)
+	return bulk
+}
+
+// buildClient warns of the seconds during which plow itself was saturated,
+// its results measuring the generator rather than the server
+func (p *Printer) buildClient(snapshot *SnapshotReport) [][]string {
+	c := snapshot.Client
+	if c == nil {
+		return nil
+	}
+	bulk := [][]string{
+		{"Saturated", colorize(fmt.Sprintf("%ds", c.Saturated), FgYellowColor)},
+		{"Peak CPU", fmt.Sprintf("%g%% of %d cores", c.PeakCPU, c.Cores)},
+		{"Cause", c.Reason},
+	}
+	alignBulk(bulk, AlignLeft, AlignRight)
+	return bulk
+}
+
+// buildServer is the utilization of the target host reported by its probe
+func (p *Printer) buildServer(snapshot *SnapshotReport) [][]string {
+	s := snapshot.Server
+	if s == nil {
+		return nil
+	}
+	bulk := [][]string{
+		{s.Host, "Mean", "Peak"},
+		{"CPU", fmt.Sprintf("%g%%", s.MeanCPU), fmt.Sprintf("%g%%", s.PeakCPU)},
+		{"Memory", fmt.Sprintf("%g%%", s.MeanMem), fmt.Sprintf("%g%%", s.PeakMem)},
+	}
+	alignBulk(bulk, AlignLeft, AlignRight, AlignRight)
+	return bulk
+}
+
+// buildSearch is the steps of the search, then the max rate it found
+func (p *Printer) buildSearch(snapshot *SnapshotReport, useSeconds bool) [][]string {
+	s := snapshot.Search
+	if s == nil {
+		return nil
+	}
+	bulk := [][]string{{"", "Rate", "RPS", "P99", "Errors", "Error Rate", ""}}
+	for _, st := range s.Steps {
+		mark := colorize("✓", FgGreenColor)
+		if !st.Passed {
+			mark = colorize("✗", FgRedColor)
+		}
+		bulk = append(bulk, []string{mark, formatFloat64(st.Rate), fmt.Sprintf("%.3f", st.RPS),
+			durationToString(st.P99, useSeconds), strconv.FormatInt(st.Errors, 10), formatFloat64(st.ErrorRate) + "%", strings.Join(st.Failed, ", ")})
+	}
+	max := colorize(fmt.Sprintf("none within %s-%s", formatFloat64(s.Min), formatFloat64(s.Max)), FgRedColor)
+	if s.Rate > 0 {
+		max = colorize(formatFloat64(s.Rate)+" req/s", FgGreenColor)
+	}
+	bulk = append(bulk, []string{"", "Max Rate", max, "", "", "", ""})
+	alignBulk(bulk, AlignLeft, AlignRight, AlignRight, AlignRight, AlignRight, AlignRight, AlignLeft)
+	return bulk
+}
+
+// buildReplay is how closely the replay kept the times of the access log,
+// the requests late by more than replayTolerance in red
+func (p *Printer) buildReplay(snapshot *SnapshotReport, useSeconds bool) [][]string {
+	r := snapshot.Replay
+	if r == nil {
+		return nil
+	}
+	onTime := "-"
+	if r.Sent > 0 {
+		onTime = fmt.Sprintf("%d (%.2f%%)", r.OnTime, float64(r.OnTime)*100/float64(r.Sent))
+		if r.OnTime < r.Sent {
+			onTime = colorize(onTime, FgRedColor)
+		}
+	}
+	bulk := [][]string{
+		{"Speed", formatFloat64(r.Speed) + "x"},
+		{"Sent", fmt.Sprintf("%d of %d", r.Sent, r.Entries)},
+		{"On Time (" + durationToString(replayTolerance, useSeconds) + ")", onTime},
+	}
+	if r.Lag != nil {
+		for i, q := range quantiles {
+			if q == 0.5 || q == 0.99 {
+				bulk = append(bulk, []string{"Lag P" + formatFloat64(q*100), durationToString(r.Lag.Percentiles[i], useSeconds)})
+			}
+		}
+		bulk = append(bulk, []string{"Lag Max", durationToString(r.MaxLag, useSeconds)})
+	}
+	alignBulk(bulk, AlignLeft, AlignRight)
+	return bulk
+}
+
+// buildQueuePercentile is the wait of the open-loop arrivals for a free
+// connection, and their latency from the time they were scheduled at
+func (p *Printer) buildQueuePercentile(snapshot *SnapshotReport, useSeconds bool) [][]string {
+	a := snapshot.Arrivals
+	if a == nil || a.QueueWait == nil {
+		return nil
+	}
+	bulk := [][]string{{"", "Mean"}}
+	aligns := []int{AlignLeft, AlignCenter}
+	for _, q := range quantiles {
+		bulk[0] = append(bulk[0], "P"+formatFloat64(q*100))
+		aligns = append(aligns, AlignCenter)
+	}
+	for _, c := range []struct {
+		name string
+		r    *ConnLatencyReport
+	}{{"Queue Wait", a.QueueWait}, {"Response", a.Response}} {
+		row := []string{c.name, durationToString(c.r.Mean, useSeconds)}
+		for _, l := range c.r.Percentiles {
+			row = append(row, durationToString(l, useSeconds))
+		}
+		bulk = append(bulk, row)
+	}
+	alignBulk(bulk, aligns...)
+	return bulk
+}
+
 func (p *Printer) buildJSONPercentile(writer *bytes.Buffer, snapshot *SnapshotReport, useSeconds bool, indent int) {
 	tab0 := strings.Repeat("  ", indent)
 	writer.WriteString(tab0 + "\"Percentiles\": {\n")
@@ -393,9 +1299,9 @@ func (p *Printer) buildJSONErrors(writer *bytes.Buffer, snapshot *SnapshotReport
 	writer.WriteString(tab0 + "}")
 }
 
-func (p *Printer) buildErrors(snapshot *SnapshotReport) [][]string {
+func (p *Printer) buildErrors(errors map[string]int64) [][]string {
 	var errorsBulks [][]string
-	for k, v := range snapshot.Errors {
+	for k, v := range errors {
 		vs := colorize(strconv.FormatInt(v, 10), FgRedColor)
 		errorsBulks = append(errorsBulks, []string{vs, "\"" + k + "\""})
 	}
@@ -406,6 +1312,304 @@ func (p *Printer) buildErrors(snapshot *SnapshotReport) [][]string {
 	return errorsBulks
 }
 
+func formatEjectionWindows(windows [][2]time.Duration) []string {
+	res := make([]string, 0, len(windows))
+	for _, w := range windows {
+		start := w[0].Truncate(100 * time.Millisecond).String()
+		end := ""
+		if w[1] >= 0 {
+			end = w[1].Truncate(100 * time.Millisecond).String()
+		}
+		res = append(res, start+"-"+end)
+	}
+	return res
+}
+
+func (p *Printer) buildJSONEjections(writer *bytes.Buffer, snapshot *SnapshotReport, indent int) {
+	tab0 := strings.Repeat("  ", indent)
+	writer.WriteString(tab0 + "\"Ejections\": {\n")
+	tab1 := strings.Repeat("  ", indent+1)
+	for i, e := range snapshot.Ejections {
+		ub, _ := json.Marshal(e.URL)
+		wb, _ := json.Marshal(formatEjectionWindows(e.Windows))
+		writer.WriteString(fmt.Sprintf(`%s%s: %s`, tab1, ub, wb))
+		if i != len(snapshot.Ejections)-1 {
+			writer.WriteString(",")
+		}
+		writer.WriteString("\n")
+	}
+	writer.WriteString(tab0 + "}")
+}
+
+func (p *Printer) buildEjections(snapshot *SnapshotReport) [][]string {
+	var ejectionsBulk [][]string
+	for _, e := range snapshot.Ejections {
+		vs := colorize(strconv.Itoa(len(e.Windows)), FgYellowColor)
+		ejectionsBulk = append(ejectionsBulk, []string{vs, e.URL, strings.Join(formatEjectionWindows(e.Windows), " ")})
+	}
+	alignBulk(ejectionsBulk, AlignLeft, AlignLeft, AlignLeft)
+	return ejectionsBulk
+}
+
+// buildWarmup is the warmup excluded from the summary, detected or still
+// going on
+func (p *Printer) buildWarmup(snapshot *SnapshotReport, isFinal bool) [][]string {
+	w := snapshot.Warmup
+	if w == nil {
+		return nil
+	}
+	state := "stabilized"
+	if !w.Stable {
+		state = colorize("not stabilized", FgYellowColor)
+		if !isFinal && snapshot.Count == 0 {
+			state = "detecting"
+		}
+	}
+	warmupBulk := [][]string{
+		{"Duration", w.Duration.Truncate(100 * time.Millisecond).String()},
+		{"Excluded", strconv.FormatInt(w.Excluded, 10)},
+	}
+	if w.Auto {
+		warmupBulk = append(warmupBulk, []string{"Latency", state})
+	}
+	alignBulk(warmupBulk, AlignLeft, AlignRight)
+	return warmupBulk
+}
+
+// buildErrorClasses counts the errors by class, in the order of the classes,
+// with their share of the requests
+func (p *Printer) buildErrorClasses(snapshot *SnapshotReport) [][]string {
+	if len(snapshot.ErrorClasses) == 0 {
+		return nil
+	}
+	var bulk [][]string
+	for _, name := range errorClassNames {
+		n, ok := snapshot.ErrorClasses[name]
+		if !ok {
+			continue
+		}
+		share := 0.0
+		if snapshot.Count > 0 {
+			share = float64(n) * 100 / float64(snapshot.Count)
+		}
+		bulk = append(bulk, []string{name, colorize(strconv.FormatInt(n, 10), FgRedColor), strconv.FormatFloat(share, 'f', 2, 64) + "%"})
+	}
+	alignBulk(bulk, AlignLeft, AlignRight, AlignRight)
+	return bulk
+}
+
+// buildBodyVariants is the most frequent bodies of --hash-bodies by status,
+// with the start of each
+func (p *Printer) buildBodyVariants(snapshot *SnapshotReport) [][]string {
+	bv := snapshot.BodyVariants
+	if bv == nil {
+		return nil
+	}
+	bulk := [][]string{{"Code", "Hash", "Count", "Share", "Size", "Body"}}
+	for _, v := range bv.Variants {
+		bulk = append(bulk, []string{
+			strconv.Itoa(v.Code),
+			v.Hash,
+			strconv.FormatInt(v.Count, 10),
+			strconv.FormatFloat(v.Share*100, 'f', 2, 64) + "%",
+			formatBytes(float64(v.Size)),
+			v.Sample,
+		})
+	}
+	if more := bv.Distinct - len(bv.Variants); more > 0 {
+		bulk = append(bulk, []string{"", fmt.Sprintf("%d more", more)})
+	}
+	alignBulk(bulk, AlignLeft, AlignLeft, AlignRight, AlignRight, AlignRight, AlignLeft)
+	return bulk
+}
+
+// buildValidation counts the bodies checked with --validate, and the
+// malformed ones by class
+func (p *Printer) buildValidation(snapshot *SnapshotReport) [][]string {
+	v := snapshot.Validation
+	if v == nil {
+		return nil
+	}
+	var malformed int64
+	for _, n := range v.Malformed {
+		malformed += n
+	}
+	share := strconv.FormatFloat(float64(malformed)*100/float64(v.Checked), 'f', 2, 64) + "%"
+	if malformed > 0 {
+		share = colorize(share, FgRedColor)
+	}
+	bulk := [][]string{
+		{"Checked", strconv.FormatInt(v.Checked, 10)},
+		{"Malformed", strconv.FormatInt(malformed, 10), share},
+	}
+	for _, kv := range sortMapStrInt(v.Malformed) {
+		bulk = append(bulk, []string{"  " + kv[0], kv[1]})
+	}
+	alignBulk(bulk, AlignLeft, AlignRight, AlignLeft)
+	return bulk
+}
+
+// buildRedirects counts the redirects followed, and how the 3xx responses
+// count
+func (p *Printer) buildRedirects(snapshot *SnapshotReport) [][]string {
+	rd := snapshot.Redirects
+	if rd == nil {
+		return nil
+	}
+	status := "success"
+	if rd.Failure {
+		status = colorize("failure", FgMagentaColor)
+	}
+	redirectsBulk := [][]string{
+		{"Followed", strconv.FormatInt(rd.Followed, 10)},
+		{"Requests", strconv.FormatInt(rd.Redirected, 10)},
+		{"3xx", status},
+	}
+	alignBulk(redirectsBulk, AlignLeft, AlignRight)
+	return redirectsBulk
+}
+
+// buildStaleConns counts the requests that failed on a reused connection
+func (p *Printer) buildStaleConns(snapshot *SnapshotReport) [][]string {
+	sc := snapshot.StaleConns
+	if sc == nil {
+		return nil
+	}
+	staleBulk := [][]string{
+		{"Retried", strconv.FormatInt(sc.Retried, 10)},
+		{"Surfaced", strconv.FormatInt(sc.Surfaced, 10)},
+	}
+	alignBulk(staleBulk, AlignLeft, AlignRight)
+	return staleBulk
+}
+
+func (p *Printer) buildAuth(snapshot *SnapshotReport, useSeconds bool) [][]string {
+	a := snapshot.Auth
+	if a == nil {
+		return nil
+	}
+	authBulk := [][]string{
+		{"Handshakes", strconv.FormatInt(a.Handshakes, 10)},
+		{"Mean", durationToString(a.Mean, useSeconds)},
+		{"Max", durationToString(a.Max, useSeconds)},
+		{"Total", durationToString(a.Total, useSeconds)},
+	}
+	alignBulk(authBulk, AlignLeft, AlignRight)
+	return authBulk
+}
+
+// buildBodySize is the size of the bodies of the requests without error
+func (p *Printer) buildBodySize(snapshot *SnapshotReport) [][]string {
+	bs := snapshot.BodySize
+	if bs == nil {
+		return nil
+	}
+	bulk := [][]string{{"", "Min", "Mean", "Max", "Total"}}
+	rows := []struct {
+		name string
+		s    BodySizeStats
+	}{{"Request", bs.Request}, {"Response", bs.Response}}
+	if bs.Decoded != nil {
+		rows = append(rows, struct {
+			name string
+			s    BodySizeStats
+		}{"Decoded", *bs.Decoded})
+	}
+	for _, row := range rows {
+		bulk = append(bulk, []string{row.name, formatBytes(float64(row.s.Min)), formatBytes(row.s.Mean), formatBytes(float64(row.s.Max)), formatBytes(float64(row.s.Total))})
+	}
+	if bs.Decoded != nil && bs.Ratio > 0 {
+		bulk = append(bulk, []string{"Ratio", "", "", "", fmt.Sprintf("%.2fx", bs.Ratio)})
+	}
+	alignBulk(bulk, AlignLeft, AlignRight, AlignRight, AlignRight, AlignRight)
+	return bulk
+}
+
+// buildSizeHistogram spreads the responses by size, shown once they differ
+func (p *Printer) buildSizeHistogram(snapshot *SnapshotReport, isFinal bool) [][]string {
+	bs := snapshot.BodySize
+	if bs == nil || len(bs.Histogram) < 2 {
+		return nil
+	}
+	var maxCount, sum int64
+	for _, bin := range bs.Histogram {
+		maxCount = max(maxCount, bin.Count)
+		sum += bin.Count
+	}
+	bulk := make([][]string, 0, len(bs.Histogram))
+	for _, bin := range bs.Histogram {
+		size := formatBytes(float64(bin.Min))
+		if bin.Max != bin.Min {
+			size += " - " + formatBytes(float64(bin.Max))
+		}
+		row := []string{size, strconv.FormatInt(bin.Count, 10)}
+		if isFinal {
+			row = append(row, fmt.Sprintf("%.2f%%", float64(bin.Count)*100/float64(sum)))
+		}
+		if !isFinal || p.noClean {
+			row = append(row, strings.Repeat(barBody, int((bin.Count*int64(maxBarLen)+maxCount/2)/maxCount)))
+		}
+		bulk = append(bulk, row)
+	}
+	if isFinal {
+		alignBulk(bulk, AlignLeft, AlignRight, AlignRight)
+	} else {
+		alignBulk(bulk, AlignLeft, AlignRight, AlignLeft)
+	}
+	return bulk
+}
+
+func (p *Printer) buildServerTiming(snapshot *SnapshotReport, useSeconds bool) [][]string {
+	if len(snapshot.ServerTiming) == 0 {
+		return nil
+	}
+	bulk := [][]string{{"", "Count", "Mean", "Max", "Share"}}
+	for _, st := range snapshot.ServerTiming {
+		bulk = append(bulk, []string{
+			st.Name,
+			strconv.FormatInt(st.Count, 10),
+			durationToString(st.Mean, useSeconds),
+			durationToString(st.Max, useSeconds),
+			strconv.FormatFloat(st.Share*100, 'f', 1, 64) + "%",
+		})
+	}
+	alignBulk(bulk, AlignLeft, AlignRight, AlignRight, AlignRight, AlignRight)
+	return bulk
+}
+
+func (p *Printer) buildClientCerts(snapshot *SnapshotReport) [][]string {
+	if len(snapshot.ClientCerts) == 0 {
+		return nil
+	}
+	bulk := [][]string{{"Subject", "Serial", "Expires", "Handshakes"}}
+	for _, c := range snapshot.ClientCerts {
+		bulk = append(bulk, []string{
+			c.Subject,
+			c.Serial,
+			c.NotAfter.Local().Format("2006-01-02 15:04:05"),
+			strconv.FormatInt(c.Handshakes, 10),
+		})
+	}
+	alignBulk(bulk, AlignLeft, AlignLeft, AlignLeft, AlignRight)
+	return bulk
+}
+
+func (p *Printer) buildExtracted(snapshot *SnapshotReport) [][]string {
+	if len(snapshot.Extracted) == 0 {
+		return nil
+	}
+	bulk := [][]string{{"", "Count", "Min", "Mean", "Max", "Last"}}
+	for _, e := range snapshot.Extracted {
+		row := []string{e.Name, strconv.FormatInt(e.Count, 10), "-", "-", "-", "-"}
+		if e.Count > 0 {
+			row[2], row[3], row[4], row[5] = formatFloat64(e.Min), formatFloat64(roundFloat(e.Mean, 3)), formatFloat64(e.Max), formatFloat64(e.Last)
+		}
+		bulk = append(bulk, row)
+	}
+	alignBulk(bulk, AlignLeft, AlignRight, AlignRight, AlignRight, AlignRight, AlignRight)
+	return bulk
+}
+
 func sortMapStrInt(m map[string]int64) (ret [][]string) {
 	for k, v := range m {
 		ret = append(ret, []string{k, strconv.FormatInt(v, 10)})
@@ -463,13 +1667,18 @@ func (p *Printer) buildSummary(snapshot *SnapshotReport, isFinal bool) [][]strin
 
 	codes := sortMapStrInt(snapshot.Codes)
 	for _, v := range codes {
-		if v[0] != "2xx" {
+		if v[0] != "2xx" && (v[0] != "3xx" || snapshot.Redirects == nil || snapshot.Redirects.Failure) {
 			v[1] = colorize(v[1], FgMagentaColor)
 		}
 		summarybulk = append(summarybulk, []string{"  " + v[0], v[1]})
 	}
 	summarybulk = append(summarybulk,
 		[]string{"RPS", fmt.Sprintf("%.3f", snapshot.RPS)},
+	)
+	if c := snapshot.Connections; c != nil {
+		summarybulk = append(summarybulk, []string{"Conns/s", fmt.Sprintf("%.3f", c.Rate)})
+	}
+	summarybulk = append(summarybulk,
 		[]string{"Concurrency", fmt.Sprintf("%d", snapshot.concurrencyCount)},
 		[]string{"Reads", fmt.Sprintf("%.3fMB/s", snapshot.ReadThroughput)},
 		[]string{"Writes", fmt.Sprintf("%.3fMB/s", snapshot.WriteThroughput)},
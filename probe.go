@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"gopkg.in/alecthomas/kingpin.v3-unstable"
+)
+
+// probeSample is the utilization of the target host in the last second, as
+// streamed by `plow probe`
+type probeSample struct {
+	CPU     float64 `json:"cpu"`     // % of all the cores
+	Mem     float64 `json:"mem"`     // % used
+	MemUsed float64 `json:"memUsed"` // MB
+	Load    float64 `json:"load"`    // 1 minute load average
+	NetRx   float64 `json:"netRx"`   // MB/s
+	NetTx   float64 `json:"netTx"`   // MB/s
+}
+
+// hostCounters are the cumulative counters of the host, sampled by the
+// platform
+type hostCounters struct {
+	cpuBusy, cpuTotal  uint64 // ticks
+	memTotal, memAvail uint64 // bytes
+	netRx, netTx       uint64 // bytes, but the loopback
+	load               float64
+}
+
+// hostSampler turns the counters of the host into the samples of each second
+type hostSampler struct {
+	token string // of --token, "" for none
+
+	mu   sync.Mutex
+	prev hostCounters
+	at   time.Time
+	last probeSample
+}
+
+func (h *hostSampler) run() {
+	h.prev, _ = readHostCounters()
+	h.at = time.Now()
+	for range time.Tick(time.Second) {
+		c, err := readHostCounters()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "plow probe: %s\n", err)
+			continue
+		}
+		now := time.Now()
+		secs := now.Sub(h.at).Seconds()
+		s := probeSample{Load: c.load}
+		if dt := c.cpuTotal - h.prev.cpuTotal; dt > 0 {
+			s.CPU = roundFloat(float64(c.cpuBusy-h.prev.cpuBusy)*100/float64(dt), 1)
+		}
+		if c.memTotal > 0 {
+			used := c.memTotal - c.memAvail
+			s.Mem = roundFloat(float64(used)*100/float64(c.memTotal), 1)
+			s.MemUsed = roundFloat(float64(used)/1024/1024, 1)
+		}
+		if secs > 0 {
+			s.NetRx = roundFloat(float64(c.netRx-h.prev.netRx)/1024/1024/secs, 3)
+			s.NetTx = roundFloat(float64(c.netTx-h.prev.netTx)/1024/1024/secs, 3)
+		}
+		h.mu.Lock()
+		h.prev, h.at, h.last = c, now, s
+		h.mu.Unlock()
+	}
+}
+
+func (h *hostSampler) sample() probeSample {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.last
+}
+
+// handler streams a sample a second as a JSON line, on GET /stream, until the
+// controller goes away
+func (h *hostSampler) handler(ctx *fasthttp.RequestCtx) {
+	if !agentAuthorized(ctx, h.token) {
+		ctx.Error("the --token of the probe is required", fasthttp.StatusUnauthorized)
+		return
+	}
+	if string(ctx.Path()) != "/stream" {
+		ctx.Error("not found", fasthttp.StatusNotFound)
+		return
+	}
+	ctx.SetContentType("application/x-ndjson")
+	ctx.SetBodyStreamWriter(func(w *bufio.Writer) {
+		enc := json.NewEncoder(w)
+		for {
+			if err := enc.Encode(h.sample()); err != nil {
+				return
+			}
+			if err := w.Flush(); err != nil {
+				return
+			}
+			time.Sleep(time.Second)
+		}
+	})
+}
+
+func runProbeCommand(args []string) {
+	app := kingpin.New("plow probe", "Stream the CPU, memory and network of this host to a plow controller (plow --probe host:port ...)")
+	listen := app.Flag("listen", "Listen addr for the controller, e.g. :19998 for all the interfaces").Default("127.0.0.1:19998").String()
+	token := app.Flag("token", "Require this bearer token of the --agent-token of the controller").Envar("PLOW_AGENT_TOKEN").PlaceHolder("TOKEN").String()
+	tlsCert := app.Flag("tls-cert", "Serve the controller over TLS with this certificate, along --tls-key").PlaceHolder("FILE").ExistingFile()
+	tlsKey := app.Flag("tls-key", "Private key of --tls-cert").PlaceHolder("FILE").ExistingFile()
+	clientCA := app.Flag("tls-client-ca", "Require a client certificate of this CA from the controller, its --agent-cert").PlaceHolder("FILE").ExistingFile()
+	app.Version(version)
+	kingpin.MustParse(app.Parse(args))
+
+	if _, err := readHostCounters(); err != nil {
+		errAndExit(err.Error())
+		return
+	}
+	ln, err := agentListen(*listen, *tlsCert, *tlsKey, *clientCA)
+	if err != nil {
+		errAndExit(err.Error())
+		return
+	}
+	fmt.Fprintf(os.Stderr, "plow probe is listening on %s\n", ln.Addr())
+	agentWarnExposed("probe", ln, *token, *tlsCert)
+	h := &hostSampler{token: *token}
+	go h.run()
+	server := fasthttp.Server{Handler: h.handler}
+	if err := server.Serve(ln); err != nil {
+		errAndExit(err.Error())
+	}
+}
+
+// serverProbe follows the samples streamed by a `plow probe` during a run,
+// connecting again when the stream breaks
+type serverProbe struct {
+	addr      string
+	transport *agentTransport
+
+	mu   sync.Mutex
+	last *probeSample // nil while disconnected
+	cpu  Stats
+	mem  Stats
+}
+
+func newServerProbe(addr string, transport *agentTransport) *serverProbe {
+	return &serverProbe{addr: addr, transport: transport}
+}
+
+// follow reads the stream of the probe until done is closed
+func (p *serverProbe) follow(done <-chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-done
+		cancel()
+	}()
+	// the same error is logged once, as the probe is retried each second
+	var lastErr string
+	for ctx.Err() == nil {
+		if err := p.read(ctx); err != nil && ctx.Err() == nil && err.Error() != lastErr {
+			fmt.Fprintf(os.Stderr, "plow: probe %s: %s\n", p.addr, err)
+			lastErr = err.Error()
+		}
+		p.mu.Lock()
+		p.last = nil
+		p.mu.Unlock()
+		select {
+		case <-ctx.Done():
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+func (p *serverProbe) read(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.transport.url(p.addr, "/stream"), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := p.transport.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s", resp.Status)
+	}
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		var s probeSample
+		if err := json.Unmarshal(scanner.Bytes(), &s); err != nil {
+			return err
+		}
+		p.mu.Lock()
+		p.last = &s
+		p.cpu.Update(s.CPU)
+		p.mem.Update(s.Mem)
+		p.mu.Unlock()
+	}
+	return scanner.Err()
+}
+
+func (p *serverProbe) sample() *probeSample {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.last
+}
+
+// report is the utilization of the target host over the run, nil without
+// samples
+func (p *serverProbe) report() *ServerReport {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.cpu.count == 0 {
+		return nil
+	}
+	return &ServerReport{
+		Host:    p.addr,
+		MeanCPU: roundFloat(p.cpu.Mean(), 1),
+		PeakCPU: roundFloat(p.cpu.max, 1),
+		MeanMem: roundFloat(p.mem.Mean(), 1),
+		PeakMem: roundFloat(p.mem.max, 1),
+	}
+}
+
+// ServerReport is the utilization of the host of a --probe over the run, in %
+type ServerReport struct {
+	Host    string
+	MeanCPU float64
+	PeakCPU float64
+	MeanMem float64
+	PeakMem float64
+}
+
+// server returns the CPU, memory, load and network of the target host in the
+// last second, or nils without data
+func (cr *ChartsReport) server() []interface{} {
+	if cr == nil || cr.Server == nil {
+		return []interface{}{nil, nil, nil, nil, nil}
+	}
+	s := cr.Server
+	return []interface{}{s.CPU, s.Mem, s.Load, s.NetRx, s.NetTx}
+}
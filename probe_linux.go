@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// readHostCounters reads the counters of the host from /proc
+func readHostCounters() (hostCounters, error) {
+	var c hostCounters
+	stat, err := os.ReadFile("/proc/stat")
+	if err != nil {
+		return c, err
+	}
+	// cpu  user nice system idle iowait irq softirq steal ...
+	line, _, _ := strings.Cut(string(stat), "\n")
+	fields := strings.Fields(line)
+	if len(fields) < 5 || fields[0] != "cpu" {
+		return c, fmt.Errorf("unexpected /proc/stat: %q", line)
+	}
+	for i, f := range fields[1:] {
+		if i >= 8 {
+			// guest times are counted in user already
+			break
+		}
+		n, _ := strconv.ParseUint(f, 10, 64)
+		c.cpuTotal += n
+		if i != 3 && i != 4 {
+			c.cpuBusy += n
+		}
+	}
+
+	mem, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return c, err
+	}
+	defer mem.Close()
+	scanner := bufio.NewScanner(mem)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		kb, _ := strconv.ParseUint(fields[1], 10, 64)
+		switch fields[0] {
+		case "MemTotal:":
+			c.memTotal = kb * 1024
+		case "MemAvailable:":
+			c.memAvail = kb * 1024
+		}
+	}
+
+	dev, err := os.ReadFile("/proc/net/dev")
+	if err != nil {
+		return c, err
+	}
+	for _, line := range strings.Split(string(dev), "\n") {
+		iface, counters, ok := strings.Cut(line, ":")
+		if !ok || strings.TrimSpace(iface) == "lo" {
+			continue
+		}
+		// rx bytes packets errs drop fifo frame compressed multicast, tx bytes ...
+		fields := strings.Fields(counters)
+		if len(fields) < 9 {
+			continue
+		}
+		rx, _ := strconv.ParseUint(fields[0], 10, 64)
+		tx, _ := strconv.ParseUint(fields[8], 10, 64)
+		c.netRx += rx
+		c.netTx += tx
+	}
+
+	if load, err := os.ReadFile("/proc/loadavg"); err == nil {
+		if fields := strings.Fields(string(load)); len(fields) > 0 {
+			c.load, _ = strconv.ParseFloat(fields[0], 64)
+		}
+	}
+	return c, nil
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package main
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func readHostCounters() (hostCounters, error) {
+	return hostCounters{}, fmt.Errorf("plow probe is not supported on %s", runtime.GOOS)
+}
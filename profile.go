@@ -0,0 +1,276 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/alecthomas/kingpin.v3-unstable"
+)
+
+// profilePoint is a request rate of the recorded traffic at a point in time
+type profilePoint struct {
+	at  time.Time
+	rps float64
+}
+
+// profileStage is a --stage of the generated scenario
+type profileStage struct {
+	duration time.Duration
+	rps      float64
+}
+
+// parseProfile reads the points of a Prometheus query_range result, the
+// series being summed, or of a CSV of time and rate columns, oldest first
+func parseProfile(data []byte) ([]profilePoint, error) {
+	var points []profilePoint
+	var err error
+	if d := bytes.TrimSpace(data); len(d) > 0 && d[0] == '{' {
+		points, err = parsePromProfile(d)
+	} else {
+		points, err = parseCSVProfile(data)
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].at.Before(points[j].at) })
+	return points, err
+}
+
+func parsePromProfile(data []byte) ([]profilePoint, error) {
+	var res struct {
+		Status string
+		Error  string
+		Data   struct {
+			ResultType string
+			Result     []struct {
+				Values [][2]interface{}
+			}
+		}
+	}
+	if err := json.Unmarshal(data, &res); err != nil {
+		return nil, fmt.Errorf("prometheus result: %s", err)
+	}
+	if res.Status != "" && res.Status != "success" {
+		return nil, fmt.Errorf("prometheus result: %s %s", res.Status, res.Error)
+	}
+	if res.Data.ResultType != "matrix" {
+		return nil, fmt.Errorf("prometheus result: a range query (matrix) is needed, got %q", res.Data.ResultType)
+	}
+	sums := make(map[float64]float64)
+	for _, series := range res.Data.Result {
+		for _, v := range series.Values {
+			ts, ok := v[0].(float64)
+			s, ok2 := v[1].(string)
+			if !ok || !ok2 {
+				return nil, fmt.Errorf("prometheus result: bad sample %v", v)
+			}
+			val, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return nil, fmt.Errorf("prometheus result: bad sample value %q", s)
+			}
+			if !math.IsNaN(val) && !math.IsInf(val, 0) {
+				sums[ts] += val
+			}
+		}
+	}
+	points := make([]profilePoint, 0, len(sums))
+	for ts, val := range sums {
+		sec, frac := math.Modf(ts)
+		points = append(points, profilePoint{at: time.Unix(int64(sec), int64(frac*1e9)), rps: val})
+	}
+	return points, nil
+}
+
+// profileTimeLayouts are the time formats of the CSV rows besides unix
+// timestamps, the last one being the one of Grafana exports
+var profileTimeLayouts = []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02 15:04"}
+
+func parseProfileTime(s string) (time.Time, bool) {
+	if ts, err := strconv.ParseFloat(s, 64); err == nil {
+		if ts > 1e11 {
+			// milliseconds
+			ts /= 1000
+		}
+		sec, frac := math.Modf(ts)
+		return time.Unix(int64(sec), int64(frac*1e9)), true
+	}
+	for _, layout := range profileTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// parseCSVProfile reads rows of a time and one or more rates, summed, a
+// header row being skipped
+func parseCSVProfile(data []byte) ([]profilePoint, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	r.Comment = '#'
+	var points []profilePoint
+	for line := 1; ; line++ {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("csv: %s", err)
+		}
+		if len(row) < 2 {
+			return nil, fmt.Errorf("csv: line %d: a time and a rate column are needed", line)
+		}
+		at, ok := parseProfileTime(strings.TrimSpace(row[0]))
+		if !ok {
+			if line == 1 {
+				continue
+			}
+			return nil, fmt.Errorf("csv: line %d: bad time %q", line, row[0])
+		}
+		p := profilePoint{at: at}
+		for _, col := range row[1:] {
+			col = strings.TrimSpace(col)
+			if col == "" || col == "null" || col == "NaN" {
+				continue
+			}
+			val, err := strconv.ParseFloat(col, 64)
+			if err != nil {
+				return nil, fmt.Errorf("csv: line %d: bad rate %q", line, col)
+			}
+			p.rps += val
+		}
+		points = append(points, p)
+	}
+	return points, nil
+}
+
+// profileStages averages the sorted points over each step of the recording, and
+// maps the steps onto a replay of the given duration, merging the adjacent
+// steps of the same rate
+func profileStages(points []profilePoint, step, replay time.Duration, scale float64) []profileStage {
+	start := points[0].at
+	span := points[len(points)-1].at.Sub(start)
+	n := int(span/step) + 1
+	sums, counts := make([]float64, n), make([]int, n)
+	for _, p := range points {
+		i := int(p.at.Sub(start) / step)
+		sums[i] += p.rps
+		counts[i]++
+	}
+	// the end of step i in the replay, rounded to the second
+	stepEnd := func(i int) time.Duration {
+		return (replay * time.Duration(i+1) / time.Duration(n)).Round(time.Second)
+	}
+	var stages []profileStage
+	last := 0.0
+	for i := range sums {
+		// steps without points keep the rate of the previous one
+		rps := last
+		if counts[i] > 0 {
+			rps = sums[i] / float64(counts[i]) * scale
+		}
+		last = rps
+		d := stepEnd(i)
+		if i > 0 {
+			d -= stepEnd(i - 1)
+		}
+		if d <= 0 {
+			// replayed in less than a second
+			continue
+		}
+		if k := len(stages) - 1; k >= 0 && profileRate(stages[k].rps) == profileRate(rps) {
+			stages[k].duration += d
+			continue
+		}
+		stages = append(stages, profileStage{duration: d, rps: rps})
+	}
+	return stages
+}
+
+// profileRate is the rate of a --stage. A rate of 0 being unlimited in plow,
+// and the requests waiting for a slow rate only seeing the next stage once
+// sent, the rates under 1/s are raised to it.
+func profileRate(rps float64) string {
+	return strconv.Itoa(int(math.Max(1, math.Round(rps))))
+}
+
+func runProfileCommand(args []string) {
+	app := kingpin.New("plow profile", "Convert the request rate of recorded traffic, a Prometheus range query result or a CSV of time and rate, into --stage flags replaying its shape")
+	file := app.Arg("file", "Prometheus query_range JSON or CSV file, read from stdin without").String()
+	step := app.Flag("step", "Width of the recording averaged into one stage").Default("15m").Duration()
+	replay := app.Flag("duration", "Duration of the replay, the recording is replayed at its own pace without").Duration()
+	scale := app.Flag("scale", "Factor applied to the recorded rates").Default("1").Float64()
+	peak := app.Flag("peak", "Scale the recorded rates so the busiest stage runs at this rate, instead of --scale").PlaceHolder("RPS").Float64()
+	app.Version(version)
+	kingpin.MustParse(app.Parse(args))
+
+	if *step <= 0 || *replay < 0 || *scale <= 0 || *peak < 0 {
+		errAndExit("--step, --duration, --scale and --peak must be positive")
+		return
+	}
+	var data []byte
+	var err error
+	if *file == "" {
+		*file = "stdin"
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(*file)
+	}
+	if err != nil {
+		errAndExit(err.Error())
+		return
+	}
+	points, err := parseProfile(data)
+	if err != nil {
+		errAndExit(err.Error())
+		return
+	}
+	if len(points) == 0 {
+		errAndExit("no data points in " + *file)
+		return
+	}
+	start, end := points[0].at, points[len(points)-1].at
+	span := end.Sub(start).Truncate(*step) + *step
+	if *replay == 0 {
+		*replay = span
+	}
+	factor := *scale
+	if *peak > 0 {
+		stages := profileStages(points, *step, *replay, 1)
+		max := 0.0
+		for _, st := range stages {
+			max = math.Max(max, st.rps)
+		}
+		if max == 0 {
+			errAndExit("the recorded rate is always 0, --peak can't scale it")
+			return
+		}
+		factor = *peak / max
+	}
+	stages := profileStages(points, *step, *replay, factor)
+
+	fmt.Fprintf(os.Stderr, "# %d points from %s to %s, %s steps, rates x%s, replayed in %s\n",
+		len(points), start.Format(time.RFC3339), end.Format(time.RFC3339), *step,
+		strconv.FormatFloat(roundFloat(factor, 4), 'f', -1, 64), stagesTotal(stages))
+	for i, st := range stages {
+		sep := " \\"
+		if i == len(stages)-1 {
+			sep = ""
+		}
+		fmt.Printf("  --stage %s,rate=%s%s\n", st.duration, profileRate(st.rps), sep)
+	}
+}
+
+func stagesTotal(stages []profileStage) time.Duration {
+	var d time.Duration
+	for _, st := range stages {
+		d += st.duration
+	}
+	return d
+}
@@ -0,0 +1,58 @@
+package main
+
+import "github.com/valyala/fasthttp"
+
+// protocol sends the requests of the urls other than HTTP ones: tcp://,
+// --dns-query, mqtt://, redis://, kafka://, the databases and --backend
+type protocol interface {
+	// setup prepares the run once the targets are known, e.g. connecting
+	// ahead of it, an error aborting it
+	setup(r *Requester) error
+	// conns are the connections of a worker
+	conns(r *Requester, worker int) protocolConns
+	// teardown ends the run once the workers are done
+	teardown()
+}
+
+// protocolConns send the requests of a worker
+type protocolConns interface {
+	// build prepares the next request, out of its latency
+	build() error
+	// do sends the request of x, filling rr
+	do(x *exchange, rr *ReportRecord)
+	// close closes the connections at the end of the worker
+	close()
+}
+
+// exchange is a request of a worker to t, with the client and tracker of
+// its slot ci, the ones the protocols dial through
+type exchange struct {
+	ci     int
+	t      *target
+	client *fasthttp.HostClient
+	pt     *phaseTracker
+	req    *fasthttp.Request
+	resp   *fasthttp.Response
+}
+
+// protocol is the protocol of the urls, nil for HTTP ones, main allowing
+// one at most
+func (opt *ClientOpt) protocol() protocol {
+	switch {
+	case opt.plugged != nil:
+		return opt.plugged
+	case opt.tcp != nil:
+		return opt.tcp
+	case opt.dnsQuery != nil:
+		return opt.dnsQuery
+	case opt.mqtt != nil:
+		return opt.mqtt
+	case opt.redis != nil:
+		return opt.redis
+	case opt.kafka != nil:
+		return opt.kafka
+	case opt.sql != nil:
+		return opt.sql
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"net"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/valyala/fasthttp"
+)
+
+var proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// proxySource is a spoofed client address announced in the PROXY header,
+// either a single ip (with optional port) or every host of a CIDR block in turn.
+type proxySource struct {
+	ip   net.IP
+	port int // 0 keeps the real local port
+	net  *net.IPNet
+	size *big.Int
+}
+
+func parseProxySource(s string) (*proxySource, error) {
+	if ip, ipNet, err := net.ParseCIDR(s); err == nil {
+		ones, bits := ipNet.Mask.Size()
+		return &proxySource{ip: ip.Mask(ipNet.Mask), net: ipNet, size: new(big.Int).Lsh(big.NewInt(1), uint(bits-ones))}, nil
+	}
+	if ip := net.ParseIP(s); ip != nil {
+		return &proxySource{ip: ip}, nil
+	}
+	h, p, err := net.SplitHostPort(s)
+	if err == nil {
+		ip := net.ParseIP(h)
+		port, perr := strconv.Atoi(p)
+		if ip != nil && perr == nil && port > 0 && port < 65536 {
+			return &proxySource{ip: ip, port: port}, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid proxy source %q, must be IP, IP:PORT or CIDR", s)
+}
+
+func (s *proxySource) String() string {
+	switch {
+	case s.net != nil:
+		return s.net.String()
+	case s.port > 0:
+		return net.JoinHostPort(s.ip.String(), strconv.Itoa(s.port))
+	}
+	return s.ip.String()
+}
+
+// addr returns the n-th address of the source
+func (s *proxySource) addr(n uint64) net.IP {
+	if s.net == nil {
+		return s.ip
+	}
+	off := new(big.Int).SetUint64(n)
+	if s.size.Cmp(big.NewInt(2)) > 0 {
+		// skip the network and broadcast addresses
+		off.Mod(off, new(big.Int).Sub(s.size, big.NewInt(2)))
+		off.Add(off, big.NewInt(1))
+	} else {
+		off.Mod(off, s.size)
+	}
+	base := s.ip.To4()
+	if base == nil {
+		base = s.ip.To16()
+	}
+	v := new(big.Int).Add(new(big.Int).SetBytes(base), off)
+	ip := make(net.IP, len(base))
+	v.FillBytes(ip)
+	return ip
+}
+
+// proxyHeader builds a PROXY protocol header of version 1 or 2 for a connection
+// from src to dst; non-TCP connections get an UNKNOWN/LOCAL header.
+func proxyHeader(version int, src, dst *net.TCPAddr) []byte {
+	var buf bytes.Buffer
+	if version == 1 {
+		if src == nil || dst == nil {
+			return []byte("PROXY UNKNOWN\r\n")
+		}
+		if src.IP.To4() != nil && dst.IP.To4() != nil {
+			fmt.Fprintf(&buf, "PROXY TCP4 %s %s %d %d\r\n", src.IP.To4(), dst.IP.To4(), src.Port, dst.Port)
+		} else {
+			fmt.Fprintf(&buf, "PROXY TCP6 %s %s %d %d\r\n", formatIPv6(src.IP), formatIPv6(dst.IP), src.Port, dst.Port)
+		}
+		return buf.Bytes()
+	}
+
+	buf.Write(proxyV2Signature)
+	if src == nil || dst == nil {
+		// LOCAL command, no addresses
+		buf.Write([]byte{0x20, 0x00, 0x00, 0x00})
+		return buf.Bytes()
+	}
+	ports := make([]byte, 4)
+	binary.BigEndian.PutUint16(ports, uint16(src.Port))
+	binary.BigEndian.PutUint16(ports[2:], uint16(dst.Port))
+	if src.IP.To4() != nil && dst.IP.To4() != nil {
+		buf.Write([]byte{0x21, 0x11, 0x00, 12})
+		buf.Write(src.IP.To4())
+		buf.Write(dst.IP.To4())
+	} else {
+		buf.Write([]byte{0x21, 0x21, 0x00, 36})
+		buf.Write(src.IP.To16())
+		buf.Write(dst.IP.To16())
+	}
+	buf.Write(ports)
+	return buf.Bytes()
+}
+
+// formatIPv6 always prints the IPv6 form, i.e. ::ffff:1.2.3.4 for IPv4 addresses
+func formatIPv6(ip net.IP) string {
+	if ip4 := ip.To4(); ip4 != nil {
+		return "::ffff:" + ip4.String()
+	}
+	return ip.String()
+}
+
+// ProxyProtocolDial writes a PROXY protocol header on each new connection,
+// before any TLS handshake, announcing the sources in turn as the client address.
+func ProxyProtocolDial(dial fasthttp.DialFunc, version int, sources []*proxySource) fasthttp.DialFunc {
+	var seq uint64
+	return func(addr string) (net.Conn, error) {
+		conn, err := dial(addr)
+		if err != nil {
+			return nil, err
+		}
+		src, _ := conn.LocalAddr().(*net.TCPAddr)
+		dst, _ := conn.RemoteAddr().(*net.TCPAddr)
+		if src != nil && len(sources) > 0 {
+			n := atomic.AddUint64(&seq, 1) - 1
+			s := sources[n%uint64(len(sources))]
+			spoofed := &net.TCPAddr{IP: s.addr(n / uint64(len(sources))), Port: src.Port}
+			if s.port > 0 {
+				spoofed.Port = s.port
+			}
+			src = spoofed
+		}
+		if _, err = conn.Write(proxyHeader(version, src, dst)); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return conn, nil
+	}
+}
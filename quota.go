@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"golang.org/x/time/rate"
+)
+
+// guiQuota bounds each run started from the web UI with a token, so that a
+// shared GUI server can't be turned against the staging it benchmarks
+type guiQuota struct {
+	concurrency int
+	duration    time.Duration
+	rate        rate.Limit // requests per second, 0 for unbounded
+	rateStr     string
+}
+
+// guiQuotas are the quotas by token, "" being the one of the requests
+// without a token of their own. With quotas but no default one, the requests
+// without a known token are refused.
+type guiQuotas map[string]*guiQuota
+
+// parseGUIQuota parses `[TOKEN:]c=N,d=DURATION,rate=RATE`, such as
+// `ci-bot:c=200,d=30m,rate=1000/s`
+func parseGUIQuota(spec string) (string, *guiQuota, error) {
+	token, limits := "", spec
+	if i := strings.Index(spec, ":"); i >= 0 && !strings.Contains(spec[:i], "=") {
+		token, limits = spec[:i], spec[i+1:]
+		if token == "" {
+			return "", nil, fmt.Errorf("quota %q has an empty token", spec)
+		}
+	}
+	q := &guiQuota{}
+	for _, p := range strings.Split(limits, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(p), "=")
+		if !ok {
+			return "", nil, fmt.Errorf("quota %q: %q is not a LIMIT=VALUE (i.e. c=100,d=5m,rate=500)", spec, p)
+		}
+		switch k {
+		case "c", "concurrency":
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				return "", nil, fmt.Errorf("quota %q: concurrency %q must be a positive number", spec, v)
+			}
+			q.concurrency = n
+		case "d", "duration":
+			d, err := time.ParseDuration(v)
+			if err != nil || d < time.Second {
+				return "", nil, fmt.Errorf("quota %q: duration %q must be at least 1s", spec, v)
+			}
+			q.duration = d
+		case "rate":
+			rv := &rateFlagValue{}
+			if err := rv.Set(v); err != nil {
+				return "", nil, fmt.Errorf("quota %q: %s", spec, err)
+			}
+			if l := rv.Limit(); l != nil {
+				q.rate, q.rateStr = *l, v
+			}
+		default:
+			return "", nil, fmt.Errorf("quota %q: unknown limit %q, expecting c, d or rate", spec, k)
+		}
+	}
+	return token, q, nil
+}
+
+func parseGUIQuotas(specs []string) (guiQuotas, error) {
+	res := make(guiQuotas)
+	for _, s := range specs {
+		token, q, err := parseGUIQuota(s)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := res[token]; ok {
+			if token == "" {
+				return nil, fmt.Errorf("quota %q: the default quota is already set", s)
+			}
+			return nil, fmt.Errorf("quota %q: token %q already has a quota", s, token)
+		}
+		res[token] = q
+	}
+	return res, nil
+}
+
+// allowsReload tells whether token may reload the settings of the server:
+// any when no quota has a token, else only those with a quota
+func (qs guiQuotas) allowsReload(token string) bool {
+	for t := range qs {
+		if t != "" {
+			_, ok := qs[token]
+			return ok && token != ""
+		}
+	}
+	return true
+}
+
+// requestToken is the bearer token of the Authorization header of ctx
+func requestToken(ctx *fasthttp.RequestCtx) string {
+	auth := string(ctx.Request.Header.Peek("Authorization"))
+	if token, ok := strings.CutPrefix(auth, "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+	// the downloads and pages the GUI page opens carry it as ?token=
+	return strings.TrimSpace(string(ctx.QueryArgs().Peek("token")))
+}
+
+// apply gives req the defaults of the quota of token, and returns the HTTP
+// status and the reason req is refused for, 0 when within it
+func (qs guiQuotas) apply(token string, req *BenchmarkRequest) (int, error) {
+	q, status, err := qs.of(token)
+	if q == nil {
+		return status, err
+	}
+	// the defaults of the form fit in the quota, and a run bounded by its
+	// requests only by the duration of the quota too
+	if req.Duration <= 0 && q.duration > 0 && (q.duration < 10*time.Second || req.Requests > 0) {
+		req.Duration = int(q.duration / time.Second)
+	}
+	if req.Rate <= 0 && q.rate > 0 {
+		req.Rate = float64(q.rate)
+	}
+	switch {
+	case q.concurrency > 0 && req.Concurrency > q.concurrency:
+		return fasthttp.StatusForbidden, fmt.Errorf("concurrency %d is over the quota of %d", req.Concurrency, q.concurrency)
+	case q.duration > 0 && time.Duration(req.Duration)*time.Second > q.duration:
+		return fasthttp.StatusForbidden, fmt.Errorf("duration %ds is over the quota of %s", req.Duration, q.duration)
+	case q.rate > 0 && req.Rate > float64(q.rate):
+		return fasthttp.StatusForbidden, fmt.Errorf("rate %g/s is over the quota of %s", req.Rate, q.rateStr)
+	}
+	return 0, nil
+}
+
+// of returns the quota of token, nil without quotas, and the HTTP status and
+// the reason token is refused for
+func (qs guiQuotas) of(token string) (*guiQuota, int, error) {
+	if len(qs) == 0 {
+		return nil, 0, nil
+	}
+	q, ok := qs[token]
+	if !ok {
+		if q, ok = qs[""]; !ok {
+			if token == "" {
+				return nil, fasthttp.StatusUnauthorized, fmt.Errorf("a token is required to start a benchmark")
+			}
+			return nil, fasthttp.StatusForbidden, fmt.Errorf("unknown token")
+		}
+	}
+	return q, 0, nil
+}
+
+// adjust returns the HTTP status and the reason the concurrency of a run
+// can't be set to n by token for, 0 when within its quota
+func (qs guiQuotas) adjust(token string, n int) (int, error) {
+	q, status, err := qs.of(token)
+	if q != nil && q.concurrency > 0 && n > q.concurrency {
+		return fasthttp.StatusForbidden, fmt.Errorf("concurrency %d is over the quota of %d", n, q.concurrency)
+	}
+	return status, err
+}
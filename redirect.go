@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	url2 "net/url"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// RedirectReport counts the redirects followed with --follow-redirects, and
+// whether the 3xx responses count as failures with --redirect-status
+type RedirectReport struct {
+	Followed   int64 // hops
+	Redirected int64 // requests redirected at least once
+	Failure    bool
+}
+
+// isFailure tells whether rr counts as an error of its target, i.e. with an
+// error or a 5xx, or a 3xx when redirects are failures
+func isFailure(rr *ReportRecord, redirectFailure bool) bool {
+	return rr.error != "" || rr.code >= 500 || redirectFailure && rr.code/100 == 3
+}
+
+// expandFollowRedirects turns --follow-redirects=N into --follow-redirects
+// --max-redirects=N, kingpin not taking a value for a bool flag
+func expandFollowRedirects(args []string) []string {
+	res := make([]string, 0, len(args)+1)
+	for i, arg := range args {
+		if arg == "--" {
+			return append(res, args[i:]...)
+		}
+		if n, ok := strings.CutPrefix(arg, "--follow-redirects="); ok {
+			res = append(res, "--follow-redirects", "--max-redirects="+n)
+			continue
+		}
+		res = append(res, arg)
+	}
+	return res
+}
+
+// redirectFollower follows the redirects of the responses of a worker, the
+// hops to the host of the request going through its connection, and the
+// ones to another host through a connection of their own
+type redirectFollower struct {
+	r       *Requester
+	clients map[string]*fasthttp.HostClient // by proxy and origin
+	hop     *fasthttp.Request
+}
+
+func newRedirectFollower(r *Requester) *redirectFollower {
+	return &redirectFollower{r: r, clients: make(map[string]*fasthttp.HostClient), hop: &fasthttp.Request{}}
+}
+
+// isRedirect tells whether resp sends the request elsewhere
+func isRedirect(resp *fasthttp.Response) bool {
+	switch resp.StatusCode() {
+	case fasthttp.StatusMovedPermanently, fasthttp.StatusFound, fasthttp.StatusSeeOther,
+		fasthttp.StatusTemporaryRedirect, fasthttp.StatusPermanentRedirect:
+		return len(resp.Header.Peek("Location")) > 0
+	}
+	return false
+}
+
+// follow sends the hops of the redirect in resp, up to --max-redirects, the
+// latency of rr adding up theirs and its code being the one of the last
+func (f *redirectFollower) follow(client *fasthttp.HostClient, req *fasthttp.Request, resp *fasthttp.Response, rr *ReportRecord, jar *cookieJar) {
+	max := f.r.clientOpt.followRedirects
+	origin := req.URI()
+	scheme, host := string(origin.Scheme()), string(origin.Host())
+	base, err := url2.Parse(origin.String())
+	if err != nil {
+		return
+	}
+	hop := f.hop
+	req.CopyTo(hop)
+	for rr.error == "" && isRedirect(resp) {
+		if rr.redirects == max {
+			rr.code = 0
+			rr.error = fmt.Sprintf("stopped after %d redirects", max)
+			rr.errClass = errOther
+			return
+		}
+		loc, err := base.Parse(string(resp.Header.Peek("Location")))
+		if err != nil || (loc.Scheme != "http" && loc.Scheme != "https") {
+			rr.code = 0
+			rr.error = fmt.Sprintf("bad redirect location %q", resp.Header.Peek("Location"))
+			rr.errClass = errOther
+			return
+		}
+		switch code := resp.StatusCode(); {
+		case code == fasthttp.StatusSeeOther && !hop.Header.IsHead(),
+			(code == fasthttp.StatusMovedPermanently || code == fasthttp.StatusFound) && hop.Header.IsPost():
+			hop.Header.SetMethod(fasthttp.MethodGet)
+			hop.ResetBody()
+			hop.Header.Del("Content-Type")
+		}
+		hop.SetRequestURI(loc.String())
+		hop.Header.SetHost(loc.Host)
+		c := client
+		if loc.Scheme != scheme || loc.Host != host {
+			// the credentials of the origin aren't sent elsewhere, like curl
+			hop.Header.Del("Authorization")
+			hop.Header.Del("Cookie")
+			c = f.client(loc, rr.proxy)
+		}
+		if jar != nil {
+			jar.apply(hop)
+		}
+		resp.Reset()
+		start := time.Now()
+		err = doTimeout(c, hop, resp, f.r.clientOpt.doTimeout)
+		rr.cost += time.Since(start)
+		rr.redirects++
+		if err != nil {
+			rr.code = 0
+			rr.error = err.Error()
+			rr.errClass = classifyError(err)
+			return
+		}
+		rr.code = resp.StatusCode()
+		rr.respSize = int64(len(resp.Body()))
+		rr.validated, rr.malformed = validateResponse(f.r.clientOpt.validate, f.r.clientOpt.validateRate, hop, resp)
+		if jar != nil {
+			jar.update(hop, resp)
+		}
+		base = loc
+	}
+}
+
+// client returns the connection of the host of loc through proxy
+func (f *redirectFollower) client(loc *url2.URL, proxy int) *fasthttp.HostClient {
+	origin := loc.Scheme + "://" + loc.Host
+	key := fmt.Sprintf("%d %s", proxy, origin)
+	if c := f.clients[key]; c != nil {
+		return c
+	}
+	opt := *f.r.clientOpt
+	opt.maxConns = 1
+	opt.phases = nil
+	// --connect-to and --sni only apply to the target
+	opt.connectTo, opt.sni = "", ""
+	if proxy >= 0 {
+		opt.proxy = opt.proxies[proxy]
+	}
+	c, _, err := buildRequestClient(&opt, origin, &f.r.readBytes, &f.r.writeBytes)
+	if err != nil {
+		// the settings were already accepted for the targets
+		c = &fasthttp.HostClient{Addr: addMissingPort(loc.Host, loc.Scheme == "https"), IsTLS: loc.Scheme == "https"}
+	}
+	f.clients[key] = c
+	return c
+}
@@ -0,0 +1,360 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// maxRedisDepth bounds the nesting of the arrays of a reply
+const maxRedisDepth = 32
+
+// isRedisURL tells the redis:// and rediss:// urls of the servers
+func isRedisURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	return err == nil && (u.Scheme == "redis" || u.Scheme == "rediss")
+}
+
+// RedisReport counts the commands sent to the redis:// and rediss:// urls,
+// Nil being the nil replies, e.g. the misses of a GET, and Errors the error
+// replies
+type RedisReport struct {
+	Commands int64
+	Rate     float64 // commands per second
+	Nil      int64
+	Errors   int64
+}
+
+// redisStats are the counters of the commands of all the workers, a request
+// sending a pipeline of them
+type redisStats struct {
+	commands int64
+	nils     int64
+	errors   int64
+}
+
+func (s *redisStats) report(elapsed float64) *RedisReport {
+	rs := &RedisReport{
+		Commands: atomic.LoadInt64(&s.commands),
+		Nil:      atomic.LoadInt64(&s.nils),
+		Errors:   atomic.LoadInt64(&s.errors),
+	}
+	if elapsed > 0 {
+		rs.Rate = float64(rs.Commands) / elapsed
+	}
+	return rs
+}
+
+// redisMode sends the commands of --redis-command in turn, --redis-pipeline
+// of them per request
+type redisMode struct {
+	commands *templateCycle
+	pipeline int
+	stats    redisStats
+}
+
+func newRedisMode(commands []string, pipeline int) (*redisMode, error) {
+	if pipeline < 1 {
+		return nil, fmt.Errorf("--redis-pipeline must be at least 1")
+	}
+	for _, c := range commands {
+		if args, err := splitRedisArgs(c); err != nil || len(args) == 0 && !strings.Contains(c, "{{") {
+			return nil, fmt.Errorf("invalid --redis-command %q", c)
+		}
+	}
+	cycle, err := newTemplateCycle("--redis-command", commands)
+	if err != nil {
+		return nil, err
+	}
+	return &redisMode{commands: cycle, pipeline: pipeline}, nil
+}
+
+// splitRedisArgs splits a command into its arguments at the spaces, the
+// double-quoted ones being unquoted with their Go escapes
+func splitRedisArgs(s string) ([]string, error) {
+	var args []string
+	for {
+		s = strings.TrimLeft(s, " \t")
+		if s == "" {
+			return args, nil
+		}
+		if s[0] != '"' {
+			i := strings.IndexAny(s, " \t")
+			if i < 0 {
+				i = len(s)
+			}
+			args, s = append(args, s[:i]), s[i:]
+			continue
+		}
+		arg, err := strconv.QuotedPrefix(s)
+		if err != nil {
+			return nil, err
+		}
+		unquoted, _ := strconv.Unquote(arg)
+		args, s = append(args, unquoted), s[len(arg):]
+	}
+}
+
+// appendRedisCommand appends args as a RESP array of bulk strings
+func appendRedisCommand(b []byte, args []string) []byte {
+	b = append(b, '*')
+	b = strconv.AppendInt(b, int64(len(args)), 10)
+	b = append(b, '\r', '\n')
+	for _, a := range args {
+		b = append(b, '$')
+		b = strconv.AppendInt(b, int64(len(a)), 10)
+		b = append(b, '\r', '\n')
+		b = append(b, a...)
+		b = append(b, '\r', '\n')
+	}
+	return b
+}
+
+// redisConns are the connections of a worker to the servers of the
+// redis:// and rediss:// urls, by client, the -c workers being the pool of
+// connections
+type redisConns struct {
+	r     *Requester
+	mode  *redisMode
+	conns map[int]*tcpConn
+	out   []byte
+	tmpl  bytes.Buffer
+	read  int64 // bytes of the replies read
+}
+
+func newRedisConns(r *Requester) *redisConns {
+	return &redisConns{r: r, mode: r.clientOpt.redis, conns: make(map[int]*tcpConn)}
+}
+
+func (m *redisMode) setup(r *Requester) error { return nil }
+
+func (m *redisMode) conns(r *Requester, worker int) protocolConns { return newRedisConns(r) }
+
+func (m *redisMode) teardown() {}
+
+func (c *redisConns) do(x *exchange, rr *ReportRecord) {
+	c.roundTrip(x.ci, x.t, x.client, x.pt, rr)
+}
+
+// close closes the connections of the worker at its end
+func (c *redisConns) close() {
+	for ci, conn := range c.conns {
+		conn.Close()
+		delete(c.conns, ci)
+	}
+}
+
+// build packs the pipeline of the next commands, ahead of the request and
+// out of its latency
+func (c *redisConns) build() error {
+	c.out = c.out[:0]
+	for i := 0; i < c.mode.pipeline; i++ {
+		cmd, err := c.mode.commands.take(&c.tmpl)
+		if err != nil {
+			return err
+		}
+		args, err := splitRedisArgs(cmd)
+		if err != nil || len(args) == 0 {
+			return fmt.Errorf("redis: invalid command %q", cmd)
+		}
+		c.out = appendRedisCommand(c.out, args)
+	}
+	return nil
+}
+
+// roundTrip sends the pipeline built on the connection ci and reads its
+// replies, the latency of rr being the round trip, with the connect, AUTH
+// and SELECT of a new connection
+func (c *redisConns) roundTrip(ci int, t *target, client *fasthttp.HostClient, pt *phaseTracker, rr *ReportRecord) {
+	opt := c.r.clientOpt
+	rr.reset()
+
+	start := time.Now()
+	var replyErr string
+	var err error
+	// a connection the server closed while idle is dialed again once
+	for attempt := 0; attempt < 2; attempt++ {
+		pt.start()
+		start = time.Now()
+		c.read = 0
+		conn := c.conns[ci]
+		rr.cold = conn == nil
+		if conn == nil {
+			if conn, err = c.dial(t, client); err != nil {
+				break
+			}
+			c.conns[ci] = conn
+		}
+		c.deadlines(conn)
+		if replyErr, err = c.exchange(conn); err == nil || rr.cold || c.read > 0 {
+			break
+		}
+		conn.Close()
+		delete(c.conns, ci)
+		rr.stale = staleRetried
+	}
+	done := time.Now()
+	rr.cost = done.Sub(start)
+	rr.addr = pt.addr
+	rr.phases = pt.times(rr.cost, done)
+	rr.reqSize, rr.respSize = int64(len(c.out)), c.read
+	rr.error = ""
+	if err != nil {
+		rr.error = err.Error()
+		rr.errClass = classifyError(err)
+	} else if replyErr != "" {
+		rr.error = "redis: " + replyErr
+		rr.errClass = errOther
+	}
+	if conn := c.conns[ci]; conn != nil && (err != nil || opt.disableKeepalive) {
+		conn.Close()
+		delete(c.conns, ci)
+	}
+}
+
+// dial connects to the server of t, sending the AUTH of the user and
+// password of its url and the SELECT of its /DB path
+func (c *redisConns) dial(t *target, client *fasthttp.HostClient) (*tcpConn, error) {
+	nc, err := client.Dial(client.Addr)
+	if err != nil {
+		return nil, err
+	}
+	conn := &tcpConn{Conn: nc, br: bufio.NewReader(nc)}
+	c.deadlines(conn)
+	u, _ := url.Parse(t.url)
+	var setup []byte
+	n := 0
+	if u.User != nil {
+		args := []string{"AUTH", u.User.Username()}
+		if pass, ok := u.User.Password(); ok {
+			if u.User.Username() == "" {
+				args = args[:1]
+			}
+			args = append(args, pass)
+		}
+		setup, n = appendRedisCommand(setup, args), n+1
+	}
+	if db := strings.Trim(u.Path, "/"); db != "" && db != "0" {
+		setup, n = appendRedisCommand(setup, []string{"SELECT", db}), n+1
+	}
+	if n > 0 {
+		if _, err = conn.Write(setup); err == nil {
+			for i := 0; i < n && err == nil; i++ {
+				var msg string
+				if _, msg, err = c.reply(conn.br, 0); err == nil && msg != "" {
+					err = errors.New("redis: " + msg)
+				}
+			}
+		}
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	c.read = 0
+	return conn, nil
+}
+
+// deadlines sets the deadlines of a request on conn
+func (c *redisConns) deadlines(conn *tcpConn) {
+	opt := c.r.clientOpt
+	now := time.Now()
+	var deadline time.Time
+	if opt.doTimeout > 0 {
+		deadline = now.Add(opt.doTimeout)
+	}
+	if opt.writeTimeout > 0 {
+		_ = conn.SetWriteDeadline(now.Add(opt.writeTimeout))
+	} else {
+		_ = conn.SetWriteDeadline(deadline)
+	}
+	if opt.readTimeout > 0 {
+		_ = conn.SetReadDeadline(now.Add(opt.readTimeout))
+	} else {
+		_ = conn.SetReadDeadline(deadline)
+	}
+}
+
+// exchange writes the pipeline to conn and reads a reply per command,
+// returning the first error reply
+func (c *redisConns) exchange(conn *tcpConn) (string, error) {
+	if _, err := conn.Write(c.out); err != nil {
+		return "", err
+	}
+	stats := &c.mode.stats
+	first := ""
+	for i := 0; i < c.mode.pipeline; i++ {
+		isNil, msg, err := c.reply(conn.br, 0)
+		if err != nil {
+			return first, err
+		}
+		atomic.AddInt64(&stats.commands, 1)
+		if isNil {
+			atomic.AddInt64(&stats.nils, 1)
+		}
+		if msg != "" {
+			atomic.AddInt64(&stats.errors, 1)
+			if first == "" {
+				first = msg
+			}
+		}
+	}
+	return first, nil
+}
+
+// reply reads a RESP reply, telling a nil one and returning the message of
+// an error one
+func (c *redisConns) reply(br *bufio.Reader, depth int) (bool, string, error) {
+	line, err := br.ReadSlice('\n')
+	c.read += int64(len(line))
+	if err != nil {
+		return false, "", unexpectedEOF(err, int(c.read))
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return false, "", errors.New("redis: malformed reply")
+	}
+	body := string(line[1 : len(line)-2])
+	switch line[0] {
+	case '+', ':':
+		return false, "", nil
+	case '-':
+		return false, body, nil
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return false, "", errors.New("redis: malformed reply")
+		}
+		if n < 0 {
+			return true, "", nil
+		}
+		discarded, err := br.Discard(n + 2)
+		c.read += int64(discarded)
+		if err != nil {
+			return false, "", unexpectedEOF(err, int(c.read))
+		}
+		return false, "", nil
+	case '*':
+		n, err := strconv.Atoi(body)
+		if err != nil || depth >= maxRedisDepth {
+			return false, "", errors.New("redis: malformed reply")
+		}
+		if n < 0 {
+			return true, "", nil
+		}
+		for i := 0; i < n; i++ {
+			if _, _, err := c.reply(br, depth+1); err != nil {
+				return false, "", err
+			}
+		}
+		return false, "", nil
+	}
+	return false, "", fmt.Errorf("redis: unknown reply type %q", line[0])
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bufio"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAppendRedisCommand(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"PING"}, "*1\r\n$4\r\nPING\r\n"},
+		{[]string{"SET", "key", ""}, "*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$0\r\n\r\n"},
+		{[]string{"SET", "k", "a b\r\n"}, "*3\r\n$3\r\nSET\r\n$1\r\nk\r\n$5\r\na b\r\n\r\n"},
+		{[]string{"GET", "é"}, "*2\r\n$3\r\nGET\r\n$2\r\né\r\n"},
+	}
+	for _, tt := range tests {
+		if got := string(appendRedisCommand(nil, tt.args)); got != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestSplitRedisArgs(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"GET key", []string{"GET", "key"}},
+		{"  SET  key \t value ", []string{"SET", "key", "value"}},
+		{`SET key "a b\r\n"`, []string{"SET", "key", "a b\r\n"}},
+		{`SET key ""`, []string{"SET", "key", ""}},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		got, err := splitRedisArgs(tt.in)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: got %q, %v", tt.in, got, err)
+		}
+	}
+	if _, err := splitRedisArgs(`SET key "unterminated`); err == nil {
+		t.Error("unterminated quote: no error")
+	}
+}
+
+func TestRedisExchange(t *testing.T) {
+	replies := "+OK\r\n" +
+		"$-1\r\n" +
+		"-ERR wrong type\r\n" +
+		":42\r\n" +
+		"$5\r\nhello\r\n" +
+		"*3\r\n$1\r\na\r\n*-1\r\n*1\r\n:1\r\n" +
+		"-ERR other\r\n"
+	nc := newScriptedConn([]byte(replies))
+	c := &redisConns{mode: &redisMode{pipeline: 7}, out: appendRedisCommand(nil, []string{"PING"})}
+	msg, err := c.exchange(&tcpConn{Conn: nc, br: bufio.NewReader(nc)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg != "ERR wrong type" {
+		t.Errorf("first error reply %q", msg)
+	}
+	if got := nc.out.String(); got != "*1\r\n$4\r\nPING\r\n" {
+		t.Errorf("sent %q", got)
+	}
+	s := &c.mode.stats
+	if s.commands != 7 || s.nils != 1 || s.errors != 2 || c.read != int64(len(replies)) {
+		t.Errorf("%d commands, %d nils, %d errors, %d bytes read", s.commands, s.nils, s.errors, c.read)
+	}
+
+	for _, bad := range []string{"OK\r\n", "+OK\n", "$abc\r\n", "?1\r\n", "$5\r\nhel"} {
+		nc := newScriptedConn([]byte(bad))
+		c := &redisConns{mode: &redisMode{pipeline: 1}}
+		if _, err := c.exchange(&tcpConn{Conn: nc, br: bufio.NewReader(nc)}); err == nil {
+			t.Errorf("%q: no error", bad)
+		}
+	}
+	nested := strings.Repeat("*1\r\n", maxRedisDepth+1) + ":1\r\n"
+	nc = newScriptedConn([]byte(nested))
+	c = &redisConns{mode: &redisMode{pipeline: 1}}
+	if _, err := c.exchange(&tcpConn{Conn: nc, br: bufio.NewReader(nc)}); err == nil {
+		t.Error("too deep reply: no error")
+	}
+}
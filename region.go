@@ -0,0 +1,97 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	"github.com/beorn7/perks/quantile"
+)
+
+// agentRegion is a region label of --agent REGION=HOST:PORT, with the
+// addresses of its agents
+type agentRegion struct {
+	name   string
+	agents []string
+}
+
+// parseAgents splits the --agent specs into agent addresses and, when at
+// least one of them is labeled, the regions of the agents and the index of
+// the region of each agent. Unlabeled agents are a region of their own.
+func parseAgents(specs []string) (addrs []string, regions []*agentRegion, regionOf []int) {
+	labeled := false
+	names := make([]string, len(specs))
+	for i, s := range specs {
+		addrs = append(addrs, s)
+		if n := strings.Index(s, "="); n > 0 {
+			names[i], addrs[i] = s[:n], s[n+1:]
+			labeled = true
+		}
+	}
+	if !labeled {
+		return addrs, nil, nil
+	}
+	index := make(map[string]int)
+	for i, name := range names {
+		if name == "" {
+			name = addrs[i]
+		}
+		ri, ok := index[name]
+		if !ok {
+			ri = len(regions)
+			index[name] = ri
+			regions = append(regions, &agentRegion{name: name})
+		}
+		regions[ri].agents = append(regions[ri].agents, addrs[i])
+		regionOf = append(regionOf, ri)
+	}
+	return addrs, regions, regionOf
+}
+
+// RegionReport is the outcome of the requests sent by the agents of one
+// region, Errors counting failed requests and 5xx responses
+type RegionReport struct {
+	Region string
+	Agents int
+	Count  int64
+	RPS    float64
+	Errors int64
+	Mean   time.Duration
+	P50    time.Duration
+	P90    time.Duration
+	P99    time.Duration
+	Max    time.Duration
+}
+
+func (s *StreamReport) collectRegion(r *ReportRecord) {
+	if r.region < 0 || r.region >= len(s.regions) {
+		return
+	}
+	for len(s.regionStats) <= r.region {
+		s.regionStats = append(s.regionStats, &targetStats{quantile: quantile.NewTargeted(quantilesTarget)})
+	}
+	rs := s.regionStats[r.region]
+	rs.Update(float64(r.cost))
+	rs.quantile.Insert(float64(r.cost))
+	if r.error != "" || r.code >= 500 {
+		rs.errs++
+	}
+}
+
+func (s *StreamReport) regionReports(elapsed time.Duration) []*RegionReport {
+	var res []*RegionReport
+	for i, region := range s.regions {
+		rr := &RegionReport{Region: region.name, Agents: len(region.agents)}
+		if i < len(s.regionStats) {
+			st := s.regionStats[i]
+			rr.Count, rr.Errors = st.count, st.errs
+			rr.Mean, rr.Max = time.Duration(st.Mean()), time.Duration(st.max)
+			rr.P50 = time.Duration(st.quantile.Query(0.5))
+			rr.P90, rr.P99 = time.Duration(st.quantile.Query(0.9)), time.Duration(st.quantile.Query(0.99))
+			if sec := elapsed.Seconds(); sec > 0 {
+				rr.RPS = float64(st.count) / sec
+			}
+		}
+		res = append(res, rr)
+	}
+	return res
+}
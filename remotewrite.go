@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/snappy"
+)
+
+// remoteWriter pushes the metrics of the run with the Prometheus remote
+// write protocol, e.g. to Mimir or Thanos, for the runs which end before
+// any scrape
+type remoteWriter struct {
+	url      string
+	interval time.Duration
+	labels   []string // pairs of names and values added to each series
+	headers  http.Header
+	client   *http.Client
+	// failed is set once a push failed, reported once
+	failed bool
+}
+
+// newRemoteWriter returns the writer to rawURL, labels being K=V and
+// headers K:V, the series of the run being labeled with its runID
+func newRemoteWriter(rawURL string, interval time.Duration, labels, headers []string, runID string) (*remoteWriter, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("--remote-write %q is not an http(s) url", rawURL)
+	}
+	rw := &remoteWriter{
+		url:      rawURL,
+		interval: interval,
+		labels:   []string{"job", "plow", "run_id", runID},
+		headers:  make(http.Header),
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+	for _, l := range labels {
+		name, value, ok := strings.Cut(l, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("--remote-write-label %q is not NAME=VALUE", l)
+		}
+		rw.setLabel(name, value)
+	}
+	for _, h := range headers {
+		name, value, ok := strings.Cut(h, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("--remote-write-header %q is not K:V", h)
+		}
+		rw.headers.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+	return rw, nil
+}
+
+// setLabel sets the label name of the series, replacing a default one
+func (rw *remoteWriter) setLabel(name, value string) {
+	for i := 0; i+1 < len(rw.labels); i += 2 {
+		if rw.labels[i] == name {
+			rw.labels[i+1] = value
+			return
+		}
+	}
+	rw.labels = append(rw.labels, name, value)
+}
+
+// watch pushes the report of snapshot every interval until done is closed
+func (rw *remoteWriter) watch(snapshot func() *SnapshotReport, done <-chan struct{}) {
+	ticker := time.NewTicker(rw.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := rw.push(snapshot(), nil); err != nil && !rw.failed {
+				rw.failed = true
+				fmt.Fprintf(os.Stderr, "plow: remote write to %s failed: %s\n", rw.url, err)
+			}
+		}
+	}
+}
+
+// push sends the series of the report s and of the results of its
+// thresholds, with a sample at the current time each
+func (rw *remoteWriter) push(s *SnapshotReport, results []*ThresholdResult) error {
+	batch := &remoteWriteBatch{labels: rw.labels, timestamp: time.Now().UnixMilli()}
+	collectMetrics(batch, s, results)
+	req, err := http.NewRequest("POST", rw.url, bytes.NewReader(snappy.Encode(nil, batch.encode())))
+	if err != nil {
+		return err
+	}
+	for k, v := range rw.headers {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	req.Header.Set("User-Agent", "plow/"+version)
+	resp, err := rw.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// remoteWriteBatch is the series of a push, a sample each, encoded as the
+// protobuf WriteRequest of the remote write protocol
+type remoteWriteBatch struct {
+	labels    []string
+	timestamp int64 // in milliseconds
+	series    []remoteWriteSeries
+}
+
+type remoteWriteSeries struct {
+	labels   [][2]string // sorted by name
+	value    float64
+	exemplar *Exemplar
+}
+
+func (b *remoteWriteBatch) family(name, typ, unit, help string) {}
+
+func (b *remoteWriteBatch) sample(name string, v float64, labels ...string) {
+	b.exemplarSample(name, v, nil, labels...)
+}
+
+func (b *remoteWriteBatch) exemplarSample(name string, v float64, e *Exemplar, labels ...string) {
+	ls := [][2]string{{"__name__", name}}
+	for i := 0; i+1 < len(b.labels); i += 2 {
+		ls = append(ls, [2]string{b.labels[i], b.labels[i+1]})
+	}
+	for i := 0; i+1 < len(labels); i += 2 {
+		ls = append(ls, [2]string{labels[i], labels[i+1]})
+	}
+	sort.SliceStable(ls, func(i, j int) bool { return ls[i][0] < ls[j][0] })
+	b.series = append(b.series, remoteWriteSeries{labels: ls, value: v, exemplar: e})
+}
+
+// encode is the WriteRequest of the series: repeated TimeSeries timeseries
+// = 1, a TimeSeries being repeated Label labels = 1 and repeated Sample
+// samples = 2 and repeated Exemplar exemplars = 3, a Label string name = 1
+// and string value = 2, a Sample double value = 1 and int64 timestamp = 2
+// and an Exemplar repeated Label labels = 1, double value = 2 and int64
+// timestamp = 3
+func (b *remoteWriteBatch) encode() []byte {
+	var buf, ts, msg []byte
+	for _, s := range b.series {
+		ts = ts[:0]
+		for _, l := range s.labels {
+			msg = protoString(msg[:0], 1, l[0])
+			msg = protoString(msg, 2, l[1])
+			ts = protoBytes(ts, 1, msg)
+		}
+		msg = binary.AppendUvarint(msg[:0], 1<<3|1)
+		msg = binary.LittleEndian.AppendUint64(msg, math.Float64bits(s.value))
+		msg = binary.AppendUvarint(msg, 2<<3)
+		msg = binary.AppendUvarint(msg, uint64(b.timestamp))
+		ts = protoBytes(ts, 2, msg)
+		if e := s.exemplar; e != nil {
+			msg = protoString(msg[:0], 1, "trace_id")
+			msg = protoString(msg, 2, e.TraceID)
+			msg = protoBytes(nil, 1, msg)
+			msg = binary.AppendUvarint(msg, 2<<3|1)
+			msg = binary.LittleEndian.AppendUint64(msg, math.Float64bits(e.Latency.Seconds()))
+			msg = binary.AppendUvarint(msg, 3<<3)
+			msg = binary.AppendUvarint(msg, uint64(e.Time.UnixMilli()))
+			ts = protoBytes(ts, 3, msg)
+		}
+		buf = protoBytes(buf, 1, ts)
+	}
+	return buf
+}
+
+// protoBytes appends the length-delimited field of number field
+func protoBytes(buf []byte, field int, v []byte) []byte {
+	buf = binary.AppendUvarint(buf, uint64(field)<<3|2)
+	buf = binary.AppendUvarint(buf, uint64(len(v)))
+	return append(buf, v...)
+}
+
+func protoString(buf []byte, field int, v string) []byte {
+	buf = binary.AppendUvarint(buf, uint64(field)<<3|2)
+	buf = binary.AppendUvarint(buf, uint64(len(v)))
+	return append(buf, v...)
+}
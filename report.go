@@ -2,6 +2,8 @@ package main
 
 import (
 	"math"
+	"sort"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -81,30 +83,150 @@ type StreamReport struct {
 	rpsStats         *Stats
 	latencyQuantile  *quantile.Stream
 	latencyHistogram *histogram.Histogram
+	coldLatency      *connLatency
+	warmLatency      *connLatency
 	codes            map[int]int64
 	errors           map[string]int64
+	graphqlErrors    map[string]int64
+	errorClasses     [numErrorClasses]int64
+	serverTimings    map[string]*serverTimingStats
 	concurrencyCount int
 
+	authStats     Stats
+	staleRetried  int64
+	staleSurfaced int64
+	// redirects followed, and the requests they were followed for
+	redirectHops     int64
+	redirected       int64
+	redirectFailure  bool
+	reqSizeStats     Stats
+	respSizeStats    Stats
+	respSizeBins     sizeBins
+	latencyBuckets   latencyBuckets
+	bodyVariants     bodyVariants
+	decodedStats     Stats // of the responses decoded with --compressed
+	pipeline         *PipelineReport
+	compressed       bool
+	validated        int64
+	malformed        map[string]int64
+	grpcMode         string // type of the gRPC calls, their messages and their latency
+	msgSent          int64
+	msgRecv          int64
+	grpcCodes        map[string]int64
+	msgLatency       *connLatency
+	dnsType          string           // of the --dns-query queries, counted by response code
+	rcodes           map[string]int64 // the response codes of DNS, the error codes of SQL or the codes of a --backend
+	sse              *sseStats        // of the --sse streams, outliving their records
+	mqtt             *mqttMode        // its deliveries to the subscribers of --mqtt-subscribe
+	redis            *redisMode       // its commands, a request sending a pipeline of them
+	kafka            *kafkaMode       // its messages, a request producing a batch of them
+	sql              *sqlMode         // its rows, counted with the error codes
+	plugged          *pluggedMode
+	disableKeepalive bool
+	// arrival is the model of the request rate, the open-loop arrivals of
+	// poisson waiting for a free connection out of the latency
+	arrival          string
+	maxQueue         int
+	queueWait        *connLatency
+	response         *connLatency // queue wait plus latency
+	dropped          int64
+	replaySpeed      float64 // of the --access-log replay, 0 as fast as possible
+	replayEntries    int
+	replayLag        *connLatency // of its requests behind their time
+	replayOnTime     int64
+	backpressure     *backpressure
+	connections      int64 // opened by the requests
+	phaseStats       [numPhases]Stats
+	phasesWithinSec  [numPhases]float64
 	latencyWithinSec *Stats
 	rpsWithinSec     float64
+	readWithinSec    float64 // MB/s read and written in the last second
+	writeWithinSec   float64
+	errorsWithinSec  [numErrorClasses]int64 // of each class
 	noDateWithinSec  bool
+	warmupWithinSec  bool // the last second holding records of the warmup
+	ticks            []*TickReport
+	stages           []*stageStats
 
 	readBytes  int64
 	writeBytes int64
 
+	// the connections of the clients so far, the ones opened and closed per
+	// second in the last second, and the mean wait of the arrivals for a
+	// free connection in the last second
+	connsOpened       int64
+	connsClosed       int64
+	openedWithinSec   float64
+	closedWithinSec   float64
+	connWaitWithinSec float64
+
+	// the resources used by plow itself
+	client *clientMonitor
+	// the utilization of the target host, nil without --probe
+	probe *serverProbe
+	// the steps of the --search
+	search *rateSearch
+
+	// the run by historyInterval, and with soak the per-second ticks older
+	// than soakTicks dropped, ticksDropped counting them
+	history      []*TickReport
+	soak         bool
+	ticksDropped int
+
+	targets *targetPool
+	// urls and weights of the targets, by index
+	urls        []string
+	weights     []int
+	targetStats []*targetStats
+	proxies     []string
+	// regions of the agents, by index
+	regions     []*agentRegion
+	regionStats []*targetStats
+	// per proxy, by index in proxies
+	proxyStats []Stats
+	proxyErrs  []int64
+	// per ip of the target when dialed directly
+	addrStats map[string]*addrStats
+
+	extracts     []*extractor
+	extractStats []extractStats
+
+	certs *certReloader
+	// aborted is the reason the guardrail stopped the run
+	aborted string
+	// interrupted is closed when the run was stopped before its end, by
+	// ctrl-c or /stop
+	interrupted <-chan struct{}
+	// warmup excludes the first requests of the run from the summary
+	warmup *warmupDetector
+
 	doneChan chan struct{}
 }
 
-func NewStreamReport() *StreamReport {
+func NewStreamReport(targets *targetPool) *StreamReport {
 	return &StreamReport{
+		targets:          targets,
 		latencyQuantile:  quantile.NewTargeted(quantilesTarget),
 		latencyHistogram: histogram.New(8),
+		coldLatency:      newConnLatency(),
+		warmLatency:      newConnLatency(),
+		msgLatency:       newConnLatency(),
+		queueWait:        newConnLatency(),
+		replayLag:        newConnLatency(),
+		response:         newConnLatency(),
 		codes:            make(map[int]int64, 1),
 		errors:           make(map[string]int64, 1),
+		graphqlErrors:    make(map[string]int64),
+		malformed:        make(map[string]int64),
+		respSizeBins:     make(sizeBins),
+		bodyVariants:     bodyVariants{variants: make(map[bodyVariantKey]*BodyVariant)},
+		serverTimings:    make(map[string]*serverTimingStats),
+		addrStats:        make(map[string]*addrStats),
 		doneChan:         make(chan struct{}, 1),
 		latencyStats:     &Stats{},
 		rpsStats:         &Stats{},
 		latencyWithinSec: &Stats{},
+		client:           newClientMonitor(),
 	}
 }
 
@@ -116,29 +238,71 @@ func (s *StreamReport) insert(v float64) {
 
 func (s *StreamReport) Collect(records <-chan *ReportRecord) {
 	latencyWithinSecTemp := &Stats{}
+	connWaitWithinSecTemp := &Stats{}
+	warmupWithinSecTemp := false
+	var phasesWithinSecTemp [numPhases]Stats
+	tick := newTickCollector()
+	period := newTickCollector()
 	go func() {
 		startTime := time.Unix(0, atomic.LoadInt64(&startTimeUnixNano))
 		ticker := time.NewTicker(time.Second)
 		lastCount := int64(0)
 		lastTime := startTime
+		var lastRead, lastWrite int64
+		var lastOpened, lastClosed int64
+		var lastErrors [numErrorClasses]int64
 		for {
 			select {
-			case <-ticker.C:
+			case now := <-ticker.C:
+				client := s.client.sample()
 				s.lock.Lock()
-				dc := s.latencyStats.count - lastCount
+				s.client.observe(client)
+				count := s.latencyStats.count
+				if s.warmup != nil {
+					count += s.warmup.excluded
+				}
+				dc := count - lastCount
 				if dc > 0 {
-					rps := float64(dc) / time.Since(lastTime).Seconds()
-					s.rpsStats.Update(rps)
-					lastCount = s.latencyStats.count
+					secs := time.Since(lastTime).Seconds()
+					rps := float64(dc) / secs
+					if s.warmup == nil || s.warmup.done {
+						s.rpsStats.Update(rps)
+					}
+					s.readWithinSec = float64(s.readBytes-lastRead) / 1024.0 / 1024.0 / secs
+					s.writeWithinSec = float64(s.writeBytes-lastWrite) / 1024.0 / 1024.0 / secs
+					lastCount = count
 					lastTime = time.Now()
+					lastRead, lastWrite = s.readBytes, s.writeBytes
+					s.openedWithinSec = float64(s.connsOpened-lastOpened) / secs
+					s.closedWithinSec = float64(s.connsClosed-lastClosed) / secs
+					lastOpened, lastClosed = s.connsOpened, s.connsClosed
+					s.connWaitWithinSec = connWaitWithinSecTemp.Mean() / 1e6
+					connWaitWithinSecTemp.Reset()
+					for i, n := range s.errorClasses {
+						s.errorsWithinSec[i] = n - lastErrors[i]
+					}
+					lastErrors = s.errorClasses
 
 					*s.latencyWithinSec = *latencyWithinSecTemp
 					s.rpsWithinSec = rps
 					latencyWithinSecTemp.Reset()
+					for i := range phasesWithinSecTemp {
+						s.phasesWithinSec[i] = phasesWithinSecTemp[i].Mean()
+						phasesWithinSecTemp[i].Reset()
+					}
+					s.warmupWithinSec, warmupWithinSecTemp = warmupWithinSecTemp, false
 					s.noDateWithinSec = false
 				} else {
 					s.noDateWithinSec = true
 				}
+				s.ticks = append(s.ticks, tick.flush(now, time.Unix(0, atomic.LoadInt64(&startTimeUnixNano))))
+				if s.warmup != nil && s.warmup.observe(s.ticks[len(s.ticks)-1]) {
+					s.warmup.readBytes, s.warmup.writeBytes, s.warmup.dropped = s.readBytes, s.writeBytes, s.dropped
+				}
+				s.trimTicks()
+				if now.Sub(period.start) >= historyInterval-time.Second/2 {
+					s.history = append(s.history, period.flush(now, time.Unix(0, atomic.LoadInt64(&startTimeUnixNano))))
+				}
 				s.lock.Unlock()
 			case <-s.doneChan:
 				return
@@ -149,20 +313,178 @@ func (s *StreamReport) Collect(records <-chan *ReportRecord) {
 	for {
 		r, ok := <-records
 		if !ok {
+			s.lock.Lock()
+			if tick.count > 0 {
+				s.ticks = append(s.ticks, tick.flush(time.Now(), time.Unix(0, atomic.LoadInt64(&startTimeUnixNano))))
+			}
+			if period.count > 0 {
+				s.history = append(s.history, period.flush(time.Now(), time.Unix(0, atomic.LoadInt64(&startTimeUnixNano))))
+			}
+			s.lock.Unlock()
 			close(s.doneChan)
 			break
 		}
 		s.lock.Lock()
 		latencyWithinSecTemp.Update(float64(r.cost))
+		connWaitWithinSecTemp.Update(float64(r.queueWait))
+		s.connsOpened, s.connsClosed = r.connsOpened, r.connsClosed
+		tick.collect(r)
+		period.collect(r)
+		if s.warmup != nil && s.warmup.expire(time.Since(time.Unix(0, atomic.LoadInt64(&startTimeUnixNano)))) {
+			s.warmup.readBytes, s.warmup.writeBytes, s.warmup.dropped = s.readBytes, s.writeBytes, s.dropped
+		}
+		if s.warmup != nil && !s.warmup.done {
+			// in the live charts only, marked as the warmup
+			tick.warmup, period.warmup, warmupWithinSecTemp = true, true, true
+			s.warmup.excluded++
+			s.readBytes, s.writeBytes = r.readBytes, r.writeBytes
+			s.dropped = r.dropped
+			s.concurrencyCount = r.concurrencyCount
+			s.lock.Unlock()
+			recordPool.Put(r)
+			continue
+		}
+		if r.stage >= 0 {
+			for len(s.stages) <= r.stage {
+				s.stages = append(s.stages, newStageStats())
+			}
+			s.stages[r.stage].collect(r, time.Now())
+		}
 		s.insert(float64(r.cost))
+		s.latencyBuckets.add(r)
+		switch r.stale {
+		case staleRetried:
+			s.staleRetried++
+		case staleSurfaced:
+			s.staleSurfaced++
+		}
+		if r.cold && r.error == "" {
+			s.connections++
+		}
+		if r.cold {
+			s.coldLatency.insert(float64(r.cost))
+		} else {
+			s.warmLatency.insert(float64(r.cost))
+		}
+		if r.error == "" && r.phases != (phaseTimes{}) {
+			for i, d := range r.phases {
+				s.phaseStats[i].Update(float64(d))
+				phasesWithinSecTemp[i].Update(float64(d))
+			}
+		}
+		if len(s.urls) > 1 && r.target < len(s.urls) {
+			for len(s.targetStats) <= r.target {
+				s.targetStats = append(s.targetStats, &targetStats{quantile: quantile.NewTargeted(quantilesTarget)})
+			}
+			ts := s.targetStats[r.target]
+			ts.Update(float64(r.cost))
+			ts.quantile.Insert(float64(r.cost))
+			if isFailure(r, s.redirectFailure) {
+				ts.errs++
+			}
+		}
+		s.collectRegion(r)
+		if r.proxy >= 0 {
+			for len(s.proxyStats) <= r.proxy {
+				s.proxyStats = append(s.proxyStats, Stats{})
+				s.proxyErrs = append(s.proxyErrs, 0)
+			}
+			s.proxyStats[r.proxy].Update(float64(r.cost))
+			if isFailure(r, s.redirectFailure) {
+				s.proxyErrs[r.proxy]++
+			}
+		}
+		if len(r.extracted) > 0 {
+			s.extractStats = updateExtracted(s.extractStats, r.extracted)
+		}
+		if r.addr != "" {
+			as := s.addrStats[r.addr]
+			if as == nil {
+				as = &addrStats{}
+				s.addrStats[r.addr] = as
+			}
+			as.Update(float64(r.cost))
+			if isFailure(r, s.redirectFailure) {
+				as.errs++
+			}
+		}
+		if r.authCost > 0 {
+			s.authStats.Update(float64(r.authCost))
+		}
+		if r.error == "" {
+			s.reqSizeStats.Update(float64(r.reqSize))
+			s.respSizeStats.Update(float64(r.respSize))
+			s.respSizeBins.add(r.respSize)
+			if s.compressed {
+				s.decodedStats.Update(float64(r.decodedSize))
+			}
+		}
+		if r.bodyHash != 0 {
+			s.bodyVariants.add(r)
+		}
+		if r.validated {
+			s.validated++
+			if r.malformed != "" {
+				s.malformed[r.malformed]++
+			}
+		}
+		if r.redirects > 0 {
+			s.redirectHops += int64(r.redirects)
+			s.redirected++
+		}
+		if s.grpcMode != "" {
+			s.msgSent += int64(r.msgSent)
+			s.msgRecv += int64(r.msgRecv)
+			for _, d := range r.msgLatencies {
+				s.msgLatency.insert(float64(d))
+			}
+			if r.grpcStatus != "" {
+				if s.grpcCodes == nil {
+					s.grpcCodes = make(map[string]int64)
+				}
+				s.grpcCodes[r.grpcStatus]++
+			}
+		}
+		if r.rcode != "" {
+			if s.rcodes == nil {
+				s.rcodes = make(map[string]int64)
+			}
+			s.rcodes[r.rcode]++
+		}
 		if r.code != 0 {
 			s.codes[r.code]++
 		}
 		if r.error != "" {
 			s.errors[r.error]++
+			if r.errClass >= 0 && r.errClass < numErrorClasses {
+				s.errorClasses[r.errClass]++
+			}
+		}
+		if r.graphqlError != "" {
+			s.graphqlErrors[r.graphqlError]++
+		}
+		for _, st := range r.serverTimings {
+			sts := s.serverTimings[st.Name]
+			if sts == nil {
+				sts = &serverTimingStats{}
+				s.serverTimings[st.Name] = sts
+			}
+			sts.Update(float64(st.Dur))
+			sts.latency += float64(r.cost)
+		}
+		if s.arrival == arrivalPoisson {
+			s.queueWait.insert(float64(r.queueWait))
+			s.response.insert(float64(r.queueWait + r.cost))
+		}
+		if s.replaySpeed > 0 && r.replayLag >= 0 {
+			s.replayLag.insert(float64(r.replayLag))
+			if r.replayLag <= replayTolerance {
+				s.replayOnTime++
+			}
 		}
 		s.readBytes = r.readBytes
 		s.writeBytes = r.writeBytes
+		s.dropped = r.dropped
 		s.concurrencyCount = r.concurrencyCount
 		s.lock.Unlock()
 		recordPool.Put(r)
@@ -181,10 +503,14 @@ type SnapshotReport struct {
 	Count            int64
 	Codes            map[string]int64
 	Errors           map[string]int64
+	ErrorClasses     map[string]int64
+	GraphQLErrors    map[string]int64
 	RPS              float64
 	ReadThroughput   float64
 	WriteThroughput  float64
 	concurrencyCount int
+	Aborted          string // reason the guardrail stopped the run
+	Interrupted      bool   // stopped before its end, by ctrl-c or /stop
 
 	Stats *struct {
 		Min    time.Duration
@@ -209,6 +535,149 @@ type SnapshotReport struct {
 		Mean  time.Duration
 		Count int
 	}
+	// the buckets of the exported latency histogram
+	LatencyBuckets []LatencyBucket
+
+	Ejections  []*EjectionReport
+	Stages     []*SnapshotReport
+	Auth       *AuthReport
+	StaleConns *StaleConnReport
+	Warmup     *WarmupReport
+	Redirects  *RedirectReport
+	BodySize   *BodySizeReport
+	Validation *ValidationReport
+	// the distinct response bodies of --hash-bodies
+	BodyVariants *BodyVariantsReport
+	GRPC         *GRPCReport
+	DNS          *DNSReport
+	MQTT         *MQTTReport
+	Redis        *RedisReport
+	Kafka        *KafkaReport
+	SQL          *SQLReport
+	Backend      *BackendReport
+	SSE          *SSEReport
+	Pipeline     *PipelineReport
+	// the wait of the open-loop arrivals for a free connection
+	Arrivals *ArrivalReport
+	// the lag of the --access-log replay behind the times of the log
+	Replay *ReplayReport
+	// the records which waited for Collect, nil when none did
+	Backpressure *BackpressureReport
+	// the seconds plow itself was saturated, nil when it never was
+	Client *ClientReport
+	// the utilization of the target host, nil without --probe
+	Server *ServerReport
+	// the steps and the max rate of the --search
+	Search *SearchReport
+	// the connections opened, with keep-alive disabled
+	Connections *ConnectionsReport
+	// the latency spikes, and their period
+	Outliers *OutlierReport
+
+	Targets   []*TargetReport
+	Regions   []*RegionReport
+	Proxies   []*ProxyReport
+	Addresses []*AddressReport
+	Extracted []*ExtractReport
+
+	ClientCerts []*ClientCertReport
+
+	// latency of the requests which opened their connection, and of the others
+	Cold *ConnLatencyReport
+	Warm *ConnLatencyReport
+
+	ServerTiming []*ServerTimingReport
+	Phases       []*PhaseReport
+}
+
+// ProxyReport is the outcome of the requests sent through one proxy,
+// Errors counting failed requests and 5xx responses
+type ProxyReport struct {
+	Proxy  string
+	Count  int64
+	Errors int64
+	Mean   time.Duration
+	Max    time.Duration
+}
+
+type targetStats struct {
+	Stats
+	errs     int64
+	quantile *quantile.Stream
+}
+
+// TargetReport is the outcome of the requests sent to one url of the mix,
+// Errors counting failed requests and 5xx responses
+type TargetReport struct {
+	URL    string
+	Weight int // 0 without weights
+	Count  int64
+	RPS    float64
+	Errors int64
+	Mean   time.Duration
+	P90    time.Duration
+	P99    time.Duration
+	Max    time.Duration
+}
+
+type addrStats struct {
+	Stats
+	errs int64
+}
+
+// AddressReport is the outcome of the requests sent to one ip of the targets,
+// Errors counting failed requests and 5xx responses
+type AddressReport struct {
+	Addr   string
+	Count  int64
+	Errors int64
+	Mean   time.Duration
+	Max    time.Duration
+}
+
+// PhaseReport is the time spent in one phase of the requests, Mean adding
+// up to the mean latency across phases.
+type PhaseReport struct {
+	Name string
+	Mean time.Duration
+	Max  time.Duration
+}
+
+// connLatency is the latency of either cold or warm connection requests
+type connLatency struct {
+	stats    Stats
+	quantile *quantile.Stream
+}
+
+func newConnLatency() *connLatency {
+	return &connLatency{quantile: quantile.NewTargeted(quantilesTarget)}
+}
+
+func (c *connLatency) insert(v float64) {
+	c.stats.Update(v)
+	c.quantile.Insert(v)
+}
+
+// ConnLatencyReport has the latency percentiles of the quantiles
+type ConnLatencyReport struct {
+	Count       int64
+	Mean        time.Duration
+	Percentiles []time.Duration
+}
+
+func (c *connLatency) snapshot() *ConnLatencyReport {
+	r := &ConnLatencyReport{Count: c.stats.count, Mean: time.Duration(c.stats.Mean())}
+	for _, q := range quantiles {
+		r.Percentiles = append(r.Percentiles, time.Duration(c.quantile.Query(q)))
+	}
+	return r
+}
+
+// Abort records the reason the run was stopped before its end
+func (s *StreamReport) Abort(reason string) {
+	s.lock.Lock()
+	s.aborted = reason
+	s.lock.Unlock()
 }
 
 func (s *StreamReport) Snapshot() *SnapshotReport {
@@ -224,6 +693,23 @@ func (s *StreamReport) Snapshot() *SnapshotReport {
 			Max    time.Duration
 		}{time.Duration(s.latencyStats.min), time.Duration(s.latencyStats.Mean()),
 			time.Duration(s.latencyStats.Stddev()), time.Duration(s.latencyStats.max)},
+		Aborted: s.aborted,
+	}
+	select {
+	case <-s.interrupted:
+		rs.Interrupted = true
+	default:
+	}
+	readBytes, writeBytes, dropped := s.readBytes, s.writeBytes, s.dropped
+	if s.warmup != nil {
+		rs.Warmup = s.warmup.report(rs.Elapsed)
+		if s.warmup.done {
+			// the summary covers the run after the warmup
+			rs.Elapsed -= s.warmup.end
+			readBytes -= s.warmup.readBytes
+			writeBytes -= s.warmup.writeBytes
+			dropped -= s.warmup.dropped
+		}
 	}
 	if s.rpsStats.count > 0 {
 		rs.RpsStats = &struct {
@@ -237,19 +723,33 @@ func (s *StreamReport) Snapshot() *SnapshotReport {
 
 	elapseInSec := rs.Elapsed.Seconds()
 	rs.RPS = float64(rs.Count) / elapseInSec
-	rs.ReadThroughput = float64(s.readBytes) / 1024.0 / 1024.0 / elapseInSec
-	rs.WriteThroughput = float64(s.writeBytes) / 1024.0 / 1024.0 / elapseInSec
+	rs.ReadThroughput = float64(readBytes) / 1024.0 / 1024.0 / elapseInSec
+	rs.WriteThroughput = float64(writeBytes) / 1024.0 / 1024.0 / elapseInSec
 	rs.concurrencyCount = s.concurrencyCount
 
 	rs.Codes = make(map[string]int64, len(s.codes))
 	for k, v := range s.codes {
 		section := k / 100
-		rs.Codes[httpStatusSectionLabelMap[section]] = v
+		rs.Codes[httpStatusSectionLabelMap[section]] += v
 	}
 	rs.Errors = make(map[string]int64, len(s.errors))
 	for k, v := range s.errors {
 		rs.Errors[k] = v
 	}
+	for i, n := range s.errorClasses {
+		if n > 0 {
+			if rs.ErrorClasses == nil {
+				rs.ErrorClasses = make(map[string]int64)
+			}
+			rs.ErrorClasses[errorClassNames[i]] = n
+		}
+	}
+	if len(s.graphqlErrors) > 0 {
+		rs.GraphQLErrors = make(map[string]int64, len(s.graphqlErrors))
+		for k, v := range s.graphqlErrors {
+			rs.GraphQLErrors[k] = v
+		}
+	}
 
 	rs.Percentiles = make([]*struct {
 		Percentile float64
@@ -274,10 +774,195 @@ func (s *StreamReport) Snapshot() *SnapshotReport {
 		}{time.Duration(b.Mean()), b.Count}
 	}
 
+	rs.Ejections = s.targets.Ejections()
+	if s.authStats.count > 0 {
+		rs.Auth = &AuthReport{
+			Handshakes: s.authStats.count,
+			Mean:       time.Duration(s.authStats.Mean()),
+			Max:        time.Duration(s.authStats.max),
+			Total:      time.Duration(s.authStats.sum),
+		}
+	}
+	if s.staleRetried > 0 || s.staleSurfaced > 0 {
+		rs.StaleConns = &StaleConnReport{Retried: s.staleRetried, Surfaced: s.staleSurfaced}
+	}
+	if s.respSizeStats.count > 0 {
+		rs.BodySize = &BodySizeReport{Request: newBodySizeStats(&s.reqSizeStats), Response: newBodySizeStats(&s.respSizeStats), Histogram: s.respSizeBins.histogram()}
+		if s.compressed {
+			decoded := newBodySizeStats(&s.decodedStats)
+			rs.BodySize.Decoded = &decoded
+			if s.respSizeStats.sum > 0 {
+				rs.BodySize.Ratio = s.decodedStats.sum / s.respSizeStats.sum
+			}
+		}
+	}
+	rs.LatencyBuckets = s.latencyBuckets.report()
+	if s.bodyVariants.hashed > 0 {
+		rs.BodyVariants = s.bodyVariants.report()
+	}
+	if s.validated > 0 {
+		rs.Validation = &ValidationReport{Checked: s.validated}
+		if len(s.malformed) > 0 {
+			rs.Validation.Malformed = make(map[string]int64, len(s.malformed))
+			for k, v := range s.malformed {
+				rs.Validation.Malformed[k] = v
+			}
+		}
+	}
+	if s.redirected > 0 || s.redirectFailure {
+		rs.Redirects = &RedirectReport{Followed: s.redirectHops, Redirected: s.redirected, Failure: s.redirectFailure}
+	}
+	if s.disableKeepalive {
+		rs.Connections = &ConnectionsReport{Opened: s.connections, Rate: float64(s.connections) / elapseInSec}
+	}
+	if s.grpcMode != "" {
+		rs.GRPC = &GRPCReport{Type: s.grpcMode, Sent: s.msgSent, Received: s.msgRecv, Rate: float64(s.msgSent+s.msgRecv) / elapseInSec}
+		if s.msgLatency.stats.count > 0 {
+			rs.GRPC.Latency = s.msgLatency.snapshot()
+		}
+		if len(s.grpcCodes) > 0 {
+			rs.GRPC.Codes = make(map[string]int64, len(s.grpcCodes))
+			for k, v := range s.grpcCodes {
+				rs.GRPC.Codes[k] = v
+			}
+		}
+	}
+	if s.dnsType != "" {
+		rs.DNS = &DNSReport{Type: s.dnsType, Rcodes: make(map[string]int64, len(s.rcodes))}
+		for k, v := range s.rcodes {
+			rs.DNS.Rcodes[k] = v
+		}
+	}
+	if s.mqtt != nil && s.mqtt.stats != nil {
+		rs.MQTT = s.mqtt.stats.report(s.mqtt.qos, elapseInSec)
+	}
+	if s.redis != nil {
+		rs.Redis = s.redis.stats.report(elapseInSec)
+	}
+	if s.kafka != nil {
+		rs.Kafka = s.kafka.report(elapseInSec)
+	}
+	if s.sql != nil {
+		rs.SQL = s.sql.report(s.rcodes, elapseInSec)
+	}
+	if s.plugged != nil {
+		rs.Backend = s.plugged.report(s.rcodes)
+	}
+	if s.sse != nil {
+		rs.SSE = s.sse.report(elapseInSec)
+	}
+	if s.pipeline != nil {
+		p := *s.pipeline
+		p.RPSPerConn = rs.RPS / float64(p.Connections)
+		rs.Pipeline = &p
+	}
+	if s.arrival == arrivalPoisson {
+		rs.Arrivals = &ArrivalReport{Model: s.arrival, MaxQueue: s.maxQueue, Dropped: dropped}
+		if s.queueWait.stats.count > 0 {
+			rs.Arrivals.QueueWait = s.queueWait.snapshot()
+			rs.Arrivals.Response = s.response.snapshot()
+		}
+	}
+	rs.Backpressure = s.backpressure.report()
+	rs.Client = s.client.report()
+	rs.Server = s.probe.report()
+	rs.Search = s.search.report()
+	if s.replaySpeed > 0 {
+		rs.Replay = &ReplayReport{Speed: s.replaySpeed, Entries: s.replayEntries, Sent: s.replayLag.stats.count, OnTime: s.replayOnTime}
+		if s.replayLag.stats.count > 0 {
+			rs.Replay.Lag = s.replayLag.snapshot()
+			rs.Replay.MaxLag = time.Duration(s.replayLag.stats.max)
+		}
+	}
+	if len(s.serverTimings) > 0 {
+		rs.ServerTiming = serverTimingReports(s.serverTimings)
+	}
+	for i, u := range s.urls {
+		if len(s.urls) < 2 {
+			break
+		}
+		tr := &TargetReport{URL: u}
+		if i < len(s.weights) {
+			tr.Weight = s.weights[i]
+		}
+		if i < len(s.targetStats) {
+			st := s.targetStats[i]
+			tr.Count, tr.Errors = st.count, st.errs
+			tr.Mean, tr.Max = time.Duration(st.Mean()), time.Duration(st.max)
+			tr.P90, tr.P99 = time.Duration(st.quantile.Query(0.9)), time.Duration(st.quantile.Query(0.99))
+			if sec := rs.Elapsed.Seconds(); sec > 0 {
+				tr.RPS = float64(st.count) / sec
+			}
+		}
+		rs.Targets = append(rs.Targets, tr)
+	}
+	rs.Regions = s.regionReports(rs.Elapsed)
+	for i, st := range s.proxyStats {
+		name := strconv.Itoa(i)
+		if i < len(s.proxies) {
+			name = redactProxy(s.proxies[i])
+		}
+		rs.Proxies = append(rs.Proxies, &ProxyReport{
+			Proxy: name, Count: st.count, Errors: s.proxyErrs[i], Mean: time.Duration(st.Mean()), Max: time.Duration(st.max),
+		})
+	}
+	if s.certs != nil {
+		rs.ClientCerts = s.certs.reports()
+	}
+	if len(s.extracts) > 0 {
+		rs.Extracted = extractReports(s.extracts, s.extractStats, s.ticks)
+	}
+	if s.warmup == nil {
+		rs.Outliers = outlierReport(s.ticks, 0)
+	} else if s.warmup.done {
+		rs.Outliers = outlierReport(s.ticks, s.warmup.end)
+	}
+	for addr, st := range s.addrStats {
+		rs.Addresses = append(rs.Addresses, &AddressReport{
+			Addr: addr, Count: st.count, Errors: st.errs, Mean: time.Duration(st.Mean()), Max: time.Duration(st.max),
+		})
+	}
+	sort.Slice(rs.Addresses, func(i, j int) bool { return rs.Addresses[i].Addr < rs.Addresses[j].Addr })
+	if s.coldLatency.stats.count > 0 && s.warmLatency.stats.count > 0 {
+		rs.Cold = s.coldLatency.snapshot()
+		rs.Warm = s.warmLatency.snapshot()
+	}
+	if s.phaseStats[phaseWait].count > 0 {
+		for i, st := range s.phaseStats {
+			rs.Phases = append(rs.Phases, &PhaseReport{Name: phaseNames[i], Mean: time.Duration(st.Mean()), Max: time.Duration(st.max)})
+		}
+	}
+	for _, st := range s.stages {
+		rs.Stages = append(rs.Stages, st.snapshot())
+	}
+
 	s.lock.Unlock()
 	return rs
 }
 
+// Ticks returns the per-second reports recorded since index from, from the
+// oldest one kept with --soak
+func (s *StreamReport) Ticks(from int) []*TickReport {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if from -= s.ticksDropped; from < 0 {
+		from = 0
+	}
+	if from >= len(s.ticks) {
+		return nil
+	}
+	res := make([]*TickReport, len(s.ticks)-from)
+	copy(res, s.ticks[from:])
+	return res
+}
+
+// count is the requests collected so far
+func (s *StreamReport) count() int64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.latencyStats.count
+}
+
 func (s *StreamReport) Done() <-chan struct{} {
 	return s.doneChan
 }
@@ -290,6 +975,32 @@ type ChartsReport struct {
 	OverallLatency Stats // latency kumulatif seluruh sesi (untuk stat cards)
 	CodeMap        map[int]int64
 	Concurrency    int
+	Phases         [numPhases]float64 // mean of each phase in the last second
+	Extracted      []float64          // mean of each extracted field in the last second
+	// MB/s in the last second, and over the run for the stat cards
+	ReadThroughput     float64
+	WriteThroughput    float64
+	AvgReadThroughput  float64
+	AvgWriteThroughput float64
+	RespSize           float64                // mean response body bytes
+	Errors             [numErrorClasses]int64 // of each class in the last second
+
+	// the connections open, the ones opened and closed per second, and the
+	// mean wait in ms of the arrivals for a free connection, in the last
+	// second
+	OpenConns   int64
+	ConnsOpened float64
+	ConnsClosed float64
+	ConnWait    float64
+
+	// the resources used by plow itself in the last second
+	Client clientSample
+	// the utilization of the target host in the last second, nil without
+	// --probe or while it is unreachable
+	Server *probeSample
+	// Warmup tells the last second held records of the warmup, excluded
+	// from the summary
+	Warmup bool
 }
 
 func (s *StreamReport) Charts() *ChartsReport {
@@ -306,8 +1017,56 @@ func (s *StreamReport) Charts() *ChartsReport {
 			OverallLatency: *s.latencyStats,
 			CodeMap:        s.copyCodes(),
 			Concurrency:    s.concurrencyCount,
+			Phases:         s.phasesWithinSec,
+
+			ReadThroughput:  s.readWithinSec,
+			WriteThroughput: s.writeWithinSec,
+			RespSize:        s.respSizeStats.Mean(),
+			Errors:          s.errorsWithinSec,
+
+			OpenConns:   s.connsOpened - s.connsClosed,
+			ConnsOpened: s.openedWithinSec,
+			ConnsClosed: s.closedWithinSec,
+			ConnWait:    s.connWaitWithinSec,
+
+			Client: s.client.last,
+			Server: s.probe.sample(),
+			Warmup: s.warmupWithinSec,
+		}
+		if elapsed := time.Since(time.Unix(0, atomic.LoadInt64(&startTimeUnixNano))).Seconds(); elapsed > 0 {
+			cr.AvgReadThroughput = float64(s.readBytes) / 1024.0 / 1024.0 / elapsed
+			cr.AvgWriteThroughput = float64(s.writeBytes) / 1024.0 / 1024.0 / elapsed
+		}
+		if len(s.extracts) > 0 && len(s.ticks) > 0 {
+			cr.Extracted = s.ticks[len(s.ticks)-1].Extracted
 		}
 	}
 	s.lock.Unlock()
 	return cr
 }
+
+// extracted returns the mean of each of the n extracted fields, nil without data
+func (cr *ChartsReport) extracted(n int) []interface{} {
+	values := make([]interface{}, n)
+	if cr == nil {
+		return values
+	}
+	for i, v := range cr.Extracted {
+		if i < n && !math.IsNaN(v) {
+			values[i] = v
+		}
+	}
+	return values
+}
+
+// phases returns the mean of each phase in ms, or nils without data
+func (cr *ChartsReport) phases() []interface{} {
+	values := make([]interface{}, numPhases)
+	if cr == nil {
+		return values
+	}
+	for i, p := range cr.Phases {
+		values[i] = p / 1e6
+	}
+	return values
+}
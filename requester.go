@@ -1,11 +1,15 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
 	"fmt"
 	"io"
 	"math"
+	"math/rand"
 	"net"
 	url2 "net/url"
 	"os"
@@ -29,14 +33,67 @@ var (
 )
 
 type ReportRecord struct {
+	target           int
+	stage            int
 	cost             time.Duration
+	authCost         time.Duration
 	code             int
 	error            string
+	errClass         int // of error, when set
+	graphqlError     string
+	serverTimings    []ServerTiming
+	phases           phaseTimes
+	cold             bool   // the request opened its connection
+	stale            int    // staleRetried or staleSurfaced when it failed on a reused one
+	proxy            int    // index of the proxy, -1 without
+	region           int    // index of the region of the agent, only set by the controller
+	addr             string // ip the request was sent to, if known
+	extracted        []float64
+	redirects        int   // hops followed
+	reqSize          int64 // body bytes of the request and response
+	respSize         int64
+	decodedSize      int64  // of the response body with --compressed
+	validated        bool   // the body was checked with --validate
+	malformed        string // class of the malformed body, "" when valid
+	bodyHash         uint64 // of the response body with --hash-bodies, 0 when not hashed
+	bodySample       string // start of the body of a variant seen first
+	traceID          string // of --trace-header, the exemplar of its latency bucket
+	msgSent          int    // messages of a gRPC call
+	msgRecv          int
+	grpcStatus       string // name of the grpc-status of a gRPC call, or of its RST_STREAM code
+	rcode            string // name of the response code of a --dns-query, the error code of a --sql-query or the code of a --backend record
+	msgLatencies     []time.Duration
+	queueWait        time.Duration // of the open-loop arrival for a free connection
+	replayLag        time.Duration // behind the time of the --replay-speed request, -1 without
 	readBytes        int64
 	writeBytes       int64
+	connsOpened      int64 // by the clients so far
+	connsClosed      int64
+	dropped          int64 // arrivals dropped so far
 	concurrencyCount int
 }
 
+// reset clears the fields of the response of the last request of a pooled
+// record, ahead of the next one
+func (rr *ReportRecord) reset() {
+	rr.code = 0
+	rr.graphqlError = ""
+	rr.serverTimings = rr.serverTimings[:0]
+	rr.phases = phaseTimes{}
+	rr.cold = false
+	rr.stale = staleNone
+	rr.addr = ""
+	rr.extracted = rr.extracted[:0]
+	rr.redirects = 0
+	rr.reqSize, rr.respSize, rr.decodedSize = 0, 0, 0
+	rr.validated, rr.malformed = false, ""
+	rr.bodyHash, rr.bodySample = 0, ""
+	rr.msgSent, rr.msgRecv = 0, 0
+	rr.msgLatencies = rr.msgLatencies[:0]
+	rr.grpcStatus = ""
+	rr.rcode = ""
+}
+
 var recordPool = sync.Pool{
 	New: func() interface{} { return new(ReportRecord) },
 }
@@ -56,11 +113,16 @@ func init() {
 
 type MyConn struct {
 	net.Conn
-	r, w *int64
+	r, w   *int64
+	pool   *connPool
+	closed int32
 }
 
-func NewMyConn(conn net.Conn, r, w *int64) (*MyConn, error) {
-	myConn := &MyConn{Conn: conn, r: r, w: w}
+func NewMyConn(conn net.Conn, r, w *int64, pool *connPool) (*MyConn, error) {
+	myConn := &MyConn{Conn: conn, r: r, w: w, pool: pool}
+	if pool != nil {
+		atomic.AddInt64(&pool.opened, 1)
+	}
 	return myConn, nil
 }
 
@@ -82,13 +144,20 @@ func (c *MyConn) Write(b []byte) (n int, err error) {
 	return sz, err
 }
 
-func ThroughputInterceptorDial(dial fasthttp.DialFunc, r *int64, w *int64) fasthttp.DialFunc {
+func (c *MyConn) Close() error {
+	if c.pool != nil && atomic.CompareAndSwapInt32(&c.closed, 0, 1) {
+		atomic.AddInt64(&c.pool.closed, 1)
+	}
+	return c.Conn.Close()
+}
+
+func ThroughputInterceptorDial(dial fasthttp.DialFunc, r *int64, w *int64, pool *connPool) fasthttp.DialFunc {
 	return func(addr string) (net.Conn, error) {
 		conn, err := dial(addr)
 		if err != nil {
 			return nil, err
 		}
-		return NewMyConn(conn, r, w)
+		return NewMyConn(conn, r, w, pool)
 	}
 }
 
@@ -98,43 +167,199 @@ type Requester struct {
 	requests    int64
 	duration    time.Duration
 	rampUp      int
+	stages      []*Stage
+	stage       int32
+	search      *rateSearch
 	clientOpt   *ClientOpt
-	httpClient  *fasthttp.HostClient
-	httpHeader  *fasthttp.RequestHeader
+	targets     *targetPool
 	errWriter   io.Writer
 
 	recordChan chan *ReportRecord
 	closeOnce  sync.Once
 	wg         sync.WaitGroup
+	stalls     backpressure
+
+	// quits are the stop flags of the workers by index, grown and shrunk by
+	// Adjust, workers being their number as the concurrency of the records,
+	// and active counts the goroutines of the workers still running
+	workersMu sync.Mutex
+	quits     []*int32
+	spawn     func(worker int, quit *int32)
+	workers   int64
+	active    int64
+
+	// grpcGroups are the connections of the gRPC calls, shared by the
+	// workers of a group of --grpc-streams
+	grpcMu     sync.Mutex
+	grpcGroups map[int]*grpcConns
+	// protocol sends the requests of the urls other than HTTP ones, nil
+	// for these
+	protocol protocol
 
 	readBytes  int64
 	writeBytes int64
+	proxySeq   uint64
+	pool       connPool
+	// arrivals generates the open-loop arrivals, nil with the fixed rate
+	arrivals *arrivals
 
-	cancel func()
+	cancel     func()
+	cancelOnce sync.Once
+	// interrupted is closed when the run was stopped before its end, by
+	// ctrl-c or /stop
+	interrupted   chan struct{}
+	interruptOnce sync.Once
+	// ended is closed when the run is over or canceled, aborting the streamed
+	// bodies which the end of -n lets complete
+	ended     chan struct{}
+	endedOnce sync.Once
 }
 
 type ClientOpt struct {
-	url       string
-	method    string
-	headers   []string
-	bodyBytes []byte
-	bodyFile  string
+	urls []string
+	// weights of urls in the mix of requests, nil to take them in turn
+	weights []int
+	// targetRequests replace the method, headers and body of each url, e.g.
+	// with the requests of a HAR file, and with sequence each connection
+	// sends them in order
+	targetRequests []*targetRequest
+	sequence       bool
+	// stream replays the requests of an access log on the url
+	stream *requestStream
+
+	method  string
+	headers []string
+	// traceHeader sets a new trace id in a header of each request
+	traceHeader *traceHeader
+	bodyBytes   []byte
+	bodyFile    string
+	// bodyStream paces the chunked bodies of bodyFile, or generates them
+	bodyStream *bodyStream
 
-	certPath string
-	keyPath  string
-	insecure bool
+	certPath   string
+	keyPath    string
+	caCertPath string
+	insecure   bool
+	// certs presents the client certificate reloaded on change, when set
+	certs *certReloader
 
 	maxConns     int
 	doTimeout    time.Duration
 	readTimeout  time.Duration
 	writeTimeout time.Duration
 	dialTimeout  time.Duration
+	// grace is the time the requests in flight have to complete once the
+	// run is stopped
+	grace     time.Duration
+	staleConn string // policy of the requests failing on a reused connection
+	// connLimiter spreads the dials of new connections, nil without --conn-rate
+	connLimiter *rate.Limiter
+	// disableKeepalive closes the connection after each request
+	disableKeepalive bool
+	// compressed decodes the response bodies, for their extractors and size
+	compressed bool
+	// pipeline is the number of requests pipelined on each connection
+	pipeline int
+	// tlsTimeout bounds the TLS handshakes, writeTimeout when 0
+	tlsTimeout time.Duration
+	// thinkTime pauses each worker between its requests, nil without
+	thinkTime *thinkTime
+	// arrival is the model of the request rate, and maxQueue the arrivals
+	// waiting for a free connection at most with poisson
+	arrival  string
+	maxQueue int
+
+	// proxies are rotated per request, proxy is the one of a worker client
+	proxies []string
+	proxy   string
 
-	socks5Proxy string
-	httpProxy   string
 	contentType string
 	host        string
-	unixSocket  string
+	// sni is the TLS server name sent and verified instead of the host of
+	// the url
+	sni        string
+	unixSocket string
+	// connectTo is the host:port dialed instead of the one of the url,
+	// which keeps giving the Host header and the TLS server name
+	connectTo string
+	dns       *dnsResolver
+	local     *localAddrs
+
+	ejectAfter    int
+	probeInterval time.Duration
+
+	proxyProtocol int
+	proxySources  []*proxySource
+
+	auth authScheme
+
+	graphql bool
+	jwt     *jwtMinter
+	oauth2  *oauth2Source
+	// extractors read numeric fields of a share extractRate of the JSON responses
+	extractors  []*extractor
+	extractRate float64
+	// validate checks a share validateRate of the 2xx bodies are well-formed
+	// utf8, json or xml
+	validate     string
+	validateRate float64
+	// hasher hashes the response bodies to count their variants, nil
+	// without --hash-bodies
+	hasher *bodyHasher
+
+	// cookieJar gives each worker its own cookies, set by its responses
+	cookieJar bool
+	// followRedirects is the most hops followed per request, 0 to not follow
+	// them, and redirectFailure counts the 3xx responses as failures
+	followRedirects int
+	redirectFailure bool
+
+	// user is the USER:PASSWORD sent with Basic auth, or with Digest auth
+	user   string
+	digest bool
+
+	// templating renders the placeholders of the url, headers and body per
+	// request, with the next row of data
+	templating bool
+	data       *dataFeed
+	// captures set variables of the templates of the next requests of a
+	// worker from the headers of its responses
+	captures []*headerCapture
+
+	// grpc sends the requests as gRPC calls of this type, with grpcMessages
+	// messages per client-stream or bidi call sent at grpcMessageRate per
+	// second, 0 for no limit, and grpcStreams calls sharing each connection
+	grpc            string
+	grpcMessages    int
+	grpcMessageRate float64
+	grpcStreams     int
+	// tcp sends the bodies as is to the tcp:// and tls:// urls
+	tcp *tcpMode
+	// dnsQuery sends DNS queries to the udp://, tcp://, tls:// and https://
+	// urls
+	dnsQuery *dnsMode
+	// mqtt publishes the bodies to the mqtt:// and mqtts:// urls
+	mqtt *mqttMode
+	// redis sends the commands of --redis-command to the redis:// and
+	// rediss:// urls
+	redis *redisMode
+	// kafka produces the bodies to the topics of the kafka:// urls
+	kafka *kafkaMode
+	// sql runs --sql-query on the databases of the postgres:// and mysql://
+	// urls
+	sql *sqlMode
+	// plugged sends the requests through the Backend of --backend
+	plugged *pluggedMode
+	// sse reads the responses as Server-Sent Events streams, counted here
+	sse *sseStats
+	// failures keeps the first failed requests with their responses, nil
+	// without --failures
+	failures *failureSamples
+
+	// phases is set on the single-connection clients of workers
+	phases *phaseTracker
+	// pool counts the connections of the clients
+	pool *connPool
 }
 
 func NewRequester(concurrency int, requests int64, duration time.Duration, reqRate *rate.Limit, errWriter io.Writer, clientOpt *ClientOpt, rampUp int) (*Requester, error) {
@@ -151,13 +376,80 @@ func NewRequester(concurrency int, requests int64, duration time.Duration, reqRa
 		errWriter:   errWriter,
 		clientOpt:   clientOpt,
 		recordChan:  make(chan *ReportRecord, maxResult),
+		ended:       make(chan struct{}),
+		interrupted: make(chan struct{}),
 	}
-	client, header, err := buildRequestClient(clientOpt, &r.readBytes, &r.writeBytes)
-	if err != nil {
-		return nil, err
+	clientOpt.pool = &r.pool
+	r.targets = &targetPool{
+		ejectAfter:    int64(clientOpt.ejectAfter),
+		probeInterval: clientOpt.probeInterval,
+		probeTimeout:  clientOpt.doTimeout,
+		probeBody:     clientOpt.bodyBytes,
+	}
+	if r.targets.probeInterval <= 0 {
+		r.targets.probeInterval = 5 * time.Second
+	}
+	if r.targets.probeTimeout <= 0 {
+		r.targets.probeTimeout = r.targets.probeInterval
+	}
+	r.targets.sequence = clientOpt.sequence
+	slots := make(map[string]int)
+	pipelines := make(map[int]*fasthttp.PipelineClient)
+	for i, u := range clientOpt.urls {
+		opt := clientOpt
+		var tr *targetRequest
+		if i < len(clientOpt.targetRequests) {
+			tr = clientOpt.targetRequests[i]
+			o := *clientOpt
+			// the headers of the flags come last to override the recorded ones
+			o.method, o.headers, o.bodyBytes = tr.Method, append(tr.Headers[:len(tr.Headers):len(tr.Headers)], clientOpt.headers...), tr.Body
+			opt = &o
+		}
+		client, header, err := buildRequestClient(opt, u, &r.readBytes, &r.writeBytes)
+		if err != nil {
+			return nil, err
+		}
+		t := &target{url: u, httpClient: client, httpHeader: header, request: tr}
+		if opt.templating {
+			var row map[string]interface{}
+			if opt.data != nil {
+				row = opt.data.rows[0]
+			}
+			if t.template, err = newRequestTemplate(u, opt.headers, opt.bodyBytes, captureSample(row, opt.captures)); err != nil {
+				return nil, err
+			}
+		}
+		// the targets of a same host share the connections of a worker
+		key := client.Addr
+		if client.IsTLS {
+			key = "https://" + key
+		}
+		slot, ok := slots[key]
+		if !ok {
+			slot = len(slots)
+			slots[key] = slot
+		}
+		t.slot = slot
+		if clientOpt.pipeline > 0 {
+			if pipelines[slot] == nil {
+				pipelines[slot] = newPipelineClient(client, opt, concurrency)
+			}
+			t.pipeline = pipelines[slot]
+		}
+		r.targets.targets = append(r.targets.targets, t)
+	}
+	r.targets.slots = len(slots)
+	if len(r.targets.targets) == 0 {
+		return nil, fmt.Errorf("no request url")
+	}
+	if len(clientOpt.weights) == len(r.targets.targets) {
+		r.targets.schedule = weightSchedule(clientOpt.weights)
+	}
+	if r.protocol = clientOpt.protocol(); r.protocol != nil {
+		if err := r.protocol.setup(r); err != nil {
+			return nil, err
+		}
 	}
-	r.httpClient = client
-	r.httpHeader = header
 	return r, nil
 }
 
@@ -175,54 +467,96 @@ func addMissingPort(addr string, isTLS bool) string {
 
 func buildTLSConfig(opt *ClientOpt) (*tls.Config, error) {
 	var certs []tls.Certificate
-	if opt.certPath != "" && opt.keyPath != "" {
+	if opt.certs == nil && opt.certPath != "" && opt.keyPath != "" {
 		c, err := tls.LoadX509KeyPair(opt.certPath, opt.keyPath)
 		if err != nil {
 			return nil, err
 		}
 		certs = append(certs, c)
 	}
-	return &tls.Config{
+	var roots *x509.CertPool
+	if opt.caCertPath != "" {
+		pem, err := os.ReadFile(opt.caCertPath)
+		if err != nil {
+			return nil, err
+		}
+		roots = x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no PEM certificate found in %s", opt.caCertPath)
+		}
+	}
+	cfg := &tls.Config{
+		ServerName:         opt.sni,
 		InsecureSkipVerify: opt.insecure,
 		Certificates:       certs,
-	}, nil
+		RootCAs:            roots,
+	}
+	if opt.certs != nil {
+		cfg.GetClientCertificate = opt.certs.getClientCertificate
+	}
+	return cfg, nil
 }
 
-func buildRequestClient(opt *ClientOpt, r *int64, w *int64) (*fasthttp.HostClient, *fasthttp.RequestHeader, error) {
-	u, err := url2.Parse(opt.url)
+func buildRequestClient(opt *ClientOpt, rawURL string, r *int64, w *int64) (*fasthttp.HostClient, *fasthttp.RequestHeader, error) {
+	u, err := url2.Parse(rawURL)
 	if err != nil {
 		return nil, nil, err
 	}
 	httpClient := &fasthttp.HostClient{
 		Addr:                          addMissingPort(u.Host, u.Scheme == "https"),
-		IsTLS:                         u.Scheme == "https",
+		IsTLS:                         u.Scheme == "https" || u.Scheme == "tls" || u.Scheme == "mqtts" || u.Scheme == "rediss",
 		Name:                          "plow",
 		MaxConns:                      opt.maxConns,
 		ReadTimeout:                   opt.readTimeout,
 		WriteTimeout:                  opt.writeTimeout,
 		DisableHeaderNamesNormalizing: true,
 	}
-	if opt.socks5Proxy != "" {
-		if !strings.Contains(opt.socks5Proxy, "://") {
-			opt.socks5Proxy = "socks5://" + opt.socks5Proxy
+	proxy := opt.proxy
+	if proxy == "" && len(opt.proxies) > 0 {
+		proxy = opt.proxies[0]
+	}
+	if proxy != "" {
+		if httpClient.Dial, err = proxyDial(proxy, opt.dialTimeout); err != nil {
+			return nil, nil, err
 		}
-		httpClient.Dial = fasthttpproxy.FasthttpSocksDialer(opt.socks5Proxy)
 	} else if opt.unixSocket != "" {
 		httpClient.Dial = func(addr string) (net.Conn, error) {
-			return net.Dial("unix", opt.unixSocket)
+			return net.DialTimeout("unix", opt.unixSocket, opt.dialTimeout)
 		}
-	} else if opt.httpProxy != "" {
-		httpClient.Dial = fasthttpproxy.FasthttpHTTPDialerDualStack(opt.httpProxy)
+	} else if opt.local != nil {
+		httpClient.Dial = opt.local.dial(opt.dialTimeout)
 	} else {
-		httpClient.Dial = fasthttpproxy.FasthttpProxyHTTPDialerTimeout(opt.dialTimeout)
+		// dual stack, as AAAA records may be dialed with --dns-round-robin
+		d := &fasthttpproxy.Dialer{Timeout: opt.dialTimeout, DialDualStack: true}
+		if httpClient.Dial, err = d.GetDialFunc(true); err != nil {
+			return nil, nil, err
+		}
+	}
+	if opt.connectTo != "" && opt.unixSocket == "" {
+		dial, hostAddr := httpClient.Dial, httpClient.Addr
+		// the phase tracker passes the resolved connectTo through
+		httpClient.Dial = func(addr string) (net.Conn, error) {
+			if addr == hostAddr {
+				addr = opt.connectTo
+			}
+			return dial(addr)
+		}
 	}
-	httpClient.Dial = ThroughputInterceptorDial(httpClient.Dial, r, w)
+	if opt.proxyProtocol > 0 {
+		httpClient.Dial = ProxyProtocolDial(httpClient.Dial, opt.proxyProtocol, opt.proxySources)
+	}
+	httpClient.Dial = ThroughputInterceptorDial(httpClient.Dial, r, w, opt.pool)
 
 	tlsConfig, err := buildTLSConfig(opt)
 	if err != nil {
 		return nil, nil, err
 	}
 	httpClient.TLSConfig = tlsConfig
+	if opt.phases != nil {
+		httpClient.Dial = opt.phases.dial(httpClient.Dial, opt, tlsConfig, httpClient.IsTLS)
+	} else if opt.connLimiter != nil {
+		httpClient.Dial = connRateDial(httpClient.Dial, opt.connLimiter)
+	}
 
 	var requestHeader fasthttp.RequestHeader
 	if opt.contentType != "" {
@@ -234,6 +568,9 @@ func buildRequestClient(opt *ClientOpt, r *int64, w *int64) (*fasthttp.HostClien
 		requestHeader.SetHost(u.Host)
 	}
 	requestHeader.SetMethod(opt.method)
+	if opt.disableKeepalive {
+		requestHeader.SetConnectionClose()
+	}
 	requestHeader.SetRequestURI(u.RequestURI())
 	for _, h := range opt.headers {
 		n := strings.SplitN(h, ":", 2)
@@ -242,40 +579,150 @@ func buildRequestClient(opt *ClientOpt, r *int64, w *int64) (*fasthttp.HostClien
 		}
 		requestHeader.Set(n[0], n[1])
 	}
+	if opt.user != "" && !opt.digest {
+		requestHeader.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(opt.user)))
+	}
 
 	return httpClient, &requestHeader, nil
 }
 
+func (r *Requester) Targets() *targetPool {
+	return r.targets
+}
+
+// currentStage returns the index of the running stage, or step of the
+// search, or -1 without stages
+func (r *Requester) currentStage() int {
+	if len(r.stages) == 0 && r.search == nil {
+		return -1
+	}
+	return int(atomic.LoadInt32(&r.stage))
+}
+
+// runStages moves the limiter through the stages, one after another
+func (r *Requester) runStages(ctx context.Context, limiter *rate.Limiter) {
+	for i, st := range r.stages {
+		atomic.StoreInt32(&r.stage, int32(i))
+		if st.Rate == nil && r.reqRate != nil {
+			limiter.SetLimit(*r.reqRate)
+		} else {
+			limiter.SetLimit(st.limit())
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(st.Duration):
+		}
+	}
+}
+
+// Cancel stops the run: no request starts anymore and the ones in flight
+// have the grace period to complete before the records are closed
 func (r *Requester) Cancel() {
-	r.cancel()
+	if r.cancel == nil {
+		return
+	}
+	r.cancelOnce.Do(func() {
+		r.cancel()
+		time.AfterFunc(r.clientOpt.grace, r.closeRecord)
+	})
+}
+
+// Interrupt cancels the run before its end, which is reported as interrupted
+func (r *Requester) Interrupt() {
+	r.interruptOnce.Do(func() {
+		close(r.interrupted)
+	})
+	r.Cancel()
+}
+
+func (r *Requester) Interrupted() <-chan struct{} {
+	return r.interrupted
+}
+
+func (r *Requester) end() {
+	r.endedOnce.Do(func() {
+		close(r.ended)
+	})
 }
 
 func (r *Requester) RecordChan() <-chan *ReportRecord {
 	return r.recordChan
 }
 
+func (r *Requester) Backpressure() *backpressure {
+	return &r.stalls
+}
+
 func (r *Requester) closeRecord() {
+	r.end()
 	r.closeOnce.Do(func() {
 		close(r.recordChan)
 	})
 }
 
-func (r *Requester) DoRequest(req *fasthttp.Request, resp *fasthttp.Response, rr *ReportRecord) {
+// requestDoer sends a request, with the HostClient of a worker or the
+// PipelineClient of --pipeline
+type requestDoer interface {
+	Do(req *fasthttp.Request, resp *fasthttp.Response) error
+	DoTimeout(req *fasthttp.Request, resp *fasthttp.Response, timeout time.Duration) error
+}
+
+func (r *Requester) DoRequest(client requestDoer, pt *phaseTracker, req *fasthttp.Request, resp *fasthttp.Response, rr *ReportRecord) {
 	startTime := time.Unix(0, atomic.LoadInt64(&startTimeUnixNano))
 	t1 := time.Since(startTime)
+	rr.reset()
+	if pt != nil {
+		pt.start()
+	}
 	var err error
 	if r.clientOpt.doTimeout > 0 {
-		err = r.httpClient.DoTimeout(req, resp, r.clientOpt.doTimeout)
+		err = client.DoTimeout(req, resp, r.clientOpt.doTimeout)
 	} else {
-		err = r.httpClient.Do(req, resp)
+		err = client.Do(req, resp)
 	}
+	done := time.Now()
 
+	if pt != nil {
+		// the wait of --conn-rate is out of the latency
+		t1 += pt.queued
+		rr.cold = pt.dialed
+		rr.stale = pt.stale
+		rr.addr = pt.addr
+	}
 	if err != nil {
 		rr.cost = time.Since(startTime) - t1
+		rr.code = 0
 		rr.error = err.Error()
+		rr.errClass = classifyError(err)
+		if rr.errClass == errReadTimeout {
+			rr.errClass = timeoutClass(pt, rr.cost, r.clientOpt.doTimeout)
+		}
 		return
 	}
 
+	rr.serverTimings = parseServerTiming(rr.serverTimings, resp)
+	rr.reqSize, rr.respSize = requestBodySize(req), int64(len(resp.Body()))
+	body := resp.Body()
+	if r.clientOpt.compressed {
+		if body, err = decodedBody(resp); err != nil {
+			rr.cost = time.Since(startTime) - t1
+			rr.code = 0
+			rr.error = err.Error()
+			rr.errClass = errOther
+			return
+		}
+		rr.decodedSize = int64(len(body))
+	}
+	rr.extracted = extractValues(rr.extracted, r.clientOpt.extractors, r.clientOpt.extractRate, body)
+	if r.clientOpt.graphql && resp.StatusCode() == fasthttp.StatusOK {
+		rr.graphqlError = graphqlError(body)
+	}
+	rr.validated, rr.malformed = validateResponse(r.clientOpt.validate, r.clientOpt.validateRate, req, resp)
+	if r.clientOpt.hasher != nil {
+		rr.bodyHash, rr.bodySample = r.clientOpt.hasher.hash(resp.StatusCode(), body)
+	}
+
 	writeTo := io.Discard
 	if resp.StatusCode() >= 500 {
 		writeTo = r.errWriter
@@ -285,13 +732,70 @@ func (r *Requester) DoRequest(req *fasthttp.Request, resp *fasthttp.Response, rr
 	err = resp.BodyWriteTo(writeTo)
 	if err != nil {
 		rr.cost = time.Since(startTime) - t1
+		rr.code = 0
 		rr.error = err.Error()
+		rr.errClass = classifyError(err)
 		return
 	}
 
 	rr.cost = time.Since(startTime) - t1
 	rr.code = resp.StatusCode()
 	rr.error = ""
+	if pt != nil {
+		rr.phases = pt.times(done.Sub(startTime)-t1, done)
+	}
+}
+
+// sendError records a request that failed before being sent
+func (r *Requester) sendError(target int, err error) {
+	rr := recordPool.Get().(*ReportRecord)
+	rr.target = target
+	rr.stage = r.currentStage()
+	rr.cost = 0
+	rr.authCost = 0
+	rr.reset()
+	rr.error = err.Error()
+	rr.errClass = errOther
+	rr.proxy = -1
+	rr.traceID = ""
+	rr.queueWait = 0
+	rr.replayLag = -1
+	rr.readBytes = atomic.LoadInt64(&r.readBytes)
+	rr.writeBytes = atomic.LoadInt64(&r.writeBytes)
+	rr.connsOpened, rr.connsClosed = r.pool.counts()
+	rr.dropped = r.arrivals.droppedCount()
+	rr.concurrencyCount = int(atomic.LoadInt64(&r.workers))
+	r.stalls.send(r.recordChan, rr)
+}
+
+// addWorker starts the next worker, r.workersMu must be held
+func (r *Requester) addWorker() {
+	quit := new(int32)
+	r.spawn(len(r.quits), quit)
+	r.quits = append(r.quits, quit)
+	atomic.StoreInt64(&r.workers, int64(len(r.quits)))
+}
+
+// Adjust grows or shrinks the workers of the running benchmark to n, the
+// last ones stopping after their current request
+func (r *Requester) Adjust(n int) error {
+	if n <= 0 {
+		return fmt.Errorf("concurrency must be positive")
+	}
+	r.workersMu.Lock()
+	defer r.workersMu.Unlock()
+	if r.spawn == nil || r.targets.ctx.Err() != nil || atomic.LoadInt64(&r.active) == 0 {
+		return fmt.Errorf("benchmark is not running")
+	}
+	for len(r.quits) > n {
+		atomic.StoreInt32(r.quits[len(r.quits)-1], 1)
+		r.quits = r.quits[:len(r.quits)-1]
+	}
+	atomic.StoreInt64(&r.workers, int64(len(r.quits)))
+	for len(r.quits) < n {
+		r.addWorker()
+	}
+	return nil
 }
 
 func (r *Requester) Run() {
@@ -302,12 +806,18 @@ func (r *Requester) Run() {
 
 	ctx, cancelFunc := context.WithCancel(context.Background())
 	r.cancel = cancelFunc
+	r.targets.ctx = ctx
 	go func() {
+		<-sigs
+		r.Interrupt()
+		// a second one does not wait for the requests in flight
 		<-sigs
 		r.closeRecord()
-		cancelFunc()
 	}()
 	atomic.StoreInt64(&startTimeUnixNano, time.Now().UnixNano())
+	if d := stagesDuration(r.stages); d > 0 && (r.duration <= 0 || d < r.duration) {
+		r.duration = d
+	}
 	if r.duration > 0 {
 		time.AfterFunc(r.duration, func() {
 			r.closeRecord()
@@ -319,86 +829,305 @@ func (r *Requester) Run() {
 	if r.reqRate != nil {
 		limiter = rate.NewLimiter(*r.reqRate, 1)
 	}
+	if len(r.stages) > 0 {
+		if limiter == nil {
+			limiter = rate.NewLimiter(rate.Inf, 1)
+		}
+		go r.runStages(ctx, limiter)
+	}
+	if r.search != nil {
+		limiter = rate.NewLimiter(rate.Limit(r.search.min), 1)
+		go func() {
+			r.search.run(ctx, limiter, &r.stage)
+			r.closeRecord()
+			cancelFunc()
+		}()
+	}
+	if r.clientOpt.arrival == arrivalPoisson && limiter != nil {
+		r.arrivals = newArrivals(r.clientOpt.maxQueue)
+		go r.arrivals.run(ctx, limiter)
+	}
 
 	semaphore := r.requests
-	if r.rampUp <= 0 {
-		r.rampUp = r.concurrency
-	}
-	concurrencyCount := 0
-	loopCount := int(math.Ceil(float64(r.concurrency) / float64(r.rampUp)))
-	for i := 0; i < loopCount; i++ {
-		for j := 0; j < r.rampUp; j++ {
-			if concurrencyCount > r.concurrency {
-				break
+	// spawn starts a worker, which stops after its request once quit is set
+	spawn := func(worker int, quit *int32) {
+		r.wg.Add(1)
+		atomic.AddInt64(&r.active, 1)
+		go func() {
+			defer func() {
+				atomic.AddInt64(&r.active, -1)
+				r.wg.Done()
+				v := recover()
+				if v != nil && v != sendOnCloseError {
+					panic(v)
+				}
+			}()
+			reqs := make([]*fasthttp.Request, len(r.targets.targets))
+			resp := &fasthttp.Response{}
+			var tmplBuf bytes.Buffer
+			dataCursor := 0
+			var targetCursor uint64
+			// each worker has its own connection per host and proxy, timed by a tracker
+			numProxies := len(r.clientOpt.proxies)
+			if numProxies == 0 {
+				numProxies = 1
 			}
-			concurrencyCount++
-			r.wg.Add(1)
-			go func() {
-				defer func() {
-					r.wg.Done()
-					v := recover()
-					if v != nil && v != sendOnCloseError {
-						panic(v)
+			clients := make([]*fasthttp.HostClient, r.targets.slots*numProxies)
+			trackers := make([]*phaseTracker, len(clients))
+			var auths []*connAuth
+			if r.clientOpt.auth != nil {
+				auths = make([]*connAuth, len(clients))
+			}
+			var jar *cookieJar
+			if r.clientOpt.cookieJar {
+				jar = newCookieJar()
+			}
+			var redirects *redirectFollower
+			if r.clientOpt.followRedirects > 0 {
+				redirects = newRedirectFollower(r)
+			}
+			var calls *grpcCaller
+			if r.clientOpt.grpc != "" {
+				calls = newGRPCCaller(r, worker)
+			}
+			var conns protocolConns
+			var x exchange
+			if r.protocol != nil {
+				conns = r.protocol.conns(r, worker)
+				defer conns.close()
+			}
+			var streams *sseStream
+			if r.clientOpt.sse != nil {
+				streams = newSSEStream(r)
+			}
+			var vars *captureVars
+			if len(r.clientOpt.captures) > 0 {
+				vars = newCaptureVars(r.clientOpt.captures)
+			}
+			var thinkRng *rand.Rand
+			if r.clientOpt.thinkTime != nil {
+				thinkRng = rand.New(rand.NewSource(time.Now().UnixNano() + int64(worker)))
+			}
+
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				default:
+				}
+				if atomic.LoadInt32(quit) != 0 {
+					return
+				}
+
+				var scheduled time.Time
+				if r.arrivals != nil {
+					var ok bool
+					if scheduled, ok = r.arrivals.take(ctx); !ok {
+						return
 					}
-				}()
-				req := &fasthttp.Request{}
-				resp := &fasthttp.Response{}
-				r.httpHeader.CopyTo(&req.Header)
-				if r.httpClient.IsTLS {
-					req.URI().SetScheme("https")
-					req.URI().SetHostBytes(req.Header.Host())
+				} else if limiter != nil {
+					err := limiter.Wait(ctx)
+					if err != nil {
+						continue
+					}
+				}
+
+				if r.requests > 0 && atomic.AddInt64(&semaphore, -1) < 0 {
+					cancelFunc()
+					return
 				}
 
-				for {
-					select {
-					case <-ctx.Done():
+				var entry *logEntry
+				if r.clientOpt.stream != nil {
+					if entry = r.clientOpt.stream.take(ctx); entry == nil {
 						return
-					default:
 					}
+				}
+
+				idx, t := r.targets.Pick(&targetCursor)
+				req := reqs[idx]
+				if req == nil {
+					req = t.newRequest()
+					reqs[idx] = req
+				}
 
-					if limiter != nil {
-						err := limiter.Wait(ctx)
-						if err != nil {
-							continue
-						}
+				if r.clientOpt.jwt != nil {
+					// signed ahead of the request, out of its latency
+					token, err := r.clientOpt.jwt.mint()
+					if err != nil {
+						r.sendError(idx, err)
+						continue
+					}
+					req.Header.Set(r.clientOpt.jwt.header, r.clientOpt.jwt.prefix+token)
+				}
+				if r.clientOpt.oauth2 != nil {
+					// a due token is fetched again out of the latency too
+					token, err := r.clientOpt.oauth2.get()
+					if err != nil {
+						r.sendError(idx, err)
+						continue
 					}
+					req.Header.Set("Authorization", "Bearer "+token)
+				}
 
-					if r.requests > 0 && atomic.AddInt64(&semaphore, -1) < 0 {
-						cancelFunc()
+				var body *pacedBody
+				if r.clientOpt.bodyStream != nil && r.clientOpt.bodyStream.size > 0 {
+					body = r.clientOpt.bodyStream.body(r.ended, nil)
+					req.SetBodyStream(body, -1)
+				} else if r.clientOpt.bodyFile != "" {
+					file, err := os.Open(r.clientOpt.bodyFile)
+					if err != nil {
+						r.sendError(idx, err)
+						continue
+					}
+					body = r.clientOpt.bodyStream.body(r.ended, file)
+					req.SetBodyStream(body, -1)
+				} else if t.request != nil {
+					req.SetBodyRaw(t.request.Body)
+				} else {
+					req.SetBodyRaw(r.clientOpt.bodyBytes)
+				}
+				if entry != nil {
+					r.clientOpt.stream.apply(entry, req, t)
+				}
+				if t.template != nil {
+					var row interface{}
+					if r.clientOpt.data != nil {
+						row = r.clientOpt.data.next(worker, r.concurrency, &dataCursor)
+					}
+					if vars != nil {
+						row = vars.with(row)
+					}
+					if err := t.render(req, &tmplBuf, row); err != nil {
+						r.sendError(idx, err)
+						continue
+					}
+				}
+				if conns != nil {
+					if err := conns.build(); err != nil {
+						r.sendError(idx, err)
+						continue
+					}
+				}
+				if jar != nil {
+					jar.apply(req)
+				}
+				traceID := ""
+				if th := r.clientOpt.traceHeader; th != nil {
+					id := th.id()
+					req.Header.Set(th.name, id)
+					traceID = th.traceID(id)
+				}
+				resp.Reset()
+				rr := recordPool.Get().(*ReportRecord)
+				rr.target = idx
+				rr.traceID = traceID
+				rr.stage = r.currentStage()
+				rr.proxy = r.nextProxy()
+				rr.queueWait = 0
+				if !scheduled.IsZero() {
+					// out of the latency, the request being sent now
+					rr.queueWait = time.Since(scheduled)
+				}
+				rr.replayLag = -1
+				if entry != nil {
+					if due := r.clientOpt.stream.due(entry); !due.IsZero() {
+						rr.replayLag = max(time.Since(due), 0)
+					}
+				}
+				ci := t.slot
+				if rr.proxy > 0 {
+					ci += rr.proxy * r.targets.slots
+				}
+				if clients[ci] == nil {
+					trackers[ci] = &phaseTracker{}
+					clients[ci] = r.workerClient(t, trackers[ci], rr.proxy)
+					if auths != nil {
+						auths[ci] = newConnAuth(r.clientOpt.auth)
+					}
+				}
+				if streams != nil {
+					rr.authCost = 0
+					if !streams.open(ctx, t, req, rr) {
+						recordPool.Put(rr)
 						return
 					}
+				} else if calls != nil {
+					rr.authCost = 0
+					calls.call(t, req, rr)
+				} else if conns != nil {
+					rr.authCost = 0
+					x = exchange{ci: ci, t: t, client: clients[ci], pt: trackers[ci], req: req, resp: resp}
+					conns.do(&x, rr)
+				} else if t.pipeline != nil {
+					rr.authCost = 0
+					r.DoRequest(t.pipeline, nil, req, resp, rr)
+				} else if auths != nil {
+					r.doAuthRequest(auths[ci], clients[ci], trackers[ci], req, resp, rr)
+				} else {
+					rr.authCost = 0
+					r.DoRequest(clients[ci], trackers[ci], req, resp, rr)
+				}
+				if body != nil {
+					rr.reqSize = body.sent
+				}
+				if jar != nil && rr.error == "" {
+					jar.update(req, resp)
+				}
+				if redirects != nil {
+					redirects.follow(clients[ci], req, resp, rr, jar)
+				}
+				if vars != nil && calls == nil && streams == nil && conns == nil && rr.error == "" {
+					vars.update(resp)
+				}
+				r.targets.Report(t, isFailure(rr, r.clientOpt.redirectFailure))
+				if r.clientOpt.failures != nil && calls == nil && streams == nil && conns == nil {
+					r.clientOpt.failures.add(req, resp, rr, r.clientOpt)
+				}
+				rr.readBytes = atomic.LoadInt64(&r.readBytes)
+				rr.writeBytes = atomic.LoadInt64(&r.writeBytes)
+				rr.connsOpened, rr.connsClosed = r.pool.counts()
+				rr.dropped = r.arrivals.droppedCount()
+				rr.concurrencyCount = int(atomic.LoadInt64(&r.workers))
+				r.stalls.send(r.recordChan, rr)
+				if streams != nil {
+					streams.follow(ctx)
+				}
 
-					if r.clientOpt.bodyFile != "" {
-						file, err := os.Open(r.clientOpt.bodyFile)
-						if err != nil {
-							rr := recordPool.Get().(*ReportRecord)
-							rr.cost = 0
-							rr.error = err.Error()
-							rr.readBytes = atomic.LoadInt64(&r.readBytes)
-							rr.writeBytes = atomic.LoadInt64(&r.writeBytes)
-							rr.concurrencyCount = concurrencyCount
-							r.recordChan <- rr
-							continue
-						}
-						req.SetBodyStream(file, -1)
-					} else {
-						req.SetBodyRaw(r.clientOpt.bodyBytes)
-					}
-					resp.Reset()
-					rr := recordPool.Get().(*ReportRecord)
-					r.DoRequest(req, resp, rr)
-					rr.readBytes = atomic.LoadInt64(&r.readBytes)
-					rr.writeBytes = atomic.LoadInt64(&r.writeBytes)
-					rr.concurrencyCount = concurrencyCount
-					r.recordChan <- rr
+				if thinkRng != nil && !r.clientOpt.thinkTime.pause(ctx, thinkRng) {
+					return
 				}
-			}()
+			}
+		}()
+	}
+
+	r.workersMu.Lock()
+	r.spawn = spawn
+	r.workersMu.Unlock()
+
+	if r.rampUp <= 0 {
+		r.rampUp = r.concurrency
+	}
+	concurrencyCount := 0
+	loopCount := int(math.Ceil(float64(r.concurrency) / float64(r.rampUp)))
+	for i := 0; i < loopCount; i++ {
+		r.workersMu.Lock()
+		for j := 0; j < r.rampUp; j++ {
+			if concurrencyCount > r.concurrency {
+				break
+			}
+			concurrencyCount++
+			r.addWorker()
 		}
+		r.workersMu.Unlock()
 		if r.rampUp != r.concurrency {
 			time.Sleep(time.Second)
 		}
 	}
 
 	r.wg.Wait()
+	if r.protocol != nil {
+		r.protocol.teardown()
+	}
 	r.closeRecord()
 }
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/alecthomas/kingpin.v3-unstable"
+	"gopkg.in/yaml.v2"
+)
+
+// scenarioFile is a YAML file of `plow scenario`: the url arguments and flags
+// of a benchmark, with the fragments it includes, e.g. shared headers, auth
+// or endpoint groups
+type scenarioFile struct {
+	Include []scenarioInclude `yaml:"include"`
+	Vars    yaml.MapSlice     `yaml:"vars"`
+	URLs    []string          `yaml:"urls"`
+	Flags   yaml.MapSlice     `yaml:"flags"`
+}
+
+// scenarioInclude is an included fragment, with the vars bound for it only
+type scenarioInclude struct {
+	File string        `yaml:"file"`
+	Vars yaml.MapSlice `yaml:"vars"`
+}
+
+func (inc *scenarioInclude) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if err := unmarshal(&inc.File); err == nil {
+		return nil
+	}
+	type plain scenarioInclude
+	return unmarshal((*plain)(inc))
+}
+
+// scenarioFlag is a flag of the scenario, list ones being repeated
+type scenarioFlag struct {
+	name   string
+	values []string
+	list   bool
+}
+
+type scenario struct {
+	urls  []string
+	flags []*scenarioFlag
+}
+
+var scenarioVarRe = regexp.MustCompile(`\$\{([^}]*)\}`)
+
+// loadScenario reads a scenario file and its fragments into the arguments of
+// the main command line, but for the flags in overridden
+func loadScenario(path string, overridden map[string]bool) ([]string, error) {
+	sc := &scenario{}
+	if err := sc.load(path, nil, nil); err != nil {
+		return nil, err
+	}
+	args := append([]string(nil), sc.urls...)
+	for _, f := range sc.flags {
+		if overridden[f.name] {
+			continue
+		}
+		for _, v := range f.values {
+			switch {
+			case f.list || v != "true" && v != "false":
+				args = append(args, "--"+f.name+"="+v)
+			case v == "true":
+				args = append(args, "--"+f.name)
+			}
+		}
+	}
+	return args, nil
+}
+
+// load adds the urls and flags of the file at path after the ones of its
+// fragments. Its vars are defaults, overridden by the scope of the file
+// including it, and are seen by its fragments but never by its includer or
+// the other fragments. Scalar flags of a file override the ones of its
+// fragments, and list flags and urls add up.
+func (sc *scenario) load(path string, scope map[string]string, stack []string) error {
+	for _, p := range stack {
+		if p == path {
+			return fmt.Errorf("%s: include cycle through %s", stack[0], path)
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var f scenarioFile
+	if err := yaml.UnmarshalStrict(data, &f); err != nil {
+		return fmt.Errorf("%s: %s", path, err)
+	}
+	vars := make(map[string]string, len(scope)+len(f.Vars)+1)
+	for k, v := range scope {
+		vars[k] = v
+	}
+	// the directory of the file, for the paths of its flags
+	vars["dir"] = filepath.Dir(path)
+	for _, kv := range f.Vars {
+		name := fmt.Sprint(kv.Key)
+		if _, ok := scope[name]; ok {
+			continue
+		}
+		if vars[name], err = expandScenarioVars(fmt.Sprint(kv.Value), vars); err != nil {
+			return fmt.Errorf("%s: vars.%s: %s", path, name, err)
+		}
+	}
+
+	stack = append(stack, path)
+	for _, inc := range f.Include {
+		if inc.File == "" {
+			return fmt.Errorf("%s: include without a file", path)
+		}
+		incScope := make(map[string]string, len(vars)+len(inc.Vars))
+		for k, v := range vars {
+			incScope[k] = v
+		}
+		delete(incScope, "dir")
+		for _, kv := range inc.Vars {
+			name := fmt.Sprint(kv.Key)
+			if incScope[name], err = expandScenarioVars(fmt.Sprint(kv.Value), vars); err != nil {
+				return fmt.Errorf("%s: include %s: vars.%s: %s", path, inc.File, name, err)
+			}
+		}
+		file, err := expandScenarioVars(inc.File, vars)
+		if err != nil {
+			return fmt.Errorf("%s: include %s: %s", path, inc.File, err)
+		}
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(filepath.Dir(path), file)
+		}
+		if err := sc.load(file, incScope, stack); err != nil {
+			return err
+		}
+	}
+
+	for _, u := range f.URLs {
+		u, err := expandScenarioVars(u, vars)
+		if err != nil {
+			return fmt.Errorf("%s: urls: %s", path, err)
+		}
+		sc.urls = append(sc.urls, u)
+	}
+	for _, kv := range f.Flags {
+		name := strings.TrimPrefix(fmt.Sprint(kv.Key), "--")
+		var values []string
+		list := false
+		switch v := kv.Value.(type) {
+		case []interface{}:
+			list = true
+			for _, item := range v {
+				values = append(values, fmt.Sprint(item))
+			}
+		case yaml.MapSlice, nil:
+			return fmt.Errorf("%s: flags.%s: a value or a list of values is needed", path, name)
+		default:
+			values = []string{fmt.Sprint(v)}
+		}
+		for i, v := range values {
+			if values[i], err = expandScenarioVars(v, vars); err != nil {
+				return fmt.Errorf("%s: flags.%s: %s", path, name, err)
+			}
+		}
+		sc.setFlag(name, values, list)
+	}
+	return nil
+}
+
+// overriddenFlags returns the flags of args that can't be repeated, which
+// override the ones of a scenario
+func overriddenFlags(args []string) map[string]bool {
+	model := kingpin.CommandLine.Model()
+	res := make(map[string]bool)
+	for _, arg := range args {
+		if arg == "--" {
+			break
+		}
+		var flag *kingpin.ClauseModel
+		if name, ok := strings.CutPrefix(arg, "--"); ok {
+			name, _, _ = strings.Cut(name, "=")
+			if flag = model.FlagByName(name); flag == nil {
+				flag = model.FlagByName(strings.TrimPrefix(name, "no-"))
+			}
+		} else if len(arg) > 1 && arg[0] == '-' {
+			for _, f := range model.Flags {
+				if f.Short == rune(arg[1]) {
+					flag = f
+				}
+			}
+		}
+		if flag != nil && !flag.Cumulative {
+			res[flag.Name] = true
+		}
+	}
+	return res
+}
+
+func (sc *scenario) setFlag(name string, values []string, list bool) {
+	for _, f := range sc.flags {
+		if f.name == name {
+			if list || f.list {
+				f.values, f.list = append(f.values, values...), true
+			} else {
+				f.values = values
+			}
+			return
+		}
+	}
+	sc.flags = append(sc.flags, &scenarioFlag{name: name, values: values, list: list})
+}
+
+// expandScenarioVars replaces the ${name} of s with the vars in scope, and
+// ${env:NAME} with the environment
+func expandScenarioVars(s string, vars map[string]string) (string, error) {
+	var err error
+	res := scenarioVarRe.ReplaceAllStringFunc(s, func(m string) string {
+		name := m[2 : len(m)-1]
+		if env, ok := strings.CutPrefix(name, "env:"); ok {
+			v, ok := os.LookupEnv(env)
+			if !ok && err == nil {
+				err = fmt.Errorf("environment variable %s is not set", env)
+			}
+			return v
+		}
+		v, ok := vars[name]
+		if !ok && err == nil {
+			err = fmt.Errorf("undefined variable ${%s}", name)
+		}
+		return v
+	})
+	return res, err
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// maxSearchSteps bounds the steps of a search whatever its precision
+const maxSearchSteps = 20
+
+// searchMinThroughput is the share of the rate of a step its throughput must
+// reach, the target falling behind the rate failing the step too
+const searchMinThroughput = 0.95
+
+// rateSearch is the --search for the highest rate the target sustains within
+// the thresholds: each step runs the middle rate of the bounds for a
+// --search-step, the bound it passed or failed moving to it, until the bounds
+// are within --search-precision of each other
+type rateSearch struct {
+	min, max   float64
+	step       time.Duration
+	precision  float64 // % of the upper bound
+	thresholds []*Threshold
+
+	mu sync.Mutex
+	// snapshot is the report of the step i once it ran, set by the report
+	snapshot func(i int) *SnapshotReport
+	steps    []*SearchStep
+}
+
+// parseSearch parses the `MIN-MAX` rates of --search, in requests per second
+func parseSearch(spec string, step time.Duration, precision float64, thresholds []*Threshold) (*rateSearch, error) {
+	lo, hi, ok := strings.Cut(spec, "-")
+	min, err1 := strconv.ParseFloat(strings.TrimSpace(lo), 64)
+	max, err2 := strconv.ParseFloat(strings.TrimSpace(hi), 64)
+	if !ok || err1 != nil || err2 != nil || min <= 0 || max <= min {
+		return nil, fmt.Errorf("search %q is not MIN-MAX requests per second, e.g. 100-5000", spec)
+	}
+	if len(thresholds) == 0 {
+		return nil, fmt.Errorf("--search needs the latency and error budget as --threshold, e.g. --threshold 'p99<100ms' --threshold 'error_rate<1%%'")
+	}
+	if step < time.Second {
+		return nil, fmt.Errorf("--search-step %s is shorter than 1s", step)
+	}
+	if precision <= 0 || precision >= 100 {
+		return nil, fmt.Errorf("--search-precision %g is not within 0 and 100%%", precision)
+	}
+	return &rateSearch{min: min, max: max, step: step, precision: precision, thresholds: thresholds}, nil
+}
+
+func (rs *rateSearch) setSnapshot(f func(i int) *SnapshotReport) {
+	rs.mu.Lock()
+	rs.snapshot = f
+	rs.mu.Unlock()
+}
+
+// judge evaluates the thresholds on the step i which ran at limit
+func (rs *rateSearch) judge(i int, limit float64) *SearchStep {
+	rs.mu.Lock()
+	snapshot := rs.snapshot
+	rs.mu.Unlock()
+	ss := newStageStats().snapshot()
+	if snapshot != nil {
+		ss = snapshot(i)
+	}
+	st := &SearchStep{Rate: roundFloat(limit, 3), RPS: roundFloat(ss.RPS, 3), Errors: errorCount(ss),
+		ErrorRate: roundFloat(errorRate(ss)*100, 3), Passed: ss.Count > 0}
+	for _, p := range ss.Percentiles {
+		if p.Percentile == 0.99 {
+			st.P99 = p.Latency
+		}
+	}
+	if ss.RPS < limit*searchMinThroughput {
+		st.Passed = false
+		st.Failed = append(st.Failed, fmt.Sprintf("rps %.1f below %g%% of the rate", ss.RPS, searchMinThroughput*100))
+	}
+	for _, r := range evaluateThresholds(rs.thresholds, ss) {
+		if !r.Passed {
+			st.Passed = false
+			st.Failed = append(st.Failed, fmt.Sprintf("%s (%s)", r.Threshold, r.Actual))
+		}
+	}
+	rs.mu.Lock()
+	rs.steps = append(rs.steps, st)
+	rs.mu.Unlock()
+	return st
+}
+
+// run moves the limiter through the steps of the search, the index of the
+// running one in stage, until the bounds meet or ctx is done
+func (rs *rateSearch) run(ctx context.Context, limiter *rate.Limiter, stage *int32) {
+	lo, hi := rs.min, rs.max
+	for i := 0; i < maxSearchSteps && hi-lo > hi*rs.precision/100; i++ {
+		mid := (lo + hi) / 2
+		atomic.StoreInt32(stage, int32(i))
+		limiter.SetLimit(rate.Limit(mid))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(rs.step):
+		}
+		if rs.judge(i, mid).Passed {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+}
+
+// stages are the steps run so far as stages, with the thresholds of the
+// search, for --capacity-report
+func (rs *rateSearch) stages() []*Stage {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	var stages []*Stage
+	for _, st := range rs.steps {
+		l := rate.Limit(st.Rate)
+		stages = append(stages, &Stage{Duration: rs.step, Rate: &l, rateStr: formatFloat64(st.Rate) + "/s", Thresholds: rs.thresholds})
+	}
+	return stages
+}
+
+func (rs *rateSearch) report() *SearchReport {
+	if rs == nil {
+		return nil
+	}
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	r := &SearchReport{Min: rs.min, Max: rs.max, Steps: append([]*SearchStep(nil), rs.steps...)}
+	for _, st := range rs.steps {
+		if st.Passed && st.Rate > r.Rate {
+			r.Rate = st.Rate
+		}
+	}
+	return r
+}
+
+// SearchReport is the outcome of a --search, Rate being the highest one
+// which passed the thresholds, 0 when none did
+type SearchReport struct {
+	Min   float64
+	Max   float64
+	Rate  float64
+	Steps []*SearchStep
+}
+
+// SearchStep is a step of the search at Rate requests per second, Failed
+// being the thresholds it failed and their actual values
+type SearchStep struct {
+	Rate      float64
+	RPS       float64
+	P99       time.Duration
+	Errors    int64
+	ErrorRate float64 // % of the error_rate threshold, 4xx and 5xx included
+	Passed    bool
+	Failed    []string
+}
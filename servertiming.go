@@ -0,0 +1,99 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// ServerTiming is one metric of a Server-Timing response header
+type ServerTiming struct {
+	Name string
+	Dur  time.Duration
+}
+
+// parseServerTiming appends the metrics with a duration of the Server-Timing
+// headers of resp to dst, e.g. `db;dur=53.2, cache;desc="Cache Read";dur=23.2`.
+// A metric repeated within a response is summed.
+func parseServerTiming(dst []ServerTiming, resp *fasthttp.Response) []ServerTiming {
+	for _, v := range resp.Header.PeekAll("Server-Timing") {
+		for _, metric := range strings.Split(string(v), ",") {
+			params := strings.Split(metric, ";")
+			name := strings.TrimSpace(params[0])
+			if name == "" {
+				continue
+			}
+			dur, ok := -1.0, false
+			for _, p := range params[1:] {
+				k, val, found := strings.Cut(strings.TrimSpace(p), "=")
+				if !found || !strings.EqualFold(strings.TrimSpace(k), "dur") {
+					continue
+				}
+				var err error
+				if dur, err = strconv.ParseFloat(strings.Trim(strings.TrimSpace(val), `"`), 64); err == nil && dur >= 0 {
+					ok = true
+				}
+				break
+			}
+			if !ok {
+				continue
+			}
+			d := time.Duration(dur * float64(time.Millisecond))
+			merged := false
+			for i := range dst {
+				if dst[i].Name == name {
+					dst[i].Dur += d
+					merged = true
+					break
+				}
+			}
+			if !merged {
+				dst = append(dst, ServerTiming{Name: name, Dur: d})
+			}
+		}
+	}
+	return dst
+}
+
+// serverTimingStats aggregates one server-declared phase, along with the
+// client latency of the responses declaring it.
+type serverTimingStats struct {
+	Stats
+	latency float64
+}
+
+// ServerTimingReport is a server-declared phase, Share is its part of the
+// client-measured latency of the responses declaring it.
+type ServerTimingReport struct {
+	Name  string
+	Count int64
+	Mean  time.Duration
+	Max   time.Duration
+	Share float64
+}
+
+func serverTimingReports(m map[string]*serverTimingStats) []*ServerTimingReport {
+	res := make([]*ServerTimingReport, 0, len(m))
+	for name, st := range m {
+		r := &ServerTimingReport{
+			Name:  name,
+			Count: st.count,
+			Mean:  time.Duration(st.Mean()),
+			Max:   time.Duration(st.max),
+		}
+		if st.latency > 0 {
+			r.Share = st.sum / st.latency
+		}
+		res = append(res, r)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Share != res[j].Share {
+			return res[i].Share > res[j].Share
+		}
+		return res[i].Name < res[j].Name
+	})
+	return res
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"gopkg.in/alecthomas/kingpin.v3-unstable"
+)
+
+// testServer is the target of `plow serve-test`, answering after a latency
+// with an error share and a payload of a given size, or an echo of the body.
+type testServer struct {
+	latency   time.Duration
+	jitter    time.Duration
+	errorRate float64 // share of the responses, 0 to 1
+	errorCode int
+	payload   []byte // nil to echo the request body
+
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+func (s *testServer) random() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rnd.Float64()
+}
+
+// Handler answers, the latency, status and size query args overriding the
+// flags for one request, e.g. /?latency=200ms&status=503&size=1024.
+func (s *testServer) Handler(ctx *fasthttp.RequestCtx) {
+	args := ctx.QueryArgs()
+	latency := s.latency
+	if s.jitter > 0 {
+		latency += time.Duration(s.random() * float64(s.jitter))
+	}
+	if v := args.Peek("latency"); len(v) > 0 {
+		if d, err := time.ParseDuration(string(v)); err == nil {
+			latency = d
+		}
+	}
+	status := fasthttp.StatusOK
+	if s.errorRate > 0 && s.random() < s.errorRate {
+		status = s.errorCode
+	}
+	if v, err := args.GetUint("status"); err == nil && v >= 100 && v < 600 {
+		status = v
+	}
+	body := s.payload
+	if v, err := args.GetUint("size"); err == nil {
+		body = testPayload(v)
+	}
+	if body == nil {
+		body = ctx.PostBody()
+	}
+
+	if latency > 0 {
+		time.Sleep(latency)
+	}
+	ctx.SetStatusCode(status)
+	ctx.SetContentType("text/plain; charset=utf-8")
+	ctx.SetBody(body)
+}
+
+// testPayload is a body of size bytes
+func testPayload(size int) []byte {
+	const line = "plow serve-test payload 0123456789abcdefghijklmnopqrstuvwxyz\n"
+	return bytes.Repeat([]byte(line), size/len(line)+1)[:size]
+}
+
+func runServeTestCommand(args []string) {
+	app := kingpin.New("plow serve-test", "Run a local test target with a configurable latency, error rate and payload")
+	listen := app.Flag("listen", "Listen addr of the test server").Default("127.0.0.1:8080").String()
+	latency := app.Flag("latency", "Latency of each response").Default("0s").Duration()
+	jitter := app.Flag("jitter", "Random latency up to this duration added to --latency").Default("0s").Duration()
+	errorRate := app.Flag("error-rate", "Percentage of responses answered with --error-code").Default("0").Float64()
+	errorCode := app.Flag("error-code", "Status code of the error responses").Default("500").Int()
+	size := app.Flag("size", "Size in bytes of the response body, the request body being echoed without it").PlaceHolder("BYTES").Int()
+	app.Version(version)
+	kingpin.MustParse(app.Parse(args))
+
+	if *errorRate < 0 || *errorRate > 100 {
+		errAndExit("--error-rate must be between 0 and 100")
+		return
+	}
+	if *errorCode < 100 || *errorCode > 599 {
+		errAndExit("--error-code must be a valid status code")
+		return
+	}
+	if *latency < 0 || *jitter < 0 || *size < 0 {
+		errAndExit("--latency, --jitter and --size can't be negative")
+		return
+	}
+	s := &testServer{
+		latency:   *latency,
+		jitter:    *jitter,
+		errorRate: *errorRate / 100,
+		errorCode: *errorCode,
+		rnd:       rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	if *size > 0 {
+		s.payload = testPayload(*size)
+	}
+
+	ln, err := net.Listen("tcp", *listen)
+	if err != nil {
+		errAndExit(err.Error())
+		return
+	}
+	desc := fmt.Sprintf("latency %s", s.latency)
+	if s.jitter > 0 {
+		desc += fmt.Sprintf(" +0-%s", s.jitter)
+	}
+	desc += fmt.Sprintf(", %s%% errors (%d)", strconv.FormatFloat(*errorRate, 'f', -1, 64), s.errorCode)
+	if s.payload != nil {
+		desc += fmt.Sprintf(", %d bytes payload", len(s.payload))
+	} else {
+		desc += ", echoing the request body"
+	}
+	fmt.Fprintf(os.Stderr, "plow serve-test is listening on http://%s with %s\n", ln.Addr(), desc)
+	server := fasthttp.Server{Handler: s.Handler, Name: "plow serve-test"}
+	if err := server.Serve(ln); err != nil {
+		errAndExit(err.Error())
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"text/template"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+)
+
+// isSQLURL tells the postgres://, postgresql:// and mysql:// urls of the
+// databases
+func isSQLURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	return err == nil && (u.Scheme == "postgres" || u.Scheme == "postgresql" || u.Scheme == "mysql")
+}
+
+// SQLReport counts the rows of the --sql-query queries, Codes counting their
+// errors by SQLSTATE or MySQL error number
+type SQLReport struct {
+	Pool  int
+	Rows  int64
+	Rate  float64 // rows per second
+	Codes map[string]int64
+}
+
+// sqlMode runs --sql-query with the arguments of --sql-arg rendered per
+// request, on a pool of --sql-pool connections to each database
+type sqlMode struct {
+	query string
+	args  []*template.Template // nil for the arguments without placeholders
+	raw   []string
+	pool  int
+	rows  int64
+	dbs   map[string]*sql.DB // by url
+	stmts map[string]*sql.Stmt
+}
+
+func newSQLMode(query string, args []string, pool int) (*sqlMode, error) {
+	if strings.TrimSpace(query) == "" {
+		return nil, fmt.Errorf("the postgres:// and mysql:// urls need a --sql-query")
+	}
+	if pool < 1 {
+		return nil, fmt.Errorf("--sql-pool must be at least 1")
+	}
+	m := &sqlMode{query: query, raw: args, args: make([]*template.Template, len(args)), pool: pool,
+		dbs: make(map[string]*sql.DB), stmts: make(map[string]*sql.Stmt)}
+	for i, a := range args {
+		tmpl, err := parseTemplate("--sql-arg", a, nil)
+		if err != nil {
+			return nil, err
+		}
+		m.args[i] = tmpl
+	}
+	return m, nil
+}
+
+// sqlDriver returns the driver and its data source name for rawURL
+func sqlDriver(rawURL string) (string, string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", "", err
+	}
+	if u.Scheme != "mysql" {
+		return "postgres", rawURL, nil
+	}
+	cfg := mysql.NewConfig()
+	if u.User != nil {
+		cfg.User = u.User.Username()
+		cfg.Passwd, _ = u.User.Password()
+	}
+	cfg.Net, cfg.Addr, cfg.DBName = "tcp", u.Host, strings.TrimPrefix(u.Path, "/")
+	if u.RawQuery != "" {
+		// the parameters of the driver, e.g. ?tls=true
+		parsed, err := mysql.ParseDSN(cfg.FormatDSN() + "?" + u.RawQuery)
+		if err != nil {
+			return "", "", err
+		}
+		cfg = parsed
+	}
+	return "mysql", cfg.FormatDSN(), nil
+}
+
+// open connects to the databases of targets and prepares the query on
+// them, ahead of the run
+func (m *sqlMode) open(targets *targetPool, opt *ClientOpt) error {
+	for _, t := range targets.targets {
+		if m.dbs[t.url] != nil {
+			continue
+		}
+		driver, dsn, err := sqlDriver(t.url)
+		if err != nil {
+			return err
+		}
+		db, err := sql.Open(driver, dsn)
+		if err != nil {
+			return err
+		}
+		db.SetMaxOpenConns(m.pool)
+		db.SetMaxIdleConns(m.pool)
+		if opt.disableKeepalive {
+			db.SetMaxIdleConns(-1)
+		}
+		m.dbs[t.url] = db
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		stmt, err := db.PrepareContext(ctx, m.query)
+		cancel()
+		if err != nil {
+			m.close()
+			return fmt.Errorf("%s: %w", redactURL(t.url), err)
+		}
+		m.stmts[t.url] = stmt
+	}
+	return nil
+}
+
+// close closes the pools of connections at the end of the run
+func (m *sqlMode) close() {
+	for u, db := range m.dbs {
+		if stmt := m.stmts[u]; stmt != nil {
+			stmt.Close()
+		}
+		db.Close()
+	}
+}
+
+func (m *sqlMode) report(codes map[string]int64, elapsed float64) *SQLReport {
+	rs := &SQLReport{Pool: m.pool, Rows: atomic.LoadInt64(&m.rows)}
+	if elapsed > 0 {
+		rs.Rate = float64(rs.Rows) / elapsed
+	}
+	if len(codes) > 0 {
+		rs.Codes = make(map[string]int64, len(codes))
+		for k, v := range codes {
+			rs.Codes[k] = v
+		}
+	}
+	return rs
+}
+
+// redactURL hides the password of rawURL in the errors
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	return u.Redacted()
+}
+
+// sqlCode is the SQLSTATE of a PostgreSQL error or the number of a MySQL
+// one, "" for the other errors, e.g. of the connection
+func sqlCode(err error) string {
+	var pqErr *pq.Error
+	var myErr *mysql.MySQLError
+	switch {
+	case errors.As(err, &pqErr):
+		return string(pqErr.Code) + " " + pqErr.Code.Name()
+	case errors.As(err, &myErr):
+		return "Error " + strconv.Itoa(int(myErr.Number))
+	}
+	return ""
+}
+
+// sqlQuerier runs the queries of a worker, the connections being the ones
+// of the pools shared by the workers
+type sqlQuerier struct {
+	r    *Requester
+	mode *sqlMode
+	args []interface{}
+	buf  bytes.Buffer
+}
+
+func newSQLQuerier(r *Requester) *sqlQuerier {
+	return &sqlQuerier{r: r, mode: r.clientOpt.sql, args: make([]interface{}, len(r.clientOpt.sql.args))}
+}
+
+// setup connects to the databases and prepares the query ahead of the run
+func (m *sqlMode) setup(r *Requester) error { return m.open(r.targets, r.clientOpt) }
+
+func (m *sqlMode) conns(r *Requester, worker int) protocolConns { return newSQLQuerier(r) }
+
+func (m *sqlMode) teardown() { m.close() }
+
+func (q *sqlQuerier) do(x *exchange, rr *ReportRecord) { q.query(x.t, rr) }
+
+// close leaves the connections to the pools, closed by teardown
+func (q *sqlQuerier) close() {}
+
+// build renders the arguments of the next query, ahead of the request and
+// out of its latency
+func (q *sqlQuerier) build() error {
+	for i, tmpl := range q.mode.args {
+		if tmpl == nil {
+			q.args[i] = q.mode.raw[i]
+			continue
+		}
+		q.buf.Reset()
+		if err := tmpl.Execute(&q.buf, nil); err != nil {
+			return err
+		}
+		q.args[i] = q.buf.String()
+	}
+	return nil
+}
+
+// query runs the query on the database of t and reads its rows, the
+// latency of rr being the time to the last one
+func (q *sqlQuerier) query(t *target, rr *ReportRecord) {
+	opt := q.r.clientOpt
+	rr.reset()
+
+	ctx := context.Background()
+	if opt.doTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opt.doTimeout)
+		defer cancel()
+	}
+	start := time.Now()
+	var n int64
+	rows, err := q.mode.stmts[t.url].QueryContext(ctx, q.args...)
+	if err == nil {
+		for rows.Next() {
+			n++
+		}
+		err = rows.Err()
+		rows.Close()
+	}
+	done := time.Now()
+	rr.cost = done.Sub(start)
+	rr.phases[phaseWait] = rr.cost
+	atomic.AddInt64(&q.mode.rows, n)
+	rr.error = ""
+	if err != nil {
+		rr.error = err.Error()
+		rr.errClass = classifyError(err)
+		rr.rcode = sqlCode(err)
+	}
+}
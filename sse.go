@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// SSEReport counts the events of the --sse streams, the latency of their
+// requests being the time to their first event
+type SSEReport struct {
+	Open       int64 // streams being read
+	Events     int64
+	Rate       float64 // events per second
+	Dropped    int64   // streams ended by the server or broken before the end of the run
+	Reconnects int64   // streams opened again after a drop
+}
+
+// sseStats are the counters of the streams of all the workers, read by the
+// report as the streams outlive their requests
+type sseStats struct {
+	open       int64
+	events     int64
+	dropped    int64
+	reconnects int64
+}
+
+func (s *sseStats) report(elapsed float64) *SSEReport {
+	rs := &SSEReport{
+		Open:       atomic.LoadInt64(&s.open),
+		Events:     atomic.LoadInt64(&s.events),
+		Dropped:    atomic.LoadInt64(&s.dropped),
+		Reconnects: atomic.LoadInt64(&s.reconnects),
+	}
+	if elapsed > 0 {
+		rs.Rate = float64(rs.Events) / elapsed
+	}
+	return rs
+}
+
+// sseMaxLine is the part of a line of the stream read, the fields being
+// told apart by their start
+const sseMaxLine = 4 << 10
+
+// sseStream reads the --sse streams of a worker, one at a time: open sends
+// the request and waits for the first event, then follow reads the others
+// until the stream ends
+type sseStream struct {
+	r       *Requester
+	clients map[int]*http.Client // by slot of the target
+	resp    *http.Response
+	buf     *bufio.Reader
+	lastID  string        // of the last event, sent as Last-Event-ID when reconnecting
+	retry   time.Duration // to wait before reconnecting, set by the server
+	dropped bool          // the last stream was dropped, the next one reconnecting
+}
+
+func newSSEStream(r *Requester) *sseStream {
+	return &sseStream{r: r, clients: make(map[int]*http.Client)}
+}
+
+// client returns the HTTP/1 client of the host of t, dialing like its own
+// connections, e.g. through its proxy or to --connect-to
+func (s *sseStream) client(t *target) *http.Client {
+	if c := s.clients[t.slot]; c != nil {
+		return c
+	}
+	hc := t.httpClient
+	tr := &http.Transport{
+		DialContext: func(context.Context, string, string) (net.Conn, error) {
+			return hc.Dial(hc.Addr)
+		},
+		DisableCompression: true,
+		MaxConnsPerHost:    1,
+	}
+	if hc.IsTLS && hc.TLSConfig != nil {
+		tr.TLSClientConfig = hc.TLSConfig.Clone()
+	}
+	c := &http.Client{Transport: tr, CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	s.clients[t.slot] = c
+	return c
+}
+
+// open sends req as the request of a stream and waits for its first event,
+// the latency of rr being the time to it. It is false when the run ended
+// before, rr not to be recorded.
+func (s *sseStream) open(ctx context.Context, t *target, req *fasthttp.Request, rr *ReportRecord) bool {
+	rr.reset()
+
+	start := time.Now()
+	fail := func(err error) bool {
+		if ctx.Err() != nil {
+			return false
+		}
+		rr.cost = time.Since(start)
+		rr.code = 0
+		rr.error = err.Error()
+		rr.errClass = classifyError(err)
+		return true
+	}
+	scheme := "http"
+	if t.httpClient.IsTLS {
+		scheme = "https"
+	}
+	trace := &httptrace.ClientTrace{GotConn: func(info httptrace.GotConnInfo) { rr.cold = !info.Reused }}
+	hreq, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, trace), string(req.Header.Method()),
+		scheme+"://"+string(req.URI().Host())+string(req.URI().RequestURI()), bytes.NewReader(req.Body()))
+	if err != nil {
+		return fail(err)
+	}
+	req.Header.VisitAll(func(k, v []byte) {
+		switch strings.ToLower(string(k)) {
+		case "host", "content-length", "connection", "transfer-encoding":
+			return
+		}
+		hreq.Header.Add(string(k), string(v))
+	})
+	hreq.Host = string(req.Header.Host())
+	hreq.Header.Set("Accept", "text/event-stream")
+	hreq.Header.Set("Cache-Control", "no-cache")
+	if s.lastID != "" {
+		hreq.Header.Set("Last-Event-ID", s.lastID)
+	}
+	rr.reqSize = int64(len(req.Body()))
+
+	resp, err := s.client(t).Do(hreq)
+	if err != nil {
+		// the error repeats the url
+		if ue, ok := err.(*url.Error); ok {
+			err = ue.Err
+		}
+		return fail(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		n, _ := io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		resp.Body.Close()
+		rr.cost = time.Since(start)
+		rr.code = resp.StatusCode
+		rr.respSize = n
+		rr.error = ""
+		return true
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/event-stream") {
+		resp.Body.Close()
+		return fail(fmt.Errorf("sse: content type %q is not text/event-stream", ct))
+	}
+	s.resp = resp
+	if s.buf == nil {
+		s.buf = bufio.NewReaderSize(resp.Body, sseMaxLine)
+	} else {
+		s.buf.Reset(resp.Body)
+	}
+	atomic.AddInt64(&s.r.clientOpt.sse.open, 1)
+	if err := s.next(); err != nil {
+		s.close(ctx)
+		if err == io.EOF {
+			err = fmt.Errorf("sse: stream ended before its first event")
+		}
+		return fail(err)
+	}
+	rr.cost = time.Since(start)
+	rr.code = resp.StatusCode
+	rr.error = ""
+	if s.dropped {
+		s.dropped = false
+		atomic.AddInt64(&s.r.clientOpt.sse.reconnects, 1)
+	}
+	return true
+}
+
+// follow reads the events of the open stream until it ends, then waits for
+// the retry delay of the server when it was dropped
+func (s *sseStream) follow(ctx context.Context) {
+	if s.resp == nil {
+		return
+	}
+	for s.next() == nil {
+	}
+	if s.close(ctx) && s.retry > 0 {
+		select {
+		case <-ctx.Done():
+		case <-time.After(s.retry):
+		}
+	}
+}
+
+// close closes the open stream, counting it as dropped unless the run ended
+func (s *sseStream) close(ctx context.Context) bool {
+	s.resp.Body.Close()
+	s.resp = nil
+	atomic.AddInt64(&s.r.clientOpt.sse.open, -1)
+	if ctx.Err() != nil {
+		return false
+	}
+	s.dropped = true
+	atomic.AddInt64(&s.r.clientOpt.sse.dropped, 1)
+	return true
+}
+
+// next reads the stream up to the end of its next event, io.EOF when the
+// server ended it
+func (s *sseStream) next() error {
+	data := false
+	for {
+		line, err := s.readLine()
+		if err != nil {
+			return err
+		}
+		if len(line) == 0 {
+			if data {
+				atomic.AddInt64(&s.r.clientOpt.sse.events, 1)
+				return nil
+			}
+			continue
+		}
+		field, value, _ := bytes.Cut(line, []byte(":"))
+		value = bytes.TrimPrefix(value, []byte(" "))
+		switch string(field) {
+		case "data":
+			data = true
+		case "id":
+			if bytes.IndexByte(value, 0) < 0 {
+				s.lastID = string(value)
+			}
+		case "retry":
+			if ms, err := strconv.Atoi(string(value)); err == nil && ms >= 0 {
+				s.retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+}
+
+// readLine reads the next line of the stream without its end, only its first
+// sseMaxLine bytes for a longer one
+func (s *sseStream) readLine() ([]byte, error) {
+	line, err := s.buf.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		// the start of the line tells its field, the rest is skipped
+		line = append([]byte(nil), line...)
+		for err == bufio.ErrBufferFull {
+			_, err = s.buf.ReadSlice('\n')
+		}
+	}
+	if err != nil {
+		if err == io.EOF && len(line) > 0 {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return bytes.TrimRight(line, "\r\n"), nil
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/beorn7/perks/quantile"
+	"golang.org/x/time/rate"
+)
+
+// Stage is one step of a multi-stage run, with its own request rate and
+// latency/error gates evaluated on the stage's data only.
+type Stage struct {
+	Duration   time.Duration
+	Rate       *rate.Limit
+	rateStr    string
+	Thresholds []*Threshold
+}
+
+// parseStage parses `DURATION[,rate=RATE][,THRESHOLD...]`, such as `1m,rate=100/s,p99<200ms,error_rate<1%`
+func parseStage(spec string) (*Stage, error) {
+	parts := strings.Split(spec, ",")
+	d, err := time.ParseDuration(strings.TrimSpace(parts[0]))
+	if err != nil || d <= 0 {
+		return nil, fmt.Errorf("stage %q must start with a positive duration (i.e. 30s,rate=100)", spec)
+	}
+	st := &Stage{Duration: d}
+	for _, p := range parts[1:] {
+		p = strings.TrimSpace(p)
+		if strings.HasPrefix(p, "rate=") {
+			rv := &rateFlagValue{}
+			if err := rv.Set(p[len("rate="):]); err != nil {
+				return nil, fmt.Errorf("stage %q: %s", spec, err)
+			}
+			st.Rate = rv.Limit()
+			st.rateStr = p[len("rate="):]
+			continue
+		}
+		t, err := parseThreshold(p)
+		if err != nil {
+			return nil, fmt.Errorf("stage %q: %s", spec, err)
+		}
+		st.Thresholds = append(st.Thresholds, t)
+	}
+	return st, nil
+}
+
+func parseStages(specs []string) ([]*Stage, error) {
+	var res []*Stage
+	for _, s := range specs {
+		st, err := parseStage(s)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, st)
+	}
+	return res, nil
+}
+
+func stagesDuration(stages []*Stage) time.Duration {
+	var d time.Duration
+	for _, st := range stages {
+		d += st.Duration
+	}
+	return d
+}
+
+func (st *Stage) limit() rate.Limit {
+	if st.Rate == nil {
+		return rate.Inf
+	}
+	return *st.Rate
+}
+
+func (st *Stage) String() string {
+	s := st.Duration.String()
+	if st.rateStr != "" {
+		s += " @ " + st.rateStr
+	}
+	return s
+}
+
+// stageStats aggregates the records sent during one stage
+type stageStats struct {
+	first, last     time.Time
+	latencyStats    Stats
+	latencyQuantile *quantile.Stream
+	codes           map[int]int64
+	errors          map[string]int64
+	graphqlErrors   map[string]int64
+}
+
+func newStageStats() *stageStats {
+	return &stageStats{
+		latencyQuantile: quantile.NewTargeted(quantilesTarget),
+		codes:           make(map[int]int64, 1),
+		errors:          make(map[string]int64, 1),
+		graphqlErrors:   make(map[string]int64),
+	}
+}
+
+func (st *stageStats) collect(r *ReportRecord, now time.Time) {
+	if st.latencyStats.count == 0 {
+		st.first = now.Add(-r.cost)
+	}
+	st.last = now
+	st.latencyStats.Update(float64(r.cost))
+	st.latencyQuantile.Insert(float64(r.cost))
+	if r.code != 0 {
+		st.codes[r.code]++
+	}
+	if r.error != "" {
+		st.errors[r.error]++
+	}
+	if r.graphqlError != "" {
+		st.graphqlErrors[r.graphqlError]++
+	}
+}
+
+func (st *stageStats) snapshot() *SnapshotReport {
+	rs := &SnapshotReport{
+		Elapsed: st.last.Sub(st.first),
+		Count:   st.latencyStats.count,
+		Stats: &struct {
+			Min    time.Duration
+			Mean   time.Duration
+			StdDev time.Duration
+			Max    time.Duration
+		}{time.Duration(st.latencyStats.min), time.Duration(st.latencyStats.Mean()),
+			time.Duration(st.latencyStats.Stddev()), time.Duration(st.latencyStats.max)},
+		Codes:  make(map[string]int64, len(st.codes)),
+		Errors: make(map[string]int64, len(st.errors)),
+	}
+	if rs.Elapsed > 0 {
+		rs.RPS = float64(rs.Count) / rs.Elapsed.Seconds()
+	}
+	for k, v := range st.codes {
+		rs.Codes[httpStatusSectionLabelMap[k/100]] += v
+	}
+	for k, v := range st.errors {
+		rs.Errors[k] = v
+	}
+	if len(st.graphqlErrors) > 0 {
+		rs.GraphQLErrors = make(map[string]int64, len(st.graphqlErrors))
+		for k, v := range st.graphqlErrors {
+			rs.GraphQLErrors[k] = v
+		}
+	}
+	rs.Percentiles = make([]*struct {
+		Percentile float64
+		Latency    time.Duration
+	}, len(quantiles))
+	for i, p := range quantiles {
+		rs.Percentiles[i] = &struct {
+			Percentile float64
+			Latency    time.Duration
+		}{p, time.Duration(st.latencyQuantile.Query(p))}
+	}
+	return rs
+}
+
+// stageSnapshot returns the report of stage i, empty if the stage never ran
+func stageSnapshot(s *SnapshotReport, i int) *SnapshotReport {
+	if i < len(s.Stages) {
+		return s.Stages[i]
+	}
+	return newStageStats().snapshot()
+}
+
+// StageResult is the verdict of one stage
+type StageResult struct {
+	Stage      string             `json:"Stage"`
+	Count      int64              `json:"Count"`
+	RPS        float64            `json:"RPS"`
+	P99        float64            `json:"P99"`
+	Errors     int64              `json:"Errors"`
+	Passed     bool               `json:"Passed"`
+	Thresholds []*ThresholdResult `json:"Thresholds,omitempty"`
+}
+
+func evaluateStages(stages []*Stage, s *SnapshotReport, useSeconds bool) []*StageResult {
+	_, unit := latencyUnit(useSeconds)
+	var res []*StageResult
+	for i, st := range stages {
+		sr := &StageResult{Stage: fmt.Sprintf("#%d %s", i+1, st)}
+		ss := stageSnapshot(s, i)
+		sr.Count = ss.Count
+		sr.RPS = roundFloat(ss.RPS, 3)
+		for _, p := range ss.Percentiles {
+			if p.Percentile == 0.99 {
+				sr.P99 = roundFloat(float64(p.Latency)/unit, 6)
+			}
+		}
+		sr.Errors = errorCount(ss)
+		sr.Thresholds = evaluateThresholds(st.Thresholds, ss)
+		sr.Passed = thresholdsPassed(sr.Thresholds)
+		res = append(res, sr)
+	}
+	return res
+}
+
+func stagesPassed(results []*StageResult) bool {
+	for _, r := range results {
+		if !r.Passed {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"errors"
+	"os"
+
+	"github.com/valyala/fasthttp"
+)
+
+// policies of --stale-conn for the requests failing on an idle connection
+// reused from the pool, e.g. one closed by the keep-alive timeout of the server
+const (
+	staleRetry = "retry"
+	staleError = "error"
+)
+
+// outcomes of a request on a stale connection, in ReportRecord.stale
+const (
+	staleNone = iota
+	staleRetried
+	staleSurfaced
+)
+
+// StaleConnReport counts the requests that failed on a reused connection,
+// either sent again on a new one or reported as errors
+type StaleConnReport struct {
+	Retried  int64
+	Surfaced int64
+}
+
+// retryIf decides whether a failed attempt of the worker client is sent
+// again: only once, and only when it failed on a reused connection with the
+// retry policy. A new connection failing, or a timeout, is never retried.
+func (t *phaseTracker) retryIf(policy string) fasthttp.RetryIfErrFunc {
+	return func(_ *fasthttp.Request, attempts int, err error) (bool, bool) {
+		if attempts > 1 || t.dialed || errors.Is(err, fasthttp.ErrTimeout) || os.IsTimeout(err) {
+			return false, false
+		}
+		if policy == staleError {
+			t.stale = staleSurfaced
+			return false, false
+		}
+		t.stale = staleRetried
+		return false, true
+	}
+}
@@ -0,0 +1,529 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+)
+
+// summarySchemaVersion is the version of the Summary JSON, raised by each
+// change older readers would misread, along a migration from the previous one
+const summarySchemaVersion = 1
+
+// summaryMigrations migrate a Summary JSON of version i to version i+1
+var summaryMigrations = []func(map[string]interface{}) error{
+	// 0 is a summary written before the versioning, the fields of 1 without
+	// its version
+	func(map[string]interface{}) error { return nil },
+}
+
+// Summary is the machine-readable form of a final SnapshotReport,
+// latencies are expressed in LatencyUnit.
+type Summary struct {
+	SchemaVersion   int              `json:"SchemaVersion"`
+	Elapsed         float64          `json:"Elapsed"`
+	Count           int64            `json:"Count"`
+	Codes           map[string]int64 `json:"Codes"`
+	Errors          map[string]int64 `json:"Errors,omitempty"`
+	ErrorClasses    map[string]int64 `json:"ErrorClasses,omitempty"`
+	GraphQLErrors   map[string]int64 `json:"GraphQLErrors,omitempty"`
+	RPS             float64          `json:"RPS"`
+	Concurrency     int              `json:"Concurrency"`
+	ReadThroughput  float64          `json:"ReadThroughput"`
+	WriteThroughput float64          `json:"WriteThroughput"`
+	Aborted         string           `json:"Aborted,omitempty"`
+	Interrupted     bool             `json:"Interrupted,omitempty"`
+	Notes           string           `json:"Notes,omitempty"` // of the run in the web UI
+
+	LatencyUnit string             `json:"LatencyUnit"`
+	Latency     SummaryStats       `json:"Latency"`
+	RpsStats    *SummaryStats      `json:"RpsStats,omitempty"`
+	Percentiles map[string]float64 `json:"Percentiles"`
+	Histograms  []SummaryBin       `json:"Histograms"`
+
+	Ejections    []*SummaryEjection     `json:"Ejections,omitempty"`
+	Auth         *SummaryAuth           `json:"Auth,omitempty"`
+	StaleConns   *StaleConnReport       `json:"StaleConns,omitempty"`
+	Warmup       *SummaryWarmup         `json:"Warmup,omitempty"`
+	Redirects    *RedirectReport        `json:"Redirects,omitempty"`
+	BodySize     *BodySizeReport        `json:"BodySize,omitempty"`
+	Validation   *ValidationReport      `json:"Validation,omitempty"`
+	BodyVariants *BodyVariantsReport    `json:"BodyVariants,omitempty"`
+	Connections  *ConnectionsReport     `json:"Connections,omitempty"`
+	Outliers     *SummaryOutliers       `json:"Outliers,omitempty"`
+	GRPC         *SummaryGRPC           `json:"GRPC,omitempty"`
+	DNS          *DNSReport             `json:"DNS,omitempty"`
+	MQTT         *SummaryMQTT           `json:"MQTT,omitempty"`
+	Redis        *RedisReport           `json:"Redis,omitempty"`
+	Kafka        *KafkaReport           `json:"Kafka,omitempty"`
+	SQL          *SQLReport             `json:"SQL,omitempty"`
+	Backend      *BackendReport         `json:"Backend,omitempty"`
+	SSE          *SSEReport             `json:"SSE,omitempty"`
+	Pipeline     *PipelineReport        `json:"Pipeline,omitempty"`
+	Arrivals     *SummaryArrivals       `json:"Arrivals,omitempty"`
+	Replay       *SummaryReplay         `json:"Replay,omitempty"`
+	Backpressure *SummaryBackpressure   `json:"Backpressure,omitempty"`
+	Client       *ClientReport          `json:"Client,omitempty"`
+	Server       *ServerReport          `json:"Server,omitempty"`
+	Search       *SummarySearch         `json:"Search,omitempty"`
+	ServerTiming []*SummaryServerTiming `json:"ServerTiming,omitempty"`
+	Phases       []*SummaryPhase        `json:"Phases,omitempty"`
+	Targets      []*SummaryTarget       `json:"Targets,omitempty"`
+	Regions      []*SummaryRegion       `json:"Regions,omitempty"`
+	Proxies      []*SummaryProxy        `json:"Proxies,omitempty"`
+	Addresses    []*SummaryAddress      `json:"Addresses,omitempty"`
+	Extracted    []*SummaryExtracted    `json:"Extracted,omitempty"`
+	ClientCerts  []*ClientCertReport    `json:"ClientCerts,omitempty"`
+	Cold         *SummaryConnLatency    `json:"Cold,omitempty"`
+	Warm         *SummaryConnLatency    `json:"Warm,omitempty"`
+	Thresholds   []*ThresholdResult     `json:"Thresholds,omitempty"`
+	Stages       []*StageResult         `json:"Stages,omitempty"`
+}
+
+type SummaryStats struct {
+	Min    float64 `json:"Min"`
+	Mean   float64 `json:"Mean"`
+	StdDev float64 `json:"StdDev"`
+	Max    float64 `json:"Max"`
+}
+
+type SummaryBin struct {
+	Mean  float64 `json:"Mean"`
+	Count int     `json:"Count"`
+}
+
+type SummaryEjection struct {
+	URL     string       `json:"URL"`
+	Windows [][2]float64 `json:"Windows"`
+}
+
+// SummaryAuth is the authentication handshake overhead, not part of Latency
+type SummaryAuth struct {
+	Handshakes int64   `json:"Handshakes"`
+	Mean       float64 `json:"Mean"`
+	Max        float64 `json:"Max"`
+	Total      float64 `json:"Total"`
+}
+
+// SummaryWarmup is the warmup excluded from the summary, Duration in seconds
+type SummaryWarmup struct {
+	Duration float64 `json:"Duration"`
+	Excluded int64   `json:"Excluded"`
+	Stable   bool    `json:"Stable"`
+	Auto     bool    `json:"Auto"`
+}
+
+// SummaryOutliers is the latency spikes of the run, times in seconds and
+// Period 0 when they aren't periodic
+type SummaryOutliers struct {
+	Threshold float64                  `json:"Threshold"`
+	Period    float64                  `json:"Period"`
+	Jitter    float64                  `json:"Jitter"`
+	Clusters  []*SummaryOutlierCluster `json:"Clusters"`
+}
+
+// SummaryOutlierCluster is a spike, Start being the elapsed time of the run
+type SummaryOutlierCluster struct {
+	Start    float64 `json:"Start"`
+	Duration float64 `json:"Duration"`
+	Peak     float64 `json:"Peak"`
+	Count    int64   `json:"Count"`
+}
+
+// SummaryServerTiming is a phase declared by the server in Server-Timing,
+// Share is its fraction of the latency of the responses declaring it.
+type SummaryServerTiming struct {
+	Name  string  `json:"Name"`
+	Count int64   `json:"Count"`
+	Mean  float64 `json:"Mean"`
+	Max   float64 `json:"Max"`
+	Share float64 `json:"Share"`
+}
+
+// SummaryPhase is the time spent in DNS, Connect, TLS, Wait or Transfer
+type SummaryPhase struct {
+	Name string  `json:"Name"`
+	Mean float64 `json:"Mean"`
+	Max  float64 `json:"Max"`
+}
+
+// SummaryConnLatency is the latency of the requests that opened their
+// connection (Cold) or reused one (Warm)
+type SummaryConnLatency struct {
+	Count       int64              `json:"Count"`
+	Mean        float64            `json:"Mean"`
+	Percentiles map[string]float64 `json:"Percentiles"`
+}
+
+// SummaryGRPC is the messages of the gRPC calls, Latency being the one of
+// each message
+type SummaryGRPC struct {
+	Type     string              `json:"Type"`
+	Sent     int64               `json:"Sent"`
+	Received int64               `json:"Received"`
+	Rate     float64             `json:"Rate"`
+	Latency  *SummaryConnLatency `json:"Latency,omitempty"`
+	Codes    map[string]int64    `json:"Codes,omitempty"` // by grpc-status or RST_STREAM code
+}
+
+// SummaryMQTT is the delivery of the messages to the subscribers of
+// --mqtt-subscribe, Latency being the time from their publish
+type SummaryMQTT struct {
+	QoS       int                 `json:"QoS"`
+	Delivered int64               `json:"Delivered"`
+	Rate      float64             `json:"Rate"`
+	Latency   *SummaryConnLatency `json:"Latency,omitempty"`
+}
+
+// SummaryArrivals is the open-loop arrivals of --arrival poisson, QueueWait
+// being their wait for a free connection and Response their latency from the
+// time they were scheduled at
+type SummaryArrivals struct {
+	Model     string              `json:"Model"`
+	MaxQueue  int                 `json:"MaxQueue"`
+	Dropped   int64               `json:"Dropped"`
+	QueueWait *SummaryConnLatency `json:"QueueWait,omitempty"`
+	Response  *SummaryConnLatency `json:"Response,omitempty"`
+}
+
+// SummaryReplay is how closely the --replay-speed replay kept the times of
+// the access log, Lag being the delay of its requests behind their time
+type SummaryReplay struct {
+	Speed     float64             `json:"Speed"`
+	Entries   int                 `json:"Entries"`
+	Sent      int64               `json:"Sent"`
+	OnTime    int64               `json:"OnTime"`
+	Tolerance float64             `json:"Tolerance"`
+	Lag       *SummaryConnLatency `json:"Lag,omitempty"`
+	MaxLag    float64             `json:"MaxLag"`
+}
+
+// SummaryBackpressure is the records which waited for the report to collect
+// them, StallTime being their total wait in seconds, during which their
+// connections sent no request
+type SummaryBackpressure struct {
+	Stalled   int64   `json:"Stalled"`
+	StallTime float64 `json:"StallTime"`
+}
+
+// SummarySearch is the outcome of a --search, Rate being the highest rate in
+// requests per second which passed the thresholds, 0 when none did
+type SummarySearch struct {
+	Min   float64              `json:"Min"`
+	Max   float64              `json:"Max"`
+	Rate  float64              `json:"Rate"`
+	Steps []*SummarySearchStep `json:"Steps"`
+}
+
+// SummarySearchStep is a step of the search, P99 in the latency unit
+type SummarySearchStep struct {
+	Rate      float64  `json:"Rate"`
+	RPS       float64  `json:"RPS"`
+	P99       float64  `json:"P99"`
+	Errors    int64    `json:"Errors"`
+	ErrorRate float64  `json:"ErrorRate"` // %, 4xx and 5xx included
+	Passed    bool     `json:"Passed"`
+	Failed    []string `json:"Failed,omitempty"`
+}
+
+// SummaryProxy is the outcome of the requests sent through one proxy
+type SummaryProxy struct {
+	Proxy  string  `json:"Proxy"`
+	Count  int64   `json:"Count"`
+	Errors int64   `json:"Errors"`
+	Mean   float64 `json:"Mean"`
+	Max    float64 `json:"Max"`
+}
+
+// SummaryTarget is the outcome of the requests sent to one url of the mix
+type SummaryTarget struct {
+	URL    string  `json:"URL"`
+	Weight int     `json:"Weight,omitempty"`
+	Count  int64   `json:"Count"`
+	RPS    float64 `json:"RPS"`
+	Errors int64   `json:"Errors"`
+	Mean   float64 `json:"Mean"`
+	P90    float64 `json:"P90"`
+	P99    float64 `json:"P99"`
+	Max    float64 `json:"Max"`
+}
+
+// SummaryRegion is the outcome of the requests sent by the agents of a region
+type SummaryRegion struct {
+	Region string  `json:"Region"`
+	Agents int     `json:"Agents"`
+	Count  int64   `json:"Count"`
+	RPS    float64 `json:"RPS"`
+	Errors int64   `json:"Errors"`
+	Mean   float64 `json:"Mean"`
+	P50    float64 `json:"P50"`
+	P90    float64 `json:"P90"`
+	P99    float64 `json:"P99"`
+	Max    float64 `json:"Max"`
+}
+
+// SummaryAddress is the outcome of the requests sent to one ip of the targets
+type SummaryAddress struct {
+	Addr   string  `json:"Addr"`
+	Count  int64   `json:"Count"`
+	Errors int64   `json:"Errors"`
+	Mean   float64 `json:"Mean"`
+	Max    float64 `json:"Max"`
+}
+
+// SummaryExtracted is a field extracted from the JSON responses, Series
+// holding its mean of each second as [elapsed seconds, value] pairs
+type SummaryExtracted struct {
+	Name   string       `json:"Name"`
+	Path   string       `json:"Path"`
+	Count  int64        `json:"Count"`
+	Min    float64      `json:"Min"`
+	Mean   float64      `json:"Mean"`
+	Max    float64      `json:"Max"`
+	Last   float64      `json:"Last"`
+	Series [][2]float64 `json:"Series"`
+}
+
+func latencyUnit(useSeconds bool) (string, float64) {
+	if useSeconds {
+		return "s", float64(time.Second)
+	}
+	return "ms", float64(time.Millisecond)
+}
+
+func roundFloat(f float64, digits int) float64 {
+	p := math.Pow10(digits)
+	return math.Round(f*p) / p
+}
+
+func NewSummary(snapshot *SnapshotReport, useSeconds bool) *Summary {
+	unitName, unit := latencyUnit(useSeconds)
+	lat := func(d time.Duration) float64 {
+		return roundFloat(float64(d)/unit, 6)
+	}
+
+	s := &Summary{
+		SchemaVersion:   summarySchemaVersion,
+		Elapsed:         roundFloat(snapshot.Elapsed.Seconds(), 3),
+		Count:           snapshot.Count,
+		Codes:           snapshot.Codes,
+		Errors:          snapshot.Errors,
+		ErrorClasses:    snapshot.ErrorClasses,
+		GraphQLErrors:   snapshot.GraphQLErrors,
+		RPS:             roundFloat(snapshot.RPS, 3),
+		Concurrency:     snapshot.concurrencyCount,
+		ReadThroughput:  roundFloat(snapshot.ReadThroughput, 3),
+		WriteThroughput: roundFloat(snapshot.WriteThroughput, 3),
+		Aborted:         snapshot.Aborted,
+		Interrupted:     snapshot.Interrupted,
+		LatencyUnit:     unitName,
+		Latency: SummaryStats{
+			Min:    lat(snapshot.Stats.Min),
+			Mean:   lat(snapshot.Stats.Mean),
+			StdDev: lat(snapshot.Stats.StdDev),
+			Max:    lat(snapshot.Stats.Max),
+		},
+		Percentiles: make(map[string]float64, len(snapshot.Percentiles)),
+	}
+	if snapshot.RpsStats != nil {
+		s.RpsStats = &SummaryStats{
+			Min:    roundFloat(snapshot.RpsStats.Min, 2),
+			Mean:   roundFloat(snapshot.RpsStats.Mean, 2),
+			StdDev: roundFloat(snapshot.RpsStats.StdDev, 2),
+			Max:    roundFloat(snapshot.RpsStats.Max, 2),
+		}
+	}
+	for _, p := range snapshot.Percentiles {
+		s.Percentiles["P"+formatFloat64(p.Percentile*100)] = lat(p.Latency)
+	}
+	for _, h := range snapshot.Histograms {
+		s.Histograms = append(s.Histograms, SummaryBin{Mean: lat(h.Mean), Count: h.Count})
+	}
+	for _, e := range snapshot.Ejections {
+		se := &SummaryEjection{URL: e.URL}
+		for _, w := range e.Windows {
+			end := -1.0
+			if w[1] >= 0 {
+				end = roundFloat(w[1].Seconds(), 3)
+			}
+			se.Windows = append(se.Windows, [2]float64{roundFloat(w[0].Seconds(), 3), end})
+		}
+		s.Ejections = append(s.Ejections, se)
+	}
+	if a := snapshot.Auth; a != nil {
+		s.Auth = &SummaryAuth{Handshakes: a.Handshakes, Mean: lat(a.Mean), Max: lat(a.Max), Total: lat(a.Total)}
+	}
+	s.StaleConns = snapshot.StaleConns
+	if w := snapshot.Warmup; w != nil {
+		s.Warmup = &SummaryWarmup{Duration: roundFloat(w.Duration.Seconds(), 3), Excluded: w.Excluded, Stable: w.Stable, Auto: w.Auto}
+	}
+	s.Redirects = snapshot.Redirects
+	s.Validation = snapshot.Validation
+	if bv := snapshot.BodyVariants; bv != nil {
+		s.BodyVariants = &BodyVariantsReport{Hashed: bv.Hashed, Distinct: bv.Distinct}
+		for _, v := range bv.Variants {
+			c := *v
+			c.Share = roundFloat(c.Share, 4)
+			s.BodyVariants.Variants = append(s.BodyVariants.Variants, &c)
+		}
+	}
+	if c := snapshot.Connections; c != nil {
+		s.Connections = &ConnectionsReport{Opened: c.Opened, Rate: roundFloat(c.Rate, 3)}
+	}
+	if o := snapshot.Outliers; o != nil {
+		s.Outliers = &SummaryOutliers{Threshold: lat(o.Threshold), Period: roundFloat(o.Period.Seconds(), 1), Jitter: roundFloat(o.Jitter.Seconds(), 1)}
+		for _, c := range o.Clusters {
+			s.Outliers.Clusters = append(s.Outliers.Clusters, &SummaryOutlierCluster{
+				Start: roundFloat(c.Start.Seconds(), 1), Duration: roundFloat(c.Duration.Seconds(), 1), Peak: lat(c.Peak), Count: c.Count,
+			})
+		}
+	}
+	if b := snapshot.BodySize; b != nil {
+		s.BodySize = &BodySizeReport{Request: b.Request, Response: b.Response, Histogram: b.Histogram}
+		s.BodySize.Request.Mean = roundFloat(b.Request.Mean, 1)
+		s.BodySize.Response.Mean = roundFloat(b.Response.Mean, 1)
+		if b.Decoded != nil {
+			decoded := *b.Decoded
+			decoded.Mean = roundFloat(decoded.Mean, 1)
+			s.BodySize.Decoded, s.BodySize.Ratio = &decoded, roundFloat(b.Ratio, 3)
+		}
+	}
+	for _, st := range snapshot.ServerTiming {
+		s.ServerTiming = append(s.ServerTiming, &SummaryServerTiming{
+			Name: st.Name, Count: st.Count, Mean: lat(st.Mean), Max: lat(st.Max), Share: roundFloat(st.Share, 4),
+		})
+	}
+	connLatency := func(r *ConnLatencyReport) *SummaryConnLatency {
+		if r == nil {
+			return nil
+		}
+		c := &SummaryConnLatency{Count: r.Count, Mean: lat(r.Mean), Percentiles: make(map[string]float64, len(quantiles))}
+		for i, q := range quantiles {
+			c.Percentiles["P"+formatFloat64(q*100)] = lat(r.Percentiles[i])
+		}
+		return c
+	}
+	s.Cold, s.Warm = connLatency(snapshot.Cold), connLatency(snapshot.Warm)
+	if g := snapshot.GRPC; g != nil {
+		s.GRPC = &SummaryGRPC{Type: g.Type, Sent: g.Sent, Received: g.Received, Rate: roundFloat(g.Rate, 3), Latency: connLatency(g.Latency), Codes: g.Codes}
+	}
+	s.DNS = snapshot.DNS
+	if m := snapshot.MQTT; m != nil {
+		s.MQTT = &SummaryMQTT{QoS: m.QoS, Delivered: m.Delivered, Rate: roundFloat(m.Rate, 3), Latency: connLatency(m.Latency)}
+	}
+	if r := snapshot.Redis; r != nil {
+		redis := *r
+		redis.Rate = roundFloat(redis.Rate, 3)
+		s.Redis = &redis
+	}
+	if k := snapshot.Kafka; k != nil {
+		kafka := *k
+		kafka.Rate = roundFloat(kafka.Rate, 3)
+		s.Kafka = &kafka
+	}
+	if q := snapshot.SQL; q != nil {
+		sql := *q
+		sql.Rate = roundFloat(sql.Rate, 3)
+		s.SQL = &sql
+	}
+	s.Backend = snapshot.Backend
+	if p := snapshot.Pipeline; p != nil {
+		pipeline := *p
+		pipeline.RPSPerConn = roundFloat(pipeline.RPSPerConn, 3)
+		s.Pipeline = &pipeline
+	}
+	if e := snapshot.SSE; e != nil {
+		sse := *e
+		sse.Rate = roundFloat(sse.Rate, 3)
+		s.SSE = &sse
+	}
+	if a := snapshot.Arrivals; a != nil {
+		s.Arrivals = &SummaryArrivals{Model: a.Model, MaxQueue: a.MaxQueue, Dropped: a.Dropped, QueueWait: connLatency(a.QueueWait), Response: connLatency(a.Response)}
+	}
+	if b := snapshot.Backpressure; b != nil {
+		s.Backpressure = &SummaryBackpressure{Stalled: b.Stalled, StallTime: roundFloat(b.StallTime.Seconds(), 3)}
+	}
+	s.Client, s.Server = snapshot.Client, snapshot.Server
+	if r := snapshot.Search; r != nil {
+		s.Search = &SummarySearch{Min: r.Min, Max: r.Max, Rate: r.Rate}
+		_, unit := latencyUnit(useSeconds)
+		for _, st := range r.Steps {
+			s.Search.Steps = append(s.Search.Steps, &SummarySearchStep{
+				Rate: st.Rate, RPS: st.RPS, P99: roundFloat(float64(st.P99)/unit, 6), Errors: st.Errors, ErrorRate: st.ErrorRate, Passed: st.Passed, Failed: st.Failed,
+			})
+		}
+	}
+	if r := snapshot.Replay; r != nil {
+		s.Replay = &SummaryReplay{
+			Speed: r.Speed, Entries: r.Entries, Sent: r.Sent, OnTime: r.OnTime,
+			Tolerance: lat(replayTolerance), Lag: connLatency(r.Lag), MaxLag: lat(r.MaxLag),
+		}
+	}
+	for _, t := range snapshot.Targets {
+		s.Targets = append(s.Targets, &SummaryTarget{
+			URL: t.URL, Weight: t.Weight, Count: t.Count, RPS: roundFloat(t.RPS, 3), Errors: t.Errors,
+			Mean: lat(t.Mean), P90: lat(t.P90), P99: lat(t.P99), Max: lat(t.Max),
+		})
+	}
+	for _, r := range snapshot.Regions {
+		s.Regions = append(s.Regions, &SummaryRegion{
+			Region: r.Region, Agents: r.Agents, Count: r.Count, RPS: roundFloat(r.RPS, 3), Errors: r.Errors,
+			Mean: lat(r.Mean), P50: lat(r.P50), P90: lat(r.P90), P99: lat(r.P99), Max: lat(r.Max),
+		})
+	}
+	for _, px := range snapshot.Proxies {
+		s.Proxies = append(s.Proxies, &SummaryProxy{Proxy: px.Proxy, Count: px.Count, Errors: px.Errors, Mean: lat(px.Mean), Max: lat(px.Max)})
+	}
+	if len(snapshot.Addresses) > 1 {
+		for _, a := range snapshot.Addresses {
+			s.Addresses = append(s.Addresses, &SummaryAddress{Addr: a.Addr, Count: a.Count, Errors: a.Errors, Mean: lat(a.Mean), Max: lat(a.Max)})
+		}
+	}
+	s.ClientCerts = snapshot.ClientCerts
+	for _, e := range snapshot.Extracted {
+		se := &SummaryExtracted{Name: e.Name, Path: e.Path, Count: e.Count, Min: e.Min, Mean: roundFloat(e.Mean, 6), Max: e.Max, Last: e.Last, Series: [][2]float64{}}
+		for _, pt := range e.Series {
+			se.Series = append(se.Series, [2]float64{roundFloat(pt.Elapsed.Seconds(), 1), roundFloat(pt.Value, 6)})
+		}
+		s.Extracted = append(s.Extracted, se)
+	}
+	for _, p := range snapshot.Phases {
+		s.Phases = append(s.Phases, &SummaryPhase{Name: p.Name, Mean: lat(p.Mean), Max: lat(p.Max)})
+	}
+	return s
+}
+
+// readSummary reads a Summary JSON written by this plow or an older one,
+// migrated to the current version
+func readSummary(data []byte) (*Summary, error) {
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("invalid summary: %s", err)
+	}
+	if _, ok := m["Count"]; !ok {
+		return nil, fmt.Errorf("not a plow summary, which --summary-json writes")
+	}
+	version := 0
+	if v, ok := m["SchemaVersion"].(float64); ok {
+		version = int(v)
+	}
+	if version > summarySchemaVersion {
+		return nil, fmt.Errorf("summary version %d is newer than this plow (%d)", version, summarySchemaVersion)
+	}
+	for ; version < summarySchemaVersion; version++ {
+		if err := summaryMigrations[version](m); err != nil {
+			return nil, fmt.Errorf("migrating summary version %d: %s", version, err)
+		}
+	}
+	m["SchemaVersion"] = summarySchemaVersion
+	migrated, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	var s Summary
+	if err := json.Unmarshal(migrated, &s); err != nil {
+		return nil, fmt.Errorf("invalid summary: %s", err)
+	}
+	return &s, nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import "testing"
+
+func TestReadSummary(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  string
+		count int64
+		err   bool
+	}{
+		{"current", `{"SchemaVersion":1,"Count":10,"RPS":5.5,"LatencyUnit":"ms","Percentiles":{"P99":12.5}}`, 10, false},
+		{"before the versioning", `{"Count":20,"RPS":5.5,"LatencyUnit":"ms","Percentiles":{"P99":12.5}}`, 20, false},
+		{"newer", `{"SchemaVersion":2,"Count":10}`, 0, true},
+		{"not a summary", `{"Summary":{"Count":10}}`, 0, true},
+		{"not json", `Benchmarking http://a/`, 0, true},
+		{"invalid field", `{"SchemaVersion":1,"Count":"ten"}`, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := readSummary([]byte(tt.data))
+			if (err != nil) != tt.err {
+				t.Fatalf("error = %v, want an error %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			if s.SchemaVersion != summarySchemaVersion || s.Count != tt.count {
+				t.Errorf("got version %d and count %d, want %d and %d", s.SchemaVersion, s.Count, summarySchemaVersion, tt.count)
+			}
+			if p99, ok := summaryP99(s); !ok || p99.Microseconds() != 12500 {
+				t.Errorf("got the P99 %v, want 12.5ms", p99)
+			}
+		})
+	}
+}
+
+// TestSummaryMigrations checks that each older version has its migration
+func TestSummaryMigrations(t *testing.T) {
+	if len(summaryMigrations) != summarySchemaVersion {
+		t.Errorf("%d migrations for the version %d", len(summaryMigrations), summarySchemaVersion)
+	}
+}
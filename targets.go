@@ -0,0 +1,288 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// target is one of the request urls a benchmark rotates through
+type target struct {
+	url        string
+	httpClient *fasthttp.HostClient
+	pipeline   *fasthttp.PipelineClient // of --pipeline, shared by the workers
+	httpHeader *fasthttp.RequestHeader
+	template   *requestTemplate // nil without placeholders
+	request    *targetRequest   // nil for the method, headers and body of the flags
+	// slot is the index of the host of url, whose connection the targets share
+	slot int
+
+	failures int64
+	ejected  int32
+
+	lock      sync.Mutex
+	ejections [][2]time.Duration
+}
+
+func (t *target) newRequest() *fasthttp.Request {
+	req := &fasthttp.Request{}
+	t.httpHeader.CopyTo(&req.Header)
+	if t.httpClient.IsTLS {
+		req.URI().SetScheme("https")
+		req.URI().SetHostBytes(req.Header.Host())
+	}
+	return req
+}
+
+// setRequestURI sets the path and query of req, keeping the scheme and host
+func (t *target) setRequestURI(req *fasthttp.Request, uri []byte) {
+	req.SetRequestURIBytes(uri)
+	if t.httpClient.IsTLS {
+		req.URI().SetScheme("https")
+		req.URI().SetHostBytes(req.Header.Host())
+	}
+}
+
+// targetPool picks targets in round-robin order, skipping the ejected ones
+type targetPool struct {
+	targets []*target
+	next    uint64
+	// schedule is the order of the target indexes of a weighted mix, nil
+	// to take every target in turn
+	schedule []int
+	// sequence has each worker take the targets in order on its own
+	sequence bool
+	slots    int
+
+	ejectAfter    int64
+	probeInterval time.Duration
+	probeTimeout  time.Duration
+	probeBody     []byte
+
+	ctx context.Context
+}
+
+// Pick returns the next target, cursor being the one of the worker in
+// sequence mode
+func (p *targetPool) Pick(cursor *uint64) (int, *target) {
+	if p.schedule != nil {
+		return p.pickWeighted()
+	}
+	n := uint64(len(p.targets))
+	var start uint64
+	if p.sequence {
+		start = *cursor
+		*cursor++
+	} else {
+		start = atomic.AddUint64(&p.next, 1) - 1
+	}
+	for i := uint64(0); i < n; i++ {
+		idx := int((start + i) % n)
+		t := p.targets[idx]
+		if atomic.LoadInt32(&t.ejected) == 0 {
+			return idx, t
+		}
+	}
+	// every target is ejected, keep sending rather than stalling the run
+	idx := int(start % n)
+	return idx, p.targets[idx]
+}
+
+func (p *targetPool) pickWeighted() (int, *target) {
+	n := uint64(len(p.schedule))
+	start := atomic.AddUint64(&p.next, 1) - 1
+	for i := uint64(0); i < n; i++ {
+		idx := p.schedule[(start+i)%n]
+		t := p.targets[idx]
+		if atomic.LoadInt32(&t.ejected) == 0 {
+			return idx, t
+		}
+	}
+	idx := p.schedule[start%n]
+	return idx, p.targets[idx]
+}
+
+// weightSchedule spreads the targets over a cycle of the sum of weights,
+// reduced by their gcd, in smooth weighted round-robin order, so that a
+// target of weight 70 out of 100 doesn't get its requests in one burst.
+func weightSchedule(weights []int) []int {
+	g := 0
+	for _, w := range weights {
+		g = gcd(g, w)
+	}
+	total := 0
+	for _, w := range weights {
+		total += w / g
+	}
+	schedule := make([]int, 0, total)
+	current := make([]int, len(weights))
+	for len(schedule) < total {
+		best := 0
+		for i, w := range weights {
+			current[i] += w / g
+			if current[i] > current[best] {
+				best = i
+			}
+		}
+		current[best] -= total
+		schedule = append(schedule, best)
+	}
+	return schedule
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// parseTargetMix reads the url arguments, each one holding one or more
+// comma-separated urls with an optional weight, e.g.
+// `http://host/api/list:70, /api/detail:25, /api/write:5`. A path alone takes
+// the scheme and host of the url before it. Weights are nil without any, and
+// default to 1 otherwise.
+func parseTargetMix(args []string) ([]string, []int, error) {
+	var urls []string
+	var weights []int
+	weighted := false
+	base := ""
+	for _, arg := range args {
+		for _, entry := range splitTargetMix(arg) {
+			u, w := entry, 1
+			if i := strings.LastIndexByte(entry, ':'); i > 0 && hasURLPath(entry[:i]) {
+				if n, err := strconv.Atoi(entry[i+1:]); err == nil {
+					if n <= 0 {
+						return nil, nil, fmt.Errorf("invalid weight of %s: must be positive", entry)
+					}
+					u, w, weighted = entry[:i], n, true
+				}
+			}
+			if strings.HasPrefix(u, "/") {
+				if base == "" {
+					return nil, nil, fmt.Errorf("%s: a path needs a full url before it", u)
+				}
+				u = base + u
+			} else if i := strings.Index(u, "://"); i >= 0 {
+				base = u
+				if j := strings.IndexByte(u[i+3:], '/'); j >= 0 {
+					base = u[:i+3+j]
+				}
+			}
+			urls = append(urls, u)
+			weights = append(weights, w)
+		}
+	}
+	if !weighted {
+		weights = nil
+	}
+	return urls, weights, nil
+}
+
+// splitTargetMix splits s on the commas followed by a url or a path, the
+// other ones belonging to a url, e.g. in its query
+func splitTargetMix(s string) []string {
+	var res []string
+	for _, part := range strings.Split(s, ",") {
+		p := strings.TrimSpace(part)
+		if len(res) > 0 && !strings.HasPrefix(p, "/") && !strings.Contains(p, "://") {
+			res[len(res)-1] += "," + part
+			continue
+		}
+		res = append(res, p)
+	}
+	for i := range res {
+		res[i] = strings.TrimSpace(res[i])
+	}
+	return res
+}
+
+// hasURLPath tells whether u has a path, so that a trailing :N is a weight
+// rather than a port
+func hasURLPath(u string) bool {
+	if strings.HasPrefix(u, "/") {
+		return true
+	}
+	i := strings.Index(u, "://")
+	return i >= 0 && strings.IndexByte(u[i+3:], '/') >= 0
+}
+
+// Report feeds the outcome of a request back to the pool so that
+// consecutive failures can eject the target from rotation.
+func (p *targetPool) Report(t *target, failed bool) {
+	if p.ejectAfter <= 0 || len(p.targets) < 2 {
+		return
+	}
+	if !failed {
+		atomic.StoreInt64(&t.failures, 0)
+		return
+	}
+	if atomic.AddInt64(&t.failures, 1) < p.ejectAfter {
+		return
+	}
+	if !atomic.CompareAndSwapInt32(&t.ejected, 0, 1) {
+		return
+	}
+	t.lock.Lock()
+	t.ejections = append(t.ejections, [2]time.Duration{sinceStart(), -1})
+	t.lock.Unlock()
+	go p.probe(t)
+}
+
+func (p *targetPool) probe(t *target) {
+	ticker := time.NewTicker(p.probeInterval)
+	defer ticker.Stop()
+	req := t.newRequest()
+	req.SetBodyRaw(p.probeBody)
+	resp := &fasthttp.Response{}
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		resp.Reset()
+		err := t.httpClient.DoTimeout(req, resp, p.probeTimeout)
+		if err != nil || resp.StatusCode() >= 500 {
+			continue
+		}
+		t.lock.Lock()
+		t.ejections[len(t.ejections)-1][1] = sinceStart()
+		t.lock.Unlock()
+		atomic.StoreInt64(&t.failures, 0)
+		atomic.StoreInt32(&t.ejected, 0)
+		return
+	}
+}
+
+type EjectionReport struct {
+	URL     string
+	Windows [][2]time.Duration
+}
+
+func (p *targetPool) Ejections() []*EjectionReport {
+	if p == nil {
+		return nil
+	}
+	var res []*EjectionReport
+	for _, t := range p.targets {
+		t.lock.Lock()
+		if len(t.ejections) > 0 {
+			windows := make([][2]time.Duration, len(t.ejections))
+			copy(windows, t.ejections)
+			res = append(res, &EjectionReport{URL: t.url, Windows: windows})
+		}
+		t.lock.Unlock()
+	}
+	return res
+}
+
+func sinceStart() time.Duration {
+	return time.Since(time.Unix(0, atomic.LoadInt64(&startTimeUnixNano)))
+}
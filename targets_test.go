@@ -0,0 +1,96 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTargetMix(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		urls    []string
+		weights []int
+		err     bool
+	}{
+		{"port", []string{"http://host:8080"}, []string{"http://host:8080"}, nil, false},
+		{"port and path", []string{"http://host:8080/"}, []string{"http://host:8080/"}, nil, false},
+		{"weight after the port", []string{"http://host:8080/x:5"}, []string{"http://host:8080/x"}, []int{5}, false},
+		{
+			"paths after a url", []string{"http://host/api/list:70, /api/detail:25, /api/write:5"},
+			[]string{"http://host/api/list", "http://host/api/detail", "http://host/api/write"}, []int{70, 25, 5}, false,
+		},
+		{
+			"commas in the query", []string{"http://host:8080/a?ids=1,2,3:2, /b"},
+			[]string{"http://host:8080/a?ids=1,2,3", "http://host:8080/b"}, []int{2, 1}, false,
+		},
+		{
+			"paths after the previous url", []string{"http://a/x", "/y", "https://b:8443/z:3", "/w"},
+			[]string{"http://a/x", "http://a/y", "https://b:8443/z", "https://b:8443/w"}, []int{1, 1, 3, 1}, false,
+		},
+		{"path first", []string{"/x"}, nil, nil, true},
+		{"zero weight", []string{"http://a/x:0"}, nil, nil, true},
+		{"negative weight", []string{"http://a/x:-1"}, nil, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			urls, weights, err := parseTargetMix(tt.args)
+			if (err != nil) != tt.err {
+				t.Fatalf("error = %v, want an error %v", err, tt.err)
+			}
+			if !reflect.DeepEqual(urls, tt.urls) || !reflect.DeepEqual(weights, tt.weights) {
+				t.Errorf("got %q %v, want %q %v", urls, weights, tt.urls, tt.weights)
+			}
+		})
+	}
+}
+
+func TestSplitTargetMix(t *testing.T) {
+	tests := []struct {
+		s    string
+		want []string
+	}{
+		{"http://a/x", []string{"http://a/x"}},
+		{"http://a/x?q=1,2", []string{"http://a/x?q=1,2"}},
+		{"http://a/x?q=1, 2", []string{"http://a/x?q=1, 2"}},
+		{"http://a/x:1,/y:2", []string{"http://a/x:1", "/y:2"}},
+		{" http://a/x , https://b/y ", []string{"http://a/x", "https://b/y"}},
+		{"http://a/x?q=a,b, /y?q=c,d", []string{"http://a/x?q=a,b", "/y?q=c,d"}},
+	}
+	for _, tt := range tests {
+		if got := splitTargetMix(tt.s); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitTargetMix(%q) = %q, want %q", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestWeightSchedule(t *testing.T) {
+	tests := []struct {
+		weights []int
+		want    []int
+	}{
+		{[]int{3}, []int{0}},
+		{[]int{1, 1}, []int{0, 1}},
+		{[]int{5, 5}, []int{0, 1}},
+		{[]int{2, 4}, []int{1, 0, 1}},
+		{[]int{6, 4}, []int{0, 1, 0, 1, 0}},
+	}
+	for _, tt := range tests {
+		if got := weightSchedule(tt.weights); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("weightSchedule(%v) = %v, want %v", tt.weights, got, tt.want)
+		}
+	}
+
+	// the weights reduced by their gcd, 5, each url taking its share
+	schedule := weightSchedule([]int{70, 25, 5})
+	if len(schedule) != 20 {
+		t.Fatalf("got a schedule of %d, want 20", len(schedule))
+	}
+	counts := make([]int, 3)
+	for _, i := range schedule {
+		counts[i]++
+	}
+	if !reflect.DeepEqual(counts, []int{14, 5, 1}) {
+		t.Errorf("got the counts %v, want [14 5 1]", counts)
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// maxTCPResponse bounds a response read up to --tcp-delimiter
+const maxTCPResponse = 1 << 20
+
+// errTCPEcho is the error of an echo differing from the payload sent
+var errTCPEcho = errors.New("tcp: response differs from the payload sent")
+
+// isTCPURL tells the tcp:// and tls:// urls of the raw TCP mode
+func isTCPURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	return err == nil && (u.Scheme == "tcp" || u.Scheme == "tls")
+}
+
+// tcpMode sends the body of the requests to the tcp:// and tls:// urls as
+// is, as a custom binary protocol or to an L4 load balancer, and reads a
+// response of size bytes or up to delimiter. Without either, the response
+// is the echo of the payload, checked as such, and an empty payload only
+// opens a connection.
+type tcpMode struct {
+	size      int
+	delimiter []byte
+}
+
+// parseTCPDelimiter unquotes the Go escapes of --tcp-delimiter, e.g. \r\n
+func parseTCPDelimiter(s string) ([]byte, error) {
+	d, err := strconv.Unquote(`"` + s + `"`)
+	if err != nil || d == "" {
+		return nil, fmt.Errorf("--tcp-delimiter %q is not a string with Go escapes", s)
+	}
+	return []byte(d), nil
+}
+
+type tcpConn struct {
+	net.Conn
+	br *bufio.Reader
+}
+
+// tcpConns are the connections of a worker to the hosts of the tcp:// and
+// tls:// urls, by client, dialed like the HTTP ones and timed by their
+// tracker
+type tcpConns struct {
+	r     *Requester
+	conns map[int]*tcpConn
+	buf   []byte
+}
+
+func newTCPConns(r *Requester) *tcpConns {
+	return &tcpConns{r: r, conns: make(map[int]*tcpConn)}
+}
+
+func (m *tcpMode) setup(r *Requester) error { return nil }
+
+func (m *tcpMode) conns(r *Requester, worker int) protocolConns { return newTCPConns(r) }
+
+func (m *tcpMode) teardown() {}
+
+func (c *tcpConns) build() error { return nil }
+
+func (c *tcpConns) do(x *exchange, rr *ReportRecord) {
+	c.roundTrip(x.ci, x.client, x.pt, x.req, rr)
+}
+
+// close closes the connections of the worker at its end
+func (c *tcpConns) close() {
+	for ci, conn := range c.conns {
+		conn.Close()
+		delete(c.conns, ci)
+	}
+}
+
+// roundTrip sends the body of req on the connection ci and reads its
+// response, the latency of rr being the round trip, with the connect and
+// TLS phases of a new connection
+func (c *tcpConns) roundTrip(ci int, client *fasthttp.HostClient, pt *phaseTracker, req *fasthttp.Request, rr *ReportRecord) {
+	opt := c.r.clientOpt
+	rr.reset()
+
+	payload := req.Body()
+	start := time.Now()
+	var n int
+	var err error
+	dialed := false
+	// a connection the server closed while idle is dialed again once
+	for attempt := 0; attempt < 2; attempt++ {
+		pt.start()
+		start = time.Now()
+		conn := c.conns[ci]
+		rr.cold = conn == nil
+		if conn == nil {
+			var nc net.Conn
+			if nc, err = client.Dial(client.Addr); err != nil {
+				break
+			}
+			dialed = true
+			conn = &tcpConn{Conn: nc, br: bufio.NewReader(nc)}
+			c.conns[ci] = conn
+		}
+		if len(payload) == 0 {
+			// connect only
+			break
+		}
+		n, err = c.exchange(conn, payload)
+		if err == nil || rr.cold || n > 0 || errors.Is(err, errTCPEcho) {
+			break
+		}
+		conn.Close()
+		delete(c.conns, ci)
+		rr.stale = staleRetried
+	}
+	done := time.Now()
+	rr.cost = done.Sub(start)
+	rr.addr = pt.addr
+	rr.phases = pt.times(rr.cost, done)
+	if dialed || !rr.cold {
+		rr.reqSize, rr.respSize = int64(len(payload)), int64(n)
+	}
+	rr.error = ""
+	if err != nil {
+		rr.error = err.Error()
+		rr.errClass = classifyError(err)
+	}
+	// a wrong echo leaves the connection in step, unlike the other errors
+	broken := err != nil && !errors.Is(err, errTCPEcho)
+	if conn := c.conns[ci]; conn != nil && (broken || len(payload) == 0 || opt.disableKeepalive) {
+		conn.Close()
+		delete(c.conns, ci)
+	}
+}
+
+// exchange writes payload to conn and reads its response, returning the
+// bytes of the response read
+func (c *tcpConns) exchange(conn *tcpConn, payload []byte) (int, error) {
+	opt := c.r.clientOpt
+	now := time.Now()
+	var deadline time.Time
+	if opt.doTimeout > 0 {
+		deadline = now.Add(opt.doTimeout)
+	}
+	if opt.writeTimeout > 0 {
+		_ = conn.SetWriteDeadline(now.Add(opt.writeTimeout))
+	} else {
+		_ = conn.SetWriteDeadline(deadline)
+	}
+	if _, err := conn.Write(payload); err != nil {
+		return 0, err
+	}
+	if opt.readTimeout > 0 {
+		_ = conn.SetReadDeadline(time.Now().Add(opt.readTimeout))
+	} else {
+		_ = conn.SetReadDeadline(deadline)
+	}
+
+	mode := opt.tcp
+	if len(mode.delimiter) > 0 {
+		c.buf = c.buf[:0]
+		for !bytes.HasSuffix(c.buf, mode.delimiter) {
+			if len(c.buf) >= maxTCPResponse {
+				return len(c.buf), fmt.Errorf("tcp: no --tcp-delimiter in the first %d bytes of the response", maxTCPResponse)
+			}
+			b, err := conn.br.ReadByte()
+			if err != nil {
+				return len(c.buf), unexpectedEOF(err, len(c.buf))
+			}
+			c.buf = append(c.buf, b)
+		}
+		return len(c.buf), nil
+	}
+	size := mode.size
+	if size == 0 {
+		size = len(payload)
+	}
+	if cap(c.buf) < size {
+		c.buf = make([]byte, size)
+	}
+	n, err := io.ReadFull(conn.br, c.buf[:size])
+	if err != nil {
+		return n, unexpectedEOF(err, n)
+	}
+	if mode.size == 0 && !bytes.Equal(c.buf[:size], payload) {
+		return n, errTCPEcho
+	}
+	return n, nil
+}
+
+// unexpectedEOF tells a connection the server closed in the middle of a
+// response from the one it closed before
+func unexpectedEOF(err error, read int) error {
+	if read > 0 && errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	mrand "math/rand"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"text/template"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+var (
+	templateSeq int64
+
+	firstNames = []string{"James", "Mary", "Robert", "Patricia", "John", "Jennifer", "Michael", "Linda", "David", "Elizabeth",
+		"William", "Barbara", "Richard", "Susan", "Joseph", "Jessica", "Thomas", "Sarah", "Carlos", "Yuki", "Wei", "Fatima",
+		"Olga", "Ahmed", "Priya", "Lucas", "Emma", "Noah", "Mia", "Liam"}
+	lastNames = []string{"Smith", "Johnson", "Williams", "Brown", "Jones", "Garcia", "Miller", "Davis", "Rodriguez", "Martinez",
+		"Hernandez", "Lopez", "Wilson", "Anderson", "Taylor", "Moore", "Martin", "Lee", "Thompson", "White", "Nguyen", "Kim",
+		"Chen", "Ivanov", "Sato", "Müller", "Rossi", "Silva", "Khan", "Singh"}
+)
+
+const randChars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// newUUID returns a random UUID v4
+func newUUID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	h := hex.EncodeToString(b[:])
+	return h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
+
+// templateFuncs are the placeholders of --template, e.g. {{uuid}} or {{randInt 1 100}}
+var templateFuncs = template.FuncMap{
+	"uuid": newUUID,
+	"randInt": func(min, max int) int {
+		if max <= min {
+			return min
+		}
+		return min + mrand.Intn(max-min+1)
+	},
+	"randString": func(n int) string {
+		b := make([]byte, n)
+		for i := range b {
+			b[i] = randChars[mrand.Intn(len(randChars))]
+		}
+		return string(b)
+	},
+	"randItem": func(items ...string) string {
+		if len(items) == 0 {
+			return ""
+		}
+		return items[mrand.Intn(len(items))]
+	},
+	"firstName": func() string { return firstNames[mrand.Intn(len(firstNames))] },
+	"lastName":  func() string { return lastNames[mrand.Intn(len(lastNames))] },
+	"name": func() string {
+		return firstNames[mrand.Intn(len(firstNames))] + " " + lastNames[mrand.Intn(len(lastNames))]
+	},
+	"email": func() string {
+		return strings.ToLower(firstNames[mrand.Intn(len(firstNames))]) + "." + strings.ToLower(lastNames[mrand.Intn(len(lastNames))]) +
+			fmt.Sprintf("%d@example.com", mrand.Intn(10000))
+	},
+	"timestamp":   func() int64 { return time.Now().Unix() },
+	"timestampMs": func() int64 { return time.Now().UnixMilli() },
+	"date":        func(layout string) string { return time.Now().Format(layout) },
+	"seq":         func() int64 { return atomic.AddInt64(&templateSeq, 1) },
+	"env":         os.Getenv,
+	// randLine is a line of the file of a {FILE} pattern of the url
+	"randLine": func(path string) (string, error) {
+		lines := patternLines[path]
+		if len(lines) == 0 {
+			return "", fmt.Errorf("no line of %s was loaded", path)
+		}
+		return lines[mrand.Intn(len(lines))], nil
+	},
+	"pathEscape": url.PathEscape,
+}
+
+// requestTemplate renders the parts of the requests of a target holding
+// placeholders, each request getting its own values.
+type requestTemplate struct {
+	uri     *template.Template
+	headers []*headerTemplate
+	body    *template.Template
+}
+
+type headerTemplate struct {
+	name  string
+	value *template.Template
+}
+
+// parseTemplate parses text when it holds placeholders, sample being the
+// data of a first render, a row of the data feed or nil
+func parseTemplate(name, text string, sample interface{}) (*template.Template, error) {
+	if !strings.Contains(text, "{{") {
+		return nil, nil
+	}
+	tmpl, err := template.New(name).Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err == nil {
+		// a first render catches the unknown arguments of functions, {{seq}}
+		// still starting from 1
+		err = tmpl.Execute(&bytes.Buffer{}, sample)
+		atomic.StoreInt64(&templateSeq, 0)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid template in %s: %s", name, err)
+	}
+	return tmpl, nil
+}
+
+// newRequestTemplate parses the placeholders of rawURL, only allowed in
+// its path and query, of the headers and of the body, nil without any.
+func newRequestTemplate(rawURL string, headers []string, body []byte, sample interface{}) (*requestTemplate, error) {
+	rt := &requestTemplate{}
+	rest := rawURL
+	if i := strings.Index(rest, "://"); i >= 0 {
+		rest = rest[i+3:]
+	}
+	uri := ""
+	if i := strings.IndexAny(rest, "/?"); i >= 0 {
+		rest, uri = rest[:i], rest[i:]
+	}
+	if strings.Contains(rest, "{{") {
+		return nil, fmt.Errorf("placeholders are only supported in the path and query of %s", rawURL)
+	}
+	var err error
+	if rt.uri, err = parseTemplate("url", uri, sample); err != nil {
+		return nil, err
+	}
+	for _, h := range headers {
+		n := strings.SplitN(h, ":", 2)
+		if len(n) != 2 {
+			continue
+		}
+		tmpl, err := parseTemplate("header "+n[0], n[1], sample)
+		if err != nil {
+			return nil, err
+		}
+		if tmpl != nil {
+			rt.headers = append(rt.headers, &headerTemplate{name: n[0], value: tmpl})
+		}
+	}
+	if rt.body, err = parseTemplate("body", string(body), sample); err != nil {
+		return nil, err
+	}
+	if rt.uri == nil && rt.headers == nil && rt.body == nil {
+		return nil, nil
+	}
+	return rt, nil
+}
+
+// render sets the templated parts of req with the row of the data feed,
+// buf being reused across calls
+func (t *target) render(req *fasthttp.Request, buf *bytes.Buffer, row interface{}) error {
+	rt := t.template
+	if rt.uri != nil {
+		buf.Reset()
+		if err := rt.uri.Execute(buf, row); err != nil {
+			return err
+		}
+		t.setRequestURI(req, buf.Bytes())
+	}
+	for _, h := range rt.headers {
+		buf.Reset()
+		if err := h.value.Execute(buf, row); err != nil {
+			return err
+		}
+		req.Header.Set(h.name, strings.TrimSpace(buf.String()))
+	}
+	if rt.body != nil {
+		buf.Reset()
+		if err := rt.body.Execute(buf, row); err != nil {
+			return err
+		}
+		req.SetBody(buf.Bytes())
+	}
+	return nil
+}
+
+// templateCycle hands out its items in turn, the placeholders of the ones
+// having any rendered on each take, e.g. the names of --dns-query
+type templateCycle struct {
+	items []string
+	tmpls []*template.Template // nil for the items without placeholders
+	next  uint64
+}
+
+func newTemplateCycle(flag string, items []string) (*templateCycle, error) {
+	c := &templateCycle{items: items, tmpls: make([]*template.Template, len(items))}
+	for i, item := range items {
+		tmpl, err := parseTemplate(flag, item, nil)
+		if err != nil {
+			return nil, err
+		}
+		c.tmpls[i] = tmpl
+	}
+	return c, nil
+}
+
+// take returns the next item, rendered into buf when it has placeholders
+func (c *templateCycle) take(buf *bytes.Buffer) (string, error) {
+	i := int((atomic.AddUint64(&c.next, 1) - 1) % uint64(len(c.items)))
+	tmpl := c.tmpls[i]
+	if tmpl == nil {
+		return c.items[i], nil
+	}
+	buf.Reset()
+	if err := tmpl.Execute(buf, nil); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// thinkTime is the pause of --think-time of each worker between a response
+// and its next request, as a user reading a page, out of the latency
+type thinkTime struct {
+	// min is the fixed pause, or the mean of an exponential one, and max
+	// the bound of a uniform one between min and max
+	min, max time.Duration
+	exp      bool
+}
+
+// parseThinkTime parses `DURATION`, `MIN-MAX` for a uniform pause or
+// `exp:MEAN` for an exponential one, such as `500ms`, `1s-3s` or `exp:2s`
+func parseThinkTime(spec string) (*thinkTime, error) {
+	badSpec := fmt.Errorf("--think-time %q is neither a DURATION, a MIN-MAX range nor exp:MEAN (i.e. 500ms, 1s-3s or exp:2s)", spec)
+	t := &thinkTime{}
+	var err error
+	if mean, ok := strings.CutPrefix(spec, "exp:"); ok {
+		if t.min, err = time.ParseDuration(mean); err != nil || t.min <= 0 {
+			return nil, badSpec
+		}
+		t.exp = true
+		return t, nil
+	}
+	if min, max, ok := strings.Cut(spec, "-"); ok {
+		if t.min, err = time.ParseDuration(min); err != nil || t.min < 0 {
+			return nil, badSpec
+		}
+		if t.max, err = time.ParseDuration(max); err != nil || t.max < t.min {
+			return nil, badSpec
+		}
+		return t, nil
+	}
+	if t.min, err = time.ParseDuration(spec); err != nil || t.min < 0 {
+		return nil, badSpec
+	}
+	return t, nil
+}
+
+// next draws the next pause of a worker from its own rng
+func (t *thinkTime) next(rng *rand.Rand) time.Duration {
+	switch {
+	case t.exp:
+		return time.Duration(rng.ExpFloat64() * float64(t.min))
+	case t.max > t.min:
+		return t.min + time.Duration(rng.Int63n(int64(t.max-t.min)+1))
+	}
+	return t.min
+}
+
+// pause waits for the next think time of a worker, false when ctx is done
+// meanwhile
+func (t *thinkTime) pause(ctx context.Context, rng *rand.Rand) bool {
+	d := t.next(rng)
+	if d <= 0 {
+		return true
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+func (t *thinkTime) String() string {
+	switch {
+	case t.exp:
+		return "exp:" + t.min.String()
+	case t.max > 0:
+		return t.min.String() + "-" + t.max.String()
+	}
+	return t.min.String()
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Threshold is a pass/fail check evaluated on a final report, such as `p99<200ms` or `error_rate<1%`
+type Threshold struct {
+	Expr   string
+	Metric string
+	Op     string
+	Value  float64
+}
+
+type ThresholdResult struct {
+	Threshold string `json:"Threshold"`
+	Actual    string `json:"Actual"`
+	Passed    bool   `json:"Passed"`
+}
+
+var thresholdOps = []string{"<=", ">=", "==", "<", ">"}
+
+var latencyMetrics = map[string]bool{
+	"min": true, "mean": true, "stddev": true, "max": true,
+}
+
+func isLatencyMetric(m string) bool {
+	if latencyMetrics[m] {
+		return true
+	}
+	_, ok := percentileOf(m)
+	return ok
+}
+
+func percentileOf(m string) (float64, bool) {
+	if !strings.HasPrefix(m, "p") {
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(m[1:], 64)
+	if err != nil {
+		return 0, false
+	}
+	for _, q := range quantiles {
+		if formatFloat64(q*100) == formatFloat64(f) {
+			return q, true
+		}
+	}
+	return 0, false
+}
+
+func isRatioMetric(m string) bool {
+	return m == "error_rate" || m == "graphql_error_rate" || m == "malformed_rate" || strings.HasSuffix(m, "xx_rate")
+}
+
+func parseThreshold(expr string) (*Threshold, error) {
+	s := strings.ReplaceAll(expr, " ", "")
+	for _, op := range thresholdOps {
+		i := strings.Index(s, op)
+		if i <= 0 {
+			continue
+		}
+		t := &Threshold{Expr: expr, Metric: strings.ToLower(s[:i]), Op: op}
+		v := s[i+len(op):]
+		var err error
+		switch {
+		case isLatencyMetric(t.Metric):
+			var d time.Duration
+			d, err = time.ParseDuration(v)
+			t.Value = float64(d)
+		case isRatioMetric(t.Metric):
+			if strings.HasSuffix(v, "%") {
+				t.Value, err = strconv.ParseFloat(v[:len(v)-1], 64)
+				t.Value /= 100
+			} else {
+				t.Value, err = strconv.ParseFloat(v, 64)
+			}
+		case t.Metric == "rps" || t.Metric == "count" || t.Metric == "errors" || t.Metric == "graphql_errors" || t.Metric == "malformed":
+			t.Value, err = strconv.ParseFloat(v, 64)
+		default:
+			return nil, fmt.Errorf("threshold %q: unknown metric %q", expr, t.Metric)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("threshold %q: invalid value %q", expr, v)
+		}
+		return t, nil
+	}
+	return nil, fmt.Errorf("threshold %q doesn't match the \"metric<value\" format (i.e. p99<200ms)", expr)
+}
+
+func parseThresholds(exprs []string) ([]*Threshold, error) {
+	var res []*Threshold
+	for _, e := range exprs {
+		t, err := parseThreshold(e)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, t)
+	}
+	return res, nil
+}
+
+func errorCount(s *SnapshotReport) int64 {
+	var n int64
+	for _, v := range s.Errors {
+		n += v
+	}
+	return n
+}
+
+// errorRate is the share of the requests of s error_rate checks: the
+// errors, the 4xx and 5xx, and the 3xx with --redirect-failure
+func errorRate(s *SnapshotReport) float64 {
+	if s.Count == 0 {
+		return 0
+	}
+	failed := errorCount(s) + s.Codes["4xx"] + s.Codes["5xx"]
+	if s.Redirects != nil && s.Redirects.Failure {
+		failed += s.Codes["3xx"]
+	}
+	return float64(failed) / float64(s.Count)
+}
+
+func malformedCount(s *SnapshotReport) int64 {
+	var n int64
+	if s.Validation != nil {
+		for _, v := range s.Validation.Malformed {
+			n += v
+		}
+	}
+	return n
+}
+
+func graphqlErrorCount(s *SnapshotReport) int64 {
+	var n int64
+	for _, v := range s.GraphQLErrors {
+		n += v
+	}
+	return n
+}
+
+func (t *Threshold) actual(s *SnapshotReport) float64 {
+	if q, ok := percentileOf(t.Metric); ok {
+		for _, p := range s.Percentiles {
+			if p.Percentile == q {
+				return float64(p.Latency)
+			}
+		}
+	}
+	ratio := func(n int64) float64 {
+		if s.Count == 0 {
+			return 0
+		}
+		return float64(n) / float64(s.Count)
+	}
+	switch t.Metric {
+	case "min":
+		return float64(s.Stats.Min)
+	case "mean":
+		return float64(s.Stats.Mean)
+	case "stddev":
+		return float64(s.Stats.StdDev)
+	case "max":
+		return float64(s.Stats.Max)
+	case "rps":
+		return s.RPS
+	case "count":
+		return float64(s.Count)
+	case "errors":
+		return float64(errorCount(s))
+	case "error_rate":
+		return errorRate(s)
+	case "graphql_errors":
+		return float64(graphqlErrorCount(s))
+	case "graphql_error_rate":
+		return ratio(graphqlErrorCount(s))
+	case "malformed":
+		return float64(malformedCount(s))
+	case "malformed_rate":
+		// of the checked bodies
+		if s.Validation == nil {
+			return 0
+		}
+		return float64(malformedCount(s)) / float64(s.Validation.Checked)
+	}
+	if isRatioMetric(t.Metric) {
+		return ratio(s.Codes[strings.TrimSuffix(t.Metric, "_rate")])
+	}
+	return 0
+}
+
+func (t *Threshold) format(v float64) string {
+	switch {
+	case isLatencyMetric(t.Metric):
+		return time.Duration(v).Truncate(time.Microsecond).String()
+	case isRatioMetric(t.Metric):
+		return formatFloat64(roundFloat(v*100, 3)) + "%"
+	}
+	return formatFloat64(roundFloat(v, 3))
+}
+
+func (t *Threshold) Evaluate(s *SnapshotReport) *ThresholdResult {
+	v := t.actual(s)
+	var ok bool
+	switch t.Op {
+	case "<":
+		ok = v < t.Value
+	case "<=":
+		ok = v <= t.Value
+	case ">":
+		ok = v > t.Value
+	case ">=":
+		ok = v >= t.Value
+	case "==":
+		ok = v == t.Value
+	}
+	return &ThresholdResult{Threshold: t.Expr, Actual: t.format(v), Passed: ok}
+}
+
+func evaluateThresholds(thresholds []*Threshold, s *SnapshotReport) []*ThresholdResult {
+	var res []*ThresholdResult
+	for _, t := range thresholds {
+		res = append(res, t.Evaluate(s))
+	}
+	return res
+}
+
+func thresholdsPassed(results []*ThresholdResult) bool {
+	for _, r := range results {
+		if !r.Passed {
+			return false
+		}
+	}
+	return true
+}
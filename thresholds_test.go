@@ -0,0 +1,55 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseThreshold(t *testing.T) {
+	tests := []struct {
+		expr   string
+		metric string
+		op     string
+		value  float64
+	}{
+		{"p99<200ms", "p99", "<", float64(200 * time.Millisecond)},
+		{"P99 <= 1s", "p99", "<=", float64(time.Second)},
+		{"p99.9<1.5s", "p99.9", "<", float64(1500 * time.Millisecond)},
+		{"mean>10µs", "mean", ">", float64(10 * time.Microsecond)},
+		{"error_rate<1%", "error_rate", "<", 0.01},
+		{"error_rate<0.02", "error_rate", "<", 0.02},
+		{"5xx_rate<=0.5%", "5xx_rate", "<=", 0.005},
+		{"rps>=1000", "rps", ">=", 1000},
+		{"count==100", "count", "==", 100},
+		{"errors<1", "errors", "<", 1},
+	}
+	for _, tt := range tests {
+		th, err := parseThreshold(tt.expr)
+		if err != nil {
+			t.Errorf("%s: %v", tt.expr, err)
+			continue
+		}
+		if th.Expr != tt.expr || th.Metric != tt.metric || th.Op != tt.op || th.Value != tt.value {
+			t.Errorf("%s: got %+v, want %s %s %v", tt.expr, th, tt.metric, tt.op, tt.value)
+		}
+	}
+}
+
+func TestParseThresholdErrors(t *testing.T) {
+	for _, expr := range []string{
+		"p99",
+		"<1s",
+		"p98<1s",
+		"latency<1s",
+		"p99<fast",
+		"error_rate<often",
+		"rps>many",
+	} {
+		if th, err := parseThreshold(expr); err == nil {
+			t.Errorf("%s: got %+v, want an error", expr, th)
+		}
+	}
+	if _, err := parseThresholds([]string{"p99<1s", "p99<fast"}); err == nil {
+		t.Error("parseThresholds with an invalid threshold: no error")
+	}
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"encoding/csv"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/beorn7/perks/quantile"
+)
+
+// TickReport holds the metrics of one reporting tick (one second)
+type TickReport struct {
+	Time        time.Time
+	Elapsed     time.Duration
+	Count       int64
+	RPS         float64
+	Latency     Stats
+	Percentiles []time.Duration
+	Codes       map[int]int64
+	Errors      int64
+	Extracted   []float64 // mean of each extracted field, NaN without value
+	Buckets     []int64   // the requests which didn't fail by heatmap bucket of their latency
+	Warmup      bool      // holding records of the warmup, excluded from the summary
+}
+
+// tickCollector accumulates the records of the current tick
+type tickCollector struct {
+	start     time.Time
+	count     int64
+	latency   Stats
+	quantile  *quantile.Stream
+	codes     map[int]int64
+	errors    int64
+	extracted []Stats
+	buckets   []int64
+	warmup    bool
+}
+
+func newTickCollector() *tickCollector {
+	return &tickCollector{
+		start:    time.Now(),
+		quantile: quantile.NewTargeted(quantilesTarget),
+		codes:    make(map[int]int64, 1),
+		buckets:  make([]int64, len(heatmapBounds)+1),
+	}
+}
+
+func (c *tickCollector) collect(r *ReportRecord) {
+	c.count++
+	c.latency.Update(float64(r.cost))
+	c.quantile.Insert(float64(r.cost))
+	if r.code != 0 {
+		c.codes[r.code/100]++
+	}
+	if r.error != "" {
+		c.errors++
+	} else {
+		c.buckets[heatmapBucket(r.cost)]++
+	}
+	for i, v := range r.extracted {
+		if math.IsNaN(v) {
+			continue
+		}
+		for len(c.extracted) <= i {
+			c.extracted = append(c.extracted, Stats{})
+		}
+		c.extracted[i].Update(v)
+	}
+}
+
+func (c *tickCollector) flush(now, startTime time.Time) *TickReport {
+	t := &TickReport{
+		Time:    now,
+		Elapsed: now.Sub(startTime),
+		Count:   c.count,
+		Latency: c.latency,
+		Codes:   c.codes,
+		Errors:  c.errors,
+		Buckets: c.buckets,
+		Warmup:  c.warmup,
+	}
+	if d := now.Sub(c.start).Seconds(); d > 0 {
+		t.RPS = float64(c.count) / d
+	}
+	t.Percentiles = make([]time.Duration, len(quantiles))
+	if c.count > 0 {
+		for i, q := range quantiles {
+			t.Percentiles[i] = time.Duration(c.quantile.Query(q))
+		}
+	}
+
+	for i := range c.extracted {
+		if c.extracted[i].count > 0 {
+			t.Extracted = append(t.Extracted, c.extracted[i].Mean())
+		} else {
+			t.Extracted = append(t.Extracted, math.NaN())
+		}
+		c.extracted[i].Reset()
+	}
+
+	c.start = now
+	c.count = 0
+	c.latency.Reset()
+	c.quantile.Reset()
+	c.codes = make(map[int]int64, len(c.codes))
+	c.errors = 0
+	c.buckets = make([]int64, len(c.buckets))
+	c.warmup = false
+	return t
+}
+
+func ticksCSVHeader(extracts []*extractor) []string {
+	header := []string{"time", "elapsed", "count", "rps", "min", "mean", "max"}
+	for _, q := range quantiles {
+		header = append(header, "p"+formatFloat64(q*100))
+	}
+	for i := 1; i <= 5; i++ {
+		header = append(header, httpStatusSectionLabelMap[i])
+	}
+	header = append(header, "errors")
+	for _, e := range extracts {
+		header = append(header, e.name)
+	}
+	return header
+}
+
+// writeTicksCSV writes one row per tick, latencies are in milliseconds
+func writeTicksCSV(w io.Writer, ticks []*TickReport, header bool, extracts []*extractor) error {
+	cw := csv.NewWriter(w)
+	if header {
+		if err := cw.Write(ticksCSVHeader(extracts)); err != nil {
+			return err
+		}
+	}
+	ms := func(v float64) string {
+		return strconv.FormatFloat(v/float64(time.Millisecond), 'f', 3, 64)
+	}
+	for _, t := range ticks {
+		row := []string{
+			t.Time.Format("2006-01-02T15:04:05.000Z07:00"),
+			strconv.FormatFloat(t.Elapsed.Seconds(), 'f', 1, 64),
+			strconv.FormatInt(t.Count, 10),
+			strconv.FormatFloat(t.RPS, 'f', 3, 64),
+			ms(t.Latency.min),
+			ms(t.Latency.Mean()),
+			ms(t.Latency.max),
+		}
+		for _, p := range t.Percentiles {
+			row = append(row, ms(float64(p)))
+		}
+		for i := 1; i <= 5; i++ {
+			row = append(row, strconv.FormatInt(t.Codes[i], 10))
+		}
+		row = append(row, strconv.FormatInt(t.Errors, 10))
+		for i := range extracts {
+			v := ""
+			if i < len(t.Extracted) && !math.IsNaN(t.Extracted[i]) {
+				v = strconv.FormatFloat(t.Extracted[i], 'f', -1, 64)
+			}
+			row = append(row, v)
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// tickCSVWriter appends the ticks of a running report to a CSV file as they are recorded
+type tickCSVWriter struct {
+	f      *os.File
+	report *StreamReport
+	n      int
+	done   chan struct{}
+}
+
+func newTickCSVWriter(path string, report *StreamReport) (*tickCSVWriter, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	if err = writeTicksCSV(f, nil, true, report.extracts); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &tickCSVWriter{f: f, report: report, done: make(chan struct{})}, nil
+}
+
+func (c *tickCSVWriter) flush() error {
+	ticks := c.report.Ticks(c.n)
+	c.n += len(ticks)
+	return writeTicksCSV(c.f, ticks, false, c.report.extracts)
+}
+
+// Run flushes new ticks every second until the report is done
+func (c *tickCSVWriter) Run() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	defer close(c.done)
+	for {
+		select {
+		case <-ticker.C:
+			_ = c.flush()
+		case <-c.report.Done():
+			return
+		}
+	}
+}
+
+func (c *tickCSVWriter) Close() error {
+	<-c.done
+	err := c.flush()
+	if cerr := c.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+const (
+	traceFormatUUID        = "uuid"
+	traceFormatHex         = "hex"
+	traceFormatTraceparent = "traceparent"
+)
+
+// traceHeader sets a unique id in a header of each request, for the logs of
+// the server to be matched with the requests of the run
+type traceHeader struct {
+	name   string
+	format string
+}
+
+// parseTraceHeader parses NAME[:FORMAT] of --trace-header, the format being
+// traceparent for the W3C header of that name and uuid for the others
+// without one
+func parseTraceHeader(spec string) (*traceHeader, error) {
+	name, format, _ := strings.Cut(spec, ":")
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, fmt.Errorf("--trace-header %q has no header name", spec)
+	}
+	format = strings.ToLower(strings.TrimSpace(format))
+	if format == "" {
+		format = traceFormatUUID
+		if strings.EqualFold(name, traceFormatTraceparent) {
+			format = traceFormatTraceparent
+		}
+	}
+	switch format {
+	case traceFormatUUID, traceFormatHex, traceFormatTraceparent:
+	default:
+		return nil, fmt.Errorf("--trace-header format %q is not uuid, hex or traceparent", format)
+	}
+	return &traceHeader{name: name, format: format}, nil
+}
+
+// id returns a new id in the format of the header
+func (t *traceHeader) id() string {
+	switch t.format {
+	case traceFormatHex:
+		return randomHex(16)
+	case traceFormatTraceparent:
+		// version 00, a trace of its own, sampled
+		return "00-" + randomHex(16) + "-" + randomHex(8) + "-01"
+	}
+	return newUUID()
+}
+
+// traceID is the trace id of id, the one of a traceparent and the whole
+// id otherwise
+func (t *traceHeader) traceID(id string) string {
+	if t.format == traceFormatTraceparent {
+		return id[3:35]
+	}
+	return id
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// runTrend is the outcome of a finished run of the web UI, a point of the
+// trend charts of its preset
+type runTrend struct {
+	ID        string    `json:"id"`
+	Desc      string    `json:"desc"`
+	Preset    string    `json:"preset,omitempty"`
+	StartedAt time.Time `json:"startedAt"`
+	P99       float64   `json:"p99"` // ms
+	RPS       float64   `json:"rps"`
+	ErrorRate float64   `json:"errorRate"` // errors, 4xx and 5xx over count
+}
+
+func newRunTrend(run *guiRun) *runTrend {
+	s := run.summary
+	t := &runTrend{ID: run.ID, Desc: run.Desc, Preset: run.Preset, StartedAt: run.StartedAt, P99: s.Percentiles["P99"], RPS: s.RPS}
+	if s.Count > 0 {
+		failed := s.Codes["4xx"] + s.Codes["5xx"]
+		for _, n := range s.Errors {
+			failed += n
+		}
+		t.ErrorRate = roundFloat(float64(failed)/float64(s.Count), 6)
+	}
+	return t
+}
+
+// handleTrends serves the outcome of the finished runs of the preset given
+// by the query, or of all the runs without, oldest first
+func (g *GUIServer) handleTrends(ctx *fasthttp.RequestCtx) {
+	ctx.SetContentType("application/json")
+	args := ctx.QueryArgs()
+	preset := string(args.Peek("preset"))
+	g.mu.Lock()
+	trends := make([]*runTrend, 0, len(g.runs))
+	for _, run := range g.runs {
+		if run.summary == nil || (args.Has("preset") && run.Preset != preset) {
+			continue
+		}
+		trends = append(trends, newRunTrend(run))
+	}
+	g.mu.Unlock()
+	json.NewEncoder(ctx).Encode(trends)
+}
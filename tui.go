@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-isatty"
+	"github.com/mattn/go-runewidth"
+)
+
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// adjuster is a source of records whose connections change while it runs,
+// the Requester but not the Controller of the agents
+type adjuster interface {
+	Adjust(n int) error
+}
+
+// tui is the --tui dashboard of the run on the alternate screen, the charts
+// of the web UI as sparklines for the runs over SSH, with keys to stop the
+// run and to change its connections
+type tui struct {
+	desc        string
+	maxNum      int64
+	maxDuration time.Duration
+	useSeconds  bool
+	source      recordSource
+
+	rps, latency, ok, failed []float64 // a value per second, the last width ones
+	codes                    map[int]int64
+	status                   string // answer to the last key
+}
+
+// spark is the sparkline of the last width values of vs, scaled to their max
+func spark(vs []float64, width int) string {
+	if len(vs) > width {
+		vs = vs[len(vs)-width:]
+	}
+	top := 0.0
+	for _, v := range vs {
+		top = max(top, v)
+	}
+	var b strings.Builder
+	for _, v := range vs {
+		i := 0
+		if top > 0 {
+			i = int(v / top * float64(len(sparkBlocks)-1))
+		}
+		b.WriteRune(sparkBlocks[i])
+	}
+	return b.String()
+}
+
+// push appends v to the values of a chart, keeping the last ones the
+// widest terminal shows
+func push(vs []float64, v float64) []float64 {
+	const kept = 512
+	if len(vs) >= kept {
+		vs = append(vs[:0], vs[1:]...)
+	}
+	return append(vs, v)
+}
+
+// sample adds the last second of the charts report to the sparklines
+func (t *tui) sample(cr *ChartsReport) {
+	if cr == nil {
+		t.rps = push(t.rps, 0)
+		t.latency = push(t.latency, 0)
+		t.ok = push(t.ok, 0)
+		t.failed = push(t.failed, 0)
+		return
+	}
+	t.rps = push(t.rps, cr.RPS)
+	t.latency = push(t.latency, cr.Latency.Mean())
+	var ok, failed int64
+	for code, n := range cr.CodeMap {
+		n -= t.codes[code]
+		if code >= 500 {
+			failed += n
+		} else {
+			ok += n
+		}
+	}
+	for _, n := range cr.Errors {
+		failed += n
+	}
+	t.codes = cr.CodeMap
+	t.ok = push(t.ok, float64(ok))
+	t.failed = push(t.failed, float64(failed))
+}
+
+// render writes the dashboard of the snapshot s, width columns wide
+func (t *tui) render(buf *bytes.Buffer, s *SnapshotReport, width int) {
+	line := func(format string, args ...interface{}) {
+		l := fmt.Sprintf(format, args...)
+		if displayWidth(l) > width {
+			l = runewidth.Truncate(ansi.ReplaceAllLiteralString(l, ""), width, "")
+		}
+		buf.WriteString(l)
+		buf.WriteString("\033[K\r\n")
+	}
+	chart := width - 2
+
+	line("%s", colorize(strings.TrimSuffix(t.desc, "."), FgCyanColor))
+	progress := "Elapsed " + durationToString(s.Elapsed.Truncate(100*time.Millisecond), t.useSeconds)
+	if t.maxDuration > 0 {
+		progress += " / " + t.maxDuration.String()
+	}
+	if t.maxNum > 0 {
+		progress += fmt.Sprintf("   Requests %d / %d", s.Count, t.maxNum)
+	} else {
+		progress += fmt.Sprintf("   Requests %d", s.Count)
+	}
+	line("%s   Connections %d", progress, s.concurrencyCount)
+	line("")
+
+	last := func(vs []float64) float64 {
+		if len(vs) == 0 {
+			return 0
+		}
+		return vs[len(vs)-1]
+	}
+	line("%s  %.0f/s, mean %.0f/s", colorize("Requests", FgYellowColor), last(t.rps), s.RPS)
+	line(" %s", colorize(spark(t.rps, chart), FgGreenColor))
+	mean, p99 := time.Duration(0), time.Duration(0)
+	if s.Stats != nil {
+		mean = s.Stats.Mean
+	}
+	for _, p := range s.Percentiles {
+		if p.Percentile == 0.99 {
+			p99 = p.Latency
+		}
+	}
+	line("%s  %s in the last second, mean %s, P99 %s", colorize("Latency", FgYellowColor),
+		durationToString(time.Duration(last(t.latency)), t.useSeconds), durationToString(mean, t.useSeconds), durationToString(p99, t.useSeconds))
+	line(" %s", colorize(spark(t.latency, chart), FgBlueColor))
+	line("%s  %.0f/s", colorize("Succeeded", FgYellowColor), last(t.ok))
+	line(" %s", colorize(spark(t.ok, chart), FgGreenColor))
+	line("%s  %.0f/s, 5xx and errors", colorize("Failed", FgYellowColor), last(t.failed))
+	line(" %s", colorize(spark(t.failed, chart), FgRedColor))
+	line("")
+
+	var codes []string
+	for _, code := range sortedKeys(s.Codes) {
+		codes = append(codes, fmt.Sprintf("%s %d", code, s.Codes[code]))
+	}
+	var errs int64
+	for _, n := range s.Errors {
+		errs += n
+	}
+	if errs > 0 {
+		codes = append(codes, colorize(fmt.Sprintf("errors %d", errs), FgRedColor))
+	}
+	line("Codes  %s", strings.Join(codes, "   "))
+	line("Throughput  read %.2f MB/s, written %.2f MB/s", s.ReadThroughput, s.WriteThroughput)
+	line("")
+	keys := "q stop"
+	if _, ok := t.source.(adjuster); ok {
+		keys += "   + / - connections"
+	}
+	line("%s   %s", colorize(keys, FgMagentaColor), t.status)
+}
+
+// key handles a key pressed during the run
+func (t *tui) key(k byte, s *SnapshotReport) {
+	switch k {
+	case 'q', 'Q':
+		t.status = "stopping, draining the requests in flight"
+		t.source.Interrupt()
+	case '+', '=', '-', '_':
+		a, ok := t.source.(adjuster)
+		if !ok {
+			t.status = "the connections of the agents are fixed"
+			return
+		}
+		n := s.concurrencyCount
+		step := max(n/10, 1)
+		if k == '+' || k == '=' {
+			n += step
+		} else {
+			n = max(n-step, 1)
+		}
+		if err := a.Adjust(n); err != nil {
+			t.status = err.Error()
+			return
+		}
+		t.status = fmt.Sprintf("%d connections", n)
+	}
+}
+
+// run shows the dashboard every interval until doneChan is closed, leaving
+// the terminal as it found it
+func (t *tui) run(snapshot func() *SnapshotReport, charts func() *ChartsReport, interval time.Duration, doneChan <-chan struct{}) {
+	keys := make(chan byte, 8)
+	if isatty.IsTerminal(os.Stdin.Fd()) {
+		if restore, err := rawTerminal(os.Stdin.Fd()); err == nil {
+			defer restore()
+		}
+		go func() {
+			b := make([]byte, 16)
+			for {
+				n, err := os.Stdin.Read(b)
+				if err != nil {
+					return
+				}
+				for _, k := range b[:n] {
+					select {
+					case keys <- k:
+					default:
+					}
+				}
+			}
+		}()
+	}
+	os.Stdout.WriteString("\033[?1049h\033[?25l")
+	defer os.Stdout.WriteString("\033[?25h\033[?1049l")
+
+	var buf bytes.Buffer
+	draw := func() *SnapshotReport {
+		s := snapshot()
+		buf.Reset()
+		buf.WriteString("\033[H")
+		t.render(&buf, s, terminalWidth(os.Stdout.Fd()))
+		buf.WriteString("\033[J")
+		os.Stdout.Write(buf.Bytes())
+		return s
+	}
+	second := time.NewTicker(time.Second)
+	defer second.Stop()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	s := draw()
+	for {
+		select {
+		case <-doneChan:
+			return
+		case <-second.C:
+			t.sample(charts())
+		case <-ticker.C:
+			s = draw()
+		case k := <-keys:
+			t.key(k, s)
+			s = draw()
+		}
+	}
+}
@@ -0,0 +1,30 @@
+//go:build darwin || freebsd
+
+package main
+
+import "golang.org/x/sys/unix"
+
+// rawTerminal reads the keys of the terminal fd as they are pressed, without
+// echoing them, ctrl-c still interrupting, until restore
+func rawTerminal(fd uintptr) (restore func(), err error) {
+	old, err := unix.IoctlGetTermios(int(fd), unix.TIOCGETA)
+	if err != nil {
+		return nil, err
+	}
+	raw := *old
+	raw.Lflag &^= unix.ICANON | unix.ECHO
+	raw.Cc[unix.VMIN], raw.Cc[unix.VTIME] = 1, 0
+	if err := unix.IoctlSetTermios(int(fd), unix.TIOCSETA, &raw); err != nil {
+		return nil, err
+	}
+	return func() { _ = unix.IoctlSetTermios(int(fd), unix.TIOCSETA, old) }, nil
+}
+
+// terminalWidth is the columns of the terminal fd, 80 when unknown
+func terminalWidth(fd uintptr) int {
+	ws, err := unix.IoctlGetWinsize(int(fd), unix.TIOCGWINSZ)
+	if err != nil || ws.Col == 0 {
+		return 80
+	}
+	return int(ws.Col)
+}
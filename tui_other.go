@@ -0,0 +1,15 @@
+//go:build !linux && !darwin && !freebsd
+
+package main
+
+import "errors"
+
+// rawTerminal is Linux, macOS and FreeBSD only, the keys of --tui being read
+// a line at a time elsewhere
+func rawTerminal(fd uintptr) (restore func(), err error) {
+	return nil, errors.New("raw terminal is not supported")
+}
+
+func terminalWidth(fd uintptr) int {
+	return 80
+}
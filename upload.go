@@ -0,0 +1,290 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// uploadKeyData are the fields of upload key templates
+type uploadKeyData struct {
+	RunID     string // 20060102-150405-<random>
+	Date      string // 2006-01-02
+	Time      string // 150405
+	Timestamp int64  // unix seconds
+	Host      string // host of the first url
+	Hostname  string // host running plow
+}
+
+func newUploadKeyData(urls []string) *uploadKeyData {
+	now := time.Now()
+	d := &uploadKeyData{
+		RunID:     newRunID(),
+		Date:      now.Format("2006-01-02"),
+		Time:      now.Format("150405"),
+		Timestamp: now.Unix(),
+	}
+	if len(urls) > 0 {
+		if u, err := url.Parse(urls[0]); err == nil {
+			d.Host = u.Hostname()
+		}
+	}
+	d.Hostname, _ = os.Hostname()
+	return d
+}
+
+// reportUpload is the destination of an --upload flag,
+// e.g. `s3://bucket/plow/{{.Date}}/{{.RunID}}.json`.
+type reportUpload struct {
+	scheme    string // s3, gs or azblob
+	bucket    string // bucket, or account/container for azblob
+	key       *template.Template
+	keySource string
+}
+
+func parseReportUpload(s string) (*reportUpload, error) {
+	i := strings.Index(s, "://")
+	if i < 0 {
+		return nil, fmt.Errorf("upload %q must be s3://, gs:// or azblob://", s)
+	}
+	u := &reportUpload{scheme: s[:i]}
+	rest := s[i+3:]
+	parts := 2
+	switch u.scheme {
+	case "s3", "gs":
+	case "azblob":
+		parts = 3
+	default:
+		return nil, fmt.Errorf("upload %q must be s3://, gs:// or azblob://", s)
+	}
+	ps := strings.SplitN(rest, "/", parts)
+	if len(ps) < parts || ps[len(ps)-1] == "" {
+		return nil, fmt.Errorf("upload %q has no key", s)
+	}
+	u.bucket = strings.Join(ps[:parts-1], "/")
+	u.keySource = ps[parts-1]
+	var err error
+	if u.key, err = template.New("key").Option("missingkey=error").Parse(u.keySource); err == nil {
+		err = u.key.Execute(io.Discard, &uploadKeyData{})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("upload %q: %s", s, err)
+	}
+	return u, nil
+}
+
+// htmlReport renders the terminal report as a standalone page
+func htmlReport(desc, text string) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "<!DOCTYPE html>\n<html><head><meta charset=\"utf-8\"><title>plow report</title>"+
+		"<style>body{font-family:sans-serif;margin:24px}pre{font-size:13px;line-height:1.4}</style></head>\n"+
+		"<body><h3>%s</h3>\n<pre>%s</pre></body></html>\n", html.EscapeString(desc), html.EscapeString(text))
+	return b.Bytes()
+}
+
+// uploadClient bounds an upload, an unreachable bucket not hanging the end
+// of the run
+var uploadClient = &http.Client{Timeout: 2 * time.Minute}
+
+// upload renders the key and puts the report, HTML for a .html key and JSON otherwise
+func (u *reportUpload) upload(data *uploadKeyData, jsonReport, htmlReport []byte) (string, error) {
+	var key strings.Builder
+	if err := u.key.Execute(&key, data); err != nil {
+		return "", err
+	}
+	body, contentType := jsonReport, "application/json"
+	if ext := path.Ext(key.String()); ext == ".html" || ext == ".htm" {
+		body, contentType = htmlReport, "text/html; charset=utf-8"
+	}
+
+	var req *http.Request
+	var err error
+	switch u.scheme {
+	case "s3":
+		req, err = s3PutRequest(u.bucket, key.String(), body, contentType)
+	case "gs":
+		req, err = gcsPutRequest(u.bucket, key.String(), body, contentType)
+	case "azblob":
+		req, err = azblobPutRequest(u.bucket, key.String(), body, contentType)
+	}
+	if err != nil {
+		return "", err
+	}
+	resp, err := uploadClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("%s: %s %s", resp.Status, req.URL.Host, strings.TrimSpace(string(msg)))
+	}
+	return u.scheme + "://" + u.bucket + "/" + key.String(), nil
+}
+
+// escapeKey percent-encodes all but the unreserved characters of each key
+// segment, as required for the canonical URI of AWS signatures
+func escapeKey(key string) string {
+	var b strings.Builder
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || strings.IndexByte("-_.~/", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// canonicalQuery is the query of a signature, its parameters sorted by name
+// then value, and percent-encoded but for the unreserved characters
+func canonicalQuery(query url.Values) string {
+	escape := func(s string) string { return strings.ReplaceAll(escapeKey(s), "/", "%2F") }
+	var params [][2]string
+	for k, vs := range query {
+		for _, v := range vs {
+			params = append(params, [2]string{escape(k), escape(v)})
+		}
+	}
+	sort.Slice(params, func(i, j int) bool {
+		if params[i][0] != params[j][0] {
+			return params[i][0] < params[j][0]
+		}
+		return params[i][1] < params[j][1]
+	})
+	var b strings.Builder
+	for i, p := range params {
+		if i > 0 {
+			b.WriteByte('&')
+		}
+		b.WriteString(p[0] + "=" + p[1])
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// s3PutRequest signs a PutObject with AWS Signature V4 from the AWS_* environment,
+// AWS_ENDPOINT_URL pointing to S3-compatible stores such as MinIO (path-style).
+func s3PutRequest(bucket, key string, body []byte, contentType string) (*http.Request, error) {
+	accessKey, secretKey := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY")
+	if accessKey == "" || secretKey == "" {
+		return nil, fmt.Errorf("s3 upload needs AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if region == "" {
+		region = "us-east-1"
+	}
+	var u string
+	if endpoint := os.Getenv("AWS_ENDPOINT_URL"); endpoint != "" {
+		u = strings.TrimRight(endpoint, "/") + "/" + bucket + "/" + escapeKey(key)
+	} else {
+		u = "https://" + bucket + ".s3." + region + ".amazonaws.com/" + escapeKey(key)
+	}
+	req, err := http.NewRequest(http.MethodPut, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	payloadHash := sha256.Sum256(body)
+	headers := map[string]string{
+		"content-type":         contentType,
+		"x-amz-content-sha256": hex.EncodeToString(payloadHash[:]),
+	}
+	if token := os.Getenv("AWS_SESSION_TOKEN"); token != "" {
+		headers["x-amz-security-token"] = token
+	}
+	signV4(req, headers, headers["x-amz-content-sha256"], accessKey, secretKey, region, "s3", time.Now())
+	return req, nil
+}
+
+// signV4 sets headers and the AWS Signature V4 authorization of a request to
+// service, headers having lowercase names and payloadHash being the hex
+// SHA-256 of the body
+func signV4(req *http.Request, headers map[string]string, payloadHash, accessKey, secretKey, region, service string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	headers["host"] = req.URL.Host
+	headers["x-amz-date"] = amzDate
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + strings.TrimSpace(headers[k]) + "\n")
+		if k != "host" {
+			req.Header.Set(k, headers[k])
+		}
+	}
+	signedHeaders := strings.Join(names, ";")
+	canonicalRequest := strings.Join([]string{
+		req.Method, req.URL.EscapedPath(), canonicalQuery(req.URL.Query()), canonicalHeaders.String(), signedHeaders, payloadHash,
+	}, "\n")
+	scope := day + "/" + region + "/" + service + "/aws4_request"
+	crHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(crHash[:])
+	signingKey := hmacSHA256(hmacSHA256(hmacSHA256(hmacSHA256([]byte("AWS4"+secretKey), day), region), service), "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+accessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+// gcsPutRequest uploads with the JSON API and an OAuth token in
+// GOOGLE_OAUTH_ACCESS_TOKEN, e.g. from `gcloud auth print-access-token`.
+func gcsPutRequest(bucket, key string, body []byte, contentType string) (*http.Request, error) {
+	token := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN")
+	if token == "" {
+		return nil, fmt.Errorf("gs upload needs GOOGLE_OAUTH_ACCESS_TOKEN")
+	}
+	u := "https://storage.googleapis.com/upload/storage/v1/b/" + url.PathEscape(bucket) +
+		"/o?uploadType=media&name=" + url.QueryEscape(key)
+	req, err := http.NewRequest(http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", contentType)
+	return req, nil
+}
+
+// azblobPutRequest puts a block blob authorized by the SAS token in
+// AZURE_STORAGE_SAS_TOKEN, target being account/container.
+func azblobPutRequest(target, key string, body []byte, contentType string) (*http.Request, error) {
+	sas := strings.TrimPrefix(os.Getenv("AZURE_STORAGE_SAS_TOKEN"), "?")
+	if sas == "" {
+		return nil, fmt.Errorf("azblob upload needs AZURE_STORAGE_SAS_TOKEN")
+	}
+	account, container, _ := strings.Cut(target, "/")
+	u := "https://" + account + ".blob.core.windows.net/" + url.PathEscape(container) + "/" + escapeKey(key) + "?" + sas
+	req, err := http.NewRequest(http.MethodPut, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("x-ms-blob-type", "BlockBlob")
+	req.Header.Set("x-ms-version", "2020-10-02")
+	req.Header.Set("Content-Type", contentType)
+	return req, nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// urlPatternRe matches the {N-M} and {FILE} patterns of a url
+	urlPatternRe = regexp.MustCompile(`\{([^{}]+)\}`)
+	urlRangeRe   = regexp.MustCompile(`^(\d+)-(\d+)$`)
+
+	// patternLines are the lines of the {FILE} patterns, by path
+	patternLines = map[string][]string{}
+)
+
+// expandURLPatterns turns the patterns of the path and query of rawURL into
+// the placeholders of --template drawing a value per request: {N-M} a number
+// from N to M and {FILE} a line of FILE, escaped. The {{...}} placeholders
+// and the braces of anything else are left as they are. It is false without
+// any pattern.
+func expandURLPatterns(rawURL string) (string, bool, error) {
+	start := 0
+	if i := strings.Index(rawURL, "://"); i >= 0 {
+		start = i + 3
+	}
+	if i := strings.IndexAny(rawURL[start:], "/?"); i >= 0 {
+		start += i
+	} else {
+		return rawURL, false, nil
+	}
+	query := strings.IndexByte(rawURL, '?')
+	var b strings.Builder
+	b.WriteString(rawURL[:start])
+	last, found := start, false
+	for _, m := range urlPatternRe.FindAllStringSubmatchIndex(rawURL[start:], -1) {
+		s, e := m[0]+start, m[1]+start
+		if s > 0 && rawURL[s-1] == '{' || e < len(rawURL) && rawURL[e] == '}' {
+			// a placeholder of --template
+			continue
+		}
+		arg := rawURL[m[2]+start : m[3]+start]
+		var placeholder string
+		if r := urlRangeRe.FindStringSubmatch(arg); r != nil {
+			min, err1 := strconv.Atoi(r[1])
+			max, err2 := strconv.Atoi(r[2])
+			if err1 != nil || err2 != nil || min > max {
+				return "", false, fmt.Errorf("invalid range {%s} in %s", arg, rawURL)
+			}
+			placeholder = fmt.Sprintf("{{randInt %d %d}}", min, max)
+		} else if _, err := os.Stat(arg); err == nil {
+			if err := loadPatternLines(arg); err != nil {
+				return "", false, err
+			}
+			escape := "pathEscape"
+			if query >= 0 && s > query {
+				escape = "urlquery"
+			}
+			placeholder = fmt.Sprintf("{{randLine %s | %s}}", strconv.Quote(arg), escape)
+		} else {
+			continue
+		}
+		b.WriteString(rawURL[last:s])
+		b.WriteString(placeholder)
+		last, found = e, true
+	}
+	if !found {
+		return rawURL, false, nil
+	}
+	b.WriteString(rawURL[last:])
+	return b.String(), true, nil
+}
+
+// loadPatternLines reads the non-empty lines of the file of a {FILE} pattern
+func loadPatternLines(path string) error {
+	if _, ok := patternLines[path]; ok {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var lines []string
+	for _, l := range strings.Split(string(data), "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			lines = append(lines, l)
+		}
+	}
+	if len(lines) == 0 {
+		return fmt.Errorf("%s has no line for the {%s} pattern", path, path)
+	}
+	patternLines[path] = lines
+	return nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"math/rand"
+	"unicode/utf8"
+
+	"github.com/valyala/fasthttp"
+)
+
+// ValidationReport counts the response bodies checked with --validate, and
+// the malformed ones by class, e.g. "truncated JSON"
+type ValidationReport struct {
+	Checked   int64
+	Malformed map[string]int64 `json:",omitempty"`
+}
+
+// validateResponse checks the body of a share rate of the 2xx responses with
+// a body against format, returning whether it was checked and why it's
+// malformed, "" when valid
+func validateResponse(format string, rate float64, req *fasthttp.Request, resp *fasthttp.Response) (bool, string) {
+	code := resp.StatusCode()
+	if format == "" || code/100 != 2 || code == fasthttp.StatusNoContent || req.Header.IsHead() {
+		return false, ""
+	}
+	if rate < 1 && rand.Float64() >= rate {
+		return false, ""
+	}
+	body, err := resp.BodyUncompressed()
+	if err != nil {
+		return true, "undecodable " + string(resp.Header.ContentEncoding()) + " body"
+	}
+	return true, validateBody(format, body)
+}
+
+// validateBody is the cheap structural check of a body, telling the truncated
+// bodies apart from the otherwise invalid ones
+func validateBody(format string, body []byte) string {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return "empty body"
+	}
+	switch format {
+	case "utf8":
+		if utf8.Valid(body) {
+			return ""
+		}
+		// an incomplete sequence at the end only
+		i := len(body) - 1
+		for i > 0 && len(body)-i < utf8.UTFMax && !utf8.RuneStart(body[i]) {
+			i--
+		}
+		if utf8.Valid(body[:i]) && !utf8.FullRune(body[i:]) {
+			return "truncated UTF-8"
+		}
+		return "invalid UTF-8"
+	case "json":
+		if json.Valid(body) {
+			return ""
+		}
+		var v json.RawMessage
+		if err := json.Unmarshal(body, &v); err != nil && err.Error() == "unexpected end of JSON input" {
+			return "truncated JSON"
+		}
+		return "invalid JSON"
+	case "xml":
+		d := xml.NewDecoder(bytes.NewReader(body))
+		root := false
+		for {
+			t, err := d.Token()
+			if err == io.EOF {
+				if !root {
+					return "invalid XML"
+				}
+				return ""
+			}
+			var se *xml.SyntaxError
+			if errors.Is(err, io.ErrUnexpectedEOF) || errors.As(err, &se) && se.Msg == "unexpected EOF" {
+				return "truncated XML"
+			}
+			if err != nil {
+				return "invalid XML"
+			}
+			if _, ok := t.(xml.StartElement); ok {
+				root = true
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,97 @@
+package main
+
+import "time"
+
+// the warmup of --auto-warmup ends once the per-second median latency of the
+// last warmupWindow seconds spreads by at most warmupSpread of their mean
+const (
+	warmupWindow = 5
+	warmupSpread = 0.2
+)
+
+// warmupDetector excludes the requests from the summary until the latency
+// stabilizes, or for max at most. The stable seconds found are excluded with
+// the warmup, the records not being kept to be collected afterwards.
+type warmupDetector struct {
+	// fixed is the warmup of --warmup, the latency being watched after it
+	// with --auto-warmup only
+	fixed   time.Duration
+	max     time.Duration
+	medians []float64
+	done    bool
+	stable  bool
+	// end is the elapsed time of the run when the warmup ended
+	end      time.Duration
+	excluded int64
+	// readBytes and writeBytes are the byte counters at the end of the warmup
+	readBytes  int64
+	writeBytes int64
+	// dropped is the count of the dropped arrivals at the end of the warmup
+	dropped int64
+}
+
+// WarmupReport is the warmup excluded from the summary, Stable being false
+// when it ran for the whole --auto-warmup without the latency stabilizing
+type WarmupReport struct {
+	Duration time.Duration
+	Excluded int64
+	Stable   bool
+	Auto     bool // detected with --auto-warmup
+}
+
+// observe reads the median latency of a tick, and returns whether the warmup
+// ends with it
+func (w *warmupDetector) observe(t *TickReport) bool {
+	if w.done || t.Elapsed < w.fixed {
+		return false
+	}
+	if w.max <= 0 {
+		// no later than the first record after it
+		w.done, w.end = true, w.fixed
+		return true
+	}
+	if t.Count == 0 || len(t.Percentiles) == 0 {
+		// an idle second restarts the window
+		w.medians = w.medians[:0]
+	} else {
+		w.medians = append(w.medians, float64(t.Percentiles[0]))
+		if len(w.medians) > warmupWindow {
+			w.medians = w.medians[1:]
+		}
+	}
+	if len(w.medians) == warmupWindow {
+		min, max, sum := w.medians[0], w.medians[0], 0.0
+		for _, m := range w.medians {
+			if m < min {
+				min = m
+			}
+			if m > max {
+				max = m
+			}
+			sum += m
+		}
+		w.stable = max-min <= warmupSpread*sum/warmupWindow
+	}
+	if w.stable || t.Elapsed >= w.max {
+		w.done, w.end = true, t.Elapsed
+	}
+	return w.done
+}
+
+// expire ends a --warmup without --auto-warmup at elapsed, rather than on the
+// next tick
+func (w *warmupDetector) expire(elapsed time.Duration) bool {
+	if w.done || w.max > 0 || w.fixed <= 0 || elapsed < w.fixed {
+		return false
+	}
+	w.done, w.end = true, w.fixed
+	return true
+}
+
+func (w *warmupDetector) report(elapsed time.Duration) *WarmupReport {
+	r := &WarmupReport{Duration: elapsed, Excluded: w.excluded, Stable: w.stable, Auto: w.max > 0}
+	if w.done {
+		r.Duration = w.end
+	}
+	return r
+}
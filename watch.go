@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// runLive is the condensed state of a run polled by the /watch page,
+// latencies in ms
+type runLive struct {
+	ID        string  `json:"id"`
+	Desc      string  `json:"desc"`
+	Done      bool    `json:"done"`
+	Aborted   string  `json:"aborted,omitempty"`
+	Elapsed   float64 `json:"elapsed"`  // seconds
+	Duration  float64 `json:"duration"` // seconds, 0 when unbounded
+	Requests  int64   `json:"requests,omitempty"`
+	Count     int64   `json:"count"`
+	RPS       float64 `json:"rps"` // of the last second
+	P99       float64 `json:"p99"` // of the last second
+	AvgRPS    float64 `json:"avgRps"`
+	ErrorRate float64 `json:"errorRate"` // failed requests and 5xx responses of the run, %
+}
+
+// lastTick is the report of the last second of the run, nil before the first
+func (s *StreamReport) lastTick() *TickReport {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if len(s.ticks) == 0 {
+		return nil
+	}
+	return s.ticks[len(s.ticks)-1]
+}
+
+// handleRunLive serves the live state of a run to the /watch page
+func (g *GUIServer) handleRunLive(ctx *fasthttp.RequestCtx, id string) {
+	ctx.SetContentType("application/json")
+	g.mu.Lock()
+	run := g.findRun(id)
+	var live runLive
+	var report *StreamReport
+	if run != nil {
+		live = runLive{ID: run.ID, Desc: run.Desc, Done: run.Done, Duration: run.duration.Seconds()}
+		if run.requests > 0 {
+			live.Requests = run.requests
+		}
+		report = run.report
+	}
+	g.mu.Unlock()
+	if run == nil {
+		ctx.SetStatusCode(404)
+		json.NewEncoder(ctx).Encode(map[string]string{"error": "run not found"})
+		return
+	}
+	snapshot := report.Snapshot()
+	live.Aborted, live.Count, live.AvgRPS = snapshot.Aborted, snapshot.Count, roundFloat(snapshot.RPS, 1)
+	live.Elapsed = roundFloat(snapshot.Elapsed.Seconds(), 1)
+	if snapshot.Count > 0 {
+		failed := errorCount(snapshot) + snapshot.Codes["5xx"]
+		live.ErrorRate = roundFloat(float64(failed)*100/float64(snapshot.Count), 2)
+	}
+	if t := report.lastTick(); t != nil {
+		if live.Done {
+			// the snapshot keeps counting after the end
+			live.Elapsed = roundFloat(t.Elapsed.Seconds(), 1)
+		} else {
+			live.RPS = roundFloat(t.RPS, 1)
+			for i, q := range quantiles {
+				if q == 0.99 && i < len(t.Percentiles) {
+					live.P99 = roundFloat(float64(t.Percentiles[i])/float64(time.Millisecond), 2)
+				}
+			}
+		}
+	}
+	json.NewEncoder(ctx).Encode(live)
+}
+
+// handleWatch serves the watch page of a run
+func (g *GUIServer) handleWatch(ctx *fasthttp.RequestCtx, id string) {
+	g.mu.Lock()
+	run := g.findRun(id)
+	g.mu.Unlock()
+	if run == nil {
+		ctx.Error("run not found", fasthttp.StatusNotFound)
+		return
+	}
+	ctx.SetContentType("text/html; charset=utf-8")
+	ctx.WriteString(strings.Replace(watchPageHTML, "{{RUN_ID}}", run.ID, 1))
+}
+
+// watchPageHTML is the read-only view of a run sized for phones, polling
+// its live state until it is done
+const watchPageHTML = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="UTF-8">
+<meta name="viewport" content="width=device-width, initial-scale=1.0">
+<meta name="theme-color" content="#0d0f17">
+<title>Plow — Watch</title>
+<style>
+:root{--bg:#0d0f17;--card:#1e2235;--border:#2e3250;--accent:#6c63ff;--accent2:#9b8fff;--green:#2dd4a0;--red:#ff6b7a;--yellow:#fbbf24;--text:#e2e8f0;--text2:#94a3b8;--text3:#64748b}
+*{margin:0;padding:0;box-sizing:border-box}
+body{font-family:-apple-system,'Inter',sans-serif;background:var(--bg);color:var(--text);padding:16px;max-width:520px;margin:0 auto}
+.top{display:flex;align-items:center;gap:8px;margin-bottom:6px}
+.logo{font-weight:700;font-size:20px;color:var(--accent2)}
+.state{margin-left:auto;font-size:13px;color:var(--text2);display:flex;align-items:center;gap:6px}
+.dot{width:9px;height:9px;border-radius:50%;background:var(--text3)}
+.dot.on{background:var(--green);box-shadow:0 0 8px var(--green)}
+.dot.bad{background:var(--red)}
+.desc{font-size:12px;color:var(--text3);margin-bottom:14px;word-break:break-all}
+.grid{display:grid;grid-template-columns:1fr 1fr;gap:10px}
+.card{background:var(--card);border:1px solid var(--border);border-radius:12px;padding:14px}
+.card.wide{grid-column:1/-1}
+.lbl{font-size:11px;font-weight:600;color:var(--text2);text-transform:uppercase;letter-spacing:.5px}
+.val{font-size:34px;font-weight:700;font-variant-numeric:tabular-nums;margin-top:2px}
+.val small{font-size:14px;font-weight:500;color:var(--text2)}
+.sub{font-size:12px;color:var(--text3)}
+.val.er{color:var(--red)}
+.bar{height:8px;background:var(--bg);border-radius:4px;overflow:hidden;margin:10px 0 6px}
+.fill{height:100%;width:0;background:linear-gradient(90deg,var(--accent),var(--accent2));transition:width .5s}
+.abort{color:var(--red);font-size:13px;margin-top:10px}
+</style>
+</head>
+<body>
+<div class="top"><span class="logo">plow</span><span class="state"><span class="dot" id="dot"></span><span id="state">Connecting…</span></span></div>
+<div class="desc" id="desc"></div>
+<div class="grid">
+  <div class="card"><div class="lbl">RPS</div><div class="val" id="rps">—</div><div class="sub" id="avg"></div></div>
+  <div class="card"><div class="lbl">P99</div><div class="val" id="p99">—</div><div class="sub">last second</div></div>
+  <div class="card"><div class="lbl">Errors</div><div class="val" id="err">—</div><div class="sub">of the run</div></div>
+  <div class="card"><div class="lbl">Requests</div><div class="val" id="count">—</div><div class="sub">&nbsp;</div></div>
+  <div class="card wide"><div class="lbl">Progress</div><div class="bar"><div class="fill" id="fill"></div></div><div class="sub" id="prog"></div></div>
+</div>
+<div class="abort" id="abort"></div>
+<script>
+const RUN_ID = '{{RUN_ID}}';
+// the token of a server with credentials, given as ?token= by the GUI page
+const TOKEN = new URLSearchParams(location.search).get('token');
+const $ = id => document.getElementById(id);
+const dur = s => { s = Math.round(s); const h = Math.floor(s/3600), m = Math.floor(s%3600/60), r = s%60;
+  return (h ? h+'h' : '') + (h||m ? m+'m' : '') + r+'s'; };
+const num = n => n >= 1e6 ? (n/1e6).toFixed(2)+'M' : n >= 1e4 ? (n/1e3).toFixed(1)+'k' : String(n);
+let tmr = null;
+async function poll(){
+  let d;
+  try{
+    const r = await fetch('/runs/'+RUN_ID+'/live', { headers: TOKEN ? {'Authorization': 'Bearer '+TOKEN} : {} });
+    d = await r.json();
+    if(!r.ok){ $('state').textContent = d.error || r.statusText; clearInterval(tmr); return; }
+  } catch(e){ $('state').textContent = 'Offline'; $('dot').className = 'dot bad'; return; }
+  $('desc').textContent = d.desc;
+  $('dot').className = 'dot' + (d.aborted ? ' bad' : d.done ? '' : ' on');
+  $('state').textContent = d.aborted ? 'Aborted' : d.done ? 'Done' : 'Running';
+  $('rps').textContent = d.done ? '—' : d.rps.toFixed(1);
+  $('avg').textContent = 'avg ' + d.avgRps.toFixed(1);
+  $('p99').innerHTML = d.done ? '—' : d.p99.toFixed(1) + '<small>ms</small>';
+  $('err').innerHTML = d.errorRate.toFixed(2) + '<small>%</small>';
+  $('err').className = 'val' + (d.errorRate > 0 ? ' er' : '');
+  $('count').textContent = num(d.count);
+  let pct = d.done ? 100 : d.duration > 0 ? Math.min(100, 100*d.elapsed/d.duration) : 0;
+  if(d.requests && !d.done) pct = Math.max(pct, Math.min(100, 100*d.count/d.requests));
+  $('fill').style.width = pct + '%';
+  $('prog').textContent = dur(d.elapsed) + (d.duration > 0 ? ' of ' + dur(d.duration) : '') +
+    (d.requests ? ' · ' + num(d.count) + ' of ' + num(d.requests) + ' req' : '') + (d.duration > 0 || d.requests ? ' · ' + pct.toFixed(0) + '%' : '');
+  $('abort').textContent = d.aborted || '';
+  document.title = (d.done ? '✓ ' : d.rps.toFixed(0) + ' rps · ') + 'Plow — Watch';
+  if(d.done) clearInterval(tmr);
+}
+poll();
+tmr = setInterval(poll, 2000);
+</script>
+</body>
+</html>
+`
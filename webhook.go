@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// webhook events, by the outcome of the run
+const (
+	webhookPassed  = "passed"
+	webhookFailed  = "failed" // a threshold or a stage failed
+	webhookAborted = "aborted"
+)
+
+// webhook is a --webhook notified of the end of each run, with a generic
+// JSON POST of its summary or a Slack message
+type webhook struct {
+	url   string
+	slack bool
+}
+
+// parseWebhook parses `[slack:]URL`, the urls of hooks.slack.com getting
+// Slack messages without the prefix
+func parseWebhook(s string) (*webhook, error) {
+	w := &webhook{url: s}
+	if rest, ok := strings.CutPrefix(s, "slack:"); ok {
+		w.url, w.slack = rest, true
+	}
+	u, err := url.Parse(w.url)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("webhook %q is not an http(s) url", s)
+	}
+	if u.Hostname() == "hooks.slack.com" {
+		w.slack = true
+	}
+	return w, nil
+}
+
+func parseWebhooks(specs []string) ([]*webhook, error) {
+	var res []*webhook
+	for _, s := range specs {
+		w, err := parseWebhook(s)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, w)
+	}
+	return res, nil
+}
+
+// host is the host of the webhook, logged instead of its url which often
+// holds a secret
+func (w *webhook) host() string {
+	if u, err := url.Parse(w.url); err == nil {
+		return u.Host
+	}
+	return ""
+}
+
+// webhookEvent is the JSON posted to the generic webhooks
+type webhookEvent struct {
+	Event   string   `json:"event"`
+	RunID   string   `json:"runId,omitempty"`
+	Desc    string   `json:"desc"`
+	Reason  string   `json:"reason,omitempty"` // of the abort, or the failed thresholds
+	Summary *Summary `json:"summary"`
+}
+
+func newWebhookEvent(runID, desc string, s *Summary) *webhookEvent {
+	ev := &webhookEvent{Event: webhookPassed, RunID: runID, Desc: desc, Summary: s}
+	var failed []string
+	for _, r := range s.Thresholds {
+		if !r.Passed {
+			failed = append(failed, fmt.Sprintf("%s (%s)", r.Threshold, r.Actual))
+		}
+	}
+	for i, st := range s.Stages {
+		if !st.Passed {
+			failed = append(failed, fmt.Sprintf("stage #%d %s", i+1, st.Stage))
+		}
+	}
+	switch {
+	case s.Aborted != "":
+		ev.Event, ev.Reason = webhookAborted, s.Aborted
+	case len(failed) > 0:
+		ev.Event, ev.Reason = webhookFailed, strings.Join(failed, ", ")
+	}
+	return ev
+}
+
+// slackText is the message of ev for Slack
+func (ev *webhookEvent) slackText() string {
+	icon := map[string]string{webhookPassed: "✅", webhookFailed: "❌", webhookAborted: "⛔"}[ev.Event]
+	s := ev.Summary
+	var errors int64
+	for _, n := range s.Errors {
+		errors += n
+	}
+	text := fmt.Sprintf("%s plow run %s: %s\n%d requests in %gs, %.1f req/s, P99 %g%s, %d errors",
+		icon, ev.Event, ev.Desc, s.Count, s.Elapsed, s.RPS, s.Percentiles["P99"], s.LatencyUnit, errors)
+	if ev.Reason != "" {
+		text += "\n" + ev.Reason
+	}
+	return text
+}
+
+var webhookClient = &http.Client{Timeout: 10 * time.Second}
+
+func (w *webhook) send(ev *webhookEvent) error {
+	var payload interface{} = ev
+	if w.slack {
+		payload = map[string]string{"text": ev.slackText()}
+	}
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(payload); err != nil {
+		return err
+	}
+	resp, err := webhookClient.Post(w.url, "application/json", &body)
+	if err != nil {
+		// the error repeats the url
+		if ue, ok := err.(*url.Error); ok {
+			err = ue.Err
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s", resp.Status)
+	}
+	return nil
+}
+
+// notifyWebhooks sends ev to each webhook, their failures only being logged
+// as the run is over
+func notifyWebhooks(hooks []*webhook, ev *webhookEvent) {
+	for _, w := range hooks {
+		if err := w.send(ev); err != nil {
+			fmt.Fprintf(os.Stderr, "plow: webhook to %s failed: %s\n", w.host(), err)
+		}
+	}
+}